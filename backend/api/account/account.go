@@ -2,7 +2,12 @@
 package account
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"portal/middleware"
+	"portal/model"
+	"portal/pkg/region"
 	"portal/pkg/response"
 	"portal/repository"
 	"portal/service/account"
@@ -12,7 +17,8 @@ import (
 
 // DeleteRequest 删除账号请求结构
 type DeleteRequest struct {
-	AccountIDs []string `json:"account_ids" binding:"required,min=1"`
+	AccountIDs         []string `json:"account_ids" binding:"required,min=1"`
+	TerminateInstances bool     `json:"terminate_instances"` // 可选,为true时先终止账号在AWS上的实例再删除，默认保留实例
 }
 
 // List 获取账号列表
@@ -55,6 +61,24 @@ func Delete(c *gin.Context) {
 	}
 
 	accountService := account.NewAccountService(repository.GetDB())
+	if req.TerminateInstances {
+		terminated, err := accountService.DeleteWithInstances(c, userID, req.AccountIDs)
+		if err != nil {
+			// 终止失败时账号未删除，同时返回已处理的实例供用户确认
+			c.JSON(http.StatusInternalServerError, response.Response{
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+				Data:    gin.H{"terminated": terminated},
+			})
+			return
+		}
+		response.Success(c, http.StatusOK, gin.H{
+			"message":    "账号删除成功",
+			"terminated": terminated,
+		})
+		return
+	}
+
 	err := accountService.Delete(userID, req.AccountIDs)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err.Error())
@@ -128,11 +152,237 @@ func ApplyHK(c *gin.Context) {
 	response.Success(c, http.StatusOK, results)
 }
 
+// RegionStatusRequest 查询账号区域开通状态请求结构
+type RegionStatusRequest struct {
+	AccountIDs []string `json:"account_ids" binding:"required,min=1"`
+	Region     string   `json:"region" binding:"required"` // 区域代码或别名
+}
+
+// RegionStatus 管理员查询账号在指定区域的开通状态
+func RegionStatus(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req RegionStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数无效:"+err.Error())
+		return
+	}
+
+	regionCode := region.Normalize(req.Region)
+	if !region.IsSupported(regionCode) {
+		response.Error(c, http.StatusBadRequest, "不支持的区域: "+req.Region)
+		return
+	}
+
+	accountService := account.NewAccountService(repository.GetDB())
+	results, err := accountService.CheckRegionStatuses(c.Request.Context(), req.AccountIDs, regionCode)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, results)
+}
+
+// ReviveRequest 恢复失效账号请求结构
+type ReviveRequest struct {
+	AccountIDs []string `json:"account_ids" binding:"required,min=1"`
+}
+
+// Revive 管理员重新检测失效账号，检测通过的重新加入账号池
+func Revive(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req ReviveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数无效:"+err.Error())
+		return
+	}
+
+	accountService := account.NewAccountService(repository.GetDB())
+	results, err := accountService.Revive(c.Request.Context(), req.AccountIDs)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, results)
+}
+
+// SetRegionRequest 设置账号区域请求
+type SetRegionRequest struct {
+	AccountIDs []string `json:"account_ids" binding:"required,min=1"`
+	Region     string   `json:"region" binding:"required"`
+}
+
+// SetRegion 管理员修改账号区域，检查目标区域已开通后更新账号池
+func SetRegion(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req SetRegionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数无效:"+err.Error())
+		return
+	}
+
+	regionCode := region.Normalize(req.Region)
+	if !region.IsSupported(regionCode) {
+		response.Error(c, http.StatusBadRequest, "不支持的区域: "+req.Region)
+		return
+	}
+
+	accountService := account.NewAccountService(repository.GetDB())
+	results, err := accountService.SetRegion(c.Request.Context(), req.AccountIDs, regionCode)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, results)
+}
+
+// SetEnabledRequest 启用或停用账号请求结构
+type SetEnabledRequest struct {
+	AccountIDs []string `json:"account_ids" binding:"required,min=1"`
+	Enabled    *bool    `json:"enabled" binding:"required"`
+}
+
+// SetEnabled 管理员启用或停用账号，停用的账号不删除但退出补机轮换
+func SetEnabled(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req SetEnabledRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数无效:"+err.Error())
+		return
+	}
+
+	accountService := account.NewAccountService(repository.GetDB())
+	updated, err := accountService.SetEnabled(req.AccountIDs, *req.Enabled)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"updated": updated,
+		"enabled": *req.Enabled,
+	})
+}
+
+// QueryFailedList 管理员查看检测结果为"查询失败"的账号
+func QueryFailedList(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	accountService := account.NewAccountService(repository.GetDB())
+	accounts, err := accountService.ListQueryFailedAccounts()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"total": len(accounts),
+		"list":  accounts,
+	})
+}
+
+// SelfTestRequest 凭证自检请求结构，提供账号ID或key1/key2其中之一
+type SelfTestRequest struct {
+	AccountID string `json:"account_id"`
+	Key1      string `json:"key1"`
+	Key2      string `json:"key2"`
+}
+
+// SelfTest 检测AWS凭证是否可用，返回配额和各区域开通状态
+func SelfTest(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
+		return
+	}
+
+	var req SelfTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数无效:"+err.Error())
+		return
+	}
+	if req.AccountID == "" && (req.Key1 == "" || req.Key2 == "") {
+		response.Error(c, http.StatusBadRequest, "需要提供account_id或key1和key2")
+		return
+	}
+
+	accountService := account.NewAccountService(repository.GetDB())
+	result, err := accountService.SelfTest(c.Request.Context(), userID, req.AccountID, req.Key1, req.Key2)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, result)
+}
+
+// ReconcileVMCountRequest 校准实例数量请求结构
+type ReconcileVMCountRequest struct {
+	AccountIDs []string `json:"account_ids"` // 为空时校准所有有效账号
+}
+
+// ReconcileVMCount 管理员按AWS实际运行数量校准账号的vm_count
+func ReconcileVMCount(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req ReconcileVMCountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Error(c, http.StatusBadRequest, "请求参数无效:"+err.Error())
+		return
+	}
+
+	accountService := account.NewAccountService(repository.GetDB())
+	results, err := accountService.ReconcileVMCount(c.Request.Context(), req.AccountIDs)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	updated := 0
+	for _, result := range results {
+		if result.Updated {
+			updated++
+		}
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"total":   len(results),
+		"updated": updated,
+		"list":    results,
+	})
+}
+
 // CreateInstanceRequest 创建实例请求结构
 type CreateInstanceRequest struct {
-	AccountIDs []string `json:"account_ids" binding:"required"`
-	Region     string   `json:"region"` // 可选,默认ap-east-1
-	Count      int32    `json:"count"`  // 可选,默认1
+	AccountIDs   []string `json:"account_ids" binding:"required"`
+	Region       string   `json:"region"`        // 可选,默认ap-east-1
+	Count        int32    `json:"count"`         // 可选,默认1
+	AllowPartial bool     `json:"allow_partial"` // 可选,容量不足时尽可能多地启动而不是整批失败
+	Distribute   bool     `json:"distribute"`    // 可选,为true时count为总数，按账号余量分散到所选账号
 }
 
 // CreateInstance 创建实例接口
@@ -152,14 +402,7 @@ func CreateInstance(c *gin.Context) {
 
 	// 处理区域参数，支持中文和英文简写
 	if req.Region != "" {
-		switch req.Region {
-		case "jp", "japan", "日本":
-			req.Region = "ap-northeast-3" // 日本区域
-		case "sg", "singapore", "新加坡":
-			req.Region = "ap-southeast-1" // 新加坡区域
-		case "hk", "hongkong", "香港":
-			req.Region = "ap-east-1" // 香港区域
-		}
+		req.Region = region.Normalize(req.Region)
 	}
 
 	// 如果未指定区域，默认使用账号的区域，服务层会处理这个逻辑
@@ -171,8 +414,26 @@ func CreateInstance(c *gin.Context) {
 
 	// 调用服务
 	svc := account.NewAccountService(repository.GetDB())
-	results, err := svc.CreateInstance(c, userID, req.AccountIDs, req.Region, req.Count)
+	if req.Distribute {
+		result, err := svc.CreateInstanceDistributed(c, userID, req.AccountIDs, req.Region, req.Count)
+		if err != nil {
+			if errors.Is(err, model.ErrRegionNotAllowed) {
+				response.Error(c, http.StatusForbidden, "创建实例失败:"+err.Error())
+				return
+			}
+			response.Error(c, http.StatusInternalServerError, "创建实例失败:"+err.Error())
+			return
+		}
+		response.Success(c, http.StatusOK, result)
+		return
+	}
+
+	results, err := svc.CreateInstance(c, userID, req.AccountIDs, req.Region, req.Count, req.AllowPartial)
 	if err != nil {
+		if errors.Is(err, model.ErrRegionNotAllowed) {
+			response.Error(c, http.StatusForbidden, "创建实例失败:"+err.Error())
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, "创建实例失败:"+err.Error())
 		return
 	}
@@ -180,6 +441,46 @@ func CreateInstance(c *gin.Context) {
 	response.Success(c, http.StatusOK, results)
 }
 
+// RecreateInstanceRequest 重建实例请求结构
+type RecreateInstanceRequest struct {
+	AccountID  string `json:"account_id" binding:"required"`
+	InstanceID string `json:"instance_id" binding:"required"`
+	Region     string `json:"region"`   // 可选，默认使用账号区域
+	KeepEIP    bool   `json:"keep_eip"` // 是否保留原弹性IP并绑定到新实例
+}
+
+// RecreateInstance 删除实例并按当前设置重建
+func RecreateInstance(c *gin.Context) {
+	var req RecreateInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误:"+err.Error())
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
+		return
+	}
+
+	if req.Region != "" {
+		req.Region = region.Normalize(req.Region)
+	}
+
+	svc := account.NewAccountService(repository.GetDB())
+	result, err := svc.RecreateInstance(c, userID, req.AccountID, req.Region, req.InstanceID, req.KeepEIP)
+	if err != nil {
+		if errors.Is(err, model.ErrRegionNotAllowed) {
+			response.Error(c, http.StatusForbidden, "重建实例失败:"+err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "重建实例失败:"+err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, result)
+}
+
 // CleanMicroRequest 清理t2.micro和t3.micro实例请求结构
 type CleanMicroRequest struct {
 	AccountIDs []string `json:"account_ids" binding:"required,min=1"`
@@ -214,3 +515,47 @@ func CleanT3Micro(c *gin.Context) {
 
 	response.Success(c, http.StatusOK, results)
 }
+
+// CleanAllMicroRequest 清理全部账号micro实例请求
+type CleanAllMicroRequest struct {
+	UserID   string `json:"user_id"`   // 管理员可指定用户，不传则为当前用户
+	AllUsers bool   `json:"all_users"` // 管理员可清理全部用户的账号
+}
+
+// CleanAllMicro 清理当前用户所有有效账号中的t2.micro和t3.micro实例，无需传入账号ID
+func CleanAllMicro(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
+		return
+	}
+
+	var req CleanAllMicroRequest
+	// 请求体可以为空
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, "请求参数无效:"+err.Error())
+			return
+		}
+	}
+
+	// 指定其他用户或全部用户时需要管理员权限
+	targetUserID := userID
+	if req.AllUsers || (req.UserID != "" && req.UserID != userID) {
+		if !middleware.RequireAdmin(c) {
+			return
+		}
+		if req.UserID != "" {
+			targetUserID = req.UserID
+		}
+	}
+
+	accountService := account.NewAccountService(repository.GetDB())
+	results, err := accountService.CleanAllMicroInstances(c.Request.Context(), targetUserID, req.AllUsers)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, results)
+}
@@ -12,7 +12,9 @@ import (
 
 // ImportRequest 导入请求结构
 type ImportRequest struct {
-	Content string `json:"content" binding:"required"` // 账号列表内容
+	Content       string `json:"content" binding:"required"` // 账号列表内容
+	LenientRegion bool   `json:"lenient_region"`             // 无法识别的区域是否回退为默认区域，默认视为格式错误
+	DryRun        bool   `json:"dry_run"`                    // 只预览导入结果，不实际写入
 }
 
 // ImportAccounts 处理账号导入请求
@@ -32,7 +34,7 @@ func ImportAccounts(c *gin.Context) {
 
 	importService := batchimport.NewImportService(repository.GetDB())
 	// 传入用户ID
-	result, err := importService.ImportAccounts(req.Content, userID)
+	result, err := importService.ImportAccounts(req.Content, userID, req.LenientRegion, req.DryRun)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, err.Error())
 		return
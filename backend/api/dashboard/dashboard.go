@@ -0,0 +1,90 @@
+// api/dashboard/dashboard.go
+package dashboard
+
+import (
+	"net/http"
+	"portal/model"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+	"portal/pkg/response"
+	"portal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegionSummary 单个区域的概览
+type RegionSummary struct {
+	Region         string `json:"region"`
+	OnlineCount    int    `json:"online_count"`    // 在线实例数
+	Threshold      int    `json:"threshold"`       // 配置的阈值
+	PendingMakeup  int    `json:"pending_makeup"`  // 尚未完成的补机数量
+	BelowThreshold bool   `json:"below_threshold"` // 在线实例数是否低于阈值
+}
+
+// Summary 用户仪表盘概览
+type Summary struct {
+	Regions            []RegionSummary `json:"regions"`
+	TotalOnline        int             `json:"total_online"`
+	TotalPendingMakeup int             `json:"total_pending_makeup"`
+	MonitorEnabled     bool            `json:"monitor_enabled"` // 监控开关
+	TgEnabled          bool            `json:"tg_enabled"`      // TG通知开关
+	TgBound            bool            `json:"tg_bound"`        // 是否已绑定TG
+	IPRangeEnabled     bool            `json:"ip_range_enabled"`
+	IPRanges           gin.H           `json:"ip_ranges"` // 各区域IP段
+}
+
+// GetDashboard 获取当前用户的仪表盘概览：各区域在线实例、阈值、补机进度和通知状态
+func GetDashboard(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
+		return
+	}
+
+	config, err := model.GetMonitorByUserID(repository.GetDB(), userID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取监控配置失败")
+		return
+	}
+
+	pending := pool.GetMakeupQueue().GetPendingCountByUser(userID)
+	onlineCount := func(regionCode string) int {
+		if pool.GlobalPool == nil {
+			return 0
+		}
+		return len(pool.GlobalPool.GetInstancesByUserIDAndRegion(userID, regionCode))
+	}
+
+	response.Success(c, http.StatusOK, buildSummary(config, onlineCount, pending))
+}
+
+// buildSummary 汇总监控配置、各区域在线实例数和待补机数量
+func buildSummary(config *model.Monitor, onlineCountOf func(regionCode string) int, pending map[string]int) Summary {
+	summary := Summary{
+		MonitorEnabled: config.IsEnabled,
+		TgEnabled:      config.IsTgEnabled,
+		TgBound:        config.TgUserID != "",
+		IPRangeEnabled: config.IsIPRangeEnabled,
+		IPRanges: gin.H{
+			region.HK: config.IPRange,
+			region.JP: config.JpIPRange,
+			region.SG: config.SgIPRange,
+		},
+	}
+
+	for _, regionCode := range region.Codes() {
+		onlineCount := onlineCountOf(regionCode)
+		threshold := model.GetThresholdByRegion(config, regionCode)
+
+		summary.Regions = append(summary.Regions, RegionSummary{
+			Region:         regionCode,
+			OnlineCount:    onlineCount,
+			Threshold:      threshold,
+			PendingMakeup:  pending[regionCode],
+			BelowThreshold: onlineCount < threshold,
+		})
+		summary.TotalOnline += onlineCount
+		summary.TotalPendingMakeup += pending[regionCode]
+	}
+	return summary
+}
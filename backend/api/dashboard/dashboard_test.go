@@ -0,0 +1,68 @@
+package dashboard
+
+import (
+	"testing"
+
+	"portal/model"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+)
+
+func TestBuildSummary(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	const userID = "dashboard-user"
+	seed := &model.Monitor{
+		UserID: userID, Threshold: 3, JpThreshold: 1, SgThreshold: 0,
+		IsEnabled: true, IsTgEnabled: true, TgUserID: "10001",
+		IsIPRangeEnabled: true, IPRange: "16.162.0.0/16",
+		IsHkEnabled: true, IsJpEnabled: true, IsSgEnabled: true,
+	}
+	if err := db.Create(seed).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+	config, err := model.GetMonitorByUserID(db, userID)
+	if err != nil {
+		t.Fatalf("读取监控配置失败: %v", err)
+	}
+
+	p := pool.NewPool()
+	for _, inst := range []*pool.InstanceMetadata{
+		{InstanceID: "i-dash-hk-1", UserID: userID, Region: region.HK},
+		{InstanceID: "i-dash-jp-1", UserID: userID, Region: region.JP},
+		{InstanceID: "i-dash-jp-2", UserID: userID, Region: region.JP},
+		{InstanceID: "i-dash-other", UserID: "other-user", Region: region.HK},
+	} {
+		p.UpdateInstance(inst)
+	}
+	onlineCount := func(regionCode string) int {
+		return len(p.GetInstancesByUserIDAndRegion(userID, regionCode))
+	}
+	pending := map[string]int{region.HK: 2}
+
+	summary := buildSummary(config, onlineCount, pending)
+
+	if !summary.MonitorEnabled || !summary.TgEnabled || !summary.TgBound || !summary.IPRangeEnabled {
+		t.Errorf("通知状态 = %+v", summary)
+	}
+	if summary.IPRanges[region.HK] != "16.162.0.0/16" || summary.IPRanges[region.JP] != "" {
+		t.Errorf("IP段 = %v", summary.IPRanges)
+	}
+	if summary.TotalOnline != 3 || summary.TotalPendingMakeup != 2 {
+		t.Errorf("在线总数 = %d, 待补机总数 = %d, want 3 和 2", summary.TotalOnline, summary.TotalPendingMakeup)
+	}
+
+	want := map[string]RegionSummary{
+		region.HK: {Region: region.HK, OnlineCount: 1, Threshold: 3, PendingMakeup: 2, BelowThreshold: true},
+		region.JP: {Region: region.JP, OnlineCount: 2, Threshold: 1},
+		region.SG: {Region: region.SG},
+	}
+	if len(summary.Regions) != len(region.Codes()) {
+		t.Fatalf("区域数 = %d, want %d", len(summary.Regions), len(region.Codes()))
+	}
+	for _, got := range summary.Regions {
+		if expected, ok := want[got.Region]; ok && got != expected {
+			t.Errorf("区域%s概览 = %+v, want %+v", got.Region, got, expected)
+		}
+	}
+}
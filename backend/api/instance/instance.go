@@ -3,7 +3,10 @@ package instance
 
 import (
 	"net/http"
+	"portal/middleware"
+	"portal/model"
 	"portal/pkg/pool"
+	"portal/pkg/region"
 	"portal/pkg/response"
 	"portal/repository"
 	"portal/service/instance"
@@ -16,6 +19,7 @@ type DeleteInstanceItem struct {
 	AccountID  string `json:"account_id" binding:"required"`
 	Region     string `json:"region"` // 移除 required 标签
 	InstanceID string `json:"instance_id" binding:"required"`
+	KeepEIP    bool   `json:"keep_eip"` // 是否保留弹性IP，用于删除后重建
 }
 
 // DeleteRequest 删除实例请求结构
@@ -41,23 +45,14 @@ func Delete(c *gin.Context) {
 	serviceInstances := make([]instance.DeleteInstanceItem, len(req.Instances))
 	for i, item := range req.Instances {
 		// 处理区域参数，支持中文和英文简写
-		region := item.Region
-		if region != "" {
-			switch region {
-			case "jp", "japan", "日本":
-				region = "ap-northeast-3" // 日本区域
-			case "sg", "singapore", "新加坡":
-				region = "ap-southeast-1" // 新加坡区域
-			case "hk", "hongkong", "香港":
-				region = "ap-east-1" // 香港区域
-			}
-		}
+		itemRegion := region.Normalize(item.Region)
 		// 不再设置默认区域，让服务层根据账号信息决定
 
 		serviceInstances[i] = instance.DeleteInstanceItem{
 			AccountID:  item.AccountID,
-			Region:     region,
+			Region:     itemRegion,
 			InstanceID: item.InstanceID,
+			KeepEIP:    item.KeepEIP,
 		}
 	}
 
@@ -101,22 +96,12 @@ func ChangeIP(c *gin.Context) {
 	serviceInstances := make([]instance.ChangeIPItem, len(req.Instances))
 	for i, item := range req.Instances {
 		// 处理区域参数，支持中文和英文简写
-		region := item.Region
-		if region != "" {
-			switch region {
-			case "jp", "japan", "日本":
-				region = "ap-northeast-3" // 日本区域
-			case "sg", "singapore", "新加坡":
-				region = "ap-southeast-1" // 新加坡区域
-			case "hk", "hongkong", "香港":
-				region = "ap-east-1" // 香港区域
-			}
-		}
+		itemRegion := region.Normalize(item.Region)
 		// 不再设置默认区域，让服务层根据账号信息决定
 
 		serviceInstances[i] = instance.ChangeIPItem{
 			AccountID:  item.AccountID,
-			Region:     region,
+			Region:     itemRegion,
 			InstanceID: item.InstanceID,
 		}
 	}
@@ -140,6 +125,150 @@ func ChangeIP(c *gin.Context) {
 	response.Success(c, http.StatusOK, results)
 }
 
+// BulkOperationRequest 按用户和区域批量操作实例请求结构
+type BulkOperationRequest struct {
+	UserID  string `json:"user_id"` // 可选，默认当前用户，操作其他用户需要管理员权限
+	Region  string `json:"region" binding:"required"`
+	Action  string `json:"action" binding:"required,oneof=delete change-ip"`
+	KeepEIP bool   `json:"keep_eip"` // 仅删除时有效
+}
+
+// BulkOperation 根据连接池中的在线实例，对指定用户在指定区域的全部实例执行删除或更换IP
+func BulkOperation(c *gin.Context) {
+	var req BulkOperationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误:"+err.Error())
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
+		return
+	}
+
+	// 操作其他用户的实例需要管理员权限
+	targetUserID := userID
+	if req.UserID != "" && req.UserID != userID {
+		if !middleware.RequireAdmin(c) {
+			return
+		}
+		targetUserID = req.UserID
+	}
+
+	regionCode := region.Normalize(req.Region)
+	if !region.IsSupported(regionCode) {
+		response.Error(c, http.StatusBadRequest, "不支持的区域: "+req.Region)
+		return
+	}
+
+	if pool.GlobalPool == nil {
+		response.Error(c, http.StatusInternalServerError, "连接池未初始化")
+		return
+	}
+
+	// 从连接池解析目标实例
+	instances := pool.GlobalPool.GetInstancesByUserIDAndRegion(targetUserID, regionCode)
+	if len(instances) == 0 {
+		response.Success(c, http.StatusOK, gin.H{
+			"user_id": targetUserID,
+			"region":  regionCode,
+			"action":  req.Action,
+			"results": []interface{}{},
+		})
+		return
+	}
+
+	// 只操作仍归属该用户的账号下的实例
+	accountIDs := make([]string, 0, len(instances))
+	seen := make(map[string]bool)
+	for _, inst := range instances {
+		if !seen[inst.AccountID] {
+			seen[inst.AccountID] = true
+			accountIDs = append(accountIDs, inst.AccountID)
+		}
+	}
+	ownedAccounts, err := model.GetAccountKeysByIDs(repository.GetDB(), targetUserID, accountIDs)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "验证账号归属失败:"+err.Error())
+		return
+	}
+	owned := make(map[string]bool, len(ownedAccounts))
+	for _, acc := range ownedAccounts {
+		owned[acc.ID] = true
+	}
+
+	var skipped []string
+	svc := instance.NewInstanceService(repository.GetDB())
+
+	switch req.Action {
+	case "delete":
+		items := make([]instance.DeleteInstanceItem, 0, len(instances))
+		for _, inst := range instances {
+			if !owned[inst.AccountID] {
+				skipped = append(skipped, inst.InstanceID)
+				continue
+			}
+			items = append(items, instance.DeleteInstanceItem{
+				AccountID:  inst.AccountID,
+				Region:     regionCode,
+				InstanceID: inst.InstanceID,
+				KeepEIP:    req.KeepEIP,
+			})
+		}
+
+		results, err := svc.Delete(c, targetUserID, items)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "删除实例失败:"+err.Error())
+			return
+		}
+
+		response.Success(c, http.StatusOK, gin.H{
+			"user_id": targetUserID,
+			"region":  regionCode,
+			"action":  req.Action,
+			"results": results,
+			"skipped": skipped,
+		})
+
+	case "change-ip":
+		items := make([]instance.ChangeIPItem, 0, len(instances))
+		for _, inst := range instances {
+			if !owned[inst.AccountID] {
+				skipped = append(skipped, inst.InstanceID)
+				continue
+			}
+			items = append(items, instance.ChangeIPItem{
+				AccountID:  inst.AccountID,
+				Region:     regionCode,
+				InstanceID: inst.InstanceID,
+			})
+		}
+
+		results, err := svc.ChangeIP(c, targetUserID, items)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "更换IP失败:"+err.Error())
+			return
+		}
+
+		// 更换IP成功后，触发IP变更事件
+		eventManager := pool.GetEventManager()
+		for _, result := range results {
+			if result.Status == "成功" && result.NewIP != "" {
+				eventManager.TriggerIPChangeEvent(result.InstanceID, result.NewIP)
+			}
+		}
+
+		response.Success(c, http.StatusOK, gin.H{
+			"user_id": targetUserID,
+			"region":  regionCode,
+			"action":  req.Action,
+			"results": results,
+			"skipped": skipped,
+		})
+	}
+}
+
 // ListAccounts 获取账号列表
 func ListAccounts(c *gin.Context) {
 	userID := c.GetString("user_id")
@@ -174,14 +303,7 @@ func ListInstances(c *gin.Context) {
 
 	// 如果提供了region参数，处理区域名称映射
 	if req.Region != "" {
-		switch req.Region {
-		case "jp", "japan", "日本":
-			req.Region = "ap-northeast-3" // 日本区域
-		case "sg", "singapore", "新加坡":
-			req.Region = "ap-southeast-1" // 新加坡区域
-		case "hk", "hongkong", "香港":
-			req.Region = "ap-east-1" // 香港区域
-		}
+		req.Region = region.Normalize(req.Region)
 	}
 	// 不再设置默认区域，让服务层根据账号信息决定使用哪个区域
 
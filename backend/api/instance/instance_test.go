@@ -0,0 +1,119 @@
+package instance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"portal/model"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+	"portal/repository"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// startFakeEC2 模拟EC2接口，返回终止过的实例ID
+func startFakeEC2(t *testing.T) func() []string {
+	t.Helper()
+	var (
+		mu         sync.Mutex
+		terminated []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		action := r.PostForm.Get("Action")
+		if action == "TerminateInstances" {
+			mu.Lock()
+			terminated = append(terminated, r.PostForm.Get("InstanceId.1"))
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId></%sResponse>`, action, action)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		ids := append([]string(nil), terminated...)
+		sort.Strings(ids)
+		return ids
+	}
+}
+
+func TestBulkOperationActsOnUserRegionInstances(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	hk, jp := region.HK, region.JP
+	for _, acc := range []model.Account{
+		{ID: "9431", UserID: "bulk-user", Key1: "AKIA9431", Key2: "secret", Region: &hk},
+		{ID: "9432", UserID: "bulk-user", Key1: "AKIA9432", Key2: "secret", Region: &jp},
+		{ID: "9433", UserID: "other-user", Key1: "AKIA9433", Key2: "secret", Region: &hk},
+	} {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+	}
+	terminated := startFakeEC2(t)
+
+	old := pool.GlobalPool
+	pool.GlobalPool = pool.NewPool()
+	t.Cleanup(func() { pool.GlobalPool = old })
+	for _, inst := range []*pool.InstanceMetadata{
+		{InstanceID: "i-hk-1", UserID: "bulk-user", AccountID: "9431", Region: hk},
+		{InstanceID: "i-hk-2", UserID: "bulk-user", AccountID: "9431", Region: hk},
+		{InstanceID: "i-jp-1", UserID: "bulk-user", AccountID: "9432", Region: jp},
+		{InstanceID: "i-other", UserID: "other-user", AccountID: "9433", Region: hk},
+		// 上报的用户与账号归属不一致的实例不应被操作
+		{InstanceID: "i-foreign", UserID: "bulk-user", AccountID: "9433", Region: hk},
+	} {
+		pool.GlobalPool.UpdateInstance(inst)
+	}
+
+	body, _ := json.Marshal(BulkOperationRequest{Region: "香港", Action: "delete"})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/instances/operations", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", "bulk-user")
+	BulkOperation(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, 响应 %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data struct {
+			Region  string `json:"region"`
+			Results []struct {
+				InstanceID string `json:"instance_id"`
+				Status     string `json:"status"`
+			} `json:"results"`
+			Skipped []string `json:"skipped"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if resp.Data.Region != hk || len(resp.Data.Results) != 2 {
+		t.Fatalf("响应 = %s, 期望操作香港区的2台实例", w.Body.String())
+	}
+	for _, result := range resp.Data.Results {
+		if result.Status != "成功" {
+			t.Errorf("实例%s删除状态 = %s", result.InstanceID, result.Status)
+		}
+	}
+	if len(resp.Data.Skipped) != 1 || resp.Data.Skipped[0] != "i-foreign" {
+		t.Errorf("跳过的实例 = %v, want [i-foreign]", resp.Data.Skipped)
+	}
+	if got := terminated(); len(got) != 2 || got[0] != "i-hk-1" || got[1] != "i-hk-2" {
+		t.Fatalf("终止的实例 = %v, want [i-hk-1 i-hk-2]", got)
+	}
+}
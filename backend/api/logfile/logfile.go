@@ -0,0 +1,98 @@
+// api/logfile/logfile.go
+package logfile
+
+import (
+	"net/http"
+	"path/filepath"
+	"portal/middleware"
+	"portal/pkg/logger"
+	"portal/pkg/response"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 日志尾部行数限制
+const (
+	defaultTailLines = 200
+	maxTailLines     = 10000
+)
+
+// ListFiles 管理员接口：列出当前日志和轮转后的日志文件
+func ListFiles(c *gin.Context) {
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	files, err := logger.ListLogFiles()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"total": len(files),
+		"list":  files,
+	})
+}
+
+// Tail 管理员接口：返回日志文件的最后N行
+// 参数: file 日志文件名（默认当前日志），lines 行数（默认200，最大10000）
+func Tail(c *gin.Context) {
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	path, err := logger.ResolveLogFile(c.Query("file"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	lines := defaultTailLines
+	if value := c.Query("lines"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			response.Error(c, http.StatusBadRequest, "lines参数无效")
+			return
+		}
+		lines = n
+	}
+	if lines > maxTailLines {
+		lines = maxTailLines
+	}
+
+	result, err := logger.TailLogFile(path, lines)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// format=text 时直接返回纯文本，便于下载
+	if c.Query("format") == "text" {
+		c.String(http.StatusOK, strings.Join(result, "\n"))
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"file":  filepath.Base(path),
+		"total": len(result),
+		"lines": result,
+	})
+}
+
+// Download 管理员接口：下载完整的日志文件
+func Download(c *gin.Context) {
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	path, err := logger.ResolveLogFile(c.Query("file"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.FileAttachment(path, filepath.Base(path))
+}
@@ -3,16 +3,20 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"portal/middleware"
 	"portal/model"
+	"portal/pkg/logger"
 	"portal/pkg/pool"
+	"portal/pkg/region"
 	"portal/pkg/response"
 	"portal/pkg/tg"
 	"portal/repository"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,31 +24,51 @@ import (
 
 // UpdateConfigRequest 更新配置请求结构
 type UpdateConfigRequest struct {
-	Threshold        int    `json:"threshold"`           // 香港区阈值
-	JpThreshold      int    `json:"jp_threshold"`        // 日本区阈值
-	SgThreshold      int    `json:"sg_threshold"`        // 新加坡区阈值
-	IsEnabled        bool   `json:"is_enabled"`          // 开关状态
-	IsTgEnabled      bool   `json:"is_tg_enabled"`       // TG通知开关
-	TgUserID         string `json:"tg_user_id"`          // TG用户ID
-	IsIPRangeEnabled bool   `json:"is_ip_range_enabled"` // IP段限制开关
-	IPRange          string `json:"ip_range"`            // 香港IP段
-	JpIPRange        string `json:"jp_ip_range"`         // 日本IP段
-	SgIPRange        string `json:"sg_ip_range"`         // 新加坡IP段
+	Threshold          int            `json:"threshold"`            // 香港区阈值
+	JpThreshold        int            `json:"jp_threshold"`         // 日本区阈值
+	SgThreshold        int            `json:"sg_threshold"`         // 新加坡区阈值
+	IsEnabled          bool           `json:"is_enabled"`           // 开关状态
+	IsHkEnabled        *bool          `json:"is_hk_enabled"`        // 香港区补机开关，不传则保持原值
+	IsJpEnabled        *bool          `json:"is_jp_enabled"`        // 日本区补机开关，不传则保持原值
+	IsSgEnabled        *bool          `json:"is_sg_enabled"`        // 新加坡区补机开关，不传则保持原值
+	IsTgEnabled        bool           `json:"is_tg_enabled"`        // TG通知开关
+	TgUserID           string         `json:"tg_user_id"`           // TG用户ID
+	IsIPRangeEnabled   bool           `json:"is_ip_range_enabled"`  // IP段限制开关
+	IPRange            string         `json:"ip_range"`             // 香港IP段
+	JpIPRange          string         `json:"jp_ip_range"`          // 日本IP段
+	SgIPRange          string         `json:"sg_ip_range"`          // 新加坡IP段
+	OfflineNotifyDelay *int           `json:"offline_notify_delay"` // 离线通知延迟（秒），不传则保持原值
+	QuietHours         *string        `json:"quiet_hours"`          // 免打扰时段，如"23-7"，不传则保持原值
+	RegionThresholds   map[string]int `json:"region_thresholds"`    // 非内置区域的阈值，如{"us-west-2":3}，只有管理员可以修改
 }
 
 // AdminUpdateConfigRequest 管理员更新配置请求结构
 type AdminUpdateConfigRequest struct {
-	UserID           string `json:"user_id"`             // 要更新的用户ID
-	Threshold        int    `json:"threshold"`           // 香港区阈值
-	JpThreshold      int    `json:"jp_threshold"`        // 日本区阈值
-	SgThreshold      int    `json:"sg_threshold"`        // 新加坡区阈值
-	IsEnabled        bool   `json:"is_enabled"`          // 开关状态
-	IsTgEnabled      bool   `json:"is_tg_enabled"`       // TG通知开关
-	TgUserID         string `json:"tg_user_id"`          // TG用户ID
-	IsIPRangeEnabled bool   `json:"is_ip_range_enabled"` // IP段限制开关
-	IPRange          string `json:"ip_range"`            // 香港IP段
-	JpIPRange        string `json:"jp_ip_range"`         // 日本IP段
-	SgIPRange        string `json:"sg_ip_range"`         // 新加坡IP段
+	UserID             string         `json:"user_id"`              // 要更新的用户ID
+	Threshold          int            `json:"threshold"`            // 香港区阈值
+	JpThreshold        int            `json:"jp_threshold"`         // 日本区阈值
+	SgThreshold        int            `json:"sg_threshold"`         // 新加坡区阈值
+	IsEnabled          bool           `json:"is_enabled"`           // 开关状态
+	IsHkEnabled        *bool          `json:"is_hk_enabled"`        // 香港区补机开关，不传则保持原值
+	IsJpEnabled        *bool          `json:"is_jp_enabled"`        // 日本区补机开关，不传则保持原值
+	IsSgEnabled        *bool          `json:"is_sg_enabled"`        // 新加坡区补机开关，不传则保持原值
+	IsTgEnabled        bool           `json:"is_tg_enabled"`        // TG通知开关
+	TgUserID           string         `json:"tg_user_id"`           // TG用户ID
+	IsIPRangeEnabled   bool           `json:"is_ip_range_enabled"`  // IP段限制开关
+	IPRange            string         `json:"ip_range"`             // 香港IP段
+	JpIPRange          string         `json:"jp_ip_range"`          // 日本IP段
+	SgIPRange          string         `json:"sg_ip_range"`          // 新加坡IP段
+	OfflineNotifyDelay *int           `json:"offline_notify_delay"` // 离线通知延迟（秒），不传则保持原值
+	QuietHours         *string        `json:"quiet_hours"`          // 免打扰时段，如"23-7"，不传则保持原值
+	RegionThresholds   map[string]int `json:"region_thresholds"`    // 非内置区域的阈值，如{"us-west-2":3}，未传的区域保持原值
+}
+
+// BulkThresholdRequest 管理员批量更新阈值请求结构，未传的区域保持原值
+type BulkThresholdRequest struct {
+	UserIDs     []string `json:"user_ids"`     // 要更新的用户ID，为空时更新所有已有监控配置的用户
+	Threshold   *int     `json:"threshold"`    // 香港区阈值
+	JpThreshold *int     `json:"jp_threshold"` // 日本区阈值
+	SgThreshold *int     `json:"sg_threshold"` // 新加坡区阈值
 }
 
 // MakeupHistoryRecord 补机历史记录响应结构 (修改后)
@@ -55,6 +79,22 @@ type MakeupHistoryRecord struct {
 	Timestamp time.Time `json:"timestamp"` // 补机时间
 }
 
+// 补机历史分页大小
+const (
+	defaultHistoryPageSize = 100 // 未指定时的默认每页数量
+	maxHistoryPageSize     = 500 // 每页最大数量
+)
+
+// MakeupHistoryRequest 补机历史查询请求，所有参数均可选
+type MakeupHistoryRequest struct {
+	Page      int        `json:"page"`       // 页码，从1开始
+	PageSize  int        `json:"page_size"`  // 每页数量
+	UserID    string     `json:"user_id"`    // 按用户过滤
+	Region    string     `json:"region"`     // 按区域过滤，支持区域别名
+	StartTime *time.Time `json:"start_time"` // 开始时间（包含）
+	EndTime   *time.Time `json:"end_time"`   // 结束时间（包含）
+}
+
 // BindingResponse 绑定码响应结构
 type BindingResponse struct {
 	BindingCode string `json:"binding_code"` // 绑定码
@@ -98,6 +138,20 @@ func UpdateUserConfig(c *gin.Context) {
 		return
 	}
 
+	// 校验通知抑制设置
+	if err := model.ValidateNotifySuppression(req.OfflineNotifyDelay, req.QuietHours); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 校验并规范化TG用户ID，避免到发送通知时才发现格式错误
+	tgUserIDInput, err := model.NormalizeTgUserID(req.TgUserID)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.TgUserID = tgUserIDInput
+
 	// 首先获取当前用户的配置信息
 	currentConfig, err := model.GetMonitorByUserID(repository.GetDB(), userID)
 	if err != nil {
@@ -106,8 +160,7 @@ func UpdateUserConfig(c *gin.Context) {
 	}
 
 	// 检查用户是否是管理员
-	isAdmin, exists := c.Get("is_admin")
-	isAdminUser := exists && isAdmin.(uint8) == 1
+	isAdminUser := middleware.IsAdmin(c)
 
 	// 确定要更新的阈值
 	threshold := currentConfig.Threshold
@@ -119,6 +172,16 @@ func UpdateUserConfig(c *gin.Context) {
 		threshold = req.Threshold
 		jpThreshold = req.JpThreshold
 		sgThreshold = req.SgThreshold
+
+		// 校验阈值是否超过区域上限
+		if err := model.ValidateThresholds(threshold, jpThreshold, sgThreshold); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := model.ValidateRegionThresholds(req.RegionThresholds); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
 	// 非管理员无法修改阈值，保持原值
 
@@ -128,6 +191,12 @@ func UpdateUserConfig(c *gin.Context) {
 		response.Error(c, http.StatusInternalServerError, "更新监控配置失败")
 		return
 	}
+	if isAdminUser {
+		if err := model.UpdateRegionThresholds(repository.GetDB(), userID, req.RegionThresholds); err != nil {
+			response.Error(c, http.StatusInternalServerError, "更新区域阈值失败")
+			return
+		}
+	}
 
 	// 更新TG通知设置
 	// 只有管理员可以修改TG用户ID，普通用户保持当前ID
@@ -154,11 +223,99 @@ func UpdateUserConfig(c *gin.Context) {
 		return
 	}
 
+	// 更新各区域补机开关
+	err = model.UpdateRegionEnabled(repository.GetDB(), userID, req.IsHkEnabled, req.IsJpEnabled, req.IsSgEnabled)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "更新区域补机开关失败")
+		return
+	}
+
+	// 更新通知抑制设置
+	err = model.UpdateNotifySuppression(repository.GetDB(), userID, req.OfflineNotifyDelay, req.QuietHours)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "更新通知抑制设置失败")
+		return
+	}
+
 	response.Success(c, http.StatusOK, gin.H{
 		"message": "更新成功",
 	})
 }
 
+// BulkUpdateThresholds 管理员批量更新多个用户的区域阈值
+func BulkUpdateThresholds(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req BulkThresholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "无效的请求参数")
+		return
+	}
+
+	if req.Threshold == nil && req.JpThreshold == nil && req.SgThreshold == nil {
+		response.Error(c, http.StatusBadRequest, "至少需要指定一个区域的阈值")
+		return
+	}
+
+	db := repository.GetDB()
+
+	// 确定要更新的用户配置
+	var configs []model.Monitor
+	if len(req.UserIDs) == 0 {
+		all, err := model.GetAllMonitors(db)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "获取监控配置失败")
+			return
+		}
+		configs = all
+	} else {
+		for _, targetID := range req.UserIDs {
+			config, err := model.GetMonitorByUserID(db, targetID)
+			if err != nil {
+				response.Error(c, http.StatusInternalServerError, "获取用户["+targetID+"]监控配置失败")
+				return
+			}
+			configs = append(configs, *config)
+		}
+	}
+
+	// 先校验所有用户更新后的阈值，避免部分更新
+	for i := range configs {
+		if req.Threshold != nil {
+			configs[i].Threshold = *req.Threshold
+		}
+		if req.JpThreshold != nil {
+			configs[i].JpThreshold = *req.JpThreshold
+		}
+		if req.SgThreshold != nil {
+			configs[i].SgThreshold = *req.SgThreshold
+		}
+		if err := model.ValidateThresholds(configs[i].Threshold, configs[i].JpThreshold, configs[i].SgThreshold); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	affected := 0
+	var failed []string
+	for _, config := range configs {
+		err := model.UpdateMonitor(db, config.UserID, config.Threshold, config.JpThreshold, config.SgThreshold, config.IsEnabled)
+		if err != nil {
+			failed = append(failed, config.UserID)
+			continue
+		}
+		affected++
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"affected": affected,
+		"failed":   failed,
+	})
+}
+
 // GetAllConfigs 管理员接口：获取所有用户的监控配置
 func GetAllConfigs(c *gin.Context) {
 	// 从 context 获取用户ID
@@ -169,15 +326,7 @@ func GetAllConfigs(c *gin.Context) {
 	}
 
 	// 验证管理员权限
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	// 将 interface{} 转换为 uint8，然后与 1 比较
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -203,14 +352,7 @@ func AdminUpdateConfig(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -227,12 +369,40 @@ func AdminUpdateConfig(c *gin.Context) {
 		return
 	}
 
+	// 校验阈值是否超过区域上限
+	if err := model.ValidateRegionThresholds(req.RegionThresholds); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := model.ValidateThresholds(req.Threshold, req.JpThreshold, req.SgThreshold); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 校验通知抑制设置
+	if err := model.ValidateNotifySuppression(req.OfflineNotifyDelay, req.QuietHours); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 校验并规范化TG用户ID
+	tgUserID, err := model.NormalizeTgUserID(req.TgUserID)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.TgUserID = tgUserID
+
 	// 更新监控基础配置
-	err := model.UpdateMonitor(repository.GetDB(), req.UserID, req.Threshold, req.JpThreshold, req.SgThreshold, req.IsEnabled)
+	err = model.UpdateMonitor(repository.GetDB(), req.UserID, req.Threshold, req.JpThreshold, req.SgThreshold, req.IsEnabled)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "更新监控配置失败")
 		return
 	}
+	if err := model.UpdateRegionThresholds(repository.GetDB(), req.UserID, req.RegionThresholds); err != nil {
+		response.Error(c, http.StatusInternalServerError, "更新区域阈值失败")
+		return
+	}
 
 	// 更新TG通知设置（管理员可以直接修改TG用户ID）
 	err = model.UpdateTgSettings(repository.GetDB(), req.UserID, req.IsTgEnabled, req.TgUserID)
@@ -248,6 +418,20 @@ func AdminUpdateConfig(c *gin.Context) {
 		return
 	}
 
+	// 更新各区域补机开关
+	err = model.UpdateRegionEnabled(repository.GetDB(), req.UserID, req.IsHkEnabled, req.IsJpEnabled, req.IsSgEnabled)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "更新区域补机开关失败")
+		return
+	}
+
+	// 更新通知抑制设置
+	err = model.UpdateNotifySuppression(repository.GetDB(), req.UserID, req.OfflineNotifyDelay, req.QuietHours)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "更新通知抑制设置失败")
+		return
+	}
+
 	response.Success(c, http.StatusOK, gin.H{
 		"message": "更新成功",
 	})
@@ -262,54 +446,77 @@ func GetMakeupHistory(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	// 解析可选的查询参数，允许空请求体
+	var req MakeupHistoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.Error(c, http.StatusBadRequest, "请求参数无效: "+err.Error())
 		return
 	}
+	req.normalize()
 
 	// 获取补机历史记录
-	rawHistory := pool.GlobalMakeupHistory.GetAllRecords()
+	total, list := filterMakeupHistory(pool.GlobalMakeupHistory.GetAllRecords(), req)
 
+	response.Success(c, http.StatusOK, gin.H{
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+		"list":      list,
+	})
+}
+
+// normalize 填充分页默认值并统一区域代码
+func (req *MakeupHistoryRequest) normalize() {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = defaultHistoryPageSize
+	}
+	if req.PageSize > maxHistoryPageSize {
+		req.PageSize = maxHistoryPageSize
+	}
+	if req.Region != "" {
+		req.Region = region.Normalize(req.Region)
+	}
+}
+
+// filterMakeupHistory 按请求条件过滤补机记录，按时间倒序返回总数和当前页
+func filterMakeupHistory(rawHistory []pool.MakeupRecord, req MakeupHistoryRequest) (int, []MakeupHistoryRecord) {
 	// 转换为列表格式并按时间排序
 	var historyList []MakeupHistoryRecord
-	for combinedID, records := range rawHistory {
-		// 解析组合ID (格式: "用户ID:区域" 或 "用户ID")
-		userIDPart := combinedID
-		regionPart := "ap-east-1" // 默认为香港区域
-
-		if strings.Contains(combinedID, ":") {
-			parts := strings.Split(combinedID, ":")
-			if len(parts) == 2 {
-				userIDPart = parts[0]
-				regionPart = parts[1]
-			}
+	for _, record := range rawHistory {
+		// 旧记录可能没有区域，默认为香港区域
+		recordRegion := record.Region
+		if recordRegion == "" {
+			recordRegion = region.HK
 		}
 
-		// 区域代码转换为用户友好的显示值
-		regionDisplay := regionPart
-		switch regionPart {
-		case "ap-east-1":
-			regionDisplay = "ap-east-1" // 香港区域
-		case "ap-northeast-3":
-			regionDisplay = "ap-northeast-3" // 日本区域
-		case "ap-southeast-1":
-			regionDisplay = "ap-southeast-1" // 新加坡区域
+		// 按用户和区域过滤
+		if req.UserID != "" && req.UserID != record.UserID {
+			continue
+		}
+		if req.Region != "" && req.Region != recordRegion {
+			continue
 		}
 
-		for _, record := range records {
-			historyList = append(historyList, MakeupHistoryRecord{
-				UserID:    userIDPart,
-				Region:    regionDisplay,
-				Count:     record.Count,
-				Timestamp: record.Timestamp,
-			})
+		// 按时间范围过滤
+		if req.StartTime != nil && record.Timestamp.Before(*req.StartTime) {
+			continue
+		}
+		if req.EndTime != nil && record.Timestamp.After(*req.EndTime) {
+			continue
 		}
+		historyList = append(historyList, MakeupHistoryRecord{
+			UserID:    record.UserID,
+			Region:    recordRegion,
+			Count:     record.Count,
+			Timestamp: record.Timestamp,
+		})
 	}
 
 	// 按时间倒序排序
@@ -317,10 +524,18 @@ func GetMakeupHistory(c *gin.Context) {
 		return historyList[i].Timestamp.After(historyList[j].Timestamp)
 	})
 
-	response.Success(c, http.StatusOK, gin.H{
-		"total": len(historyList),
-		"list":  historyList,
-	})
+	// 分页
+	total := len(historyList)
+	start := (req.Page - 1) * req.PageSize
+	if start > total {
+		start = total
+	}
+	end := start + req.PageSize
+	if end > total {
+		end = total
+	}
+
+	return total, historyList[start:end]
 }
 
 // GenerateTgBindingCode 生成TG绑定码
@@ -395,14 +610,7 @@ func TriggerDetection(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -414,7 +622,7 @@ func TriggerDetection(c *gin.Context) {
 	}
 
 	// 触发所有用户的主动检测
-	log.Printf("管理员[%s]触发所有用户的主动检测", userID)
+	logger.Printf(c, "管理员[%s]触发所有用户的主动检测", userID)
 	results := detector.DetectAllUsers()
 
 	if len(results) > 0 {
@@ -430,6 +638,55 @@ func TriggerDetection(c *gin.Context) {
 	}
 }
 
+// PreviewDetection 管理员预览主动检测的补机计划，不创建任务也不修改补机历史
+func PreviewDetection(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	detector := pool.GlobalDetector
+	if detector == nil {
+		response.Error(c, http.StatusInternalServerError, "检测器未初始化")
+		return
+	}
+
+	plans, err := detector.PreviewDetectAllUsers()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "预览检测失败:"+err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, plans)
+}
+
+// GetCapacityMatrix 管理员查看所有用户各区域的阈值与在线、待补机数量对照
+func GetCapacityMatrix(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	detector := pool.GlobalDetector
+	if detector == nil {
+		response.Error(c, http.StatusInternalServerError, "检测器未初始化")
+		return
+	}
+
+	rows, err := detector.CapacityMatrix()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取容量对照失败:"+err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, rows)
+}
+
+// ClearHistoryRequest 清空补机历史请求结构
+type ClearHistoryRequest struct {
+	Region string `json:"region"` // 可选，只清空指定区域，默认所有区域
+}
+
 // ClearHistory 清空补机历史和冷却状态
 func ClearHistory(c *gin.Context) {
 	// 验证管理员权限
@@ -439,15 +696,25 @@ func ClearHistory(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
+	// 请求体可选，未指定区域时清空所有区域
+	var req ClearHistoryRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, "请求参数无效:"+err.Error())
+			return
+		}
+	}
+	regionCode := ""
+	if req.Region != "" {
+		regionCode = region.Normalize(req.Region)
+		if !region.IsSupported(regionCode) {
+			response.Error(c, http.StatusBadRequest, "不支持的区域: "+req.Region)
+			return
+		}
 	}
 
 	// 获取全局补机历史记录实例
@@ -464,13 +731,26 @@ func ClearHistory(c *gin.Context) {
 		return
 	}
 
+	// 只清空指定区域
+	if regionCode != "" {
+		makeupHistory.ClearRegionRecords(regionCode)
+		accountPool.ResetAccountsStatusForRegion(regionCode)
+		logger.Printf(c, "管理员[%s]已清空区域[%s]的补机历史记录并重置账号冷却状态", userID, regionCode)
+
+		response.Success(c, http.StatusOK, gin.H{
+			"message": fmt.Sprintf("已清空区域[%s]的补机历史记录并重置账号冷却状态", regionCode),
+			"region":  regionCode,
+		})
+		return
+	}
+
 	// 清空所有补机历史记录
 	makeupHistory.ClearAllRecords() // 确保此方法能处理所有区域的记录
-	log.Printf("管理员[%s]已清空所有区域的补机历史记录", userID)
+	logger.Printf(c, "管理员[%s]已清空所有区域的补机历史记录", userID)
 
 	// 重置所有被标记为跳过的账号
 	accountPool.ResetAllAccountsStatus() // 确保此方法重置所有区域的账号状态
-	log.Printf("管理员[%s]已重置所有区域的账号冷却状态", userID)
+	logger.Printf(c, "管理员[%s]已重置所有区域的账号冷却状态", userID)
 
 	response.Success(c, http.StatusOK, gin.H{
 		"message": "已清空所有区域的补机历史记录并重置账号冷却状态",
@@ -486,14 +766,7 @@ func BackupMonitorSettings(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -525,7 +798,7 @@ func BackupMonitorSettings(c *gin.Context) {
 		return
 	}
 
-	log.Printf("管理员[%s]已备份所有监控配置并临时关闭所有TG通知", userID)
+	logger.Printf(c, "管理员[%s]已备份所有监控配置并临时关闭所有TG通知", userID)
 
 	response.Success(c, http.StatusOK, gin.H{
 		"message": "已成功备份所有监控配置并临时关闭所有TG通知",
@@ -541,14 +814,7 @@ func RestoreMonitorSettings(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -578,13 +844,76 @@ func RestoreMonitorSettings(c *gin.Context) {
 		return
 	}
 
-	log.Printf("管理员[%s]已恢复所有监控配置", userID)
+	logger.Printf(c, "管理员[%s]已恢复所有监控配置", userID)
 
 	response.Success(c, http.StatusOK, gin.H{
 		"message": "已成功恢复所有监控配置",
 	})
 }
 
+// ExportMonitorSettings 导出所有监控配置为JSON文件
+func ExportMonitorSettings(c *gin.Context) {
+	// 验证管理员权限
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
+		return
+	}
+
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	export, err := model.ExportMonitorSettings(repository.GetDB())
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "导出监控配置失败: "+err.Error())
+		return
+	}
+
+	logger.Printf(c, "管理员[%s]导出了%d条监控配置", userID, len(export.Monitors))
+
+	// 以附件形式返回，便于直接下载保存
+	filename := fmt.Sprintf("monitor_export_%s.json", export.ExportedAt.Format("20060102150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.JSON(http.StatusOK, export)
+}
+
+// ImportMonitorSettings 从导出的JSON文件合并导入监控配置
+func ImportMonitorSettings(c *gin.Context) {
+	// 验证管理员权限
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
+		return
+	}
+
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var export model.MonitorExport
+	if err := c.ShouldBindJSON(&export); err != nil {
+		response.Error(c, http.StatusBadRequest, "导入文件格式错误: "+err.Error())
+		return
+	}
+
+	if err := model.ValidateMonitorImport(&export); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := model.ImportMonitorSettings(repository.GetDB(), &export)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "导入监控配置失败: "+err.Error())
+		return
+	}
+
+	logger.Printf(c, "管理员[%s]导入监控配置: 新建%d条，更新%d条，跳过%d条",
+		userID, result.Created, result.Updated, len(result.Skipped))
+
+	response.Success(c, http.StatusOK, result)
+}
+
 // TriggerUserIPRangeCheck 普通用户触发自己的IP范围检查
 func TriggerUserIPRangeCheck(c *gin.Context) {
 	// 从 context 获取用户ID
@@ -601,7 +930,7 @@ func TriggerUserIPRangeCheck(c *gin.Context) {
 		return
 	}
 
-	log.Printf("用户[%s]触发自己的IP范围检查", userID)
+	logger.Printf(c, "用户[%s]触发自己的IP范围检查", userID)
 	go func() {
 		ctx := context.Background()
 		if err := pool.TriggerIPRangeCheck(ctx, userID); err != nil {
@@ -624,14 +953,7 @@ func TriggerAdminIPRangeCheck(c *gin.Context) {
 	}
 
 	// 验证管理员权限
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -642,7 +964,7 @@ func TriggerAdminIPRangeCheck(c *gin.Context) {
 		return
 	}
 
-	log.Printf("管理员[%s]触发所有用户的IP范围检查", userID)
+	logger.Printf(c, "管理员[%s]触发所有用户的IP范围检查", userID)
 	go func() {
 		ctx := context.Background()
 		if err := pool.TriggerAllIPRangeCheck(ctx); err != nil {
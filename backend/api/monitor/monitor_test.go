@@ -0,0 +1,113 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"portal/model"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+	"portal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFilterMakeupHistory(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var records []pool.MakeupRecord
+	for i := 0; i < 5; i++ {
+		records = append(records, pool.MakeupRecord{UserID: "u1", Region: region.JP, Count: i + 1, Timestamp: base.Add(time.Duration(i) * time.Minute)})
+	}
+	records = append(records,
+		pool.MakeupRecord{UserID: "u2", Region: region.HK, Count: 9, Timestamp: base.Add(10 * time.Minute)},
+		// 旧记录没有区域，按香港区处理
+		pool.MakeupRecord{UserID: "u3", Count: 7, Timestamp: base.Add(20 * time.Minute)},
+	)
+
+	req := MakeupHistoryRequest{Region: "日本", PageSize: 2, Page: 2}
+	req.normalize()
+	total, list := filterMakeupHistory(records, req)
+	if total != 5 {
+		t.Fatalf("日本区记录总数 = %d, want 5", total)
+	}
+	// 按时间倒序，第2页为第3、4条
+	if len(list) != 2 || list[0].Count != 3 || list[1].Count != 2 {
+		t.Fatalf("第2页 = %+v, 期望数量为3和2的记录", list)
+	}
+	for _, record := range list {
+		if record.Region != region.JP {
+			t.Errorf("记录区域 = %s, want %s", record.Region, region.JP)
+		}
+	}
+
+	req = MakeupHistoryRequest{Region: "香港"}
+	req.normalize()
+	if total, list = filterMakeupHistory(records, req); total != 2 || list[0].UserID != "u3" || list[0].Region != region.HK {
+		t.Fatalf("香港区记录 = %d %+v, 期望包含无区域的旧记录", total, list)
+	}
+
+	req = MakeupHistoryRequest{PageSize: 10, Page: 3}
+	req.normalize()
+	if total, list = filterMakeupHistory(records, req); total != 7 || len(list) != 0 {
+		t.Fatalf("超出范围的页 = %d %+v, 期望总数7且列表为空", total, list)
+	}
+}
+
+func TestMakeupHistoryRequestNormalize(t *testing.T) {
+	req := MakeupHistoryRequest{PageSize: maxHistoryPageSize + 1}
+	req.normalize()
+	if req.Page != 1 || req.PageSize != maxHistoryPageSize {
+		t.Fatalf("normalize() = page %d size %d, want 1 %d", req.Page, req.PageSize, maxHistoryPageSize)
+	}
+
+	req = MakeupHistoryRequest{}
+	req.normalize()
+	if req.PageSize != defaultHistoryPageSize {
+		t.Fatalf("默认每页数量 = %d, want %d", req.PageSize, defaultHistoryPageSize)
+	}
+}
+
+func TestBulkUpdateThresholds(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	for _, m := range []*model.Monitor{
+		{UserID: "bulk-a", Threshold: 1, JpThreshold: 1, SgThreshold: 1, IsEnabled: true},
+		{UserID: "bulk-b", Threshold: 2, JpThreshold: 2, SgThreshold: 2, IsEnabled: true},
+		{UserID: "bulk-c", Threshold: 5, JpThreshold: 5, SgThreshold: 5, IsEnabled: true},
+	} {
+		if err := db.Create(m).Error; err != nil {
+			t.Fatalf("写入监控配置失败: %v", err)
+		}
+	}
+
+	body, _ := json.Marshal(map[string]any{"user_ids": []string{"bulk-a", "bulk-b"}, "jp_threshold": 3})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/monitor/admin/thresholds", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("is_admin", uint8(1))
+	BulkUpdateThresholds(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, 响应 %s", w.Code, w.Body.String())
+	}
+	want := map[string][3]int{
+		"bulk-a": {1, 3, 1},
+		"bulk-b": {2, 3, 2},
+		"bulk-c": {5, 5, 5}, // 不在目标中的用户保持原值
+	}
+	for userID, thresholds := range want {
+		m, err := model.GetMonitorByUserID(db, userID)
+		if err != nil {
+			t.Fatalf("读取用户[%s]监控配置失败: %v", userID, err)
+		}
+		if got := [3]int{m.Threshold, m.JpThreshold, m.SgThreshold}; got != thresholds {
+			t.Errorf("用户[%s]阈值 = %v, want %v", userID, got, thresholds)
+		}
+	}
+}
@@ -2,9 +2,15 @@
 package pool
 
 import (
-	"log"
+	"io"
 	"net/http"
+	"os"
+	"portal/middleware"
+	"portal/model"
+	"portal/pkg/aws"
+	"portal/pkg/logger"
 	"portal/pkg/pool"
+	"portal/pkg/region"
 	"portal/pkg/response"
 	"portal/repository"
 	"portal/service/instance"
@@ -17,21 +23,23 @@ import (
 
 // AccountOutput 定义账号信息的输出结构体，确保字段顺序
 type AccountOutput struct {
-	ID                   string          `json:"id"`                     // 账号ID
-	UserID               string          `json:"user_id"`                // 用户ID
-	Key1                 string          `json:"key1"`                   // Key1
-	Key2                 string          `json:"key2"`                   // Key2
-	Email                *string         `json:"email,omitempty"`        // 邮箱
-	Password             *string         `json:"password,omitempty"`     // 密码
-	Quatos               *string         `json:"quatos,omitempty"`       // 配额
-	HK                   *string         `json:"hk,omitempty"`           // HK状态
-	VMCount              *int            `json:"vm_count,omitempty"`     // 虚拟机数量
-	Region               *string         `json:"region,omitempty"`       // 区域代码
-	CreateTime           *string         `json:"create_time,omitempty"`  // 创建时间
-	IsSkipped            bool            `json:"is_skipped"`             // 是否跳过
-	ErrorNote            string          `json:"error_note"`             // 错误备注
-	SkippedInstanceTypes map[string]bool `json:"skipped_instance_types"` // 特定实例类型跳过状态
-	RegionUsedCount      int             `json:"region_used_count"`      // 区域已使用的实例计数
+	ID                   string          `json:"id"`                        // 账号ID
+	UserID               string          `json:"user_id"`                   // 用户ID
+	Key1                 string          `json:"key1"`                      // Key1
+	Key2                 string          `json:"key2"`                      // Key2
+	Email                *string         `json:"email,omitempty"`           // 邮箱
+	Password             *string         `json:"password,omitempty"`        // 密码
+	Quatos               *string         `json:"quatos,omitempty"`          // 配额
+	HK                   *string         `json:"hk,omitempty"`              // HK状态
+	VMCount              *int            `json:"vm_count,omitempty"`        // 虚拟机数量
+	VMCountRegion        *string         `json:"vm_count_region,omitempty"` // 虚拟机数量统计所在的区域
+	Region               *string         `json:"region,omitempty"`          // 区域代码
+	CreateTime           *string         `json:"create_time,omitempty"`     // 创建时间
+	IsSkipped            bool            `json:"is_skipped"`                // 是否跳过
+	ErrorNote            string          `json:"error_note"`                // 错误备注
+	SkippedInstanceTypes map[string]bool `json:"skipped_instance_types"`    // 特定实例类型跳过状态
+	RegionUsedCount      int             `json:"region_used_count"`         // 区域已使用的实例计数（各实例族之和）
+	FamilyUsedCount      map[string]int  `json:"family_used_count"`         // 区域内各实例族已使用的实例计数
 }
 
 // PoolInfo 定义账号池信息的输出结构体
@@ -73,23 +81,16 @@ func GetUserInstances(c *gin.Context) {
 	instances := pool.GlobalPool.GetInstancesByUserID(userIDStr)
 
 	response.Success(c, http.StatusOK, gin.H{
-		"total": len(instances),
-		"list":  instances,
+		"total":   len(instances),
+		"list":    instances,
+		"offline": pool.GlobalPool.GetRecentlyOfflineInstances(userIDStr), // 保留期内最近离线的实例
 	})
 }
 
 // GetAllInstances 管理员接口：获取所有实例列表
 func GetAllInstances(c *gin.Context) {
 	// 验证管理员权限
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	// 将 interface{} 转换为 uint8，然后与 1 比较
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -97,23 +98,16 @@ func GetAllInstances(c *gin.Context) {
 	instances := pool.GlobalPool.GetAllInstances()
 
 	response.Success(c, http.StatusOK, gin.H{
-		"total": len(instances),
-		"list":  instances,
+		"total":   len(instances),
+		"list":    instances,
+		"offline": pool.GlobalPool.GetRecentlyOfflineInstances(""), // 保留期内最近离线的实例
 	})
 }
 
 // GetAccountPool 获取账号池信息（管理员接口）
 func GetAccountPool(c *gin.Context) {
 	// 验证管理员权限
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	// 将 interface{} 转换为 uint8，然后与 1 比较
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -134,7 +128,8 @@ func GetAccountPool(c *gin.Context) {
 			IsSkipped:            account.IsSkipped,
 			ErrorNote:            account.ErrorNote,
 			SkippedInstanceTypes: account.SkippedInstanceTypes,
-			RegionUsedCount:      account.RegionUsedCount,
+			RegionUsedCount:      account.TotalUsedCount(),
+			FamilyUsedCount:      account.FamilyUsedCount,
 		}
 
 		// 处理可能为空的指针字段
@@ -152,6 +147,7 @@ func GetAccountPool(c *gin.Context) {
 		}
 		if account.VMCount != nil {
 			output.VMCount = account.VMCount
+			output.VMCountRegion = account.VMCountRegion
 		}
 		if account.Region != nil {
 			output.Region = account.Region
@@ -231,22 +227,12 @@ func DeleteInstance(c *gin.Context) {
 	serviceInstances := make([]instance.DeleteInstanceItem, len(req.Instances))
 	for i, item := range req.Instances {
 		// 处理区域参数，支持中文和英文简写
-		region := item.Region
-		if region != "" {
-			switch region {
-			case "jp", "japan", "日本":
-				region = "ap-northeast-3" // 日本区域
-			case "sg", "singapore", "新加坡":
-				region = "ap-southeast-1" // 新加坡区域
-			case "hk", "hongkong", "香港":
-				region = "ap-east-1" // 香港区域
-			}
-		}
+		itemRegion := region.Normalize(item.Region)
 		// 不再设置默认区域，让服务层根据账号信息决定
 
 		serviceInstances[i] = instance.DeleteInstanceItem{
 			AccountID:  item.AccountID,
-			Region:     region,
+			Region:     itemRegion,
 			InstanceID: item.InstanceID,
 		}
 	}
@@ -299,22 +285,12 @@ func ChangeIP(c *gin.Context) {
 	serviceInstances := make([]instance.ChangeIPItem, len(req.Instances))
 	for i, item := range req.Instances {
 		// 处理区域参数，支持中文和英文简写
-		region := item.Region
-		if region != "" {
-			switch region {
-			case "jp", "japan", "日本":
-				region = "ap-northeast-3" // 日本区域
-			case "sg", "singapore", "新加坡":
-				region = "ap-southeast-1" // 新加坡区域
-			case "hk", "hongkong", "香港":
-				region = "ap-east-1" // 香港区域
-			}
-		}
+		itemRegion := region.Normalize(item.Region)
 		// 不再设置默认区域，让服务层根据账号信息决定
 
 		serviceInstances[i] = instance.ChangeIPItem{
 			AccountID:  item.AccountID,
-			Region:     region,
+			Region:     itemRegion,
 			InstanceID: item.InstanceID,
 		}
 	}
@@ -340,21 +316,14 @@ func ChangeIP(c *gin.Context) {
 
 // ResetAccountsRequest 重置账号请求结构
 type ResetAccountsRequest struct {
-	AccountIDs []string `json:"account_ids" binding:"required,min=1"`
+	AccountIDs    []string `json:"account_ids" binding:"required,min=1"`
+	ClearCooldown bool     `json:"clear_cooldown"` // 是否同时清除账号所在区域的补机冷却记录，使补机立即恢复
 }
 
 // ResetAccounts 重置指定账号的状态（管理员接口）
 func ResetAccounts(c *gin.Context) {
 	// 验证管理员权限
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	// 将 interface{} 转换为 uint8，然后与 1 比较
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -370,6 +339,8 @@ func ResetAccounts(c *gin.Context) {
 	// 重置指定账号的状态
 	successIDs := make([]string, 0)
 	failedIDs := make([]string, 0)
+	// 成功重置的账号所在的区域，账号池中的账号为所有用户共用，按区域清除冷却记录
+	resetRegions := make(map[string]bool)
 
 	for _, accountID := range req.AccountIDs {
 		// 获取账号信息，确认账号存在
@@ -390,6 +361,7 @@ func ResetAccounts(c *gin.Context) {
 				len(updatedAccount.SkippedInstanceTypes) == 0 &&
 				updatedAccount.ErrorNote == "" {
 				successIDs = append(successIDs, accountID)
+				resetRegions[updatedAccount.RegionCode()] = true
 			} else {
 				// 重置未完全成功
 				failedIDs = append(failedIDs, accountID)
@@ -399,6 +371,17 @@ func ResetAccounts(c *gin.Context) {
 		}
 	}
 
+	// 清除相关区域的补机冷却记录，下一次检测即可重新补机
+	clearedRegions := make([]string, 0)
+	if req.ClearCooldown && pool.GlobalMakeupHistory != nil {
+		for regionCode := range resetRegions {
+			pool.GlobalMakeupHistory.ClearRegionRecords(regionCode)
+			clearedRegions = append(clearedRegions, regionCode)
+		}
+		sort.Strings(clearedRegions)
+		logger.Printf(c, "重置账号后已清除区域%v的补机冷却记录", clearedRegions)
+	}
+
 	// 构建响应
 	response.Success(c, http.StatusOK, gin.H{
 		"success": gin.H{
@@ -409,21 +392,14 @@ func ResetAccounts(c *gin.Context) {
 			"count": len(failedIDs),
 			"ids":   failedIDs,
 		},
+		"cleared_cooldown_regions": clearedRegions,
 	})
 }
 
 // GetMakeupQueue 获取补机队列信息（管理员接口）
 func GetMakeupQueue(c *gin.Context) {
 	// 验证管理员权限
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	// 将 interface{} 转换为 uint8，然后与 1 比较
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -434,25 +410,16 @@ func GetMakeupQueue(c *gin.Context) {
 	// 转换为输出结构
 	outputs := make([]MakeupQueueOutput, 0, len(queueItems))
 	for _, item := range queueItems {
-		// 确保显示正确的区域名称
-		region := item.Region
-		var regionDisplay string
-
-		switch region {
-		case "ap-northeast-3":
-			regionDisplay = "日本区"
-		case "ap-southeast-1":
-			regionDisplay = "新加坡区"
-		case "ap-east-1", "":
-			region = "ap-east-1" // 确保默认值一致
-			regionDisplay = "香港区"
-		default:
-			regionDisplay = region // 未知区域则直接显示代码
+		// 确保显示正确的区域名称，未知区域则直接显示代码
+		regionCode := item.Region
+		if regionCode == "" {
+			regionCode = region.HK // 确保默认值一致
 		}
+		regionDisplay := region.DisplayName(regionCode)
 
 		output := MakeupQueueOutput{
 			UserID:         item.UserID,
-			Region:         region,
+			Region:         regionCode,
 			RegionDisplay:  regionDisplay, // 添加显示名称
 			TotalCount:     item.TotalCount,
 			CompletedCount: item.CompletedCount,
@@ -473,6 +440,37 @@ func GetMakeupQueue(c *gin.Context) {
 	})
 }
 
+// 补机统计默认时间窗口
+const defaultMakeupStatsWindow = 24 * time.Hour
+
+// GetMakeupStats 获取时间窗口内的补机吞吐量、耗时和失败率统计（管理员接口）
+// 通过 window 查询参数指定时间窗口，如"6h"、"72h"，默认24小时
+func GetMakeupStats(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	window := defaultMakeupStatsWindow
+	if value := c.Query("window"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			response.Error(c, http.StatusBadRequest, "时间窗口格式无效，示例: 24h")
+			return
+		}
+		window = d
+	}
+
+	since := time.Now().Add(-window)
+	tasks, err := model.ListMakeupTasksSince(repository.GetDB(), since)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取补机任务记录失败: "+err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, model.ComputeMakeupStats(tasks, since, window))
+}
+
 // ResetMakeupQueue 重置卡住的补机队列（管理员接口）
 func ResetMakeupQueue(c *gin.Context) {
 	// 验证管理员权限
@@ -482,22 +480,14 @@ func ResetMakeupQueue(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	// 将 interface{} 转换为 uint8，然后与 1 比较
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
 	// 获取补机队列并重置卡住的任务
 	makeupQueue := pool.GetMakeupQueue()
 	makeupQueue.ResetStuckTasks() // 确保此方法能处理所有区域的任务
-	log.Printf("管理员[%s]已重置所有区域的卡住补机任务", userID)
+	logger.Printf(c, "管理员[%s]已重置所有区域的卡住补机任务", userID)
 
 	// 触发手动重置事件
 	pool.GetEventManager().TriggerEvent(pool.ManualReset, "")
@@ -508,8 +498,159 @@ func ResetMakeupQueue(c *gin.Context) {
 	})
 }
 
-// ClearMakeupQueue 清空所有补机队列（管理员接口）
-func ClearMakeupQueue(c *gin.Context) {
+// EligibleAccountsRequest 可用账号预览请求结构
+type EligibleAccountsRequest struct {
+	Region       string `json:"region" binding:"required"`        // 区域代码或中文名称
+	InstanceType string `json:"instance_type" binding:"required"` // 实例类型
+	Count        int    `json:"count"`                            // 计划开机数量
+}
+
+// PreviewEligibleAccounts 预览可承接开机的账号（管理员接口）
+func PreviewEligibleAccounts(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req EligibleAccountsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误:"+err.Error())
+		return
+	}
+
+	if req.Count <= 0 {
+		req.Count = 1
+	}
+
+	regionCode := model.GetRegionCode(req.Region)
+	accounts, totalCapacity := pool.GetAccountPool().PreviewEligibleAccounts(req.InstanceType, regionCode, req.Count)
+
+	response.Success(c, http.StatusOK, gin.H{
+		"region":         regionCode,
+		"instance_type":  req.InstanceType,
+		"count":          req.Count,
+		"total_capacity": totalCapacity,
+		"satisfiable":    totalCapacity >= req.Count,
+		"total":          len(accounts),
+		"list":           accounts,
+	})
+}
+
+// GetPoolAccount 获取单个账号在账号池中的实时状态（管理员接口）
+// 可通过 instance_type 和 region 查询参数判断该账号当前是否满足选择条件
+func GetPoolAccount(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	accountID := c.Param("id")
+	regionCode := ""
+	if value := c.Query("region"); value != "" {
+		regionCode = model.GetRegionCode(value)
+	}
+
+	state, ok := pool.GetAccountPool().InspectAccount(accountID, c.Query("instance_type"), regionCode)
+	if !ok {
+		response.Error(c, http.StatusNotFound, "账号不在账号池中")
+		return
+	}
+
+	response.Success(c, http.StatusOK, state)
+}
+
+// GetPoolAccountFailures 获取单个账号最近的开机失败记录（管理员接口）
+func GetPoolAccountFailures(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	failures := pool.GetAccountPool().GetAccountFailures(c.Param("id"))
+	response.Success(c, http.StatusOK, gin.H{
+		"total": len(failures),
+		"list":  failures,
+	})
+}
+
+// GetAccountInstances 查询账号在AWS上的实例，并与连接池的上报状态合并（管理员接口）
+func GetAccountInstances(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	accountID := c.Param("id")
+	accounts, err := model.GetAccountKeysByIDsForAdmin(repository.GetDB(), []string{accountID})
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取账号信息失败")
+		return
+	}
+	if len(accounts) == 0 {
+		response.Error(c, http.StatusNotFound, "账号不存在")
+		return
+	}
+	acc := accounts[0]
+
+	regionCode := acc.RegionCode()
+
+	awsClient := aws.NewAWSClient(acc.Key1, acc.Key2)
+	live, err := awsClient.ListInstances(c.Request.Context(), aws.ListInstancesParams{
+		Region:    regionCode,
+		AccountID: accountID,
+	})
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	instances := pool.GlobalPool.ReconcileAccountInstances(accountID, live)
+	response.Success(c, http.StatusOK, gin.H{
+		"account_id": accountID,
+		"region":     regionCode,
+		"total":      len(instances),
+		"list":       instances,
+	})
+}
+
+// RecomputeAccountUsage 按AWS实际实例重新统计单个账号的区域使用计数（管理员接口）
+func RecomputeAccountUsage(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	result, err := pool.GetAccountPool().RecomputeAccountUsage(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, result)
+}
+
+// ClearIPLocksRequest 清除IP锁定请求结构
+type ClearIPLocksRequest struct {
+	InstanceIDs []string `json:"instance_ids"` // 要清除锁定的实例ID，为空时清除全部
+}
+
+// GetIPLocks 获取当前所有IP锁定（管理员接口）
+func GetIPLocks(c *gin.Context) {
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	locks := pool.GlobalPool.GetIPLocks()
+
+	response.Success(c, http.StatusOK, gin.H{
+		"total": len(locks),
+		"list":  locks,
+	})
+}
+
+// ClearIPLocks 清除指定实例或全部的IP锁定（管理员接口）
+func ClearIPLocks(c *gin.Context) {
 	// 验证管理员权限
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -517,22 +658,122 @@ func ClearMakeupQueue(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req ClearIPLocksRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		response.Error(c, http.StatusBadRequest, "参数错误:"+err.Error())
+		return
+	}
+
+	// 未指定实例时清除全部锁定
+	if len(req.InstanceIDs) == 0 {
+		count := pool.GlobalPool.ClearAllIPLocks()
+		logger.Printf(c, "管理员[%s]已清除所有IP锁定，共%d个", userID, count)
+		response.Success(c, http.StatusOK, gin.H{
+			"cleared": count,
+		})
+		return
+	}
+
+	clearedIDs := make([]string, 0)
+	notFoundIDs := make([]string, 0)
+	for _, instanceID := range req.InstanceIDs {
+		if pool.GlobalPool.ClearIPLock(instanceID) {
+			clearedIDs = append(clearedIDs, instanceID)
+		} else {
+			notFoundIDs = append(notFoundIDs, instanceID)
+		}
+	}
+	logger.Printf(c, "管理员[%s]清除了%d个实例的IP锁定", userID, len(clearedIDs))
+
+	response.Success(c, http.StatusOK, gin.H{
+		"cleared":       len(clearedIDs),
+		"cleared_ids":   clearedIDs,
+		"not_found_ids": notFoundIDs,
+	})
+}
+
+// DebugInstanceRequest 模拟实例上下线请求结构
+type DebugInstanceRequest struct {
+	Action   string                 `json:"action" binding:"required,oneof=online offline"` // online注入实例，offline移除实例
+	Instance *pool.InstanceMetadata `json:"instance" binding:"required"`                    // 实例信息，offline时只需要instance_id
+}
+
+// DebugInstance 模拟实例上线或离线，用于验证通知和补机流程（管理员接口）
+// 需要设置 POOL_DEBUG_ENDPOINTS=true 才能使用
+func DebugInstance(c *gin.Context) {
+	if os.Getenv("POOL_DEBUG_ENDPOINTS") != "true" {
+		response.Error(c, http.StatusNotFound, "调试接口未开启")
+		return
+	}
+
+	// 验证管理员权限
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
+		return
+	}
+
+	if !middleware.RequireAdmin(c) {
+		return
+	}
+
+	var req DebugInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "参数错误:"+err.Error())
+		return
+	}
+	if req.Instance.InstanceID == "" {
+		response.Error(c, http.StatusBadRequest, "instance_id不能为空")
+		return
+	}
+
+	if req.Action == "online" {
+		if req.Instance.UserID == "" {
+			response.Error(c, http.StatusBadRequest, "注入实例时user_id不能为空")
+			return
+		}
+		pool.GlobalPool.UpdateInstance(req.Instance)
+		logger.Printf(c, "管理员[%s]模拟实例[%s]上线", userID, req.Instance.InstanceID)
+		response.Success(c, http.StatusOK, gin.H{
+			"message":  "已注入实例",
+			"instance": req.Instance,
+		})
+		return
+	}
+
+	metadata, ok := pool.GlobalPool.MarkInstanceOffline(req.Instance.InstanceID)
+	if !ok {
+		response.Error(c, http.StatusNotFound, "实例不在连接池中")
+		return
+	}
+	logger.Printf(c, "管理员[%s]模拟实例[%s]离线", userID, req.Instance.InstanceID)
+	response.Success(c, http.StatusOK, gin.H{
+		"message":  "已移除实例",
+		"instance": metadata,
+	})
+}
+
+// ClearMakeupQueue 清空所有补机队列（管理员接口）
+func ClearMakeupQueue(c *gin.Context) {
+	// 验证管理员权限
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
 		return
 	}
 
-	// 将 interface{} 转换为 uint8，然后与 1 比较
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
 	// 获取补机队列并清空
 	makeupQueue := pool.GetMakeupQueue()
 	makeupQueue.ClearAllQueue() // 确保此方法能处理所有区域的队列
-	log.Printf("管理员[%s]已清空所有区域的补机队列", userID)
+	logger.Printf(c, "管理员[%s]已清空所有区域的补机队列", userID)
 
 	// 构建响应
 	response.Success(c, http.StatusOK, gin.H{
@@ -0,0 +1,93 @@
+package pool
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"portal/model"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+	"portal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResetAccountsClearsRegionCooldown(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	const userID = "reset-cooldown-user"
+	if err := db.Create(&model.Monitor{UserID: userID, IsEnabled: true, JpThreshold: 1}).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+
+	oldPool, oldHistory := pool.GlobalPool, pool.GlobalMakeupHistory
+	pool.GlobalPool = pool.NewPool()
+	pool.GlobalMakeupHistory = &pool.MakeupHistory{}
+	pool.GlobalMakeupHistory.ClearAllRecords()
+	t.Cleanup(func() { pool.GlobalPool, pool.GlobalMakeupHistory = oldPool, oldHistory })
+
+	// 日本区刚补过机，处于冷却期；香港区的冷却记录与重置的账号无关
+	history := pool.GlobalMakeupHistory
+	history.AddMakeupRecordWithRegion(userID, 1, region.JP)
+	history.AddMakeupRecordWithRegion(userID, 1, region.HK)
+
+	jp := region.JP
+	accountPool := pool.GetAccountPool()
+	accountPool.AddAccount(model.Account{ID: "9481", UserID: "u-9481", Key1: "AKIARESET", Key2: "secret", Region: &jp})
+	t.Cleanup(func() { accountPool.RemoveAccount("9481") })
+	accountPool.MarkAccountFailed("9481", "额度不足")
+
+	detector := pool.NewDetector(db, history)
+	if plan := jpPlan(t, detector, userID); plan.WouldQueue || plan.RecentMakeup == 0 {
+		t.Fatalf("重置前日本区应处于冷却期: %+v", plan)
+	}
+
+	body, _ := json.Marshal(map[string]any{"account_ids": []string{"9481"}, "clear_cooldown": true})
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/pool/admin/reset", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("is_admin", uint8(1))
+	ResetAccounts(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码 = %d, 响应 %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data struct {
+			Cleared []string `json:"cleared_cooldown_regions"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data.Cleared) != 1 || resp.Data.Cleared[0] != region.JP {
+		t.Fatalf("清除冷却的区域 = %+v (%v), 响应 %s", resp.Data.Cleared, err, w.Body.String())
+	}
+
+	// 冷却记录清除后，下一次检测即会重新补机
+	if plan := jpPlan(t, detector, userID); !plan.WouldQueue || plan.RecentMakeup != 0 {
+		t.Fatalf("重置后日本区应立即补机: %+v", plan)
+	}
+	if history.GetMakeupCountForRegion(userID, region.HK, 5*time.Minute) != 1 {
+		t.Fatal("未重置账号的区域不应清除冷却记录")
+	}
+}
+
+// jpPlan 获取用户在日本区的补机计划
+func jpPlan(t *testing.T, detector *pool.Detector, userID string) pool.DetectPlan {
+	t.Helper()
+	plans, err := detector.PreviewDetectAllUsers()
+	if err != nil {
+		t.Fatalf("评估补机计划失败: %v", err)
+	}
+	for _, plan := range plans {
+		if plan.UserID == userID && plan.Region == region.JP {
+			return plan
+		}
+	}
+	t.Fatalf("没有用户[%s]在日本区的补机计划", userID)
+	return pool.DetectPlan{}
+}
@@ -3,7 +3,9 @@ package setting
 
 import (
 	"net/http"
+	"portal/middleware"
 	"portal/model"
+	"portal/pkg/aws"
 	"portal/pkg/response"
 	"portal/repository"
 	"portal/service/setting"
@@ -13,14 +15,19 @@ import (
 
 // AdminUpdateSettingRequest 管理员更新配置请求结构
 type AdminUpdateSettingRequest struct {
-	UserID       string `json:"user_id"`       // 要更新的用户ID
-	Region       string `json:"region"`        // 区域
-	InstanceType string `json:"instance_type"` // 实例类型
-	DiskSize     int    `json:"disk_size"`     // 磁盘大小
-	Password     string `json:"password"`      // 密码
-	Script       string `json:"script"`        // 脚本
-	JpScript     string `json:"jp_script"`     // 日本区域脚本
-	SgScript     string `json:"sg_script"`     // 新加坡区域脚本
+	UserID            string  `json:"user_id"`            // 要更新的用户ID
+	Region            string  `json:"region"`             // 区域
+	InstanceType      string  `json:"instance_type"`      // 实例类型
+	DiskSize          int     `json:"disk_size"`          // 磁盘大小
+	Password          string  `json:"password"`           // 密码
+	Script            string  `json:"script"`             // 脚本
+	JpScript          string  `json:"jp_script"`          // 日本区域脚本
+	SgScript          string  `json:"sg_script"`          // 新加坡区域脚本
+	SkipSSHPassword   *bool   `json:"skip_ssh_password"`  // 是否跳过SSH密码配置
+	AllowedRegions    *string `json:"allowed_regions"`    // 允许开机的区域，逗号分隔，空字符串表示不限制
+	AdditionalVolumes *string `json:"additional_volumes"` // 附加数据盘配置，JSON数组
+
+	RegionPasswords map[string]string `json:"region_passwords"` // 区域密码，如{"ap-northeast-3":"xxx"}，空字符串表示使用默认密码，未传的区域保持原值
 }
 
 // GetSetting 获取设置
@@ -58,13 +65,31 @@ func UpdateSetting(c *gin.Context) {
 	}
 
 	// 预先验证密码强度
-	tempSetting := &model.Setting{
-		Password: req.Password,
-	}
+	tempSetting := &model.Setting{Password: req.Password}
 	if err := tempSetting.ValidatePassword(); err != nil {
 		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := model.ValidateRegionPasswords(req.RegionPasswords); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 验证硬盘大小，0表示保持原值
+	if req.DiskSize != 0 {
+		if err := model.ValidateDiskSize(req.DiskSize); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// 验证附加数据盘配置
+	if req.AdditionalVolumes != nil {
+		if _, err := aws.ParseVolumeSpecs(*req.AdditionalVolumes); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 
 	settingService := setting.NewSettingService(repository.GetDB())
 	err := settingService.UpdateSetting(userID, &req)
@@ -76,8 +101,14 @@ func UpdateSetting(c *gin.Context) {
 	response.Success(c, http.StatusOK, "设置更新成功")
 }
 
-// GetAllSettings 管理员接口：获取所有用户的设置
-func GetAllSettings(c *gin.Context) {
+// PreviewScriptRequest 预览开机脚本请求结构
+type PreviewScriptRequest struct {
+	Script string `json:"script"` // 要检查的脚本，为空时使用当前设置中该区域的脚本
+	Region string `json:"region"` // 区域，为空时使用设置中的区域
+}
+
+// PreviewScript 预览开机时生成的完整用户数据并检查脚本语法，不会开机
+func PreviewScript(c *gin.Context) {
 	// 从 context 获取用户ID
 	userID := c.GetString("user_id")
 	if userID == "" {
@@ -85,16 +116,51 @@ func GetAllSettings(c *gin.Context) {
 		return
 	}
 
-	// 验证管理员权限
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	var req PreviewScriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "请求参数无效")
+		return
+	}
+
+	userSetting, err := model.GetSettingByUserID(repository.GetDB(), userID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "获取用户设置失败")
+		return
+	}
+
+	regionCode := userSetting.GetRegionCode()
+	if req.Region != "" {
+		regionCode = model.GetRegionCode(req.Region)
+	}
+
+	script := req.Script
+	if script == "" {
+		script = userSetting.GetScriptForRegion(regionCode)
+	}
+
+	// 预览中隐藏密码
+	userData := aws.BuildUserData("******", userSetting.SkipSSHPassword, script)
+	warnings := aws.LintScript(script)
+
+	response.Success(c, http.StatusOK, gin.H{
+		"region":    regionCode,
+		"user_data": userData,
+		"warnings":  warnings,
+		"valid":     len(warnings) == 0,
+	})
+}
+
+// GetAllSettings 管理员接口：获取所有用户的设置
+func GetAllSettings(c *gin.Context) {
+	// 从 context 获取用户ID
+	userID := c.GetString("user_id")
+	if userID == "" {
+		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
 		return
 	}
 
-	// 将 interface{} 转换为 uint8，然后与 1 比较
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	// 验证管理员权限
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -121,14 +187,7 @@ func AdminUpdateSetting(c *gin.Context) {
 		return
 	}
 
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return
-	}
-
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -146,23 +205,53 @@ func AdminUpdateSetting(c *gin.Context) {
 	}
 
 	// 预先验证密码强度
-	tempSetting := &model.Setting{
-		Password: req.Password,
-	}
+	tempSetting := &model.Setting{Password: req.Password}
 	if err := tempSetting.ValidatePassword(); err != nil {
 		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := model.ValidateRegionPasswords(req.RegionPasswords); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 验证硬盘大小，0表示保持原值
+	if req.DiskSize != 0 {
+		if err := model.ValidateDiskSize(req.DiskSize); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// 验证附加数据盘配置
+	if req.AdditionalVolumes != nil {
+		if _, err := aws.ParseVolumeSpecs(*req.AdditionalVolumes); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// 验证允许开机的区域
+	if req.AllowedRegions != nil {
+		if _, err := model.NormalizeAllowedRegions(*req.AllowedRegions); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 
 	// 转换为模型更新请求
 	updateReq := &model.UpdateSettingRequest{
-		Region:       req.Region,
-		InstanceType: req.InstanceType,
-		DiskSize:     req.DiskSize,
-		Password:     req.Password,
-		Script:       req.Script,
-		JpScript:     req.JpScript,
-		SgScript:     req.SgScript,
+		Region:            req.Region,
+		InstanceType:      req.InstanceType,
+		DiskSize:          req.DiskSize,
+		Password:          req.Password,
+		Script:            req.Script,
+		JpScript:          req.JpScript,
+		SgScript:          req.SgScript,
+		SkipSSHPassword:   req.SkipSSHPassword,
+		AllowedRegions:    req.AllowedRegions,
+		AdditionalVolumes: req.AdditionalVolumes,
+		RegionPasswords:   req.RegionPasswords,
 	}
 
 	// 更新设置
@@ -5,10 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"portal/middleware"
 	"portal/model"
+	"portal/pkg/logger"
 	"portal/pkg/pool"
 	"portal/pkg/response"
 	"portal/repository"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -27,48 +32,39 @@ type UpdateUsersRequest struct {
 	IsAdmin  *uint8   `json:"is_admin"`
 }
 
-// checkAdminPermission 检查管理员权限
-func checkAdminPermission(c *gin.Context) bool {
-	userID := c.GetString("user_id")
-	if userID == "" {
-		response.Error(c, http.StatusUnauthorized, "未获取到用户ID")
-		return false
-	}
-
-	isAdmin, exists := c.Get("is_admin")
-	if !exists {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return false
-	}
-
-	if adminValue, ok := isAdmin.(uint8); !ok || adminValue != 1 {
-		response.Error(c, http.StatusForbidden, "需要管理员权限")
-		return false
-	}
-
-	return true
-}
-
 // GetUsers 获取指定ID的用户信息
 // GetUsers 获取用户信息
 func GetUsers(c *gin.Context) {
 	// 验证管理员权限
-	if !checkAdminPermission(c) {
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
 	var req GetUsersRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		// 如果没有提供IDs或解析JSON失败，则获取所有用户
-		allUsers, err := model.GetAllUsers(repository.GetDB())
+		// 如果没有提供IDs或解析JSON失败，则按查询参数分页/搜索用户
+		// page、page_size 不传时返回全部用户，email 为邮箱模糊搜索
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "0"))
+		if page < 1 {
+			page = 1
+		}
+		if pageSize > 100 {
+			pageSize = 100
+		}
+		email := strings.TrimSpace(c.Query("email"))
+
+		pagedUsers, total, err := model.GetUsersPaged(repository.GetDB(), page, pageSize, email)
 		if err != nil {
 			response.Error(c, http.StatusInternalServerError, "获取用户信息失败: "+err.Error())
 			return
 		}
 
 		response.Success(c, http.StatusOK, gin.H{
-			"users": allUsers,
-			"total": len(allUsers),
+			"users":     pagedUsers,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
 		})
 		return
 	}
@@ -89,7 +85,7 @@ func GetUsers(c *gin.Context) {
 // UpdateUsers 更新用户信息
 func UpdateUsers(c *gin.Context) {
 	// 验证管理员权限
-	if !checkAdminPermission(c) {
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -137,10 +133,112 @@ type MakeupUsersRequest struct {
 	Count  int      `json:"count"`  // 可选参数：补机数量
 }
 
+const (
+	defaultMakeupWaitTimeout = 60 * time.Second  // 同步等待补机的默认超时
+	maxMakeupWaitTimeout     = 300 * time.Second // 同步等待补机的最大超时
+)
+
+// 同步等待时查询任务状态的间隔
+var makeupWaitPollInterval = 2 * time.Second
+
+// MakeupUserProgress 同步等待模式下单个用户的补机进度
+type MakeupUserProgress struct {
+	UserID    string `json:"user_id"`
+	Region    string `json:"region"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+	Remaining int    `json:"remaining"`
+	Status    string `json:"status"`
+}
+
+// parseMakeupWaitTimeout 解析timeout参数（秒），未设置或非法时使用默认值
+func parseMakeupWaitTimeout(value string) time.Duration {
+	if value == "" {
+		return defaultMakeupWaitTimeout
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return defaultMakeupWaitTimeout
+	}
+	timeout := time.Duration(seconds) * time.Second
+	if timeout > maxMakeupWaitTimeout {
+		return maxMakeupWaitTimeout
+	}
+	return timeout
+}
+
+// makeupWaitTask 同步等待模式下需要跟踪的补机任务
+type makeupWaitTask struct {
+	userID  string
+	region  string
+	queueID string
+}
+
+// makeupProgressSource 查询补机任务进度，由 *pool.MakeupQueue 实现
+type makeupProgressSource interface {
+	GetTaskProgress(queueKey string) (completed, total int, status string, ok bool)
+}
+
+// isMakeupTaskTerminal 任务是否已经结束，不会再有进展
+// 已放弃或已从队列清理（不存在）的任务无需继续等待
+func isMakeupTaskTerminal(status string) bool {
+	return status == "已完成" || status == "已放弃" || status == "不存在"
+}
+
+// waitForMakeupTasks 等待补机任务结束或超时，返回每个任务的进度以及是否全部完成
+// 所有任务都进入结束状态（已完成、已放弃、不存在）时立即返回
+func waitForMakeupTasks(c *gin.Context, makeupQueue makeupProgressSource, tasks []makeupWaitTask, timeout time.Duration) ([]MakeupUserProgress, bool) {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(makeupWaitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		progress := make([]MakeupUserProgress, 0, len(tasks))
+		allDone := true
+		allTerminal := true
+		for _, task := range tasks {
+			completed, total, status, ok := makeupQueue.GetTaskProgress(task.queueID)
+			if !ok {
+				// 任务已被清理，视为未完成
+				status = "不存在"
+			}
+			remaining := total - completed
+			if remaining < 0 {
+				remaining = 0
+			}
+			if status != "已完成" {
+				allDone = false
+			}
+			if !isMakeupTaskTerminal(status) {
+				allTerminal = false
+			}
+			progress = append(progress, MakeupUserProgress{
+				UserID:    task.userID,
+				Region:    task.region,
+				Total:     total,
+				Completed: completed,
+				Remaining: remaining,
+				Status:    status,
+			})
+		}
+
+		if allTerminal || time.Now().After(deadline) {
+			return progress, allDone
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return progress, false
+		case <-ticker.C:
+		}
+	}
+}
+
 // MakeupUsers 为指定用户执行补机操作
+// 支持 wait=true&timeout=秒 参数，同步等待任务完成并返回每个用户的进度
 func MakeupUsers(c *gin.Context) {
 	// 验证管理员权限
-	if !checkAdminPermission(c) {
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
@@ -162,6 +260,7 @@ func MakeupUsers(c *gin.Context) {
 	// 补机结果
 	successCount := 0
 	failedIDs := make([]string, 0)
+	waitTasks := make([]makeupWaitTask, 0, len(req.IDs))
 
 	// 处理每个用户
 	for _, userID := range req.IDs {
@@ -172,22 +271,54 @@ func MakeupUsers(c *gin.Context) {
 			setting, err := model.GetSettingByUserID(repository.GetDB(), userID)
 			if err != nil {
 				failedIDs = append(failedIDs, userID)
-				fmt.Printf("获取用户[%s]设置失败: %v\n", userID, err)
+				logger.Printf(c, "获取用户[%s]设置失败: %v", userID, err)
 				continue
 			}
 			region = setting.GetRegionCode()
-			fmt.Printf("获取用户[%s]默认区域: %s\n", userID, region)
+			logger.Printf(c, "获取用户[%s]默认区域: %s", userID, region)
 		}
 
 		// 管理员手动补机时，直接添加到补机队列 - 已移除额外的布尔参数
-		makeupQueue.AddToQueueWithRegion(userID, count, region)
+		queueID := makeupQueue.AddToQueueWithRegion(userID, count, region)
+		waitTasks = append(waitTasks, makeupWaitTask{userID: userID, region: region, queueID: queueID})
 		successCount++
 	}
 
+	if c.Query("wait") != "true" || len(waitTasks) == 0 {
+		response.Success(c, http.StatusOK, gin.H{
+			"message":       fmt.Sprintf("已提交%d个用户的补机任务", successCount),
+			"success_count": successCount,
+			"failed_ids":    failedIDs,
+		})
+		return
+	}
+
+	// 同步等待模式：轮询队列状态直到全部完成或超时
+	timeout := parseMakeupWaitTimeout(c.Query("timeout"))
+	logger.Printf(c, "同步等待%d个补机任务完成，超时时间: %v", len(waitTasks), timeout)
+	progress, allDone := waitForMakeupTasks(c, makeupQueue, waitTasks, timeout)
+
+	message := fmt.Sprintf("已提交%d个用户的补机任务，全部完成", successCount)
+	if !allDone {
+		message = fmt.Sprintf("已提交%d个用户的补机任务，等待超时，部分任务未完成", successCount)
+		allTerminal := true
+		for _, p := range progress {
+			if !isMakeupTaskTerminal(p.Status) {
+				allTerminal = false
+				break
+			}
+		}
+		if allTerminal {
+			message = fmt.Sprintf("已提交%d个用户的补机任务，部分任务已放弃或已被清理", successCount)
+		}
+	}
+
 	response.Success(c, http.StatusOK, gin.H{
-		"message":       fmt.Sprintf("已提交%d个用户的补机任务", successCount),
+		"message":       message,
 		"success_count": successCount,
 		"failed_ids":    failedIDs,
+		"all_completed": allDone,
+		"progress":      progress,
 	})
 }
 
@@ -201,7 +332,7 @@ type CreateUserRequest struct {
 // CreateUser 创建新用户
 func CreateUser(c *gin.Context) {
 	// 验证管理员权限
-	if !checkAdminPermission(c) {
+	if !middleware.RequireAdmin(c) {
 		return
 	}
 
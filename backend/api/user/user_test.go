@@ -0,0 +1,112 @@
+package user
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeProgress 按调用次数依次返回预设状态的任务进度
+type fakeProgress struct {
+	mu     sync.Mutex
+	calls  map[string]int
+	states map[string][]string // 队列ID -> 每次查询返回的状态，最后一个状态保持不变
+}
+
+func (f *fakeProgress) GetTaskProgress(queueKey string) (int, int, string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	states, exists := f.states[queueKey]
+	if !exists {
+		return 0, 0, "", false
+	}
+	i := f.calls[queueKey]
+	f.calls[queueKey]++
+	if i >= len(states) {
+		i = len(states) - 1
+	}
+	if states[i] == "已完成" {
+		return 2, 2, states[i], true
+	}
+	return 1, 2, states[i], true
+}
+
+func newWaitContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	return c
+}
+
+func TestWaitForMakeupTasks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldInterval := makeupWaitPollInterval
+	makeupWaitPollInterval = 10 * time.Millisecond
+	defer func() { makeupWaitPollInterval = oldInterval }()
+
+	t.Run("超时前完成", func(t *testing.T) {
+		src := &fakeProgress{calls: map[string]int{}, states: map[string][]string{
+			"q1": {"进行中", "进行中", "已完成"},
+		}}
+		progress, allDone := waitForMakeupTasks(newWaitContext(), src,
+			[]makeupWaitTask{{userID: "u1", region: "hk", queueID: "q1"}}, time.Second)
+		if !allDone || progress[0].Status != "已完成" || progress[0].Remaining != 0 {
+			t.Fatalf("期望全部完成, 得到 allDone=%v progress=%+v", allDone, progress)
+		}
+	})
+
+	t.Run("已放弃或已清理的任务立即返回", func(t *testing.T) {
+		src := &fakeProgress{calls: map[string]int{}, states: map[string][]string{
+			"q1": {"已放弃"},
+			"q2": {"已完成"},
+		}}
+		start := time.Now()
+		progress, allDone := waitForMakeupTasks(newWaitContext(), src, []makeupWaitTask{
+			{userID: "u1", queueID: "q1"},
+			{userID: "u2", queueID: "q2"},
+			{userID: "u3", queueID: "gone"},
+		}, 5*time.Second)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("结束状态的任务不应等待到超时, 耗时 %v", elapsed)
+		}
+		if allDone {
+			t.Fatal("存在已放弃的任务时不应报告全部完成")
+		}
+		want := []string{"已放弃", "已完成", "不存在"}
+		for i, p := range progress {
+			if p.Status != want[i] {
+				t.Errorf("任务%d状态 = %q, 期望 %q", i, p.Status, want[i])
+			}
+		}
+	})
+
+	t.Run("未结束的任务等到超时", func(t *testing.T) {
+		src := &fakeProgress{calls: map[string]int{}, states: map[string][]string{
+			"q1": {"进行中"},
+		}}
+		progress, allDone := waitForMakeupTasks(newWaitContext(), src,
+			[]makeupWaitTask{{userID: "u1", queueID: "q1"}}, 50*time.Millisecond)
+		if allDone || progress[0].Status != "进行中" {
+			t.Fatalf("期望超时未完成, 得到 allDone=%v progress=%+v", allDone, progress)
+		}
+	})
+}
+
+func TestParseMakeupWaitTimeout(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":     defaultMakeupWaitTimeout,
+		"abc":  defaultMakeupWaitTimeout,
+		"-1":   defaultMakeupWaitTimeout,
+		"30":   30 * time.Second,
+		"9999": maxMakeupWaitTimeout,
+	}
+	for input, want := range cases {
+		if got := parseMakeupWaitTimeout(input); got != want {
+			t.Errorf("parseMakeupWaitTimeout(%q) = %v, 期望 %v", input, got, want)
+		}
+	}
+}
@@ -3,35 +3,27 @@ package main
 
 import (
 	"log"
-	"os"
-	"path/filepath"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 
 	"portal/middleware"
+	"portal/pkg/config"
 	_ "portal/pkg/logger" // 导入日志模块，自动初始化
 	"portal/pkg/pool"
 	"portal/pkg/tg"
 	"portal/repository"
 	"portal/routes"
+	"portal/service/account"
 	"portal/utils/s3"
 )
 
 func init() {
-	// 先检查系统环境变量
-	wsURL := os.Getenv("WS_URL")
-	if wsURL == "" {
-		// 只有在系统环境变量不存在时才加载.env文件
-		currentDir, _ := os.Getwd()
-		parentDir := filepath.Dir(currentDir)
-		envPath := filepath.Join(parentDir, ".env")
-
-		_ = godotenv.Load(envPath)
-		wsURL = os.Getenv("WS_URL") // 重新获取
+	// 加载并校验配置，必填项缺失或非法时直接终止启动
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("配置校验失败: %v", err)
 	}
-
-	log.Printf("当前WebSocket域名: %s", wsURL)
+	cfg.LogSummary()
 }
 
 // setupRouter 配置路由
@@ -47,6 +39,7 @@ func setupRouter() *gin.Engine {
 
 	// 使用全局中间件
 	r.Use(gin.Recovery())
+	r.Use(middleware.RequestIDMiddleware())
 	r.Use(gin.Logger())
 	r.Use(middleware.CORSMiddleware())
 
@@ -79,6 +72,12 @@ func main() {
 	// 初始化连接池服务
 	pool.InitPool()
 
+	// 启动实例数量定时校准（可选）
+	account.StartVMCountReconcileJob(repository.GetDB())
+
+	// 启动账号状态定时复检（可选）
+	account.StartAccountRecheckJob(repository.GetDB())
+
 	// 初始化TG客户端
 	if err := tg.InitTgClient(); err != nil {
 		log.Printf("TG客户端初始化失败: %v", err)
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"portal/pkg/response"
+)
+
+// IsAdmin 判断当前请求的用户是否为管理员，未经过JWT认证的请求视为非管理员
+func IsAdmin(c *gin.Context) bool {
+	isAdmin, exists := c.Get("is_admin")
+	if !exists {
+		return false
+	}
+	adminValue, ok := isAdmin.(uint8)
+	return ok && adminValue == 1
+}
+
+// RequireAdmin 检查管理员权限，不是管理员时返回403，调用方在返回false时直接结束处理
+func RequireAdmin(c *gin.Context) bool {
+	if !IsAdmin(c) {
+		response.Error(c, http.StatusForbidden, "需要管理员权限")
+		return false
+	}
+	return true
+}
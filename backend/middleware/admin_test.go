@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		isAdmin  interface{}
+		set      bool
+		wantCode int
+	}{
+		{"管理员", uint8(1), true, http.StatusOK},
+		{"普通用户", uint8(0), true, http.StatusForbidden},
+		{"类型不符", 1, true, http.StatusForbidden},
+		{"未经过认证", nil, false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", func(c *gin.Context) {
+				if tt.set {
+					c.Set("is_admin", tt.isAdmin)
+				}
+				if !RequireAdmin(c) {
+					return
+				}
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("状态码 = %d, 期望 %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
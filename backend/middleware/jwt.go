@@ -2,12 +2,13 @@ package middleware
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+
+	"portal/pkg/config"
 )
 
 // CustomClaims 自定义JWT的声明
@@ -17,46 +18,14 @@ type CustomClaims struct {
 	jwt.RegisteredClaims
 }
 
-var jwtSecret []byte
-var jwtExpire time.Duration
-
-// 移除init函数，改为延迟初始化
-
-// 获取JWT Secret
+// 获取JWT Secret，默认值与校验由配置模块统一处理
 func getJWTSecret() []byte {
-	if len(jwtSecret) == 0 {
-		// 延迟初始化，第一次使用时加载
-		secretStr := os.Getenv("JWT_SECRET")
-		if secretStr == "" {
-			// 如果环境变量未设置，使用默认值并记录日志
-			fmt.Println("警告: JWT_SECRET 环境变量未设置，使用默认值")
-			secretStr = "default_jwt_secret_for_development"
-		}
-		jwtSecret = []byte(secretStr)
-	}
-	return jwtSecret
+	return []byte(config.Get().JWT.Secret)
 }
 
 // 获取JWT过期时间
 func getJWTExpire() time.Duration {
-	if jwtExpire == 0 {
-		// 延迟初始化，第一次使用时加载
-		expireStr := os.Getenv("JWT_EXPIRE")
-		if expireStr == "" {
-			// 如果环境变量未设置，使用默认值并记录日志
-			fmt.Println("警告: JWT_EXPIRE 环境变量未设置，使用默认值 24h")
-			expireStr = "24h"
-		}
-
-		var err error
-		jwtExpire, err = time.ParseDuration(expireStr)
-		if err != nil {
-			// 如果解析失败，使用默认值
-			fmt.Printf("警告: 解析JWT过期时间失败: %v，使用默认值 24h\n", err)
-			jwtExpire = 24 * time.Hour
-		}
-	}
-	return jwtExpire
+	return config.Get().JWT.Expire
 }
 
 // GenerateToken 生成JWT token
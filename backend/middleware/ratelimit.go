@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitWindow 单个限流窗口内的请求计数
+type rateLimitWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimitMiddleware 按用户限流的中间件，每个用户在window时间内最多请求limit次
+// 未登录的请求按客户端IP限流
+func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := make(map[string]*rateLimitWindow)
+
+	return func(c *gin.Context) {
+		key := c.GetString("user_id")
+		if key == "" {
+			key = "ip:" + c.ClientIP()
+		}
+
+		now := time.Now()
+		mu.Lock()
+		// 顺带清理已过期的窗口，避免内存持续增长
+		for k, w := range windows {
+			if now.Sub(w.start) >= window {
+				delete(windows, k)
+			}
+		}
+		w, exists := windows[key]
+		if !exists {
+			w = &rateLimitWindow{start: now}
+			windows[key] = w
+		}
+		w.count++
+		count := w.count
+		retryAfter := window - now.Sub(w.start)
+		mu.Unlock()
+
+		if count > limit {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+			c.JSON(429, gin.H{
+				"code": 429,
+				"msg":  fmt.Sprintf("请求过于频繁，请在%d秒后重试", int(retryAfter.Seconds())+1),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
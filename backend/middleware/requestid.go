@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"portal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 请求ID的请求/响应头
+const RequestIDHeader = "X-Request-ID"
+
+// generateRequestID 生成16位十六进制请求ID
+func generateRequestID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		// 随机数生成失败时退回到时间戳
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// RequestIDMiddleware 为每个请求分配请求ID
+// 优先沿用请求头中的ID，没有则生成新的，写入上下文并在响应头中返回
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = generateRequestID()
+		}
+
+		c.Set(logger.RequestIDKey, requestID)
+		// 同时写入请求的 context，service 层拿到 c.Request.Context() 也能取到请求ID
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+		c.Writer.Header().Set(RequestIDHeader, requestID)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"portal/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var fromGin, fromRequest string
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		fromGin = logger.RequestIDFromContext(c)
+		fromRequest = logger.RequestIDFromContext(c.Request.Context())
+	})
+
+	t.Run("沿用请求头中的ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, "abc123")
+		r.ServeHTTP(w, req)
+
+		if fromGin != "abc123" || fromRequest != "abc123" {
+			t.Fatalf("请求ID未透传: gin=%q request=%q", fromGin, fromRequest)
+		}
+		if got := w.Header().Get(RequestIDHeader); got != "abc123" {
+			t.Fatalf("响应头请求ID = %q", got)
+		}
+	})
+
+	t.Run("缺失时生成新ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if len(fromRequest) != 16 || fromRequest != fromGin {
+			t.Fatalf("生成的请求ID异常: gin=%q request=%q", fromGin, fromRequest)
+		}
+		if got := w.Header().Get(RequestIDHeader); got != fromRequest {
+			t.Fatalf("响应头请求ID = %q, 期望 %q", got, fromRequest)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package model
 
 import (
 	"fmt"
+	"portal/pkg/region"
 	"strconv"
 	"strings"
 	"time"
@@ -12,17 +13,33 @@ import (
 
 // Account AWS账号模型
 type Account struct {
-	ID         string     `gorm:"primarykey;type:varchar(255)" json:"id"`              // 自增ID
-	UserID     string     `gorm:"type:varchar(255);not null" json:"user_id"`           // 用户ID
-	Key1       string     `gorm:"type:varchar(255);not null" json:"key1"`              // Key1
-	Key2       string     `gorm:"type:varchar(255);not null" json:"key2"`              // Key2
-	Email      *string    `gorm:"type:varchar(255);default:null" json:"email"`         // 邮箱
-	Password   *string    `gorm:"type:varchar(255);default:null" json:"password"`      // 密码
-	Quatos     *string    `gorm:"type:varchar(255);default:null" json:"quatos"`        // 配额
-	HK         *string    `gorm:"type:varchar(255);default:null" json:"hk"`            // HK区状态
-	VMCount    *int       `gorm:"type:int;default:null" json:"vm_count"`               // 虚拟机数量
-	Region     *string    `gorm:"type:varchar(255);default:'ap-east-1'" json:"region"` // 区域代码
-	CreateTime *time.Time `gorm:"type:timestamp;default:null" json:"create_time"`      // 创建时间
+	ID            string     `gorm:"primarykey;type:varchar(255)" json:"id"`                    // 自增ID
+	UserID        string     `gorm:"type:varchar(255);not null" json:"user_id"`                 // 用户ID
+	Key1          string     `gorm:"type:varchar(255);not null" json:"key1"`                    // Key1
+	Key2          string     `gorm:"type:varchar(255);not null" json:"key2"`                    // Key2
+	Email         *string    `gorm:"type:varchar(255);default:null" json:"email"`               // 邮箱
+	Password      *string    `gorm:"type:varchar(255);default:null" json:"password"`            // 密码
+	Quatos        *string    `gorm:"type:varchar(255);default:null" json:"quatos"`              // 配额
+	HK            *string    `gorm:"type:varchar(255);default:null" json:"hk"`                  // HK区状态
+	VMCount       *int       `gorm:"type:int;default:null" json:"vm_count"`                     // 虚拟机数量
+	VMCountRegion *string    `gorm:"type:varchar(255);default:null" json:"vm_count_region"`     // 虚拟机数量对应的区域
+	Region        *string    `gorm:"type:varchar(255);default:'ap-east-1'" json:"region"`       // 区域代码
+	CreateTime    *time.Time `gorm:"type:timestamp;default:null" json:"create_time"`            // 创建时间
+	Enabled       *bool      `gorm:"not null;default:true" json:"enabled"`                      // 是否参与补机，停用后保留账号但不加入账号池
+	ExternalRef   *string    `gorm:"type:varchar(255);unique;default:null" json:"external_ref"` // 外部引用，导入时可选，用于跨库恢复后仍能稳定识别账号
+}
+
+// IsEnabled 账号是否启用，未读取该字段时视为启用
+func (a *Account) IsEnabled() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+// RegionCode 获取账号的区域代码，未设置区域时视为默认的香港区域，与数据库字段默认值一致
+func (a *Account) RegionCode() string {
+	if a.Region == nil || *a.Region == "" {
+		return region.HK
+	}
+	return *a.Region
 }
 
 // TableName 指定表名
@@ -42,26 +59,22 @@ type ImportResult struct {
 		DuplicateList   []string `json:"duplicate_list,omitempty"`    // 重复账号列表
 		FormatErrorList []string `json:"format_error_list,omitempty"` // 格式错误账号列表
 	} `json:"details"`
+	DryRun          bool      `json:"dry_run,omitempty"` // 是否为预览结果，预览时不会写入数据库
+	CreatedAccounts []Account `json:"-"`                 // 成功创建的账号，用于直接加入账号池
 }
 
 // AccountInput 导入的账号信息
 type AccountInput struct {
-	Account  string
-	Password string
-	Key1     string
-	Key2     string
-	Region   string // 新增区域字段
-}
-
-// RegionMapping 区域名称到代码的映射
-var RegionMapping = map[string]string{
-	"香港":  "ap-east-1",
-	"日本":  "ap-northeast-3",
-	"新加坡": "ap-southeast-1",
+	Account     string
+	Password    string
+	Key1        string
+	Key2        string
+	Region      string // 新增区域字段
+	ExternalRef string // 外部引用，可选
 }
 
-// ParseAccountList 解析账号列表
-func ParseAccountList(input string) ([]AccountInput, []string) {
+// ParseAccountList 解析账号列表，lenientRegion为true时无法识别的区域回退为默认香港区域
+func ParseAccountList(input string, lenientRegion bool) ([]AccountInput, []string) {
 	var accounts []AccountInput
 	var errorLines []string
 
@@ -73,7 +86,7 @@ func ParseAccountList(input string) ([]AccountInput, []string) {
 			continue
 		}
 
-		account, err := parseAccountLine(line)
+		account, err := parseAccountLine(line, lenientRegion)
 		if err != nil {
 			errorLines = append(errorLines, err.Error())
 			continue
@@ -85,13 +98,14 @@ func ParseAccountList(input string) ([]AccountInput, []string) {
 }
 
 // parseAccountLine 修改错误提示格式
-func parseAccountLine(line string) (AccountInput, error) {
+// 格式: 邮箱---密码---key1---key2[---区域[---外部引用]]，只指定外部引用时区域可留空
+func parseAccountLine(line string, lenientRegion bool) (AccountInput, error) {
 	// 尝试用 ---- 分割
 	parts := strings.Split(line, "----")
-	if len(parts) != 4 && len(parts) != 5 {
+	if len(parts) < 4 || len(parts) > 6 {
 		// 尝试用 --- 分割
 		parts = strings.Split(line, "---")
-		if len(parts) != 4 && len(parts) != 5 {
+		if len(parts) < 4 || len(parts) > 6 {
 			return AccountInput{}, fmt.Errorf("%s", line) // 直接返回原始行
 		}
 	}
@@ -118,28 +132,21 @@ func parseAccountLine(line string) (AccountInput, error) {
 	}
 
 	// 如果存在第5个字段作为区域
-	if len(parts) == 5 && parts[4] != "" {
-		region := parts[4]
-
-		// 处理简写格式
-		switch strings.ToUpper(region) {
-		case "HK":
-			accountInput.Region = "ap-east-1" // 香港
-		case "JP":
-			accountInput.Region = "ap-northeast-3" // 日本
-		case "SG":
-			accountInput.Region = "ap-southeast-1" // 新加坡
-		default:
-			// 检查是否是完整的区域代码
-			if region == "ap-east-1" || region == "ap-northeast-3" || region == "ap-southeast-1" {
-				accountInput.Region = region
-			} else if code, exists := RegionMapping[region]; exists {
-				// 如果是中文区域名，转换为代码
-				accountInput.Region = code
-			}
+	if len(parts) >= 5 && parts[4] != "" {
+		// 处理简写、中文名和完整区域代码
+		if code := region.Normalize(parts[4]); region.IsSupported(code) {
+			accountInput.Region = code
+		} else if !lenientRegion {
+			// 无法识别的区域视为格式错误，避免拼写错误的区域被静默替换为香港
+			return AccountInput{}, fmt.Errorf("%s (无法识别的区域: %s)", line, parts[4])
 		}
 	}
 
+	// 第6个字段作为外部引用
+	if len(parts) == 6 {
+		accountInput.ExternalRef = parts[5]
+	}
+
 	return accountInput, nil
 }
 
@@ -150,17 +157,10 @@ func ValidateAndCreateAccounts(db *gorm.DB, accounts []AccountInput, userID stri
 
 	for _, input := range accounts {
 		// 检查重复
-		var count int64
-		db.Model(&Account{}).Where("key1 = ? OR key2 = ?", input.Key1, input.Key2).Count(&count)
-		if count > 0 {
+		if isDuplicateAccount(db, input) {
 			result.Summary.DuplicateCount++
 			result.Summary.FailedCount++
-			// 将完整的账号信息格式化为原始输入格式
-			duplicateInfo := fmt.Sprintf("%s---%s---%s---%s", input.Account, input.Password, input.Key1, input.Key2)
-			if input.Region != "ap-east-1" {
-				duplicateInfo += fmt.Sprintf("---%s", input.Region)
-			}
-			result.Details.DuplicateList = append(result.Details.DuplicateList, duplicateInfo)
+			result.Details.DuplicateList = append(result.Details.DuplicateList, formatDuplicateInfo(input))
 			continue
 		}
 
@@ -179,6 +179,9 @@ func ValidateAndCreateAccounts(db *gorm.DB, accounts []AccountInput, userID stri
 		}
 		// 设置区域信息
 		account.Region = &input.Region
+		if input.ExternalRef != "" {
+			account.ExternalRef = &input.ExternalRef
+		}
 
 		if err := db.Create(&account).Error; err != nil {
 			fmt.Printf("创建账号失败，账号: %s, 错误: %v\n", input.Account, err)
@@ -187,11 +190,61 @@ func ValidateAndCreateAccounts(db *gorm.DB, accounts []AccountInput, userID stri
 		}
 
 		result.Summary.SuccessCount++
+		result.CreatedAccounts = append(result.CreatedAccounts, account)
+	}
+
+	return result
+}
+
+// PreviewAccountsImport 预览导入结果，只做重复检查不写入数据库
+// 同一批次中key重复的账号在实际导入时会被数据库检测为重复，这里同样计为重复
+func PreviewAccountsImport(db *gorm.DB, accounts []AccountInput) ImportResult {
+	result := ImportResult{DryRun: true}
+	seenKeys := make(map[string]bool)
+	seenRefs := make(map[string]bool)
+
+	for _, input := range accounts {
+		if isDuplicateAccount(db, input) || seenKeys[input.Key1] || seenKeys[input.Key2] ||
+			(input.ExternalRef != "" && seenRefs[input.ExternalRef]) {
+			result.Summary.DuplicateCount++
+			result.Summary.FailedCount++
+			result.Details.DuplicateList = append(result.Details.DuplicateList, formatDuplicateInfo(input))
+			continue
+		}
+
+		seenKeys[input.Key1] = true
+		seenKeys[input.Key2] = true
+		if input.ExternalRef != "" {
+			seenRefs[input.ExternalRef] = true
+		}
+		result.Summary.SuccessCount++
 	}
 
 	return result
 }
 
+// isDuplicateAccount 检查key或外部引用是否已被现有账号使用
+func isDuplicateAccount(db *gorm.DB, input AccountInput) bool {
+	var count int64
+	query := db.Model(&Account{}).Where("key1 = ? OR key2 = ?", input.Key1, input.Key2)
+	if input.ExternalRef != "" {
+		query = query.Or("external_ref = ?", input.ExternalRef)
+	}
+	query.Count(&count)
+	return count > 0
+}
+
+// formatDuplicateInfo 将完整的账号信息格式化为原始输入格式
+func formatDuplicateInfo(input AccountInput) string {
+	duplicateInfo := fmt.Sprintf("%s---%s---%s---%s", input.Account, input.Password, input.Key1, input.Key2)
+	if input.ExternalRef != "" {
+		duplicateInfo += fmt.Sprintf("---%s---%s", input.Region, input.ExternalRef)
+	} else if input.Region != "ap-east-1" {
+		duplicateInfo += fmt.Sprintf("---%s", input.Region)
+	}
+	return duplicateInfo
+}
+
 // List 获取指定用户ID的所有账号列表
 func List(db *gorm.DB, userID string) ([]Account, error) {
 	var accounts []Account
@@ -238,8 +291,35 @@ func GetAccountKeysByIDs(db *gorm.DB, userID string, accountIDs []string) ([]Acc
 	return accounts, err
 }
 
+// GetAccountKeysByIDsForAdmin 管理员获取账号的key信息，不校验账号归属
+func GetAccountKeysByIDsForAdmin(db *gorm.DB, accountIDs []string) ([]Account, error) {
+	var accounts []Account
+	err := db.Select("id, key1, key2, region").Where("id IN ?", accountIDs).Find(&accounts).Error
+	return accounts, err
+}
+
+// GetAccountsByIDs 通过ID数组获取完整账号信息，不校验账号归属
+func GetAccountsByIDs(db *gorm.DB, accountIDs []string) ([]Account, error) {
+	var accounts []Account
+	err := db.Where("id IN ?", accountIDs).Find(&accounts).Error
+	return accounts, err
+}
+
+// MarkAccountQueryFailed 仅在账号尚无配额状态时标记为查询失败，用于临时错误，不覆盖已有状态
+func MarkAccountQueryFailed(db *gorm.DB, accountID string) error {
+	return db.Model(&Account{}).
+		Where("id = ? AND (quatos IS NULL OR quatos = '')", accountID).
+		Update("quatos", "查询失败").Error
+}
+
 // UpdateAccountStatus 更新账号状态
 func UpdateAccountStatus(db *gorm.DB, accountID string, quota, hkStatus string, instanceCount *int32) error {
+	return UpdateAccountStatusInRegion(db, accountID, quota, hkStatus, instanceCount, "")
+}
+
+// UpdateAccountStatusInRegion 更新账号状态，并记录实例数量是在哪个区域统计的
+// instanceCount为nil时同时清空数量和区域，避免保留过期的统计
+func UpdateAccountStatusInRegion(db *gorm.DB, accountID string, quota, hkStatus string, instanceCount *int32, countRegion string) error {
 	// 使用事务确保并发安全
 	tx := db.Begin()
 	defer func() {
@@ -257,6 +337,11 @@ func UpdateAccountStatus(db *gorm.DB, accountID string, quota, hkStatus string,
 		"hk":       hkStatus,
 		"vm_count": instanceCount,
 	}
+	if instanceCount != nil && countRegion != "" {
+		updates["vm_count_region"] = countRegion
+	} else {
+		updates["vm_count_region"] = nil
+	}
 
 	if err := tx.Model(&Account{}).Where("id = ?", accountID).Updates(updates).Error; err != nil {
 		tx.Rollback()
@@ -266,6 +351,34 @@ func UpdateAccountStatus(db *gorm.DB, accountID string, quota, hkStatus string,
 	return tx.Commit().Error
 }
 
+// UpdateAccountVMCount 只更新账号的实例数量及其统计区域
+func UpdateAccountVMCount(db *gorm.DB, accountID string, instanceCount int32, countRegion string) error {
+	return db.Model(&Account{}).Where("id = ?", accountID).Updates(map[string]interface{}{
+		"vm_count":        instanceCount,
+		"vm_count_region": countRegion,
+	}).Error
+}
+
+// UpdateAccountRegion 更新账号的区域代码
+func UpdateAccountRegion(db *gorm.DB, accountID string, regionCode string) error {
+	return db.Model(&Account{}).Where("id = ?", accountID).Update("region", regionCode).Error
+}
+
+// SetAccountsEnabled 批量启用或停用账号
+func SetAccountsEnabled(db *gorm.DB, accountIDs []string, enabled bool) (int64, error) {
+	result := db.Model(&Account{}).Where("id IN ?", accountIDs).Update("enabled", enabled)
+	return result.RowsAffected, result.Error
+}
+
+// ListAllValidAccounts 获取所有用户的有效账号
+func ListAllValidAccounts(db *gorm.DB) ([]Account, error) {
+	var accounts []Account
+	err := db.Where("quatos != '账号已失效' OR quatos IS NULL").
+		Select("id, user_id, key1, key2, region, vm_count").
+		Find(&accounts).Error
+	return accounts, err
+}
+
 // ListValidAccounts 获取指定用户的有效账号列表
 func ListValidAccounts(db *gorm.DB, userID string) ([]Account, error) {
 	var accounts []Account
@@ -279,6 +392,16 @@ func ListValidAccounts(db *gorm.DB, userID string) ([]Account, error) {
 	return accounts, nil
 }
 
+// ListQueryFailedAccounts 获取最近一次检测结果为"查询失败"的账号，不返回密钥
+func ListQueryFailedAccounts(db *gorm.DB) ([]Account, error) {
+	var accounts []Account
+	err := db.Where("quatos = ?", "查询失败").
+		Select("id, user_id, email, quatos, hk, vm_count, region, create_time").
+		Order("id ASC").
+		Find(&accounts).Error
+	return accounts, err
+}
+
 // BeforeCreate GORM 的钩子，在创建记录前自动设置 ID
 func (a *Account) BeforeCreate(tx *gorm.DB) error {
 	// 查询当前最大 ID
@@ -295,16 +418,11 @@ func (a *Account) BeforeCreate(tx *gorm.DB) error {
 
 // GetRegionCode 根据区域名称获取区域代码
 func GetRegionCode(regionName string) string {
-	// 如果已经是区域代码，直接返回
-	if regionName == "ap-east-1" || regionName == "ap-northeast-3" || regionName == "ap-southeast-1" {
-		return regionName
-	}
-
-	// 如果是中文名称，转换为代码
-	if code, exists := RegionMapping[regionName]; exists {
+	// 区域代码或别名，统一转换为区域代码
+	if code := region.Normalize(regionName); region.IsSupported(code) {
 		return code
 	}
 
 	// 默认返回香港区域代码
-	return "ap-east-1"
+	return region.HK
 }
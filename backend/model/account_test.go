@@ -0,0 +1,110 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+)
+
+func TestParseAccountListRegion(t *testing.T) {
+	const prefix = "user@example.com----password----AKIAIMPORT----secret"
+	tests := []struct {
+		name       string
+		regionPart string
+		lenient    bool
+		wantRegion string
+		wantError  bool
+	}{
+		{"区域简写", "jp", false, region.JP, false},
+		{"中文区域名", "新加坡", false, region.SG, false},
+		{"完整区域代码", "ap-northeast-3", false, region.JP, false},
+		{"未填写区域使用默认区域", "", false, region.HK, false},
+		{"无法识别的区域视为格式错误", "tokyo1", false, "", true},
+		{"宽松模式下回退为默认区域", "tokyo1", true, region.HK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := prefix
+			if tt.regionPart != "" {
+				line += "----" + tt.regionPart
+			}
+			accounts, errorLines := ParseAccountList(line, tt.lenient)
+
+			if tt.wantError {
+				if len(accounts) != 0 || len(errorLines) != 1 || !strings.Contains(errorLines[0], tt.regionPart) {
+					t.Fatalf("解析结果 = %+v, 错误行 = %v, 期望该行因区域无法识别被拒绝", accounts, errorLines)
+				}
+				return
+			}
+			if len(errorLines) != 0 || len(accounts) != 1 {
+				t.Fatalf("解析结果 = %+v, 错误行 = %v, 期望解析出1个账号", accounts, errorLines)
+			}
+			if accounts[0].Region != tt.wantRegion {
+				t.Fatalf("区域 = %s, want %s", accounts[0].Region, tt.wantRegion)
+			}
+		})
+	}
+}
+
+func TestAccountRegionCode(t *testing.T) {
+	empty, jp := "", region.JP
+	tests := []struct {
+		name   string
+		region *string
+		want   string
+	}{
+		{"未设置区域视为香港", nil, region.HK},
+		{"空区域视为香港", &empty, region.HK},
+		{"使用账号区域", &jp, region.JP},
+	}
+	for _, tt := range tests {
+		acc := Account{Region: tt.region}
+		if got := acc.RegionCode(); got != tt.want {
+			t.Errorf("%s: RegionCode() = %s, 期望 %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImportAccountsExternalRef(t *testing.T) {
+	db := testdb.Open(t, Models()...)
+
+	accounts, errorLines := ParseAccountList(
+		"a@example.com---pass---AKIAREF1---secret1---jp---crm-1001\n"+
+			"b@example.com---pass---AKIAREF2---secret2\n"+
+			"c@example.com---pass---AKIAREF3---secret3------crm-1002\n", false)
+	if len(errorLines) != 0 || len(accounts) != 3 {
+		t.Fatalf("解析结果 = %+v, 错误行 %v", accounts, errorLines)
+	}
+	if accounts[0].ExternalRef != "crm-1001" || accounts[0].Region != region.JP {
+		t.Fatalf("带区域和外部引用的账号 = %+v", accounts[0])
+	}
+	// 只指定外部引用时区域留空，使用默认区域
+	if accounts[2].ExternalRef != "crm-1002" || accounts[2].Region != region.HK {
+		t.Fatalf("区域留空的账号 = %+v", accounts[2])
+	}
+
+	result := ValidateAndCreateAccounts(db, accounts, "ref-user")
+	if result.Summary.SuccessCount != 3 {
+		t.Fatalf("导入结果 = %+v, 期望3个账号全部导入", result.Summary)
+	}
+	refs := make(map[string]*string)
+	for _, acc := range result.CreatedAccounts {
+		refs[acc.Key1] = acc.ExternalRef
+	}
+	if refs["AKIAREF1"] == nil || *refs["AKIAREF1"] != "crm-1001" {
+		t.Errorf("AKIAREF1的外部引用 = %v, want crm-1001", refs["AKIAREF1"])
+	}
+	if refs["AKIAREF2"] != nil {
+		t.Errorf("未提供外部引用的账号 = %v, want nil", *refs["AKIAREF2"])
+	}
+
+	// 外部引用已被使用时视为重复账号
+	result = ValidateAndCreateAccounts(db, []AccountInput{
+		{Account: "d@example.com", Key1: "AKIAREF4", Key2: "secret4", Region: region.HK, ExternalRef: "crm-1001"},
+	}, "ref-user")
+	if result.Summary.DuplicateCount != 1 || result.Summary.SuccessCount != 0 {
+		t.Fatalf("重复外部引用的导入结果 = %+v, 期望计为重复", result.Summary)
+	}
+}
@@ -0,0 +1,12 @@
+package model
+
+import (
+	"os"
+	"testing"
+)
+
+// 区域配置只在首次使用时加载一次，在所有测试之前登记额外区域
+func TestMain(m *testing.M) {
+	os.Setenv("EXTRA_REGIONS", `[{"code":"us-west-2","name":"美西区","ami":"ami-usw2"}]`)
+	os.Exit(m.Run())
+}
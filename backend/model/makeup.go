@@ -0,0 +1,136 @@
+// model/makeup.go
+package model
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MakeupTask 补机任务记录，用于统计补机耗时和成功率
+type MakeupTask struct {
+	QueueID        string     `gorm:"primarykey;type:varchar(255)" json:"queue_id"`    // 队列项唯一ID
+	UserID         string     `gorm:"type:varchar(255);not null;index" json:"user_id"` // 用户ID
+	Region         string     `gorm:"type:varchar(255);not null" json:"region"`        // 区域代码
+	TotalCount     int        `gorm:"not null;default:0" json:"total_count"`           // 需要补机总数
+	CompletedCount int        `gorm:"not null;default:0" json:"completed_count"`       // 已完成数量
+	FailedAttempts int        `gorm:"not null;default:0" json:"failed_attempts"`       // 开机失败的尝试次数
+	Status         string     `gorm:"type:varchar(32);not null" json:"status"`         // 状态：等待中、进行中、已完成、已放弃
+	AddTime        time.Time  `gorm:"type:timestamp;not null;index" json:"add_time"`   // 添加到队列的时间
+	StartTime      *time.Time `gorm:"type:timestamp;default:null" json:"start_time"`   // 首次开始处理的时间
+	FinishTime     *time.Time `gorm:"type:timestamp;default:null" json:"finish_time"`  // 完成或放弃的时间
+}
+
+// TableName 指定表名
+func (MakeupTask) TableName() string {
+	return "makeup_task"
+}
+
+// SaveMakeupTask 保存补机任务记录，已存在时覆盖
+func SaveMakeupTask(db *gorm.DB, task *MakeupTask) error {
+	return db.Save(task).Error
+}
+
+// ListMakeupTasksSince 获取指定时间之后添加的补机任务记录
+func ListMakeupTasksSince(db *gorm.DB, since time.Time) ([]MakeupTask, error) {
+	var tasks []MakeupTask
+	err := db.Where("add_time >= ?", since).Order("add_time ASC").Find(&tasks).Error
+	return tasks, err
+}
+
+// MakeupRegionStats 单个区域的补机统计
+type MakeupRegionStats struct {
+	Region            string  `json:"region"`              // 区域代码
+	Tasks             int     `json:"tasks"`               // 任务数
+	CompletedTasks    int     `json:"completed_tasks"`     // 已完成任务数
+	AbandonedTasks    int     `json:"abandoned_tasks"`     // 已放弃任务数
+	Launched          int     `json:"launched"`            // 成功开机数量
+	FailedAttempts    int     `json:"failed_attempts"`     // 开机失败的尝试次数
+	FailureRate       float64 `json:"failure_rate"`        // 失败率：失败次数 / (失败次数 + 成功开机数)
+	AvgLatencySeconds float64 `json:"avg_latency_seconds"` // 已完成任务从添加到完成的平均耗时（秒）
+	AvgWaitSeconds    float64 `json:"avg_wait_seconds"`    // 从添加到开始处理的平均等待时间（秒）
+	ThroughputPerHour float64 `json:"throughput_per_hour"` // 时间窗口内每小时成功开机数量
+	latencyTotal      float64
+	latencySamples    int
+	waitTotal         float64
+	waitSamples       int
+}
+
+// MakeupStats 补机吞吐量和耗时统计
+type MakeupStats struct {
+	Window  string               `json:"window"`  // 统计时间窗口
+	Since   time.Time            `json:"since"`   // 统计起始时间
+	Total   MakeupRegionStats    `json:"total"`   // 所有区域汇总
+	Regions []*MakeupRegionStats `json:"regions"` // 各区域统计，按区域代码排序
+}
+
+// ComputeMakeupStats 根据补机任务记录计算统计数据，window为统计时间窗口
+func ComputeMakeupStats(tasks []MakeupTask, since time.Time, window time.Duration) *MakeupStats {
+	stats := &MakeupStats{
+		Window: window.String(),
+		Since:  since,
+		Total:  MakeupRegionStats{Region: "all"},
+	}
+
+	byRegion := make(map[string]*MakeupRegionStats)
+	var regionOrder []string
+	for _, task := range tasks {
+		regionStats, exists := byRegion[task.Region]
+		if !exists {
+			regionStats = &MakeupRegionStats{Region: task.Region}
+			byRegion[task.Region] = regionStats
+			regionOrder = append(regionOrder, task.Region)
+		}
+		for _, s := range []*MakeupRegionStats{regionStats, &stats.Total} {
+			s.addTask(task)
+		}
+	}
+
+	sort.Strings(regionOrder)
+	for _, code := range regionOrder {
+		byRegion[code].finish(window)
+		stats.Regions = append(stats.Regions, byRegion[code])
+	}
+	stats.Total.finish(window)
+	return stats
+}
+
+// addTask 累加单个任务的数据
+func (s *MakeupRegionStats) addTask(task MakeupTask) {
+	s.Tasks++
+	s.Launched += task.CompletedCount
+	s.FailedAttempts += task.FailedAttempts
+
+	switch task.Status {
+	case "已完成":
+		s.CompletedTasks++
+		if task.FinishTime != nil {
+			s.latencyTotal += task.FinishTime.Sub(task.AddTime).Seconds()
+			s.latencySamples++
+		}
+	case "已放弃":
+		s.AbandonedTasks++
+	}
+
+	if task.StartTime != nil {
+		s.waitTotal += task.StartTime.Sub(task.AddTime).Seconds()
+		s.waitSamples++
+	}
+}
+
+// finish 根据累加的数据计算平均值和比率
+func (s *MakeupRegionStats) finish(window time.Duration) {
+	if s.latencySamples > 0 {
+		s.AvgLatencySeconds = s.latencyTotal / float64(s.latencySamples)
+	}
+	if s.waitSamples > 0 {
+		s.AvgWaitSeconds = s.waitTotal / float64(s.waitSamples)
+	}
+	if attempts := s.FailedAttempts + s.Launched; attempts > 0 {
+		s.FailureRate = float64(s.FailedAttempts) / float64(attempts)
+	}
+	if hours := window.Hours(); hours > 0 {
+		s.ThroughputPerHour = float64(s.Launched) / hours
+	}
+}
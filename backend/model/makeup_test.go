@@ -0,0 +1,83 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"portal/pkg/testdb"
+)
+
+func TestComputeMakeupStatsFromSeededTasks(t *testing.T) {
+	db := testdb.Open(t, Models()...)
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) *time.Time {
+		v := now.Add(time.Duration(minutes) * time.Minute)
+		return &v
+	}
+	seeds := []MakeupTask{
+		// 香港区：两个已完成任务，耗时10分钟和20分钟，等待1分钟和3分钟
+		{QueueID: "q1", UserID: "u1", Region: "ap-east-1", TotalCount: 2, CompletedCount: 2, FailedAttempts: 1,
+			Status: "已完成", AddTime: now, StartTime: at(1), FinishTime: at(10)},
+		{QueueID: "q2", UserID: "u2", Region: "ap-east-1", TotalCount: 3, CompletedCount: 3, FailedAttempts: 0,
+			Status: "已完成", AddTime: now, StartTime: at(3), FinishTime: at(20)},
+		// 日本区：一个已放弃任务，不计入耗时
+		{QueueID: "q3", UserID: "u1", Region: "ap-northeast-3", TotalCount: 2, CompletedCount: 1, FailedAttempts: 3,
+			Status: "已放弃", AddTime: now, StartTime: at(2), FinishTime: at(60)},
+		// 统计窗口之前的任务
+		{QueueID: "q0", UserID: "u1", Region: "ap-east-1", TotalCount: 5, CompletedCount: 5,
+			Status: "已完成", AddTime: now.Add(-48 * time.Hour), StartTime: at(-48 * 60), FinishTime: at(-47 * 60)},
+	}
+	for i := range seeds {
+		if err := SaveMakeupTask(db, &seeds[i]); err != nil {
+			t.Fatalf("写入任务记录失败: %v", err)
+		}
+	}
+
+	window := 24 * time.Hour
+	since := now.Add(-window)
+	tasks, err := ListMakeupTasksSince(db, since)
+	if err != nil {
+		t.Fatalf("读取任务记录失败: %v", err)
+	}
+	if len(tasks) != 3 {
+		t.Fatalf("统计窗口内应有3个任务，实际为 %d", len(tasks))
+	}
+
+	stats := ComputeMakeupStats(tasks, since, window)
+	if len(stats.Regions) != 2 || stats.Regions[0].Region != "ap-east-1" || stats.Regions[1].Region != "ap-northeast-3" {
+		t.Fatalf("区域统计应按区域代码排序，实际为 %+v", stats.Regions)
+	}
+
+	approx := func(name string, got, want float64) {
+		t.Helper()
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s = %v, 期望 %v", name, got, want)
+		}
+	}
+
+	hk := stats.Regions[0]
+	if hk.Tasks != 2 || hk.CompletedTasks != 2 || hk.Launched != 5 || hk.FailedAttempts != 1 {
+		t.Errorf("香港区统计错误: %+v", hk)
+	}
+	approx("香港区平均耗时", hk.AvgLatencySeconds, 15*60)
+	approx("香港区平均等待", hk.AvgWaitSeconds, 2*60)
+	approx("香港区失败率", hk.FailureRate, 1.0/6)
+	approx("香港区吞吐量", hk.ThroughputPerHour, 5.0/24)
+
+	jp := stats.Regions[1]
+	if jp.AbandonedTasks != 1 || jp.CompletedTasks != 0 {
+		t.Errorf("日本区统计错误: %+v", jp)
+	}
+	approx("日本区平均耗时", jp.AvgLatencySeconds, 0)
+	approx("日本区失败率", jp.FailureRate, 3.0/4)
+
+	total := stats.Total
+	if total.Tasks != 3 || total.Launched != 6 || total.FailedAttempts != 4 {
+		t.Errorf("汇总统计错误: %+v", total)
+	}
+	approx("汇总平均耗时", total.AvgLatencySeconds, 15*60)
+	approx("汇总平均等待", total.AvgWaitSeconds, 2*60)
+	approx("汇总失败率", total.FailureRate, 4.0/10)
+}
@@ -0,0 +1,7 @@
+// model/models.go
+package model
+
+// Models 返回需要建表的所有模型，数据库迁移和测试数据库使用同一份列表
+func Models() []interface{} {
+	return []interface{}{&User{}, &Account{}, &Setting{}, &Monitor{}, &MakeupTask{}}
+}
@@ -2,26 +2,40 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"os"
+	"portal/pkg/region"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 // Monitor 监控配置模型
 type Monitor struct {
-	ID               uint   `gorm:"primaryKey;autoIncrement" json:"id"`                // 让数据库自动递增
-	UserID           string `gorm:"type:varchar(255);not null" json:"user_id"`         // 用户ID
-	Threshold        int    `gorm:"not null;default:0" json:"threshold"`               // 香港区阈值，默认为0
-	JpThreshold      int    `gorm:"not null;default:0" json:"jp_threshold"`            // 日本区阈值，默认为0
-	SgThreshold      int    `gorm:"not null;default:0" json:"sg_threshold"`            // 新加坡区阈值，默认为0
-	IsEnabled        bool   `gorm:"not null;default:false" json:"is_enabled"`          // 监控开关，默认关闭
-	IsTgEnabled      bool   `gorm:"not null;default:false" json:"is_tg_enabled"`       // TG通知开关，默认关闭
-	TgUserID         string `gorm:"type:varchar(255);default:''" json:"tg_user_id"`    // TG用户ID，默认为空
-	IsIPRangeEnabled bool   `gorm:"not null;default:false" json:"is_ip_range_enabled"` // IP段限制开关，默认关闭
-	IPRange          string `gorm:"type:varchar(255);default:''" json:"ip_range"`      // 香港IP段，默认为空
-	JpIPRange        string `gorm:"type:varchar(255);default:''" json:"jp_ip_range"`   // 日本IP段，默认为空
-	SgIPRange        string `gorm:"type:varchar(255);default:''" json:"sg_ip_range"`   // 新加坡IP段，默认为空
+	ID                 uint   `gorm:"primaryKey;autoIncrement" json:"id"`                // 让数据库自动递增
+	UserID             string `gorm:"type:varchar(255);not null" json:"user_id"`         // 用户ID
+	Threshold          int    `gorm:"not null;default:0" json:"threshold"`               // 香港区阈值，默认为0
+	JpThreshold        int    `gorm:"not null;default:0" json:"jp_threshold"`            // 日本区阈值，默认为0
+	SgThreshold        int    `gorm:"not null;default:0" json:"sg_threshold"`            // 新加坡区阈值，默认为0
+	IsEnabled          bool   `gorm:"not null;default:false" json:"is_enabled"`          // 监控开关，默认关闭
+	IsHkEnabled        bool   `gorm:"not null;default:true" json:"is_hk_enabled"`        // 香港区补机开关，关闭后保留阈值但暂停检测
+	IsJpEnabled        bool   `gorm:"not null;default:true" json:"is_jp_enabled"`        // 日本区补机开关
+	IsSgEnabled        bool   `gorm:"not null;default:true" json:"is_sg_enabled"`        // 新加坡区补机开关
+	IsTgEnabled        bool   `gorm:"not null;default:false" json:"is_tg_enabled"`       // TG通知开关，默认关闭
+	TgUserID           string `gorm:"type:varchar(255);default:''" json:"tg_user_id"`    // TG用户ID，默认为空
+	IsIPRangeEnabled   bool   `gorm:"not null;default:false" json:"is_ip_range_enabled"` // IP段限制开关，默认关闭
+	IPRange            string `gorm:"type:varchar(255);default:''" json:"ip_range"`      // 香港IP段，默认为空
+	JpIPRange          string `gorm:"type:varchar(255);default:''" json:"jp_ip_range"`   // 日本IP段，默认为空
+	SgIPRange          string `gorm:"type:varchar(255);default:''" json:"sg_ip_range"`   // 新加坡IP段，默认为空
+	OfflineNotifyDelay int    `gorm:"not null;default:0" json:"offline_notify_delay"`    // 离线多少秒后才发送通知，期间恢复则不通知，0表示立即通知
+	QuietHours         string `gorm:"type:varchar(32);default:''" json:"quiet_hours"`    // 免打扰时段，格式如"23-7"，为空表示不启用
+	RegionThresholds   string `gorm:"type:text" json:"region_thresholds"`                // 非内置区域的阈值，JSON对象，如{"us-west-2":3}
 }
 
 // TableName 指定表名
@@ -29,6 +43,98 @@ func (Monitor) TableName() string {
 	return "monitor"
 }
 
+// IPRangeForRegion 获取指定区域的IP段要求，为空表示该区域不限制，非内置区域不限制
+func (m *Monitor) IPRangeForRegion(regionCode string) string {
+	switch regionCode {
+	case region.JP:
+		return m.JpIPRange
+	case region.SG:
+		return m.SgIPRange
+	case region.HK, "":
+		return m.IPRange
+	default:
+		return ""
+	}
+}
+
+// IsRegionEnabled 判断指定区域是否开启补机检测，需同时打开总开关和区域开关
+func (m *Monitor) IsRegionEnabled(regionCode string) bool {
+	if !m.IsEnabled {
+		return false
+	}
+	switch regionCode {
+	case region.JP:
+		return m.IsJpEnabled
+	case region.SG:
+		return m.IsSgEnabled
+	case region.HK, "":
+		return m.IsHkEnabled
+	default:
+		// 非内置区域没有单独的开关，由阈值控制
+		return true
+	}
+}
+
+// RegionThresholdMap 解析非内置区域的阈值，格式错误时视为未设置
+func (m *Monitor) RegionThresholdMap() map[string]int {
+	thresholds := make(map[string]int)
+	if strings.TrimSpace(m.RegionThresholds) == "" {
+		return thresholds
+	}
+	if err := json.Unmarshal([]byte(m.RegionThresholds), &thresholds); err != nil {
+		log.Printf("用户[%s]的区域阈值格式错误: %v", m.UserID, err)
+		return make(map[string]int)
+	}
+	return thresholds
+}
+
+// ValidateRegionThresholds 校验非内置区域的阈值：区域已配置、不为负数且不超过区域上限
+func ValidateRegionThresholds(thresholds map[string]int) error {
+	for code, threshold := range thresholds {
+		switch {
+		case region.IsBuiltin(code):
+			return fmt.Errorf("区域[%s]请使用对应的阈值字段设置", code)
+		case !region.IsSupported(code):
+			return fmt.Errorf("不支持的区域: %s", code)
+		case threshold < 0:
+			return fmt.Errorf("区域[%s]的阈值不能为负数", code)
+		case threshold > GetThresholdCeiling(code):
+			return fmt.Errorf("区域[%s]的阈值不能超过%d", code, GetThresholdCeiling(code))
+		}
+	}
+	return nil
+}
+
+// UpdateRegionThresholds 更新非内置区域的阈值，阈值为0时删除该区域，未传的区域保持原值
+func UpdateRegionThresholds(db *gorm.DB, userID string, thresholds map[string]int) error {
+	if len(thresholds) == 0 {
+		return nil
+	}
+	if err := ValidateRegionThresholds(thresholds); err != nil {
+		return err
+	}
+
+	var config Monitor
+	if err := db.Where("user_id = ?", userID).First(&config).Error; err != nil {
+		return err
+	}
+
+	merged := config.RegionThresholdMap()
+	for code, threshold := range thresholds {
+		if threshold == 0 {
+			delete(merged, code)
+		} else {
+			merged[code] = threshold
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	return db.Model(&Monitor{}).Where("user_id = ?", userID).Update("region_thresholds", string(data)).Error
+}
+
 // GetMonitorByUserID 获取用户的监控配置
 func GetMonitorByUserID(db *gorm.DB, userID string) (*Monitor, error) {
 	var config Monitor
@@ -114,13 +220,192 @@ func GetIPRangeSettingsByRegion(db *gorm.DB, userID string, region string) (bool
 		if ipRange == "" {
 			ipRange = config.IPRange
 		}
-	default: // 默认香港区域
+	case "ap-east-1", "": // 香港区域
 		ipRange = config.IPRange
 	}
+	// 非内置区域没有IP段设置，不限制
 
 	return config.IsIPRangeEnabled, ipRange, nil
 }
 
+// 离线通知延迟的最大值（秒）
+const MaxOfflineNotifyDelay = 86400
+
+// ParseQuietHours 解析免打扰时段，格式为"开始小时-结束小时"，如"23-7"表示23点到次日7点
+func ParseQuietHours(quietHours string) (int, int, error) {
+	parts := strings.Split(strings.TrimSpace(quietHours), "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("免打扰时段格式错误，应为\"开始小时-结束小时\"")
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || start < 0 || start > 23 {
+		return 0, 0, fmt.Errorf("免打扰开始小时无效: %s", parts[0])
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || end < 0 || end > 23 {
+		return 0, 0, fmt.Errorf("免打扰结束小时无效: %s", parts[1])
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("免打扰开始和结束小时不能相同")
+	}
+	return start, end, nil
+}
+
+// InQuietHours 判断指定时间是否处于免打扰时段（按服务器本地时间），未配置或配置无效时返回false
+func InQuietHours(quietHours string, t time.Time) bool {
+	if strings.TrimSpace(quietHours) == "" {
+		return false
+	}
+	start, end, err := ParseQuietHours(quietHours)
+	if err != nil {
+		return false
+	}
+	hour := t.Hour()
+	if start < end {
+		return hour >= start && hour < end
+	}
+	// 跨越午夜的时段
+	return hour >= start || hour < end
+}
+
+// GetNotifySuppression 获取用户的离线通知延迟和免打扰时段
+func GetNotifySuppression(db *gorm.DB, userID string) (time.Duration, string, error) {
+	var config Monitor
+	result := db.Where("user_id = ?", userID).First(&config)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			// 如果没有找到记录，返回默认值
+			return 0, "", nil
+		}
+		return 0, "", result.Error
+	}
+	return time.Duration(config.OfflineNotifyDelay) * time.Second, config.QuietHours, nil
+}
+
+// ValidateNotifySuppression 校验离线通知延迟和免打扰时段
+func ValidateNotifySuppression(offlineNotifyDelay *int, quietHours *string) error {
+	if offlineNotifyDelay != nil && (*offlineNotifyDelay < 0 || *offlineNotifyDelay > MaxOfflineNotifyDelay) {
+		return fmt.Errorf("离线通知延迟必须在0到%d秒之间", MaxOfflineNotifyDelay)
+	}
+	if quietHours != nil && strings.TrimSpace(*quietHours) != "" {
+		if _, _, err := ParseQuietHours(*quietHours); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateRegionEnabled 更新各区域的补机开关，参数为nil时保持原值
+func UpdateRegionEnabled(db *gorm.DB, userID string, hkEnabled, jpEnabled, sgEnabled *bool) error {
+	updates := make(map[string]interface{})
+	if hkEnabled != nil {
+		updates["is_hk_enabled"] = *hkEnabled
+	}
+	if jpEnabled != nil {
+		updates["is_jp_enabled"] = *jpEnabled
+	}
+	if sgEnabled != nil {
+		updates["is_sg_enabled"] = *sgEnabled
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return db.Model(&Monitor{}).Where("user_id = ?", userID).Updates(updates).Error
+}
+
+// UpdateNotifySuppression 更新用户的离线通知延迟和免打扰时段，nil表示保持原值
+func UpdateNotifySuppression(db *gorm.DB, userID string, offlineNotifyDelay *int, quietHours *string) error {
+	updates := make(map[string]interface{})
+	if offlineNotifyDelay != nil {
+		updates["offline_notify_delay"] = *offlineNotifyDelay
+	}
+	if quietHours != nil {
+		updates["quiet_hours"] = strings.TrimSpace(*quietHours)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return db.Model(&Monitor{}).Where("user_id = ?", userID).Updates(updates).Error
+}
+
+// 默认的阈值上限，防止误填过大的阈值触发大量补机
+const defaultMaxThreshold = 100
+
+var (
+	thresholdCeilings     map[string]int
+	thresholdCeilingsOnce sync.Once
+)
+
+// GetThresholdCeiling 获取指定区域允许设置的最大阈值
+// 可通过 MAX_THRESHOLD 统一配置，MAX_THRESHOLD_HK/JP/SG 按区域覆盖，
+// 其他区域使用大写并将"-"替换为"_"的区域代码，如 MAX_THRESHOLD_US_WEST_2
+func GetThresholdCeiling(regionCode string) int {
+	thresholdCeilingsOnce.Do(func() {
+		base := defaultMaxThreshold
+		if value := os.Getenv("MAX_THRESHOLD"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				base = n
+			} else {
+				log.Printf("MAX_THRESHOLD配置无效: %s，使用默认值%d", value, defaultMaxThreshold)
+			}
+		}
+
+		thresholdCeilings = make(map[string]int)
+		for _, code := range region.Codes() {
+			thresholdCeilings[code] = base
+			envKey := region.EnvKey("MAX_THRESHOLD_", code)
+			if value := os.Getenv(envKey); value != "" {
+				if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+					thresholdCeilings[code] = n
+				} else {
+					log.Printf("%s配置无效: %s，使用%d", envKey, value, base)
+				}
+			}
+		}
+	})
+
+	if ceiling, ok := thresholdCeilings[regionCode]; ok {
+		return ceiling
+	}
+	return thresholdCeilings[region.HK]
+}
+
+// NormalizeTgUserID 校验并规范化TG用户ID，必须为int64数字，空字符串表示不绑定
+func NormalizeTgUserID(tgUserID string) (string, error) {
+	tgUserID = strings.TrimSpace(tgUserID)
+	if tgUserID == "" {
+		return "", nil
+	}
+	id, err := strconv.ParseInt(tgUserID, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("TG用户ID格式不正确，必须为数字: %s", tgUserID)
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// ValidateThresholds 校验各区域阈值不为负数且不超过区域上限
+func ValidateThresholds(threshold, jpThreshold, sgThreshold int) error {
+	values := []struct {
+		code  string
+		name  string
+		value int
+	}{
+		{region.HK, "香港区", threshold},
+		{region.JP, "日本区", jpThreshold},
+		{region.SG, "新加坡区", sgThreshold},
+	}
+
+	for _, item := range values {
+		if item.value < 0 {
+			return fmt.Errorf("%s阈值不能为负数", item.name)
+		}
+		if ceiling := GetThresholdCeiling(item.code); item.value > ceiling {
+			return fmt.Errorf("%s阈值%d超过上限%d", item.name, item.value, ceiling)
+		}
+	}
+	return nil
+}
+
 // GetAllMonitors 获取所有用户的监控配置
 func GetAllMonitors(db *gorm.DB) ([]Monitor, error) {
 	var configs []Monitor
@@ -404,6 +689,124 @@ func RestoreMonitorSettings(db *gorm.DB) error {
 	return nil
 }
 
+// MonitorExportVersion 监控配置导出文件的格式版本
+const MonitorExportVersion = 1
+
+// MonitorExport 监控配置导出文件结构，可纳入版本管理或迁移到其他环境
+type MonitorExport struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Monitors   []Monitor `json:"monitors"`
+}
+
+// MonitorImportResult 监控配置导入结果
+type MonitorImportResult struct {
+	Created int      `json:"created"`           // 新建的配置数
+	Updated int      `json:"updated"`           // 覆盖更新的配置数
+	Skipped []string `json:"skipped,omitempty"` // 用户不存在而跳过的用户ID
+}
+
+// ExportMonitorSettings 导出所有用户的监控配置
+func ExportMonitorSettings(db *gorm.DB) (*MonitorExport, error) {
+	monitors, err := GetAllMonitors(db)
+	if err != nil {
+		return nil, err
+	}
+	return &MonitorExport{
+		Version:    MonitorExportVersion,
+		ExportedAt: time.Now(),
+		Monitors:   monitors,
+	}, nil
+}
+
+// ValidateMonitorImport 校验导入文件中的监控配置，任一条不合法则整体拒绝
+func ValidateMonitorImport(export *MonitorExport) error {
+	if export.Version != MonitorExportVersion {
+		return fmt.Errorf("不支持的导出文件版本: %d", export.Version)
+	}
+
+	seen := make(map[string]bool, len(export.Monitors))
+	for i, m := range export.Monitors {
+		if strings.TrimSpace(m.UserID) == "" {
+			return fmt.Errorf("第%d条配置缺少user_id", i+1)
+		}
+		if seen[m.UserID] {
+			return fmt.Errorf("用户[%s]的配置重复", m.UserID)
+		}
+		seen[m.UserID] = true
+
+		if err := ValidateThresholds(m.Threshold, m.JpThreshold, m.SgThreshold); err != nil {
+			return fmt.Errorf("用户[%s]: %v", m.UserID, err)
+		}
+		if err := ValidateNotifySuppression(&m.OfflineNotifyDelay, &m.QuietHours); err != nil {
+			return fmt.Errorf("用户[%s]: %v", m.UserID, err)
+		}
+		if _, err := NormalizeTgUserID(m.TgUserID); err != nil {
+			return fmt.Errorf("用户[%s]: %v", m.UserID, err)
+		}
+		for _, ipRange := range []string{m.IPRange, m.JpIPRange, m.SgIPRange} {
+			if len(ipRange) > 255 {
+				return fmt.Errorf("用户[%s]: IP段长度超过255", m.UserID)
+			}
+		}
+		if strings.TrimSpace(m.RegionThresholds) != "" {
+			var thresholds map[string]int
+			if err := json.Unmarshal([]byte(m.RegionThresholds), &thresholds); err != nil {
+				return fmt.Errorf("用户[%s]: 区域阈值格式错误: %v", m.UserID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ImportMonitorSettings 按用户合并导入监控配置，已有配置整体覆盖，不存在的用户跳过
+func ImportMonitorSettings(db *gorm.DB, export *MonitorExport) (*MonitorImportResult, error) {
+	if err := ValidateMonitorImport(export); err != nil {
+		return nil, err
+	}
+
+	result := &MonitorImportResult{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, m := range export.Monitors {
+			var userCount int64
+			if err := tx.Model(&User{}).Where("id = ?", m.UserID).Count(&userCount).Error; err != nil {
+				return err
+			}
+			if userCount == 0 {
+				result.Skipped = append(result.Skipped, m.UserID)
+				continue
+			}
+
+			var existing Monitor
+			err := tx.Where("user_id = ?", m.UserID).First(&existing).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// 不沿用导出环境的自增ID
+				m.ID = 0
+				if err := tx.Create(&m).Error; err != nil {
+					return fmt.Errorf("创建用户[%s]的配置失败: %w", m.UserID, err)
+				}
+				result.Created++
+				continue
+			}
+
+			m.ID = existing.ID
+			if err := tx.Save(&m).Error; err != nil {
+				return fmt.Errorf("更新用户[%s]的配置失败: %w", m.UserID, err)
+			}
+			result.Updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // GetThresholdByRegion 根据区域获取对应的阈值
 func GetThresholdByRegion(config *Monitor, region string) int {
 	switch region {
@@ -411,7 +814,9 @@ func GetThresholdByRegion(config *Monitor, region string) int {
 		return config.JpThreshold
 	case "ap-southeast-1": // 新加坡区域
 		return config.SgThreshold
-	default: // 默认香港区域或其他
+	case "ap-east-1", "": // 香港区域
 		return config.Threshold
+	default: // 其他区域
+		return config.RegionThresholdMap()[region]
 	}
 }
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"portal/pkg/testdb"
+)
+
+func TestMonitorExportImportRoundTrip(t *testing.T) {
+	src := testdb.Open(t, Models()...)
+	seedUsers(t, src, 3)
+	monitors := []Monitor{
+		{UserID: "1", Threshold: 5, JpThreshold: 2, IsEnabled: true, IsHkEnabled: true, IsJpEnabled: true, IsSgEnabled: true,
+			IsTgEnabled: true, TgUserID: "10001", IsIPRangeEnabled: true, IPRange: "16.162.0.0/16", OfflineNotifyDelay: 60, QuietHours: "23-7"},
+		{UserID: "2", SgThreshold: 3, IsEnabled: true, IsHkEnabled: true, IsJpEnabled: true, IsSgEnabled: true,
+			RegionThresholds: `{"us-west-2":3}`},
+		{UserID: "3", Threshold: 1, IsHkEnabled: true, IsJpEnabled: true, IsSgEnabled: true},
+	}
+	for i := range monitors {
+		if err := src.Create(&monitors[i]).Error; err != nil {
+			t.Fatalf("写入监控配置失败: %v", err)
+		}
+	}
+
+	exported, err := ExportMonitorSettings(src)
+	if err != nil {
+		t.Fatalf("导出监控配置失败: %v", err)
+	}
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatalf("序列化导出文件失败: %v", err)
+	}
+
+	// 目标环境没有用户3，用户1已有旧配置
+	dst := testdb.Open(t, Models()...)
+	seedUsers(t, dst, 2)
+	if err := dst.Create(&Monitor{UserID: "1", Threshold: 9, IsHkEnabled: true, IsJpEnabled: true, IsSgEnabled: true}).Error; err != nil {
+		t.Fatalf("写入旧配置失败: %v", err)
+	}
+
+	var imported MonitorExport
+	if err := json.Unmarshal(data, &imported); err != nil {
+		t.Fatalf("解析导出文件失败: %v", err)
+	}
+	result, err := ImportMonitorSettings(dst, &imported)
+	if err != nil {
+		t.Fatalf("导入监控配置失败: %v", err)
+	}
+	if result.Created != 1 || result.Updated != 1 || !reflect.DeepEqual(result.Skipped, []string{"3"}) {
+		t.Fatalf("导入结果 = %+v, 期望新建1条、覆盖1条、跳过用户3", result)
+	}
+
+	got, err := GetAllMonitors(dst)
+	if err != nil {
+		t.Fatalf("读取导入后的配置失败: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("导入后配置数 = %d, want 2", len(got))
+	}
+	for _, m := range got {
+		want := monitors[0]
+		if m.UserID == "2" {
+			want = monitors[1]
+		}
+		// 自增ID由目标环境分配，不参与比较
+		m.ID, want.ID = 0, 0
+		if m != want {
+			t.Errorf("用户%s导入后的配置 = %+v, want %+v", m.UserID, m, want)
+		}
+	}
+}
+
+func TestImportMonitorSettingsRejectsInvalid(t *testing.T) {
+	db := testdb.Open(t, Models()...)
+	seedUsers(t, db, 1)
+
+	tests := map[string]*MonitorExport{
+		"版本不支持":  {Version: MonitorExportVersion + 1},
+		"缺少用户ID": {Version: MonitorExportVersion, Monitors: []Monitor{{}}},
+		"用户重复":   {Version: MonitorExportVersion, Monitors: []Monitor{{UserID: "1"}, {UserID: "1"}}},
+		"阈值为负数":  {Version: MonitorExportVersion, Monitors: []Monitor{{UserID: "1", Threshold: -1}}},
+	}
+	for name, export := range tests {
+		if _, err := ImportMonitorSettings(db, export); err == nil {
+			t.Errorf("%s: 期望导入被拒绝", name)
+		}
+	}
+	if monitors, _ := GetAllMonitors(db); len(monitors) != 0 {
+		t.Fatalf("校验失败时不应写入配置, 实际 %d 条", len(monitors))
+	}
+}
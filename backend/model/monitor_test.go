@@ -0,0 +1,98 @@
+package model
+
+import (
+	"sync"
+	"testing"
+)
+
+// resetThresholdCeilings 清空已加载的阈值上限，使新的环境变量生效
+func resetThresholdCeilings(t *testing.T) {
+	t.Helper()
+	thresholdCeilingsOnce = sync.Once{}
+	t.Cleanup(func() { thresholdCeilingsOnce = sync.Once{} })
+}
+
+func TestValidateThresholdsCeiling(t *testing.T) {
+	t.Setenv("MAX_THRESHOLD", "50")
+	t.Setenv("MAX_THRESHOLD_JP", "10")
+	resetThresholdCeilings(t)
+
+	tests := []struct {
+		name       string
+		hk, jp, sg int
+		wantErr    bool
+	}{
+		{"均在上限内", 50, 10, 50, false},
+		{"全为0", 0, 0, 0, false},
+		{"香港区超过统一上限", 51, 0, 0, true},
+		{"日本区超过区域上限", 0, 11, 0, true},
+		{"新加坡区使用统一上限", 0, 0, 51, true},
+		{"负数", -1, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateThresholds(tt.hk, tt.jp, tt.sg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateThresholds(%d, %d, %d) 错误 = %v, 期望出错 %v", tt.hk, tt.jp, tt.sg, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRegionThresholdsExtraRegion(t *testing.T) {
+	t.Setenv("MAX_THRESHOLD_US_WEST_2", "5")
+	resetThresholdCeilings(t)
+
+	tests := []struct {
+		name       string
+		thresholds map[string]int
+		wantErr    bool
+	}{
+		{"额外区域在上限内", map[string]int{"us-west-2": 5}, false},
+		{"额外区域超过区域上限", map[string]int{"us-west-2": 6}, true},
+		{"额外区域为负数", map[string]int{"us-west-2": -1}, true},
+		{"未登记的区域", map[string]int{"eu-west-1": 1}, true},
+		{"内置区域需使用单独字段", map[string]int{"ap-east-1": 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRegionThresholds(tt.thresholds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateRegionThresholds(%v) 错误 = %v, 期望出错 %v", tt.thresholds, err, tt.wantErr)
+			}
+		})
+	}
+
+	// 额外区域的阈值从 RegionThresholds 读取
+	monitor := &Monitor{RegionThresholds: `{"us-west-2":3}`}
+	if got := GetThresholdByRegion(monitor, "us-west-2"); got != 3 {
+		t.Errorf("额外区域阈值 = %d, 期望3", got)
+	}
+}
+
+func TestNormalizeTgUserID(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"数字ID", "123456789", "123456789", false},
+		{"去除空格和前导零", " 00123 ", "123", false},
+		{"群组ID为负数", "-1001234567890", "-1001234567890", false},
+		{"空字符串表示不绑定", "", "", false},
+		{"用户名", "@someone", "", true},
+		{"混合字符", "12ab", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeTgUserID(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeTgUserID(%q) 错误 = %v, 期望出错 %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("NormalizeTgUserID(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,33 +2,104 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"portal/pkg/region"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 
 	"gorm.io/gorm"
 )
 
 // Setting 系统设置模型
 type Setting struct {
-	UserID       string `gorm:"primarykey;type:varchar(255)" json:"user_id"`                         // 用户ID作为主键
-	Region       string `gorm:"type:varchar(255);not null;default:'香港'" json:"region"`               // 开机区域
-	InstanceType string `gorm:"type:varchar(255);not null;default:'c5n.large'" json:"instance_type"` // 实例规格
-	DiskSize     int    `gorm:"type:int;not null;default:20" json:"disk_size"`                       // 硬盘大小
-	Password     string `gorm:"type:varchar(255);not null;default:'Aa33669900@@'" json:"password"`   // 开机密码
-	Script       string `gorm:"type:text" json:"script"`                                             // 开机脚本
-	JpScript     string `gorm:"type:text" json:"jp_script"`                                          // 日本区域开机脚本
-	SgScript     string `gorm:"type:text" json:"sg_script"`                                          // 新加坡区域开机脚本
+	UserID            string `gorm:"primarykey;type:varchar(255)" json:"user_id"`                         // 用户ID作为主键
+	Region            string `gorm:"type:varchar(255);not null;default:'香港'" json:"region"`               // 开机区域
+	InstanceType      string `gorm:"type:varchar(255);not null;default:'c5n.large'" json:"instance_type"` // 实例规格
+	DiskSize          int    `gorm:"type:int;not null;default:20" json:"disk_size"`                       // 硬盘大小
+	Password          string `gorm:"type:varchar(255);not null;default:'Aa33669900@@'" json:"password"`   // 开机密码
+	RegionPasswords   string `gorm:"type:text" json:"region_passwords"`                                   // 区域开机密码，JSON对象，如{"ap-northeast-3":"xxx"}，未设置的区域使用默认密码
+	Script            string `gorm:"type:text" json:"script"`                                             // 开机脚本
+	JpScript          string `gorm:"type:text" json:"jp_script"`                                          // 日本区域开机脚本
+	SgScript          string `gorm:"type:text" json:"sg_script"`                                          // 新加坡区域开机脚本
+	SkipSSHPassword   bool   `gorm:"not null;default:false" json:"skip_ssh_password"`                     // 跳过开机脚本中的SSH密码登录配置
+	AllowedRegions    string `gorm:"type:varchar(255);not null;default:''" json:"allowed_regions"`        // 允许开机的区域代码，逗号分隔，为空表示不限制
+	AdditionalVolumes string `gorm:"type:text" json:"additional_volumes"`                                 // 附加数据盘配置，JSON数组，为空表示只有根盘
 }
 
 // UpdateSettingRequest 更新设置请求结构体
 type UpdateSettingRequest struct {
-	Region       string `json:"region"`
-	InstanceType string `json:"instance_type"`
-	DiskSize     int    `json:"disk_size"`
-	Password     string `json:"password"`
-	Script       string `json:"script"`
-	JpScript     string `json:"jp_script"` // 日本区域开机脚本
-	SgScript     string `json:"sg_script"` // 新加坡区域开机脚本
+	Region            string  `json:"region"`
+	InstanceType      string  `json:"instance_type"`
+	DiskSize          int     `json:"disk_size"`
+	Password          string  `json:"password"`
+	Script            string  `json:"script"`
+	JpScript          string  `json:"jp_script"`          // 日本区域开机脚本
+	SgScript          string  `json:"sg_script"`          // 新加坡区域开机脚本
+	SkipSSHPassword   *bool   `json:"skip_ssh_password"`  // 是否跳过SSH密码配置，不传则保持原值
+	AllowedRegions    *string `json:"-"`                  // 允许开机的区域，仅管理员接口可设置，不传则保持原值
+	AdditionalVolumes *string `json:"additional_volumes"` // 附加数据盘配置，JSON数组，不传则保持原值
+
+	RegionPasswords map[string]string `json:"region_passwords"` // 区域开机密码，如{"ap-northeast-3":"xxx"}，空字符串表示删除该区域的密码，未传的区域保持原值
+}
+
+// ErrRegionNotAllowed 用户不允许在该区域开机
+var ErrRegionNotAllowed = errors.New("当前用户不允许在该区域开机")
+
+// IsRegionAllowed 判断区域是否在用户允许开机的区域内，未配置时不限制
+func (s *Setting) IsRegionAllowed(regionCode string) bool {
+	if strings.TrimSpace(s.AllowedRegions) == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(s.AllowedRegions, ",") {
+		if region.Normalize(strings.TrimSpace(allowed)) == regionCode {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRegionAllowed 检查用户是否允许在该区域开机，不允许时返回包装了 ErrRegionNotAllowed 的错误
+func (s *Setting) CheckRegionAllowed(regionCode string) error {
+	if !s.IsRegionAllowed(regionCode) {
+		return fmt.Errorf("%w: %s（允许的区域: %s）", ErrRegionNotAllowed, regionCode, s.AllowedRegions)
+	}
+	return nil
+}
+
+// IsRegionAllowedForUser 判断用户是否允许在该区域开机，获取设置失败时不限制
+func IsRegionAllowedForUser(db *gorm.DB, userID string, regionCode string) bool {
+	setting, err := GetSettingByUserID(db, userID)
+	if err != nil {
+		return true
+	}
+	return setting.IsRegionAllowed(regionCode)
+}
+
+// NormalizeAllowedRegions 校验并规范化允许开机的区域列表，返回逗号分隔的区域代码
+func NormalizeAllowedRegions(input string) (string, error) {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(input, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		code := region.Normalize(item)
+		if !region.IsSupported(code) {
+			return "", fmt.Errorf("不支持的区域: %s", item)
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return strings.Join(codes, ","), nil
 }
 
 // TableName 指定表名
@@ -38,26 +109,180 @@ func (Setting) TableName() string {
 
 // GetRegionCode 获取区域对应的 AWS 区域代码
 func (s *Setting) GetRegionCode() string {
-	regionMap := map[string]string{
-		"香港":  "ap-east-1",
-		"日本":  "ap-northeast-3",
-		"新加坡": "ap-southeast-1",
+	return region.Normalize(s.Region) // 如果没有映射关系，返回原始值
+}
+
+var (
+	defaultScripts     map[string]string
+	defaultScriptsOnce sync.Once
+)
+
+// getDefaultScript 获取部署级别的区域默认脚本，通过 DEFAULT_SCRIPT_HK/JP/SG 配置，
+// 其他区域使用大写并将"-"替换为"_"的区域代码，如 DEFAULT_SCRIPT_US_WEST_2
+func getDefaultScript(regionCode string) string {
+	defaultScriptsOnce.Do(func() {
+		defaultScripts = make(map[string]string)
+		for _, code := range region.Codes() {
+			if value := os.Getenv(region.EnvKey("DEFAULT_SCRIPT_", code)); strings.TrimSpace(value) != "" {
+				defaultScripts[code] = value
+			}
+		}
+	})
+	return defaultScripts[regionCode]
+}
+
+// GetScriptForRegion 根据区域获取开机脚本，按以下顺序取第一个非空的脚本：
+//  1. 用户的区域脚本：日本区 JpScript、新加坡区 SgScript，香港区没有单独字段，使用 Script
+//  2. 部署级别的区域默认脚本，如 DEFAULT_SCRIPT_JP
+//  3. 用户的通用脚本 Script
+//
+// 开机和补机都使用该函数，保证同一区域得到相同的脚本
+func (s *Setting) GetScriptForRegion(regionCode string) string {
+	var regionScript string
+	switch regionCode {
+	case region.JP:
+		regionScript = s.JpScript
+	case region.SG:
+		regionScript = s.SgScript
+	case region.HK:
+		regionScript = s.Script
+	}
+	if regionScript != "" {
+		return regionScript
 	}
 
-	if code, exists := regionMap[s.Region]; exists {
-		return code
+	if script := getDefaultScript(regionCode); script != "" {
+		return script
 	}
-	return s.Region // 如果没有映射关系，返回原始值
+
+	return s.Script
 }
 
-// ValidatePassword 验证密码强度
+// RegionPasswordMap 解析区域开机密码，格式错误时视为未设置
+func (s *Setting) RegionPasswordMap() map[string]string {
+	passwords := make(map[string]string)
+	if strings.TrimSpace(s.RegionPasswords) == "" {
+		return passwords
+	}
+	if err := json.Unmarshal([]byte(s.RegionPasswords), &passwords); err != nil {
+		log.Printf("用户[%s]的区域密码格式错误: %v", s.UserID, err)
+		return make(map[string]string)
+	}
+	return passwords
+}
+
+// GetPasswordForRegion 根据区域获取开机密码，该区域设置了单独密码时使用单独密码，否则使用默认密码
+func (s *Setting) GetPasswordForRegion(regionCode string) string {
+	if password := s.RegionPasswordMap()[regionCode]; password != "" {
+		return password
+	}
+	return s.Password
+}
+
+// ValidateRegionPasswords 校验区域开机密码：区域已配置，密码不为空时需要满足强度要求
+func ValidateRegionPasswords(passwords map[string]string) error {
+	for code, password := range passwords {
+		if !region.IsSupported(code) {
+			return fmt.Errorf("不支持的区域: %s", code)
+		}
+		if password == "" {
+			continue
+		}
+		if err := validatePasswordStrength(password); err != nil {
+			return fmt.Errorf("%s%v", region.DisplayName(code), err)
+		}
+	}
+	return nil
+}
+
+// MergeRegionPasswords 将更新合并到已有的区域密码，空字符串删除该区域的密码，未传的区域保持原值
+func MergeRegionPasswords(current string, updates map[string]string) (string, error) {
+	if err := ValidateRegionPasswords(updates); err != nil {
+		return "", err
+	}
+
+	merged := (&Setting{RegionPasswords: current}).RegionPasswordMap()
+	for code, password := range updates {
+		if password == "" {
+			delete(merged, code)
+		} else {
+			merged[code] = password
+		}
+	}
+	if len(merged) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// 硬盘大小默认限制(GB)
+const (
+	defaultMinDiskSize = 8
+	defaultMaxDiskSize = 1000
+)
+
+var (
+	minDiskSize    int
+	maxDiskSize    int
+	diskLimitsOnce sync.Once
+)
+
+// GetDiskSizeLimits 获取硬盘大小的上下限，可通过 DISK_SIZE_MIN 和 DISK_SIZE_MAX 配置
+func GetDiskSizeLimits() (int, int) {
+	diskLimitsOnce.Do(func() {
+		minDiskSize = defaultMinDiskSize
+		maxDiskSize = defaultMaxDiskSize
+		if value := os.Getenv("DISK_SIZE_MIN"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				minDiskSize = n
+			} else {
+				log.Printf("DISK_SIZE_MIN配置无效: %s，使用默认值%d", value, defaultMinDiskSize)
+			}
+		}
+		if value := os.Getenv("DISK_SIZE_MAX"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n >= minDiskSize {
+				maxDiskSize = n
+			} else {
+				log.Printf("DISK_SIZE_MAX配置无效: %s，使用默认值%d", value, defaultMaxDiskSize)
+			}
+		}
+		if maxDiskSize < minDiskSize {
+			maxDiskSize = minDiskSize
+		}
+	})
+	return minDiskSize, maxDiskSize
+}
+
+// ValidateDiskSize 验证硬盘大小是否在允许范围内
+func ValidateDiskSize(size int) error {
+	minSize, maxSize := GetDiskSizeLimits()
+	if size < minSize || size > maxSize {
+		return fmt.Errorf("硬盘大小必须在%dGB到%dGB之间", minSize, maxSize)
+	}
+	return nil
+}
+
+// ValidatePassword 验证密码强度，区域密码为空表示使用默认密码，不为空时同样需要满足强度要求
 func (s *Setting) ValidatePassword() error {
-	if len(s.Password) < 6 {
+	if err := validatePasswordStrength(s.Password); err != nil {
+		return err
+	}
+	return ValidateRegionPasswords(s.RegionPasswordMap())
+}
+
+// validatePasswordStrength 验证单个密码的强度
+func validatePasswordStrength(password string) error {
+	if len(password) < 6 {
 		return errors.New("密码长度必须大于6位")
 	}
 
 	// 检查是否包含字母（不区分大小写）
-	hasLetter, _ := regexp.MatchString(`[a-zA-Z]`, s.Password)
+	hasLetter, _ := regexp.MatchString(`[a-zA-Z]`, password)
 	if !hasLetter {
 		return errors.New("密码必须包含至少一个字母")
 	}
@@ -84,13 +309,14 @@ func (s *Setting) UpdateSettings(db *gorm.DB) error {
 
 	// 使用 user_id 更新记录
 	result := db.Model(&Setting{}).Where("user_id = ?", s.UserID).Updates(map[string]interface{}{
-		"region":        s.Region,
-		"instance_type": s.InstanceType,
-		"disk_size":     s.DiskSize,
-		"password":      s.Password,
-		"script":        s.Script,
-		"jp_script":     s.JpScript,
-		"sg_script":     s.SgScript,
+		"region":           s.Region,
+		"instance_type":    s.InstanceType,
+		"disk_size":        s.DiskSize,
+		"password":         s.Password,
+		"script":           s.Script,
+		"jp_script":        s.JpScript,
+		"sg_script":        s.SgScript,
+		"region_passwords": s.RegionPasswords,
 	})
 
 	if result.Error != nil {
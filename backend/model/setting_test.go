@@ -0,0 +1,192 @@
+package model
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"portal/pkg/region"
+)
+
+// resetDiskSizeLimits 清空已加载的硬盘大小限制，使新的环境变量生效
+func resetDiskSizeLimits(t *testing.T) {
+	t.Helper()
+	diskLimitsOnce = sync.Once{}
+	t.Cleanup(func() { diskLimitsOnce = sync.Once{} })
+}
+
+func TestValidateDiskSize(t *testing.T) {
+	t.Setenv("DISK_SIZE_MIN", "")
+	t.Setenv("DISK_SIZE_MAX", "")
+	resetDiskSizeLimits(t)
+
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{"小于下限", defaultMinDiskSize - 1, true},
+		{"为0", 0, true},
+		{"等于下限", defaultMinDiskSize, false},
+		{"常用大小", 20, false},
+		{"等于上限", defaultMaxDiskSize, false},
+		{"大于上限", defaultMaxDiskSize + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDiskSize(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateDiskSize(%d) 错误 = %v, 期望出错 %v", tt.size, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsRegionAllowed(t *testing.T) {
+	tests := []struct {
+		allowed string
+		region  string
+		want    bool
+	}{
+		{"", region.HK, true},
+		{"ap-east-1", region.HK, true},
+		{"ap-northeast-3, 香港", region.HK, true},
+		{"ap-northeast-3", region.HK, false},
+		{"jp,sg", region.HK, false},
+		{"jp,sg", region.SG, true},
+	}
+	for _, tt := range tests {
+		s := &Setting{AllowedRegions: tt.allowed}
+		if got := s.IsRegionAllowed(tt.region); got != tt.want {
+			t.Errorf("允许区域[%s]判断[%s] = %v, want %v", tt.allowed, tt.region, got, tt.want)
+		}
+		if err := s.CheckRegionAllowed(tt.region); (err == nil) != tt.want || (err != nil && !errors.Is(err, ErrRegionNotAllowed)) {
+			t.Errorf("允许区域[%s]检查[%s]错误 = %v", tt.allowed, tt.region, err)
+		}
+	}
+}
+
+func TestNormalizeAllowedRegions(t *testing.T) {
+	got, err := NormalizeAllowedRegions(" 香港, ap-northeast-3,ap-east-1,")
+	if err != nil || got != "ap-east-1,ap-northeast-3" {
+		t.Fatalf("NormalizeAllowedRegions() = %q, %v", got, err)
+	}
+	if _, err := NormalizeAllowedRegions("ap-east-1,mars-1"); err == nil {
+		t.Fatal("不支持的区域应返回错误")
+	}
+}
+
+func TestValidateDiskSizeConfigured(t *testing.T) {
+	t.Setenv("DISK_SIZE_MIN", "30")
+	t.Setenv("DISK_SIZE_MAX", "100")
+	resetDiskSizeLimits(t)
+
+	if minSize, maxSize := GetDiskSizeLimits(); minSize != 30 || maxSize != 100 {
+		t.Fatalf("GetDiskSizeLimits() = %d, %d, want 30, 100", minSize, maxSize)
+	}
+	for size, wantErr := range map[int]bool{20: true, 30: false, 100: false, 101: true} {
+		if err := ValidateDiskSize(size); (err != nil) != wantErr {
+			t.Errorf("ValidateDiskSize(%d) 错误 = %v, 期望出错 %v", size, err, wantErr)
+		}
+	}
+}
+
+func TestGetScriptForRegionFallback(t *testing.T) {
+	t.Setenv("DEFAULT_SCRIPT_JP", "jp-default")
+	t.Setenv("DEFAULT_SCRIPT_SG", "")
+	t.Setenv("DEFAULT_SCRIPT_US_WEST_2", "usw2-default")
+	defaultScriptsOnce = sync.Once{}
+	t.Cleanup(func() { defaultScriptsOnce = sync.Once{} })
+
+	tests := []struct {
+		name    string
+		setting Setting
+		region  string
+		want    string
+	}{
+		{"优先使用用户的区域脚本", Setting{Script: "common", JpScript: "jp-user"}, region.JP, "jp-user"},
+		{"区域脚本为空时使用部署默认脚本", Setting{Script: "common"}, region.JP, "jp-default"},
+		{"没有默认脚本时使用通用脚本", Setting{Script: "common"}, region.SG, "common"},
+		{"香港区使用通用脚本", Setting{Script: "common", JpScript: "jp-user"}, region.HK, "common"},
+		{"全部为空", Setting{}, region.SG, ""},
+		{"非内置区域使用大写的环境变量名", Setting{Script: "common"}, "us-west-2", "usw2-default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.setting.GetScriptForRegion(tt.region); got != tt.want {
+				t.Fatalf("GetScriptForRegion(%s) = %q, want %q", tt.region, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPasswordForRegion(t *testing.T) {
+	s := Setting{Password: "common1", RegionPasswords: `{"ap-northeast-3":"jpPass1","ap-east-1":"hkPass1","us-west-2":"usPass1","ap-southeast-1":""}`}
+	tests := []struct {
+		region string
+		want   string
+	}{
+		{region.JP, "jpPass1"},
+		{region.SG, "common1"},
+		{region.HK, "hkPass1"},
+		{"us-west-2", "usPass1"},
+		{"eu-west-1", "common1"},
+	}
+	for _, tt := range tests {
+		if got := s.GetPasswordForRegion(tt.region); got != tt.want {
+			t.Errorf("GetPasswordForRegion(%s) = %q, want %q", tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRegionPasswords(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting Setting
+		wantErr string
+	}{
+		{"区域密码为空时使用默认密码", Setting{Password: "common1"}, ""},
+		{"区域密码满足强度", Setting{Password: "common1", RegionPasswords: `{"ap-northeast-3":"jpPass1","ap-east-1":"hkPass1"}`}, ""},
+		{"默认密码太短", Setting{Password: "a1", RegionPasswords: `{"ap-northeast-3":"jpPass1"}`}, "密码长度必须大于6位"},
+		{"日本区域密码不含字母", Setting{Password: "common1", RegionPasswords: `{"ap-northeast-3":"123456"}`}, "日本区密码必须包含至少一个字母"},
+		{"新加坡区域密码太短", Setting{Password: "common1", RegionPasswords: `{"ap-southeast-1":"sg1"}`}, "新加坡区密码长度必须大于6位"},
+		{"不支持的区域", Setting{Password: "common1", RegionPasswords: `{"mars-1":"marsPass1"}`}, "不支持的区域: mars-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.setting.ValidatePassword()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidatePassword() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("ValidatePassword() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeRegionPasswords(t *testing.T) {
+	merged, err := MergeRegionPasswords(`{"ap-northeast-3":"jpPass1","ap-southeast-1":"sgPass1"}`, map[string]string{
+		region.HK: "hkPass1",
+		region.SG: "",
+	})
+	if err != nil {
+		t.Fatalf("MergeRegionPasswords() 错误 = %v", err)
+	}
+	s := Setting{Password: "common1", RegionPasswords: merged}
+	for code, want := range map[string]string{region.HK: "hkPass1", region.JP: "jpPass1", region.SG: "common1"} {
+		if got := s.GetPasswordForRegion(code); got != want {
+			t.Errorf("合并后 GetPasswordForRegion(%s) = %q, want %q", code, got, want)
+		}
+	}
+
+	if _, err := MergeRegionPasswords(merged, map[string]string{region.JP: "123456"}); err == nil {
+		t.Fatal("强度不足的区域密码应返回错误")
+	}
+	if merged, err := MergeRegionPasswords(`{"ap-east-1":"hkPass1"}`, map[string]string{region.HK: ""}); err != nil || merged != "" {
+		t.Fatalf("删除全部区域密码后 = %q, %v, 期望空字符串", merged, err)
+	}
+}
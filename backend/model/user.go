@@ -248,6 +248,47 @@ func GetAllUsers(db *gorm.DB) ([]map[string]interface{}, error) {
 	return userInfos, nil
 }
 
+// GetUsersPaged 分页获取用户信息，支持按邮箱模糊搜索
+// pageSize 小于等于0时不分页，返回全部匹配的用户
+func GetUsersPaged(db *gorm.DB, page int, pageSize int, email string) ([]map[string]interface{}, int64, error) {
+	query := db.Model(&User{})
+	if email != "" {
+		query = query.Where("email LIKE ?", "%"+strings.ToLower(email)+"%")
+	}
+
+	// 统计匹配的总数
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 按ID数值排序，保证分页结果稳定
+	query = query.Order("CAST(id AS SIGNED)")
+	if pageSize > 0 {
+		if page < 1 {
+			page = 1
+		}
+		query = query.Offset((page - 1) * pageSize).Limit(pageSize)
+	}
+
+	var users []User
+	if err := query.Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 转换为简化的用户信息列表
+	userInfos := make([]map[string]interface{}, 0, len(users))
+	for _, user := range users {
+		userInfos = append(userInfos, map[string]interface{}{
+			"id":       user.ID,
+			"email":    user.Email,
+			"is_admin": user.IsAdmin,
+		})
+	}
+
+	return userInfos, total, nil
+}
+
 // UpdateUsers 更新用户信息（密码、邮箱、管理员状态）
 func UpdateUsers(db *gorm.DB, userUpdates map[string]interface{}) error {
 	// 获取用户IDs
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"portal/pkg/testdb"
+
+	"gorm.io/gorm"
+)
+
+// seedUsers 写入ID为1到n的用户，偶数ID的邮箱属于 example.org
+func seedUsers(t *testing.T, db *gorm.DB, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		domain := "example.com"
+		if i%2 == 0 {
+			domain = "example.org"
+		}
+		user := &User{ID: fmt.Sprint(i), Email: fmt.Sprintf("user%d@%s", i, domain), Password: "x"}
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(user).Error; err != nil {
+			t.Fatalf("写入用户失败: %v", err)
+		}
+	}
+}
+
+func TestGetUsersPaged(t *testing.T) {
+	db := testdb.Open(t, Models()...)
+	seedUsers(t, db, 12)
+
+	// 按ID数值排序分页，第2页为 ID 6-10
+	users, total, err := GetUsersPaged(db, 2, 5, "")
+	if err != nil {
+		t.Fatalf("分页查询失败: %v", err)
+	}
+	if total != 12 || len(users) != 5 || users[0]["id"] != "6" || users[4]["id"] != "10" {
+		t.Fatalf("第2页 = %v, 总数%d", users, total)
+	}
+
+	// 最后一页不足一页
+	if users, _, _ := GetUsersPaged(db, 3, 5, ""); len(users) != 2 {
+		t.Fatalf("第3页用户数 = %d, 期望2", len(users))
+	}
+
+	// 不分页时返回全部
+	if users, _, _ := GetUsersPaged(db, 1, 0, ""); len(users) != 12 {
+		t.Fatalf("不分页时用户数 = %d, 期望12", len(users))
+	}
+}
+
+func TestGetUsersPagedSearch(t *testing.T) {
+	db := testdb.Open(t, Models()...)
+	seedUsers(t, db, 12)
+
+	// 邮箱模糊搜索不区分大小写，总数为匹配的数量
+	users, total, err := GetUsersPaged(db, 1, 4, "Example.ORG")
+	if err != nil {
+		t.Fatalf("搜索失败: %v", err)
+	}
+	if total != 6 || len(users) != 4 {
+		t.Fatalf("搜索结果 = %v, 总数%d, 期望总数6且本页4个", users, total)
+	}
+	for _, user := range users {
+		if email := user["email"].(string); email[len(email)-len("example.org"):] != "example.org" {
+			t.Errorf("搜索结果包含不匹配的邮箱: %s", email)
+		}
+	}
+
+	if users, total, _ := GetUsersPaged(db, 1, 10, "user11@"); total != 1 || len(users) != 1 || users[0]["id"] != "11" {
+		t.Fatalf("精确搜索结果 = %v, 总数%d", users, total)
+	}
+}
@@ -4,13 +4,11 @@ package aws
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/account"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
 )
 
 // 区域常量
@@ -21,35 +19,9 @@ const (
 	RegionQuota = "us-east-1"      // 配额查询区域
 )
 
-// GetEC2Quota 查询账号在指定区域的EC2配额
+// GetEC2Quota 查询账号的标准实例EC2配额（不使用缓存，可用于验证凭证）
 func (c *AWSClient) GetEC2Quota(ctx context.Context) (string, error) {
-	// 创建美区配置用于查询配额
-	cfg, err := c.createConfig(ctx, RegionQuota)
-	if err != nil {
-		return "", fmt.Errorf("加载AWS配置失败: %v", err)
-	}
-
-	quotaClient := servicequotas.NewFromConfig(cfg)
-
-	input := &servicequotas.GetServiceQuotaInput{
-		ServiceCode: aws.String("ec2"),
-		QuotaCode:   aws.String("L-1216C47A"),
-	}
-
-	result, err := quotaClient.GetServiceQuota(ctx, input)
-	if err != nil {
-		if strings.Contains(err.Error(), "UnrecognizedClientException") ||
-			strings.Contains(err.Error(), "InvalidClientTokenId") {
-			return "账号已失效", nil
-		}
-		return "", fmt.Errorf("查询配额失败: %v", err)
-	}
-
-	if result.Quota == nil || result.Quota.Value == nil {
-		return "", fmt.Errorf("未找到配额信息")
-	}
-
-	return fmt.Sprintf("%d", int(*result.Quota.Value)), nil
+	return c.queryEC2Quota(ctx, DefaultQuotaCode)
 }
 
 // CheckRegionStatus 检查指定区域状态
@@ -72,7 +44,7 @@ func (c *AWSClient) CheckRegionStatus(ctx context.Context, regionCode string) (s
 	}
 
 	switch result.RegionOptStatus {
-	case "ENABLED":
+	case "ENABLED", "ENABLED_BY_DEFAULT":
 		return "启用", nil
 	case "ENABLING":
 		return "启用中", nil
@@ -0,0 +1,44 @@
+// pkg/aws/ami.go
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"portal/pkg/region"
+)
+
+// ErrImageNotFound 目标区域中不存在指定的AMI
+var ErrImageNotFound = errors.New("未找到AMI")
+
+var (
+	amiStrict     bool
+	amiStrictOnce sync.Once
+)
+
+// isAMIStrict 是否启用严格模式，可通过 AMI_STRICT_REGION=true 开启，开启后未知区域直接报错而不是回退到香港AMI
+func isAMIStrict() bool {
+	amiStrictOnce.Do(func() {
+		amiStrict = os.Getenv("AMI_STRICT_REGION") == "true"
+	})
+	return amiStrict
+}
+
+// ResolveAMI 根据区域配置获取对应的AMI ID
+// 未知区域或未配置AMI的区域默认回退到香港区域的AMI并记录警告，严格模式下返回错误
+func ResolveAMI(regionCode string) (string, error) {
+	if cfg, exists := region.Get(regionCode); exists && cfg.AMI != "" {
+		return cfg.AMI, nil
+	}
+
+	if isAMIStrict() {
+		return "", fmt.Errorf("区域[%s]未配置AMI", regionCode)
+	}
+
+	fallback, _ := region.Get(region.HK)
+	log.Printf("警告: 区域[%s]未配置AMI，回退使用香港区域AMI[%s]，可能无法在该区域启动", regionCode, fallback.AMI)
+	return fallback.AMI, nil
+}
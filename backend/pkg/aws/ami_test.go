@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"portal/pkg/region"
+)
+
+// setAMIStrict 设置严格模式，测试结束后恢复
+func setAMIStrict(t *testing.T, strict string) {
+	t.Helper()
+	t.Setenv("AMI_STRICT_REGION", strict)
+	amiStrictOnce = sync.Once{}
+	t.Cleanup(func() { amiStrictOnce = sync.Once{} })
+}
+
+func TestResolveAMIUnknownRegion(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	setAMIStrict(t, "false")
+	hk, _ := region.Get(region.HK)
+	if ami, err := ResolveAMI("xx-nowhere-1"); err != nil || ami != hk.AMI {
+		t.Fatalf("ResolveAMI(xx-nowhere-1) = %q, %v, 期望回退到香港区AMI", ami, err)
+	}
+	if !strings.Contains(buf.String(), "警告") || !strings.Contains(buf.String(), "xx-nowhere-1") {
+		t.Fatalf("回退到香港区AMI时应记录警告, 日志: %q", buf.String())
+	}
+
+	setAMIStrict(t, "true")
+	if _, err := ResolveAMI("xx-nowhere-1"); err == nil {
+		t.Fatal("严格模式下未知区域应返回错误")
+	}
+}
+
+func TestResolveAMIExtraRegion(t *testing.T) {
+	setAMIStrict(t, "true")
+
+	if ami, err := ResolveAMI("us-west-2"); err != nil || ami != "ami-usw2" {
+		t.Fatalf("ResolveAMI(us-west-2) = %q, %v, 期望 EXTRA_REGIONS 中配置的AMI", ami, err)
+	}
+	// 登记了区域但未配置AMI时，严格模式下开机失败
+	if _, err := ResolveAMI("eu-west-1"); err == nil {
+		t.Fatal("未配置AMI的区域在严格模式下应返回错误")
+	}
+
+	setAMIStrict(t, "false")
+	hk, _ := region.Get(region.HK)
+	if ami, err := ResolveAMI("eu-west-1"); err != nil || ami != hk.AMI {
+		t.Fatalf("非严格模式 ResolveAMI(eu-west-1) = %q, %v, 期望回退到香港区AMI", ami, err)
+	}
+}
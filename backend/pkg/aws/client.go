@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/smithy-go/middleware"
 )
 
 // AWSClient AWS客户端结构体
@@ -32,5 +33,6 @@ func (c *AWSClient) createConfig(ctx context.Context, region string) (aws.Config
 			c.SecretKey,
 			"",
 		)),
+		config.WithAPIOptions([]func(*middleware.Stack) error{c.rateLimitMiddleware}),
 	)
 }
@@ -0,0 +1,194 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// addressesXML 实例关联了一个弹性IP
+const addressesXML = `<addressesSet><item><publicIp>203.0.113.10</publicIp><allocationId>eipalloc-1</allocationId><associationId>eipassoc-1</associationId><instanceId>i-keep</instanceId></item></addressesSet>`
+
+func TestDeleteInstanceKeepEIP(t *testing.T) {
+	fake := newFakeEC2(t, func(action string, form url.Values) (string, error) {
+		if action == "DescribeAddresses" {
+			return addressesXML, nil
+		}
+		return "", nil
+	})
+
+	client := NewAWSClient("AKIAKEEPEIP", "secret")
+	result, err := client.DeleteInstance(context.Background(), DeleteInstanceParams{
+		Region:     "ap-east-1",
+		InstanceID: "i-keep",
+		KeepEIP:    true,
+	})
+	if err != nil {
+		t.Fatalf("删除实例失败: %v", err)
+	}
+
+	if fake.count("ReleaseAddress") != 0 {
+		t.Fatalf("保留弹性IP时不应释放, 调用: %v", fake.actions())
+	}
+	if fake.count("DisassociateAddress") != 1 || fake.count("TerminateInstances") != 1 {
+		t.Fatalf("应先解绑弹性IP再终止实例, 调用: %v", fake.actions())
+	}
+	if result.RetainedAllocationID != "eipalloc-1" || result.RetainedIP != "203.0.113.10" {
+		t.Fatalf("保留的弹性IP = %+v", result)
+	}
+	tags := fake.callsFor("CreateTags")
+	if len(tags) != 1 || tags[0].Get("ResourceId.1") != "eipalloc-1" ||
+		tags[0].Get("Tag.1.Key") != retainedAddressTag || tags[0].Get("Tag.1.Value") != "true" {
+		t.Fatalf("保留的弹性IP应打上保留标签, 调用: %v", tags)
+	}
+}
+
+func TestUnassociatedSweepSkipsRetainedEIP(t *testing.T) {
+	oldDelay := addressSettleDelay
+	addressSettleDelay = 0
+	t.Cleanup(func() { addressSettleDelay = oldDelay })
+
+	fake := newFakeEC2(t, func(action string, form url.Values) (string, error) {
+		if action == "DescribeAddresses" && form.Get("Filter.1.Name") == "association-id" {
+			return `<addressesSet>` +
+				`<item><publicIp>203.0.113.20</publicIp><allocationId>eipalloc-retained</allocationId>` +
+				`<tagSet><item><key>portal:retained</key><value>true</value></item></tagSet></item>` +
+				`<item><publicIp>203.0.113.21</publicIp><allocationId>eipalloc-stale</allocationId></item>` +
+				`</addressesSet>`, nil
+		}
+		return "", nil
+	})
+
+	client := NewAWSClient("AKIARETAINEDSWEEP", "secret")
+	if _, err := client.DeleteInstance(context.Background(), DeleteInstanceParams{Region: "ap-east-1", InstanceID: "i-other"}); err != nil {
+		t.Fatalf("删除实例失败: %v", err)
+	}
+	releases := fake.callsFor("ReleaseAddress")
+	if len(releases) != 1 || releases[0].Get("AllocationId") != "eipalloc-stale" {
+		t.Fatalf("清理未关联的弹性IP时应跳过保留的弹性IP, 调用: %v", releases)
+	}
+}
+
+func TestDeleteInstanceReleasesEIPByDefault(t *testing.T) {
+	fake := newFakeEC2(t, func(action string, form url.Values) (string, error) {
+		if action == "DescribeAddresses" && form.Get("Filter.1.Name") == "instance-id" {
+			return addressesXML, nil
+		}
+		return "", nil
+	})
+
+	client := NewAWSClient("AKIARELEASEEIP", "secret")
+	result, err := client.DeleteInstance(context.Background(), DeleteInstanceParams{
+		Region:     "ap-east-1",
+		InstanceID: "i-keep",
+	})
+	if err != nil {
+		t.Fatalf("删除实例失败: %v", err)
+	}
+	releases := fake.callsFor("ReleaseAddress")
+	if len(releases) != 1 || releases[0].Get("AllocationId") != "eipalloc-1" {
+		t.Fatalf("未保留时应释放弹性IP, 调用: %v", fake.actions())
+	}
+	if result.RetainedAllocationID != "" {
+		t.Fatalf("未保留时不应返回保留的弹性IP: %+v", result)
+	}
+}
+
+// 在 -race 下运行：同一账号并发删除多台实例，弹性IP的处理不交错，每个弹性IP只释放一次
+func TestConcurrentDeleteSameAccount(t *testing.T) {
+	oldDelay := addressSettleDelay
+	addressSettleDelay = 10 * time.Millisecond
+	t.Cleanup(func() { addressSettleDelay = oldDelay })
+
+	// 每台实例绑定一个弹性IP：eipalloc-N <-> i-N
+	const n = 4
+	var (
+		mu         sync.Mutex
+		associated = make(map[string]string) // 分配ID -> 实例ID，空表示未关联
+		released   = make(map[string]int)
+		active     int // 正在处理弹性IP的删除操作数量
+		overlapped bool
+	)
+	for i := 1; i <= n; i++ {
+		associated[fmt.Sprintf("eipalloc-%d", i)] = fmt.Sprintf("i-%d", i)
+	}
+	addressXML := func(allocationID, instanceID string) string {
+		return fmt.Sprintf(`<item><publicIp>203.0.113.%s</publicIp><allocationId>%s</allocationId><associationId>assoc-%s</associationId><instanceId>%s</instanceId></item>`,
+			strings.TrimPrefix(allocationID, "eipalloc-"), allocationID, allocationID, instanceID)
+	}
+
+	newFakeEC2(t, func(action string, form url.Values) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch action {
+		case "DescribeAddresses":
+			var items strings.Builder
+			switch form.Get("Filter.1.Name") {
+			case "instance-id":
+				// 一次删除操作开始处理弹性IP
+				active++
+				overlapped = overlapped || active > 1
+				for id, inst := range associated {
+					if inst == form.Get("Filter.1.Value.1") {
+						items.WriteString(addressXML(id, inst))
+					}
+				}
+			case "association-id":
+				// 清理未关联的弹性IP是删除操作的最后一步
+				defer func() { active-- }()
+				for id, inst := range associated {
+					if inst == "" {
+						items.WriteString(addressXML(id, ""))
+					}
+				}
+			}
+			return "<addressesSet>" + items.String() + "</addressesSet>", nil
+		case "DisassociateAddress":
+			id := strings.TrimPrefix(form.Get("AssociationId"), "assoc-")
+			if _, ok := associated[id]; ok {
+				associated[id] = ""
+			}
+			return "<return>true</return>", nil
+		case "ReleaseAddress":
+			id := form.Get("AllocationId")
+			released[id]++
+			if _, ok := associated[id]; !ok {
+				return "", &ec2Error{Code: "InvalidAllocationID.NotFound", Message: id}
+			}
+			delete(associated, id)
+			return "<return>true</return>", nil
+		}
+		return "", nil
+	})
+
+	client := NewAWSClient("AKIACONCURRENTDEL", "secret")
+	var wg sync.WaitGroup
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(instanceID string) {
+			defer wg.Done()
+			if _, err := client.DeleteInstance(context.Background(), DeleteInstanceParams{Region: "ap-east-1", InstanceID: instanceID}); err != nil {
+				t.Errorf("删除实例%s失败: %v", instanceID, err)
+			}
+		}(fmt.Sprintf("i-%d", i))
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if overlapped {
+		t.Fatal("同一账号的弹性IP处理发生了交错")
+	}
+	if len(associated) != 0 {
+		t.Fatalf("未释放的弹性IP: %v", associated)
+	}
+	for id, count := range released {
+		if count != 1 {
+			t.Errorf("弹性IP %s 被释放%d次, 期望1次", id, count)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// ec2Error 模拟EC2接口返回的错误
+type ec2Error struct {
+	Code    string
+	Message string
+}
+
+func (e *ec2Error) Error() string { return e.Code + ": " + e.Message }
+
+// fakeEC2 模拟EC2 Query接口，按Action返回响应内容并记录每次调用的参数
+type fakeEC2 struct {
+	mu     sync.Mutex
+	calls  []url.Values
+	handle func(action string, form url.Values) (string, error)
+}
+
+// newFakeEC2 启动模拟EC2服务并让SDK请求指向它，handle 返回响应元素内的XML
+func newFakeEC2(t *testing.T, handle func(action string, form url.Values) (string, error)) *fakeEC2 {
+	t.Helper()
+	f := &fakeEC2{handle: handle}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		action := r.PostForm.Get("Action")
+		f.mu.Lock()
+		f.calls = append(f.calls, r.PostForm)
+		f.mu.Unlock()
+
+		body, err := f.handle(action, r.PostForm)
+		w.Header().Set("Content-Type", "text/xml")
+		if err != nil {
+			code, message := "InternalError", err.Error()
+			if e, ok := err.(*ec2Error); ok {
+				code, message = e.Code, e.Message
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>test</RequestID></Response>`, code, message)
+			return
+		}
+		fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId>%s</%sResponse>`, action, body, action)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	return f
+}
+
+// actions 按调用顺序返回请求过的Action
+func (f *fakeEC2) actions() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	actions := make([]string, 0, len(f.calls))
+	for _, call := range f.calls {
+		actions = append(actions, call.Get("Action"))
+	}
+	return actions
+}
+
+// count 返回指定Action被调用的次数
+func (f *fakeEC2) count(action string) int {
+	n := 0
+	for _, a := range f.actions() {
+		if a == action {
+			n++
+		}
+	}
+	return n
+}
+
+// callsFor 返回指定Action每次调用的参数
+func (f *fakeEC2) callsFor(action string) []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []url.Values
+	for _, call := range f.calls {
+		if call.Get("Action") == action {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// allowAllPermissionsXML 包含IPv4和IPv6全放通的安全组规则
+const allowAllPermissionsXML = `<item><ipProtocol>-1</ipProtocol><ipRanges><item><cidrIp>0.0.0.0/0</cidrIp></item></ipRanges><ipv6Ranges><item><cidrIpv6>::/0</cidrIpv6></item></ipv6Ranges></item>`
+
+// launchResponses 开机流程中各Action的正常响应：AMI根卷8GB，安全组、VPC、子网、网关和路由表均已配置好
+var launchResponses = map[string]string{
+	"DescribeImages":           `<imagesSet><item><imageId>ami-test</imageId><rootDeviceName>/dev/xvda</rootDeviceName><blockDeviceMapping><item><deviceName>/dev/xvda</deviceName><ebs><volumeSize>8</volumeSize></ebs></item></blockDeviceMapping></item></imagesSet>`,
+	"DescribeSecurityGroups":   `<securityGroupInfo><item><groupId>sg-test</groupId><groupName>allow-all</groupName><ipPermissions>` + allowAllPermissionsXML + `</ipPermissions><ipPermissionsEgress>` + allowAllPermissionsXML + `</ipPermissionsEgress></item></securityGroupInfo>`,
+	"DescribeVpcs":             `<vpcSet><item><vpcId>vpc-test</vpcId><ipv6CidrBlockAssociationSet><item><ipv6CidrBlock>2600:1f18::/56</ipv6CidrBlock></item></ipv6CidrBlockAssociationSet></item></vpcSet>`,
+	"DescribeSubnets":          `<subnetSet><item><subnetId>subnet-test</subnetId><ipv6CidrBlockAssociationSet><item><ipv6CidrBlock>2600:1f18::/64</ipv6CidrBlock></item></ipv6CidrBlockAssociationSet></item></subnetSet>`,
+	"ModifySubnetAttribute":    `<return>true</return>`,
+	"DescribeInternetGateways": `<internetGatewaySet><item><internetGatewayId>igw-test</internetGatewayId></item></internetGatewaySet>`,
+	"DescribeRouteTables":      `<routeTableSet><item><routeTableId>rtb-test</routeTableId></item></routeTableSet>`,
+	"CreateRoute":              `<return>true</return>`,
+	"RunInstances":             `<instancesSet><item><instanceId>i-test</instanceId><instanceState><name>pending</name></instanceState></item></instancesSet>`,
+}
+
+// launchHandler 按 launchResponses 响应开机流程的请求，未知的Action返回错误
+func launchHandler(action string, form url.Values) (string, error) {
+	if body, ok := launchResponses[action]; ok {
+		return body, nil
+	}
+	return "", &ec2Error{Code: "UnsupportedOperation", Message: action}
+}
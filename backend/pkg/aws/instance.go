@@ -4,11 +4,14 @@ package aws
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
-	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"portal/pkg/config"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
@@ -16,17 +19,29 @@ import (
 
 // CreateInstanceParams 创建实例所需的参数结构
 type CreateInstanceParams struct {
-	Region       string // 区域,默认ap-east-1
-	ImageID      string // AMI ID
-	InstanceType string // 实例类型
-	DiskSize     int32  // 硬盘大小
-	Password     string // Root密码
-	Count        int32  // 创建数量,默认1
-	Script       string // 自定义开机脚本
-	UserID       string // 用户ID,用于标签
-	AccountID    string // 账号ID,用于标签
+	Region            string            // 区域,默认ap-east-1
+	ImageID           string            // AMI ID
+	InstanceType      string            // 实例类型
+	DiskSize          int32             // 硬盘大小
+	Password          string            // Root密码
+	Count             int32             // 创建数量,默认1
+	Script            string            // 自定义开机脚本
+	UserID            string            // 用户ID,用于标签
+	AccountID         string            // 账号ID,用于标签
+	SkipSSHPassword   bool              // 跳过开机脚本中设置root密码和开启SSH密码登录的部分
+	AdditionalVolumes []VolumeSpec      // 附加数据盘，默认只有根盘
+	Tags              map[string]string // 附加标签，如实例来源和补机任务ID
+	AllowPartial      bool              // 允许部分成功：容量不足时尽可能多地启动，至少1台
 }
 
+// 实例来源标签
+const (
+	TagSource    = "source"   // 实例来源标签键
+	TagQueueID   = "queue_id" // 补机任务ID标签键
+	SourceManual = "manual"   // 手动创建
+	SourceMakeup = "makeup"   // 自动补机创建
+)
+
 // CreateInstanceResult 创建实例的结果
 type CreateInstanceResult struct {
 	InstanceID string `json:"instance_id"`
@@ -40,6 +55,16 @@ func (c *AWSClient) CreateInstance(ctx context.Context, params CreateInstancePar
 	if params.Region == "" {
 		params.Region = "ap-east-1"
 	}
+	if params.ImageID == "" {
+		return nil, fmt.Errorf("区域[%s]的AMI为空", params.Region)
+	}
+
+	// 同一账号的创建请求串行执行，避免并发RunInstances触发RequestLimitExceeded
+	release, err := c.acquireCreateSlot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("等待账号创建实例名额失败: %v", err)
+	}
+	defer release()
 
 	// 创建AWS配置
 	cfg, err := c.createConfig(ctx, params.Region)
@@ -50,48 +75,25 @@ func (c *AWSClient) CreateInstance(ctx context.Context, params CreateInstancePar
 	// 创建EC2客户端
 	ec2Client := ec2.NewFromConfig(cfg)
 
+	// 确保硬盘大小不小于AMI要求的最小值，同时校验AMI在该区域存在
+	minDiskSize, err := c.getImageMinDiskSize(ctx, ec2Client, params.ImageID)
+	if errors.Is(err, ErrImageNotFound) {
+		return nil, fmt.Errorf("区域[%s]%v", params.Region, err)
+	} else if err != nil {
+		fmt.Printf("获取AMI[%s]最小硬盘大小失败: %v\n", params.ImageID, err)
+	} else if params.DiskSize < minDiskSize {
+		fmt.Printf("硬盘大小%dGB小于AMI[%s]要求的%dGB，已自动调整\n", params.DiskSize, params.ImageID, minDiskSize)
+		params.DiskSize = minDiskSize
+	}
+
 	// 准备用户数据脚本
-	userData := fmt.Sprintf(`#!/bin/bash
-# 启用IMDSv2
-TOKEN=$(curl -X PUT -H "X-aws-ec2-metadata-token-ttl-seconds: 21600" -s http://169.254.169.254/latest/api/token)
-
-# 设置root密码并启用root登录
-echo "root:%s" | chpasswd
-sed -i 's/^#PermitRootLogin.*/PermitRootLogin yes/' /etc/ssh/sshd_config
-sed -i 's/^PermitRootLogin.*/PermitRootLogin yes/' /etc/ssh/sshd_config
-sed -i 's/^PasswordAuthentication.*/PasswordAuthentication yes/' /etc/ssh/sshd_config
-systemctl restart sshd
-
-# 配置IPv6
-cat > /etc/network/interfaces.d/60-default-with-ipv6.cfg << 'EOF'
-auto lo
-iface lo inet loopback
-
-auto ens5
-iface ens5 inet dhcp
-iface ens5 inet6 dhcp
-EOF
-
-# 重启网络服务以应用IPv6配置
-systemctl restart networking
-
-# 确保IPv6转发已启用
-echo "net.ipv6.conf.all.forwarding=1" >> /etc/sysctl.conf
-echo "net.ipv6.conf.default.forwarding=1" >> /etc/sysctl.conf
-sysctl -p
-
-curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/client.sh | bash
-curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/d11.sh | bash
-curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/apt.sh | bash
-
-# 执行自定义脚本
-%s`, params.Password, params.Script)
+	userData := BuildUserData(params.Password, params.SkipSSHPassword, params.Script)
 
 	// 编码用户数据
 	encodedUserData := base64.StdEncoding.EncodeToString([]byte(userData))
 
-	// 从环境变量获取WS_URL
-	wsURL := os.Getenv("WS_URL")
+	// 从统一配置获取WS_URL
+	wsURL := config.Get().WSURL
 
 	// 准备标签
 	tags := []types.Tag{
@@ -112,6 +114,18 @@ curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/apt.sh | bash
 			Value: aws.String(wsURL),
 		},
 	}
+	// 附加标签按键排序，保证标签顺序稳定
+	extraKeys := make([]string, 0, len(params.Tags))
+	for key := range params.Tags {
+		extraKeys = append(extraKeys, key)
+	}
+	sort.Strings(extraKeys)
+	for _, key := range extraKeys {
+		tags = append(tags, types.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(params.Tags[key]),
+		})
+	}
 
 	// 创建安全组
 	sgID, err := c.createSecurityGroup(ctx, ec2Client)
@@ -125,11 +139,17 @@ curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/apt.sh | bash
 		return nil, fmt.Errorf("获取子网失败: %v", err)
 	}
 
+	// 默认要求全部启动成功，允许部分成功时只要求至少启动1台
+	minCount := params.Count
+	if params.AllowPartial {
+		minCount = 1
+	}
+
 	// 在准备启动实例的输入参数部分，修改NetworkInterfaces配置
 	input := &ec2.RunInstancesInput{
 		ImageId:      aws.String(params.ImageID),
 		InstanceType: types.InstanceType(params.InstanceType),
-		MinCount:     aws.Int32(params.Count),
+		MinCount:     aws.Int32(minCount),
 		MaxCount:     aws.Int32(params.Count),
 		UserData:     aws.String(encodedUserData),
 		NetworkInterfaces: []types.InstanceNetworkInterfaceSpecification{
@@ -141,15 +161,7 @@ curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/apt.sh | bash
 				Ipv6AddressCount:         aws.Int32(1), // 请求1个IPv6地址
 			},
 		},
-		BlockDeviceMappings: []types.BlockDeviceMapping{
-			{
-				DeviceName: aws.String("/dev/xvda"),
-				Ebs: &types.EbsBlockDevice{
-					VolumeSize: aws.Int32(params.DiskSize),
-					VolumeType: types.VolumeTypeGp2,
-				},
-			},
-		},
+		BlockDeviceMappings: buildBlockDeviceMappings(params.DiskSize, params.AdditionalVolumes),
 		// 开启实例元数据服务v2并允许标签访问
 		MetadataOptions: &types.InstanceMetadataOptionsRequest{
 			HttpTokens:              types.HttpTokensStateOptional, // 允许同时使用 IMDSv1 和 IMDSv2
@@ -168,6 +180,10 @@ curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/apt.sh | bash
 	// 运行实例
 	resp, err := ec2Client.RunInstances(ctx, input)
 	if err != nil {
+		// 网络配置可能已被修改，清除缓存以便下次重新配置
+		if isNetworkConfigError(err) {
+			c.invalidateSubnetCache(ec2Client.Options().Region)
+		}
 		return nil, fmt.Errorf("创建实例失败: %v", err)
 	}
 
@@ -187,7 +203,7 @@ curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/apt.sh | bash
 	return results, nil
 }
 
-// createSecurityGroup 创建或获取安全组
+// createSecurityGroup 创建或获取安全组，并确保其具备完整的全放通规则
 func (c *AWSClient) createSecurityGroup(ctx context.Context, ec2Client *ec2.Client) (string, error) {
 	// 先查找是否已存在同名安全组
 	describeResp, err := ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
@@ -202,111 +218,13 @@ func (c *AWSClient) createSecurityGroup(ctx context.Context, ec2Client *ec2.Clie
 		return "", fmt.Errorf("查询安全组失败: %v", err)
 	}
 
-	// 如果已存在，检查并确保其有正确的IPv6入站和出站规则
+	// 如果已存在，检查并补齐缺失的规则
 	if len(describeResp.SecurityGroups) > 0 {
-		sgID := *describeResp.SecurityGroups[0].GroupId
-		// fmt.Printf("发现已存在的allow-all安全组: %s\n", sgID)
-
-		// 检查是否已有IPv6入站规则
-		hasIpv6Ingress := false
-		for _, rule := range describeResp.SecurityGroups[0].IpPermissions {
-			if rule.IpProtocol != nil && *rule.IpProtocol == "-1" {
-				for _, ipv6Range := range rule.Ipv6Ranges {
-					if ipv6Range.CidrIpv6 != nil && *ipv6Range.CidrIpv6 == "::/0" {
-						hasIpv6Ingress = true
-						fmt.Printf("安全组已有IPv6入站规则\n")
-						break
-					}
-				}
-			}
-			if hasIpv6Ingress {
-				break
-			}
-		}
-
-		// 如果没有IPv6入站规则，添加一个
-		if !hasIpv6Ingress {
-			fmt.Printf("安全组缺少IPv6入站规则，尝试添加...\n")
-			for retries := 0; retries < 3; retries++ {
-				_, err = ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
-					GroupId: aws.String(sgID),
-					IpPermissions: []types.IpPermission{
-						{
-							IpProtocol: aws.String("-1"), // 所有协议
-							FromPort:   aws.Int32(-1),    // 所有端口
-							ToPort:     aws.Int32(-1),
-							Ipv6Ranges: []types.Ipv6Range{
-								{
-									CidrIpv6: aws.String("::/0"),
-								},
-							},
-						},
-					},
-				})
-				if err == nil {
-					fmt.Printf("成功添加IPv6入站规则到现有安全组\n")
-					break
-				}
-
-				// 如果是因为规则已存在导致的错误，这不是真正的错误
-				if strings.Contains(err.Error(), "InvalidPermission.Duplicate") {
-					fmt.Printf("IPv6入站规则已存在（重复错误）\n")
-					err = nil
-					break
-				}
-
-				fmt.Printf("添加IPv6入站规则到现有安全组失败(尝试 %d/3): %v\n", retries+1, err)
-				time.Sleep(time.Second * 2)
-			}
-
-			if err != nil {
-				fmt.Printf("警告: 无法为现有安全组添加IPv6入站规则: %v，IPv6可能无法正常工作\n", err)
-			}
-		}
-
-		// 检查是否已有IPv6出站规则
-		hasIpv6Egress := false
-		for _, rule := range describeResp.SecurityGroups[0].IpPermissionsEgress {
-			if rule.IpProtocol != nil && *rule.IpProtocol == "-1" {
-				for _, ipv6Range := range rule.Ipv6Ranges {
-					if ipv6Range.CidrIpv6 != nil && *ipv6Range.CidrIpv6 == "::/0" {
-						hasIpv6Egress = true
-						fmt.Printf("安全组已有IPv6出站规则\n")
-						break
-					}
-				}
-			}
-			if hasIpv6Egress {
-				break
-			}
+		group := describeResp.SecurityGroups[0]
+		if err := ensureSecurityGroupRules(ctx, ec2Client, group); err != nil {
+			return "", err
 		}
-
-		// 如果没有IPv6出站规则，添加一个
-		if !hasIpv6Egress {
-			fmt.Printf("安全组缺少IPv6出站规则，尝试添加...\n")
-			_, err = ec2Client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
-				GroupId: aws.String(sgID),
-				IpPermissions: []types.IpPermission{
-					{
-						IpProtocol: aws.String("-1"), // 所有协议
-						FromPort:   aws.Int32(-1),    // 所有端口
-						ToPort:     aws.Int32(-1),
-						Ipv6Ranges: []types.Ipv6Range{
-							{
-								CidrIpv6: aws.String("::/0"),
-							},
-						},
-					},
-				},
-			})
-			if err != nil {
-				fmt.Printf("警告: 无法为现有安全组添加IPv6出站规则: %v，继续执行\n", err)
-			} else {
-				fmt.Printf("成功添加IPv6出站规则到现有安全组\n")
-			}
-		}
-
-		return sgID, nil
+		return *group.GroupId, nil
 	}
 
 	// 如果不存在，创建新的安全组
@@ -337,88 +255,147 @@ func (c *AWSClient) createSecurityGroup(ctx context.Context, ec2Client *ec2.Clie
 		return "", fmt.Errorf("创建安全组失败: %v", err)
 	}
 
-	// 配置安全组入站规则
-	_, err = ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: createResp.GroupId,
-		IpPermissions: []types.IpPermission{
-			{
-				IpProtocol: aws.String("-1"), // 所有协议
-				FromPort:   aws.Int32(-1),    // 所有端口
-				ToPort:     aws.Int32(-1),
-				IpRanges: []types.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
-				},
-			},
-		},
-	})
-	if err != nil {
-		return "", fmt.Errorf("配置IPv4安全组入站规则失败: %v", err)
+	// 新建的安全组不假设任何默认规则，全部规则由ensureSecurityGroupRules补齐，重复规则视为已存在
+	group := types.SecurityGroup{GroupId: createResp.GroupId}
+	if err := ensureSecurityGroupRules(ctx, ec2Client, group); err != nil {
+		return "", err
 	}
 
-	// 添加IPv6入站规则
-	for retries := 0; retries < 3; retries++ {
-		_, err = ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
-			GroupId: createResp.GroupId,
-			IpPermissions: []types.IpPermission{
-				{
-					IpProtocol: aws.String("-1"), // 所有协议
-					FromPort:   aws.Int32(-1),    // 所有端口
-					ToPort:     aws.Int32(-1),
-					Ipv6Ranges: []types.Ipv6Range{
-						{
-							CidrIpv6: aws.String("::/0"),
-						},
-					},
-				},
-			},
-		})
-		if err == nil {
-			fmt.Printf("成功配置IPv6安全组入站规则\n")
-			break
-		}
+	return *createResp.GroupId, nil
+}
 
-		// 如果是因为规则已存在导致的错误，这不是真正的错误
-		if strings.Contains(err.Error(), "InvalidPermission.Duplicate") {
-			fmt.Printf("IPv6安全组入站规则已存在\n")
-			err = nil
-			break
+// allowAllRule 安全组需要具备的一条全放通规则
+type allowAllRule struct {
+	name   string // 规则名称，用于日志
+	egress bool   // 是否为出站规则
+	ipv6   bool   // 是否为IPv6规则
+	cidr   string // 放通的CIDR
+}
+
+// allowAllRules allow-all安全组需要具备的全部规则
+var allowAllRules = []allowAllRule{
+	{name: "IPv4入站", egress: false, ipv6: false, cidr: "0.0.0.0/0"},
+	{name: "IPv6入站", egress: false, ipv6: true, cidr: "::/0"},
+	{name: "IPv4出站", egress: true, ipv6: false, cidr: "0.0.0.0/0"},
+	{name: "IPv6出站", egress: true, ipv6: true, cidr: "::/0"},
+}
+
+// hasAllowAllRule 检查已有规则中是否包含指定的全放通规则
+func hasAllowAllRule(permissions []types.IpPermission, rule allowAllRule) bool {
+	for _, permission := range permissions {
+		if permission.IpProtocol == nil || *permission.IpProtocol != "-1" {
+			continue
+		}
+		if rule.ipv6 {
+			for _, ipv6Range := range permission.Ipv6Ranges {
+				if ipv6Range.CidrIpv6 != nil && *ipv6Range.CidrIpv6 == rule.cidr {
+					return true
+				}
+			}
+		} else {
+			for _, ipRange := range permission.IpRanges {
+				if ipRange.CidrIp != nil && *ipRange.CidrIp == rule.cidr {
+					return true
+				}
+			}
 		}
+	}
+	return false
+}
 
-		fmt.Printf("配置IPv6安全组入站规则失败(尝试 %d/3): %v\n", retries+1, err)
-		time.Sleep(time.Second * 2)
+// permission 构造全放通规则对应的IpPermission
+func (rule allowAllRule) permission() types.IpPermission {
+	permission := types.IpPermission{
+		IpProtocol: aws.String("-1"), // 所有协议
+		FromPort:   aws.Int32(-1),    // 所有端口
+		ToPort:     aws.Int32(-1),
+	}
+	if rule.ipv6 {
+		permission.Ipv6Ranges = []types.Ipv6Range{{CidrIpv6: aws.String(rule.cidr)}}
+	} else {
+		permission.IpRanges = []types.IpRange{{CidrIp: aws.String(rule.cidr)}}
 	}
+	return permission
+}
 
-	// 如果所有尝试都失败，记录警告但继续执行
-	if err != nil {
-		fmt.Printf("警告: 无法配置IPv6安全组入站规则: %v，继续执行但IPv6可能无法正常工作\n", err)
+// ensureSecurityGroupRules 幂等地确保安全组具备全部全放通规则，已存在的规则不会重复添加
+// IPv4规则添加失败返回错误，IPv6规则添加失败只记录警告
+func ensureSecurityGroupRules(ctx context.Context, ec2Client *ec2.Client, group types.SecurityGroup) error {
+	for _, rule := range allowAllRules {
+		existing := group.IpPermissions
+		if rule.egress {
+			existing = group.IpPermissionsEgress
+		}
+		if hasAllowAllRule(existing, rule) {
+			continue
+		}
+
+		var err error
+		for retries := 0; retries < 3; retries++ {
+			if rule.egress {
+				_, err = ec2Client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+					GroupId:       group.GroupId,
+					IpPermissions: []types.IpPermission{rule.permission()},
+				})
+			} else {
+				_, err = ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+					GroupId:       group.GroupId,
+					IpPermissions: []types.IpPermission{rule.permission()},
+				})
+			}
+			if err == nil {
+				fmt.Printf("成功添加%s规则到安全组[%s]\n", rule.name, *group.GroupId)
+				break
+			}
+
+			// 如果是因为规则已存在导致的错误，这不是真正的错误
+			if strings.Contains(err.Error(), "InvalidPermission.Duplicate") {
+				err = nil
+				break
+			}
+
+			fmt.Printf("添加%s规则失败(尝试 %d/3): %v\n", rule.name, retries+1, err)
+			time.Sleep(time.Second * 2)
+		}
+
+		if err != nil {
+			if !rule.ipv6 {
+				return fmt.Errorf("配置安全组%s规则失败: %v", rule.name, err)
+			}
+			fmt.Printf("警告: 无法配置安全组%s规则: %v，继续执行但IPv6可能无法正常工作\n", rule.name, err)
+		}
 	}
-	// 添加IPv6出站规则
-	_, err = ec2Client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
-		GroupId: createResp.GroupId,
-		IpPermissions: []types.IpPermission{
-			{
-				IpProtocol: aws.String("-1"), // 所有协议
-				FromPort:   aws.Int32(-1),    // 所有端口
-				ToPort:     aws.Int32(-1),
-				Ipv6Ranges: []types.Ipv6Range{
-					{
-						CidrIpv6: aws.String("::/0"),
-					},
-				},
-			},
-		},
+	return nil
+}
+
+// getImageMinDiskSize 获取AMI根卷快照的大小，即创建实例时允许的最小硬盘大小
+func (c *AWSClient) getImageMinDiskSize(ctx context.Context, ec2Client *ec2.Client, imageID string) (int32, error) {
+	resp, err := ec2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+		ImageIds: []string{imageID},
 	})
 	if err != nil {
-		return "", fmt.Errorf("配置IPv6安全组出站规则失败: %v", err)
+		return 0, err
+	}
+	if len(resp.Images) == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrImageNotFound, imageID)
 	}
 
-	return *createResp.GroupId, nil
+	image := resp.Images[0]
+	for _, mapping := range image.BlockDeviceMappings {
+		if mapping.Ebs == nil || mapping.Ebs.VolumeSize == nil {
+			continue
+		}
+		// 优先匹配根设备，没有根设备名时使用第一个EBS卷
+		if image.RootDeviceName == nil || aws.ToString(mapping.DeviceName) == aws.ToString(image.RootDeviceName) {
+			return *mapping.Ebs.VolumeSize, nil
+		}
+	}
+
+	return 0, fmt.Errorf("AMI[%s]未包含根卷信息", imageID)
 }
 
-// getDefaultSubnet 获取默认VPC的第一个子网并确保IPv6已启用
-func (c *AWSClient) getDefaultSubnet(ctx context.Context, ec2Client *ec2.Client) (string, error) {
+// setupDefaultSubnet 获取默认VPC的第一个子网并确保IPv6、互联网网关和路由已配置
+func (c *AWSClient) setupDefaultSubnet(ctx context.Context, ec2Client *ec2.Client) (string, error) {
 	// 获取默认VPC
 	vpcResp, err := ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
 		Filters: []types.Filter{
@@ -656,19 +633,49 @@ func (c *AWSClient) getDefaultSubnet(ctx context.Context, ec2Client *ec2.Client)
 type DeleteInstanceParams struct {
 	Region     string // 区域
 	InstanceID string // 实例ID
+	KeepEIP    bool   // 是否保留弹性IP（只解绑不释放，用于删除后重建复用）
+}
+
+// DeleteInstanceResult 删除实例结果
+type DeleteInstanceResult struct {
+	RetainedAllocationID string // 保留的弹性IP分配ID（仅KeepEIP时有值）
+	RetainedIP           string // 保留的弹性IP地址
+}
+
+// addressSettleDelay 删除实例后等待弹性IP状态更新的时间，再清理未关联的弹性IP
+var addressSettleDelay = 2 * time.Second
+
+// retainedAddressTag 删除实例时保留待复用的弹性IP带有该标签（值为true），清理未关联的弹性IP时跳过
+const retainedAddressTag = "portal:retained"
+
+// isRetainedAddress 判断弹性IP是否为删除实例时保留待复用的
+func isRetainedAddress(address types.Address) bool {
+	for _, tag := range address.Tags {
+		if aws.ToString(tag.Key) == retainedAddressTag && aws.ToString(tag.Value) == "true" {
+			return true
+		}
+	}
+	return false
 }
 
 // DeleteInstance 删除EC2实例并释放关联的弹性IP
-func (c *AWSClient) DeleteInstance(ctx context.Context, params DeleteInstanceParams) error {
+// 设置KeepEIP时只解绑弹性IP，不释放，并打上保留标签，之后清理未关联的弹性IP时不会被释放
+func (c *AWSClient) DeleteInstance(ctx context.Context, params DeleteInstanceParams) (*DeleteInstanceResult, error) {
 	// 创建AWS配置
 	cfg, err := c.createConfig(ctx, params.Region)
 	if err != nil {
-		return fmt.Errorf("配置AWS失败: %v", err)
+		return nil, fmt.Errorf("配置AWS失败: %v", err)
 	}
 
+	result := &DeleteInstanceResult{}
+
 	// 创建EC2客户端
 	ec2Client := ec2.NewFromConfig(cfg)
 
+	// 同一账号的弹性IP解绑、释放和清理需要串行，不同账号之间仍可并行
+	unlock := c.lockAddresses(params.Region)
+	defer unlock()
+
 	// 首先检查该实例是否有关联的弹性IP
 	describeAddressesInput := &ec2.DescribeAddressesInput{
 		Filters: []types.Filter{
@@ -694,6 +701,26 @@ func (c *AWSClient) DeleteInstance(ctx context.Context, params DeleteInstancePar
 				}
 			}
 
+			// 保留弹性IP，记录分配ID供重建时复用
+			if params.KeepEIP {
+				if address.AllocationId != nil {
+					result.RetainedAllocationID = *address.AllocationId
+					_, err = ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+						Resources: []string{*address.AllocationId},
+						Tags:      []types.Tag{{Key: aws.String(retainedAddressTag), Value: aws.String("true")}},
+					})
+					if err != nil {
+						// 记录错误但继续流程，未打上标签的弹性IP可能被之后的清理释放
+						fmt.Printf("标记保留的弹性IP失败: %v\n", err)
+					}
+				}
+				if address.PublicIp != nil {
+					result.RetainedIP = *address.PublicIp
+					fmt.Printf("保留弹性IP: %s\n", *address.PublicIp)
+				}
+				continue
+			}
+
 			// 释放弹性IP
 			if address.AllocationId != nil {
 				_, err = ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
@@ -717,12 +744,17 @@ func (c *AWSClient) DeleteInstance(ctx context.Context, params DeleteInstancePar
 	// 执行删除操作
 	_, err = ec2Client.TerminateInstances(ctx, input)
 	if err != nil {
-		return fmt.Errorf("删除实例失败: %v", err)
+		return result, fmt.Errorf("删除实例失败: %v", err)
+	}
+
+	// 保留弹性IP时不清理未关联的弹性IP，避免标签尚未生效时刚解绑的IP被释放
+	if params.KeepEIP {
+		return result, nil
 	}
 
 	// 删除实例后，再次检查是否有弹性IP仍然存在但未关联任何实例
-	// 这是为了捕获可能的边缘情况，如IP在过程中的状态变化
-	time.Sleep(2 * time.Second) // 稍微等待以确保状态更新
+	// 这是为了捕获可能的边缘情况，如IP在过程中的状态变化，保留待复用的弹性IP除外
+	time.Sleep(addressSettleDelay) // 稍微等待以确保状态更新
 
 	unassociatedAddressesInput := &ec2.DescribeAddressesInput{
 		Filters: []types.Filter{
@@ -736,6 +768,9 @@ func (c *AWSClient) DeleteInstance(ctx context.Context, params DeleteInstancePar
 	unassociatedAddresses, err := ec2Client.DescribeAddresses(ctx, unassociatedAddressesInput)
 	if err == nil && len(unassociatedAddresses.Addresses) > 0 {
 		for _, address := range unassociatedAddresses.Addresses {
+			if isRetainedAddress(address) {
+				continue
+			}
 			// 尝试释放未关联的弹性IP
 			if address.AllocationId != nil {
 				_, err = ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
@@ -750,7 +785,7 @@ func (c *AWSClient) DeleteInstance(ctx context.Context, params DeleteInstancePar
 		}
 	}
 
-	return nil
+	return result, nil
 }
 
 // ChangeIPParams 更换IP参数结构
@@ -798,42 +833,27 @@ func (c *AWSClient) ChangeIP(ctx context.Context, params ChangeIPParams) (*Chang
 		OldIP: currentIP,
 	}
 
-	// 检查当前IP是否为弹性IP
-	addressesResult, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("public-ip"),
-				Values: []string{currentIP},
-			},
-		},
-	})
-
-	// 如果当前IP是弹性IP,需要先解绑并释放
-	if err == nil && len(addressesResult.Addresses) > 0 {
-		address := addressesResult.Addresses[0]
-
-		// 解绑弹性IP
-		if address.AssociationId != nil {
-			_, err = ec2Client.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{
-				AssociationId: address.AssociationId,
-			})
-			if err != nil {
-				return nil, fmt.Errorf("解绑弹性IP失败: %v", err)
-			}
-		}
+	// 同一账号的弹性IP清理、分配和绑定需要串行，避免新分配的IP被并发的清理释放
+	unlock := c.lockAddresses(params.Region)
+	defer unlock()
 
-		// 释放弹性IP
-		if address.AllocationId != nil {
-			_, err = ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
-				AllocationId: address.AllocationId,
-			})
-			if err != nil {
-				return nil, fmt.Errorf("释放弹性IP失败: %v", err)
-			}
+	// 检查当前IP是否为弹性IP，先保留旧IP，新IP绑定成功后再释放，避免轮换失败导致实例没有公网IP
+	var oldAddress *types.Address
+	if currentIP != "" {
+		addressesResult, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+			Filters: []types.Filter{
+				{
+					Name:   aws.String("public-ip"),
+					Values: []string{currentIP},
+				},
+			},
+		})
+		if err == nil && len(addressesResult.Addresses) > 0 {
+			oldAddress = &addressesResult.Addresses[0]
 		}
 	}
 
-	// 释放所有未绑定的弹性IP
+	// 释放所有未绑定的弹性IP，腾出区域弹性IP名额，保留待复用的弹性IP除外
 	unassociatedAddresses, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
 		Filters: []types.Filter{
 			{
@@ -845,6 +865,9 @@ func (c *AWSClient) ChangeIP(ctx context.Context, params ChangeIPParams) (*Chang
 
 	if err == nil {
 		for _, address := range unassociatedAddresses.Addresses {
+			if isRetainedAddress(address) {
+				continue
+			}
 			if address.AllocationId != nil {
 				_, err = ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
 					AllocationId: address.AllocationId,
@@ -857,14 +880,34 @@ func (c *AWSClient) ChangeIP(ctx context.Context, params ChangeIPParams) (*Chang
 	}
 
 	// 分配新的弹性IP
+	oldReleased := false
 	allocateResult, err := ec2Client.AllocateAddress(ctx, &ec2.AllocateAddressInput{
 		Domain: types.DomainTypeVpc,
 	})
+	if err != nil && isAddressLimitError(err) && oldAddress != nil {
+		// 弹性IP名额已满，只能先释放旧IP再分配
+		fmt.Printf("实例[%s]所在区域弹性IP已达上限，先释放旧IP[%s]再分配\n", params.InstanceID, currentIP)
+		if releaseErr := releaseAddress(ctx, ec2Client, *oldAddress); releaseErr != nil {
+			return nil, fmt.Errorf("弹性IP已达上限且释放旧IP失败: %v", releaseErr)
+		}
+		oldReleased = true
+
+		allocateResult, err = ec2Client.AllocateAddress(ctx, &ec2.AllocateAddressInput{
+			Domain: types.DomainTypeVpc,
+		})
+		if err != nil {
+			// 分配仍然失败，找回原IP并重新绑定，保证实例可以连通
+			if restoreErr := restoreAddress(ctx, ec2Client, params.InstanceID, currentIP); restoreErr != nil {
+				return nil, fmt.Errorf("分配新的弹性IP失败: %v，恢复原IP失败: %v", err, restoreErr)
+			}
+			return nil, fmt.Errorf("分配新的弹性IP失败，已恢复原IP: %v", err)
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("分配新的弹性IP失败: %v", err)
 	}
 
-	// 绑定新的弹性IP到实例
+	// 绑定新的弹性IP到实例，会替换实例当前的公网IP
 	_, err = ec2Client.AssociateAddress(ctx, &ec2.AssociateAddressInput{
 		InstanceId:   aws.String(params.InstanceID),
 		AllocationId: allocateResult.AllocationId,
@@ -874,16 +917,129 @@ func (c *AWSClient) ChangeIP(ctx context.Context, params ChangeIPParams) (*Chang
 		_, releaseErr := ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
 			AllocationId: allocateResult.AllocationId,
 		})
+		// 旧IP已释放时尝试找回，未释放时实例仍绑定着旧IP
+		if oldReleased {
+			if restoreErr := restoreAddress(ctx, ec2Client, params.InstanceID, currentIP); restoreErr != nil {
+				fmt.Printf("恢复实例[%s]原IP[%s]失败: %v\n", params.InstanceID, currentIP, restoreErr)
+			}
+		}
 		if releaseErr != nil {
 			return nil, fmt.Errorf("绑定新IP失败且无法释放: %v, %v", err, releaseErr)
 		}
 		return nil, fmt.Errorf("绑定新IP失败: %v", err)
 	}
 
+	// 新IP绑定成功后释放旧的弹性IP，失败时只记录，不影响本次更换结果
+	if oldAddress != nil && !oldReleased {
+		if releaseErr := releaseAddress(ctx, ec2Client, *oldAddress); releaseErr != nil {
+			fmt.Printf("释放实例[%s]旧弹性IP[%s]失败: %v\n", params.InstanceID, currentIP, releaseErr)
+		}
+	}
+
 	result.NewIP = *allocateResult.PublicIp
 	return result, nil
 }
 
+// isAddressLimitError 判断是否为弹性IP数量达到上限的错误
+func isAddressLimitError(err error) bool {
+	return strings.Contains(err.Error(), "AddressLimitExceeded")
+}
+
+// releaseAddress 解绑并释放弹性IP，已被替换而自动解绑的地址直接释放
+func releaseAddress(ctx context.Context, ec2Client *ec2.Client, address types.Address) error {
+	if address.AssociationId != nil {
+		_, err := ec2Client.DisassociateAddress(ctx, &ec2.DisassociateAddressInput{
+			AssociationId: address.AssociationId,
+		})
+		if err != nil && !strings.Contains(err.Error(), "InvalidAssociationID.NotFound") {
+			return fmt.Errorf("解绑弹性IP失败: %v", err)
+		}
+	}
+	if address.AllocationId != nil {
+		_, err := ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{
+			AllocationId: address.AllocationId,
+		})
+		if err != nil {
+			return fmt.Errorf("释放弹性IP失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// restoreAddress 找回刚释放的弹性IP并重新绑定到实例，地址已被他人分配时会失败
+func restoreAddress(ctx context.Context, ec2Client *ec2.Client, instanceID string, ip string) error {
+	allocateResult, err := ec2Client.AllocateAddress(ctx, &ec2.AllocateAddressInput{
+		Domain:  types.DomainTypeVpc,
+		Address: aws.String(ip),
+	})
+	if err != nil {
+		return fmt.Errorf("找回弹性IP失败: %v", err)
+	}
+	_, err = ec2Client.AssociateAddress(ctx, &ec2.AssociateAddressInput{
+		InstanceId:   aws.String(instanceID),
+		AllocationId: allocateResult.AllocationId,
+	})
+	if err != nil {
+		return fmt.Errorf("重新绑定弹性IP失败: %v", err)
+	}
+	return nil
+}
+
+// AssociateAddressParams 绑定已有弹性IP参数
+type AssociateAddressParams struct {
+	Region       string // 区域
+	InstanceID   string // 实例ID
+	AllocationID string // 弹性IP分配ID
+}
+
+// AssociateAddress 等待实例进入running状态后绑定已有的弹性IP，返回绑定的IP地址
+func (c *AWSClient) AssociateAddress(ctx context.Context, params AssociateAddressParams) (string, error) {
+	// 创建AWS配置
+	cfg, err := c.createConfig(ctx, params.Region)
+	if err != nil {
+		return "", fmt.Errorf("配置AWS失败: %v", err)
+	}
+
+	// 创建EC2客户端
+	ec2Client := ec2.NewFromConfig(cfg)
+
+	// 新建实例处于pending状态时无法绑定弹性IP，需先等待running
+	waiter := ec2.NewInstanceRunningWaiter(ec2Client)
+	err = waiter.Wait(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{params.InstanceID},
+	}, 5*time.Minute)
+	if err != nil {
+		return "", fmt.Errorf("等待实例运行失败: %v", err)
+	}
+
+	_, err = ec2Client.AssociateAddress(ctx, &ec2.AssociateAddressInput{
+		InstanceId:   aws.String(params.InstanceID),
+		AllocationId: aws.String(params.AllocationID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("绑定弹性IP失败: %v", err)
+	}
+
+	// 弹性IP已重新使用，移除保留标签
+	_, err = ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+		Resources: []string{params.AllocationID},
+		Tags:      []types.Tag{{Key: aws.String(retainedAddressTag)}},
+	})
+	if err != nil {
+		fmt.Printf("移除弹性IP保留标签失败: %v\n", err)
+	}
+
+	// 查询绑定后的IP地址
+	addresses, err := ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		AllocationIds: []string{params.AllocationID},
+	})
+	if err == nil && len(addresses.Addresses) > 0 && addresses.Addresses[0].PublicIp != nil {
+		return *addresses.Addresses[0].PublicIp, nil
+	}
+
+	return "", nil
+}
+
 // InstanceInfo 实例信息结构
 type InstanceInfo struct {
 	InstanceID   string    `json:"instance_id"`
@@ -892,6 +1048,8 @@ type InstanceInfo struct {
 	InstanceType string    `json:"instance_type"`
 	State        string    `json:"state"`
 	LaunchTime   time.Time `json:"launch_time"`
+	Source       string    `json:"source,omitempty"`   // 实例来源：manual/makeup
+	QueueID      string    `json:"queue_id,omitempty"` // 创建该实例的补机任务ID
 }
 
 // ListInstancesParams 查询实例列表参数
@@ -927,15 +1085,27 @@ func (c *AWSClient) ListInstances(ctx context.Context, params ListInstancesParam
 		},
 	}
 
-	// 执行查询
-	result, err := ec2Client.DescribeInstances(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("查询实例失败: %v", err)
-	}
+	// 分页查询，避免实例较多时只返回第一页
+	return describeAllInstances(ctx, ec2Client, input)
+}
 
-	// 在解析结果部分，收集IPv6地址
+// describeAllInstances 按分页查询所有实例并转换为实例信息，DescribeInstances单页有数量上限
+func describeAllInstances(ctx context.Context, client ec2.DescribeInstancesAPIClient, input *ec2.DescribeInstancesInput) ([]InstanceInfo, error) {
 	var instances []InstanceInfo
-	for _, reservation := range result.Reservations {
+	paginator := ec2.NewDescribeInstancesPaginator(client, input)
+	for paginator.HasMorePages() {
+		result, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("查询实例失败: %v", err)
+		}
+		instances = appendInstanceInfos(instances, result.Reservations)
+	}
+	return instances, nil
+}
+
+// appendInstanceInfos 解析一页查询结果，收集IP、IPv6和来源标签
+func appendInstanceInfos(instances []InstanceInfo, reservations []types.Reservation) []InstanceInfo {
+	for _, reservation := range reservations {
 		for _, instance := range reservation.Instances {
 			info := InstanceInfo{
 				InstanceID:   *instance.InstanceId,
@@ -957,9 +1127,22 @@ func (c *AWSClient) ListInstances(ctx context.Context, params ListInstancesParam
 				}
 			}
 
+			// 读取来源标签，便于追溯补机任务
+			for _, tag := range instance.Tags {
+				if tag.Key == nil || tag.Value == nil {
+					continue
+				}
+				switch *tag.Key {
+				case TagSource:
+					info.Source = *tag.Value
+				case TagQueueID:
+					info.QueueID = *tag.Value
+				}
+			}
+
 			instances = append(instances, info)
 		}
 	}
 
-	return instances, nil
+	return instances
 }
@@ -0,0 +1,257 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// newTestEC2Client 创建指向模拟EC2服务的客户端
+func newTestEC2Client(t *testing.T) *ec2.Client {
+	t.Helper()
+	client := &AWSClient{AccessKey: "AKIA-SG", SecretKey: "secret"}
+	cfg, err := client.createConfig(context.Background(), "ap-east-1")
+	if err != nil {
+		t.Fatalf("创建AWS配置失败: %v", err)
+	}
+	return ec2.NewFromConfig(cfg)
+}
+
+func TestCreateSecurityGroupConfiguredIsNoop(t *testing.T) {
+	fake := newFakeEC2(t, launchHandler)
+	ec2Client := newTestEC2Client(t)
+
+	for i := 0; i < 2; i++ {
+		groupID, err := (&AWSClient{}).createSecurityGroup(context.Background(), ec2Client)
+		if err != nil {
+			t.Fatalf("第%d次配置安全组失败: %v", i+1, err)
+		}
+		if groupID != "sg-test" {
+			t.Fatalf("安全组ID = %s, want sg-test", groupID)
+		}
+	}
+
+	for _, action := range fake.actions() {
+		if action != "DescribeSecurityGroups" {
+			t.Fatalf("已配置好的安全组不应有其他调用, 实际调用了%s（全部: %v）", action, fake.actions())
+		}
+	}
+}
+
+func TestCreateSecurityGroupAddsMissingIPv6Rules(t *testing.T) {
+	ipv4Only := `<item><ipProtocol>-1</ipProtocol><ipRanges><item><cidrIp>0.0.0.0/0</cidrIp></item></ipRanges></item>`
+	fake := newFakeEC2(t, func(action string, form url.Values) (string, error) {
+		switch action {
+		case "DescribeSecurityGroups":
+			return `<securityGroupInfo><item><groupId>sg-test</groupId><groupName>allow-all</groupName><ipPermissions>` + ipv4Only + `</ipPermissions><ipPermissionsEgress>` + ipv4Only + `</ipPermissionsEgress></item></securityGroupInfo>`, nil
+		case "AuthorizeSecurityGroupIngress":
+			return `<return>true</return>`, nil
+		case "AuthorizeSecurityGroupEgress":
+			// 规则已存在时视为成功
+			return "", &ec2Error{Code: "InvalidPermission.Duplicate", Message: "the specified rule already exists"}
+		}
+		return "", &ec2Error{Code: "UnsupportedOperation", Message: action}
+	})
+
+	if _, err := (&AWSClient{}).createSecurityGroup(context.Background(), newTestEC2Client(t)); err != nil {
+		t.Fatalf("补齐安全组规则失败: %v", err)
+	}
+
+	ingress := fake.callsFor("AuthorizeSecurityGroupIngress")
+	egress := fake.callsFor("AuthorizeSecurityGroupEgress")
+	if len(ingress) != 1 || len(egress) != 1 {
+		t.Fatalf("添加入站规则%d次、出站规则%d次, 期望各补齐1条IPv6规则", len(ingress), len(egress))
+	}
+	for _, call := range append(ingress, egress...) {
+		if call.Get("IpPermissions.1.Ipv6Ranges.1.CidrIpv6") != "::/0" || call.Get("IpPermissions.1.IpRanges.1.CidrIp") != "" {
+			t.Errorf("%s 参数 = %v, 期望只添加IPv6规则", call.Get("Action"), call)
+		}
+	}
+}
+
+func TestCreateInstanceAddsMakeupTags(t *testing.T) {
+	fake := newFakeEC2(t, launchHandler)
+	client := &AWSClient{AccessKey: "AKIA-TAGS", SecretKey: "secret"}
+
+	_, err := client.CreateInstance(context.Background(), CreateInstanceParams{
+		Region:       "ap-east-1",
+		ImageID:      "ami-test",
+		InstanceType: "t3.micro",
+		Count:        1,
+		UserID:       "u1",
+		AccountID:    "9441",
+		Tags:         map[string]string{TagSource: SourceMakeup, TagQueueID: "u1:ap-east-1"},
+	})
+	if err != nil {
+		t.Fatalf("创建实例失败: %v", err)
+	}
+
+	runs := fake.callsFor("RunInstances")
+	if len(runs) != 1 {
+		t.Fatalf("RunInstances 调用%d次, want 1", len(runs))
+	}
+	tags := make(map[string]string)
+	for i := 1; ; i++ {
+		key := runs[0].Get(fmt.Sprintf("TagSpecification.1.Tag.%d.Key", i))
+		if key == "" {
+			break
+		}
+		tags[key] = runs[0].Get(fmt.Sprintf("TagSpecification.1.Tag.%d.Value", i))
+	}
+	if tags[TagSource] != SourceMakeup || tags[TagQueueID] != "u1:ap-east-1" {
+		t.Fatalf("实例标签 = %v, 期望包含来源和补机任务ID", tags)
+	}
+}
+
+// changeIPHandler 模拟实例绑定着弹性IP 1.1.1.1 时的换IP流程，allocate 处理不带指定地址的分配请求
+func changeIPHandler(allocate func() (string, error)) func(action string, form url.Values) (string, error) {
+	return func(action string, form url.Values) (string, error) {
+		switch action {
+		case "DescribeInstances":
+			return `<reservationSet><item><instancesSet><item><instanceId>i-test</instanceId><ipAddress>1.1.1.1</ipAddress></item></instancesSet></item></reservationSet>`, nil
+		case "DescribeAddresses":
+			if form.Get("Filter.1.Name") == "public-ip" {
+				return `<addressesSet><item><publicIp>1.1.1.1</publicIp><allocationId>eipalloc-old</allocationId><associationId>eipassoc-old</associationId><domain>vpc</domain></item></addressesSet>`, nil
+			}
+			return `<addressesSet/>`, nil
+		case "AllocateAddress":
+			if ip := form.Get("Address"); ip != "" {
+				// 找回刚释放的原IP
+				return `<publicIp>` + ip + `</publicIp><allocationId>eipalloc-restored</allocationId><domain>vpc</domain>`, nil
+			}
+			return allocate()
+		case "AssociateAddress":
+			return `<return>true</return><associationId>eipassoc-new</associationId>`, nil
+		case "DisassociateAddress", "ReleaseAddress":
+			return `<return>true</return>`, nil
+		}
+		return "", &ec2Error{Code: "UnsupportedOperation", Message: action}
+	}
+}
+
+func TestChangeIPReleasesOldAddressAfterAssociate(t *testing.T) {
+	fake := newFakeEC2(t, changeIPHandler(func() (string, error) {
+		return `<publicIp>2.2.2.2</publicIp><allocationId>eipalloc-new</allocationId><domain>vpc</domain>`, nil
+	}))
+
+	result, err := (&AWSClient{AccessKey: "AKIA-EIP", SecretKey: "secret"}).ChangeIP(context.Background(), ChangeIPParams{
+		Region:     "ap-east-1",
+		InstanceID: "i-test",
+	})
+	if err != nil {
+		t.Fatalf("更换IP失败: %v", err)
+	}
+	if result.OldIP != "1.1.1.1" || result.NewIP != "2.2.2.2" {
+		t.Fatalf("更换结果 = %+v", result)
+	}
+
+	// 旧IP在新IP绑定成功后才释放
+	associated, released := -1, -1
+	for i, action := range fake.actions() {
+		switch action {
+		case "AssociateAddress":
+			associated = i
+		case "ReleaseAddress":
+			released = i
+		}
+	}
+	if associated < 0 || released < associated {
+		t.Fatalf("调用顺序 = %v, 期望先绑定新IP再释放旧IP", fake.actions())
+	}
+	if got := fake.callsFor("ReleaseAddress")[0].Get("AllocationId"); got != "eipalloc-old" {
+		t.Fatalf("释放的弹性IP = %s, want eipalloc-old", got)
+	}
+}
+
+func TestChangeIPRestoresOldAddressAtLimit(t *testing.T) {
+	fake := newFakeEC2(t, changeIPHandler(func() (string, error) {
+		return "", &ec2Error{Code: "AddressLimitExceeded", Message: "The maximum number of addresses has been reached."}
+	}))
+
+	_, err := (&AWSClient{AccessKey: "AKIA-EIP-LIMIT", SecretKey: "secret"}).ChangeIP(context.Background(), ChangeIPParams{
+		Region:     "ap-east-1",
+		InstanceID: "i-test",
+	})
+	if err == nil {
+		t.Fatal("弹性IP达到上限且无法分配时应返回错误")
+	}
+
+	// 实例重新绑定了找回的原IP，保持可连通
+	associations := fake.callsFor("AssociateAddress")
+	if len(associations) != 1 {
+		t.Fatalf("AssociateAddress调用%d次, 期望只重新绑定原IP一次（全部: %v）", len(associations), fake.actions())
+	}
+	if associations[0].Get("InstanceId") != "i-test" || associations[0].Get("AllocationId") != "eipalloc-restored" {
+		t.Fatalf("重新绑定参数 = %v, 期望把找回的原IP绑定到实例", associations[0])
+	}
+	restored := false
+	for _, call := range fake.callsFor("AllocateAddress") {
+		restored = restored || call.Get("Address") == "1.1.1.1"
+	}
+	if !restored {
+		t.Fatal("分配失败后应找回原IP 1.1.1.1")
+	}
+}
+
+// pagedDescribeClient 按NextToken分页返回实例的模拟客户端
+type pagedDescribeClient struct {
+	pages  map[string]*ec2.DescribeInstancesOutput // 请求的NextToken -> 返回的页面，第一页的键为空
+	tokens []string                                // 按顺序记录每次请求的NextToken
+}
+
+func (c *pagedDescribeClient) DescribeInstances(ctx context.Context, input *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	token := aws.ToString(input.NextToken)
+	c.tokens = append(c.tokens, token)
+	page, ok := c.pages[token]
+	if !ok {
+		return nil, fmt.Errorf("unexpected token %q", token)
+	}
+	return page, nil
+}
+
+// describePage 构造一页包含指定实例的查询结果
+func describePage(nextToken string, ids ...string) *ec2.DescribeInstancesOutput {
+	launchTime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	instances := make([]types.Instance, 0, len(ids))
+	for _, id := range ids {
+		instances = append(instances, types.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: types.InstanceTypeC5nLarge,
+			State:        &types.InstanceState{Name: types.InstanceStateNameRunning},
+			LaunchTime:   &launchTime,
+		})
+	}
+	output := &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: instances}}}
+	if nextToken != "" {
+		output.NextToken = aws.String(nextToken)
+	}
+	return output
+}
+
+func TestDescribeAllInstancesMergesPages(t *testing.T) {
+	client := &pagedDescribeClient{pages: map[string]*ec2.DescribeInstancesOutput{
+		"":       describePage("page-2", "i-1", "i-2"),
+		"page-2": describePage("", "i-3"),
+	}}
+
+	instances, err := describeAllInstances(context.Background(), client, &ec2.DescribeInstancesInput{})
+	if err != nil {
+		t.Fatalf("查询实例失败: %v", err)
+	}
+	var ids []string
+	for _, instance := range instances {
+		ids = append(ids, instance.InstanceID)
+	}
+	if fmt.Sprint(ids) != "[i-1 i-2 i-3]" {
+		t.Fatalf("合并后的实例 = %v, 期望两页共3台实例", ids)
+	}
+	if fmt.Sprint(client.tokens) != "[ page-2]" {
+		t.Fatalf("分页请求的NextToken = %q, 期望依次请求第一页和page-2", client.tokens)
+	}
+}
@@ -0,0 +1,42 @@
+// pkg/aws/instancetypes.go
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// describeInstanceTypesAPI DescribeInstanceTypes所需的EC2接口，便于替换实现
+type describeInstanceTypesAPI interface {
+	DescribeInstanceTypes(ctx context.Context, params *ec2.DescribeInstanceTypesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error)
+}
+
+// GetInstanceTypeVCPUs 查询区域内所有实例类型的默认vCPU数量
+func (c *AWSClient) GetInstanceTypeVCPUs(ctx context.Context, region string) (map[string]int, error) {
+	cfg, err := c.createConfig(ctx, region)
+	if err != nil {
+		return nil, fmt.Errorf("配置AWS失败: %v", err)
+	}
+	return collectInstanceTypeVCPUs(ctx, ec2.NewFromConfig(cfg))
+}
+
+// collectInstanceTypeVCPUs 分页读取DescribeInstanceTypes结果，生成实例类型到vCPU数量的映射
+func collectInstanceTypeVCPUs(ctx context.Context, client describeInstanceTypesAPI) (map[string]int, error) {
+	vcpus := make(map[string]int)
+	paginator := ec2.NewDescribeInstanceTypesPaginator(client, &ec2.DescribeInstanceTypesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("查询实例类型失败: %v", err)
+		}
+		for _, info := range page.InstanceTypes {
+			if info.VCpuInfo == nil || info.VCpuInfo.DefaultVCpus == nil {
+				continue
+			}
+			vcpus[string(info.InstanceType)] = int(*info.VCpuInfo.DefaultVCpus)
+		}
+	}
+	return vcpus, nil
+}
@@ -0,0 +1,59 @@
+// pkg/aws/limiter.go
+package aws
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// 每个账号默认允许同时进行的创建实例请求数
+const defaultMaxConcurrentCreate = 1
+
+var (
+	createSemaphores    sync.Map // AccessKey -> chan struct{}
+	addressLocks        sync.Map // AccessKey:区域 -> *sync.Mutex
+	maxConcurrentCreate int
+	maxConcurrentOnce   sync.Once
+)
+
+// getMaxConcurrentCreate 获取每个账号允许的最大并发创建数，可通过 AWS_MAX_CONCURRENT_CREATE 配置
+func getMaxConcurrentCreate() int {
+	maxConcurrentOnce.Do(func() {
+		maxConcurrentCreate = defaultMaxConcurrentCreate
+		if value := os.Getenv("AWS_MAX_CONCURRENT_CREATE"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				maxConcurrentCreate = n
+			} else {
+				log.Printf("AWS_MAX_CONCURRENT_CREATE配置无效: %s，使用默认值%d", value, defaultMaxConcurrentCreate)
+			}
+		}
+	})
+	return maxConcurrentCreate
+}
+
+// acquireCreateSlot 获取账号的创建实例并发名额，手动开机和自动补机共用
+// 返回释放函数，ctx取消时返回错误
+func (c *AWSClient) acquireCreateSlot(ctx context.Context) (func(), error) {
+	value, _ := createSemaphores.LoadOrStore(c.AccessKey, make(chan struct{}, getMaxConcurrentCreate()))
+	sem := value.(chan struct{})
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lockAddresses 锁定账号在指定区域的弹性IP操作，返回解锁函数
+// 删除实例和更换IP都会清理未关联的弹性IP，同一账号同一区域内必须串行，
+// 否则一个操作会释放另一个操作刚解绑或刚分配、尚未绑定的弹性IP
+func (c *AWSClient) lockAddresses(region string) func() {
+	value, _ := addressLocks.LoadOrStore(c.AccessKey+":"+region, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
@@ -0,0 +1,73 @@
+package aws
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireCreateSlotSerializesPerAccount(t *testing.T) {
+	client := &AWSClient{AccessKey: "AKIA-LIMITER-SERIAL"}
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := client.acquireCreateSlot(context.Background())
+			if err != nil {
+				t.Errorf("获取创建名额失败: %v", err)
+				return
+			}
+			defer release()
+
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 1 {
+		t.Fatalf("同一账号同时进行的创建数 = %d, want 1", got)
+	}
+}
+
+func TestAcquireCreateSlotHonorsContext(t *testing.T) {
+	client := &AWSClient{AccessKey: "AKIA-LIMITER-CTX"}
+
+	release, err := client.acquireCreateSlot(context.Background())
+	if err != nil {
+		t.Fatalf("获取创建名额失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.acquireCreateSlot(ctx); err == nil {
+		t.Fatal("名额被占用时第二次获取应等待至ctx超时并返回错误")
+	}
+
+	// 其他账号不受影响
+	other := &AWSClient{AccessKey: "AKIA-LIMITER-OTHER"}
+	otherRelease, err := other.acquireCreateSlot(context.Background())
+	if err != nil {
+		t.Fatalf("其他账号获取创建名额失败: %v", err)
+	}
+	otherRelease()
+
+	release()
+	again, err := client.acquireCreateSlot(context.Background())
+	if err != nil {
+		t.Fatalf("释放后再次获取创建名额失败: %v", err)
+	}
+	again()
+}
@@ -0,0 +1,14 @@
+package aws
+
+import (
+	"os"
+	"testing"
+)
+
+// 区域配置只在首次使用时加载一次，在所有测试之前登记额外区域
+const testExtraRegions = `[{"code":"us-west-2","name":"美西区","ami":"ami-usw2"},{"code":"eu-west-1","name":"欧洲区"}]`
+
+func TestMain(m *testing.M) {
+	os.Setenv("EXTRA_REGIONS", testExtraRegions)
+	os.Exit(m.Run())
+}
@@ -0,0 +1,173 @@
+// pkg/aws/quota.go
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+)
+
+// DefaultQuotaCode 标准按需实例(A, C, D, H, I, M, R, T, Z)的vCPU配额代码
+const DefaultQuotaCode = "L-1216C47A"
+
+// 配额查询结果缓存时间
+const quotaCacheTTL = 5 * time.Minute
+
+// defaultQuotaCodes 实例系列前缀到配额代码的默认映射
+var defaultQuotaCodes = map[string]string{
+	"a":   DefaultQuotaCode,
+	"c":   DefaultQuotaCode,
+	"d":   DefaultQuotaCode,
+	"h":   DefaultQuotaCode,
+	"i":   DefaultQuotaCode,
+	"m":   DefaultQuotaCode,
+	"r":   DefaultQuotaCode,
+	"t":   DefaultQuotaCode,
+	"z":   DefaultQuotaCode,
+	"f":   "L-74FC7D96", // F系列
+	"g":   "L-DB2E81BA", // G和VT系列
+	"vt":  "L-DB2E81BA",
+	"inf": "L-1945791B", // Inf系列
+	"p":   "L-417A185B", // P系列
+	"x":   "L-7295265B", // X系列
+	"dl":  "L-6E869C2A", // DL系列
+	"trn": "L-2C3B7624", // Trn系列
+	"hpc": "L-F7808C92", // HPC系列
+}
+
+// quotaCacheEntry 配额查询缓存项
+type quotaCacheEntry struct {
+	quota     string
+	expiresAt time.Time
+}
+
+var (
+	// 通过环境变量 EC2_QUOTA_CODES 配置的映射，格式: c5n=L-XXXX,p4=L-YYYY
+	quotaCodeOverrides     map[string]string
+	quotaCodeOverridesOnce sync.Once
+
+	// 按账号+配额代码缓存的查询结果
+	quotaCache   = make(map[string]quotaCacheEntry)
+	quotaCacheMu sync.Mutex
+)
+
+// loadQuotaCodeOverrides 解析 EC2_QUOTA_CODES 环境变量
+func loadQuotaCodeOverrides() {
+	quotaCodeOverrides = make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("EC2_QUOTA_CODES"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		quotaCodeOverrides[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	if len(quotaCodeOverrides) > 0 {
+		log.Printf("已加载自定义配额代码映射: %v", quotaCodeOverrides)
+	}
+}
+
+// GetQuotaCodeForInstanceType 根据实例类型获取对应的配额代码
+// 优先匹配自定义映射中的完整系列（如c5n），再匹配系列前缀（如c），最后使用标准配额代码
+func GetQuotaCodeForInstanceType(instanceType string) string {
+	quotaCodeOverridesOnce.Do(loadQuotaCodeOverrides)
+
+	family := strings.ToLower(strings.SplitN(instanceType, ".", 2)[0])
+	// 去掉第一个数字之后的部分，得到系列前缀
+	prefix := family
+	if idx := strings.IndexAny(family, "0123456789"); idx > 0 {
+		prefix = family[:idx]
+	}
+
+	if code, exists := quotaCodeOverrides[family]; exists {
+		return code
+	}
+	if code, exists := quotaCodeOverrides[prefix]; exists {
+		return code
+	}
+	if code, exists := defaultQuotaCodes[prefix]; exists {
+		return code
+	}
+	return DefaultQuotaCode
+}
+
+// GetEC2QuotaForInstanceType 查询账号对应实例类型的EC2配额
+func (c *AWSClient) GetEC2QuotaForInstanceType(ctx context.Context, instanceType string) (string, error) {
+	return c.GetEC2QuotaByCode(ctx, GetQuotaCodeForInstanceType(instanceType))
+}
+
+// RefreshEC2QuotaForInstanceType 跳过缓存直接查询实例类型对应的配额，并用结果刷新缓存
+// 用于用户主动发起的检测，避免提额后仍返回旧值
+func (c *AWSClient) RefreshEC2QuotaForInstanceType(ctx context.Context, instanceType string) (string, error) {
+	return c.fetchEC2Quota(ctx, GetQuotaCodeForInstanceType(instanceType))
+}
+
+// GetEC2QuotaByCode 按配额代码查询账号的EC2配额，结果按账号缓存一段时间
+// 适用于后台定时任务和批量查询，用户主动检测请使用 RefreshEC2QuotaForInstanceType
+func (c *AWSClient) GetEC2QuotaByCode(ctx context.Context, quotaCode string) (string, error) {
+	quotaCacheMu.Lock()
+	if entry, exists := quotaCache[c.AccessKey+":"+quotaCode]; exists && time.Now().Before(entry.expiresAt) {
+		quotaCacheMu.Unlock()
+		return entry.quota, nil
+	}
+	quotaCacheMu.Unlock()
+
+	return c.fetchEC2Quota(ctx, quotaCode)
+}
+
+// fetchEC2Quota 查询配额并写入缓存
+func (c *AWSClient) fetchEC2Quota(ctx context.Context, quotaCode string) (string, error) {
+	cacheKey := c.AccessKey + ":" + quotaCode
+
+	quota, err := c.queryEC2Quota(ctx, quotaCode)
+	if err != nil || quota == "账号已失效" {
+		return quota, err
+	}
+
+	// 只缓存成功查询到的配额
+	quotaCacheMu.Lock()
+	quotaCache[cacheKey] = quotaCacheEntry{
+		quota:     quota,
+		expiresAt: time.Now().Add(quotaCacheTTL),
+	}
+	quotaCacheMu.Unlock()
+
+	return quota, nil
+}
+
+// queryEC2Quota 直接查询指定配额代码的EC2配额，不使用缓存
+func (c *AWSClient) queryEC2Quota(ctx context.Context, quotaCode string) (string, error) {
+	// 创建美区配置用于查询配额
+	cfg, err := c.createConfig(ctx, RegionQuota)
+	if err != nil {
+		return "", fmt.Errorf("加载AWS配置失败: %v", err)
+	}
+
+	quotaClient := servicequotas.NewFromConfig(cfg)
+
+	input := &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String("ec2"),
+		QuotaCode:   aws.String(quotaCode),
+	}
+
+	result, err := quotaClient.GetServiceQuota(ctx, input)
+	if err != nil {
+		if strings.Contains(err.Error(), "UnrecognizedClientException") ||
+			strings.Contains(err.Error(), "InvalidClientTokenId") {
+			return "账号已失效", nil
+		}
+		return "", fmt.Errorf("查询配额失败: %v", err)
+	}
+
+	if result.Quota == nil || result.Quota.Value == nil {
+		return "", fmt.Errorf("未找到配额信息")
+	}
+
+	return fmt.Sprintf("%d", int(*result.Quota.Value)), nil
+}
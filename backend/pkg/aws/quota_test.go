@@ -0,0 +1,72 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetQuotaCodeForInstanceType(t *testing.T) {
+	t.Setenv("EC2_QUOTA_CODES", "p5=L-TESTP5")
+	quotaCodeOverridesOnce.Do(func() {})
+	loadQuotaCodeOverrides()
+
+	cases := []struct {
+		instanceType string
+		want         string
+	}{
+		{"c5n.large", DefaultQuotaCode},
+		{"t3.micro", DefaultQuotaCode},
+		{"g4dn.xlarge", "L-DB2E81BA"},
+		{"p5.48xlarge", "L-TESTP5"},
+		{"p4d.24xlarge", "L-417A185B"},
+		{"unknown", DefaultQuotaCode},
+	}
+	for _, tc := range cases {
+		if got := GetQuotaCodeForInstanceType(tc.instanceType); got != tc.want {
+			t.Errorf("GetQuotaCodeForInstanceType(%q) = %q, 期望 %q", tc.instanceType, got, tc.want)
+		}
+	}
+}
+
+// newQuotaServer 模拟 Service Quotas 接口，返回 value 指向的当前配额
+func newQuotaServer(t *testing.T, value *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		fmt.Fprintf(w, `{"Quota":{"Value":%d}}`, value.Load())
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	return srv
+}
+
+func TestRefreshEC2QuotaBypassesCache(t *testing.T) {
+	var value atomic.Int32
+	value.Store(32)
+	newQuotaServer(t, &value)
+
+	client := NewAWSClient("AKIAQUOTATEST", "secret")
+	ctx := context.Background()
+
+	if quota, err := client.GetEC2QuotaForInstanceType(ctx, "c5n.large"); err != nil || quota != "32" {
+		t.Fatalf("首次查询 = %q, %v", quota, err)
+	}
+
+	// 提额后，带缓存的查询仍返回旧值
+	value.Store(64)
+	if quota, _ := client.GetEC2QuotaForInstanceType(ctx, "c5n.large"); quota != "32" {
+		t.Fatalf("缓存查询 = %q, 期望仍为32", quota)
+	}
+
+	// 主动检测跳过缓存拿到新值，并刷新缓存
+	if quota, err := client.RefreshEC2QuotaForInstanceType(ctx, "c5n.large"); err != nil || quota != "64" {
+		t.Fatalf("跳过缓存查询 = %q, %v", quota, err)
+	}
+	if quota, _ := client.GetEC2QuotaForInstanceType(ctx, "c5n.large"); quota != "64" {
+		t.Fatalf("刷新后缓存查询 = %q, 期望64", quota)
+	}
+}
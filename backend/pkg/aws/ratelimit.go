@@ -0,0 +1,126 @@
+// pkg/aws/ratelimit.go
+package aws
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// 每个账号默认每秒允许的API请求数和突发数量
+const (
+	defaultAccountRateLimit = 5
+	defaultAccountRateBurst = 10
+)
+
+var (
+	accountRateLimit     float64
+	accountRateBurst     int
+	accountRateLimitOnce sync.Once
+	accountLimiters      sync.Map // AccessKey -> *accountLimiter
+)
+
+// getAccountRateLimit 获取每个账号的API请求速率，可通过 AWS_ACCOUNT_RATE_LIMIT（每秒请求数，0表示不限制）
+// 和 AWS_ACCOUNT_RATE_BURST（允许的突发请求数）配置
+func getAccountRateLimit() (float64, int) {
+	accountRateLimitOnce.Do(func() {
+		accountRateLimit = defaultAccountRateLimit
+		if value := os.Getenv("AWS_ACCOUNT_RATE_LIMIT"); value != "" {
+			if n, err := strconv.ParseFloat(value, 64); err == nil && n >= 0 {
+				accountRateLimit = n
+			} else {
+				log.Printf("AWS_ACCOUNT_RATE_LIMIT配置无效: %s，使用默认值%d", value, defaultAccountRateLimit)
+			}
+		}
+
+		accountRateBurst = defaultAccountRateBurst
+		if value := os.Getenv("AWS_ACCOUNT_RATE_BURST"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n > 0 {
+				accountRateBurst = n
+			} else {
+				log.Printf("AWS_ACCOUNT_RATE_BURST配置无效: %s，使用默认值%d", value, defaultAccountRateBurst)
+			}
+		}
+	})
+	return accountRateLimit, accountRateBurst
+}
+
+// accountLimiter 单个账号的请求节流器，允许burst个请求的突发，之后按固定间隔放行
+type accountLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // 相邻请求的最小间隔
+	burst    int
+	next     time.Time // 下一个请求理论上可以发出的时间
+}
+
+func newAccountLimiter(rate float64, burst int) *accountLimiter {
+	return &accountLimiter{
+		interval: time.Duration(float64(time.Second) / rate),
+		burst:    burst,
+	}
+}
+
+// reserve 预留一个请求名额，返回需要等待的时间
+func (l *accountLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 空闲一段时间后最多积累burst个名额
+	earliest := now.Add(-time.Duration(l.burst-1) * l.interval)
+	if l.next.Before(earliest) {
+		l.next = earliest
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// wait 等待直到可以发出请求，ctx取消时返回错误
+func (l *accountLimiter) wait(ctx context.Context) error {
+	delay := l.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// accountLimiterFor 获取账号共用的节流器，未开启限速时返回nil
+func accountLimiterFor(accessKey string) *accountLimiter {
+	rate, burst := getAccountRateLimit()
+	if rate <= 0 {
+		return nil
+	}
+	value, _ := accountLimiters.LoadOrStore(accessKey, newAccountLimiter(rate, burst))
+	return value.(*accountLimiter)
+}
+
+// rateLimitMiddleware 在每次API调用前按账号节流，检测、清理、开机、换IP等所有操作共用同一个节流器
+func (c *AWSClient) rateLimitMiddleware(stack *middleware.Stack) error {
+	limiter := accountLimiterFor(c.AccessKey)
+	if limiter == nil {
+		return nil
+	}
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("AccountRateLimit",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			if err := limiter.wait(ctx); err != nil {
+				return middleware.InitializeOutput{}, middleware.Metadata{}, err
+			}
+			return next.HandleInitialize(ctx, in)
+		}), middleware.Before)
+}
@@ -0,0 +1,96 @@
+package aws
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setAccountRateLimit 设置每个账号的请求速率，测试结束后恢复
+func setAccountRateLimit(t *testing.T, rate float64, burst int) {
+	t.Helper()
+	accountRateLimitOnce.Do(func() {})
+	oldRate, oldBurst := accountRateLimit, accountRateBurst
+	accountRateLimit, accountRateBurst = rate, burst
+	t.Cleanup(func() { accountRateLimit, accountRateBurst = oldRate, oldBurst })
+}
+
+func TestAccountLimiterReserve(t *testing.T) {
+	l := newAccountLimiter(10, 3)
+	now := time.Now()
+
+	// 突发名额用完后按100ms间隔放行
+	want := []time.Duration{0, 0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, w := range want {
+		if got := l.reserve(now); got != w {
+			t.Fatalf("第%d个请求等待 = %v, want %v", i+1, got, w)
+		}
+	}
+
+	// 空闲足够久后重新积累突发名额，但不超过burst
+	later := now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		if got := l.reserve(later); got != 0 {
+			t.Fatalf("空闲后第%d个请求等待 = %v, want 0", i+1, got)
+		}
+	}
+	if got := l.reserve(later); got != 100*time.Millisecond {
+		t.Fatalf("空闲后超出突发的请求等待 = %v, want 100ms", got)
+	}
+}
+
+func TestConcurrentCallsPacedPerAccount(t *testing.T) {
+	setAccountRateLimit(t, 20, 2)
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	newFakeEC2(t, func(action string, form url.Values) (string, error) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		return `<reservationSet/>`, nil
+	})
+
+	// 同一账号的不同客户端共用节流器
+	const calls = 6
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := NewAWSClient("AKIARATELIMIT", "secret")
+			if _, err := client.GetRunningInstanceCount(context.Background(), "ap-east-1"); err != nil {
+				t.Errorf("查询实例数量失败: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(times) != calls {
+		t.Fatalf("收到%d个请求, want %d", len(times), calls)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	// 突发2个之后每50ms放行一个，最后一个请求至少在200ms后发出
+	if elapsed := times[calls-1].Sub(start); elapsed < 180*time.Millisecond {
+		t.Fatalf("%d个并发请求在%v内全部发出, 未按速率限制", calls, elapsed)
+	}
+
+	// 不同账号使用各自的节流器，互不影响
+	if accountLimiterFor("AKIARATELIMIT2") == accountLimiterFor("AKIARATELIMIT") {
+		t.Fatal("不同账号不应共用节流器")
+	}
+	if wait := accountLimiterFor("AKIARATELIMIT2").reserve(time.Now()); wait != 0 {
+		t.Fatalf("其他账号的请求等待了%v, 不应受该账号节流影响", wait)
+	}
+
+	// 速率为0时不限速
+	setAccountRateLimit(t, 0, 2)
+	if accountLimiterFor("AKIARATELIMIT") != nil {
+		t.Fatal("速率为0时不应限速")
+	}
+}
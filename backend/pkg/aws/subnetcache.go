@@ -0,0 +1,91 @@
+// pkg/aws/subnetcache.go
+package aws
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+// 默认子网网络配置缓存时间
+const defaultSubnetCacheTTL = time.Hour
+
+// subnetCacheEntry 已完成IPv6/网关/路由配置的子网缓存
+type subnetCacheEntry struct {
+	SubnetID  string
+	ExpiresAt time.Time
+}
+
+var (
+	subnetCache     sync.Map // AccessKey:区域 -> *subnetCacheEntry
+	subnetCacheTTL  time.Duration
+	subnetCacheOnce sync.Once
+)
+
+// getSubnetCacheTTL 获取子网配置缓存时间，可通过 AWS_SUBNET_CACHE_TTL 配置，如"30m"，设为0表示不缓存
+func getSubnetCacheTTL() time.Duration {
+	subnetCacheOnce.Do(func() {
+		subnetCacheTTL = defaultSubnetCacheTTL
+		if value := os.Getenv("AWS_SUBNET_CACHE_TTL"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+				subnetCacheTTL = d
+			} else {
+				log.Printf("AWS_SUBNET_CACHE_TTL配置无效: %s，使用默认值%v", value, defaultSubnetCacheTTL)
+			}
+		}
+	})
+	return subnetCacheTTL
+}
+
+// subnetCacheKey 生成账号和区域的缓存键
+func (c *AWSClient) subnetCacheKey(region string) string {
+	return c.AccessKey + ":" + region
+}
+
+// getDefaultSubnet 获取已配置好IPv6的默认子网，同一账号区域在缓存有效期内跳过网络配置
+func (c *AWSClient) getDefaultSubnet(ctx context.Context, ec2Client *ec2.Client) (string, error) {
+	region := ec2Client.Options().Region
+	key := c.subnetCacheKey(region)
+	ttl := getSubnetCacheTTL()
+
+	if ttl > 0 {
+		if value, ok := subnetCache.Load(key); ok {
+			entry := value.(*subnetCacheEntry)
+			if time.Now().Before(entry.ExpiresAt) {
+				return entry.SubnetID, nil
+			}
+			subnetCache.Delete(key)
+		}
+	}
+
+	subnetID, err := c.setupDefaultSubnet(ctx, ec2Client)
+	if err != nil {
+		return "", err
+	}
+
+	if ttl > 0 {
+		subnetCache.Store(key, &subnetCacheEntry{
+			SubnetID:  subnetID,
+			ExpiresAt: time.Now().Add(ttl),
+		})
+	}
+	return subnetID, nil
+}
+
+// invalidateSubnetCache 清除账号区域的子网缓存，下次开机重新检查网络配置
+func (c *AWSClient) invalidateSubnetCache(region string) {
+	subnetCache.Delete(c.subnetCacheKey(region))
+}
+
+// isNetworkConfigError 判断开机失败是否可能由子网或IPv6配置引起
+func isNetworkConfigError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "InvalidSubnet") ||
+		strings.Contains(msg, "Ipv6") ||
+		strings.Contains(msg, "IPv6")
+}
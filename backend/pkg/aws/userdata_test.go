@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestBuildUserDataSkipSSHPassword(t *testing.T) {
+	sshEdits := []string{"chpasswd", "PermitRootLogin", "PasswordAuthentication", "systemctl restart sshd"}
+
+	withPassword := BuildUserData("Secret123!", false, "echo custom")
+	for _, edit := range sshEdits {
+		if !strings.Contains(withPassword, edit) {
+			t.Errorf("默认的开机脚本应包含 %q", edit)
+		}
+	}
+	if !strings.Contains(withPassword, `echo "root:Secret123!" | chpasswd`) {
+		t.Error("默认的开机脚本应设置root密码")
+	}
+
+	skipped := BuildUserData("Secret123!", true, "echo custom")
+	for _, edit := range sshEdits {
+		if strings.Contains(skipped, edit) {
+			t.Errorf("跳过SSH密码配置时开机脚本不应包含 %q", edit)
+		}
+	}
+	if strings.Contains(skipped, "Secret123!") {
+		t.Error("跳过SSH密码配置时开机脚本不应包含密码")
+	}
+	if !strings.HasSuffix(skipped, "echo custom") {
+		t.Error("跳过SSH密码配置时仍应执行自定义脚本")
+	}
+}
+
+func TestLintScriptFlagsSyntaxError(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("本机没有bash")
+	}
+
+	if warnings := LintScript("#!/bin/bash\nif true; then\n  echo ok\nfi\n"); len(warnings) != 0 {
+		t.Fatalf("语法正确的脚本不应有警告: %v", warnings)
+	}
+
+	warnings := LintScript("if true; then\n  echo broken\n")
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "语法错误: ") {
+		t.Fatalf("语法错误的脚本应被标记: %v", warnings)
+	}
+	if strings.Contains(warnings[0], os.TempDir()) {
+		t.Errorf("错误信息不应包含临时文件路径: %s", warnings[0])
+	}
+
+	warnings = LintScript("#!/usr/bin/python3\nprint('hi')\n")
+	if len(warnings) == 0 || !strings.Contains(warnings[0], "非shell解释器") {
+		t.Fatalf("非shell解释器应给出警告: %v", warnings)
+	}
+}
@@ -0,0 +1,108 @@
+// pkg/aws/volume.go
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// 附加数据盘数量和大小限制
+const (
+	maxAdditionalVolumes = 4
+	maxVolumeSize        = 16384 // GB
+)
+
+// 附加数据盘设备名格式，根盘/dev/xvda不可用
+var volumeDeviceNamePattern = regexp.MustCompile(`^/dev/(sd|xvd)[b-z]$`)
+
+// 支持的EBS卷类型
+var supportedVolumeTypes = map[string]bool{
+	"gp2":      true,
+	"gp3":      true,
+	"io1":      true,
+	"io2":      true,
+	"st1":      true,
+	"sc1":      true,
+	"standard": true,
+}
+
+// VolumeSpec 附加EBS数据盘配置
+type VolumeSpec struct {
+	DeviceName string `json:"device_name"` // 设备名，如 /dev/sdb
+	Size       int32  `json:"size"`        // 大小(GB)
+	Type       string `json:"type"`        // 卷类型，默认gp2
+}
+
+// ParseVolumeSpecs 解析并校验JSON格式的附加数据盘配置，空字符串表示没有附加数据盘
+func ParseVolumeSpecs(raw string) ([]VolumeSpec, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var specs []VolumeSpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("附加数据盘配置格式错误: %v", err)
+	}
+	if len(specs) > maxAdditionalVolumes {
+		return nil, fmt.Errorf("附加数据盘最多%d个", maxAdditionalVolumes)
+	}
+
+	seen := make(map[string]bool)
+	for i := range specs {
+		spec := &specs[i]
+		if !volumeDeviceNamePattern.MatchString(spec.DeviceName) {
+			return nil, fmt.Errorf("附加数据盘设备名无效: %s", spec.DeviceName)
+		}
+		if seen[spec.DeviceName] {
+			return nil, fmt.Errorf("附加数据盘设备名重复: %s", spec.DeviceName)
+		}
+		seen[spec.DeviceName] = true
+
+		if spec.Size <= 0 || spec.Size > maxVolumeSize {
+			return nil, fmt.Errorf("附加数据盘大小必须在1GB到%dGB之间", maxVolumeSize)
+		}
+		if spec.Type == "" {
+			spec.Type = string(types.VolumeTypeGp2)
+		}
+		if !supportedVolumeTypes[spec.Type] {
+			return nil, fmt.Errorf("不支持的卷类型: %s", spec.Type)
+		}
+	}
+
+	return specs, nil
+}
+
+// buildBlockDeviceMappings 构建根盘和附加数据盘的块设备映射
+func buildBlockDeviceMappings(rootSize int32, additional []VolumeSpec) []types.BlockDeviceMapping {
+	mappings := []types.BlockDeviceMapping{
+		{
+			DeviceName: aws.String("/dev/xvda"),
+			Ebs: &types.EbsBlockDevice{
+				VolumeSize: aws.Int32(rootSize),
+				VolumeType: types.VolumeTypeGp2,
+			},
+		},
+	}
+
+	for _, volume := range additional {
+		volumeType := volume.Type
+		if volumeType == "" {
+			volumeType = string(types.VolumeTypeGp2)
+		}
+		mappings = append(mappings, types.BlockDeviceMapping{
+			DeviceName: aws.String(volume.DeviceName),
+			Ebs: &types.EbsBlockDevice{
+				VolumeSize:          aws.Int32(volume.Size),
+				VolumeType:          types.VolumeType(volumeType),
+				DeleteOnTermination: aws.Bool(true),
+			},
+		})
+	}
+
+	return mappings
+}
@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCreateInstanceAdditionalVolumes(t *testing.T) {
+	fake := newFakeEC2(t, launchHandler)
+	client := &AWSClient{AccessKey: "AKIA-VOLUMES", SecretKey: "secret"}
+
+	volumes, err := ParseVolumeSpecs(`[{"device_name":"/dev/sdb","size":100,"type":"gp3"},{"device_name":"/dev/sdc","size":50}]`)
+	if err != nil {
+		t.Fatalf("解析附加数据盘失败: %v", err)
+	}
+	_, err = client.CreateInstance(context.Background(), CreateInstanceParams{
+		Region:            "ap-east-1",
+		ImageID:           "ami-test",
+		InstanceType:      "t3.micro",
+		Count:             1,
+		DiskSize:          20,
+		AdditionalVolumes: volumes,
+	})
+	if err != nil {
+		t.Fatalf("创建实例失败: %v", err)
+	}
+
+	runs := fake.callsFor("RunInstances")
+	if len(runs) != 1 {
+		t.Fatalf("RunInstances 调用%d次, want 1", len(runs))
+	}
+	want := map[string]string{
+		"BlockDeviceMapping.1.DeviceName":              "/dev/xvda",
+		"BlockDeviceMapping.1.Ebs.VolumeSize":          "20",
+		"BlockDeviceMapping.2.DeviceName":              "/dev/sdb",
+		"BlockDeviceMapping.2.Ebs.VolumeSize":          "100",
+		"BlockDeviceMapping.2.Ebs.VolumeType":          "gp3",
+		"BlockDeviceMapping.2.Ebs.DeleteOnTermination": "true",
+		"BlockDeviceMapping.3.DeviceName":              "/dev/sdc",
+		"BlockDeviceMapping.3.Ebs.VolumeSize":          "50",
+		"BlockDeviceMapping.3.Ebs.VolumeType":          "gp2",
+	}
+	for key, value := range want {
+		if got := runs[0].Get(key); got != value {
+			t.Errorf("RunInstances 参数 %s = %q, want %q", key, got, value)
+		}
+	}
+	if got := runs[0].Get("BlockDeviceMapping.4.DeviceName"); got != "" {
+		t.Errorf("多余的块设备映射: %s", got)
+	}
+}
+
+func TestParseVolumeSpecsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"根设备名":   `[{"device_name":"/dev/xvda","size":10}]`,
+		"设备名重复":  `[{"device_name":"/dev/sdb","size":10},{"device_name":"/dev/sdb","size":10}]`,
+		"大小为0":   `[{"device_name":"/dev/sdb","size":0}]`,
+		"不支持的类型": `[{"device_name":"/dev/sdb","size":10,"type":"nvme"}]`,
+		"格式错误":   `{"device_name":"/dev/sdb"}`,
+	}
+	for name, raw := range tests {
+		if _, err := ParseVolumeSpecs(raw); err == nil {
+			t.Errorf("%s: ParseVolumeSpecs(%s) 应返回错误", name, raw)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// pkg/config/config.go
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config 服务启动时统一加载的核心配置
+// 各功能模块的可选调优项（如并发数、缓存时长）仍由对应模块按需读取，但需在 tuning.go 中登记，启动时统一校验取值
+type Config struct {
+	AppEnv string // 运行环境，prod 时启用更严格的校验
+	WSURL  string // 实例回连使用的WebSocket地址
+
+	MySQL MySQLConfig
+	JWT   JWTConfig
+	S3    S3Config
+	Tg    TgConfig
+	Log   LogConfig
+}
+
+// MySQLConfig 数据库连接配置
+type MySQLConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Database string
+}
+
+// JWTConfig 登录令牌配置
+type JWTConfig struct {
+	Secret string
+	Expire time.Duration
+}
+
+// S3Config 数据库备份使用的S3配置
+// 与实例管理使用的账号凭证（保存在数据库中）完全独立，可放在单独的账号/区域。
+// 凭证来源三选一：静态密钥、共享配置中的 profile，或在前两者（都未设置时为默认凭证链）
+// 基础上再 AssumeRole 到备份账号的角色。
+type S3Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+	BucketName      string
+	Profile         string // ~/.aws/config 中的 profile 名称
+	RoleARN         string // 需要扮演的角色，为空则直接使用基础凭证
+	ExternalID      string // 扮演角色时的 ExternalId，可选
+}
+
+// TgConfig Telegram通知配置
+type TgConfig struct {
+	BotToken  string
+	ParseMode string // 小写形式：markdownv2、html、markdown、none，空表示默认
+}
+
+// LogConfig 日志输出配置
+type LogConfig struct {
+	Path          string
+	MaxSize       int // 单个日志文件大小上限，单位MB
+	ConsoleOutput bool
+}
+
+const (
+	defaultJWTSecret = "default_jwt_secret_for_development"
+	defaultJWTExpire = 24 * time.Hour
+)
+
+var (
+	current  *Config
+	loadErr  error
+	loadOnce sync.Once
+)
+
+// Load 加载并校验配置，只在第一次调用时真正读取环境变量
+// 必填项缺失或取值非法时返回错误，调用方应直接终止启动
+func Load() (*Config, error) {
+	loadOnce.Do(func() {
+		loadEnvFile()
+		current, loadErr = fromEnv()
+	})
+	return current, loadErr
+}
+
+// Get 返回已加载的配置，未加载时按需加载
+// 校验失败时仍返回带默认值的配置，是否终止由 Load 的调用方决定
+func Get() *Config {
+	cfg, _ := Load()
+	return cfg
+}
+
+// loadEnvFile 系统环境变量未配置时加载上级目录的.env文件，已存在的变量不会被覆盖
+func loadEnvFile() {
+	if os.Getenv("WS_URL") != "" {
+		return
+	}
+	currentDir, _ := os.Getwd()
+	envPath := filepath.Join(filepath.Dir(currentDir), ".env")
+	_ = godotenv.Load(envPath)
+}
+
+// fromEnv 从环境变量解析配置，所有校验错误合并返回
+func fromEnv() (*Config, error) {
+	var errs []error
+
+	cfg := &Config{
+		AppEnv: os.Getenv("APP_ENV"),
+		WSURL:  os.Getenv("WS_URL"),
+		MySQL: MySQLConfig{
+			Host:     os.Getenv("MYSQL_HOST"),
+			Port:     envOrDefault("MYSQL_PORT", "3306"),
+			Username: os.Getenv("MYSQL_USERNAME"),
+			Password: os.Getenv("MYSQL_PASSWORD"),
+			Database: os.Getenv("MYSQL_DATABASE"),
+		},
+		JWT: JWTConfig{
+			Secret: os.Getenv("JWT_SECRET"),
+			Expire: defaultJWTExpire,
+		},
+		S3: S3Config{
+			// 优先使用 BACKUP_AWS_* 专用变量，未设置时兼容旧的 AWS_* 变量
+			AccessKeyID:     envOrDefault("BACKUP_AWS_ACCESS_KEY_ID", os.Getenv("AWS_ACCESS_KEY_ID")),
+			SecretAccessKey: envOrDefault("BACKUP_AWS_SECRET_ACCESS_KEY", os.Getenv("AWS_SECRET_ACCESS_KEY")),
+			Region:          envOrDefault("BACKUP_AWS_REGION", os.Getenv("AWS_DEFAULT_REGION")),
+			BucketName:      envOrDefault("BACKUP_BUCKET_NAME", os.Getenv("BUCKET_NAME")),
+			Profile:         os.Getenv("BACKUP_AWS_PROFILE"),
+			RoleARN:         os.Getenv("BACKUP_AWS_ROLE_ARN"),
+			ExternalID:      os.Getenv("BACKUP_AWS_EXTERNAL_ID"),
+		},
+		Tg: TgConfig{
+			BotToken:  os.Getenv("TG_BOT_TOKEN"),
+			ParseMode: strings.ToLower(os.Getenv("TG_PARSE_MODE")),
+		},
+		Log: LogConfig{
+			Path:          envOrDefault("LOG_PATH", "logs/portal.log"),
+			MaxSize:       10,
+			ConsoleOutput: os.Getenv("LOG_CONSOLE_OUTPUT") != "false",
+		},
+	}
+
+	for _, key := range []string{"MYSQL_HOST", "MYSQL_USERNAME", "MYSQL_DATABASE"} {
+		if os.Getenv(key) == "" {
+			errs = append(errs, fmt.Errorf("%s 未设置", key))
+		}
+	}
+	if _, err := strconv.Atoi(cfg.MySQL.Port); err != nil {
+		errs = append(errs, fmt.Errorf("MYSQL_PORT 不是有效端口: %s", cfg.MySQL.Port))
+	}
+
+	if cfg.JWT.Secret == "" {
+		if cfg.IsProd() {
+			errs = append(errs, errors.New("生产环境必须设置 JWT_SECRET"))
+		} else {
+			log.Printf("警告: JWT_SECRET 环境变量未设置，使用默认值")
+		}
+		cfg.JWT.Secret = defaultJWTSecret
+	}
+	if value := os.Getenv("JWT_EXPIRE"); value != "" {
+		expire, err := time.ParseDuration(value)
+		if err != nil || expire <= 0 {
+			errs = append(errs, fmt.Errorf("JWT_EXPIRE 配置无效: %s", value))
+		} else {
+			cfg.JWT.Expire = expire
+		}
+	}
+
+	if cfg.IsProd() && cfg.S3.BucketName == "" {
+		errs = append(errs, errors.New("生产环境必须设置 BUCKET_NAME 用于数据库备份"))
+	}
+	if (cfg.S3.AccessKeyID == "") != (cfg.S3.SecretAccessKey == "") {
+		errs = append(errs, errors.New("备份S3的 AccessKey 与 SecretKey 必须同时设置"))
+	}
+	if cfg.S3.AccessKeyID != "" && cfg.S3.Profile != "" {
+		errs = append(errs, errors.New("BACKUP_AWS_PROFILE 不能与备份静态密钥同时设置"))
+	}
+	if cfg.S3.ExternalID != "" && cfg.S3.RoleARN == "" {
+		errs = append(errs, errors.New("设置 BACKUP_AWS_EXTERNAL_ID 时必须同时设置 BACKUP_AWS_ROLE_ARN"))
+	}
+
+	switch cfg.Tg.ParseMode {
+	case "", "markdownv2", "html", "markdown", "none":
+	default:
+		errs = append(errs, fmt.Errorf("TG_PARSE_MODE 配置无效: %s", cfg.Tg.ParseMode))
+	}
+
+	if value := os.Getenv("LOG_MAX_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			errs = append(errs, fmt.Errorf("LOG_MAX_SIZE 配置无效: %s", value))
+		} else {
+			cfg.Log.MaxSize = size
+		}
+	}
+
+	errs = append(errs, validateTuning()...)
+
+	return cfg, errors.Join(errs...)
+}
+
+// IsProd 是否为生产环境
+func (c *Config) IsProd() bool {
+	return c.AppEnv == "prod"
+}
+
+// LogSummary 输出生效配置，密钥类字段只显示是否已设置
+func (c *Config) LogSummary() {
+	log.Printf("生效配置: APP_ENV=%s WS_URL=%s", c.AppEnv, c.WSURL)
+	log.Printf("数据库: %s@%s:%s/%s 密码=%s",
+		c.MySQL.Username, c.MySQL.Host, c.MySQL.Port, c.MySQL.Database, mask(c.MySQL.Password))
+	log.Printf("JWT: 有效期=%v 密钥=%s", c.JWT.Expire, mask(c.JWT.Secret))
+	log.Printf("备份S3: 区域=%s 存储桶=%s AccessKey=%s SecretKey=%s Profile=%s 角色=%s",
+		c.S3.Region, c.S3.BucketName, mask(c.S3.AccessKeyID), mask(c.S3.SecretAccessKey), c.S3.Profile, c.S3.RoleARN)
+	log.Printf("TG: 解析模式=%s Token=%s", c.Tg.ParseMode, mask(c.Tg.BotToken))
+	log.Printf("日志: 路径=%s 单文件上限=%dMB 控制台输出=%v", c.Log.Path, c.Log.MaxSize, c.Log.ConsoleOutput)
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return "未设置"
+	}
+	return "已设置"
+}
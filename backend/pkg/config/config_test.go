@@ -0,0 +1,112 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// setValidEnv 设置一组能通过校验的环境变量，其余相关变量清空
+func setValidEnv(t *testing.T) {
+	t.Helper()
+	for key, value := range map[string]string{
+		"APP_ENV":                      "",
+		"MYSQL_HOST":                   "127.0.0.1",
+		"MYSQL_PORT":                   "",
+		"MYSQL_USERNAME":               "portal",
+		"MYSQL_DATABASE":               "portal",
+		"JWT_SECRET":                   "secret",
+		"JWT_EXPIRE":                   "",
+		"BACKUP_AWS_ACCESS_KEY_ID":     "",
+		"BACKUP_AWS_SECRET_ACCESS_KEY": "",
+		"AWS_ACCESS_KEY_ID":            "",
+		"AWS_SECRET_ACCESS_KEY":        "",
+		"BACKUP_BUCKET_NAME":           "",
+		"BUCKET_NAME":                  "",
+		"BACKUP_AWS_PROFILE":           "",
+		"BACKUP_AWS_ROLE_ARN":          "",
+		"BACKUP_AWS_EXTERNAL_ID":       "",
+		"TG_PARSE_MODE":                "",
+		"LOG_MAX_SIZE":                 "",
+	} {
+		t.Setenv(key, value)
+	}
+	for _, knob := range tuningKnobs {
+		t.Setenv(knob.Key, "")
+	}
+}
+
+func TestFromEnvDefaults(t *testing.T) {
+	setValidEnv(t)
+
+	cfg, err := fromEnv()
+	if err != nil {
+		t.Fatalf("有效配置不应报错: %v", err)
+	}
+	if cfg.MySQL.Port != "3306" || cfg.JWT.Expire != defaultJWTExpire || cfg.Log.MaxSize != 10 {
+		t.Fatalf("默认值不正确: %+v", cfg)
+	}
+
+	t.Setenv("JWT_EXPIRE", "2h")
+	t.Setenv("TG_PARSE_MODE", "HTML")
+	// 合法的调优项不报错
+	t.Setenv("MAKEUP_WAIT_REPORT", "3m")
+	t.Setenv("MAKEUP_FAILURE_NOTIFY", "user, admin")
+	t.Setenv("REGION_FAMILY_CAPS", "t3=10,c5=0")
+	t.Setenv("EXTRA_REGIONS", `[{"code":"ap-south-1","name":"孟买"}]`)
+	t.Setenv("MAX_THRESHOLD_AP_SOUTH_1", "20")
+	if cfg, err = fromEnv(); err != nil || cfg.JWT.Expire != 2*time.Hour || cfg.Tg.ParseMode != "html" {
+		t.Fatalf("fromEnv() = %+v, %v", cfg, err)
+	}
+}
+
+func TestFromEnvValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr []string
+	}{
+		{"缺少必填项", map[string]string{"MYSQL_HOST": "", "MYSQL_DATABASE": ""}, []string{"MYSQL_HOST 未设置", "MYSQL_DATABASE 未设置"}},
+		{"端口不是数字", map[string]string{"MYSQL_PORT": "abc"}, []string{"MYSQL_PORT 不是有效端口"}},
+		{"有效期格式错误", map[string]string{"JWT_EXPIRE": "tomorrow"}, []string{"JWT_EXPIRE 配置无效"}},
+		{"有效期非正数", map[string]string{"JWT_EXPIRE": "-1h"}, []string{"JWT_EXPIRE 配置无效"}},
+		{"生产环境缺少密钥和存储桶", map[string]string{"APP_ENV": "prod", "JWT_SECRET": ""}, []string{"JWT_SECRET", "BUCKET_NAME"}},
+		{"备份密钥不完整", map[string]string{"BACKUP_AWS_ACCESS_KEY_ID": "AKIA"}, []string{"AccessKey 与 SecretKey"}},
+		{"profile与静态密钥冲突", map[string]string{"BACKUP_AWS_ACCESS_KEY_ID": "AKIA", "BACKUP_AWS_SECRET_ACCESS_KEY": "secret", "BACKUP_AWS_PROFILE": "backup"}, []string{"BACKUP_AWS_PROFILE"}},
+		{"ExternalId缺少角色", map[string]string{"BACKUP_AWS_EXTERNAL_ID": "ext"}, []string{"BACKUP_AWS_ROLE_ARN"}},
+		{"解析模式无效", map[string]string{"TG_PARSE_MODE": "bbcode"}, []string{"TG_PARSE_MODE 配置无效"}},
+		{"日志大小无效", map[string]string{"LOG_MAX_SIZE": "0"}, []string{"LOG_MAX_SIZE 配置无效"}},
+		{"调优项取值无效", map[string]string{
+			"MAKEUP_RETRY_BUDGET":      "0",
+			"POOL_DEBUG_ENDPOINTS":     "yes",
+			"MAKEUP_FAILURE_NOTIFY":    "user,ops",
+			"INSTANCE_OFFLINE_TIMEOUT": "60",
+			"REGION_FAMILY_CAPS":       "t3",
+			"EXTRA_REGIONS":            `[{"name":"孟买"}]`,
+			"MAX_THRESHOLD_AP_SOUTH_1": "-1",
+		}, []string{"MAKEUP_RETRY_BUDGET", "POOL_DEBUG_ENDPOINTS", "MAKEUP_FAILURE_NOTIFY", "INSTANCE_OFFLINE_TIMEOUT", "REGION_FAMILY_CAPS", "EXTRA_REGIONS", "MAX_THRESHOLD_AP_SOUTH_1"}},
+		{"硬盘上下限颠倒", map[string]string{"DISK_SIZE_MIN": "100", "DISK_SIZE_MAX": "50"}, []string{"DISK_SIZE_MAX(50) 不能小于 DISK_SIZE_MIN(100)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setValidEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := fromEnv()
+			if err == nil {
+				t.Fatal("非法配置应返回错误")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("错误 %q 应包含 %q", err, want)
+				}
+			}
+			// 校验失败时仍返回带默认值的配置
+			if cfg == nil || cfg.JWT.Secret == "" {
+				t.Fatalf("校验失败时应返回带默认值的配置: %+v", cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// pkg/config/tuning.go
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tuningKnob 各功能模块按需读取的可选调优项
+// 模块在取值非法时会回退到默认值，这里在启动时统一校验，避免配置错误被静默忽略
+type tuningKnob struct {
+	Key   string
+	Check func(value string) error
+}
+
+// tuningKnobs 已登记的调优项，未设置（空值）时使用模块默认值，不做校验
+var tuningKnobs = []tuningKnob{
+	// 补机队列与检测
+	{"GLOBAL_MAX_INSTANCES", intAtLeast(0)},
+	{"MAKEUP_TASK_MAX_AGE", durationAtLeast(0)},
+	{"MAKEUP_REGION_CONCURRENCY", intAtLeast(1)},
+	{"MAKEUP_RETRY_BUDGET", intAtLeast(1)},
+	{"MAKEUP_WAIT_REPORT", durationAtLeast(0)},
+	{"MAKEUP_RETRY_UNREACHABLE", boolValue},
+	{"MAKEUP_SUPPRESS_ONLINE_NOTIFY", boolValue},
+	{"MAKEUP_FAILURE_NOTIFY", listOf("user", "admin", "both")},
+	{"MAX_THRESHOLD", intAtLeast(0)},
+	{"DETECTOR_COUNT_COMPLIANT_ONLY", boolValue},
+	{"EXTRA_REGIONS", extraRegions},
+	{"REGION_FAMILY_CAPS", keyValues(intAtLeast(0))},
+
+	// 连接池与实例状态
+	{"POOL_STARTUP_GRACE", durationAtLeast(0)},
+	{"POOL_DEBUG_ENDPOINTS", boolValue},
+	{"POOL_EXCLUDE_QUERY_FAILED", boolValue},
+	{"POOL_OFFLINE_RETENTION", durationAtLeast(0)},
+	{"INSTANCE_OFFLINE_TIMEOUT", durationAbove(0)},
+	{"INSTANCE_DEGRADED_TIMEOUT", durationAtLeast(0)},
+	{"INSTANCE_DEGRADED_NOTIFY", boolValue},
+	{"VM_COUNT_RECONCILE_INTERVAL", durationAbove(0)},
+	{"WS_READ_BUFFER_SIZE", intAtLeast(1)},
+	{"WS_WRITE_BUFFER_SIZE", intAtLeast(1)},
+	{"WS_MAX_MESSAGE_SIZE", intAtLeast(1)},
+
+	// IP段检测
+	{"IPRANGE_CHANGEIP_RATE", intAtLeast(1)},
+	{"IPRANGE_CHANGEIP_BURST", intAtLeast(1)},
+	{"IPRANGE_ROTATE_COOLDOWN", durationAtLeast(0)},
+
+	// 账号检测
+	{"ACCOUNT_CHECK_RETRIES", intBetween(0, 5)},
+	{"ACCOUNT_CHECK_STAGGER_WINDOW", durationAtLeast(0)},
+	{"ACCOUNT_RECHECK_INTERVAL", durationAbove(0)},
+	{"ACCOUNT_RECHECK_MIN_AGE", durationAtLeast(0)},
+	{"EC2_QUOTA_CODES", keyValues(nonEmpty)},
+
+	// AWS接口
+	{"AWS_ACCOUNT_RATE_LIMIT", floatAtLeast(0)},
+	{"AWS_ACCOUNT_RATE_BURST", intAtLeast(1)},
+	{"AWS_MAX_CONCURRENT_CREATE", intAtLeast(1)},
+	{"AWS_SUBNET_CACHE_TTL", durationAtLeast(0)},
+	{"AMI_STRICT_REGION", boolValue},
+	{"INSTANCE_VCPU_SOURCE", oneOf("aws")},
+	{"DISK_SIZE_MIN", intAtLeast(1)},
+	{"DISK_SIZE_MAX", intAtLeast(1)},
+
+	// 数据库备份
+	{"BACKUP_COMMAND_TIMEOUT", durationAtLeast(0)},
+	{"BACKUP_RETRY_COUNT", intAtLeast(0)},
+	{"BACKUP_RESTORE_SCRATCH_CHECK", boolValue},
+}
+
+// tuningPrefixes 按区域展开的调优项，键名为前缀加区域代码
+var tuningPrefixes = []tuningKnob{
+	{"MAX_THRESHOLD_", intAtLeast(0)},
+}
+
+// validateTuning 校验已设置的调优项，返回所有取值非法的错误
+func validateTuning() []error {
+	var errs []error
+	for _, knob := range tuningKnobs {
+		value := os.Getenv(knob.Key)
+		if value == "" {
+			continue
+		}
+		if err := knob.Check(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s 配置无效: %s（%v）", knob.Key, value, err))
+		}
+	}
+
+	// 按区域展开的调优项无法逐个列出，遍历环境变量匹配前缀
+	environ := os.Environ()
+	sort.Strings(environ)
+	for _, entry := range environ {
+		key, value, _ := strings.Cut(entry, "=")
+		for _, knob := range tuningPrefixes {
+			if !strings.HasPrefix(key, knob.Key) || value == "" {
+				continue
+			}
+			if err := knob.Check(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s 配置无效: %s（%v）", key, value, err))
+			}
+		}
+	}
+
+	if minValue, maxValue := os.Getenv("DISK_SIZE_MIN"), os.Getenv("DISK_SIZE_MAX"); minValue != "" && maxValue != "" {
+		minSize, minErr := strconv.Atoi(minValue)
+		maxSize, maxErr := strconv.Atoi(maxValue)
+		if minErr == nil && maxErr == nil && maxSize < minSize {
+			errs = append(errs, fmt.Errorf("DISK_SIZE_MAX(%d) 不能小于 DISK_SIZE_MIN(%d)", maxSize, minSize))
+		}
+	}
+	return errs
+}
+
+func intAtLeast(min int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("不是整数")
+		}
+		if n < min {
+			return fmt.Errorf("不能小于%d", min)
+		}
+		return nil
+	}
+}
+
+func intBetween(min, max int) func(string) error {
+	return func(value string) error {
+		if err := intAtLeast(min)(value); err != nil {
+			return err
+		}
+		if n, _ := strconv.Atoi(strings.TrimSpace(value)); n > max {
+			return fmt.Errorf("不能大于%d", max)
+		}
+		return nil
+	}
+}
+
+func floatAtLeast(min float64) func(string) error {
+	return func(value string) error {
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("不是数字")
+		}
+		if n < min {
+			return fmt.Errorf("不能小于%v", min)
+		}
+		return nil
+	}
+}
+
+func durationAtLeast(min time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("不是有效的时长，如 30s、10m、2h")
+		}
+		if d < min {
+			return fmt.Errorf("不能小于%v", min)
+		}
+		return nil
+	}
+}
+
+func durationAbove(min time.Duration) func(string) error {
+	return func(value string) error {
+		if err := durationAtLeast(min)(value); err != nil {
+			return err
+		}
+		if d, _ := time.ParseDuration(strings.TrimSpace(value)); d <= min {
+			return fmt.Errorf("必须大于%v", min)
+		}
+		return nil
+	}
+}
+
+// boolValue 开关类配置只接受 true 或 false，其他取值会被模块当作 false 处理
+func boolValue(value string) error {
+	if value != "true" && value != "false" {
+		return fmt.Errorf("只能为 true 或 false")
+	}
+	return nil
+}
+
+func nonEmpty(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("不能为空")
+	}
+	return nil
+}
+
+func oneOf(options ...string) func(string) error {
+	return func(value string) error {
+		for _, option := range options {
+			if value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("可选值为 %s", strings.Join(options, "、"))
+	}
+}
+
+// listOf 逗号分隔的列表，每一项都必须是可选值之一
+func listOf(options ...string) func(string) error {
+	return func(value string) error {
+		for _, item := range strings.Split(value, ",") {
+			if err := oneOf(options...)(strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// keyValues 逗号分隔的 key=value 列表，值由check校验
+func keyValues(check func(string) error) func(string) error {
+	return func(value string) error {
+		for _, item := range strings.Split(value, ",") {
+			key, val, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("配置项[%s]应为 key=value 格式", item)
+			}
+			if err := check(strings.TrimSpace(val)); err != nil {
+				return fmt.Errorf("配置项[%s]%v", item, err)
+			}
+		}
+		return nil
+	}
+}
+
+// extraRegions 额外区域为JSON数组，每个区域必须填写区域代码
+func extraRegions(value string) error {
+	var regions []struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(value), &regions); err != nil {
+		return fmt.Errorf("不是有效的JSON数组: %v", err)
+	}
+	for i, r := range regions {
+		if strings.TrimSpace(r.Code) == "" {
+			return fmt.Errorf("第%d个区域未填写区域代码", i+1)
+		}
+	}
+	return nil
+}
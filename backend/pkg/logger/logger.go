@@ -2,14 +2,18 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"portal/pkg/config"
 
 	"gopkg.in/natefinch/lumberjack.v2"
 )
@@ -27,50 +31,9 @@ var (
 
 // 自动初始化
 func init() {
-	// 加载环境变量(如果还没加载)
-	if os.Getenv("LOG_PATH") == "" && os.Getenv("MYSQL_HOST") == "" {
-		// 尝试加载.env文件
-		currentDir, _ := os.Getwd()
-		parentDir := filepath.Dir(currentDir)
-		envPath := filepath.Join(parentDir, ".env")
-
-		if _, err := os.Stat(envPath); err == nil {
-			// 读取并设置环境变量，但不覆盖已存在的变量
-			if data, err := os.ReadFile(envPath); err == nil {
-				lines := string(data)
-				for _, line := range filepath.SplitList(lines) {
-					if line == "" || line[0] == '#' {
-						continue
-					}
-					parts := strings.SplitN(line, "=", 2)
-					if len(parts) != 2 {
-						continue
-					}
-					if os.Getenv(parts[0]) == "" {
-						os.Setenv(parts[0], parts[1])
-					}
-				}
-			}
-		}
-	}
-
-	// 从环境变量获取配置
-	logPath := os.Getenv("LOG_PATH")
-	if logPath == "" {
-		logPath = "logs/portal.log"
-	}
-
-	maxSize, _ := strconv.Atoi(os.Getenv("LOG_MAX_SIZE"))
-	if maxSize <= 0 {
-		maxSize = 10 // 默认10MB
-	}
-
-	consoleOutput := true
-	if os.Getenv("LOG_CONSOLE_OUTPUT") == "false" {
-		consoleOutput = false
-	}
-
-	initLogger(logPath, maxSize, consoleOutput)
+	// 日志配置由配置模块统一加载（含.env文件），校验失败由main终止启动
+	logCfg := config.Get().Log
+	initLogger(logCfg.Path, logCfg.MaxSize, logCfg.ConsoleOutput)
 }
 
 // 初始化日志器
@@ -150,6 +113,155 @@ func Error(format string, args ...interface{}) {
 	GetLogger().Error(format, args...)
 }
 
+// RequestIDKey 请求ID在 gin.Context 中的键名（c.Set/c.Get 使用）
+const RequestIDKey = "request_id"
+
+// requestIDCtxKey 请求ID在标准 context 中的键，使用未导出类型避免与其他包冲突
+type requestIDCtxKey struct{}
+
+// WithRequestID 返回携带请求ID的 context，供传给 service 层的 c.Request.Context() 使用
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, requestID)
+}
+
+// RequestIDFromContext 从上下文中获取请求ID，不存在时返回空字符串
+// 同时支持 c.Request.Context() 和直接传入的 gin.Context（通过 c.Set 设置的值）
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if requestID, ok := ctx.Value(requestIDCtxKey{}).(string); ok {
+		return requestID
+	}
+	if requestID, ok := ctx.Value(RequestIDKey).(string); ok {
+		return requestID
+	}
+	return ""
+}
+
+// Printf 记录带请求ID前缀的日志，便于串联同一请求的所有日志
+func Printf(ctx context.Context, format string, args ...interface{}) {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		format = "[req:" + requestID + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// LogFileInfo 日志文件信息
+type LogFileInfo struct {
+	Name    string    `json:"name"`     // 文件名
+	Size    int64     `json:"size"`     // 文件大小(字节)
+	ModTime time.Time `json:"mod_time"` // 最后修改时间
+	Current bool      `json:"current"`  // 是否为当前正在写入的日志文件
+}
+
+// LogFilePath 获取当前日志文件路径
+func LogFilePath() string {
+	return GetLogger().writer.Filename
+}
+
+// ListLogFiles 列出日志目录下当前日志及轮转后的日志文件，按修改时间倒序
+func ListLogFiles() ([]LogFileInfo, error) {
+	current := LogFilePath()
+	dir := filepath.Dir(current)
+	base := filepath.Base(current)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-" // lumberjack轮转文件格式: name-时间戳.ext
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %v", err)
+	}
+
+	var files []LogFileInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || (name != base && !strings.HasPrefix(name, prefix)) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, LogFileInfo{
+			Name:    name,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Current: name == base,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	return files, nil
+}
+
+// ResolveLogFile 将文件名解析为日志目录下的完整路径，只允许访问日志文件列表中的文件
+// 文件名为空时返回当前日志文件
+func ResolveLogFile(name string) (string, error) {
+	current := LogFilePath()
+	if name == "" {
+		return current, nil
+	}
+	// 只接受纯文件名，防止路径穿越
+	if filepath.Base(name) != name || name == "." || name == ".." {
+		return "", fmt.Errorf("非法的日志文件名: %s", name)
+	}
+
+	files, err := ListLogFiles()
+	if err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		if file.Name == name {
+			return filepath.Join(filepath.Dir(current), name), nil
+		}
+	}
+	return "", fmt.Errorf("日志文件不存在: %s", name)
+}
+
+// TailLogFile 读取日志文件的最后n行
+func TailLogFile(path string, n int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("获取日志文件信息失败: %v", err)
+	}
+
+	// 从文件末尾按块向前读取，直到读到足够的行数
+	const chunkSize = 64 * 1024
+	offset := info.Size()
+	var data []byte
+	for offset > 0 && strings.Count(string(data), "\n") <= n {
+		readSize := int64(chunkSize)
+		if offset < readSize {
+			readSize = offset
+		}
+		offset -= readSize
+
+		chunk := make([]byte, readSize)
+		if _, err := file.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("读取日志文件失败: %v", err)
+		}
+		data = append(chunk, data...)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return []string{}, nil
+	}
+	return lines, nil
+}
+
 // Close 关闭日志文件
 func Close() error {
 	if instance != nil && instance.writer != nil {
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTailLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	// 写入超过一个读取块大小的内容，确保跨块读取时行不被截断
+	var b strings.Builder
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintf(&b, "2026/01/01 00:00:00 第%d行日志\n", i)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("写入日志文件失败: %v", err)
+	}
+
+	lines, err := TailLogFile(path, 3)
+	if err != nil {
+		t.Fatalf("读取日志失败: %v", err)
+	}
+	want := []string{"2026/01/01 00:00:00 第4998行日志", "2026/01/01 00:00:00 第4999行日志", "2026/01/01 00:00:00 第5000行日志"}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("最后3行 = %q, want %q", lines, want)
+	}
+
+	lines, err = TailLogFile(path, 3000)
+	if err != nil || len(lines) != 3000 || lines[0] != "2026/01/01 00:00:00 第2001行日志" {
+		t.Fatalf("最后3000行: 共%d行, 首行 %q, err %v", len(lines), lines[0], err)
+	}
+
+	lines, err = TailLogFile(path, 10000)
+	if err != nil || len(lines) != 5000 {
+		t.Fatalf("请求行数超过文件行数时应返回全部%d行, 得到%d行, err %v", 5000, len(lines), err)
+	}
+}
+
+func TestTailLogFileEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.log")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("写入日志文件失败: %v", err)
+	}
+	lines, err := TailLogFile(path, 10)
+	if err != nil || len(lines) != 0 {
+		t.Fatalf("空日志 = %q, %v, 期望空列表", lines, err)
+	}
+
+	if _, err := TailLogFile(filepath.Join(t.TempDir(), "missing.log"), 10); err == nil {
+		t.Fatal("日志文件不存在时应返回错误")
+	}
+}
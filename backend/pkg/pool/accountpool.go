@@ -4,12 +4,14 @@ package pool
 import (
 	"fmt"
 	"log"
+	"os"
 	"sort"
 	"strconv"
 	"sync"
 	"time"
 
 	"portal/model"
+	"portal/pkg/region"
 	"portal/repository"
 )
 
@@ -24,19 +26,58 @@ type AccountInfo struct {
 	Quatos               *string         // 配额
 	HK                   *string         // HK区状态
 	VMCount              *int            // 实例数量
+	VMCountRegion        *string         // 实例数量统计所在的区域
 	Region               *string         // 区域代码
 	CreateTime           *time.Time      // 创建时间
 	IsSkipped            bool            // 是否需要跳过该账号（例如曾经使用失败）
 	ErrorNote            string          // 错误备注，记录失败原因
 	SkippedInstanceTypes map[string]bool // 标记特定实例类型是否需要跳过（例如配额用尽）
-	RegionUsedCount      int             // 当前区域已使用的实例计数
+	FamilyUsedCount      map[string]int  // 当前区域各实例族已使用的实例计数
+}
+
+// RegionCode 获取账号的区域代码，未设置区域时视为默认的香港区域，与数据库字段默认值一致
+func (a *AccountInfo) RegionCode() string {
+	if a.Region == nil || *a.Region == "" {
+		return region.HK
+	}
+	return *a.Region
+}
+
+// 每个账号在区域内每个实例族默认可使用的最大实例计数
+const maxRegionUsedCount = 4
+
+var (
+	excludeQueryFailed     bool
+	excludeQueryFailedOnce sync.Once
+)
+
+// shouldExcludeQueryFailed 加载账号池时是否排除检测结果为"查询失败"的账号
+// 通过 POOL_EXCLUDE_QUERY_FAILED=true 开启，默认不排除，只排除"账号已失效"的账号
+func shouldExcludeQueryFailed() bool {
+	excludeQueryFailedOnce.Do(func() {
+		excludeQueryFailed = os.Getenv("POOL_EXCLUDE_QUERY_FAILED") == "true"
+		if excludeQueryFailed {
+			log.Printf("账号池将排除检测结果为查询失败的账号")
+		}
+	})
+	return excludeQueryFailed
+}
+
+// EligibleAccount 可承接开机的账号预览信息
+type EligibleAccount struct {
+	AccountID       string `json:"account_id"`        // 账号ID
+	UserID          string `json:"user_id"`           // 用户ID
+	RegionUsedCount int    `json:"region_used_count"` // 当前区域该实例族已使用的实例计数
+	Capacity        int    `json:"capacity"`          // 还可开启的该类型实例数量
+	Planned         int    `json:"planned"`           // 按当前选择顺序预计分配的实例数量
 }
 
 // AccountPool 管理可用AWS账号的内存池
 type AccountPool struct {
-	accounts   map[string]*AccountInfo // 以ID为键的账号映射
-	mutex      sync.RWMutex            // 读写锁保护并发访问
-	lastUsedID string                  // 记录上次使用的账号ID，用于循环获取
+	accounts   map[string]*AccountInfo           // 以ID为键的账号映射
+	mutex      sync.RWMutex                      // 读写锁保护并发访问
+	lastUsedID string                            // 记录上次使用的账号ID，用于循环获取
+	failures   map[string][]AccountFailureRecord // 账号ID -> 最近的开机失败记录
 }
 
 // 全局单例实例
@@ -80,6 +121,7 @@ func NewAccountPool() *AccountPool {
 	return &AccountPool{
 		accounts:   make(map[string]*AccountInfo),
 		lastUsedID: "",
+		failures:   make(map[string][]AccountFailureRecord),
 	}
 }
 
@@ -103,15 +145,22 @@ func (p *AccountPool) LoadAccountsFromDB() error {
 
 	// 从数据库获取所有有效账号
 	var accounts []model.Account
-	err := db.Where("(quatos != '账号已失效' OR quatos IS NULL)").
-		Order("id ASC"). // 按照账号ID升序排列
-		Find(&accounts).Error
+	query := db.Where("(quatos != '账号已失效' OR quatos IS NULL)").
+		Where("(enabled = ? OR enabled IS NULL)", true) // 跳过已停用的账号
+	if shouldExcludeQueryFailed() {
+		query = query.Where("(quatos != '查询失败' OR quatos IS NULL)")
+	}
+	err := query.Order("id ASC").Find(&accounts).Error // 按照账号ID升序排列
 	if err != nil {
 		return err
 	}
 
 	// 加载到内存池
+	missingRegion := 0
 	for _, account := range accounts {
+		if account.Region == nil || *account.Region == "" {
+			missingRegion++
+		}
 		p.accounts[account.ID] = &AccountInfo{
 			ID:                   account.ID,
 			UserID:               account.UserID,
@@ -122,15 +171,20 @@ func (p *AccountPool) LoadAccountsFromDB() error {
 			Quatos:               account.Quatos,
 			HK:                   account.HK,
 			VMCount:              account.VMCount,
+			VMCountRegion:        account.VMCountRegion,
 			Region:               account.Region,
 			CreateTime:           account.CreateTime,
 			IsSkipped:            false,
 			ErrorNote:            "",
 			SkippedInstanceTypes: make(map[string]bool), // 初始化为空映射
-			RegionUsedCount:      0,                     // 初始化实例计数为0
+			FamilyUsedCount:      make(map[string]int),  // 初始化实例计数为0
 		}
 	}
 
+	if missingRegion > 0 {
+		log.Printf("警告: 有%d个账号未设置区域，按默认区域[%s]参与选择", missingRegion, region.HK)
+	}
+
 	// 如果账号池大小增加了，触发账号池刷新事件
 	newSize := len(p.accounts)
 	if newSize > oldSize {
@@ -150,6 +204,9 @@ func (p *AccountPool) AddAccount(account model.Account) {
 	if account.Quatos != nil && *account.Quatos == "账号已失效" {
 		return // 不添加无效账号
 	}
+	if !account.IsEnabled() {
+		return // 不添加已停用的账号
+	}
 
 	// 检查之前是否已存在该账号
 	_, exists := p.accounts[account.ID]
@@ -165,12 +222,13 @@ func (p *AccountPool) AddAccount(account model.Account) {
 		Quatos:               account.Quatos,
 		HK:                   account.HK,
 		VMCount:              account.VMCount,
+		VMCountRegion:        account.VMCountRegion,
 		Region:               account.Region,
 		CreateTime:           account.CreateTime,
 		IsSkipped:            false,
 		ErrorNote:            "",
 		SkippedInstanceTypes: make(map[string]bool), // 初始化为空映射
-		RegionUsedCount:      0,                     // 初始化实例计数为0
+		FamilyUsedCount:      make(map[string]int),  // 初始化实例计数为0
 	}
 
 	// 如果是新添加的账号，触发事件
@@ -180,6 +238,54 @@ func (p *AccountPool) AddAccount(account model.Account) {
 	}
 }
 
+// AddAccounts 批量添加新账号到内存池，返回新加入的账号数量
+// 所有账号加入后只触发一次账号添加事件，避免大批量导入时重复处理
+func (p *AccountPool) AddAccounts(accounts []model.Account) int {
+	p.mutex.Lock()
+	added := 0
+	for _, account := range accounts {
+		// 不添加无效账号和已停用的账号
+		if account.Quatos != nil && *account.Quatos == "账号已失效" {
+			continue
+		}
+		if !account.IsEnabled() {
+			continue
+		}
+		if _, exists := p.accounts[account.ID]; exists {
+			continue
+		}
+
+		p.accounts[account.ID] = &AccountInfo{
+			ID:                   account.ID,
+			UserID:               account.UserID,
+			Key1:                 account.Key1,
+			Key2:                 account.Key2,
+			Email:                account.Email,
+			Password:             account.Password,
+			Quatos:               account.Quatos,
+			HK:                   account.HK,
+			VMCount:              account.VMCount,
+			VMCountRegion:        account.VMCountRegion,
+			Region:               account.Region,
+			CreateTime:           account.CreateTime,
+			IsSkipped:            false,
+			ErrorNote:            "",
+			SkippedInstanceTypes: make(map[string]bool), // 初始化为空映射
+			FamilyUsedCount:      make(map[string]int),  // 初始化实例计数为0
+		}
+		added++
+	}
+	p.mutex.Unlock()
+
+	// 释放锁之后再触发事件，监听器可能会访问账号池
+	if added > 0 {
+		log.Printf("账号池: 批量添加%d个新账号，并触发账号添加事件", added)
+		GetEventManager().TriggerEvent(AccountAdded, "")
+	}
+
+	return added
+}
+
 // RemoveAccount 从内存池移除账号
 func (p *AccountPool) RemoveAccount(accountID string) {
 	p.mutex.Lock()
@@ -193,6 +299,37 @@ func (p *AccountPool) RemoveAccount(accountID string) {
 	}
 }
 
+// UpdateAccountCheckResult 用账号检测结果更新内存池，账号已失效时从池中移除
+func (p *AccountPool) UpdateAccountCheckResult(accountID string, quota string, hk string, vmCount *int, vmCountRegion string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	account, exists := p.accounts[accountID]
+	if !exists {
+		return
+	}
+
+	if quota == "账号已失效" {
+		delete(p.accounts, accountID)
+		if p.lastUsedID == accountID {
+			p.lastUsedID = ""
+		}
+		log.Printf("账号[%s]检测结果为已失效，已从账号池移除", accountID)
+		return
+	}
+
+	account.Quatos = &quota
+	if hk != "" {
+		account.HK = &hk
+	}
+	if vmCount != nil {
+		account.VMCount = vmCount
+		if vmCountRegion != "" {
+			account.VMCountRegion = &vmCountRegion
+		}
+	}
+}
+
 // UpdateAccountStatus 更新账号状态
 func (p *AccountPool) UpdateAccountStatus(accountID string, isValid bool) {
 	p.mutex.Lock()
@@ -265,7 +402,7 @@ func (p *AccountPool) ResetAccountStatus(accountID string) {
 		account.SkippedInstanceTypes = make(map[string]bool) // 清空所有实例类型的跳过标记
 
 		// 重置实例使用计数
-		account.RegionUsedCount = 0
+		account.FamilyUsedCount = make(map[string]int)
 
 		// 如果之前为跳过状态，现在变成可用了，触发事件
 		if wasSkipped {
@@ -319,9 +456,10 @@ func (p *AccountPool) GetNextAccountForInstanceType(instanceType string, regionC
 
 	log.Printf("调试: 已排序账号ID列表，共%d个", len(ids))
 
-	// 计算所需实例计数
+	// 计算所需实例计数，使用量按实例族分别统计
 	instanceCount := getInstanceCountForType(instanceType)
-	log.Printf("调试: 实例类型[%s]需要计数为%d", instanceType, instanceCount)
+	family := instanceFamily(instanceType)
+	log.Printf("调试: 实例类型[%s]需要计数为%d，实例族=%s", instanceType, instanceCount, family)
 
 	// 按ID从小到大顺序，返回第一个与区域匹配且可用于指定实例类型的账号
 	matchedCount := 0
@@ -332,7 +470,7 @@ func (p *AccountPool) GetNextAccountForInstanceType(instanceType string, regionC
 		account := p.accounts[id]
 
 		// 检查账号是否与请求区域匹配
-		if account.Region == nil || *account.Region != regionCode {
+		if account.RegionCode() != regionCode {
 			regionMismatchCount++
 			continue
 		}
@@ -353,27 +491,29 @@ func (p *AccountPool) GetNextAccountForInstanceType(instanceType string, regionC
 			continue
 		}
 
-		// 检查区域实例使用量是否已达上限（4个实例）
-		if account.RegionUsedCount+instanceCount > 4 {
-			// 不直接标记账号，只记录下需要标记的账号和原因
-			needMarkAccounts[account.ID] = fmt.Sprintf("%s区域配额已满（最多4个实例）", regionCode)
+		// 检查该实例族在区域内的使用量是否已达上限
+		if account.familyHeadroom(family) < instanceCount {
+			// 只标记这个实例类型，同族更小的实例或其他实例族仍可使用该账号
+			needMarkAccounts[account.ID] = fmt.Sprintf("%s区域%s实例族配额已满（最多%d个实例）",
+				regionCode, family, getFamilyCap(family))
 
-			log.Printf("调试: 账号[%s]在区域[%s]添加[%s]后将超过区域配额，当前使用量：%d，需要：%d，待标记",
-				account.ID, regionCode, instanceType, account.RegionUsedCount, instanceCount)
+			used, _ := familyUsage(account.FamilyUsedCount, family)
+			log.Printf("调试: 账号[%s]在区域[%s]添加[%s]后将超过实例族配额，当前使用量：%d，需要：%d，待标记",
+				account.ID, regionCode, instanceType, used, instanceCount)
 
 			skippedCount++
 			continue
 		}
 
 		// 记录日志，方便跟踪账号使用情况
-		log.Printf("获取账号: ID=%s, 用户=%s, 区域=%s, 用于实例类型=%s, 当前实例使用量=%d, 将增加=%d",
-			id, account.UserID, regionCode, instanceType, account.RegionUsedCount, instanceCount)
+		log.Printf("获取账号: ID=%s, 用户=%s, 区域=%s, 用于实例类型=%s, 当前实例族使用量=%d, 将增加=%d",
+			id, account.UserID, regionCode, instanceType, account.FamilyUsedCount[family], instanceCount)
 		log.Printf("调试: 成功选择账号ID=%s，符合所有条件", account.ID)
 
 		// 在锁外标记需要标记的账号
 		go func() {
 			for accID, errMsg := range needMarkAccounts {
-				p.MarkAccountFailed(accID, errMsg)
+				p.MarkInstanceTypeFailed(accID, instanceType, errMsg)
 			}
 		}()
 
@@ -387,26 +527,190 @@ func (p *AccountPool) GetNextAccountForInstanceType(instanceType string, regionC
 	// 在锁外标记需要标记的账号
 	go func() {
 		for accID, errMsg := range needMarkAccounts {
-			p.MarkAccountFailed(accID, errMsg)
+			p.MarkInstanceTypeFailed(accID, instanceType, errMsg)
 		}
 	}()
 
 	return nil
 }
 
+// AccountState 账号在账号池中的实时状态，用于排查账号为何未被选中
+type AccountState struct {
+	AccountID            string          `json:"account_id"`              // 账号ID
+	UserID               string          `json:"user_id"`                 // 用户ID
+	Region               string          `json:"region"`                  // 区域代码
+	Quatos               *string         `json:"quatos,omitempty"`        // 配额
+	IsSkipped            bool            `json:"is_skipped"`              // 是否整体跳过
+	ErrorNote            string          `json:"error_note"`              // 错误备注
+	SkippedInstanceTypes map[string]bool `json:"skipped_instance_types"`  // 特定实例类型跳过状态
+	RegionUsedCount      int             `json:"region_used_count"`       // 区域已使用的实例计数（各实例族之和）
+	FamilyUsedCount      map[string]int  `json:"family_used_count"`       // 区域内各实例族已使用的实例计数
+	LastUsed             bool            `json:"last_used"`               // 是否为上次选中的账号
+	InstanceType         string          `json:"instance_type,omitempty"` // 用于判断是否可选的实例类型
+	Selectable           *bool           `json:"selectable,omitempty"`    // 当前是否满足该实例类型的选择条件
+	Reason               string          `json:"reason,omitempty"`        // 不满足选择条件的原因
+}
+
+// InspectAccount 获取单个账号在账号池中的状态，instanceType不为空时按 GetNextAccountForInstanceType 的条件判断是否可选
+// regionCode为空时使用账号自身的区域，账号不在池中时返回false
+func (p *AccountPool) InspectAccount(accountID string, instanceType string, regionCode string) (*AccountState, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	account, exists := p.accounts[accountID]
+	if !exists {
+		return nil, false
+	}
+
+	state := &AccountState{
+		AccountID:            account.ID,
+		UserID:               account.UserID,
+		Quatos:               account.Quatos,
+		IsSkipped:            account.IsSkipped,
+		ErrorNote:            account.ErrorNote,
+		SkippedInstanceTypes: make(map[string]bool, len(account.SkippedInstanceTypes)),
+		RegionUsedCount:      account.TotalUsedCount(),
+		FamilyUsedCount:      make(map[string]int, len(account.FamilyUsedCount)),
+		LastUsed:             p.lastUsedID == account.ID,
+	}
+	state.Region = account.RegionCode()
+	for k, v := range account.SkippedInstanceTypes {
+		state.SkippedInstanceTypes[k] = v
+	}
+	for k, v := range account.FamilyUsedCount {
+		state.FamilyUsedCount[k] = v
+	}
+
+	if instanceType == "" {
+		return state, true
+	}
+	if regionCode == "" {
+		regionCode = state.Region
+	}
+
+	// 与实际选择逻辑保持一致：区域匹配、未被跳过、实例类型未被跳过、实例族余量充足
+	family := instanceFamily(instanceType)
+	selectable := false
+	state.InstanceType = instanceType
+	switch {
+	case state.Region != regionCode:
+		state.Reason = fmt.Sprintf("账号区域[%s]与请求区域[%s]不匹配", state.Region, regionCode)
+	case account.IsSkipped:
+		state.Reason = "账号已被整体标记为跳过"
+	case account.SkippedInstanceTypes[instanceType]:
+		state.Reason = fmt.Sprintf("账号对实例类型[%s]被标记为跳过", instanceType)
+	case account.familyHeadroom(family) < getInstanceCountForType(instanceType):
+		used, limit := familyUsage(account.FamilyUsedCount, family)
+		state.Reason = fmt.Sprintf("%s实例族配额已满（已使用%d，最多%d）", family, used, limit)
+	default:
+		selectable = true
+	}
+	state.Selectable = &selectable
+
+	return state, true
+}
+
+// PreviewEligibleAccounts 预览指定区域和实例类型可用的账号，不修改账号池状态
+// 按 GetNextAccountForInstanceType 的选择顺序模拟分配 count 台，返回可用账号列表和总容量
+func (p *AccountPool) PreviewEligibleAccounts(instanceType string, regionCode string, count int) ([]EligibleAccount, int) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	instanceCount := getInstanceCountForType(instanceType)
+	family := instanceFamily(instanceType)
+	eligible := make([]EligibleAccount, 0)
+	totalCapacity := 0
+
+	for _, id := range p.sortedAccountIDs() {
+		account := p.accounts[id]
+
+		// 与实际选择逻辑保持一致：区域匹配、未被跳过、实例类型未被跳过
+		if account.RegionCode() != regionCode {
+			continue
+		}
+		if account.IsSkipped {
+			continue
+		}
+		if skipped, exists := account.SkippedInstanceTypes[instanceType]; exists && skipped {
+			continue
+		}
+
+		capacity := account.familyHeadroom(family) / instanceCount
+		if capacity <= 0 {
+			continue
+		}
+
+		used, _ := familyUsage(account.FamilyUsedCount, family)
+		eligible = append(eligible, EligibleAccount{
+			AccountID:       account.ID,
+			UserID:          account.UserID,
+			RegionUsedCount: used,
+			Capacity:        capacity,
+		})
+		totalCapacity += capacity
+	}
+
+	// 按顺序依次占满每个账号，模拟重复调用 GetNextAccountForInstanceType 的分配结果
+	remaining := count
+	for i := range eligible {
+		if remaining <= 0 {
+			break
+		}
+		planned := eligible[i].Capacity
+		if planned > remaining {
+			planned = remaining
+		}
+		eligible[i].Planned = planned
+		remaining -= planned
+	}
+
+	return eligible, totalCapacity
+}
+
+// InstanceCapacity 账号在指定区域还可开启的该类型实例数量，与 GetNextAccountForInstanceType 的选择条件一致
+// 容量为0时同时返回原因
+func (p *AccountPool) InstanceCapacity(accountID string, instanceType string, regionCode string) (int, string) {
+	state, exists := p.InspectAccount(accountID, instanceType, regionCode)
+	if !exists {
+		return 0, "账号不在账号池中"
+	}
+	if !*state.Selectable {
+		return 0, state.Reason
+	}
+
+	used, limit := familyUsage(state.FamilyUsedCount, instanceFamily(instanceType))
+	return (limit - used) / getInstanceCountForType(instanceType), ""
+}
+
+// sortedAccountIDs 返回按ID数值排序的账号ID列表，调用方需持有锁
+func (p *AccountPool) sortedAccountIDs() []string {
+	ids := make([]string, 0, len(p.accounts))
+	for id := range p.accounts {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		numI, errI := strconv.Atoi(ids[i])
+		numJ, errJ := strconv.Atoi(ids[j])
+
+		// 如果转换出错（非数字ID），则退化为字符串比较
+		if errI != nil || errJ != nil {
+			return ids[i] < ids[j]
+		}
+		return numI < numJ
+	})
+
+	return ids
+}
+
 // getInstanceCountForType 根据实例类型获取实例计数（基于vCPU数量/2）
 func getInstanceCountForType(instanceType string) int {
-	switch instanceType {
-	case "c5n.large": // 2 vCPU
-		return 1
-	case "c5n.xlarge": // 4 vCPU
-		return 2
-	case "c5n.2xlarge": // 8 vCPU
-		return 4
-	default:
+	count := getInstanceVCPUs(instanceType) / 2
+	if count < 1 {
 		// 未知的实例类型，默认计为1个实例
 		return 1
 	}
+	return count
 }
 
 // GetAllAccounts 获取所有可用账号
@@ -508,6 +812,19 @@ func (p *AccountPool) AvailableSize() int {
 	return count
 }
 
+// HasActiveAccountInRegion 判断指定区域是否存在未被整体跳过的账号
+func (p *AccountPool) HasActiveAccountInRegion(regionCode string) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for _, account := range p.accounts {
+		if account.RegionCode() == regionCode && !account.IsSkipped {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *AccountPool) GetAccountPoolInfo() map[string]interface{} {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
@@ -523,7 +840,8 @@ func (p *AccountPool) GetAccountPoolInfo() map[string]interface{} {
 			"key2":              account.Key2,
 			"is_skipped":        account.IsSkipped,
 			"error_note":        account.ErrorNote,
-			"region_used_count": account.RegionUsedCount,
+			"region_used_count": account.TotalUsedCount(),
+			"family_used_count": account.FamilyUsedCount,
 		}
 
 		// 处理可能为空的指针字段
@@ -541,6 +859,9 @@ func (p *AccountPool) GetAccountPoolInfo() map[string]interface{} {
 		}
 		if account.VMCount != nil {
 			accountInfo["vm_count"] = *account.VMCount
+			if account.VMCountRegion != nil {
+				accountInfo["vm_count_region"] = *account.VMCountRegion
+			}
 		}
 		if account.Region != nil {
 			accountInfo["region"] = *account.Region
@@ -569,6 +890,11 @@ func (p *AccountPool) GetAccountPoolInfo() map[string]interface{} {
 
 // ResetAllAccountsStatus 重置所有账号的状态，清除所有跳过标记
 func (p *AccountPool) ResetAllAccountsStatus() {
+	p.ResetAccountsStatusForRegion("")
+}
+
+// ResetAccountsStatusForRegion 重置指定区域账号的状态，region为空时重置所有区域
+func (p *AccountPool) ResetAccountsStatusForRegion(region string) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
@@ -577,13 +903,17 @@ func (p *AccountPool) ResetAllAccountsStatus() {
 	resetIDs := make([]string, 0)
 
 	for id, account := range p.accounts {
-		if account.IsSkipped || account.RegionUsedCount > 0 {
+		// 只重置指定区域的账号
+		if region != "" && account.RegionCode() != region {
+			continue
+		}
+		if account.IsSkipped || account.TotalUsedCount() > 0 {
 			account.IsSkipped = false
 			account.ErrorNote = ""
 			account.SkippedInstanceTypes = make(map[string]bool)
 
 			// 重置实例使用计数
-			account.RegionUsedCount = 0
+			account.FamilyUsedCount = make(map[string]int)
 
 			resetCount++
 			resetIDs = append(resetIDs, id)
@@ -612,7 +942,71 @@ func (p *AccountPool) OnAccountPoolEvent(event AccountPoolEvent, accountID strin
 			log.Printf("账号池: 收到账号删除事件，但未指定账号ID，尝试刷新账号池")
 			_ = p.RefreshFromDB()
 		}
+	case RegionChanged:
+		// 响应账号区域变更事件，从数据库重新读取区域
+		if accountID != "" {
+			p.reloadAccountRegion(accountID)
+		}
+	case AccountDisabled:
+		// 停用的账号从账号池移除，数据库记录保留
+		if accountID != "" {
+			log.Printf("账号池: 收到账号停用事件，移除账号ID=%s", accountID)
+			p.RemoveAccount(accountID)
+		}
+	case AccountEnabled:
+		// 重新启用的账号从数据库读取后加入账号池
+		if accountID != "" {
+			p.reloadEnabledAccount(accountID)
+		}
+	}
+}
+
+// reloadEnabledAccount 从数据库读取重新启用的账号并加入账号池
+func (p *AccountPool) reloadEnabledAccount(accountID string) {
+	db := repository.GetDB()
+	if db == nil {
+		return
+	}
+
+	accounts, err := model.GetAccountsByIDs(db, []string{accountID})
+	if err != nil || len(accounts) == 0 {
+		log.Printf("账号池: 读取重新启用的账号[%s]失败: %v", accountID, err)
+		return
+	}
+
+	// AddAccount 会跳过已失效的账号，新加入时触发账号添加事件
+	p.AddAccount(accounts[0])
+}
+
+// reloadAccountRegion 从数据库重新读取账号区域，并重置区域使用计数和跳过状态
+func (p *AccountPool) reloadAccountRegion(accountID string) {
+	db := repository.GetDB()
+	if db == nil {
+		return
+	}
+
+	accounts, err := model.GetAccountsByIDs(db, []string{accountID})
+	if err != nil || len(accounts) == 0 {
+		log.Printf("账号池: 读取账号[%s]区域失败: %v", accountID, err)
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	account, exists := p.accounts[accountID]
+	if !exists {
+		log.Printf("账号池: 账号[%s]不在账号池中，忽略区域变更", accountID)
+		return
 	}
+
+	account.Region = accounts[0].Region
+	account.FamilyUsedCount = make(map[string]int)
+	// 原区域的失败状态不再适用于新区域
+	account.IsSkipped = false
+	account.ErrorNote = ""
+	account.SkippedInstanceTypes = make(map[string]bool)
+	log.Printf("账号池: 账号[%s]区域已更新为%v，使用计数已重置", accountID, account.Region)
 }
 
 // IncrementInstanceUsage 增加账号的实例使用计数
@@ -631,18 +1025,21 @@ func (p *AccountPool) IncrementInstanceUsage(accountID string, instanceType stri
 	defer p.mutex.Unlock()
 
 	if account, exists := p.accounts[accountID]; exists {
-		oldCount := account.RegionUsedCount
+		family := instanceFamily(instanceType)
+		if account.FamilyUsedCount == nil {
+			account.FamilyUsedCount = make(map[string]int)
+		}
+		oldCount := account.FamilyUsedCount[family]
 		// 验证账号区域是否与请求区域匹配
-		if account.Region != nil && *account.Region == region {
-			// 增加区域实例使用计数
-			account.RegionUsedCount += instanceCount
-			log.Printf("调试: 账号[%s]使用计数已增加: %d -> %d",
-				accountID, oldCount, account.RegionUsedCount)
-
-			// 检查是否已达到区域限制
-			if account.RegionUsedCount >= 4 {
-				log.Printf("调试: 账号[%s]区域[%s]使用量达到上限，准备标记为跳过", accountID, region)
-				// 原有标记逻辑...
+		if account.RegionCode() == region {
+			// 增加该实例族的使用计数
+			account.FamilyUsedCount[family] += instanceCount
+			log.Printf("调试: 账号[%s]实例族[%s]使用计数已增加: %d -> %d",
+				accountID, family, oldCount, account.FamilyUsedCount[family])
+
+			// 检查是否已达到实例族限制
+			if account.familyHeadroom(family) <= 0 {
+				log.Printf("调试: 账号[%s]区域[%s]实例族[%s]使用量达到上限", accountID, region, family)
 			}
 		} else {
 			log.Printf("警告: 账号[%s]区域不匹配, 请求区域=%s, 账号区域=%v",
@@ -653,15 +1050,29 @@ func (p *AccountPool) IncrementInstanceUsage(accountID string, instanceType stri
 	}
 }
 
+// ReleaseInstanceUsage 实例被终止后减少账号的实例使用计数，不会减到0以下
+func (p *AccountPool) ReleaseInstanceUsage(accountID string, instanceType string, region string) {
+	instanceCount := getInstanceCountForType(instanceType)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	account, exists := p.accounts[accountID]
+	if !exists || account.RegionCode() != region || account.FamilyUsedCount == nil {
+		return
+	}
+	family := instanceFamily(instanceType)
+	oldCount := account.FamilyUsedCount[family]
+	account.FamilyUsedCount[family] = max(oldCount-instanceCount, 0)
+	log.Printf("账号[%s]实例族[%s]使用计数已释放: %d -> %d",
+		accountID, family, oldCount, account.FamilyUsedCount[family])
+}
+
 // getCPUForInstanceType 根据实例类型获取CPU数量
 func getCPUForInstanceType(instanceType string) int {
-	switch instanceType {
-	case "c5n.large":
-		return 2
-	case "c5n.xlarge":
-		return 4
-	default:
-		// 默认情况，返回较大值以避免超额使用
-		return 2
+	if vcpus := getInstanceVCPUs(instanceType); vcpus > 0 {
+		return vcpus
 	}
+	// 默认情况，返回较大值以避免超额使用
+	return 2
 }
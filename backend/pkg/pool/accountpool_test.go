@@ -0,0 +1,223 @@
+package pool
+
+import (
+	"sync"
+	"testing"
+
+	"portal/model"
+	"portal/pkg/region"
+
+	"gorm.io/gorm"
+)
+
+// testAccount 构造账号池中的账号，region 为空时使用默认区域
+func testAccount(id string, regionCode string, used map[string]int) *AccountInfo {
+	account := &AccountInfo{
+		ID:                   id,
+		UserID:               "u-" + id,
+		SkippedInstanceTypes: make(map[string]bool),
+		FamilyUsedCount:      used,
+	}
+	if account.FamilyUsedCount == nil {
+		account.FamilyUsedCount = make(map[string]int)
+	}
+	if regionCode != "" {
+		account.Region = &regionCode
+	}
+	return account
+}
+
+// newTestAccountPool 使用给定账号构造账号池
+func newTestAccountPool(accounts ...*AccountInfo) *AccountPool {
+	p := NewAccountPool()
+	for _, account := range accounts {
+		p.accounts[account.ID] = account
+	}
+	return p
+}
+
+func TestPreviewEligibleAccounts(t *testing.T) {
+	skipped := testAccount("2", region.HK, nil)
+	skipped.IsSkipped = true
+	typeSkipped := testAccount("4", region.HK, nil)
+	typeSkipped.SkippedInstanceTypes["c5n.large"] = true
+
+	p := newTestAccountPool(
+		testAccount("1", "", nil),        // 默认区域，余量4
+		skipped,                          // 已跳过
+		testAccount("3", region.JP, nil), // 区域不匹配
+		typeSkipped,                      // 实例类型已跳过
+		testAccount("5", region.HK, map[string]int{"c5n": 4}),  // 配额已满
+		testAccount("10", region.HK, map[string]int{"c5n": 3}), // 余量1
+	)
+
+	eligible, total := p.PreviewEligibleAccounts("c5n.large", region.HK, 5)
+	if total != 5 || len(eligible) != 2 {
+		t.Fatalf("可用账号 = %+v, 总容量%d, 期望账号1和10共5台", eligible, total)
+	}
+	want := []EligibleAccount{
+		{AccountID: "1", UserID: "u-1", RegionUsedCount: 0, Capacity: 4, Planned: 4},
+		{AccountID: "10", UserID: "u-10", RegionUsedCount: 3, Capacity: 1, Planned: 1},
+	}
+	for i := range want {
+		if eligible[i] != want[i] {
+			t.Errorf("第%d个可用账号 = %+v, 期望 %+v", i, eligible[i], want[i])
+		}
+	}
+
+	// 只需2台时全部分配给第一个账号
+	eligible, _ = p.PreviewEligibleAccounts("c5n.large", region.HK, 2)
+	if eligible[0].Planned != 2 || eligible[1].Planned != 0 {
+		t.Errorf("分配2台 = %+v, 期望全部分配给账号1", eligible)
+	}
+
+	// 预览不修改账号池状态
+	if p.accounts["1"].FamilyUsedCount["c5n"] != 0 {
+		t.Error("预览不应修改账号的使用计数")
+	}
+}
+
+// setExcludeQueryFailed 设置加载账号池时是否排除查询失败的账号，测试结束后恢复
+func setExcludeQueryFailed(t *testing.T, value string) {
+	t.Helper()
+	t.Setenv("POOL_EXCLUDE_QUERY_FAILED", value)
+	excludeQueryFailedOnce = sync.Once{}
+	t.Cleanup(func() { excludeQueryFailedOnce = sync.Once{} })
+}
+
+func TestResetAccountsStatusForRegion(t *testing.T) {
+	hk := testAccount("1", region.HK, map[string]int{"t3": 2})
+	hk.IsSkipped = true
+	hk.ErrorNote = "香港区域开机失败"
+	jp := testAccount("2", region.JP, map[string]int{"t3": 3})
+	jp.IsSkipped = true
+	jp.ErrorNote = "日本区域开机失败"
+	jp.SkippedInstanceTypes["c5n.large"] = true
+	p := newTestAccountPool(hk, jp)
+
+	p.ResetAccountsStatusForRegion(region.HK)
+
+	if hk.IsSkipped || hk.ErrorNote != "" || hk.TotalUsedCount() != 0 {
+		t.Fatalf("香港区账号应被重置: skipped=%v note=%q used=%d", hk.IsSkipped, hk.ErrorNote, hk.TotalUsedCount())
+	}
+	if !jp.IsSkipped || jp.ErrorNote == "" || jp.TotalUsedCount() != 3 || !jp.SkippedInstanceTypes["c5n.large"] {
+		t.Fatalf("日本区账号不应受影响: skipped=%v note=%q used=%d types=%v",
+			jp.IsSkipped, jp.ErrorNote, jp.TotalUsedCount(), jp.SkippedInstanceTypes)
+	}
+
+	// 不指定区域时重置所有账号
+	p.ResetAllAccountsStatus()
+	if jp.IsSkipped || jp.TotalUsedCount() != 0 || len(jp.SkippedInstanceTypes) != 0 {
+		t.Fatalf("重置全部后日本区账号应被重置: skipped=%v used=%d", jp.IsSkipped, jp.TotalUsedCount())
+	}
+}
+
+func TestLoadAccountsExcludesQueryFailed(t *testing.T) {
+	invalid, failed, quota := "账号已失效", "查询失败", "32"
+	for _, acc := range []model.Account{
+		{ID: "9401", UserID: "query-failed-user", Key1: "AKIA9401", Quatos: &quota},
+		{ID: "9402", UserID: "query-failed-user", Key1: "AKIA9402", Quatos: &failed},
+		{ID: "9403", UserID: "query-failed-user", Key1: "AKIA9403", Quatos: &invalid},
+		{ID: "9404", UserID: "query-failed-user", Key1: "AKIA9404"},
+	} {
+		if err := globalDB.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		exclude string
+		want    map[string]bool
+	}{
+		{"默认保留查询失败的账号", "", map[string]bool{"9401": true, "9402": true, "9403": false, "9404": true}},
+		{"开启后排除查询失败的账号", "true", map[string]bool{"9401": true, "9402": false, "9403": false, "9404": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setExcludeQueryFailed(t, tt.exclude)
+			p := NewAccountPool()
+			if err := p.LoadAccountsFromDB(); err != nil {
+				t.Fatalf("加载账号池失败: %v", err)
+			}
+			for id, want := range tt.want {
+				if _, ok := p.accounts[id]; ok != want {
+					t.Errorf("账号%s在池中 = %v, want %v", id, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInspectAccount(t *testing.T) {
+	quota := "32"
+	account := testAccount("7", region.JP, map[string]int{"t3": 2})
+	account.Quatos = &quota
+	account.SkippedInstanceTypes["c5n.large"] = true
+	p := newTestAccountPool(account, testAccount("8", region.JP, nil))
+	p.lastUsedID = "7"
+
+	state, ok := p.InspectAccount("7", "", "")
+	if !ok {
+		t.Fatal("账号池中的账号应能查询到")
+	}
+	if state.UserID != "u-7" || state.Region != region.JP || state.Quatos == nil || *state.Quatos != "32" ||
+		state.RegionUsedCount != 2 || state.FamilyUsedCount["t3"] != 2 || !state.SkippedInstanceTypes["c5n.large"] || !state.LastUsed {
+		t.Fatalf("账号状态 = %+v", state)
+	}
+	if state.Selectable != nil {
+		t.Fatal("未指定实例类型时不应判断是否可选")
+	}
+
+	tests := []struct {
+		name         string
+		instanceType string
+		regionCode   string
+		want         bool
+	}{
+		{"满足条件", "t3.micro", "", true},
+		{"实例类型被跳过", "c5n.large", "", false},
+		{"区域不匹配", "t3.micro", region.HK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, _ := p.InspectAccount("7", tt.instanceType, tt.regionCode)
+			if state.Selectable == nil || *state.Selectable != tt.want {
+				t.Fatalf("是否可选 = %v（%s）, want %v", state.Selectable, state.Reason, tt.want)
+			}
+			if !tt.want && state.Reason == "" {
+				t.Fatal("不可选时应给出原因")
+			}
+		})
+	}
+
+	// 返回的是副本，修改不影响账号池
+	state.SkippedInstanceTypes["t3.micro"] = true
+	if account.SkippedInstanceTypes["t3.micro"] {
+		t.Fatal("修改返回的状态不应影响账号池")
+	}
+	if _, ok := p.InspectAccount("9", "", ""); ok {
+		t.Fatal("不在账号池中的账号应返回false")
+	}
+}
+
+func TestNilRegionAccountSelectableForDefaultRegion(t *testing.T) {
+	account := testAccount("1", "", nil)
+	p := newTestAccountPool(account)
+	if account.Region != nil {
+		t.Fatal("测试账号应未设置区域")
+	}
+
+	if got := account.RegionCode(); got != region.HK {
+		t.Fatalf("未设置区域的账号区域 = %s, want %s", got, region.HK)
+	}
+	if next := p.GetNextAccountForInstanceType("t3.micro", region.HK); next == nil || next.ID != "1" {
+		t.Fatalf("默认区域选择的账号 = %+v, 期望选中未设置区域的账号", next)
+	}
+	if next := p.GetNextAccountForInstanceType("t3.micro", region.JP); next != nil {
+		t.Fatalf("其他区域不应选中未设置区域的账号: %+v", next)
+	}
+	if !p.HasActiveAccountInRegion(region.HK) {
+		t.Fatal("未设置区域的账号应计入默认区域的可用账号")
+	}
+}
@@ -0,0 +1,59 @@
+// pkg/pool/capacity.go
+package pool
+
+import (
+	"portal/model"
+)
+
+// CapacityRow 单个用户在单个区域的阈值与实际数量对照
+type CapacityRow struct {
+	UserID    string `json:"user_id"`
+	Region    string `json:"region"`
+	Threshold int    `json:"threshold"` // 该区域配置的阈值
+	Active    bool   `json:"active"`    // 监控开启、区域补机开启且允许在该区域开机，主动检测才会补机
+	Online    int    `json:"online"`    // 在线实例数量
+	Counted   int    `json:"counted"`   // 计入阈值的实例数量，只统计合规实例时可能少于在线数量
+	Pending   int    `json:"pending"`   // 补机队列中等待中任务尚未完成的数量
+	Deficit   int    `json:"deficit"`   // 阈值减去计入数量和待补机数量后仍缺少的数量
+	Surplus   int    `json:"surplus"`   // 计入数量和待补机数量超出阈值的数量
+}
+
+// CapacityMatrix 汇总所有用户各区域的阈值、在线数量和待补机数量
+// 统计口径与主动检测一致，只读取状态，不触发补机；阈值为0且没有在线实例的区域不列出
+func (d *Detector) CapacityMatrix() ([]CapacityRow, error) {
+	monitors, err := model.GetAllMonitors(d.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]CapacityRow, 0)
+	for i := range monitors {
+		monitor := &monitors[i]
+		for _, regionCode := range GetRegions() {
+			threshold := model.GetThresholdByRegion(monitor, regionCode)
+			instances := GlobalPool.GetInstancesByUserIDAndRegion(monitor.UserID, regionCode)
+			if threshold == 0 && len(instances) == 0 {
+				continue
+			}
+
+			row := CapacityRow{
+				UserID:    monitor.UserID,
+				Region:    regionCode,
+				Threshold: threshold,
+				Active: monitor.IsEnabled && threshold > 0 && monitor.IsRegionEnabled(regionCode) &&
+					model.IsRegionAllowedForUser(d.db, monitor.UserID, regionCode),
+				Online:  len(instances),
+				Counted: countUsableInstances(monitor, regionCode, instances),
+				Pending: pendingMakeupCount(monitor.UserID, regionCode),
+			}
+			if balance := row.Counted + row.Pending - row.Threshold; balance < 0 {
+				row.Deficit = -balance
+			} else {
+				row.Surplus = balance
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
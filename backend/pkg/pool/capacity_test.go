@@ -0,0 +1,63 @@
+package pool
+
+import (
+	"testing"
+
+	"portal/model"
+	"portal/pkg/region"
+)
+
+func TestCapacityMatrix(t *testing.T) {
+	pool := usePool(t)
+	queue := useMakeupQueue(t)
+	const (
+		userA = "capacity-user-a"
+		userB = "capacity-user-b"
+	)
+	for _, monitor := range []*model.Monitor{
+		{UserID: userA, IsEnabled: true, Threshold: 2, JpThreshold: 3},
+		{UserID: userB, IsEnabled: false, Threshold: 1},
+	} {
+		if err := globalDB.Create(monitor).Error; err != nil {
+			t.Fatalf("写入监控配置失败: %v", err)
+		}
+	}
+	for _, inst := range []*InstanceMetadata{
+		{InstanceID: "i-cap-hk-1", UserID: userA, Region: region.HK},
+		{InstanceID: "i-cap-hk-2", UserID: userA, Region: region.HK},
+		{InstanceID: "i-cap-hk-3", UserID: userA, Region: region.HK},
+		{InstanceID: "i-cap-jp-1", UserID: userA, Region: region.JP},
+		// 新加坡区阈值为0但有在线实例，仍应列出
+		{InstanceID: "i-cap-sg-1", UserID: userA, Region: region.SG},
+	} {
+		pool.UpdateInstance(inst)
+	}
+	queue.AddToQueueWithRegion(userA, 1, region.JP)
+
+	detector := NewDetector(globalDB, &MakeupHistory{records: make(map[makeupKey][]*MakeupRecord)})
+	rows, err := detector.CapacityMatrix()
+	if err != nil {
+		t.Fatalf("计算容量矩阵失败: %v", err)
+	}
+
+	got := make(map[string]CapacityRow)
+	for _, row := range rows {
+		if row.UserID == userA || row.UserID == userB {
+			got[row.UserID+"/"+row.Region] = row
+		}
+	}
+	want := map[string]CapacityRow{
+		userA + "/" + region.HK: {UserID: userA, Region: region.HK, Threshold: 2, Active: true, Online: 3, Counted: 3, Surplus: 1},
+		userA + "/" + region.JP: {UserID: userA, Region: region.JP, Threshold: 3, Active: true, Online: 1, Counted: 1, Pending: 1, Deficit: 1},
+		userA + "/" + region.SG: {UserID: userA, Region: region.SG, Online: 1, Counted: 1, Surplus: 1},
+		userB + "/" + region.HK: {UserID: userB, Region: region.HK, Threshold: 1, Deficit: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("容量矩阵 = %+v, 期望%d行", got, len(want))
+	}
+	for key, row := range want {
+		if got[key] != row {
+			t.Errorf("%s = %+v, want %+v", key, got[key], row)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// pkg/pool/degraded.go
+package pool
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"portal/pkg/tg"
+)
+
+// 实例超过该时间未上报视为离线
+const defaultOfflineTimeout = 60 * time.Second
+
+// 实例超过该时间未上报标记为上报缓慢，仍视为在线
+const defaultDegradedTimeout = 40 * time.Second
+
+var (
+	offlineTimeout      time.Duration
+	degradedTimeout     time.Duration
+	degradedNotify      bool
+	instanceTimeoutOnce sync.Once
+)
+
+// loadInstanceTimeouts 加载离线和上报缓慢的判定时间
+// INSTANCE_OFFLINE_TIMEOUT 默认60s；INSTANCE_DEGRADED_TIMEOUT 默认40s，设为0关闭，需小于离线时间；
+// INSTANCE_DEGRADED_NOTIFY=true 时实例变为上报缓慢会通知用户
+func loadInstanceTimeouts() {
+	instanceTimeoutOnce.Do(func() {
+		offlineTimeout = defaultOfflineTimeout
+		if value := os.Getenv("INSTANCE_OFFLINE_TIMEOUT"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d > 0 {
+				offlineTimeout = d
+			} else {
+				log.Printf("INSTANCE_OFFLINE_TIMEOUT配置无效: %s，使用默认值%v", value, defaultOfflineTimeout)
+			}
+		}
+
+		degradedTimeout = defaultDegradedTimeout
+		if value := os.Getenv("INSTANCE_DEGRADED_TIMEOUT"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+				degradedTimeout = d
+			} else {
+				log.Printf("INSTANCE_DEGRADED_TIMEOUT配置无效: %s，使用默认值%v", value, defaultDegradedTimeout)
+			}
+		}
+		if degradedTimeout >= offlineTimeout {
+			log.Printf("上报缓慢判定时间%v不小于离线判定时间%v，不标记上报缓慢", degradedTimeout, offlineTimeout)
+			degradedTimeout = 0
+		}
+
+		degradedNotify = os.Getenv("INSTANCE_DEGRADED_NOTIFY") == "true"
+	})
+}
+
+// getOfflineTimeout 获取实例离线的判定时间
+func getOfflineTimeout() time.Duration {
+	loadInstanceTimeouts()
+	return offlineTimeout
+}
+
+// getDegradedTimeout 获取实例上报缓慢的判定时间，0表示不判定
+func getDegradedTimeout() time.Duration {
+	loadInstanceTimeouts()
+	return degradedTimeout
+}
+
+// classifyInstance 按距上次上报的时间判断实例是否离线或上报缓慢
+func classifyInstance(sinceLastSeen time.Duration) (offline bool, degraded bool) {
+	if sinceLastSeen > getOfflineTimeout() {
+		return true, false
+	}
+	threshold := getDegradedTimeout()
+	return false, threshold > 0 && sinceLastSeen > threshold
+}
+
+// notifyInstanceDegraded 通知用户实例上报缓慢，未开启通知时只记录日志，测试中可替换
+var notifyInstanceDegraded = func(instances []*InstanceMetadata) {
+	loadInstanceTimeouts()
+	if !degradedNotify {
+		return
+	}
+	for _, m := range instances {
+		message := fmt.Sprintf("实例上报缓慢\n实例ID: %s\nIP: %s\n区域: %s\n超过%v未上报，超过%v将视为离线",
+			m.InstanceID, m.IPv4, m.Region, getDegradedTimeout(), getOfflineTimeout())
+		if err := tg.NotifyUserMessage(globalDB, m.UserID, message); err != nil {
+			log.Printf("发送实例[%s]上报缓慢通知失败: %v", m.InstanceID, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"testing"
+	"time"
+)
+
+// setInstanceTimeouts 设置离线和上报缓慢的判定时间，测试结束后恢复
+func setInstanceTimeouts(t *testing.T, offline, degraded time.Duration) {
+	t.Helper()
+	instanceTimeoutOnce.Do(func() {})
+	oldOffline, oldDegraded, oldNotify := offlineTimeout, degradedTimeout, degradedNotify
+	offlineTimeout, degradedTimeout, degradedNotify = offline, degraded, false
+	t.Cleanup(func() { offlineTimeout, degradedTimeout, degradedNotify = oldOffline, oldDegraded, oldNotify })
+}
+
+func TestClassifyInstance(t *testing.T) {
+	setInstanceTimeouts(t, 60*time.Second, 40*time.Second)
+	tests := []struct {
+		since             time.Duration
+		offline, degraded bool
+	}{
+		{10 * time.Second, false, false},
+		{50 * time.Second, false, true},
+		{70 * time.Second, true, false},
+	}
+	for _, tt := range tests {
+		if offline, degraded := classifyInstance(tt.since); offline != tt.offline || degraded != tt.degraded {
+			t.Errorf("classifyInstance(%v) = %v, %v, want %v, %v", tt.since, offline, degraded, tt.offline, tt.degraded)
+		}
+	}
+
+	// 上报缓慢判定关闭时只判断离线
+	setInstanceTimeouts(t, 60*time.Second, 0)
+	if offline, degraded := classifyInstance(50 * time.Second); offline || degraded {
+		t.Fatal("关闭上报缓慢判定时不应标记上报缓慢")
+	}
+}
+
+func TestCheckInstancesFlagsDegradedWithoutRemoving(t *testing.T) {
+	setInstanceTimeouts(t, 60*time.Second, 40*time.Second)
+	setStartupGrace(t, 0)
+	detected := make(map[string]bool)
+	oldDetect := detectOfflineUser
+	detectOfflineUser = func(userID string) *DetectResult {
+		detected[userID] = true
+		return nil
+	}
+	t.Cleanup(func() { detectOfflineUser = oldDetect })
+	notified := make(chan []string, 1)
+	oldNotify := notifyInstanceDegraded
+	notifyInstanceDegraded = func(instances []*InstanceMetadata) {
+		ids := make([]string, 0, len(instances))
+		for _, m := range instances {
+			ids = append(ids, m.InstanceID)
+		}
+		notified <- ids
+	}
+	t.Cleanup(func() { notifyInstanceDegraded = oldNotify })
+
+	p := NewPool()
+	t.Cleanup(p.waitOffline)
+	now := time.Now()
+	p.Instances["i-fresh"] = &InstanceMetadata{InstanceID: "i-fresh", UserID: "u-fresh", LastSeen: now.Add(-10 * time.Second)}
+	p.Instances["i-slow"] = &InstanceMetadata{InstanceID: "i-slow", UserID: "u-slow", LastSeen: now.Add(-50 * time.Second)}
+	p.Instances["i-gone"] = &InstanceMetadata{InstanceID: "i-gone", UserID: "u-gone", LastSeen: now.Add(-70 * time.Second)}
+
+	listed := p.GetInstancesByUserID("u-slow")[0]
+
+	p.checkInstancesOnce(now)
+
+	// 介于上报缓慢和离线判定时间之间的实例只标记，不移除，也不触发补机检测
+	if !p.HasInstance("i-slow") {
+		t.Fatal("上报缓慢的实例不应被移除")
+	}
+	if !p.Instances["i-slow"].Degraded {
+		t.Fatal("超过上报缓慢判定时间的实例应标记为上报缓慢")
+	}
+	if listed.Degraded {
+		t.Fatal("已返回给调用方的实例不应被原地修改")
+	}
+	if detected["u-slow"] {
+		t.Fatal("上报缓慢的实例不应触发补机检测")
+	}
+	if !p.HasInstance("i-fresh") || p.Instances["i-fresh"].Degraded {
+		t.Fatal("正常上报的实例不应标记为上报缓慢")
+	}
+	if p.HasInstance("i-gone") || !detected["u-gone"] {
+		t.Fatal("超过离线判定时间的实例应被移除并触发补机检测")
+	}
+	select {
+	case ids := <-notified:
+		if len(ids) != 1 || ids[0] != "i-slow" {
+			t.Fatalf("上报缓慢通知的实例 = %v, want [i-slow]", ids)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("未发送上报缓慢通知")
+	}
+
+	// 恢复上报后清除上报缓慢标记
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-slow", UserID: "u-slow"})
+	if p.Instances["i-slow"].Degraded {
+		t.Fatal("恢复上报后应清除上报缓慢标记")
+	}
+}
@@ -3,10 +3,13 @@ package pool
 
 import (
 	"log"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"portal/model"
+	"portal/pkg/region"
 
 	"gorm.io/gorm"
 )
@@ -34,6 +37,59 @@ func NewDetector(db *gorm.DB, history *MakeupHistory) *Detector {
 	}
 }
 
+var (
+	countCompliantOnly     bool
+	countCompliantOnlyOnce sync.Once
+)
+
+// shouldCountCompliantOnly 开启IP段限制的用户是否只统计IP符合要求的实例
+// 通过 DETECTOR_COUNT_COMPLIANT_ONLY=true 开启，默认统计所有在线实例
+func shouldCountCompliantOnly() bool {
+	countCompliantOnlyOnce.Do(func() {
+		countCompliantOnly = os.Getenv("DETECTOR_COUNT_COMPLIANT_ONLY") == "true"
+		if countCompliantOnly {
+			log.Printf("补机检测只统计IP符合IP段要求的实例")
+		}
+	})
+	return countCompliantOnly
+}
+
+// countUsableInstances 统计计入阈值的实例数量
+// 开启只统计合规实例且用户启用了IP段限制时，IP不符合该区域IP段的实例不计入
+func countUsableInstances(monitor *model.Monitor, regionCode string, instances []*InstanceMetadata) int {
+	if !shouldCountCompliantOnly() || !monitor.IsIPRangeEnabled {
+		return len(instances)
+	}
+	ipRange := monitor.IPRangeForRegion(regionCode)
+	if ipRange == "" {
+		return len(instances)
+	}
+
+	count := 0
+	for _, inst := range instances {
+		if strings.HasPrefix(inst.IPv4, ipRange) {
+			count++
+		}
+	}
+	return count
+}
+
+// GetRegions 获取检测和补机覆盖的区域代码，来自区域配置
+func GetRegions() []string {
+	return region.Codes()
+}
+
+// pendingMakeupCount 用户在指定区域补机队列中等待中任务尚未完成的数量
+func pendingMakeupCount(userID string, regionCode string) int {
+	pending := 0
+	for _, task := range GetMakeupQueue().GetWaitingTasksForRegion(regionCode) {
+		if task.UserID == userID {
+			pending += task.TotalCount - task.CompletedCount
+		}
+	}
+	return pending
+}
+
 // getUserLock 获取指定用户的互斥锁
 func (d *Detector) getUserLock(userID string) *sync.Mutex {
 	actual, _ := d.userMu.LoadOrStore(userID, &sync.Mutex{})
@@ -58,79 +114,32 @@ func (d *Detector) DetectAllUsers() []DetectResult {
 			continue
 		}
 
-		// 需要检查三个区域：香港、日本、新加坡
-		regions := []string{"ap-east-1", "ap-northeast-3", "ap-southeast-1"}
-
-		for _, region := range regions {
+		for _, region := range GetRegions() {
 			// 对每个用户和区域使用独立的锁
 			userLock := d.getUserLock(monitor.UserID + region)
 			userLock.Lock()
 
-			// 根据区域获取对应的阈值
-			var threshold int
-			switch region {
-			case "ap-east-1": // 香港
-				threshold = monitor.Threshold
-			case "ap-northeast-3": // 日本
-				threshold = monitor.JpThreshold
-			case "ap-southeast-1": // 新加坡
-				threshold = monitor.SgThreshold
-			}
-
-			// 如果阈值为0，跳过该区域检测
-			if threshold == 0 {
-				userLock.Unlock()
-				continue
-			}
-
-			// 3. 获取用户在指定区域的实例数
-			instances := GlobalPool.GetInstancesByUserIDAndRegion(monitor.UserID, region)
-			currentCount := len(instances)
-
-			// 4. 获取用户在补机队列中的待处理任务
-			makeupQueue := GetMakeupQueue()
-
-			// 获取该用户该区域所有等待中的任务
-			waitingTasks := makeupQueue.GetWaitingTasksForRegion(region)
+			// 3. 按阈值、在线数量和待补机数量评估是否需要补机
+			plan := d.planRegion(&monitor, region)
+			switch {
+			case plan.WouldQueue:
+				// 4. 不在冷却期内，添加补机记录
+				d.history.AddMakeupRecordWithRegion(monitor.UserID, plan.Need, region)
 
-			// 计算等待中的任务总数
-			pendingMakeupCount := 0
-			for _, task := range waitingTasks {
-				if task.UserID == monitor.UserID {
-					pendingMakeupCount += task.TotalCount - task.CompletedCount
-				}
-			}
-
-			// 5. 实际需要的实例总数是阈值
-			// 当前已有的实例数是：当前在线 + 待补机数量
-			actualCurrentCount := currentCount + pendingMakeupCount
-
-			// 6. 判断是否需要补机
-			if actualCurrentCount < threshold {
-				needCount := threshold - actualCurrentCount
-
-				// 7. 检查是否在冷却期内（5分钟内有补机记录）
-				recentCount := d.history.GetMakeupCountForRegion(monitor.UserID, region, 5*time.Minute)
-
-				if recentCount == 0 {
-					// 8. 不在冷却期内，添加补机记录
-					d.history.AddMakeupRecordWithRegion(monitor.UserID, needCount, region)
-
-					// 将任务添加到补机队列 - 每次都创建新任务
-					makeupQueue.AddToQueueWithRegion(monitor.UserID, needCount, region)
-					log.Printf("主动检测: 用户[%s]在区域[%s]需要补机%d台", monitor.UserID, region, needCount)
-
-					// 将结果添加到结果列表
-					results = append(results, DetectResult{
-						UserID: monitor.UserID,
-						Count:  needCount,
-						Region: region,
-					})
-				} else {
-					// 新增日志：记录用户在冷却期内的补机情况
-					log.Printf("主动检测: 用户[%s]在区域[%s]处于冷却期内，5分钟内已补机%d台，暂不补机",
-						monitor.UserID, region, recentCount)
-				}
+				// 将任务添加到补机队列 - 每次都创建新任务
+				GetMakeupQueue().AddToQueueWithRegion(monitor.UserID, plan.Need, region)
+				log.Printf("主动检测: 用户[%s]在区域[%s]需要补机%d台", monitor.UserID, region, plan.Need)
+
+				// 将结果添加到结果列表
+				results = append(results, DetectResult{
+					UserID: monitor.UserID,
+					Count:  plan.Need,
+					Region: region,
+				})
+			case plan.RecentMakeup > 0:
+				// 新增日志：记录用户在冷却期内的补机情况
+				log.Printf("主动检测: 用户[%s]在区域[%s]处于冷却期内，5分钟内已补机%d台，暂不补机",
+					monitor.UserID, region, plan.RecentMakeup)
 			}
 
 			// 解锁
@@ -141,7 +150,10 @@ func (d *Detector) DetectAllUsers() []DetectResult {
 	return results
 }
 
-// DetectSingleUser 被动检测单个用户
+// passiveDetectDelay 被动检测前的等待时间，给实例重连留出时间，测试中可替换
+var passiveDetectDelay = 10 * time.Second
+
+// DetectSingleUser 被动检测单个用户，评估规则与主动检测相同
 func (d *Detector) DetectSingleUser(userID string) *DetectResult {
 	// 获取用户的监控配置
 	monitor, err := model.GetMonitorByUserID(d.db, userID)
@@ -156,10 +168,9 @@ func (d *Detector) DetectSingleUser(userID string) *DetectResult {
 	}
 
 	// 增加10秒延迟 (保留原有逻辑)
-	time.Sleep(10 * time.Second)
+	time.Sleep(passiveDetectDelay)
 
-	// 需要检查三个区域：香港、日本、新加坡
-	regions := []string{"ap-east-1", "ap-northeast-3", "ap-southeast-1"}
+	regions := GetRegions()
 
 	var result *DetectResult
 
@@ -169,72 +180,30 @@ func (d *Detector) DetectSingleUser(userID string) *DetectResult {
 	userLock.Lock()
 	defer userLock.Unlock()
 
-	// 遍历所有区域进行检测
+	// 遍历所有区域，按与主动检测相同的规则评估
 	for _, region := range regions {
-		// 不再需要对每个区域单独加锁，因为已经有了用户级别的锁
-
-		// 根据区域获取对应的阈值
-		threshold := model.GetThresholdByRegion(monitor, region)
-
-		// 如果阈值为0，跳过该区域检测
-		if threshold == 0 {
-			continue
-		}
-
-		// 获取用户在指定区域的实例数
-		instances := GlobalPool.GetInstancesByUserIDAndRegion(userID, region)
-		currentCount := len(instances)
-
-		// 获取用户在补机队列中的待处理任务
-		makeupQueue := GetMakeupQueue()
-
-		// 获取该用户该区域所有等待中的任务
-		waitingTasks := makeupQueue.GetWaitingTasksForRegion(region)
-
-		// 计算等待中的任务总数
-		pendingMakeupCount := 0
-		for _, task := range waitingTasks {
-			if task.UserID == userID {
-				pendingMakeupCount += task.TotalCount - task.CompletedCount
-			}
-		}
-
-		// 实际需要的实例总数是阈值
-		// 当前已有的实例数是：当前在线 + 待补机数量
-		actualCurrentCount := currentCount + pendingMakeupCount
-
-		// 判断是否需要补机
-		if actualCurrentCount < threshold {
-			needCount := threshold - actualCurrentCount
-
-			// 检查是否在冷却期内
-			recentCount := d.history.GetMakeupCountForRegion(userID, region, 5*time.Minute)
-
-			if recentCount == 0 {
-				if d.history == nil {
-					log.Printf("错误：用户[%s]的补机历史记录管理器为空", userID)
-					continue
-				}
-				// 添加补机记录
-				d.history.AddMakeupRecordWithRegion(userID, needCount, region)
-
-				// 将任务添加到补机队列 - 每次都创建新任务
-				makeupQueue.AddToQueueWithRegion(userID, needCount, region)
-				log.Printf("被动检测: 用户[%s]在区域[%s]需要补机%d台", userID, region, needCount)
-
-				// 仅返回第一个需要补机的区域结果
-				if result == nil {
-					result = &DetectResult{
-						UserID: userID,
-						Count:  needCount,
-						Region: region,
-					}
+		plan := d.planRegion(monitor, region)
+		switch {
+		case plan.WouldQueue:
+			// 添加补机记录
+			d.history.AddMakeupRecordWithRegion(userID, plan.Need, region)
+
+			// 将任务添加到补机队列 - 每次都创建新任务
+			GetMakeupQueue().AddToQueueWithRegion(userID, plan.Need, region)
+			log.Printf("被动检测: 用户[%s]在区域[%s]需要补机%d台", userID, region, plan.Need)
+
+			// 仅返回第一个需要补机的区域结果
+			if result == nil {
+				result = &DetectResult{
+					UserID: userID,
+					Count:  plan.Need,
+					Region: region,
 				}
-			} else {
-				// 新增日志：记录用户在冷却期内的补机情况
-				log.Printf("被动检测: 用户[%s]在区域[%s]处于冷却期内，5分钟内已补机%d台，暂不补机",
-					userID, region, recentCount)
 			}
+		case plan.RecentMakeup > 0:
+			// 新增日志：记录用户在冷却期内的补机情况
+			log.Printf("被动检测: 用户[%s]在区域[%s]处于冷却期内，5分钟内已补机%d台，暂不补机",
+				userID, region, plan.RecentMakeup)
 		}
 	}
 
@@ -0,0 +1,99 @@
+package pool
+
+import (
+	"testing"
+
+	"portal/model"
+	"portal/pkg/region"
+)
+
+// setCountCompliantOnly 设置补机检测是否只统计IP合规的实例，测试结束后恢复
+func setCountCompliantOnly(t *testing.T, enabled bool) {
+	t.Helper()
+	countCompliantOnlyOnce.Do(func() {})
+	old := countCompliantOnly
+	countCompliantOnly = enabled
+	t.Cleanup(func() { countCompliantOnly = old })
+}
+
+func TestDetectCountsCompliantInstancesOnly(t *testing.T) {
+	pool := usePool(t)
+	useMakeupQueue(t)
+	setCountCompliantOnly(t, true)
+	const userID = "compliant-only-user"
+	monitor := &model.Monitor{UserID: userID, IsEnabled: true, Threshold: 2, IsIPRangeEnabled: true, IPRange: "18.1."}
+	if err := globalDB.Create(monitor).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+	pool.UpdateInstance(&InstanceMetadata{InstanceID: "i-compliant", UserID: userID, Region: region.HK, IPv4: "18.1.2.3"})
+	pool.UpdateInstance(&InstanceMetadata{InstanceID: "i-noncompliant", UserID: userID, Region: region.HK, IPv4: "43.5.6.7"})
+
+	detector := NewDetector(globalDB, &MakeupHistory{records: make(map[makeupKey][]*MakeupRecord)})
+	queued := 0
+	for _, result := range detector.DetectAllUsers() {
+		if result.UserID == userID && result.Region == region.HK {
+			queued = result.Count
+		}
+	}
+	// 两台在线实例中只有一台IP合规，未达到阈值2，需补1台
+	if queued != 1 {
+		t.Fatalf("香港区补机数量 = %d, want 1", queued)
+	}
+}
+
+func TestCountUsableInstances(t *testing.T) {
+	instances := []*InstanceMetadata{{IPv4: "18.1.2.3"}, {IPv4: "43.5.6.7"}}
+	monitor := &model.Monitor{IsIPRangeEnabled: true, IPRange: "18.1."}
+
+	setCountCompliantOnly(t, false)
+	if got := countUsableInstances(monitor, region.HK, instances); got != 2 {
+		t.Fatalf("未开启只统计合规实例时数量 = %d, want 2", got)
+	}
+
+	setCountCompliantOnly(t, true)
+	if got := countUsableInstances(monitor, region.HK, instances); got != 1 {
+		t.Fatalf("只统计合规实例时数量 = %d, want 1", got)
+	}
+	// 该区域未设置IP段时不限制
+	if got := countUsableInstances(monitor, region.JP, instances); got != 2 {
+		t.Fatalf("未设置IP段的区域数量 = %d, want 2", got)
+	}
+	// 用户未开启IP段限制时不限制
+	monitor.IsIPRangeEnabled = false
+	if got := countUsableInstances(monitor, region.HK, instances); got != 2 {
+		t.Fatalf("未开启IP段限制时数量 = %d, want 2", got)
+	}
+}
+
+func TestDetectSkipsDisabledRegion(t *testing.T) {
+	usePool(t)
+	useMakeupQueue(t)
+	const userID = "region-disabled-user"
+	monitor := &model.Monitor{UserID: userID, IsEnabled: true, Threshold: 2, JpThreshold: 2}
+	if err := globalDB.Create(monitor).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+	disabled := false
+	if err := model.UpdateRegionEnabled(globalDB, userID, nil, &disabled, nil); err != nil {
+		t.Fatalf("关闭日本区补机失败: %v", err)
+	}
+
+	detector := NewDetector(globalDB, &MakeupHistory{records: make(map[makeupKey][]*MakeupRecord)})
+	queued := make(map[string]int)
+	for _, result := range detector.DetectAllUsers() {
+		if result.UserID == userID {
+			queued[result.Region] = result.Count
+		}
+	}
+	if queued[region.HK] != 2 {
+		t.Fatalf("香港区补机数量 = %d, want 2", queued[region.HK])
+	}
+	if count, ok := queued[region.JP]; ok {
+		t.Fatalf("关闭补机的日本区即使阈值非0也不应补机, 实际补机%d台", count)
+	}
+	for _, task := range GetMakeupQueue().GetWaitingTasksForRegion(region.JP) {
+		if task.UserID == userID {
+			t.Fatal("关闭补机的日本区不应创建补机任务")
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// pkg/pool/detectplan.go
+package pool
+
+import (
+	"fmt"
+	"time"
+
+	"portal/model"
+)
+
+// 主动检测的补机冷却时间
+const detectCooldown = 5 * time.Minute
+
+// DetectPlan 单个用户单个区域的检测评估结果
+type DetectPlan struct {
+	UserID       string `json:"user_id"`
+	Region       string `json:"region"`
+	Threshold    int    `json:"threshold"`         // 该区域的阈值
+	Counted      int    `json:"counted"`           // 计入阈值的在线实例数量
+	Pending      int    `json:"pending"`           // 补机队列中尚未完成的数量
+	Need         int    `json:"need"`              // 缺少的数量
+	RecentMakeup int    `json:"recent_makeup"`     // 冷却期内已补机的数量
+	WouldQueue   bool   `json:"would_queue"`       // 主动检测是否会创建补机任务
+	Reason       string `json:"reason,omitempty"`  // 不会创建任务的原因
+	Blocked      string `json:"blocked,omitempty"` // 任务创建后处理时会被阻塞的原因，如全局上限、区域无可用账号
+}
+
+// planRegion 按主动检测的规则评估用户在区域内是否需要补机，只读取状态
+func (d *Detector) planRegion(monitor *model.Monitor, regionCode string) DetectPlan {
+	plan := DetectPlan{
+		UserID:    monitor.UserID,
+		Region:    regionCode,
+		Threshold: model.GetThresholdByRegion(monitor, regionCode),
+	}
+
+	// 阈值为0、区域补机关闭、不允许在该区域开机时跳过
+	switch {
+	case plan.Threshold == 0:
+		plan.Reason = "阈值为0"
+		return plan
+	case !monitor.IsRegionEnabled(regionCode):
+		plan.Reason = "区域补机开关已关闭"
+		return plan
+	case !model.IsRegionAllowedForUser(d.db, monitor.UserID, regionCode):
+		plan.Reason = "用户不允许在该区域开机"
+		return plan
+	}
+
+	// 当前已有的实例数是：当前在线 + 待补机数量
+	instances := GlobalPool.GetInstancesByUserIDAndRegion(monitor.UserID, regionCode)
+	plan.Counted = countUsableInstances(monitor, regionCode, instances)
+	plan.Pending = pendingMakeupCount(monitor.UserID, regionCode)
+	if plan.Counted+plan.Pending >= plan.Threshold {
+		plan.Reason = "在线和待补机数量已达到阈值"
+		return plan
+	}
+	plan.Need = plan.Threshold - plan.Counted - plan.Pending
+
+	// 检查是否在冷却期内
+	plan.RecentMakeup = d.history.GetMakeupCountForRegion(monitor.UserID, regionCode, detectCooldown)
+	if plan.RecentMakeup > 0 {
+		plan.Reason = fmt.Sprintf("冷却期内，%v内已补机%d台", detectCooldown, plan.RecentMakeup)
+		return plan
+	}
+	plan.WouldQueue = true
+
+	// 任务创建后的处理阻塞
+	if limit, online, booting, pending, exceeded := globalLimitState(plan.Need); exceeded {
+		plan.Blocked = fmt.Sprintf("已达全局实例上限%d（在线%d，开机中%d，待补%d）", limit, online, booting, pending)
+	} else if !GetAccountPool().HasActiveAccountInRegion(regionCode) {
+		plan.Blocked = "区域内没有可用账号，任务将暂停等待"
+	}
+	return plan
+}
+
+// PreviewDetectAllUsers 按主动检测的规则评估所有开启监控的用户，返回补机计划
+// 不添加补机记录、不创建补机任务，也不发送通知
+func (d *Detector) PreviewDetectAllUsers() ([]DetectPlan, error) {
+	monitors, err := model.GetAllMonitors(d.db)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]DetectPlan, 0)
+	for i := range monitors {
+		if !monitors[i].IsEnabled {
+			continue
+		}
+		for _, regionCode := range GetRegions() {
+			plans = append(plans, d.planRegion(&monitors[i], regionCode))
+		}
+	}
+	return plans, nil
+}
@@ -0,0 +1,133 @@
+package pool
+
+import (
+	"testing"
+
+	"portal/model"
+	"portal/pkg/region"
+)
+
+func TestPreviewDetectExtraRegion(t *testing.T) {
+	usePool(t)
+	const userID = "extra-region-user"
+	monitor := &model.Monitor{UserID: userID, IsEnabled: true, RegionThresholds: `{"us-west-2":2}`}
+	if err := globalDB.Create(monitor).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+
+	found := false
+	for _, code := range GetRegions() {
+		found = found || code == "us-west-2"
+	}
+	if !found {
+		t.Fatalf("检测区域 = %v, 期望包含 EXTRA_REGIONS 登记的 us-west-2", GetRegions())
+	}
+
+	detector := NewDetector(globalDB, &MakeupHistory{records: make(map[makeupKey][]*MakeupRecord)})
+	plans, err := detector.PreviewDetectAllUsers()
+	if err != nil {
+		t.Fatalf("评估补机计划失败: %v", err)
+	}
+	for _, plan := range plans {
+		if plan.UserID != userID {
+			continue
+		}
+		switch plan.Region {
+		case "us-west-2":
+			if plan.Threshold != 2 || plan.Need != 2 || !plan.WouldQueue {
+				t.Errorf("额外区域的补机计划 = %+v, 期望阈值2且需补2台", plan)
+			}
+		default:
+			if plan.WouldQueue {
+				t.Errorf("未设置阈值的区域[%s]不应补机: %+v", plan.Region, plan)
+			}
+		}
+	}
+}
+
+func TestDetectSingleUserFollowsPlan(t *testing.T) {
+	usePool(t)
+	useMakeupQueue(t)
+	oldDelay := passiveDetectDelay
+	passiveDetectDelay = 0
+	t.Cleanup(func() { passiveDetectDelay = oldDelay })
+
+	const userID = "single-detect-user"
+	monitor := &model.Monitor{UserID: userID, IsEnabled: true, Threshold: 2, JpThreshold: 2}
+	if err := globalDB.Create(monitor).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+	disabled := false
+	if err := model.UpdateRegionEnabled(globalDB, userID, nil, &disabled, nil); err != nil {
+		t.Fatalf("关闭日本区补机失败: %v", err)
+	}
+
+	detector := NewDetector(globalDB, &MakeupHistory{records: make(map[makeupKey][]*MakeupRecord)})
+	result := detector.DetectSingleUser(userID)
+	if result == nil || result.Region != region.HK || result.Count != 2 {
+		t.Fatalf("被动检测结果 = %+v, 期望香港区补2台", result)
+	}
+	if pendingMakeupCount(userID, region.JP) != 0 {
+		t.Fatal("关闭补机的日本区不应创建补机任务")
+	}
+
+	// 待补数量已计入，且处于冷却期，再次检测不应重复创建任务
+	if again := detector.DetectSingleUser(userID); again != nil {
+		t.Fatalf("重复检测结果 = %+v, 期望不再补机", again)
+	}
+	if pending := pendingMakeupCount(userID, region.HK); pending != 2 {
+		t.Fatalf("香港区待补数量 = %d, want 2", pending)
+	}
+}
+
+func TestPreviewDetectMatchesStateWithoutSideEffects(t *testing.T) {
+	pool := usePool(t)
+	queue := useMakeupQueue(t)
+	const userID = "preview-plan-user"
+	monitor := &model.Monitor{UserID: userID, IsEnabled: true, Threshold: 1, JpThreshold: 3, SgThreshold: 2}
+	if err := globalDB.Create(monitor).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+	// 日本区在线1台且待补1台，仍缺1台；新加坡区在线2台已达到阈值；香港区处于冷却期
+	pool.UpdateInstance(&InstanceMetadata{InstanceID: "i-preview-jp", UserID: userID, Region: region.JP})
+	pool.UpdateInstance(&InstanceMetadata{InstanceID: "i-preview-sg-1", UserID: userID, Region: region.SG})
+	pool.UpdateInstance(&InstanceMetadata{InstanceID: "i-preview-sg-2", UserID: userID, Region: region.SG})
+	queue.AddToQueueWithRegion(userID, 1, region.JP)
+	history := &MakeupHistory{records: make(map[makeupKey][]*MakeupRecord)}
+	history.AddMakeupRecordWithRegion(userID, 1, region.HK)
+
+	detector := NewDetector(globalDB, history)
+	plans, err := detector.PreviewDetectAllUsers()
+	if err != nil {
+		t.Fatalf("评估补机计划失败: %v", err)
+	}
+	got := make(map[string]DetectPlan)
+	for _, plan := range plans {
+		if plan.UserID == userID {
+			got[plan.Region] = plan
+		}
+	}
+
+	if plan := got[region.HK]; plan.WouldQueue || plan.Need != 1 || plan.RecentMakeup != 1 {
+		t.Errorf("香港区计划 = %+v, 期望缺1台但处于冷却期", plan)
+	}
+	if plan := got[region.JP]; !plan.WouldQueue || plan.Counted != 1 || plan.Pending != 1 || plan.Need != 1 {
+		t.Errorf("日本区计划 = %+v, 期望在线1台、待补1台、需补1台", plan)
+	}
+	if plan := got[region.SG]; plan.WouldQueue || plan.Counted != 2 || plan.Need != 0 {
+		t.Errorf("新加坡区计划 = %+v, 期望已达到阈值", plan)
+	}
+
+	// 评估不创建补机任务，也不添加补机记录
+	if tasks := len(queue.GetWaitingTasksForRegion(region.JP)); tasks != 1 {
+		t.Fatalf("日本区等待中的任务 = %d, 评估不应创建补机任务", tasks)
+	}
+	for _, code := range []string{region.HK, region.SG} {
+		if tasks := len(queue.GetWaitingTasksForRegion(code)); tasks != 0 {
+			t.Fatalf("区域[%s]等待中的任务 = %d, 评估不应创建补机任务", code, tasks)
+		}
+	}
+	if records := history.GetAllRecords(); len(records) != 1 {
+		t.Fatalf("补机记录 = %+v, 评估不应添加补机记录", records)
+	}
+}
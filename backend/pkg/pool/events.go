@@ -3,6 +3,7 @@ package pool
 
 import (
 	"log"
+	"time"
 )
 
 // AccountPoolEvent 定义账号池事件类型
@@ -10,11 +11,14 @@ type AccountPoolEvent string
 
 const (
 	// 定义事件类型常量
-	AccountAdded   AccountPoolEvent = "账号添加"
-	AccountReset   AccountPoolEvent = "账号重置"
-	ManualReset    AccountPoolEvent = "手动重置"
-	AccountDeleted AccountPoolEvent = "账号删除" // 账号删除事件
-	IPChanged      AccountPoolEvent = "IP变更" // 新增IP变更事件
+	AccountAdded    AccountPoolEvent = "账号添加"
+	AccountReset    AccountPoolEvent = "账号重置"
+	ManualReset     AccountPoolEvent = "手动重置"
+	AccountDeleted  AccountPoolEvent = "账号删除" // 账号删除事件
+	IPChanged       AccountPoolEvent = "IP变更" // 新增IP变更事件
+	RegionChanged   AccountPoolEvent = "区域变更" // 账号区域变更事件
+	AccountEnabled  AccountPoolEvent = "账号启用" // 停用的账号重新启用
+	AccountDisabled AccountPoolEvent = "账号停用" // 账号停用，从账号池移除但不删除
 )
 
 // AccountPoolListener 账号池事件监听器接口
@@ -27,10 +31,26 @@ type IPChangeListener interface {
 	OnIPChangeEvent(instanceID string, newIP string)
 }
 
+// AccountFailure 补机过程中账号被移除或标记失败的信息
+type AccountFailure struct {
+	AccountID string    // 账号ID
+	UserID    string    // 账号所属用户ID
+	Region    string    // 开机区域
+	Reason    string    // 失败原因
+	Removed   bool      // 是否已从账号池移除
+	Time      time.Time // 发生时间
+}
+
+// AccountFailureListener 账号失败事件监听器接口
+type AccountFailureListener interface {
+	OnAccountFailure(failure AccountFailure)
+}
+
 // EventManager 事件管理器，负责注册和触发事件
 type EventManager struct {
 	accountListeners []AccountPoolListener
 	ipListeners      []IPChangeListener
+	failureListeners []AccountFailureListener
 }
 
 // NewEventManager 创建新的事件管理器
@@ -38,6 +58,7 @@ func NewEventManager() *EventManager {
 	return &EventManager{
 		accountListeners: make([]AccountPoolListener, 0),
 		ipListeners:      make([]IPChangeListener, 0),
+		failureListeners: make([]AccountFailureListener, 0),
 	}
 }
 
@@ -53,6 +74,12 @@ func (em *EventManager) RegisterIPChangeListener(listener IPChangeListener) {
 	log.Printf("已注册新的IP变更事件监听器")
 }
 
+// RegisterAccountFailureListener 注册账号失败事件监听器
+func (em *EventManager) RegisterAccountFailureListener(listener AccountFailureListener) {
+	em.failureListeners = append(em.failureListeners, listener)
+	log.Printf("已注册新的账号失败事件监听器")
+}
+
 // TriggerEvent 触发账号事件
 func (em *EventManager) TriggerEvent(event AccountPoolEvent, accountID string) {
 	log.Printf("调试: 触发事件: 类型=%s, 账号ID=%s, 监听器数量=%d",
@@ -93,6 +120,22 @@ func (em *EventManager) TriggerIPChangeEvent(instanceID string, newIP string) {
 	}
 }
 
+// TriggerAccountFailureEvent 触发账号失败事件
+func (em *EventManager) TriggerAccountFailureEvent(failure AccountFailure) {
+	log.Printf("触发账号失败事件: 账号ID=%s, 用户=%s, 区域=%s, 已移除=%v, 原因=%s",
+		failure.AccountID, failure.UserID, failure.Region, failure.Removed, failure.Reason)
+	for _, listener := range em.failureListeners {
+		func(l AccountFailureListener) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("严重错误: 账号失败事件监听器panic: %v", r)
+				}
+			}()
+			l.OnAccountFailure(failure)
+		}(listener)
+	}
+}
+
 // 全局事件管理器
 var (
 	globalEventManager *EventManager
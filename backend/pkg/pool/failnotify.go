@@ -0,0 +1,73 @@
+// pkg/pool/failnotify.go
+package pool
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"portal/pkg/tg"
+	"portal/repository"
+)
+
+// accountFailureNotifier 账号在补机过程中失效时发送TG通知
+// 通过 MAKEUP_FAILURE_NOTIFY 配置通知对象：user（账号所属用户）、admin（管理员），可用逗号组合，默认不通知
+type accountFailureNotifier struct {
+	notifyUser  bool
+	notifyAdmin bool
+}
+
+// newAccountFailureNotifier 根据环境变量创建通知器，未配置时返回nil
+func newAccountFailureNotifier() *accountFailureNotifier {
+	value := os.Getenv("MAKEUP_FAILURE_NOTIFY")
+	if value == "" {
+		return nil
+	}
+
+	notifier := &accountFailureNotifier{}
+	for _, target := range strings.Split(value, ",") {
+		switch strings.TrimSpace(target) {
+		case "user":
+			notifier.notifyUser = true
+		case "admin":
+			notifier.notifyAdmin = true
+		case "both":
+			notifier.notifyUser = true
+			notifier.notifyAdmin = true
+		default:
+			log.Printf("MAKEUP_FAILURE_NOTIFY包含无效值: %s", target)
+		}
+	}
+
+	if !notifier.notifyUser && !notifier.notifyAdmin {
+		return nil
+	}
+	return notifier
+}
+
+// OnAccountFailure 实现AccountFailureListener接口
+func (n *accountFailureNotifier) OnAccountFailure(failure AccountFailure) {
+	action := "已标记为跳过"
+	if failure.Removed {
+		action = "已从账号池移除"
+	}
+	message := fmt.Sprintf("⚠️ 补机账号异常\n账号ID: %s\n区域: %s\n状态: %s\n原因: %s\n时间: %s",
+		failure.AccountID, failure.Region, action, failure.Reason,
+		failure.Time.Format("2006-01-02 15:04:05"))
+
+	// 发送通知不阻塞补机流程
+	go func() {
+		db := repository.GetDB()
+		if n.notifyUser && failure.UserID != "" {
+			if err := tg.NotifyUserMessage(db, failure.UserID, message); err != nil {
+				log.Printf("发送账号[%s]异常通知给用户[%s]失败: %v", failure.AccountID, failure.UserID, err)
+			}
+		}
+		if n.notifyAdmin {
+			if err := tg.NotifyAdminMessage(db, fmt.Sprintf("%s\n所属用户: %s", message, failure.UserID)); err != nil {
+				log.Printf("发送账号[%s]异常通知给管理员失败: %v", failure.AccountID, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,89 @@
+package pool
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"portal/pkg/aws"
+)
+
+// failureRecorder 记录指定账号的失败事件
+type failureRecorder struct {
+	accountID string
+	failures  chan AccountFailure
+}
+
+// OnAccountFailure 实现AccountFailureListener接口
+func (r *failureRecorder) OnAccountFailure(failure AccountFailure) {
+	if failure.AccountID == r.accountID {
+		r.failures <- failure
+	}
+}
+
+func TestHandleAccountErrorReportsRemoval(t *testing.T) {
+	// 所有AWS请求均返回凭证无效
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type":"UnrecognizedClientException","message":"The security token included in the request is invalid."}`)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+
+	const accountID = "9411"
+	recorder := &failureRecorder{accountID: accountID, failures: make(chan AccountFailure, 1)}
+	GetEventManager().RegisterAccountFailureListener(recorder)
+
+	accountPool := GetAccountPool()
+	account := testAccount(accountID, "ap-northeast-1", nil)
+	accountPool.mutex.Lock()
+	accountPool.accounts[accountID] = account
+	accountPool.mutex.Unlock()
+	t.Cleanup(func() { accountPool.RemoveAccount(accountID) })
+
+	awsClient := &aws.AWSClient{AccessKey: "AKIA9411", SecretKey: "secret"}
+	handleAccountError(globalDB, accountID, "AuthFailure: AWS was not able to validate the provided access credentials", awsClient, "t3.micro", "ap-northeast-1")
+
+	if accountPool.GetAccount(accountID) != nil {
+		t.Fatalf("失效账号应从账号池移除")
+	}
+	select {
+	case failure := <-recorder.failures:
+		if !failure.Removed || failure.UserID != account.UserID || failure.Region != "ap-northeast-1" || failure.Reason != "账号已失效" {
+			t.Fatalf("失败事件 = %+v", failure)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("移除账号后未触发失败事件")
+	}
+}
+
+func TestNewAccountFailureNotifier(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantNil   bool
+		wantUser  bool
+		wantAdmin bool
+	}{
+		{"", true, false, false},
+		{"user", false, true, false},
+		{"admin", false, false, true},
+		{"user, admin", false, true, true},
+		{"both", false, true, true},
+		{"nobody", true, false, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("MAKEUP_FAILURE_NOTIFY", tt.value)
+		notifier := newAccountFailureNotifier()
+		if (notifier == nil) != tt.wantNil {
+			t.Errorf("MAKEUP_FAILURE_NOTIFY=%q: 通知器为nil = %v, want %v", tt.value, notifier == nil, tt.wantNil)
+			continue
+		}
+		if notifier != nil && (notifier.notifyUser != tt.wantUser || notifier.notifyAdmin != tt.wantAdmin) {
+			t.Errorf("MAKEUP_FAILURE_NOTIFY=%q: user=%v admin=%v, want user=%v admin=%v",
+				tt.value, notifier.notifyUser, notifier.notifyAdmin, tt.wantUser, tt.wantAdmin)
+		}
+	}
+}
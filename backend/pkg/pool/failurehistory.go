@@ -0,0 +1,58 @@
+// pkg/pool/failurehistory.go
+package pool
+
+import (
+	"time"
+)
+
+// 每个账号保留的最近失败记录数量
+const maxAccountFailureHistory = 10
+
+// AccountFailureRecord 账号的一次开机失败记录
+type AccountFailureRecord struct {
+	Time         time.Time `json:"time"`          // 失败时间
+	InstanceType string    `json:"instance_type"` // 实例类型
+	Region       string    `json:"region"`        // 区域代码
+	Reason       string    `json:"reason"`        // 处理后的失败原因，与账号的错误备注一致
+	RawError     string    `json:"raw_error"`     // AWS返回的原始错误
+}
+
+// RecordAccountFailure 记录账号的开机失败，只保留最近的 maxAccountFailureHistory 条
+// 失败原因取处理错误后账号的错误备注，账号已从账号池移除时记为已移除
+func (p *AccountPool) RecordAccountFailure(accountID string, instanceType string, regionCode string, rawError string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	reason := "账号已从账号池移除"
+	if account, exists := p.accounts[accountID]; exists {
+		reason = account.ErrorNote
+	}
+
+	if p.failures == nil {
+		p.failures = make(map[string][]AccountFailureRecord)
+	}
+	history := append(p.failures[accountID], AccountFailureRecord{
+		Time:         time.Now(),
+		InstanceType: instanceType,
+		Region:       regionCode,
+		Reason:       reason,
+		RawError:     rawError,
+	})
+	if len(history) > maxAccountFailureHistory {
+		history = history[len(history)-maxAccountFailureHistory:]
+	}
+	p.failures[accountID] = history
+}
+
+// GetAccountFailures 获取账号最近的开机失败记录，按时间从新到旧排列
+func (p *AccountPool) GetAccountFailures(accountID string) []AccountFailureRecord {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	history := p.failures[accountID]
+	result := make([]AccountFailureRecord, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		result = append(result, history[i])
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package pool
+
+import (
+	"fmt"
+	"testing"
+
+	"portal/pkg/region"
+)
+
+func TestAccountFailuresAccumulateUpToCap(t *testing.T) {
+	account := testAccount("1", region.HK, nil)
+	account.ErrorNote = "额度不足"
+	p := newTestAccountPool(account)
+
+	total := maxAccountFailureHistory + 3
+	for i := 0; i < total; i++ {
+		p.RecordAccountFailure("1", "c5n.large", region.HK, fmt.Sprintf("error-%d", i))
+	}
+
+	failures := p.GetAccountFailures("1")
+	if len(failures) != maxAccountFailureHistory {
+		t.Fatalf("失败记录数量 = %d, 期望 %d", len(failures), maxAccountFailureHistory)
+	}
+	// 按时间从新到旧排列，超出上限时丢弃最早的记录
+	for i, failure := range failures {
+		want := fmt.Sprintf("error-%d", total-1-i)
+		if failure.RawError != want {
+			t.Fatalf("第%d条失败记录 = %s, 期望 %s", i, failure.RawError, want)
+		}
+		if failure.Reason != "额度不足" || failure.Region != region.HK || failure.InstanceType != "c5n.large" {
+			t.Fatalf("失败记录内容不正确: %+v", failure)
+		}
+	}
+
+	// 账号已移除时仍记录失败，原因标记为已移除
+	p.RecordAccountFailure("2", "c5n.large", region.HK, "AuthFailure")
+	removed := p.GetAccountFailures("2")
+	if len(removed) != 1 || removed[0].Reason != "账号已从账号池移除" {
+		t.Fatalf("已移除账号的失败记录 = %+v", removed)
+	}
+	if len(p.GetAccountFailures("3")) != 0 {
+		t.Fatal("没有失败的账号不应有失败记录")
+	}
+}
@@ -0,0 +1,92 @@
+// pkg/pool/family.go
+package pool
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	familyCaps     map[string]int
+	familyCapsOnce sync.Once
+)
+
+// instanceFamily 获取实例类型所属的实例族，如 c5n.2xlarge -> c5n
+func instanceFamily(instanceType string) string {
+	if idx := strings.Index(instanceType, "."); idx > 0 {
+		return instanceType[:idx]
+	}
+	if instanceType == "" {
+		return "default"
+	}
+	return instanceType
+}
+
+// getFamilyCap 获取账号在一个区域内某实例族可使用的最大实例计数
+// REGION_FAMILY_CAPS 中配置的实例族（如 "p4d=8,g5=4"）使用独立上限；
+// 未配置的实例族共用同一份配额（标准实例共享vCPU配额），合计上限为 maxRegionUsedCount
+func getFamilyCap(family string) int {
+	if limit, ok := ownFamilyCap(family); ok {
+		return limit
+	}
+	return maxRegionUsedCount
+}
+
+// ownFamilyCap 获取实例族在 REGION_FAMILY_CAPS 中配置的独立上限
+func ownFamilyCap(family string) (int, bool) {
+	familyCapsOnce.Do(func() {
+		familyCaps = make(map[string]int)
+		value := os.Getenv("REGION_FAMILY_CAPS")
+		if value == "" {
+			return
+		}
+		for _, item := range strings.Split(value, ",") {
+			parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+			if len(parts) != 2 {
+				log.Printf("REGION_FAMILY_CAPS配置项无效: %s", item)
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil || n < 0 {
+				log.Printf("REGION_FAMILY_CAPS配置项无效: %s", item)
+				continue
+			}
+			familyCaps[strings.TrimSpace(parts[0])] = n
+		}
+	})
+
+	limit, ok := familyCaps[family]
+	return limit, ok
+}
+
+// familyUsage 获取实例族已使用的计数和上限
+// 配置了独立上限的实例族只统计自身；其余实例族统计所有共用配额的实例族之和
+func familyUsage(usedCount map[string]int, family string) (used int, limit int) {
+	if limit, ok := ownFamilyCap(family); ok {
+		return usedCount[family], limit
+	}
+	for f, count := range usedCount {
+		if _, ok := ownFamilyCap(f); !ok {
+			used += count
+		}
+	}
+	return used, maxRegionUsedCount
+}
+
+// TotalUsedCount 账号在当前区域所有实例族的使用计数之和
+func (a *AccountInfo) TotalUsedCount() int {
+	total := 0
+	for _, count := range a.FamilyUsedCount {
+		total += count
+	}
+	return total
+}
+
+// familyHeadroom 账号在当前区域某实例族还可使用的实例计数
+func (a *AccountInfo) familyHeadroom(family string) int {
+	used, limit := familyUsage(a.FamilyUsedCount, family)
+	return limit - used
+}
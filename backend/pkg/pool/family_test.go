@@ -0,0 +1,58 @@
+package pool
+
+import "testing"
+
+// setFamilyCaps 设置实例族独立上限，测试结束后恢复
+func setFamilyCaps(t *testing.T, caps map[string]int) {
+	t.Helper()
+	familyCapsOnce.Do(func() {})
+	old := familyCaps
+	familyCaps = caps
+	t.Cleanup(func() { familyCaps = old })
+}
+
+func TestFamilyHeadroomMixedFamilies(t *testing.T) {
+	tests := []struct {
+		name     string
+		caps     map[string]int
+		used     map[string]int
+		headroom map[string]int
+	}{
+		{
+			name:     "未配置的实例族共用配额",
+			caps:     map[string]int{},
+			used:     map[string]int{"c5n": 2, "t3": 1},
+			headroom: map[string]int{"c5n": 1, "t3": 1, "m5": 1},
+		},
+		{
+			name:     "共用配额用满后所有标准实例族都不可用",
+			caps:     map[string]int{},
+			used:     map[string]int{"c5n": 2, "t3": 2},
+			headroom: map[string]int{"c5n": 0, "t3": 0, "m5": 0},
+		},
+		{
+			name:     "独立上限的实例族不占用共用配额",
+			caps:     map[string]int{"p4d": 8},
+			used:     map[string]int{"c5n": 4, "p4d": 2},
+			headroom: map[string]int{"c5n": 0, "m5": 0, "p4d": 6},
+		},
+		{
+			name:     "共用配额不受独立实例族使用量影响",
+			caps:     map[string]int{"p4d": 8},
+			used:     map[string]int{"c5n": 1, "p4d": 8},
+			headroom: map[string]int{"c5n": 3, "t3": 3, "p4d": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFamilyCaps(t, tt.caps)
+			account := &AccountInfo{FamilyUsedCount: tt.used}
+			for family, want := range tt.headroom {
+				if got := account.familyHeadroom(family); got != want {
+					t.Errorf("实例族[%s]余量应为%d，实际为%d", family, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// pkg/pool/globallimit.go
+package pool
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"portal/pkg/tg"
+	"portal/repository"
+)
+
+// 全局上限告警的最小间隔，避免补机反复触发时刷屏
+const globalLimitAlertInterval = 10 * time.Minute
+
+var (
+	globalMaxInstances     int
+	globalMaxInstancesOnce sync.Once
+
+	globalLimitAlertMu   sync.Mutex
+	globalLimitAlertedAt time.Time
+)
+
+// getGlobalMaxInstances 获取整个平台允许的最大实例数，可通过 GLOBAL_MAX_INSTANCES 配置，0表示不限制
+func getGlobalMaxInstances() int {
+	globalMaxInstancesOnce.Do(func() {
+		if value := os.Getenv("GLOBAL_MAX_INSTANCES"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				globalMaxInstances = n
+			} else {
+				log.Printf("GLOBAL_MAX_INSTANCES配置无效: %s，不限制全局实例数", value)
+			}
+		}
+	})
+	return globalMaxInstances
+}
+
+// InstanceCount 获取连接池中在线实例的数量
+func (pool *Pool) InstanceCount() int {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	return len(pool.Instances)
+}
+
+// globalLimitState 获取全局上限、在线数量、开机未上报数量、待补数量，以及再启动requested台后是否超过上限，不记录日志也不告警
+// 补机任务开机后等待上报期间，该实例同时计入开机未上报和待补数量，统计偏保守
+func globalLimitState(requested int) (limit, online, booting, pending int, exceeded bool) {
+	limit = getGlobalMaxInstances()
+	if limit <= 0 {
+		return limit, 0, 0, 0, false
+	}
+
+	if GlobalPool != nil {
+		online = GlobalPool.InstanceCount()
+	}
+	booting = totalUnreportedLaunches()
+	pending = GetMakeupQueue().GetTotalPendingCount()
+
+	return limit, online, booting, pending, online+booting+pending+requested > limit
+}
+
+// CheckGlobalInstanceLimit 检查再启动requested台实例后是否超过全局上限
+// 统计口径为连接池中的在线实例数、已开机但尚未上报的实例数，加上补机队列中尚未完成的数量
+// 补机任务自身的开机已计入待补数量，调用时传0
+func CheckGlobalInstanceLimit(requested int) error {
+	limit, online, booting, pending, exceeded := globalLimitState(requested)
+	if !exceeded {
+		return nil
+	}
+
+	err := fmt.Errorf("%w%d（在线%d，开机中%d，待补%d，本次%d），拒绝开机", ErrGlobalLimitReached, limit, online, booting, pending, requested)
+	log.Printf("%v", err)
+	alertGlobalLimit(limit, online, booting, pending, requested)
+	return err
+}
+
+// alertGlobalLimit 通知管理员已触及全局实例上限，间隔内只发送一次
+func alertGlobalLimit(limit, online, booting, pending, requested int) {
+	globalLimitAlertMu.Lock()
+	if time.Since(globalLimitAlertedAt) < globalLimitAlertInterval {
+		globalLimitAlertMu.Unlock()
+		return
+	}
+	globalLimitAlertedAt = time.Now()
+	globalLimitAlertMu.Unlock()
+
+	message := fmt.Sprintf("⚠️ 已达到全局实例上限\n上限: %d\n在线实例: %d\n开机未上报: %d\n待补数量: %d\n本次请求: %d\n时间: %s",
+		limit, online, booting, pending, requested, time.Now().Format("2006-01-02 15:04:05"))
+	go func() {
+		if err := tg.NotifyAdminMessage(repository.GetDB(), message); err != nil {
+			log.Printf("发送全局实例上限通知失败: %v", err)
+		}
+	}()
+}
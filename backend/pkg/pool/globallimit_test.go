@@ -0,0 +1,89 @@
+package pool
+
+import (
+	"errors"
+	"testing"
+)
+
+// useMakeupQueue 将全局补机队列替换为不启动处理协程的新队列
+func useMakeupQueue(t *testing.T) *MakeupQueue {
+	t.Helper()
+	makeupQueueOnce.Do(func() {})
+	old := globalMakeupQueue
+	globalMakeupQueue = newMakeupQueue()
+	t.Cleanup(func() {
+		if old == nil {
+			old = newMakeupQueue()
+		}
+		globalMakeupQueue = old
+	})
+	return globalMakeupQueue
+}
+
+// setGlobalMaxInstances 设置全局实例上限，测试结束后恢复
+func setGlobalMaxInstances(t *testing.T, n int) {
+	t.Helper()
+	globalMaxInstancesOnce.Do(func() {})
+	old := globalMaxInstances
+	globalMaxInstances = n
+	t.Cleanup(func() { globalMaxInstances = old })
+}
+
+func TestCheckGlobalInstanceLimit(t *testing.T) {
+	p := usePool(t)
+	mq := useMakeupQueue(t)
+
+	p.UpdateInstances([]*InstanceMetadata{
+		{InstanceID: "i-1", UserID: "u1"},
+		{InstanceID: "i-2", UserID: "u2"},
+	})
+	mq.AddToQueueWithRegion("u1", 1, "ap-east-1")
+
+	setGlobalMaxInstances(t, 0)
+	if err := CheckGlobalInstanceLimit(100); err != nil {
+		t.Fatalf("未配置上限时不应拒绝开机: %v", err)
+	}
+
+	// 在线2台 + 待补1台
+	setGlobalMaxInstances(t, 4)
+	if err := CheckGlobalInstanceLimit(1); err != nil {
+		t.Fatalf("未超过上限时应允许开机: %v", err)
+	}
+	if err := CheckGlobalInstanceLimit(2); !errors.Is(err, ErrGlobalLimitReached) {
+		t.Fatalf("超过上限时应返回 ErrGlobalLimitReached，实际为 %v", err)
+	}
+
+	// 补机任务自身的开机已计入待补数量，达到上限后不再放行
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-3", UserID: "u3"})
+	if err := CheckGlobalInstanceLimit(0); err != nil {
+		t.Fatalf("恰好达到上限时补机任务自身的开机应放行: %v", err)
+	}
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-4", UserID: "u4"})
+	if err := CheckGlobalInstanceLimit(0); !errors.Is(err, ErrGlobalLimitReached) {
+		t.Fatalf("在线与待补之和超过上限后应拒绝开机，实际为 %v", err)
+	}
+}
+
+func TestGlobalLimitCountsUnreportedLaunches(t *testing.T) {
+	p := usePool(t)
+	useMakeupQueue(t)
+	setGlobalMaxInstances(t, 2)
+
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-online", UserID: "u1"})
+	RecordUserLaunches("u1", "ap-east-1", "i-boot")
+	t.Cleanup(func() { forgetLaunch("i-boot") })
+
+	// 在线1台 + 开机未上报1台，已达到上限
+	if err := CheckGlobalInstanceLimit(1); !errors.Is(err, ErrGlobalLimitReached) {
+		t.Fatalf("开机未上报的实例应计入全局上限，实际为 %v", err)
+	}
+
+	// 上报后只按在线计数，不重复计入
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-boot", UserID: "u1"})
+	if _, online, booting, _, _ := globalLimitState(0); online != 2 || booting != 0 {
+		t.Fatalf("上报后在线%d台，开机中%d台，期望2和0", online, booting)
+	}
+	if err := CheckGlobalInstanceLimit(0); err != nil {
+		t.Fatalf("恰好达到上限时应放行: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+// pkg/pool/grace.go
+package pool
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// 默认启动宽限期，期间实例陆续重连，不做离线检测也不发送上下线通知
+const defaultStartupGrace = 2 * time.Minute
+
+var (
+	startupGrace     time.Duration
+	startupGraceOnce sync.Once
+)
+
+// getStartupGrace 获取启动宽限期，可通过 POOL_STARTUP_GRACE 配置，如"3m"，设为0表示不启用
+func getStartupGrace() time.Duration {
+	startupGraceOnce.Do(func() {
+		startupGrace = defaultStartupGrace
+		if value := os.Getenv("POOL_STARTUP_GRACE"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+				startupGrace = d
+			} else {
+				log.Printf("POOL_STARTUP_GRACE配置无效: %s，使用默认值%v", value, defaultStartupGrace)
+			}
+		}
+	})
+	return startupGrace
+}
+
+// InStartupGrace 判断连接池是否处于启动宽限期
+func (pool *Pool) InStartupGrace() bool {
+	return time.Since(pool.startedAt) < getStartupGrace()
+}
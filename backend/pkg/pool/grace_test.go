@@ -0,0 +1,56 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// setStartupGrace 设置启动宽限期，测试结束后恢复
+func setStartupGrace(t *testing.T, d time.Duration) {
+	t.Helper()
+	startupGraceOnce.Do(func() {})
+	old := startupGrace
+	startupGrace = d
+	t.Cleanup(func() { startupGrace = old })
+}
+
+func TestOnlineNotificationSuppressedDuringStartupGrace(t *testing.T) {
+	const userID = "startup-grace-user"
+	setStartupGrace(t, time.Hour)
+	notified := make(chan string, 4)
+	old := sendInstanceOnlineNotification
+	sendInstanceOnlineNotification = func(db *gorm.DB, m *InstanceMetadata) {
+		if m.UserID == userID {
+			notified <- m.InstanceID
+		}
+	}
+	t.Cleanup(func() { sendInstanceOnlineNotification = old })
+
+	p := NewPool()
+	if !p.InStartupGrace() {
+		t.Fatal("新建的连接池应处于启动宽限期")
+	}
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-grace-1", UserID: userID, Region: "ap-east-1"})
+	select {
+	case id := <-notified:
+		t.Fatalf("宽限期内不应发送上线通知: %s", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// 宽限期结束后恢复上线通知
+	p.startedAt = time.Now().Add(-2 * time.Hour)
+	if p.InStartupGrace() {
+		t.Fatal("宽限期已过，不应再处于启动宽限期")
+	}
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-grace-2", UserID: userID, Region: "ap-east-1"})
+	select {
+	case id := <-notified:
+		if id != "i-grace-2" {
+			t.Fatalf("上线通知的实例 = %s, want i-grace-2", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("宽限期结束后未发送上线通知")
+	}
+}
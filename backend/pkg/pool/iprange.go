@@ -4,6 +4,7 @@ package pool
 import (
 	"context"
 	"log"
+	"os"
 	"portal/model"
 	"portal/service/instance"
 	"strings"
@@ -20,6 +21,73 @@ type IPRangeChecker struct {
 	pool            *Pool                     // 连接池
 	userMu          sync.Map                  // 用户级别的互斥锁映射
 	userChecking    sync.Map                  // 用户当前是否正在检查的标记
+	rotations       sync.Map                  // 实例ID -> 最近一次更换IP的记录
+}
+
+// ipRotation 实例最近一次更换IP的记录
+type ipRotation struct {
+	IP string    // 更换后的新IP
+	At time.Time // 更换时间
+}
+
+// 默认更换IP后的冷却时间，冷却期内不再检查该实例
+const defaultRotationCooldown = 10 * time.Minute
+
+var (
+	rotationCooldown     time.Duration
+	rotationCooldownOnce sync.Once
+)
+
+// getRotationCooldown 获取更换IP后的冷却时间，可通过环境变量 IPRANGE_ROTATE_COOLDOWN 配置（如"10m"，"0"表示不冷却）
+func getRotationCooldown() time.Duration {
+	rotationCooldownOnce.Do(func() {
+		rotationCooldown = defaultRotationCooldown
+		if v := os.Getenv("IPRANGE_ROTATE_COOLDOWN"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				rotationCooldown = d
+			} else {
+				log.Printf("IPRANGE_ROTATE_COOLDOWN配置无效[%s]，使用默认值%v", v, defaultRotationCooldown)
+			}
+		}
+	})
+	return rotationCooldown
+}
+
+// recordRotation 记录实例更换IP的时间和新IP
+func (c *IPRangeChecker) recordRotation(instanceID, ip string) {
+	c.rotations.Store(instanceID, ipRotation{IP: ip, At: time.Now()})
+}
+
+// resolveCheckIP 确定本次检查使用的实例IP，返回false表示实例仍在冷却期内应跳过
+// 更换IP后在实例上报确认新IP之前，以更换得到的IP为准，避免用连接池中的旧IP误判
+func (c *IPRangeChecker) resolveCheckIP(inst *InstanceMetadata) (string, bool) {
+	value, ok := c.rotations.Load(inst.InstanceID)
+	if !ok {
+		return inst.IPv4, true
+	}
+	rotation := value.(ipRotation)
+
+	if time.Since(rotation.At) < getRotationCooldown() {
+		return "", false
+	}
+
+	// 上报的IP与更换结果一致，或更换后已有新的上报，以连接池中的IP为准
+	if inst.IPv4 == rotation.IP || inst.LastSeen.After(rotation.At) {
+		c.rotations.Delete(inst.InstanceID)
+		return inst.IPv4, true
+	}
+
+	return rotation.IP, true
+}
+
+// pruneRotations 清理已不在连接池中的实例的更换记录
+func (c *IPRangeChecker) pruneRotations() {
+	c.rotations.Range(func(key, _ interface{}) bool {
+		if !c.pool.HasInstance(key.(string)) {
+			c.rotations.Delete(key)
+		}
+		return true
+	})
 }
 
 // NewIPRangeChecker 创建新的IP范围检测器
@@ -102,23 +170,21 @@ func (c *IPRangeChecker) CheckSingleUser(ctx context.Context, userID string) err
 	// 3. 逐个检查实例IP
 	for _, inst := range instances { // 注意这里用 inst 避免与包名冲突
 		// 获取当前实例所在区域对应的IP范围
-		var ipRange string
-		switch inst.Region {
-		case "ap-northeast-3": // 日本区域
-			ipRange = config.JpIPRange
-		case "ap-southeast-1": // 新加坡区域
-			ipRange = config.SgIPRange
-		default: // 默认香港区域
-			ipRange = config.IPRange
-		}
+		ipRange := config.IPRangeForRegion(inst.Region)
 
 		// 如果当前区域没有设置IP范围，则跳过该实例
 		if ipRange == "" {
 			continue
 		}
 
+		// 刚更换过IP的实例在冷却期内跳过，等待上报确认新IP
+		currentIP, ok := c.resolveCheckIP(inst)
+		if !ok {
+			continue
+		}
+
 		// 检查IP是否符合范围
-		if c.isIPMatchRange(inst.IPv4, ipRange) {
+		if c.isIPMatchRange(currentIP, ipRange) {
 			continue
 		}
 
@@ -135,6 +201,12 @@ func (c *IPRangeChecker) CheckSingleUser(ctx context.Context, userID string) err
 				},
 			}
 
+			// 所有用户共享全局限流，避免大范围不合规时同时发起大量AWS调用
+			if err := getChangeIPLimiter().Wait(ctx); err != nil {
+				log.Printf("等待更换实例[%s]IP的限流令牌被取消: %v", inst.InstanceID, err)
+				return err
+			}
+
 			results, err := c.instanceService.ChangeIP(ctx, inst.UserID, changeIPItems)
 			if err != nil {
 				log.Printf("更换实例[%s]IP失败: %v", inst.InstanceID, err)
@@ -160,6 +232,7 @@ func (c *IPRangeChecker) CheckSingleUser(ctx context.Context, userID string) err
 				log.Printf("实例[%s]无法获取新IP", inst.InstanceID)
 				break
 			}
+			c.recordRotation(inst.InstanceID, newIP)
 
 			// 检查新IP是否符合范围
 			if c.isIPMatchRange(newIP, ipRange) {
@@ -194,6 +267,9 @@ func (c *IPRangeChecker) CheckAllUsers(ctx context.Context) error {
 		}
 	}
 
+	// 清理已下线实例的更换记录
+	c.pruneRotations()
+
 	if len(enabledUsers) == 0 {
 		return nil
 	}
@@ -0,0 +1,102 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"portal/model"
+)
+
+// setRotationCooldown 设置更换IP后的冷却时间，测试结束后恢复
+func setRotationCooldown(t *testing.T, d time.Duration) {
+	t.Helper()
+	rotationCooldownOnce.Do(func() {})
+	old := rotationCooldown
+	rotationCooldown = d
+	t.Cleanup(func() { rotationCooldown = old })
+}
+
+func TestRotatedInstanceSkippedWithinCooldown(t *testing.T) {
+	setRotationCooldown(t, time.Minute)
+	c := &IPRangeChecker{}
+	inst := &InstanceMetadata{InstanceID: "i-rotated", IPv4: "198.51.100.1", LastSeen: time.Now()}
+
+	// 没有更换记录时使用上报的IP
+	if ip, ok := c.resolveCheckIP(inst); !ok || ip != "198.51.100.1" {
+		t.Fatalf("未更换过IP的实例 = %q, %v, 期望检查上报的IP", ip, ok)
+	}
+
+	c.recordRotation(inst.InstanceID, "203.0.113.7")
+	if _, ok := c.resolveCheckIP(inst); ok {
+		t.Fatal("冷却期内的实例应被跳过")
+	}
+
+	// 冷却期结束但实例尚未上报新IP，以更换得到的IP为准
+	c.rotations.Store(inst.InstanceID, ipRotation{IP: "203.0.113.7", At: time.Now().Add(-2 * time.Minute)})
+	inst.LastSeen = time.Now().Add(-3 * time.Minute)
+	if ip, ok := c.resolveCheckIP(inst); !ok || ip != "203.0.113.7" {
+		t.Fatalf("冷却期后未上报的实例 = %q, %v, 期望检查更换得到的IP", ip, ok)
+	}
+
+	// 更换后已有新的上报，以上报的IP为准并清除记录
+	inst.LastSeen = time.Now()
+	if ip, ok := c.resolveCheckIP(inst); !ok || ip != "198.51.100.1" {
+		t.Fatalf("冷却期后已上报的实例 = %q, %v, 期望检查上报的IP", ip, ok)
+	}
+	if _, exists := c.rotations.Load(inst.InstanceID); exists {
+		t.Fatal("实例上报后应清除更换记录")
+	}
+}
+
+func TestRotationCooldownDisabled(t *testing.T) {
+	t.Setenv("IPRANGE_ROTATE_COOLDOWN", "0")
+	rotationCooldownOnce = sync.Once{}
+	t.Cleanup(func() { rotationCooldownOnce = sync.Once{} })
+
+	c := &IPRangeChecker{}
+	inst := &InstanceMetadata{InstanceID: "i-no-cooldown", IPv4: "203.0.113.8", LastSeen: time.Now()}
+	c.recordRotation(inst.InstanceID, "203.0.113.8")
+	if ip, ok := c.resolveCheckIP(inst); !ok || ip != "203.0.113.8" {
+		t.Fatalf("不冷却时 = %q, %v, 期望立即检查", ip, ok)
+	}
+}
+
+func TestPruneRotations(t *testing.T) {
+	p := NewPool()
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-online", UserID: "prune-user"})
+	c := &IPRangeChecker{pool: p}
+	c.recordRotation("i-online", "203.0.113.9")
+	c.recordRotation("i-gone", "203.0.113.10")
+
+	c.pruneRotations()
+	if _, exists := c.rotations.Load("i-online"); !exists {
+		t.Fatal("在线实例的更换记录不应被清理")
+	}
+	if _, exists := c.rotations.Load("i-gone"); exists {
+		t.Fatal("已下线实例的更换记录应被清理")
+	}
+}
+
+func TestCheckSingleUserSkipsRotatedInstance(t *testing.T) {
+	const userID = "iprange-cooldown-user"
+	setRotationCooldown(t, time.Minute)
+	if err := globalDB.Create(&model.Monitor{UserID: userID, IsIPRangeEnabled: true, IPRange: "16.162.0.0/16"}).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+
+	p := NewPool()
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-cooldown", UserID: userID, AccountID: "9421", Region: "ap-east-1", IPv4: "198.51.100.1"})
+	// 未设置实例服务：如果冷却期内仍尝试更换IP，测试会因空指针失败
+	c := NewIPRangeChecker(globalDB, p, nil)
+	c.recordRotation("i-cooldown", "203.0.113.7")
+
+	if err := c.CheckSingleUser(context.Background(), userID); err != nil {
+		t.Fatalf("检查用户IP范围失败: %v", err)
+	}
+	value, _ := c.rotations.Load("i-cooldown")
+	if rotation := value.(ipRotation); rotation.IP != "203.0.113.7" {
+		t.Fatalf("冷却期内的更换记录被修改: %+v", rotation)
+	}
+}
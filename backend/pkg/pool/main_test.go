@@ -0,0 +1,28 @@
+package pool
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"portal/model"
+	"portal/pkg/testdb"
+	"portal/repository"
+)
+
+func TestMain(m *testing.M) {
+	// 区域配置只在首次使用时加载一次，在所有测试之前登记额外区域
+	os.Setenv("EXTRA_REGIONS", `[{"code":"us-west-2","name":"美西区","ami":"ami-usw2"}]`)
+
+	// 通知、持久化等协程可能在测试结束后仍在运行，整个测试进程使用同一个连接，避免替换全局连接时产生数据竞争
+	db, cleanup, err := testdb.New(model.Models()...)
+	if err != nil {
+		log.Fatalf("打开测试数据库失败: %v", err)
+	}
+	repository.SetDB(db)
+	globalDB = db
+
+	code := m.Run()
+	cleanup()
+	os.Exit(code)
+}
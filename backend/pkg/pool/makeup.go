@@ -2,14 +2,159 @@
 package pool
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"portal/model"
+	"portal/pkg/tg"
+)
+
+// 默认的等待任务最大存活时间，超过后任务将被放弃
+const defaultMaxTaskAge = 24 * time.Hour
+
+// getMaxTaskAge 从环境变量 MAKEUP_TASK_MAX_AGE 读取任务最大存活时间（如 12h、90m）
+// 设置为0表示永不放弃
+func getMaxTaskAge() time.Duration {
+	value := os.Getenv("MAKEUP_TASK_MAX_AGE")
+	if value == "" {
+		return defaultMaxTaskAge
+	}
+
+	maxAge, err := time.ParseDuration(value)
+	if err != nil || maxAge < 0 {
+		log.Printf("MAKEUP_TASK_MAX_AGE 配置无效[%s]，使用默认值%v", value, defaultMaxTaskAge)
+		return defaultMaxTaskAge
+	}
+	return maxAge
+}
+
+// 默认每个区域同时进行的补机开机数量
+const defaultRegionConcurrency = 3
+
+var (
+	regionSemaphores   = make(map[string]chan struct{}) // 区域代码 -> 开机信号量
+	regionSemaphoresMu sync.Mutex
 )
 
+// getRegionConcurrency 从环境变量 MAKEUP_REGION_CONCURRENCY 读取每个区域的最大并发开机数
+func getRegionConcurrency() int {
+	value := os.Getenv("MAKEUP_REGION_CONCURRENCY")
+	if value == "" {
+		return defaultRegionConcurrency
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("MAKEUP_REGION_CONCURRENCY 配置无效[%s]，使用默认值%d", value, defaultRegionConcurrency)
+		return defaultRegionConcurrency
+	}
+	return n
+}
+
+// acquireRegionSlot 获取区域的开机名额，不同区域互不影响，返回释放函数
+func acquireRegionSlot(region string) func() {
+	regionSemaphoresMu.Lock()
+	sem, exists := regionSemaphores[region]
+	if !exists {
+		sem = make(chan struct{}, getRegionConcurrency())
+		regionSemaphores[region] = sem
+	}
+	regionSemaphoresMu.Unlock()
+
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+var (
+	// launchMakeupInstance 补机开机的实现，测试中可替换
+	launchMakeupInstance func(userID, region, queueID string) (*InstanceCreationResult, error)
+	// discardMakeupInstance 终止开机后未上报的实例，测试中可替换
+	discardMakeupInstance = terminateUnreachableInstance
+	// instanceReported 判断实例是否已通过WebSocket上报，测试中可替换
+	instanceReported = func(instanceID string) bool {
+		return GlobalPool != nil && GlobalPool.HasInstance(instanceID)
+	}
+	// reportPollInterval 等待实例上报时的检查间隔
+	reportPollInterval = 5 * time.Second
+	// makeupLaunchInterval 同一任务连续开机之间的间隔，避免请求过快
+	makeupLaunchInterval = 2 * time.Second
+	// makeupRetryInterval 开机失败后重试前的等待时间
+	makeupRetryInterval = 2 * time.Second
+)
+
+func init() {
+	// 在init中赋值，避免与 CreateInstanceForUser 形成包级变量初始化循环
+	launchMakeupInstance = CreateInstanceForUser
+}
+
+// 默认每个补机任务单轮最多失败重试的次数
+const defaultRetryBudget = 10
+
+// getRetryBudget 从环境变量 MAKEUP_RETRY_BUDGET 读取每个补机任务单轮的重试预算，与账号池大小无关
+func getRetryBudget() int {
+	value := os.Getenv("MAKEUP_RETRY_BUDGET")
+	if value == "" {
+		return defaultRetryBudget
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("MAKEUP_RETRY_BUDGET 配置无效[%s]，使用默认值%d", value, defaultRetryBudget)
+		return defaultRetryBudget
+	}
+	return n
+}
+
+// 单台实例等待上报的最长时间，需远小于任务处理超时，避免等待期间任务被判定超时而重复处理
+const maxReportWaitTimeout = 10 * time.Minute
+
+// 任务处理超时时间，每开一台重新计时，超时后任务被重置为等待中
+const taskProcessingTimeout = 30 * time.Minute
+
+// getReportWaitTimeout 从环境变量 MAKEUP_WAIT_REPORT 读取开机后等待实例上报的时间（如 3m）
+// 未设置或为0时不等待，开机接口返回即视为补机成功，超过10分钟时按10分钟处理
+// 超时未上报的实例会被终止且不计入完成数量，MAKEUP_RETRY_UNREACHABLE=true 时还会跳过该账号
+func getReportWaitTimeout() time.Duration {
+	value := os.Getenv("MAKEUP_WAIT_REPORT")
+	if value == "" {
+		return 0
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		log.Printf("MAKEUP_WAIT_REPORT 配置无效[%s]，不等待实例上报", value)
+		return 0
+	}
+	if timeout > maxReportWaitTimeout {
+		log.Printf("MAKEUP_WAIT_REPORT 配置[%s]超过上限，使用%v", value, maxReportWaitTimeout)
+		return maxReportWaitTimeout
+	}
+	return timeout
+}
+
+// waitForInstanceReport 等待实例通过WebSocket上报，超时返回false
+// 在任务自己的处理协程中等待，不占用区域开机名额，也不阻塞其他任务
+func waitForInstanceReport(instanceID string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if instanceReported(instanceID) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(reportPollInterval)
+	}
+}
+
 // MakeupQueueItem 补机队列项
 type MakeupQueueItem struct {
 	UserID         string    // 用户ID
@@ -17,8 +162,11 @@ type MakeupQueueItem struct {
 	TotalCount     int       // 需要补机总数
 	CompletedCount int       // 已完成数量
 	AddTime        time.Time // 添加到队列的时间
-	Status         string    // 状态：等待中、进行中、已完成
+	Status         string    // 状态：等待中、进行中、已完成、已放弃
 	QueueID        string    // 队列项唯一ID，格式为：userID:region:timestamp
+	StartTime      time.Time // 首次开始处理的时间
+	FinishTime     time.Time // 完成或放弃的时间
+	FailedAttempts int       // 开机失败的尝试次数
 }
 
 // MakeupQueue 补机队列管理器
@@ -26,8 +174,25 @@ type MakeupQueue struct {
 	queue       map[string]*MakeupQueueItem // 任务队列键 -> 补机任务
 	mu          sync.RWMutex                // 读写锁
 	taskChannel chan string                 // 任务通知通道
-	isRunning   bool                        // 是否已启动处理循环
-	processing  bool                        // 是否正在处理任务的标志
+	isRunning   atomic.Bool                 // 是否已启动处理循环
+	active      map[string]*taskClaim       // 正在处理的任务键 -> 处理协程的认领，受mu保护
+
+	persistMu      sync.Mutex                   // 保护待写入的任务快照
+	persistPending map[string]pendingTaskRecord // 待写入数据库的任务快照，同一任务只保留最新的
+	persistSignal  chan struct{}                // 通知写入协程有新的快照
+	persistOnce    sync.Once                    // 确保只启动一个写入协程
+}
+
+// newMakeupQueue 创建补机队列，不启动处理协程
+func newMakeupQueue() *MakeupQueue {
+	return &MakeupQueue{
+		queue:       make(map[string]*MakeupQueueItem),
+		taskChannel: make(chan string, 100), // 缓冲区大小设为100，避免阻塞
+		active:      make(map[string]*taskClaim),
+
+		persistPending: make(map[string]pendingTaskRecord),
+		persistSignal:  make(chan struct{}, 1),
+	}
 }
 
 // 全局补机队列
@@ -39,12 +204,7 @@ var (
 // GetMakeupQueue 获取全局补机队列实例
 func GetMakeupQueue() *MakeupQueue {
 	makeupQueueOnce.Do(func() {
-		globalMakeupQueue = &MakeupQueue{
-			queue:       make(map[string]*MakeupQueueItem),
-			taskChannel: make(chan string, 100), // 缓冲区大小设为100，避免阻塞
-			isRunning:   false,
-			processing:  false,
-		}
+		globalMakeupQueue = newMakeupQueue()
 		// 启动补机处理协程
 		go globalMakeupQueue.StartProcessing()
 
@@ -54,6 +214,9 @@ func GetMakeupQueue() *MakeupQueue {
 		// 注册为账号池事件的监听器
 		GetEventManager().RegisterAccountListener(globalMakeupQueue)
 		log.Printf("补机队列已注册为账号池事件监听器")
+
+		// 注册为账号失败事件的监听器，账号被移除或跳过时立即重新评估该区域的任务
+		GetEventManager().RegisterAccountFailureListener(globalMakeupQueue)
 	})
 	return globalMakeupQueue
 }
@@ -64,7 +227,7 @@ func (mq *MakeupQueue) OnAccountPoolEvent(event AccountPoolEvent, accountID stri
 
 	// 根据不同事件类型处理
 	switch event {
-	case AccountAdded, AccountReset, ManualReset:
+	case AccountAdded, AccountReset, ManualReset, RegionChanged:
 		// 这些事件都应该触发重置卡住的任务
 		mq.ResetStuckTasks()
 
@@ -73,14 +236,36 @@ func (mq *MakeupQueue) OnAccountPoolEvent(event AccountPoolEvent, accountID stri
 	}
 }
 
+// OnAccountFailure 实现AccountFailureListener接口
+// 补机过程中账号被移除或跳过后，若该区域仍有可用账号，立即推送该区域等待中的任务，不必等定期检查
+func (mq *MakeupQueue) OnAccountFailure(failure AccountFailure) {
+	if failure.Region == "" {
+		return
+	}
+	if !GetAccountPool().HasActiveAccountInRegion(failure.Region) {
+		log.Printf("账号[%s]失败后区域[%s]已无可用账号，等待中的任务保持暂停", failure.AccountID, failure.Region)
+		return
+	}
+
+	tasks := mq.GetWaitingTasksForRegion(failure.Region)
+	for _, task := range tasks {
+		select {
+		case mq.taskChannel <- task.QueueID:
+			log.Printf("账号[%s]失败后重新推送区域[%s]的任务[%s]", failure.AccountID, failure.Region, task.QueueID)
+		default:
+			log.Printf("任务通知通道已满，任务[%s]将由定期检查处理", task.QueueID)
+		}
+	}
+}
+
 // ResetStuckTasks 将所有"进行中"但未完成的任务重置为"等待中"
 func (mq *MakeupQueue) ResetStuckTasks() {
 	mq.mu.Lock()
 
-	// 寻找所有状态为"进行中"但未完成的任务
+	// 寻找所有状态为"进行中"但未完成、且没有协程在处理的任务
 	stuckTasks := make([]string, 0)
 	for key, task := range mq.queue {
-		if task.Status == "进行中" && task.CompletedCount < task.TotalCount {
+		if _, active := mq.active[key]; task.Status == "进行中" && task.CompletedCount < task.TotalCount && !active {
 			// 将任务状态重置为"等待中"
 			task.Status = "等待中"
 			stuckTasks = append(stuckTasks, key)
@@ -90,9 +275,6 @@ func (mq *MakeupQueue) ResetStuckTasks() {
 		}
 	}
 
-	// 重置处理标志，确保可以处理新任务
-	mq.processing = false
-
 	mq.mu.Unlock()
 
 	// 将重置的任务重新放入处理队列
@@ -117,8 +299,9 @@ func (mq *MakeupQueue) ResetStuckTasks() {
 }
 
 // StartProcessing 启动处理循环
+// 每个任务在独立的协程中处理，不同任务可以并行开机，同一区域的并发开机数由区域信号量限制
 func (mq *MakeupQueue) StartProcessing() {
-	mq.isRunning = true
+	mq.isRunning.Store(true)
 	log.Printf("补机队列处理器启动")
 
 	// 处理所有已有任务
@@ -129,120 +312,168 @@ func (mq *MakeupQueue) StartProcessing() {
 
 	// 等待新任务通知
 	for queueKey := range mq.taskChannel {
-		// 如果已经在处理任务，记录并跳过，但不要丢弃任务
-		if mq.processing {
-			log.Printf("正在处理其他任务，任务[%s]将稍后处理", queueKey)
-			// 将任务重新加入队列而不是直接忽略
-			go func(key string) {
-				time.Sleep(10 * time.Second) // 等待一段时间后重试
-				mq.taskChannel <- key
-				log.Printf("已将延迟的任务[%s]重新加入处理队列", key)
-			}(queueKey)
-			continue
-		}
-
 		// 记录日志，方便跟踪任务处理流程
 		log.Printf("收到任务处理通知: %s", queueKey)
 
-		// 通过任务键获取任务
-		task := mq.GetQueueItemByKey(queueKey)
-		if task == nil {
-			log.Printf("任务[%s]不存在", queueKey)
+		remainingCount, claim, ok := mq.claimTask(queueKey)
+		if !ok {
 			continue
 		}
 
-		// 检查任务是否需要处理
-		if task.Status != "等待中" {
-			log.Printf("任务[%s]状态为[%s]，不需要处理", queueKey, task.Status)
-			continue
-		}
+		log.Printf("开始处理任务[%s]，需处理%d台", queueKey, remainingCount)
+		go mq.runTask(queueKey, remainingCount, claim)
+	}
+}
 
-		if task.CompletedCount >= task.TotalCount {
-			log.Printf("任务[%s]已完成（已完成=%d, 总数=%d）",
-				queueKey, task.CompletedCount, task.TotalCount)
+// taskClaim 处理协程对任务的认领，指针本身作为所有权凭证
+// 任务被重置、清空或交给新的协程后，旧协程持有的认领不再有效
+type taskClaim struct {
+	claimedAt time.Time   // 认领时间
+	timer     *time.Timer // 处理超时计时器，只在处理协程中访问
+}
 
-			// 更新状态为已完成
-			mq.updateTaskStatusByKey(queueKey, "已完成")
-			continue
-		}
+// touch 任务有进展时重新开始处理超时计时
+func (c *taskClaim) touch() {
+	if c.timer != nil {
+		c.timer.Reset(taskProcessingTimeout)
+	}
+}
 
-		// 添加安全检查：确保任务确实有需要处理的内容
-		needToProcess := task.TotalCount - task.CompletedCount
-		if needToProcess <= 0 {
-			log.Printf("任务[%s]没有需要处理的内容：总量=%d，已完成=%d",
-				queueKey, task.TotalCount, task.CompletedCount)
+// claimTask 检查任务是否需要处理，需要时标记为进行中并登记为正在处理，返回剩余数量和认领
+// 同一任务同时只会被一个协程处理，重复的通知直接忽略
+func (mq *MakeupQueue) claimTask(queueKey string) (int, *taskClaim, bool) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
 
-			// 更新状态为已完成
-			mq.updateTaskStatusByKey(queueKey, "已完成")
-			continue
+	task, exists := mq.queue[queueKey]
+	if !exists {
+		log.Printf("任务[%s]不存在", queueKey)
+		return 0, nil, false
+	}
+
+	if _, active := mq.active[queueKey]; active {
+		log.Printf("任务[%s]正在处理中，忽略重复通知", queueKey)
+		return 0, nil, false
+	}
+
+	// 检查任务是否需要处理
+	if task.Status != "等待中" {
+		log.Printf("任务[%s]状态为[%s]，不需要处理", queueKey, task.Status)
+		return 0, nil, false
+	}
+
+	remainingCount := task.TotalCount - task.CompletedCount
+	if remainingCount <= 0 {
+		log.Printf("任务[%s]已完成（已完成=%d, 总数=%d）",
+			queueKey, task.CompletedCount, task.TotalCount)
+
+		// 更新状态为已完成
+		setItemStatus(task, "已完成")
+		mq.persistTask(task)
+		return 0, nil, false
+	}
+
+	// 更新任务状态为进行中
+	setItemStatus(task, "进行中")
+	log.Printf("更新任务[%s]状态为[进行中]", queueKey)
+	mq.persistTask(task)
+	claim := &taskClaim{claimedAt: time.Now()}
+	mq.active[queueKey] = claim
+	return remainingCount, claim, true
+}
+
+// releaseTask 取消任务的正在处理登记，返回该认领此前是否仍持有任务
+// 任务已被其他协程认领时不做修改，避免旧协程取消新协程的登记
+func (mq *MakeupQueue) releaseTask(queueKey string, claim *taskClaim) bool {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if mq.active[queueKey] != claim {
+		return false
+	}
+	delete(mq.active, queueKey)
+	return true
+}
+
+// ownsTask 认领是否仍持有任务：任务存在、仍为进行中且没有被重置或交给其他协程
+func (mq *MakeupQueue) ownsTask(queueKey string, claim *taskClaim) bool {
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+
+	task, exists := mq.queue[queueKey]
+	return exists && task.Status == "进行中" && mq.active[queueKey] == claim
+}
+
+// isTaskActive 任务是否正在被某个协程处理
+func (mq *MakeupQueue) isTaskActive(queueKey string) bool {
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+
+	_, active := mq.active[queueKey]
+	return active
+}
+
+// runTask 处理单个任务，结束后根据错误类型决定重试时机
+func (mq *MakeupQueue) runTask(queueKey string, remainingCount int, claim *taskClaim) {
+	// 添加处理超时保护，每开一台重新计时
+	processingTimer := time.AfterFunc(taskProcessingTimeout, func() {
+		if mq.releaseTask(queueKey, claim) {
+			log.Printf("警告：任务[%s]处理超时(%v)，强制重置处理状态", queueKey, taskProcessingTimeout)
+			// 将任务状态重置为等待中
+			mq.updateTaskStatusByKey(queueKey, "等待中")
+			// 重新加入队列
+			mq.taskChannel <- queueKey
 		}
+	})
+	claim.timer = processingTimer
 
-		// 更新任务状态为进行中
-		mq.updateTaskStatusByKey(queueKey, "进行中")
-		log.Printf("开始处理任务[%s]，需处理%d台", queueKey, needToProcess)
+	// 使用defer确保无论如何处理登记都会被取消
+	defer func() {
+		// 取消处理超时计时器
+		processingTimer.Stop()
 
-		// 设置处理标志
-		mq.processing = true
+		// 无论任务处理是否成功，都取消处理登记
+		mq.releaseTask(queueKey, claim)
 
-		// 添加处理超时保护
-		processingTimer := time.AfterFunc(30*time.Minute, func() {
-			if mq.processing {
-				log.Printf("警告：任务[%s]处理超时(30分钟)，强制重置处理状态", queueKey)
-				mq.processing = false
-				// 将任务状态重置为等待中
-				mq.updateTaskStatusByKey(queueKey, "等待中")
-				// 重新加入队列
-				mq.taskChannel <- queueKey
-			}
-		})
-
-		// 使用匿名函数和defer确保无论如何processing标志都会被重置
-		func() {
-			defer func() {
-				// 取消处理超时计时器
-				processingTimer.Stop()
-
-				// 无论任务处理是否成功，都重置处理标志
-				mq.processing = false
-
-				// 恢复可能的panic
-				if r := recover(); r != nil {
-					log.Printf("补机任务处理过程中发生panic: %v", r)
-					// 将任务状态重置为等待中
-					mq.updateTaskStatusByKey(queueKey, "等待中")
-				}
+		// 恢复可能的panic
+		if r := recover(); r != nil {
+			log.Printf("补机任务处理过程中发生panic: %v", r)
+			// 将任务状态重置为等待中
+			mq.updateTaskStatusByKey(queueKey, "等待中")
+		}
 
-				log.Printf("任务[%s]处理完成或中断", queueKey)
-			}()
+		log.Printf("任务[%s]处理完成或中断", queueKey)
+	}()
 
-			// 处理任务
-			remainingCount := task.TotalCount - task.CompletedCount
-			err := mq.processMakeup(queueKey, remainingCount)
-			if err != nil {
-				log.Printf("任务[%s]处理出错: %v", queueKey, err)
-
-				// 如果不是因为账号不足，则将状态设回等待中，以便下次处理
-				if err.Error() != "没有可用的账号" {
-					mq.updateTaskStatusByKey(queueKey, "等待中")
-					// 将任务重新加入队列，延迟5秒再处理
-					go func(key string) {
-						time.Sleep(5 * time.Second)
-						mq.taskChannel <- key
-					}(queueKey)
-				} else {
-					log.Printf("由于没有可用账号，任务[%s]将保持等待状态，15分钟后重试", queueKey)
-					// 即使没有可用账号，也设置一个较长的延迟后重试，避免任务永久卡住
-					go func(key string) {
-						time.Sleep(15 * time.Minute)
-						task := mq.GetQueueItemByKey(key)
-						if task != nil && task.Status == "等待中" && task.CompletedCount < task.TotalCount {
-							log.Printf("定时重试缺少账号的任务[%s]", key)
-							mq.taskChannel <- key
-						}
-					}(queueKey)
-				}
+	// 处理任务
+	err := mq.processMakeup(queueKey, remainingCount, claim)
+	if err == nil {
+		return
+	}
+	log.Printf("任务[%s]处理出错: %v", queueKey, err)
+
+	// 区域不允许的任务已放弃，不再重试
+	if errors.Is(err, model.ErrRegionNotAllowed) {
+		log.Printf("任务[%s]的区域不在用户允许范围内，已放弃", queueKey)
+	} else if !shouldPauseMakeup(err) {
+		// 如果不是因为账号不足或达到上限，则将状态设回等待中，以便下次处理
+		mq.updateTaskStatusByKey(queueKey, "等待中")
+		// 将任务重新加入队列，延迟5秒再处理
+		go func(key string) {
+			time.Sleep(5 * time.Second)
+			mq.taskChannel <- key
+		}(queueKey)
+	} else {
+		log.Printf("由于没有可用账号或达到上限，任务[%s]将保持等待状态，15分钟后重试", queueKey)
+		// 即使没有可用账号，也设置一个较长的延迟后重试，避免任务永久卡住
+		go func(key string) {
+			time.Sleep(15 * time.Minute)
+			completed, total, status, ok := mq.GetTaskProgress(key)
+			if ok && status == "等待中" && completed < total {
+				log.Printf("定时重试缺少账号的任务[%s]", key)
+				mq.taskChannel <- key
 			}
-		}()
+		}(queueKey)
 	}
 }
 
@@ -264,9 +495,9 @@ func generateQueueID(userID string, region string) string {
 	return fmt.Sprintf("%s:%s:%d", userID, region, timestamp)
 }
 
-// AddToQueueWithRegion 添加带区域的补机任务到队列
+// AddToQueueWithRegion 添加带区域的补机任务到队列，返回新任务的队列ID
 // 强制新建任务而不是更新现有任务
-func (mq *MakeupQueue) AddToQueueWithRegion(userID string, count int, region string) {
+func (mq *MakeupQueue) AddToQueueWithRegion(userID string, count int, region string) string {
 	mq.mu.Lock()
 	defer mq.mu.Unlock()
 
@@ -276,7 +507,7 @@ func (mq *MakeupQueue) AddToQueueWithRegion(userID string, count int, region str
 	queueID := generateQueueID(userID, region)
 
 	// 创建新任务
-	mq.queue[queueID] = &MakeupQueueItem{
+	item := &MakeupQueueItem{
 		UserID:         userID,
 		Region:         region,
 		TotalCount:     count,
@@ -285,9 +516,11 @@ func (mq *MakeupQueue) AddToQueueWithRegion(userID string, count int, region str
 		Status:         "等待中",
 		QueueID:        queueID,
 	}
+	mq.queue[queueID] = item
 
 	log.Printf("为用户[%s]在区域[%s]创建新补机任务：数量[%d]，队列ID[%s]",
 		userID, region, count, queueID)
+	mq.persistTask(item)
 
 	// 发送通知到处理通道
 	go func() {
@@ -309,6 +542,8 @@ func (mq *MakeupQueue) AddToQueueWithRegion(userID string, count int, region str
 			}()
 		}
 	}()
+
+	return queueID
 }
 
 // 移除不再使用的原有方法
@@ -318,12 +553,73 @@ func (mq *MakeupQueue) AddToQueue(userID string, count int) {
 	mq.AddToQueueWithRegion(userID, count, "ap-east-1")
 }
 
-// GetQueueItemByKey 通过队列键获取任务
+// abandonTask 将长时间等待的任务标记为已放弃，不再参与处理
+func (mq *MakeupQueue) abandonTask(queueKey string, waitTime time.Duration) {
+	mq.mu.Lock()
+	item, exists := mq.queue[queueKey]
+	if !exists || item.Status != "等待中" {
+		mq.mu.Unlock()
+		return
+	}
+	setItemStatus(item, "已放弃")
+	mq.persistTask(item)
+	userID, region := item.UserID, item.Region
+	totalCount, completedCount := item.TotalCount, item.CompletedCount
+	mq.mu.Unlock()
+
+	log.Printf("任务[%s]已等待%.1f小时，超过最大存活时间，标记为已放弃（已完成=%d, 总数=%d）",
+		queueKey, waitTime.Hours(), completedCount, totalCount)
+
+	message := fmt.Sprintf("补机任务已放弃\n用户ID: %s\n区域: %s\n进度: %d/%d\n等待时间: %.1f小时",
+		userID, region, completedCount, totalCount, waitTime.Hours())
+
+	go func() {
+		if err := tg.NotifyUserMessage(globalDB, userID, message); err != nil {
+			log.Printf("发送任务放弃通知失败: %v", err)
+		}
+		if err := tg.NotifyAdminMessage(globalDB, message); err != nil {
+			log.Printf("发送任务放弃管理员通知失败: %v", err)
+		}
+	}()
+}
+
+// HasActiveTasks 是否有正在处理的补机任务
+func (mq *MakeupQueue) HasActiveTasks() bool {
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+
+	for _, task := range mq.queue {
+		if task.Status == "进行中" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetQueueItemByKey 通过队列键获取任务的副本，不存在时返回nil
+// 任务字段由处理协程在锁内修改，返回副本避免调用方无锁读取
 func (mq *MakeupQueue) GetQueueItemByKey(queueKey string) *MakeupQueueItem {
 	mq.mu.RLock()
 	defer mq.mu.RUnlock()
 
-	return mq.queue[queueKey]
+	item, exists := mq.queue[queueKey]
+	if !exists {
+		return nil
+	}
+	snapshot := *item
+	return &snapshot
+}
+
+// GetTaskProgress 获取任务进度快照，避免调用方在无锁状态下读取任务字段
+func (mq *MakeupQueue) GetTaskProgress(queueKey string) (completed, total int, status string, ok bool) {
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+
+	item, exists := mq.queue[queueKey]
+	if !exists {
+		return 0, 0, "", false
+	}
+	return item.CompletedCount, item.TotalCount, item.Status, true
 }
 
 // updateTaskStatusByKey 通过队列键更新任务状态
@@ -332,8 +628,9 @@ func (mq *MakeupQueue) updateTaskStatusByKey(queueKey string, status string) {
 	defer mq.mu.Unlock()
 
 	if item, exists := mq.queue[queueKey]; exists {
-		item.Status = status
+		setItemStatus(item, status)
 		log.Printf("更新任务[%s]状态为[%s]", queueKey, status)
+		mq.persistTask(item)
 	}
 }
 
@@ -348,13 +645,14 @@ func (mq *MakeupQueue) IncrementCompletedCount(queueKey string) {
 
 		// 检查是否已完成所有补机
 		if item.CompletedCount >= item.TotalCount {
-			item.Status = "已完成"
+			setItemStatus(item, "已完成")
 			log.Printf("任务[%s]已全部完成，共完成[%d]台", queueKey, item.CompletedCount)
 		}
+		mq.persistTask(item)
 	}
 }
 
-// GetWaitingTasks 获取所有等待中的任务（按添加时间排序）
+// GetWaitingTasks 获取所有等待中任务的副本（按添加时间排序）
 func (mq *MakeupQueue) GetWaitingTasks() []*MakeupQueueItem {
 	mq.mu.RLock()
 	defer mq.mu.RUnlock()
@@ -362,7 +660,8 @@ func (mq *MakeupQueue) GetWaitingTasks() []*MakeupQueueItem {
 	var waitingTasks []*MakeupQueueItem
 	for _, task := range mq.queue {
 		if task.Status == "等待中" && task.CompletedCount < task.TotalCount {
-			waitingTasks = append(waitingTasks, task)
+			snapshot := *task
+			waitingTasks = append(waitingTasks, &snapshot)
 		}
 	}
 
@@ -374,7 +673,7 @@ func (mq *MakeupQueue) GetWaitingTasks() []*MakeupQueueItem {
 	return waitingTasks
 }
 
-// GetQueue 获取整个补机队列（按添加时间排序）
+// GetQueue 获取整个补机队列的副本（按添加时间排序）
 func (mq *MakeupQueue) GetQueue() []*MakeupQueueItem {
 	mq.mu.RLock()
 	defer mq.mu.RUnlock()
@@ -382,7 +681,8 @@ func (mq *MakeupQueue) GetQueue() []*MakeupQueueItem {
 	// 将map转为切片
 	items := make([]*MakeupQueueItem, 0, len(mq.queue))
 	for _, item := range mq.queue {
-		items = append(items, item)
+		snapshot := *item
+		items = append(items, &snapshot)
 	}
 
 	// 按添加时间正序排序
@@ -393,8 +693,8 @@ func (mq *MakeupQueue) GetQueue() []*MakeupQueueItem {
 	return items
 }
 
-// processMakeup 处理用户的补机任务
-func (mq *MakeupQueue) processMakeup(queueKey string, count int) error {
+// processMakeup 处理用户的补机任务，claim为处理协程对任务的认领
+func (mq *MakeupQueue) processMakeup(queueKey string, count int, claim *taskClaim) error {
 	task := mq.GetQueueItemByKey(queueKey)
 	if task == nil {
 		return fmt.Errorf("任务不存在")
@@ -419,22 +719,47 @@ func (mq *MakeupQueue) processMakeup(queueKey string, count int) error {
 	// 处理计数
 	processedCount := 0
 
-	// 最大重试次数（等于账号池中账号数量或设定上限）
-	maxRetries := accountPool.Size()
-	if maxRetries > 10 {
-		maxRetries = 10 // 限制最大重试次数为10
-	}
+	// 最大重试次数，使用配置的重试预算
+	maxRetries := getRetryBudget()
 	log.Printf("调试: 设置最大重试次数=%d", maxRetries)
 
 	// 重试计数
 	retryCount := 0
 
+	// 记录本轮尝试过的账号（按首次尝试顺序）
+	var triedAccounts []string
+	triedSet := make(map[string]bool)
+	recordTried := func(accountID string) {
+		if accountID != "" && !triedSet[accountID] {
+			triedSet[accountID] = true
+			triedAccounts = append(triedAccounts, accountID)
+		}
+	}
+
+	// 跳过单独上线通知时，本轮结束后汇总通知开机成功的实例
+	var launched []string
+	if shouldSuppressMakeupOnline() {
+		defer func() {
+			if len(launched) > 0 {
+				go notifyMakeupLaunched(userID, region, launched)
+			}
+		}()
+	}
+
 	// 循环处理每台需要补的机器
 	for processedCount < count {
+		// 任务已被清空、放弃或重置后交给其他协程时停止开机
+		if !mq.ownsTask(queueKey, claim) {
+			log.Printf("任务[%s]已不再由当前协程处理（认领于%v前），停止补机，已处理[%d/%d]台",
+				queueKey, time.Since(claim.claimedAt).Round(time.Second), processedCount, count)
+			return nil
+		}
+		claim.touch()
+
 		// 检查是否达到最大重试次数
 		if retryCount >= maxRetries {
-			log.Printf("用户[%s]在区域[%s]补机失败，已达到最大重试次数(%d)，暂停任务",
-				userID, region, maxRetries)
+			log.Printf("用户[%s]在区域[%s]补机失败，已达到最大重试次数(%d)，暂停任务，尝试过的账号: %v",
+				userID, region, maxRetries, triedAccounts)
 			// 将任务重置为等待状态，以便稍后重试
 			mq.updateTaskStatusByKey(queueKey, "等待中")
 			log.Printf("调试: 达到最大重试次数，任务状态已重置为等待中")
@@ -446,20 +771,40 @@ func (mq *MakeupQueue) processMakeup(queueKey string, count int) error {
 			userID, region, processedCount, count, retryCount)
 
 		// 使用 makeupvm.go 中的函数创建实例，传递区域参数
-		result, err := CreateInstanceForUser(userID, region)
+		// 同一区域的并发开机数受限，避免触发AWS接口和容量限制
+		release := acquireRegionSlot(region)
+		result, err := launchMakeupInstance(userID, region, queueKey)
+		release()
+		if result != nil {
+			recordTried(result.AccountID)
+		}
+
+		// 阈值已被手动开机或其他任务补足，剩余数量无需再补
+		if errors.Is(err, ErrThresholdReached) {
+			log.Printf("用户[%s]在区域[%s]的实例数已达到阈值，补机任务提前完成，已处理[%d/%d]台", userID, region, processedCount, count)
+			mq.updateTaskStatusByKey(queueKey, "已完成")
+			return nil
+		}
 
 		if err != nil {
 			log.Printf("用户[%s]在区域[%s]补机尝试失败：%v", userID, region, err)
 			retryCount++
+			mq.recordFailedAttempt(queueKey)
 
 			log.Printf("调试: 创建实例失败，当前重试次数=%d/%d, 错误=%v",
 				retryCount, maxRetries, err)
 
-			// 如果是因为没有可用账号，中断处理
-			if err.Error() == "没有可用的账号" {
+			// 用户不允许在该区域开机，重试也不会成功，直接放弃任务
+			if errors.Is(err, model.ErrRegionNotAllowed) {
+				mq.updateTaskStatusByKey(queueKey, "已放弃")
+				return err
+			}
+
+			// 没有可用账号、配额用尽或达到全局上限时，中断处理
+			if shouldPauseMakeup(err) {
 				// 将任务重置为等待状态，以便稍后重试
 				mq.updateTaskStatusByKey(queueKey, "等待中")
-				log.Printf("调试: 由于没有可用账号，任务已重置为等待中")
+				log.Printf("调试: 由于%v，任务已重置为等待中", err)
 
 				// 检查账号池状态
 				log.Printf("调试: 当前账号池状态: 总数=%d, 可用=%d",
@@ -470,17 +815,56 @@ func (mq *MakeupQueue) processMakeup(queueKey string, count int) error {
 					accountPool.LoadAccountsFromDB()
 				}
 
-				return fmt.Errorf("没有可用的账号")
+				return err
+			}
+
+			// 临时错误等待更长时间再重试，其他错误（如账号未开通区域）直接换账号重试
+			if errors.Is(err, ErrTransient) {
+				log.Printf("调试: 临时错误，等待10秒后重试")
+				time.Sleep(10 * time.Second)
+				continue
 			}
 
 			// 小延迟，避免快速重试
-			log.Printf("调试: 等待2秒后重试")
-			time.Sleep(2 * time.Second)
+			log.Printf("调试: 等待%v后重试", makeupRetryInterval)
+			time.Sleep(makeupRetryInterval)
 			continue
 		}
 
+		// 可选：等待实例通过WebSocket上报，上报后才算真正补机成功
+		if timeout := getReportWaitTimeout(); timeout > 0 {
+			if !waitForInstanceReport(result.InstanceID, timeout) {
+				log.Printf("用户[%s]在区域[%s]的实例[%s]已开机但%v内未上报，状态为开机但不可达",
+					userID, region, result.InstanceID, timeout)
+
+				// 不可达的实例不计入完成数量，先终止再重新开机，避免遗留实例导致超出阈值
+				if err := discardMakeupInstance(result, region); err != nil {
+					log.Printf("终止不可达实例[%s]失败，需人工处理: %v", result.InstanceID, err)
+					accountPool.RecordAccountFailure(result.AccountID, result.InstanceType, region,
+						fmt.Sprintf("实例[%s]开机后未上报且终止失败: %v", result.InstanceID, err))
+				} else {
+					log.Printf("已终止不可达实例[%s]", result.InstanceID)
+				}
+				retryCount++
+				mq.recordFailedAttempt(queueKey)
+
+				// 配置了重试时跳过该账号，下次使用其他账号开机
+				if os.Getenv("MAKEUP_RETRY_UNREACHABLE") == "true" {
+					accountPool.MarkAccountFailed(result.AccountID, fmt.Sprintf("实例[%s]开机后未上报", result.InstanceID))
+				}
+				continue
+			} else {
+				log.Printf("用户[%s]在区域[%s]的实例[%s]已上报", userID, region, result.InstanceID)
+			}
+		}
+
 		// 创建成功，增加已完成计数
 		processedCount++
+		if result.PublicIP != "" {
+			launched = append(launched, fmt.Sprintf("%s (%s)", result.InstanceID, result.PublicIP))
+		} else {
+			launched = append(launched, result.InstanceID)
+		}
 		mq.IncrementCompletedCount(queueKey)
 
 		// 记录开机成功信息
@@ -491,11 +875,11 @@ func (mq *MakeupQueue) processMakeup(queueKey string, count int) error {
 		retryCount = 0
 
 		// 小延迟，避免请求过快
-		log.Printf("调试: 等待2秒后处理下一台")
-		time.Sleep(2 * time.Second)
+		log.Printf("调试: 等待%v后处理下一台", makeupLaunchInterval)
+		time.Sleep(makeupLaunchInterval)
 	}
 
-	log.Printf("用户[%s]在区域[%s]本轮补机完成，共处理[%d]台", userID, region, processedCount)
+	log.Printf("用户[%s]在区域[%s]本轮补机完成，共处理[%d]台，使用过的账号: %v", userID, region, processedCount, triedAccounts)
 	log.Printf("调试: 补机任务[%s]已全部完成，处理=%d/%d", queueKey, processedCount, count)
 
 	// 更新任务状态为已完成
@@ -504,6 +888,43 @@ func (mq *MakeupQueue) processMakeup(queueKey string, count int) error {
 	return nil
 }
 
+// GetPendingCountByUser 获取指定用户各区域尚未完成的补机数量（等待中和进行中的任务）
+func (mq *MakeupQueue) GetPendingCountByUser(userID string) map[string]int {
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+
+	pending := make(map[string]int)
+	for _, item := range mq.queue {
+		if item.UserID != userID {
+			continue
+		}
+		if item.Status != "等待中" && item.Status != "进行中" {
+			continue
+		}
+		if remaining := item.TotalCount - item.CompletedCount; remaining > 0 {
+			pending[item.Region] += remaining
+		}
+	}
+	return pending
+}
+
+// GetTotalPendingCount 获取所有用户未完成补机任务的剩余数量之和
+func (mq *MakeupQueue) GetTotalPendingCount() int {
+	mq.mu.RLock()
+	defer mq.mu.RUnlock()
+
+	total := 0
+	for _, item := range mq.queue {
+		if item.Status != "等待中" && item.Status != "进行中" {
+			continue
+		}
+		if remaining := item.TotalCount - item.CompletedCount; remaining > 0 {
+			total += remaining
+		}
+	}
+	return total
+}
+
 // GetQueueStatus 获取队列状态摘要
 func (mq *MakeupQueue) GetQueueStatus() map[string]interface{} {
 	mq.mu.RLock()
@@ -512,6 +933,7 @@ func (mq *MakeupQueue) GetQueueStatus() map[string]interface{} {
 	activeCount := 0
 	waitingCount := 0
 	completedCount := 0
+	abandonedCount := 0
 	totalMachines := 0
 	completedMachines := 0
 
@@ -525,6 +947,8 @@ func (mq *MakeupQueue) GetQueueStatus() map[string]interface{} {
 			waitingCount++
 		} else if item.Status == "已完成" {
 			completedCount++
+		} else if item.Status == "已放弃" {
+			abandonedCount++
 		}
 	}
 
@@ -533,6 +957,7 @@ func (mq *MakeupQueue) GetQueueStatus() map[string]interface{} {
 		"active_tasks":       activeCount,
 		"waiting_tasks":      waitingCount,
 		"completed_tasks":    completedCount,
+		"abandoned_tasks":    abandonedCount,
 		"total_machines":     totalMachines,
 		"completed_machines": completedMachines,
 	}
@@ -551,6 +976,7 @@ func (mq *MakeupQueue) ResetHKRequestingAccounts() {
 		for _, account := range accounts {
 			// 只重置被标记为跳过且错误原因是申请HK区相关的账号
 			if account.IsSkipped && (strings.Contains(account.ErrorNote, "HK区域未开通") ||
+				strings.Contains(account.ErrorNote, "香港区域未开通") ||
 				strings.Contains(account.ErrorNote, "HK区资源验证中")) {
 				accountPool.ResetAccountStatus(account.ID)
 				resetCount++
@@ -581,21 +1007,19 @@ func (mq *MakeupQueue) ClearAllQueue() {
 	mq.mu.Lock()
 	defer mq.mu.Unlock()
 
-	// 清空队列
+	// 清空队列，正在处理的协程会在找不到任务后自行结束
 	mq.queue = make(map[string]*MakeupQueueItem)
+	mq.active = make(map[string]*taskClaim)
 
 	// 清空任务通道
 	for len(mq.taskChannel) > 0 {
 		<-mq.taskChannel
 	}
 
-	// 重置处理标志
-	mq.processing = false
-
 	log.Printf("已清空所有补机队列")
 }
 
-// GetWaitingTasksForRegion 获取指定区域所有等待中的任务（按添加时间排序）
+// GetWaitingTasksForRegion 获取指定区域所有等待中任务的副本（按添加时间排序）
 func (mq *MakeupQueue) GetWaitingTasksForRegion(region string) []*MakeupQueueItem {
 	mq.mu.RLock()
 	defer mq.mu.RUnlock()
@@ -604,7 +1028,8 @@ func (mq *MakeupQueue) GetWaitingTasksForRegion(region string) []*MakeupQueueIte
 	for _, task := range mq.queue {
 		// 检查任务是否属于指定区域
 		if task.Region == region && task.Status == "等待中" && task.CompletedCount < task.TotalCount {
-			waitingTasks = append(waitingTasks, task)
+			snapshot := *task
+			waitingTasks = append(waitingTasks, &snapshot)
 		}
 	}
 
@@ -623,41 +1048,45 @@ func (mq *MakeupQueue) periodicTaskCheck() {
 
 	for range ticker.C {
 		// log.Printf("开始执行定期任务检查...")
+		mq.checkWaitingTasks(time.Now())
+	}
+}
 
-		waitingTasks := mq.GetWaitingTasks()
-		if len(waitingTasks) == 0 {
-			// log.Printf("当前没有等待中的任务")
-			continue
-		}
-
-		log.Printf("发现%d个等待中的任务", len(waitingTasks))
+// checkWaitingTasks 放弃超过最大存活时间的等待任务，并推动长时间等待的任务
+func (mq *MakeupQueue) checkWaitingTasks(now time.Time) {
+	waitingTasks := mq.GetWaitingTasks()
+	if len(waitingTasks) == 0 {
+		// log.Printf("当前没有等待中的任务")
+		return
+	}
 
-		// 检查是否有长时间等待的任务
-		now := time.Now()
-		for _, task := range waitingTasks {
-			waitTime := now.Sub(task.AddTime)
+	log.Printf("发现%d个等待中的任务", len(waitingTasks))
 
-			// 如果任务等待超过15分钟，主动推送到处理通道
-			if waitTime > 15*time.Minute {
-				log.Printf("任务[%s:%s]已等待%.1f分钟，主动推送处理",
-					task.UserID, task.Region, waitTime.Minutes())
+	// 检查是否有长时间等待的任务
+	maxAge := getMaxTaskAge()
+	for _, task := range waitingTasks {
+		waitTime := now.Sub(task.AddTime)
 
-				// 使用非阻塞方式尝试推送
-				select {
-				case mq.taskChannel <- task.QueueID:
-					log.Printf("已将长时间等待的任务[%s]推送至处理通道", task.QueueID)
-				default:
-					log.Printf("处理通道已满，无法推送任务[%s]", task.QueueID)
-				}
-			}
+		// 超过最大存活时间的任务直接放弃，避免无限重试
+		if maxAge > 0 && waitTime > maxAge {
+			mq.abandonTask(task.QueueID, waitTime)
+			continue
 		}
 
-		// 检查处理标志是否异常地长时间为true
-		if mq.processing {
-			log.Printf("发现处理标志仍为true，这可能阻止其他任务处理，强制重置处理标志")
-			mq.processing = false
-		}
+		// 如果任务等待超过15分钟，主动推送到处理通道
+		if waitTime > 15*time.Minute {
+			log.Printf("任务[%s:%s]已等待%.1f分钟，主动推送处理",
+				task.UserID, task.Region, waitTime.Minutes())
 
-		log.Printf("定期任务检查完成")
+			// 使用非阻塞方式尝试推送
+			select {
+			case mq.taskChannel <- task.QueueID:
+				log.Printf("已将长时间等待的任务[%s]推送至处理通道", task.QueueID)
+			default:
+				log.Printf("处理通道已满，无法推送任务[%s]", task.QueueID)
+			}
+		}
 	}
+
+	log.Printf("定期任务检查完成")
 }
@@ -0,0 +1,457 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"portal/model"
+	"portal/pkg/aws"
+	"portal/pkg/region"
+)
+
+// stubMakeupLaunch 替换补机开机实现，测试结束后恢复
+func stubMakeupLaunch(t *testing.T, launch func(userID, region, queueID string) (*InstanceCreationResult, error)) {
+	t.Helper()
+	oldLaunch, oldInterval, oldRetry := launchMakeupInstance, makeupLaunchInterval, makeupRetryInterval
+	launchMakeupInstance = launch
+	makeupLaunchInterval, makeupRetryInterval = 0, 0
+	t.Cleanup(func() {
+		launchMakeupInstance, makeupLaunchInterval, makeupRetryInterval = oldLaunch, oldInterval, oldRetry
+	})
+}
+
+// claimQueuedTask 以处理协程的身份认领任务，供直接调用processMakeup的测试使用
+func claimQueuedTask(t *testing.T, mq *MakeupQueue, queueID string) *taskClaim {
+	t.Helper()
+	_, claim, ok := mq.claimTask(queueID)
+	if !ok {
+		t.Fatalf("等待中的任务[%s]应能被认领", queueID)
+	}
+	return claim
+}
+
+// resetRegionSemaphores 清空区域信号量，使新的并发配置生效
+func resetRegionSemaphores(t *testing.T) {
+	t.Helper()
+	regionSemaphoresMu.Lock()
+	regionSemaphores = make(map[string]chan struct{})
+	regionSemaphoresMu.Unlock()
+	t.Cleanup(func() {
+		regionSemaphoresMu.Lock()
+		regionSemaphores = make(map[string]chan struct{})
+		regionSemaphoresMu.Unlock()
+	})
+}
+
+// waitQueueSettled 等待队列中所有任务都结束（已完成或已放弃）
+func waitQueueSettled(t *testing.T, mq *MakeupQueue, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		status := mq.GetQueueStatus()
+		if status["active_tasks"] == 0 && status["waiting_tasks"] == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待任务结束超时: %v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMakeupRegionConcurrencyCap(t *testing.T) {
+	t.Setenv("MAKEUP_REGION_CONCURRENCY", "2")
+	resetRegionSemaphores(t)
+
+	var (
+		mu      sync.Mutex
+		current = make(map[string]int)
+		peak    = make(map[string]int)
+		seq     int
+	)
+	stubMakeupLaunch(t, func(userID, region, queueID string) (*InstanceCreationResult, error) {
+		mu.Lock()
+		current[region]++
+		if current[region] > peak[region] {
+			peak[region] = current[region]
+		}
+		seq++
+		instanceID := fmt.Sprintf("i-%d", seq)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		current[region]--
+		mu.Unlock()
+		return &InstanceCreationResult{Success: true, InstanceID: instanceID, AccountID: "acc-1"}, nil
+	})
+
+	mq := newMakeupQueue()
+	go mq.StartProcessing()
+
+	for i := 0; i < 6; i++ {
+		mq.AddToQueueWithRegion(fmt.Sprintf("hk-user-%d", i), 2, "ap-east-1")
+	}
+	for i := 0; i < 3; i++ {
+		mq.AddToQueueWithRegion(fmt.Sprintf("jp-user-%d", i), 2, "ap-northeast-3")
+	}
+	waitQueueSettled(t, mq, 5*time.Second)
+
+	if status := mq.GetQueueStatus(); status["completed_machines"] != 18 {
+		t.Fatalf("完成的开机数量 = %v, 期望18", status["completed_machines"])
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for region, n := range peak {
+		if n > 2 {
+			t.Errorf("区域[%s]并发开机峰值 = %d, 超过上限2", region, n)
+		}
+	}
+	if peak["ap-east-1"] < 2 {
+		t.Errorf("区域[ap-east-1]并发开机峰值 = %d, 期望多个任务并行开机", peak["ap-east-1"])
+	}
+}
+
+func TestMakeupUnreachableInstanceNotCounted(t *testing.T) {
+	t.Setenv("MAKEUP_WAIT_REPORT", "50ms")
+	resetRegionSemaphores(t)
+
+	oldReported, oldDiscard, oldPoll := instanceReported, discardMakeupInstance, reportPollInterval
+	t.Cleanup(func() {
+		instanceReported, discardMakeupInstance, reportPollInterval = oldReported, oldDiscard, oldPoll
+	})
+	reportPollInterval = 5 * time.Millisecond
+
+	var (
+		mu        sync.Mutex
+		seq       int
+		launched  []string
+		discarded []string
+	)
+	stubMakeupLaunch(t, func(userID, region, queueID string) (*InstanceCreationResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		seq++
+		instanceID := fmt.Sprintf("i-%d", seq)
+		launched = append(launched, instanceID)
+		return &InstanceCreationResult{Success: true, InstanceID: instanceID, AccountID: "acc-1"}, nil
+	})
+	// 第一台实例始终不上报，之后的实例正常上报
+	instanceReported = func(instanceID string) bool {
+		return instanceID != "i-1"
+	}
+	discardMakeupInstance = func(result *InstanceCreationResult, regionCode string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		discarded = append(discarded, result.InstanceID)
+		return nil
+	}
+
+	mq := newMakeupQueue()
+	queueID := mq.AddToQueueWithRegion("user-1", 2, "ap-east-1")
+	if err := mq.processMakeup(queueID, 2, claimQueuedTask(t, mq, queueID)); err != nil {
+		t.Fatalf("processMakeup 返回错误: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(discarded) != 1 || discarded[0] != "i-1" {
+		t.Fatalf("终止的实例 = %v, 期望只终止未上报的 i-1", discarded)
+	}
+	if len(launched) != 3 {
+		t.Fatalf("开机次数 = %d, 期望3（不可达的一台需重新开机）", len(launched))
+	}
+	completed, total, status, _ := mq.GetTaskProgress(queueID)
+	if completed != 2 || total != 2 || status != "已完成" {
+		t.Fatalf("任务进度 = %d/%d %s, 期望 2/2 已完成", completed, total, status)
+	}
+	if item := mq.GetQueueItemByKey(queueID); item.FailedAttempts != 1 {
+		t.Fatalf("失败次数 = %d, 期望1", item.FailedAttempts)
+	}
+}
+
+func TestGetReportWaitTimeout(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    0,
+		"3m":  3 * time.Minute,
+		"2h":  maxReportWaitTimeout,
+		"-1m": 0,
+		"abc": 0,
+	}
+	for value, want := range cases {
+		t.Setenv("MAKEUP_WAIT_REPORT", value)
+		if got := getReportWaitTimeout(); got != want {
+			t.Errorf("MAKEUP_WAIT_REPORT=%q: 等待时间 = %v, want %v", value, got, want)
+		}
+	}
+	if maxReportWaitTimeout >= taskProcessingTimeout {
+		t.Fatalf("等待上报上限%v应小于任务处理超时%v", maxReportWaitTimeout, taskProcessingTimeout)
+	}
+}
+
+// 在 -race 下运行：处理协程修改任务的同时，事件回调、检测器和接口读取任务状态
+func TestMakeupQueueConcurrentAccess(t *testing.T) {
+	resetRegionSemaphores(t)
+	stubMakeupLaunch(t, func(userID, region, queueID string) (*InstanceCreationResult, error) {
+		time.Sleep(time.Millisecond)
+		return &InstanceCreationResult{Success: true, InstanceID: "i-" + userID, AccountID: "acc-1"}, nil
+	})
+
+	mq := newMakeupQueue()
+	go mq.StartProcessing()
+
+	const tasks = 20
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < tasks; i++ {
+			mq.AddToQueueWithRegion(fmt.Sprintf("user-%d", i), 2, "ap-east-1")
+		}
+	}()
+
+	readers := []func(){
+		func() {
+			for _, task := range mq.GetWaitingTasksForRegion("ap-east-1") {
+				_ = task.TotalCount - task.CompletedCount
+			}
+		},
+		func() {
+			for _, item := range mq.GetQueue() {
+				_ = item.Status
+				_ = item.CompletedCount
+			}
+		},
+		func() { mq.OnAccountPoolEvent(AccountAdded, "acc-1") },
+		func() { mq.HasActiveTasks() },
+		func() { mq.GetPendingCountByUser("user-1") },
+		func() { mq.GetQueueStatus() },
+	}
+	for _, read := range readers {
+		wg.Add(1)
+		go func(read func()) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					read()
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}(read)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	// 并发阶段被事件回调清空通道的任务重新推送一次
+	mq.processExistingTasks()
+	waitQueueSettled(t, mq, 5*time.Second)
+
+	if status := mq.GetQueueStatus(); status["completed_machines"] != 2*tasks {
+		t.Fatalf("完成的开机数量 = %v, 期望%d", status["completed_machines"], 2*tasks)
+	}
+}
+
+func TestProcessMakeupStopsWhenTaskNoLongerOwned(t *testing.T) {
+	resetRegionSemaphores(t)
+
+	cases := map[string]func(mq *MakeupQueue, queueID string){
+		"清空队列": func(mq *MakeupQueue, queueID string) { mq.ClearAllQueue() },
+		"重置后被重新认领": func(mq *MakeupQueue, queueID string) {
+			mq.mu.RLock()
+			old := mq.active[queueID]
+			mq.mu.RUnlock()
+			mq.releaseTask(queueID, old)
+			mq.updateTaskStatusByKey(queueID, "等待中")
+			if _, _, ok := mq.claimTask(queueID); !ok {
+				t.Error("重置后的任务应能被重新认领")
+			}
+		},
+	}
+	for name, interrupt := range cases {
+		t.Run(name, func(t *testing.T) {
+			mq := newMakeupQueue()
+			queueID := mq.AddToQueueWithRegion("owned-user", 3, "ap-east-1")
+			claim := claimQueuedTask(t, mq, queueID)
+
+			launches := 0
+			stubMakeupLaunch(t, func(userID, region, queueID string) (*InstanceCreationResult, error) {
+				launches++
+				// 第一台开机期间任务被外部操作接管
+				interrupt(mq, queueID)
+				return &InstanceCreationResult{Success: true, InstanceID: fmt.Sprintf("i-%d", launches), AccountID: "acc-1"}, nil
+			})
+
+			if err := mq.processMakeup(queueID, 3, claim); err != nil {
+				t.Fatalf("processMakeup 返回错误: %v", err)
+			}
+			if launches != 1 {
+				t.Fatalf("开机次数 = %d, 任务不再由当前协程处理后应停止开机", launches)
+			}
+		})
+	}
+}
+
+func TestWaitingTaskAbandonedAfterMaxAge(t *testing.T) {
+	t.Setenv("MAKEUP_TASK_MAX_AGE", "2h")
+
+	mq := newMakeupQueue()
+	oldID := mq.AddToQueueWithRegion("max-age-old", 1, "ap-east-1")
+	freshID := mq.AddToQueueWithRegion("max-age-fresh", 1, "ap-east-1")
+
+	// 以3小时后为当前时间检查：旧任务超过2小时被放弃，新任务只等待了1小时
+	now := time.Now().Add(3 * time.Hour)
+	mq.mu.Lock()
+	mq.queue[freshID].AddTime = now.Add(-time.Hour)
+	mq.mu.Unlock()
+
+	mq.checkWaitingTasks(now)
+
+	if _, _, status, _ := mq.GetTaskProgress(oldID); status != "已放弃" {
+		t.Fatalf("超过最大存活时间的任务状态 = %s, 期望已放弃", status)
+	}
+	if _, _, status, _ := mq.GetTaskProgress(freshID); status != "等待中" {
+		t.Fatalf("未超过最大存活时间的任务状态 = %s, 期望等待中", status)
+	}
+}
+
+func TestMakeupRetryBudget(t *testing.T) {
+	resetRegionSemaphores(t)
+
+	var attempts int
+	stubMakeupLaunch(t, func(userID, region, queueID string) (*InstanceCreationResult, error) {
+		attempts++
+		accountID := fmt.Sprintf("acc-%d", attempts)
+		return &InstanceCreationResult{AccountID: accountID}, fmt.Errorf("账号[%s]开机失败", accountID)
+	})
+
+	// 重试预算与账号池中的账号数量无关，超过10次的上限同样生效
+	poolSize := GetAccountPool().Size()
+	for _, budget := range []int{3, 15} {
+		t.Run(fmt.Sprint(budget), func(t *testing.T) {
+			t.Setenv("MAKEUP_RETRY_BUDGET", fmt.Sprint(budget))
+			attempts = 0
+
+			mq := newMakeupQueue()
+			queueID := mq.AddToQueueWithRegion("retry-user", 1, "ap-east-1")
+			if err := mq.processMakeup(queueID, 1, claimQueuedTask(t, mq, queueID)); err == nil {
+				t.Fatal("重试预算用尽时应返回错误")
+			}
+			if attempts != budget {
+				t.Fatalf("开机尝试次数 = %d, want %d（账号池大小%d）", attempts, budget, poolSize)
+			}
+			if task := mq.GetQueueItemByKey(queueID); task == nil || task.Status != "等待中" {
+				t.Fatalf("预算用尽后的任务 = %+v, 期望重置为等待中", task)
+			}
+		})
+	}
+}
+
+func TestMakeupInstanceTaggedWithQueueID(t *testing.T) {
+	const userID = "makeup-tag-user"
+	if err := globalDB.Create(&model.Setting{UserID: userID, Region: "日本", InstanceType: "t3.micro", DiskSize: 20}).Error; err != nil {
+		t.Fatalf("写入用户设置失败: %v", err)
+	}
+	accountPool := GetAccountPool()
+	jp := region.JP
+	accountPool.AddAccount(model.Account{ID: "9442", UserID: userID, Key1: "AKIA9442", Key2: "secret", Region: &jp})
+	t.Cleanup(func() { accountPool.RemoveAccount("9442") })
+
+	// 其他测试遗留的补机协程也可能开机，只记录本用户的开机参数
+	var (
+		mu       sync.Mutex
+		launched []aws.CreateInstanceParams
+	)
+	old := LaunchInstances
+	LaunchInstances = func(ctx context.Context, client *aws.AWSClient, params aws.CreateInstanceParams) ([]aws.CreateInstanceResult, error) {
+		if params.UserID == userID {
+			mu.Lock()
+			launched = append(launched, params)
+			mu.Unlock()
+		}
+		return []aws.CreateInstanceResult{{InstanceID: "i-makeup-tagged", Status: "pending"}}, nil
+	}
+	t.Cleanup(func() { LaunchInstances = old })
+
+	queueID := userID + ":" + jp
+	if _, err := CreateInstanceForUser(userID, jp, queueID); err != nil {
+		t.Fatalf("补机开机失败: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(launched) != 1 {
+		t.Fatalf("开机次数 = %d, want 1", len(launched))
+	}
+	if tags := launched[0].Tags; tags[aws.TagSource] != aws.SourceMakeup || tags[aws.TagQueueID] != queueID {
+		t.Fatalf("补机实例标签 = %v, 期望来源为makeup且补机任务ID为%s", tags, queueID)
+	}
+}
+
+func TestGetPendingCountByUser(t *testing.T) {
+	mq := newMakeupQueue()
+	for key, item := range map[string]*MakeupQueueItem{
+		"a": {UserID: "pending-user", Region: region.HK, TotalCount: 3, CompletedCount: 1, Status: "进行中"},
+		"b": {UserID: "pending-user", Region: region.JP, TotalCount: 2, Status: "等待中"},
+		"c": {UserID: "pending-user", Region: region.JP, TotalCount: 4, CompletedCount: 4, Status: "进行中"},
+		"d": {UserID: "pending-user", Region: region.SG, TotalCount: 5, Status: "已放弃"},
+		"e": {UserID: "other-user", Region: region.HK, TotalCount: 9, Status: "等待中"},
+	} {
+		mq.queue[key] = item
+	}
+
+	pending := mq.GetPendingCountByUser("pending-user")
+	if len(pending) != 2 || pending[region.HK] != 2 || pending[region.JP] != 2 {
+		t.Fatalf("待补机数量 = %v, 期望香港2台、日本2台", pending)
+	}
+}
+
+func TestAccountFailureRequeuesWaitingTasks(t *testing.T) {
+	mq := newMakeupQueue()
+	queueID := mq.AddToQueueWithRegion("failover-user", 2, region.SG)
+	// 取走新建任务时发送的通知
+	select {
+	case <-mq.taskChannel:
+	case <-time.After(time.Second):
+		t.Fatal("新建任务未发送通知")
+	}
+
+	sg := region.SG
+	accountPool := GetAccountPool()
+	accountPool.AddAccount(model.Account{ID: "9461", UserID: "failover-user", Key1: "AKIA9461", Key2: "secret", Region: &sg})
+	accountPool.AddAccount(model.Account{ID: "9462", UserID: "failover-user", Key1: "AKIA9462", Key2: "secret", Region: &sg})
+	t.Cleanup(func() {
+		accountPool.RemoveAccount("9461")
+		accountPool.RemoveAccount("9462")
+	})
+
+	// 补机中账号9461被移除，区域内仍有可用账号，任务应立即重新推送
+	accountPool.RemoveAccount("9461")
+	mq.OnAccountFailure(AccountFailure{AccountID: "9461", UserID: "failover-user", Region: region.SG, Removed: true})
+	select {
+	case got := <-mq.taskChannel:
+		if got != queueID {
+			t.Fatalf("重新推送的任务 = %s, want %s", got, queueID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("区域内仍有可用账号时应立即重新推送等待中的任务")
+	}
+
+	// 区域内已没有可用账号时保持暂停
+	accountPool.RemoveAccount("9462")
+	mq.OnAccountFailure(AccountFailure{AccountID: "9462", UserID: "failover-user", Region: region.SG, Removed: true})
+	select {
+	case got := <-mq.taskChannel:
+		t.Fatalf("区域内没有可用账号时不应推送任务, 实际推送了%s", got)
+	default:
+	}
+}
@@ -0,0 +1,58 @@
+// pkg/pool/makeuperr.go
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CreateInstanceForUser 返回的错误类型，补机队列据此决定暂停还是重试
+var (
+	ErrNoAvailableAccount = errors.New("没有可用的账号")      // 区域内没有可用账号，需暂停等待账号恢复
+	ErrQuotaExhausted     = errors.New("区域内所有账号配额已用完") // 区域内账号均因配额或实例类型被跳过，需暂停
+	ErrRegionNotEnabled   = errors.New("账号区域未开通")      // 当前账号未开通该区域，换账号重试
+	ErrTransient          = errors.New("临时错误")         // 限流、容量不足或网络问题，稍后重试
+	ErrGlobalLimitReached = errors.New("已达到全局实例上限")    // 平台实例总数达到上限，需暂停
+	ErrThresholdReached   = errors.New("用户实例数已达到阈值")   // 手动开机或其他任务已补足阈值，剩余数量无需再补
+)
+
+// 区域未开通的AWS错误特征
+var regionNotEnabledMarkers = []string{
+	"OptInRequired",
+	"PendingVerification",
+}
+
+// 可重试的临时AWS错误特征
+var transientMarkers = []string{
+	"RequestLimitExceeded",
+	"Throttling",
+	"InsufficientInstanceCapacity",
+	"ServiceUnavailable",
+	"InternalError",
+	"timeout",
+	"connection reset",
+}
+
+// classifyLaunchError 将AWS开机错误归类为对应的错误类型，保留原始错误信息
+func classifyLaunchError(err error) error {
+	errMsg := err.Error()
+	for _, marker := range regionNotEnabledMarkers {
+		if strings.Contains(errMsg, marker) {
+			return fmt.Errorf("%w: %v", ErrRegionNotEnabled, err)
+		}
+	}
+	for _, marker := range transientMarkers {
+		if strings.Contains(errMsg, marker) {
+			return fmt.Errorf("%w: %v", ErrTransient, err)
+		}
+	}
+	return err
+}
+
+// shouldPauseMakeup 判断补机错误是否需要暂停任务等待，而不是立即换账号重试
+func shouldPauseMakeup(err error) bool {
+	return errors.Is(err, ErrNoAvailableAccount) ||
+		errors.Is(err, ErrQuotaExhausted) ||
+		errors.Is(err, ErrGlobalLimitReached)
+}
@@ -0,0 +1,53 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"portal/pkg/region"
+)
+
+func TestMakeupErrorQueueBehavior(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantPause bool  // 暂停任务等待
+		wantIs    error // 归类后的错误类型，nil表示保持原始错误
+	}{
+		{"没有可用账号", ErrNoAvailableAccount, true, ErrNoAvailableAccount},
+		{"配额用尽", ErrQuotaExhausted, true, ErrQuotaExhausted},
+		{"达到全局上限", fmt.Errorf("%w%d", ErrGlobalLimitReached, 10), true, ErrGlobalLimitReached},
+		{"区域未开通换账号重试", classifyLaunchError(errors.New("api error OptInRequired: not subscribed")), false, ErrRegionNotEnabled},
+		{"限流稍后重试", classifyLaunchError(errors.New("api error RequestLimitExceeded")), false, ErrTransient},
+		{"容量不足稍后重试", classifyLaunchError(errors.New("InsufficientInstanceCapacity: no capacity")), false, ErrTransient},
+		{"其他错误直接换账号", classifyLaunchError(errors.New("InvalidParameterValue")), false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldPauseMakeup(tt.err); got != tt.wantPause {
+				t.Fatalf("shouldPauseMakeup(%v) = %v, want %v", tt.err, got, tt.wantPause)
+			}
+			if tt.wantIs != nil && !errors.Is(tt.err, tt.wantIs) {
+				t.Fatalf("错误 %v 应归类为 %v", tt.err, tt.wantIs)
+			}
+			if tt.wantIs == nil && (errors.Is(tt.err, ErrTransient) || errors.Is(tt.err, ErrRegionNotEnabled)) {
+				t.Fatalf("未知错误 %v 不应被归类", tt.err)
+			}
+		})
+	}
+}
+
+func TestHasActiveAccountInRegion(t *testing.T) {
+	skipped := testAccount("2", region.JP, nil)
+	skipped.IsSkipped = true
+	p := newTestAccountPool(testAccount("1", region.HK, nil), skipped)
+
+	if !p.HasActiveAccountInRegion(region.HK) {
+		t.Fatal("香港区有未跳过的账号")
+	}
+	// 日本区账号全部被跳过时，应返回没有可用账号而不是配额用尽
+	if p.HasActiveAccountInRegion(region.JP) {
+		t.Fatal("日本区账号均被跳过")
+	}
+}
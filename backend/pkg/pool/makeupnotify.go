@@ -0,0 +1,63 @@
+// pkg/pool/makeupnotify.go
+package pool
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"portal/pkg/tg"
+)
+
+// 补机实例等待上线的最长记录时间，超过后按普通实例处理
+const makeupInstanceTTL = time.Hour
+
+var (
+	suppressMakeupOnline     bool
+	suppressMakeupOnlineOnce sync.Once
+
+	makeupInstances sync.Map // 实例ID -> 补机创建时间
+)
+
+// shouldSuppressMakeupOnline 是否跳过补机实例的单独上线通知，改为每轮补机结束后发送一条汇总
+// 通过 MAKEUP_SUPPRESS_ONLINE_NOTIFY=true 开启，默认每台实例单独通知
+func shouldSuppressMakeupOnline() bool {
+	suppressMakeupOnlineOnce.Do(func() {
+		suppressMakeupOnline = os.Getenv("MAKEUP_SUPPRESS_ONLINE_NOTIFY") == "true"
+		if suppressMakeupOnline {
+			log.Printf("补机实例不再单独发送上线通知，改为补机汇总通知")
+		}
+	})
+	return suppressMakeupOnline
+}
+
+// markMakeupInstance 记录由补机创建的实例，上线时跳过单独通知
+func markMakeupInstance(instanceID string) {
+	now := time.Now()
+	// 顺便清理长时间未上线的记录
+	makeupInstances.Range(func(key, value interface{}) bool {
+		if now.Sub(value.(time.Time)) > makeupInstanceTTL {
+			makeupInstances.Delete(key)
+		}
+		return true
+	})
+	makeupInstances.Store(instanceID, now)
+}
+
+// takeMakeupInstance 判断实例是否为近期补机创建的实例，并移除记录，之后的离线再上线按普通实例通知
+func takeMakeupInstance(instanceID string) bool {
+	value, ok := makeupInstances.LoadAndDelete(instanceID)
+	return ok && time.Since(value.(time.Time)) <= makeupInstanceTTL
+}
+
+// notifyMakeupLaunched 发送一轮补机的汇总通知
+func notifyMakeupLaunched(userID string, region string, instances []string) {
+	message := fmt.Sprintf("补机完成\n区域: %s\n数量: %d\n实例:\n%s",
+		region, len(instances), strings.Join(instances, "\n"))
+	if err := tg.NotifyUserMessage(globalDB, userID, message); err != nil {
+		log.Printf("发送补机汇总通知失败: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// setSuppressMakeupOnline 设置是否跳过补机实例的单独上线通知，测试结束后恢复
+func setSuppressMakeupOnline(t *testing.T, suppress bool) {
+	t.Helper()
+	suppressMakeupOnlineOnce.Do(func() {})
+	old := suppressMakeupOnline
+	suppressMakeupOnline = suppress
+	t.Cleanup(func() { suppressMakeupOnline = old })
+}
+
+func TestMakeupInstanceSkipsOnlineNotification(t *testing.T) {
+	const userID = "makeup-notify-user"
+	setStartupGrace(t, 0)
+	setSuppressMakeupOnline(t, true)
+	notified := make(chan string, 4)
+	old := sendInstanceOnlineNotification
+	sendInstanceOnlineNotification = func(db *gorm.DB, m *InstanceMetadata) {
+		if m.UserID == userID {
+			notified <- m.InstanceID
+		}
+	}
+	t.Cleanup(func() { sendInstanceOnlineNotification = old })
+
+	markMakeupInstance("i-makeup-1")
+	t.Cleanup(func() { makeupInstances.Delete("i-makeup-1") })
+
+	p := NewPool()
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-makeup-1", UserID: userID, Region: "ap-east-1"})
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-normal-1", UserID: userID, Region: "ap-east-1"})
+
+	// 只有非补机实例发送单独的上线通知
+	select {
+	case id := <-notified:
+		if id != "i-normal-1" {
+			t.Fatalf("补机实例不应单独发送上线通知: %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("普通实例未发送上线通知")
+	}
+	select {
+	case id := <-notified:
+		t.Fatalf("补机实例不应单独发送上线通知: %s", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// 补机记录上线后即被移除，之后离线再上线按普通实例通知
+	if takeMakeupInstance("i-makeup-1") {
+		t.Fatal("补机实例上线后应移除补机记录")
+	}
+}
+
+func TestMakeupInstanceRecordExpires(t *testing.T) {
+	makeupInstances.Store("i-makeup-old", time.Now().Add(-2*makeupInstanceTTL))
+	t.Cleanup(func() { makeupInstances.Delete("i-makeup-old") })
+
+	if takeMakeupInstance("i-makeup-old") {
+		t.Fatal("超过记录时间的补机实例应按普通实例通知")
+	}
+}
@@ -0,0 +1,96 @@
+// pkg/pool/makeupstats.go
+package pool
+
+import (
+	"log"
+	"time"
+
+	"portal/model"
+
+	"gorm.io/gorm"
+)
+
+// setItemStatus 更新任务状态，同时记录首次开始处理和结束的时间，调用方需持有队列锁
+func setItemStatus(item *MakeupQueueItem, status string) {
+	item.Status = status
+	switch status {
+	case "进行中":
+		if item.StartTime.IsZero() {
+			item.StartTime = time.Now()
+		}
+	case "已完成", "已放弃":
+		item.FinishTime = time.Now()
+	}
+}
+
+// pendingTaskRecord 待写入的任务快照，连同生成快照时的数据库连接
+type pendingTaskRecord struct {
+	db     *gorm.DB
+	record model.MakeupTask
+}
+
+// persistTask 记录任务当前状态的快照，由单个写入协程按记录顺序写入数据库，调用方需持有队列锁
+// 在状态变化时同步生成快照，同一任务只保留最新的快照，避免并发写入时旧状态覆盖新状态
+func (mq *MakeupQueue) persistTask(item *MakeupQueueItem) {
+	db := globalDB
+	if db == nil {
+		return
+	}
+
+	record := model.MakeupTask{
+		QueueID:        item.QueueID,
+		UserID:         item.UserID,
+		Region:         item.Region,
+		TotalCount:     item.TotalCount,
+		CompletedCount: item.CompletedCount,
+		FailedAttempts: item.FailedAttempts,
+		Status:         item.Status,
+		AddTime:        item.AddTime,
+	}
+	if !item.StartTime.IsZero() {
+		startTime := item.StartTime
+		record.StartTime = &startTime
+	}
+	if !item.FinishTime.IsZero() {
+		finishTime := item.FinishTime
+		record.FinishTime = &finishTime
+	}
+
+	mq.persistMu.Lock()
+	mq.persistPending[record.QueueID] = pendingTaskRecord{db: db, record: record}
+	mq.persistMu.Unlock()
+
+	mq.persistOnce.Do(func() { go mq.runPersister() })
+	select {
+	case mq.persistSignal <- struct{}{}:
+	default:
+		// 已有待处理的信号，写入协程会一并写入本次快照
+	}
+}
+
+// runPersister 按顺序写入任务快照，写入失败只记录日志
+func (mq *MakeupQueue) runPersister() {
+	for range mq.persistSignal {
+		mq.persistMu.Lock()
+		pending := mq.persistPending
+		mq.persistPending = make(map[string]pendingTaskRecord)
+		mq.persistMu.Unlock()
+
+		for queueKey, entry := range pending {
+			if err := model.SaveMakeupTask(entry.db, &entry.record); err != nil {
+				log.Printf("保存补机任务[%s]记录失败: %v", queueKey, err)
+			}
+		}
+	}
+}
+
+// recordFailedAttempt 记录任务的一次开机失败
+func (mq *MakeupQueue) recordFailedAttempt(queueKey string) {
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if item, exists := mq.queue[queueKey]; exists {
+		item.FailedAttempts++
+		mq.persistTask(item)
+	}
+}
@@ -0,0 +1,64 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"portal/model"
+)
+
+// taskRows 读取任务的所有记录
+func taskRows(t *testing.T, queueID string) []model.MakeupTask {
+	t.Helper()
+	var rows []model.MakeupTask
+	if err := globalDB.Where("queue_id = ?", queueID).Find(&rows).Error; err != nil {
+		t.Fatalf("读取任务[%s]的记录失败: %v", queueID, err)
+	}
+	return rows
+}
+
+// waitTaskRow 等待任务记录写入数据库并满足条件
+func waitTaskRow(t *testing.T, queueID string, ok func(model.MakeupTask) bool) model.MakeupTask {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		for _, row := range taskRows(t, queueID) {
+			if ok(row) {
+				return row
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("任务[%s]的记录未按期望写入: %+v", queueID, taskRows(t, queueID))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPersistTaskKeepsLatestState(t *testing.T) {
+	const total = 50
+	mq := newMakeupQueue()
+	queueID := mq.AddToQueueWithRegion("u1", total, "ap-east-1")
+	if _, _, ok := mq.claimTask(queueID); !ok {
+		t.Fatal("等待中的任务应能被领取")
+	}
+	for i := 0; i < total; i++ {
+		mq.recordFailedAttempt(queueID)
+		mq.IncrementCompletedCount(queueID)
+	}
+
+	waitTaskRow(t, queueID, func(row model.MakeupTask) bool { return row.Status == "已完成" })
+
+	// 写入协程空闲后记录不应再被旧快照覆盖
+	time.Sleep(50 * time.Millisecond)
+	rows := taskRows(t, queueID)
+	if len(rows) != 1 {
+		t.Fatalf("每个任务只应有一条记录，实际为 %d 条", len(rows))
+	}
+	row := rows[0]
+	if row.Status != "已完成" || row.CompletedCount != total || row.FailedAttempts != total {
+		t.Fatalf("记录应为最终状态（已完成 %d/%d，失败%d次），实际为 %+v", total, total, total, row)
+	}
+	if row.StartTime == nil || row.FinishTime == nil {
+		t.Fatalf("已完成的任务应记录开始和完成时间，实际为 %+v", row)
+	}
+}
@@ -9,52 +9,29 @@ import (
 	"portal/pkg/aws"
 	"portal/repository"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 // InstanceCreationResult 创建实例的结果
 type InstanceCreationResult struct {
-	Success    bool   // 是否成功
-	InstanceID string // 实例ID
-	PublicIP   string // 公网IP
-	Error      error  // 错误信息
+	Success      bool   // 是否成功
+	InstanceID   string // 实例ID
+	PublicIP     string // 公网IP
+	AccountID    string // 开机使用的账号ID
+	InstanceType string // 实例类型
+	Error        error  // 错误信息
 }
 
-// getAMIForRegion 根据区域获取对应的AMI ID
-func getAMIForRegion(regionCode string) string {
-	amiMap := map[string]string{
-		"ap-east-1":      "ami-06dd48f3dbcc241f3", // 香港区域
-		"ap-northeast-3": "ami-0eed40102c8eb6998", // 日本区域
-		"ap-southeast-1": "ami-0acbb557db23991cc", // 新加坡区域
-	}
-
-	if ami, exists := amiMap[regionCode]; exists {
-		return ami
-	}
-
-	// 默认返回香港区域的AMI
-	return "ami-06dd48f3dbcc241f3"
-}
-
-// getScriptForRegion 根据区域获取对应的启动脚本
-func getScriptForRegion(setting *model.Setting, regionCode string) string {
-	// 根据区域选择对应的脚本
-	switch regionCode {
-	case "ap-northeast-3": // 日本区域
-		return setting.JpScript // 直接返回日本区域脚本，无论是否为空
-	case "ap-southeast-1": // 新加坡区域
-		return setting.SgScript // 直接返回新加坡区域脚本，无论是否为空
-	case "ap-east-1": // 香港区域
-		return setting.Script // 香港区域使用通用脚本
-	default:
-		return "" // 其他未知区域返回空字符串
-	}
+// LaunchInstances 调用AWS创建实例，手动开机和自动补机共用，测试中可替换
+var LaunchInstances = func(ctx context.Context, client *aws.AWSClient, params aws.CreateInstanceParams) ([]aws.CreateInstanceResult, error) {
+	return client.CreateInstance(ctx, params)
 }
 
 // CreateInstanceForUser 为用户创建实例
-// 增加 regionOverride 参数，允许指定区域覆盖用户设置
-func CreateInstanceForUser(userID string, regionOverride string) (*InstanceCreationResult, error) {
+// 增加 regionOverride 参数，允许指定区域覆盖用户设置，queueID 为发起的补机任务ID，会写入实例标签
+func CreateInstanceForUser(userID string, regionOverride string, queueID string) (*InstanceCreationResult, error) {
 	log.Printf("调试: 开始为用户[%s]创建实例，区域覆盖=[%s]", userID, regionOverride)
 
 	// 获取数据库连接
@@ -90,6 +67,27 @@ func CreateInstanceForUser(userID string, regionOverride string) (*InstanceCreat
 
 	log.Printf("调试: 使用区域代码=[%s], 实例类型=[%s]", regionCode, setting.InstanceType)
 
+	// 检查用户是否允许在该区域开机
+	if err := setting.CheckRegionAllowed(regionCode); err != nil {
+		log.Printf("用户[%s]不允许在区域[%s]补机: %v", userID, regionCode, err)
+		return nil, err
+	}
+
+	// 与该用户在同一区域的手动开机互斥，避免同时开机超出阈值
+	unlock := LockUserRegions(userID, regionCode)
+	defer unlock()
+
+	// 检查全局实例上限，当前任务已计入待补数量
+	if err := CheckGlobalInstanceLimit(0); err != nil {
+		return nil, err
+	}
+
+	// 在锁内重新检查阈值，入队后手动开机或其他任务可能已补足
+	if headroom, limited := UserRegionHeadroom(userID, regionCode); limited && headroom <= 0 {
+		log.Printf("用户[%s]在区域[%s]的实例数已达到阈值，跳过补机", userID, regionCode)
+		return nil, ErrThresholdReached
+	}
+
 	// 获取下一个可用账号，根据实例类型和区域选择适合的账号
 	log.Printf("调试: 准备获取用户[%s]实例类型[%s]区域[%s]的账号",
 		userID, setting.InstanceType, regionCode)
@@ -98,7 +96,11 @@ func CreateInstanceForUser(userID string, regionOverride string) (*InstanceCreat
 		log.Printf("没有可用的账号，用户[%s]在区域[%s]的补机任务暂停，实例类型[%s]", userID, regionCode, setting.InstanceType)
 		log.Printf("调试: 没有找到可用账号，账号池状态: 总数=%d, 可用=%d",
 			accountPool.Size(), accountPool.AvailableSize())
-		return nil, fmt.Errorf("没有可用的账号")
+		// 区域内仍有未跳过的账号，说明是配额或实例类型被跳过导致
+		if accountPool.HasActiveAccountInRegion(regionCode) {
+			return nil, ErrQuotaExhausted
+		}
+		return nil, ErrNoAvailableAccount
 	}
 
 	log.Printf("调试: 成功获取账号[%s]，准备创建AWS客户端", account.ID)
@@ -107,35 +109,55 @@ func CreateInstanceForUser(userID string, regionOverride string) (*InstanceCreat
 	awsClient := aws.NewAWSClient(account.Key1, account.Key2)
 	// log.Printf("调试: AWS客户端已创建")
 
-	// 获取区域对应的AMI
-	amiID := getAMIForRegion(regionCode)
+	// 获取区域对应的AMI，严格模式下未知区域直接失败
+	amiID, err := aws.ResolveAMI(regionCode)
+	if err != nil {
+		log.Printf("用户[%s]在区域[%s]补机失败: %v", userID, regionCode, err)
+		return nil, err
+	}
 	log.Printf("调试: 区域[%s]的AMI ID=[%s]", regionCode, amiID)
 
 	// 获取区域对应的脚本
-	script := getScriptForRegion(setting, regionCode)
+	script := setting.GetScriptForRegion(regionCode)
+
+	// 获取区域对应的密码
+	password := setting.GetPasswordForRegion(regionCode)
 	// scriptLen := 0
 	// if script != "" {
 	// 	scriptLen = len(script)
 	// }
 	// log.Printf("调试: 获取到启动脚本，长度=%d字节", scriptLen)
 
+	// 解析附加数据盘配置，配置无效时只使用根盘
+	additionalVolumes, err := aws.ParseVolumeSpecs(setting.AdditionalVolumes)
+	if err != nil {
+		log.Printf("用户[%s]的附加数据盘配置无效，忽略: %v", userID, err)
+		additionalVolumes = nil
+	}
+
 	// 准备创建实例的参数
 	params := aws.CreateInstanceParams{
-		Region:       regionCode,              // 使用确定的区域代码
-		ImageID:      amiID,                   // 根据区域获取对应的AMI
-		InstanceType: setting.InstanceType,    // 从用户设置获取
-		DiskSize:     int32(setting.DiskSize), // 从用户设置获取
-		Password:     setting.Password,        // 从用户设置获取
-		Count:        1,                       // 每次只开一台
-		Script:       script,                  // 根据区域获取对应的脚本
-		UserID:       userID,                  // 用于标签
-		AccountID:    account.ID,              // 用于标签
+		Region:            regionCode,              // 使用确定的区域代码
+		ImageID:           amiID,                   // 根据区域获取对应的AMI
+		InstanceType:      setting.InstanceType,    // 从用户设置获取
+		DiskSize:          int32(setting.DiskSize), // 从用户设置获取
+		Password:          password,                // 根据区域获取的密码
+		Count:             1,                       // 每次只开一台
+		Script:            script,                  // 根据区域获取对应的脚本
+		UserID:            userID,                  // 用于标签
+		AccountID:         account.ID,              // 用于标签
+		SkipSSHPassword:   setting.SkipSSHPassword, // 是否跳过SSH密码配置
+		AdditionalVolumes: additionalVolumes,       // 附加数据盘
+		Tags: map[string]string{ // 标记来源补机任务，便于追溯
+			aws.TagSource:  aws.SourceMakeup,
+			aws.TagQueueID: queueID,
+		},
 	}
 	// log.Printf("调试: 创建实例参数已准备完成")
 
 	// 执行创建操作
 	// log.Printf("调试: 准备调用AWS API创建实例")
-	instances, err := awsClient.CreateInstance(context.Background(), params)
+	instances, err := LaunchInstances(context.Background(), awsClient, params)
 	if err != nil {
 		errMsg := err.Error()
 		log.Printf("使用账号[%s]在区域[%s]开机失败, 实例类型[%s]: %v", account.ID, regionCode, setting.InstanceType, err)
@@ -146,33 +168,74 @@ func CreateInstanceForUser(userID string, regionOverride string) (*InstanceCreat
 		handleAccountError(db, account.ID, errMsg, awsClient, setting.InstanceType, regionCode)
 		log.Printf("调试: 账号错误处理完成")
 
-		// 返回错误
-		return nil, err
+		// 返回归类后的错误，同时带上使用的账号便于调用方记录
+		err = classifyLaunchError(err)
+		return &InstanceCreationResult{
+			Success:   false,
+			AccountID: account.ID,
+			Error:     err,
+		}, err
 	}
 
 	// 开机成功，更新账号的实例使用计数
 	// log.Printf("调试: AWS创建实例成功，实例ID=%s", instances[0].InstanceID)
 	// log.Printf("调试: 准备更新账号[%s]使用计数", account.ID)
 	accountPool.IncrementInstanceUsage(account.ID, setting.InstanceType, regionCode)
+	RecordUserLaunches(userID, regionCode, instances[0].InstanceID)
+	if shouldSuppressMakeupOnline() {
+		markMakeupInstance(instances[0].InstanceID)
+	}
 	// log.Printf("调试: 账号使用计数已更新")
 
 	// 开机成功
 	log.Printf("用户[%s]使用账号[%s]在区域[%s]补机成功，实例类型[%s]，实例ID[%s]", userID, account.ID, regionCode, setting.InstanceType, instances[0].InstanceID)
 
 	result := &InstanceCreationResult{
-		Success:    true,
-		InstanceID: instances[0].InstanceID,
-		PublicIP:   instances[0].PublicIP,
+		Success:      true,
+		InstanceID:   instances[0].InstanceID,
+		PublicIP:     instances[0].PublicIP,
+		AccountID:    account.ID,
+		InstanceType: setting.InstanceType,
 	}
 
 	log.Printf("调试: CreateInstanceForUser完成，成功=true，实例ID=%s", instances[0].InstanceID)
 	return result, nil
 }
 
+// terminateUnreachableInstance 终止补机开机后未上报的实例，并释放其占用的账号使用计数
+func terminateUnreachableInstance(result *InstanceCreationResult, regionCode string) error {
+	accountPool := GetAccountPool()
+	account := accountPool.GetAccount(result.AccountID)
+	if account == nil {
+		return fmt.Errorf("账号[%s]不在账号池中", result.AccountID)
+	}
+
+	awsClient := aws.NewAWSClient(account.Key1, account.Key2)
+	if _, err := awsClient.DeleteInstance(context.Background(), aws.DeleteInstanceParams{
+		Region:     regionCode,
+		InstanceID: result.InstanceID,
+	}); err != nil {
+		return err
+	}
+
+	accountPool.ReleaseInstanceUsage(result.AccountID, result.InstanceType, regionCode)
+	forgetLaunch(result.InstanceID)
+	return nil
+}
+
 // handleAccountError 处理账号错误
 func handleAccountError(db *gorm.DB, accountID string, errMsg string, awsClient *aws.AWSClient, instanceType string, regionCode string) {
 	accountPool := GetAccountPool()
 
+	// 移除前记录账号所属用户，用于失败通知
+	var ownerID string
+	if account := accountPool.GetAccount(accountID); account != nil {
+		ownerID = account.UserID
+	}
+
+	// 处理完成后记录失败历史，保留原始错误便于排查
+	defer accountPool.RecordAccountFailure(accountID, instanceType, regionCode, errMsg)
+
 	if strings.Contains(errMsg, "AuthFailure") ||
 		strings.Contains(errMsg, "not able to validate the provided access credentials") {
 		// 账号凭证无效，检查账号状态
@@ -188,6 +251,7 @@ func handleAccountError(db *gorm.DB, accountID string, errMsg string, awsClient
 
 			// 从账号池中移除账号
 			accountPool.RemoveAccount(accountID)
+			reportAccountFailure(accountID, ownerID, regionCode, "账号已失效", true)
 		} else {
 			// 获取区域类型，只有香港区才需要检查区域是否开通
 			if regionCode == "ap-east-1" { // 香港区
@@ -195,23 +259,26 @@ func handleAccountError(db *gorm.DB, accountID string, errMsg string, awsClient
 				status, regionErr := awsClient.CheckRegionStatus(context.Background(), regionCode)
 
 				if regionErr != nil || status != "启用" {
-					// 尝试开通香港区
-					enableErr := awsClient.EnableRegion(context.Background(), regionCode)
+					// 尝试开通香港区，并在后台按退避间隔复查开通状态，开通后自动恢复账号
+					enableErr := enableRegionAndWatch(awsClient, accountID, regionCode)
 					if enableErr != nil {
 						log.Printf("为账号[%s]开通香港区域失败: %v", accountID, enableErr)
 					}
 
 					// 标记账号需要跳过，稍后再重试
 					accountPool.MarkAccountFailed(accountID, "香港区域未开通，已尝试开通")
+					reportAccountFailure(accountID, ownerID, regionCode, "香港区域未开通，已尝试开通", false)
 				}
 			} else {
 				// 其他区域（日本、新加坡）不需要单独开通，但可能仍有其他凭证问题
 				accountPool.MarkAccountFailed(accountID, fmt.Sprintf("%s区域凭证验证失败", regionCode))
+				reportAccountFailure(accountID, ownerID, regionCode, fmt.Sprintf("%s区域凭证验证失败", regionCode), false)
 			}
 		}
 	} else if strings.Contains(errMsg, "PendingVerification") {
 		// 区域资源验证中
 		accountPool.MarkAccountFailed(accountID, fmt.Sprintf("%s区域资源验证中", regionCode))
+		reportAccountFailure(accountID, ownerID, regionCode, fmt.Sprintf("%s区域资源验证中", regionCode), false)
 	} else if strings.Contains(errMsg, "VcpuLimitExceeded") ||
 		strings.Contains(errMsg, "vCPU capacity") {
 		// 配额用完 - 针对特定实例类型标记
@@ -222,10 +289,24 @@ func handleAccountError(db *gorm.DB, accountID string, errMsg string, awsClient
 		} else {
 			// 如果是小型实例也配额不足，整个账号标记为跳过
 			accountPool.MarkAccountFailed(accountID, fmt.Sprintf("%s区域配额已用完，跳过", regionCode))
+			reportAccountFailure(accountID, ownerID, regionCode, fmt.Sprintf("%s区域配额已用完，跳过", regionCode), false)
 			log.Printf("账号[%s]在区域[%s]的所有实例类型配额均不足", accountID, regionCode)
 		}
 	} else {
 		// 其他错误
 		accountPool.MarkAccountFailed(accountID, fmt.Sprintf("%s区域开机失败: %s", regionCode, errMsg))
+		reportAccountFailure(accountID, ownerID, regionCode, fmt.Sprintf("%s区域开机失败: %s", regionCode, errMsg), false)
 	}
 }
+
+// reportAccountFailure 触发账号失败事件，通知已注册的监听器
+func reportAccountFailure(accountID, userID, regionCode, reason string, removed bool) {
+	GetEventManager().TriggerAccountFailureEvent(AccountFailure{
+		AccountID: accountID,
+		UserID:    userID,
+		Region:    regionCode,
+		Reason:    reason,
+		Removed:   removed,
+		Time:      time.Now(),
+	})
+}
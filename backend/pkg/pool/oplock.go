@@ -0,0 +1,140 @@
+// pkg/pool/oplock.go
+package pool
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"portal/model"
+	"portal/repository"
+)
+
+// userRegionLocks 用户+区域级别的开机互斥锁映射，手动开机和自动补机共用
+var userRegionLocks sync.Map
+
+// getUserRegionLock 获取指定用户在指定区域的开机互斥锁
+func getUserRegionLock(userID string, regionCode string) *sync.Mutex {
+	actual, _ := userRegionLocks.LoadOrStore(userID+":"+regionCode, &sync.Mutex{})
+	return actual.(*sync.Mutex)
+}
+
+// LockUserRegions 锁定用户在多个区域的开机操作，返回解锁函数
+// 避免手动开机和自动补机同时为同一用户同一区域开机，导致重复计入阈值和配额
+// 按区域排序后依次加锁，保证多个调用方之间不会死锁
+func LockUserRegions(userID string, regionCodes ...string) func() {
+	unique := make(map[string]bool, len(regionCodes))
+	sorted := make([]string, 0, len(regionCodes))
+	for _, code := range regionCodes {
+		if !unique[code] {
+			unique[code] = true
+			sorted = append(sorted, code)
+		}
+	}
+	sort.Strings(sorted)
+
+	locks := make([]*sync.Mutex, 0, len(sorted))
+	for _, code := range sorted {
+		lock := getUserRegionLock(userID, code)
+		lock.Lock()
+		locks = append(locks, lock)
+	}
+
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+}
+
+// launchRecordTTL 开机记录的保留时间，超过该时间仍未上报的实例不再计入阈值
+const launchRecordTTL = 10 * time.Minute
+
+// launchRecord 已开机但尚未上报的实例
+type launchRecord struct {
+	UserID     string
+	RegionCode string
+	LaunchedAt time.Time
+}
+
+var (
+	launchRecordsMu sync.Mutex
+	launchRecords   = make(map[string]launchRecord) // 实例ID -> 开机记录
+)
+
+// RecordUserLaunches 记录用户在区域内新开的实例，上报前也计入阈值，避免后续开机重复补足同一缺口
+func RecordUserLaunches(userID string, regionCode string, instanceIDs ...string) {
+	launchRecordsMu.Lock()
+	defer launchRecordsMu.Unlock()
+	now := time.Now()
+	for _, id := range instanceIDs {
+		launchRecords[id] = launchRecord{UserID: userID, RegionCode: regionCode, LaunchedAt: now}
+	}
+}
+
+// forgetLaunch 移除实例的开机记录，用于实例被终止后
+func forgetLaunch(instanceID string) {
+	launchRecordsMu.Lock()
+	defer launchRecordsMu.Unlock()
+	delete(launchRecords, instanceID)
+}
+
+// pruneLaunchRecordsLocked 清理已上报或过期的开机记录，调用方需持有launchRecordsMu
+func pruneLaunchRecordsLocked() {
+	for id, record := range launchRecords {
+		if time.Since(record.LaunchedAt) > launchRecordTTL || instanceReported(id) {
+			delete(launchRecords, id)
+		}
+	}
+}
+
+// unreportedLaunchCount 用户在区域内已开机但尚未上报的实例数量，同时清理已上报或过期的记录
+func unreportedLaunchCount(userID string, regionCode string) int {
+	launchRecordsMu.Lock()
+	defer launchRecordsMu.Unlock()
+	pruneLaunchRecordsLocked()
+	count := 0
+	for _, record := range launchRecords {
+		if record.UserID == userID && record.RegionCode == regionCode {
+			count++
+		}
+	}
+	return count
+}
+
+// totalUnreportedLaunches 所有用户已开机但尚未上报的实例数量，同时清理已上报或过期的记录
+func totalUnreportedLaunches() int {
+	launchRecordsMu.Lock()
+	defer launchRecordsMu.Unlock()
+	pruneLaunchRecordsLocked()
+	return len(launchRecords)
+}
+
+// UserRegionHeadroom 用户在区域内距补机阈值还可开机的数量，调用方需持有 LockUserRegions 的锁
+// 在线实例与已开机未上报的实例都计入阈值；未开启监控、区域补机关闭或阈值为0时不限制，limited 返回false
+func UserRegionHeadroom(userID string, regionCode string) (headroom int, limited bool) {
+	db := repository.GetDB()
+	if db == nil {
+		return 0, false
+	}
+	monitor, err := model.GetMonitorByUserID(db, userID)
+	if err != nil {
+		log.Printf("获取用户[%s]监控配置失败，不按阈值限制开机: %v", userID, err)
+		return 0, false
+	}
+	threshold := model.GetThresholdByRegion(monitor, regionCode)
+	if threshold == 0 || !monitor.IsRegionEnabled(regionCode) {
+		return 0, false
+	}
+
+	online := 0
+	if GlobalPool != nil {
+		online = countUsableInstances(monitor, regionCode, GlobalPool.GetInstancesByUserIDAndRegion(userID, regionCode))
+	}
+	headroom = threshold - online - unreportedLaunchCount(userID, regionCode)
+	if headroom < 0 {
+		headroom = 0
+	}
+	return headroom, true
+}
@@ -2,15 +2,20 @@
 package pool
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
+	"portal/model"
 	"portal/pkg/tg"
 	"portal/repository"
 
 	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 // InstanceMetadata 实例元数据结构，与客户端上报的数据结构保持一致
@@ -24,6 +29,7 @@ type InstanceMetadata struct {
 	LaunchTime   string    `json:"launch_time"`   // 启动时间
 	ReportTime   string    `json:"report_time"`   // 上报时间
 	LastSeen     time.Time `json:"-"`             // 最后一次上报的时间戳
+	Degraded     bool      `json:"degraded"`      // 上报缓慢：超过上报缓慢判定时间未上报，但尚未离线
 }
 
 // IPLock IP锁定信息
@@ -32,6 +38,13 @@ type IPLock struct {
 	ExpiresAt time.Time // 锁定过期时间
 }
 
+// IPLockInfo IP锁定信息的输出结构
+type IPLockInfo struct {
+	InstanceID string    `json:"instance_id"` // 实例ID
+	IP         string    `json:"ip"`          // 锁定的IP地址
+	ExpiresAt  time.Time `json:"expires_at"`  // 锁定过期时间
+}
+
 // Client 表示一个WebSocket客户端连接
 type Client struct {
 	Conn *websocket.Conn // WebSocket连接
@@ -50,6 +63,18 @@ type Pool struct {
 	// 新增：IP锁定映射表
 	ipLocks   map[string]*IPLock // 存储实例ID -> IP锁定信息
 	ipLocksMu sync.RWMutex       // IP锁定映射表的互斥锁
+
+	startedAt time.Time // 连接池创建时间，用于判断启动宽限期
+
+	// 新增：最近离线的实例，保留期内仍可在接口中查看，受mu保护
+	recentlyOffline map[string]*OfflineInstance
+
+	// 新增：等待发送的离线通知，用户配置了离线通知延迟时使用
+	pendingOffline   map[string]*time.Timer // 实例ID -> 延迟通知定时器
+	pendingOfflineMu sync.Mutex             // 离线通知定时器的互斥锁
+
+	// 新增：异步的离线通知和补机检测，测试中可等待其执行完成
+	offlineWG sync.WaitGroup
 }
 
 // NewPool 创建一个新的连接池
@@ -60,6 +85,10 @@ func NewPool() *Pool {
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 		ipLocks:    make(map[string]*IPLock),
+		startedAt:  time.Now(),
+
+		pendingOffline:  make(map[string]*time.Timer),
+		recentlyOffline: make(map[string]*OfflineInstance),
 	}
 }
 
@@ -90,21 +119,25 @@ func (c *Client) ReadMessages() {
 	for {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("客户端消息超过大小限制，关闭连接: %s", c.Conn.RemoteAddr())
+				break
+			}
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("读取消息错误: %v", err)
 			}
 			break
 		}
 
-		// 解析实例数据
-		var metadata InstanceMetadata
-		if err := json.Unmarshal(message, &metadata); err != nil {
+		// 解析实例数据，支持批量上报
+		metadatas, err := parseInstanceReport(message)
+		if err != nil {
 			log.Printf("解析消息失败: %v", err)
 			continue
 		}
 
 		// 更新实例状态
-		c.Pool.UpdateInstance(&metadata)
+		c.Pool.UpdateInstances(metadatas)
 	}
 }
 
@@ -132,48 +165,110 @@ func (pool *Pool) LockInstanceIP(instanceID string, newIP string, duration time.
 	pool.mu.Lock()
 	if instance, exists := pool.Instances[instanceID]; exists {
 		oldIP := instance.IPv4
-		instance.IPv4 = newIP
+		updated := *instance
+		updated.IPv4 = newIP
+		pool.Instances[instanceID] = &updated
 		log.Printf("实例[%s]IP立即更新: %s -> %s", instanceID, oldIP, newIP)
 	}
 	pool.mu.Unlock()
 }
 
+// GetIPLocks 获取当前所有未过期的IP锁定
+func (pool *Pool) GetIPLocks() []IPLockInfo {
+	pool.ipLocksMu.RLock()
+	defer pool.ipLocksMu.RUnlock()
+
+	now := time.Now()
+	locks := make([]IPLockInfo, 0, len(pool.ipLocks))
+	for instanceID, lock := range pool.ipLocks {
+		if now.After(lock.ExpiresAt) {
+			continue
+		}
+		locks = append(locks, IPLockInfo{
+			InstanceID: instanceID,
+			IP:         lock.IP,
+			ExpiresAt:  lock.ExpiresAt,
+		})
+	}
+
+	// 按过期时间排序
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].ExpiresAt.Before(locks[j].ExpiresAt)
+	})
+
+	return locks
+}
+
+// ClearIPLock 清除指定实例的IP锁定，返回是否存在该锁定
+func (pool *Pool) ClearIPLock(instanceID string) bool {
+	pool.ipLocksMu.Lock()
+	defer pool.ipLocksMu.Unlock()
+
+	if _, exists := pool.ipLocks[instanceID]; !exists {
+		return false
+	}
+	delete(pool.ipLocks, instanceID)
+	log.Printf("已清除实例[%s]的IP锁定", instanceID)
+	return true
+}
+
+// ClearAllIPLocks 清除所有IP锁定，返回清除的数量
+func (pool *Pool) ClearAllIPLocks() int {
+	pool.ipLocksMu.Lock()
+	defer pool.ipLocksMu.Unlock()
+
+	count := len(pool.ipLocks)
+	pool.ipLocks = make(map[string]*IPLock)
+	log.Printf("已清除所有IP锁定，共%d个", count)
+	return count
+}
+
 // UpdateInstance 更新实例状态，考虑IP锁定
 func (pool *Pool) UpdateInstance(metadata *InstanceMetadata) {
-	// 检查该实例是否在IP锁定状态
-	var useLockedIP bool
-	var lockedIP string
+	pool.UpdateInstances([]*InstanceMetadata{metadata})
+}
 
-	pool.ipLocksMu.RLock()
-	if ipLock, exists := pool.ipLocks[metadata.InstanceID]; exists {
-		if time.Now().Before(ipLock.ExpiresAt) {
-			// IP锁定未过期，使用锁定的IP替换上报的IP
-			useLockedIP = true
-			lockedIP = ipLock.IP
+// UpdateInstances 批量更新实例状态，整批只获取一次锁
+func (pool *Pool) UpdateInstances(metadatas []*InstanceMetadata) {
+	if len(metadatas) == 0 {
+		return
+	}
+
+	// 检查实例是否在IP锁定状态，锁定未过期时使用锁定的IP替换上报的IP
+	now := time.Now()
+	pool.ipLocksMu.Lock()
+	for _, metadata := range metadatas {
+		ipLock, exists := pool.ipLocks[metadata.InstanceID]
+		if !exists {
+			continue
+		}
+		if now.Before(ipLock.ExpiresAt) {
+			originalIP := metadata.IPv4
+			metadata.IPv4 = ipLock.IP
+			log.Printf("实例[%s]上报的IP[%s]被锁定的IP[%s]覆盖", metadata.InstanceID, originalIP, ipLock.IP)
 		} else {
-			// IP锁定已过期，需要从锁定表中移除
-			pool.ipLocksMu.RUnlock()
-			pool.ipLocksMu.Lock()
+			// IP锁定已过期，从锁定表中移除
 			delete(pool.ipLocks, metadata.InstanceID)
-			pool.ipLocksMu.Unlock()
-			pool.ipLocksMu.RLock()
 		}
 	}
-	pool.ipLocksMu.RUnlock()
+	pool.ipLocksMu.Unlock()
 
-	// 如果IP锁定生效，替换上报的IP
-	if useLockedIP {
-		originalIP := metadata.IPv4
-		metadata.IPv4 = lockedIP
-		log.Printf("实例[%s]上报的IP[%s]被锁定的IP[%s]覆盖", metadata.InstanceID, originalIP, lockedIP)
-	}
+	var onlineInstances []*InstanceMetadata
 
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
+	for _, metadata := range metadatas {
+		metadata.LastSeen = now
+		metadata.Degraded = false
 
-	metadata.LastSeen = time.Now()
+		if existing, exists := pool.Instances[metadata.InstanceID]; exists {
+			if existing.Degraded {
+				log.Printf("实例[%s]恢复正常上报", metadata.InstanceID)
+			}
+			// 更新现有实例
+			pool.Instances[metadata.InstanceID] = metadata
+			continue
+		}
 
-	if _, exists := pool.Instances[metadata.InstanceID]; !exists {
 		// 新实例上线
 		log.Printf("新实例上线: ID=%s, 类型=%s, 用户=%s, IP=%s, 区域=%s",
 			metadata.InstanceID,
@@ -184,28 +279,77 @@ func (pool *Pool) UpdateInstance(metadata *InstanceMetadata) {
 
 		// 保存实例信息
 		pool.Instances[metadata.InstanceID] = metadata
+		if _, flapped := pool.recentlyOffline[metadata.InstanceID]; flapped {
+			delete(pool.recentlyOffline, metadata.InstanceID)
+			log.Printf("实例[%s]离线后在保留期内恢复", metadata.InstanceID)
+		}
+
+		// 离线通知尚在延迟期内，视为短暂离线，离线和上线通知都不发送
+		if pool.cancelPendingOffline(metadata.InstanceID) {
+			log.Printf("实例[%s]在离线通知延迟期内恢复，跳过上线和离线通知", metadata.InstanceID)
+			continue
+		}
+		onlineInstances = append(onlineInstances, metadata)
+	}
+	pool.mu.Unlock()
 
-		// 发送实例上线TG通知
-		go func(m *InstanceMetadata) {
-			db := repository.GetDB()
-			err := tg.NotifyInstanceStatus(
-				db,
-				true, // isOnline
-				m.UserID,
-				m.AccountID,
-				m.InstanceID,
-				m.IPv4,
-				m.InstanceType,
-				m.Region,
-			)
-			if err != nil {
-				log.Printf("发送实例上线TG通知失败: %v", err)
+	// 启动宽限期内重连的实例不发送上线通知，避免重启后通知刷屏
+	if len(onlineInstances) > 0 && pool.InStartupGrace() {
+		log.Printf("启动宽限期内，跳过%d个实例的上线通知", len(onlineInstances))
+		return
+	}
+
+	// 发送实例上线TG通知
+	for _, metadata := range onlineInstances {
+		// 补机创建的实例由补机汇总通知，不再单独通知
+		if shouldSuppressMakeupOnline() && takeMakeupInstance(metadata.InstanceID) {
+			log.Printf("实例[%s]由补机创建，跳过单独的上线通知", metadata.InstanceID)
+			continue
+		}
+		go sendInstanceOnlineNotification(repository.GetDB(), metadata)
+	}
+}
+
+// sendInstanceOnlineNotification 发送实例上线TG通知，测试中可替换
+var sendInstanceOnlineNotification = func(db *gorm.DB, m *InstanceMetadata) {
+	err := tg.NotifyInstanceStatus(
+		db,
+		true, // isOnline
+		m.UserID,
+		m.AccountID,
+		m.InstanceID,
+		m.IPv4,
+		m.InstanceType,
+		m.Region,
+	)
+	if err != nil {
+		log.Printf("发送实例上线TG通知失败: %v", err)
+	}
+}
+
+// parseInstanceReport 解析客户端上报的消息，支持单个实例对象或实例数组
+func parseInstanceReport(message []byte) ([]*InstanceMetadata, error) {
+	trimmed := bytes.TrimSpace(message)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []*InstanceMetadata
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return nil, err
+		}
+		// 过滤空元素
+		metadatas := make([]*InstanceMetadata, 0, len(batch))
+		for _, metadata := range batch {
+			if metadata != nil && metadata.InstanceID != "" {
+				metadatas = append(metadatas, metadata)
 			}
-		}(metadata)
-	} else {
-		// 更新现有实例
-		pool.Instances[metadata.InstanceID] = metadata
+		}
+		return metadatas, nil
+	}
+
+	var metadata InstanceMetadata
+	if err := json.Unmarshal(trimmed, &metadata); err != nil {
+		return nil, err
 	}
+	return []*InstanceMetadata{&metadata}, nil
 }
 
 // CheckInstanceStatus 定期检查实例状态，同时清理过期的IP锁定
@@ -214,63 +358,193 @@ func (pool *Pool) CheckInstanceStatus() {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		var offlineInstances []*InstanceMetadata
-		userMap := make(map[string]bool) // 用于去重的用户Map
-		now := time.Now()
-
-		// 清理过期的IP锁定
-		pool.ipLocksMu.Lock()
-		for instanceID, lock := range pool.ipLocks {
-			if now.After(lock.ExpiresAt) {
-				log.Printf("实例[%s]的IP锁定已过期，移除锁定", instanceID)
-				delete(pool.ipLocks, instanceID)
-			}
+		pool.checkInstancesOnce(time.Now())
+	}
+}
+
+// checkInstancesOnce 执行一轮实例状态检查：清理过期的IP锁定和离线记录，标记上报缓慢的实例并处理离线实例
+func (pool *Pool) checkInstancesOnce(now time.Time) {
+	var offlineInstances, degradedInstances []*InstanceMetadata
+	userMap := make(map[string]bool) // 用于去重的用户Map
+
+	// 清理过期的IP锁定
+	pool.ipLocksMu.Lock()
+	for instanceID, lock := range pool.ipLocks {
+		if now.After(lock.ExpiresAt) {
+			log.Printf("实例[%s]的IP锁定已过期，移除锁定", instanceID)
+			delete(pool.ipLocks, instanceID)
 		}
-		pool.ipLocksMu.Unlock()
-
-		pool.mu.Lock()
-		for instanceID, metadata := range pool.Instances {
-			if now.Sub(metadata.LastSeen) > 60*time.Second {
-				log.Printf("实例离线: 用户ID=%s, 账号ID=%s, IP=%s, 实例ID=%s",
-					metadata.UserID,
-					metadata.AccountID,
-					metadata.IPv4,
-					instanceID)
-				// 保存离线实例信息用于发送通知
-				offlineInstances = append(offlineInstances, metadata)
-				// 将用户ID添加到Map中而不是数组，自动去重
-				userMap[metadata.UserID] = true
-				delete(pool.Instances, instanceID)
-			}
+	}
+	pool.ipLocksMu.Unlock()
+
+	// 清理超过保留期的离线实例
+	pool.purgeExpiredOffline(now)
+
+	// 启动宽限期内实例还在陆续重连，不做离线检测
+	if pool.InStartupGrace() {
+		return
+	}
+
+	pool.mu.Lock()
+	for instanceID, metadata := range pool.Instances {
+		offline, degraded := classifyInstance(now.Sub(metadata.LastSeen))
+		if degraded && !metadata.Degraded {
+			// 上报缓慢只标记不移除，仍计入在线数量
+			log.Printf("实例上报缓慢: 用户ID=%s, 实例ID=%s, 已%v未上报",
+				metadata.UserID, instanceID, now.Sub(metadata.LastSeen).Round(time.Second))
+			// 查询接口返回的是map中的指针，替换为修改后的副本而不是原地修改
+			flagged := *metadata
+			flagged.Degraded = true
+			metadata = &flagged
+			pool.Instances[instanceID] = metadata
+			degradedInstances = append(degradedInstances, metadata)
+		}
+		if offline {
+			log.Printf("实例离线: 用户ID=%s, 账号ID=%s, IP=%s, 实例ID=%s",
+				metadata.UserID,
+				metadata.AccountID,
+				metadata.IPv4,
+				instanceID)
+			// 保存离线实例信息用于发送通知
+			offlineInstances = append(offlineInstances, metadata)
+			// 将用户ID添加到Map中而不是数组，自动去重
+			userMap[metadata.UserID] = true
+			delete(pool.Instances, instanceID)
+			pool.retainOfflineLocked(metadata, now)
 		}
-		pool.mu.Unlock()
-
-		// 发送实例离线TG通知
-		for _, metadata := range offlineInstances {
-			go func(m *InstanceMetadata) {
-				db := repository.GetDB()
-				err := tg.NotifyInstanceStatus(
-					db,
-					false, // isOnline
-					m.UserID,
-					m.AccountID,
-					m.InstanceID,
-					m.IPv4,
-					m.InstanceType,
-					m.Region,
-				)
-				if err != nil {
-					log.Printf("发送实例离线TG通知失败: %v", err)
-				}
-			}(metadata)
+	}
+	pool.mu.Unlock()
+
+	pool.goOffline(func() { notifyInstanceDegraded(degradedInstances) })
+	pool.handleOfflineInstances(offlineInstances, userMap)
+}
+
+// detectOfflineUser 对出现离线实例的用户进行补机检测，测试中可替换
+var detectOfflineUser = func(userID string) *DetectResult {
+	return GlobalDetector.DetectSingleUser(userID)
+}
+
+// handleOfflineInstances 发送离线通知并对涉及的用户进行补机检测
+func (pool *Pool) handleOfflineInstances(offlineInstances []*InstanceMetadata, userMap map[string]bool) {
+	// 发送实例离线TG通知
+	for _, metadata := range offlineInstances {
+		pool.goOffline(func() { pool.notifyInstanceOffline(metadata) })
+	}
+
+	// 对去重后的用户列表进行检测
+	for userID := range userMap {
+		if result := detectOfflineUser(userID); result != nil {
+			log.Printf("用户[%s]需要补机%d台", result.UserID, result.Count)
 		}
+	}
+}
 
-		// 对去重后的用户列表进行检测
-		for userID := range userMap {
-			if result := GlobalDetector.DetectSingleUser(userID); result != nil {
-				log.Printf("用户[%s]需要补机%d台", result.UserID, result.Count)
-			}
+// MarkInstanceOffline 立即将实例标记为离线，走与超时离线相同的通知和补机检测流程
+func (pool *Pool) MarkInstanceOffline(instanceID string) (*InstanceMetadata, bool) {
+	pool.mu.Lock()
+	metadata, exists := pool.Instances[instanceID]
+	if exists {
+		delete(pool.Instances, instanceID)
+		pool.retainOfflineLocked(metadata, time.Now())
+	}
+	pool.mu.Unlock()
+
+	if !exists {
+		return nil, false
+	}
+
+	log.Printf("实例被手动标记离线: 用户ID=%s, 账号ID=%s, IP=%s, 实例ID=%s",
+		metadata.UserID, metadata.AccountID, metadata.IPv4, instanceID)
+	// 补机检测包含延迟等待，异步执行避免阻塞调用方（调试接口的HTTP请求）
+	pool.goOffline(func() {
+		pool.handleOfflineInstances([]*InstanceMetadata{metadata}, map[string]bool{metadata.UserID: true})
+	})
+	return metadata, true
+}
+
+// goOffline 异步执行离线处理，记录在offlineWG中以便等待
+func (pool *Pool) goOffline(fn func()) {
+	pool.offlineWG.Add(1)
+	go func() {
+		defer pool.offlineWG.Done()
+		fn()
+	}()
+}
+
+// waitOffline 等待已发起的离线通知和补机检测执行完成，包括延迟中的离线通知
+func (pool *Pool) waitOffline() {
+	pool.offlineWG.Wait()
+}
+
+// notifyInstanceOffline 发送实例离线通知，用户配置了离线通知延迟时，延迟结束仍未恢复才发送
+func (pool *Pool) notifyInstanceOffline(m *InstanceMetadata) {
+	db := repository.GetDB()
+	delay, _, err := model.GetNotifySuppression(db, m.UserID)
+	if err != nil {
+		log.Printf("获取用户[%s]离线通知延迟失败，立即通知: %v", m.UserID, err)
+		delay = 0
+	}
+
+	if delay <= 0 {
+		sendInstanceOfflineNotification(db, m)
+		return
+	}
+
+	pool.pendingOfflineMu.Lock()
+	defer pool.pendingOfflineMu.Unlock()
+
+	if old, exists := pool.pendingOffline[m.InstanceID]; exists && old.Stop() {
+		pool.offlineWG.Done()
+	}
+
+	var timer *time.Timer
+	pool.offlineWG.Add(1)
+	timer = time.AfterFunc(delay, func() {
+		defer pool.offlineWG.Done()
+		pool.pendingOfflineMu.Lock()
+		// 定时器已被取消或替换时不再发送
+		if pool.pendingOffline[m.InstanceID] != timer {
+			pool.pendingOfflineMu.Unlock()
+			return
 		}
+		delete(pool.pendingOffline, m.InstanceID)
+		pool.pendingOfflineMu.Unlock()
+
+		sendInstanceOfflineNotification(repository.GetDB(), m)
+	})
+	pool.pendingOffline[m.InstanceID] = timer
+}
+
+// cancelPendingOffline 取消实例尚未发送的离线通知，返回是否存在待发送的通知
+func (pool *Pool) cancelPendingOffline(instanceID string) bool {
+	pool.pendingOfflineMu.Lock()
+	defer pool.pendingOfflineMu.Unlock()
+
+	timer, exists := pool.pendingOffline[instanceID]
+	if !exists {
+		return false
+	}
+	if timer.Stop() {
+		pool.offlineWG.Done()
+	}
+	delete(pool.pendingOffline, instanceID)
+	return true
+}
+
+// sendInstanceOfflineNotification 发送实例离线TG通知，测试中可替换
+var sendInstanceOfflineNotification = func(db *gorm.DB, m *InstanceMetadata) {
+	err := tg.NotifyInstanceStatus(
+		db,
+		false, // isOnline
+		m.UserID,
+		m.AccountID,
+		m.InstanceID,
+		m.IPv4,
+		m.InstanceType,
+		m.Region,
+	)
+	if err != nil {
+		log.Printf("发送实例离线TG通知失败: %v", err)
 	}
 }
 
@@ -286,6 +560,15 @@ func (pool *Pool) GetAllInstances() []*InstanceMetadata {
 	return instances
 }
 
+// HasInstance 判断实例是否已通过WebSocket上报
+func (pool *Pool) HasInstance(instanceID string) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	_, exists := pool.Instances[instanceID]
+	return exists
+}
+
 // GetInstancesByUserID 获取指定用户ID的所有在线实例
 func (pool *Pool) GetInstancesByUserID(userID string) []*InstanceMetadata {
 	pool.mu.RLock()
@@ -0,0 +1,176 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"portal/model"
+
+	"gorm.io/gorm"
+)
+
+func TestMarkInstanceOfflineDetectsAsynchronously(t *testing.T) {
+	detected := make(chan string, 1)
+	release := make(chan struct{})
+	oldDetect := detectOfflineUser
+	detectOfflineUser = func(userID string) *DetectResult {
+		detected <- userID
+		<-release
+		return nil
+	}
+	t.Cleanup(func() { detectOfflineUser = oldDetect })
+
+	p := NewPool()
+	t.Cleanup(p.waitOffline)
+	defer close(release)
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-debug", UserID: "u1", Region: "ap-east-1"})
+	if !p.HasInstance("i-debug") {
+		t.Fatal("注入的实例应在线")
+	}
+
+	// 检测被阻塞时 MarkInstanceOffline 仍应立即返回
+	returned := make(chan bool, 1)
+	go func() {
+		_, ok := p.MarkInstanceOffline("i-debug")
+		returned <- ok
+	}()
+	select {
+	case ok := <-returned:
+		if !ok {
+			t.Fatal("在线实例应能被标记离线")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("MarkInstanceOffline 等待补机检测完成，未异步执行")
+	}
+
+	if p.HasInstance("i-debug") {
+		t.Fatal("标记离线后实例应从池中移除")
+	}
+	if _, ok := p.MarkInstanceOffline("i-debug"); ok {
+		t.Fatal("已离线的实例不应再次标记成功")
+	}
+
+	select {
+	case userID := <-detected:
+		if userID != "u1" {
+			t.Fatalf("检测的用户应为 u1，实际为 %s", userID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("标记离线后未触发补机检测")
+	}
+}
+
+func TestIPLocksListAndClear(t *testing.T) {
+	p := NewPool()
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-lock", UserID: "u1", Region: "ap-east-1", IPv4: "198.51.100.1"})
+
+	p.LockInstanceIP("i-lock", "198.51.100.2", time.Minute)
+	p.LockInstanceIP("i-other", "198.51.100.3", 2*time.Minute)
+
+	locks := p.GetIPLocks()
+	if len(locks) != 2 || locks[0].InstanceID != "i-lock" || locks[0].IP != "198.51.100.2" {
+		t.Fatalf("IP锁定列表 = %+v, 期望按过期时间排序的两个锁定", locks)
+	}
+
+	// 锁定期间上报的旧IP被锁定的IP覆盖
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-lock", UserID: "u1", Region: "ap-east-1", IPv4: "198.51.100.1"})
+	if inst := p.GetInstancesByUserID("u1"); len(inst) != 1 || inst[0].IPv4 != "198.51.100.2" {
+		t.Fatalf("锁定期间实例IP = %+v, 期望保持锁定的IP", inst)
+	}
+
+	if !p.ClearIPLock("i-lock") || p.ClearIPLock("i-lock") {
+		t.Fatal("清除存在的锁定应返回true，重复清除应返回false")
+	}
+	if locks := p.GetIPLocks(); len(locks) != 1 || locks[0].InstanceID != "i-other" {
+		t.Fatalf("清除后的IP锁定列表 = %+v", locks)
+	}
+
+	// 清除后上报的IP生效
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-lock", UserID: "u1", Region: "ap-east-1", IPv4: "198.51.100.1"})
+	if inst := p.GetInstancesByUserID("u1"); inst[0].IPv4 != "198.51.100.1" {
+		t.Fatalf("清除锁定后实例IP = %s, 期望上报的IP", inst[0].IPv4)
+	}
+
+	if n := p.ClearAllIPLocks(); n != 1 || len(p.GetIPLocks()) != 0 {
+		t.Fatalf("清除全部锁定数量 = %d, 剩余 %v", n, p.GetIPLocks())
+	}
+}
+
+func TestOfflineNotificationSuppressedWithinDelay(t *testing.T) {
+	const userID = "notify-delay-user"
+	if err := globalDB.Create(&model.Monitor{UserID: userID, OfflineNotifyDelay: 1}).Error; err != nil {
+		t.Fatalf("写入监控配置失败: %v", err)
+	}
+
+	sent := make(chan string, 4)
+	oldSend := sendInstanceOfflineNotification
+	sendInstanceOfflineNotification = func(db *gorm.DB, m *InstanceMetadata) {
+		sent <- m.InstanceID
+	}
+	t.Cleanup(func() { sendInstanceOfflineNotification = oldSend })
+
+	p := NewPool()
+	t.Cleanup(p.waitOffline)
+	flapping := &InstanceMetadata{InstanceID: "i-flap", UserID: userID}
+	down := &InstanceMetadata{InstanceID: "i-down", UserID: userID}
+	p.notifyInstanceOffline(flapping)
+	p.notifyInstanceOffline(down)
+
+	// 离线时间短于延迟时间，重新上报后不应发送离线通知
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-flap", UserID: userID})
+
+	select {
+	case id := <-sent:
+		if id != "i-down" {
+			t.Fatalf("发送了实例[%s]的离线通知, 期望只通知持续离线的 i-down", id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("持续离线的实例在延迟结束后未发送通知")
+	}
+	select {
+	case id := <-sent:
+		t.Fatalf("延迟期内恢复的实例[%s]不应发送离线通知", id)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestBatchedReportUpdatesAllInstances(t *testing.T) {
+	p := NewPool()
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-batch-1", UserID: "batch-user", Region: "ap-east-1", IPv4: "198.51.100.10"})
+
+	message := []byte(` [
+		{"instance_id":"i-batch-1","user_id":"batch-user","region":"ap-east-1","ipv4":"198.51.100.11"},
+		{"instance_id":"i-batch-2","user_id":"batch-user","region":"ap-east-1","ipv4":"198.51.100.12"},
+		null,
+		{"instance_id":"i-batch-3","user_id":"batch-user","region":"ap-east-1","ipv4":"198.51.100.13"}
+	]`)
+	metadatas, err := parseInstanceReport(message)
+	if err != nil {
+		t.Fatalf("解析批量上报失败: %v", err)
+	}
+	if len(metadatas) != 3 {
+		t.Fatalf("解析出%d个实例, want 3", len(metadatas))
+	}
+	p.UpdateInstances(metadatas)
+
+	instances := p.GetInstancesByUserID("batch-user")
+	if len(instances) != 3 {
+		t.Fatalf("批量上报后实例数 = %d, want 3", len(instances))
+	}
+	for _, inst := range instances {
+		want := map[string]string{
+			"i-batch-1": "198.51.100.11",
+			"i-batch-2": "198.51.100.12",
+			"i-batch-3": "198.51.100.13",
+		}[inst.InstanceID]
+		if inst.IPv4 != want || inst.LastSeen.IsZero() {
+			t.Errorf("实例%s: IP=%s LastSeen=%v, want IP=%s 且已更新上报时间", inst.InstanceID, inst.IPv4, inst.LastSeen, want)
+		}
+	}
+
+	// 单个实例对象的上报格式仍然支持
+	single, err := parseInstanceReport([]byte(`{"instance_id":"i-batch-4","user_id":"batch-user"}`))
+	if err != nil || len(single) != 1 || single[0].InstanceID != "i-batch-4" {
+		t.Fatalf("解析单个实例上报 = %+v, err=%v", single, err)
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
 	"portal/repository"
 	"portal/service/instance"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,20 +27,35 @@ type MakeupRecord struct {
 	Region    string    // 区域代码
 }
 
+// makeupKey 补机记录索引键，按用户和区域分组
+type makeupKey struct {
+	UserID string
+	Region string
+}
+
 // MakeupHistory 补机历史记录管理器
 type MakeupHistory struct {
-	records map[string][]*MakeupRecord // key为UserID，value为该用户的补机记录列表
-	mu      sync.RWMutex               // 读写锁
+	records map[makeupKey][]*MakeupRecord // key为用户和区域，value为该用户在该区域的补机记录列表
+	mu      sync.RWMutex                  // 读写锁
 }
 
+// WebSocket缓冲区和消息大小的默认值
+const (
+	defaultWSBufferSize     = 1024
+	defaultWSMaxMessageSize = 512 * 1024
+)
+
 var (
-	upgrader = websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
-		CheckOrigin: func(r *http.Request) bool {
-			return true // 允许所有来源的连接,生产环境需要更严格的检查
-		},
-	}
+	// WebSocket升级器，缓冲区大小在首次使用时从环境变量读取
+	upgrader     websocket.Upgrader
+	upgraderOnce sync.Once
+
+	// 单条消息的最大字节数，超过后关闭连接
+	wsMaxMessageSize int64
+
+	// 允许的WebSocket来源列表，从环境变量 WS_ALLOWED_ORIGINS 读取（逗号分隔）
+	allowedOrigins     map[string]bool
+	allowedOriginsOnce sync.Once
 
 	// 全局变量
 	GlobalPool          *Pool
@@ -46,19 +65,62 @@ var (
 	globalDB            *gorm.DB
 )
 
+// loadAllowedOrigins 解析 WS_ALLOWED_ORIGINS 环境变量
+func loadAllowedOrigins() {
+	allowedOrigins = make(map[string]bool)
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		origin = strings.TrimRight(strings.ToLower(strings.TrimSpace(origin)), "/")
+		if origin != "" {
+			allowedOrigins[origin] = true
+		}
+	}
+}
+
+// checkOrigin 校验WebSocket连接来源
+// 实例开机脚本等非浏览器客户端不会携带Origin头，直接放行；
+// 浏览器来源需要在允许列表中，未配置列表时只允许同源连接
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	allowedOriginsOnce.Do(loadAllowedOrigins)
+
+	normalized := strings.TrimRight(strings.ToLower(origin), "/")
+	if allowedOrigins["*"] || allowedOrigins[normalized] {
+		return true
+	}
+
+	// 未配置允许列表时退回到同源检查
+	if len(allowedOrigins) == 0 {
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+	}
+
+	log.Printf("拒绝来自 %s 的WebSocket连接: 来源不在允许列表中", origin)
+	return false
+}
+
 // InitPool 初始化WebSocket服务
 func InitPool() {
 	// 从 repository 获取数据库连接
 	globalDB = repository.GetDB()
 	GlobalPool = NewPool()
 	GlobalMakeupHistory = &MakeupHistory{
-		records: make(map[string][]*MakeupRecord),
+		records: make(map[makeupKey][]*MakeupRecord),
 	}
 	GlobalDetector = NewDetector(globalDB, GlobalMakeupHistory)
 
 	// 向事件管理器注册IP变更事件监听器
 	GetEventManager().RegisterIPChangeListener(GlobalPool)
 
+	// 配置了账号异常通知时注册监听器
+	if notifier := newAccountFailureNotifier(); notifier != nil {
+		GetEventManager().RegisterAccountFailureListener(notifier)
+	}
+
 	// 初始化账号池
 	accountPool := GetAccountPool() // 确保账号池被初始化
 	// 从数据库加载账号
@@ -85,6 +147,11 @@ func InitPool() {
 		defer ticker.Stop()
 
 		for range ticker.C {
+			// 启动宽限期内实例还没有全部重连，避免误判需要补机
+			if GlobalPool.InStartupGrace() {
+				log.Printf("启动宽限期内，跳过本轮主动检测")
+				continue
+			}
 			results := GlobalDetector.DetectAllUsers()
 			for _, result := range results {
 				log.Printf("主动检测: 用户[%s]需要补机%d台", result.UserID, result.Count)
@@ -151,28 +218,61 @@ func TriggerAllIPRangeCheck(ctx context.Context) error {
 	return nil
 }
 
-// GetAllRecords 获取所有补机历史记录
-func (mh *MakeupHistory) GetAllRecords() map[string][]*MakeupRecord {
+// GetAllRecords 获取所有补机历史记录，每条记录自带用户ID和区域
+func (mh *MakeupHistory) GetAllRecords() []MakeupRecord {
 	mh.mu.RLock()
 	defer mh.mu.RUnlock()
 
-	// 创建一个新的map来存储记录的副本
-	records := make(map[string][]*MakeupRecord)
-	for userID, userRecords := range mh.records {
-		records[userID] = append([]*MakeupRecord{}, userRecords...)
+	// 返回记录的副本，避免调用方修改内部数据
+	var records []MakeupRecord
+	for _, keyRecords := range mh.records {
+		for _, record := range keyRecords {
+			records = append(records, *record)
+		}
 	}
 
 	return records
 }
 
+// getEnvBytes 读取表示字节数的正整数环境变量，无效时使用默认值
+func getEnvBytes(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("%s配置无效: %s，使用默认值%d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// getUpgrader 获取WebSocket升级器
+// 读写缓冲区通过 WS_READ_BUFFER_SIZE、WS_WRITE_BUFFER_SIZE 配置，单条消息上限通过 WS_MAX_MESSAGE_SIZE 配置（字节）
+func getUpgrader() *websocket.Upgrader {
+	upgraderOnce.Do(func() {
+		upgrader = websocket.Upgrader{
+			ReadBufferSize:  getEnvBytes("WS_READ_BUFFER_SIZE", defaultWSBufferSize),
+			WriteBufferSize: getEnvBytes("WS_WRITE_BUFFER_SIZE", defaultWSBufferSize),
+			CheckOrigin:     checkOrigin,
+		}
+		wsMaxMessageSize = int64(getEnvBytes("WS_MAX_MESSAGE_SIZE", defaultWSMaxMessageSize))
+	})
+	return &upgrader
+}
+
 // HandleWebSocket 处理新的WebSocket连接
 func HandleWebSocket(c *gin.Context) {
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := getUpgrader().Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("WebSocket升级失败:", err)
 		return
 	}
 
+	// 限制单条消息大小，超出时读取会失败并关闭连接
+	conn.SetReadLimit(wsMaxMessageSize)
+
 	client := &Client{
 		Conn: conn,
 		Pool: GlobalPool,
@@ -184,13 +284,25 @@ func HandleWebSocket(c *gin.Context) {
 	go client.ReadMessages()
 }
 
+// ClearRegionRecords 清空指定区域的补机历史记录
+func (h *MakeupHistory) ClearRegionRecords(region string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key := range h.records {
+		if key.Region == region {
+			delete(h.records, key)
+		}
+	}
+}
+
 // ClearAllRecords 清空所有补机历史记录
 func (h *MakeupHistory) ClearAllRecords() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	// 清空记录，注意使用正确的类型
-	h.records = make(map[string][]*MakeupRecord)
+	h.records = make(map[makeupKey][]*MakeupRecord)
 }
 
 // GetMakeupCountForRegion 获取指定用户在指定区域和时间段内的补机总数
@@ -198,8 +310,7 @@ func (mh *MakeupHistory) GetMakeupCountForRegion(userID string, region string, d
 	mh.mu.RLock()
 	defer mh.mu.RUnlock()
 
-	key := userID + ":" + region
-	records := mh.records[key]
+	records := mh.records[makeupKey{UserID: userID, Region: region}]
 	if len(records) == 0 {
 		return 0
 	}
@@ -226,8 +337,7 @@ func (mh *MakeupHistory) AddMakeupRecordWithRegion(userID string, count int, reg
 	mh.mu.Lock()
 	defer mh.mu.Unlock()
 
-	// 组合键
-	key := userID + ":" + region
+	key := makeupKey{UserID: userID, Region: region}
 
 	// 检查是否在短时间内（例如1秒）有相同的记录
 	records := mh.records[key]
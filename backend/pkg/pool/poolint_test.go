@@ -0,0 +1,152 @@
+package pool
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// setAllowedOrigins 设置WebSocket允许的来源列表，测试结束后恢复
+func setAllowedOrigins(t *testing.T, origins string) {
+	t.Helper()
+	t.Setenv("WS_ALLOWED_ORIGINS", origins)
+	allowedOriginsOnce = sync.Once{}
+	t.Cleanup(func() { allowedOriginsOnce = sync.Once{} })
+}
+
+func TestCheckOrigin(t *testing.T) {
+	setAllowedOrigins(t, "https://panel.example.com/, https://admin.example.com")
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{"允许的来源", "https://panel.example.com", true},
+		{"大小写和末尾斜杠不影响匹配", "HTTPS://Admin.Example.com/", true},
+		{"不在列表中的来源", "https://evil.example.com", false},
+		{"没有Origin的实例客户端", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://api.example.com/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := checkOrigin(r); got != tt.want {
+				t.Fatalf("checkOrigin(%q) = %v, 期望 %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOriginSameHostByDefault(t *testing.T) {
+	setAllowedOrigins(t, "")
+
+	r := httptest.NewRequest("GET", "http://api.example.com/ws", nil)
+	r.Header.Set("Origin", "https://api.example.com")
+	if !checkOrigin(r) {
+		t.Fatal("未配置允许列表时应允许同源连接")
+	}
+	r.Header.Set("Origin", "https://other.example.com")
+	if checkOrigin(r) {
+		t.Fatal("未配置允许列表时应拒绝跨域连接")
+	}
+}
+
+// setWSMaxMessageSize 设置WebSocket单条消息上限，测试结束后恢复
+func setWSMaxMessageSize(t *testing.T, size string) {
+	t.Helper()
+	t.Setenv("WS_MAX_MESSAGE_SIZE", size)
+	upgraderOnce = sync.Once{}
+	t.Cleanup(func() { upgraderOnce = sync.Once{} })
+}
+
+// usePool 将全局连接池替换为新的连接池
+func usePool(t *testing.T) *Pool {
+	t.Helper()
+	old := GlobalPool
+	GlobalPool = NewPool()
+	t.Cleanup(func() { GlobalPool = old })
+	return GlobalPool
+}
+
+func TestOversizedMessageClosesConnection(t *testing.T) {
+	setAllowedOrigins(t, "")
+	setWSMaxMessageSize(t, "64")
+	pool := usePool(t)
+	go pool.Start()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ws", HandleWebSocket)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("连接WebSocket失败: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.TextMessage, bytes.Repeat([]byte("a"), 1024)); err != nil {
+		t.Fatalf("发送消息失败: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("超过大小限制的消息应关闭连接并返回1009, 实际错误: %v", err)
+	}
+}
+
+func TestMakeupHistoryRegions(t *testing.T) {
+	h := &MakeupHistory{records: make(map[makeupKey][]*MakeupRecord)}
+	// 用户ID中包含旧组合键使用的分隔符，也不能与区域混淆
+	h.AddMakeupRecordWithRegion("user_ap-east-1", 2, "ap-northeast-3")
+	h.AddMakeupRecordWithRegion("user", 3, "ap-east-1")
+	h.AddMakeupRecordWithRegion("user", 4, "ap-northeast-3")
+	h.AddMakeupRecordWithRegion("user", 5, "ap-southeast-1")
+
+	counts := []struct {
+		userID, region string
+		want           int
+	}{
+		{"user", "ap-east-1", 3},
+		{"user", "ap-northeast-3", 4},
+		{"user", "ap-southeast-1", 5},
+		{"user_ap-east-1", "ap-northeast-3", 2},
+		{"user_ap-east-1", "ap-east-1", 0},
+	}
+	for _, c := range counts {
+		if got := h.GetMakeupCountForRegion(c.userID, c.region, time.Hour); got != c.want {
+			t.Errorf("用户[%s]区域[%s]补机数 = %d, want %d", c.userID, c.region, got, c.want)
+		}
+	}
+
+	byKey := make(map[makeupKey]int)
+	for _, record := range h.GetAllRecords() {
+		byKey[makeupKey{UserID: record.UserID, Region: record.Region}] += record.Count
+	}
+	if len(byKey) != 4 || byKey[makeupKey{"user_ap-east-1", "ap-northeast-3"}] != 2 {
+		t.Fatalf("全部记录按用户和区域归类 = %v", byKey)
+	}
+
+
+	h.ClearRegionRecords("ap-northeast-3")
+	if got := h.GetMakeupCountForRegion("user", "ap-northeast-3", time.Hour); got != 0 {
+		t.Fatalf("清空日本区后补机数 = %d, want 0", got)
+	}
+	if got := h.GetMakeupCountForRegion("user_ap-east-1", "ap-northeast-3", time.Hour); got != 0 {
+		t.Fatalf("清空日本区后其他用户的补机数 = %d, want 0", got)
+	}
+	if got := h.GetMakeupCountForRegion("user", "ap-east-1", time.Hour); got != 3 {
+		t.Fatalf("清空日本区不应影响香港区, 补机数 = %d, want 3", got)
+	}
+}
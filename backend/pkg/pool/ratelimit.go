@@ -0,0 +1,102 @@
+// pkg/pool/ratelimit.go
+package pool
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 默认IP范围检测器更换IP的速率：每分钟30次，允许突发5次
+const (
+	defaultChangeIPRatePerMinute = 30
+	defaultChangeIPBurst         = 5
+)
+
+// tokenBucket 令牌桶限流器，按固定速率补充令牌，桶满后不再累积
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 桶容量
+	tokens float64 // 当前令牌数
+	last   time.Time
+}
+
+// newTokenBucket 创建令牌桶，ratePerSecond为每秒补充的令牌数，burst为桶容量
+func newTokenBucket(ratePerSecond float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   ratePerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait 阻塞直到取得一个令牌或ctx被取消
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+
+		// 计算补足一个令牌所需的时间
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+var (
+	changeIPLimiter     *tokenBucket
+	changeIPLimiterOnce sync.Once
+)
+
+// getChangeIPLimiter 获取IP范围检测器更换IP的全局限流器，所有用户共享
+// 速率通过环境变量 IPRANGE_CHANGEIP_RATE 配置（每分钟次数），突发量通过 IPRANGE_CHANGEIP_BURST 配置
+func getChangeIPLimiter() *tokenBucket {
+	changeIPLimiterOnce.Do(func() {
+		ratePerMinute := defaultChangeIPRatePerMinute
+		if v := os.Getenv("IPRANGE_CHANGEIP_RATE"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				ratePerMinute = n
+			} else {
+				log.Printf("IPRANGE_CHANGEIP_RATE配置无效[%s]，使用默认值%d", v, defaultChangeIPRatePerMinute)
+			}
+		}
+
+		burst := defaultChangeIPBurst
+		if v := os.Getenv("IPRANGE_CHANGEIP_BURST"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				burst = n
+			} else {
+				log.Printf("IPRANGE_CHANGEIP_BURST配置无效[%s]，使用默认值%d", v, defaultChangeIPBurst)
+			}
+		}
+
+		changeIPLimiter = newTokenBucket(float64(ratePerMinute)/60, burst)
+	})
+	return changeIPLimiter
+}
@@ -0,0 +1,73 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketStaysUnderRate(t *testing.T) {
+	const (
+		ratePerSecond = 20
+		burst         = 3
+		window        = 500 * time.Millisecond
+	)
+	bucket := newTokenBucket(ratePerSecond, burst)
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+
+	// 多个检测协程同时争抢令牌
+	start := time.Now()
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for bucket.Wait(ctx) == nil {
+				calls.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start).Seconds()
+	limit := int32(burst + ratePerSecond*elapsed + 1)
+	if got := calls.Load(); got > limit {
+		t.Fatalf("%.2fs内取得%d个令牌, 超过限制%d", elapsed, got, limit)
+	}
+	if got := calls.Load(); got < burst {
+		t.Fatalf("取得%d个令牌, 期望至少可以突发%d次", got, burst)
+	}
+}
+
+func TestTokenBucketWaitHonorsContext(t *testing.T) {
+	bucket := newTokenBucket(0.01, 1)
+	if err := bucket.Wait(context.Background()); err != nil {
+		t.Fatalf("桶内有令牌时不应等待: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := bucket.Wait(ctx); err == nil {
+		t.Fatal("令牌耗尽且ctx超时后应返回错误")
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Fatalf("ctx取消后仍等待了%v", waited)
+	}
+}
+
+func TestGetChangeIPLimiterConfig(t *testing.T) {
+	t.Setenv("IPRANGE_CHANGEIP_RATE", "120")
+	t.Setenv("IPRANGE_CHANGEIP_BURST", "2")
+	changeIPLimiterOnce = sync.Once{}
+	t.Cleanup(func() { changeIPLimiterOnce = sync.Once{} })
+
+	limiter := getChangeIPLimiter()
+	if limiter.rate != 2 || limiter.burst != 2 {
+		t.Fatalf("限流器速率 = %v/秒 容量 = %v, want 2/秒 容量2", limiter.rate, limiter.burst)
+	}
+}
@@ -0,0 +1,91 @@
+// pkg/pool/reconcile.go
+package pool
+
+import (
+	"sort"
+	"time"
+
+	"portal/pkg/aws"
+)
+
+// 实例上报状态
+const (
+	ReportOnline        = "online"         // AWS上存在且正在上报
+	ReportOffline       = "offline"        // AWS上存在，最近离线、仍在保留期内
+	ReportNeverReported = "never_reported" // AWS上存在但从未上报或已超过离线保留期
+	ReportMissingInAWS  = "missing_in_aws" // 正在上报但AWS上已查不到，可能已被删除
+)
+
+// ReconciledInstance 合并AWS实时列表和连接池上报状态后的实例信息
+type ReconciledInstance struct {
+	InstanceID   string     `json:"instance_id"`
+	InstanceType string     `json:"instance_type"`
+	State        string     `json:"state"`                 // AWS中的实例状态，AWS上不存在时为空
+	PublicIP     string     `json:"public_ip"`             // AWS中的公网IP
+	LaunchTime   *time.Time `json:"launch_time,omitempty"` // 启动时间
+	Source       string     `json:"source,omitempty"`      // 实例来源：manual/makeup
+	QueueID      string     `json:"queue_id,omitempty"`    // 创建该实例的补机任务ID
+	ReportStatus string     `json:"report_status"`         // 上报状态
+	UserID       string     `json:"user_id,omitempty"`     // 上报的用户ID
+	ReportedIP   string     `json:"reported_ip,omitempty"` // 上报的IP
+	LastSeen     *time.Time `json:"last_seen,omitempty"`   // 最后一次上报时间
+}
+
+// ReconcileAccountInstances 将账号在AWS上的实例列表与连接池的上报状态合并
+// 连接池中属于该账号、但AWS列表中没有的实例标记为 missing_in_aws
+func (pool *Pool) ReconcileAccountInstances(accountID string, live []aws.InstanceInfo) []ReconciledInstance {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	results := make([]ReconciledInstance, 0, len(live))
+	seen := make(map[string]bool, len(live))
+	for _, info := range live {
+		seen[info.InstanceID] = true
+		launchTime := info.LaunchTime
+		item := ReconciledInstance{
+			InstanceID:   info.InstanceID,
+			InstanceType: info.InstanceType,
+			State:        info.State,
+			PublicIP:     info.PublicIP,
+			LaunchTime:   &launchTime,
+			Source:       info.Source,
+			QueueID:      info.QueueID,
+			ReportStatus: ReportNeverReported,
+		}
+
+		if metadata, exists := pool.Instances[info.InstanceID]; exists {
+			item.ReportStatus = ReportOnline
+			item.applyReport(metadata)
+		} else if record, exists := pool.recentlyOffline[info.InstanceID]; exists {
+			item.ReportStatus = ReportOffline
+			item.applyReport(record.InstanceMetadata)
+		}
+		results = append(results, item)
+	}
+
+	for instanceID, metadata := range pool.Instances {
+		if metadata.AccountID != accountID || seen[instanceID] {
+			continue
+		}
+		item := ReconciledInstance{
+			InstanceID:   instanceID,
+			InstanceType: metadata.InstanceType,
+			ReportStatus: ReportMissingInAWS,
+		}
+		item.applyReport(metadata)
+		results = append(results, item)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].InstanceID < results[j].InstanceID
+	})
+	return results
+}
+
+// applyReport 填充实例的上报信息
+func (r *ReconciledInstance) applyReport(metadata *InstanceMetadata) {
+	lastSeen := metadata.LastSeen
+	r.UserID = metadata.UserID
+	r.ReportedIP = metadata.IPv4
+	r.LastSeen = &lastSeen
+}
@@ -0,0 +1,60 @@
+package pool
+
+import (
+	"testing"
+	"time"
+
+	"portal/pkg/aws"
+)
+
+func TestReconcileAccountInstances(t *testing.T) {
+	p := NewPool()
+	now := time.Now()
+	p.Instances["i-online"] = &InstanceMetadata{InstanceID: "i-online", AccountID: "acc-1", UserID: "u-1", IPv4: "1.1.1.1", LastSeen: now}
+	p.Instances["i-gone"] = &InstanceMetadata{InstanceID: "i-gone", AccountID: "acc-1", UserID: "u-1", InstanceType: "c5n.large", IPv4: "2.2.2.2", LastSeen: now}
+	p.Instances["i-other"] = &InstanceMetadata{InstanceID: "i-other", AccountID: "acc-2", UserID: "u-2", LastSeen: now}
+	p.recentlyOffline["i-offline"] = &OfflineInstance{
+		InstanceMetadata: &InstanceMetadata{InstanceID: "i-offline", AccountID: "acc-1", UserID: "u-1", IPv4: "3.3.3.3", LastSeen: now},
+		State:            "offline",
+		OfflineAt:        now,
+	}
+
+	live := []aws.InstanceInfo{
+		{InstanceID: "i-online", InstanceType: "c5n.large", State: "running", PublicIP: "1.1.1.1", Source: "makeup"},
+		{InstanceID: "i-offline", InstanceType: "c5n.large", State: "running", PublicIP: "3.3.3.3"},
+		{InstanceID: "i-silent", InstanceType: "c5n.large", State: "pending"},
+	}
+	results := p.ReconcileAccountInstances("acc-1", live)
+
+	want := map[string]string{
+		"i-gone":    ReportMissingInAWS,
+		"i-offline": ReportOffline,
+		"i-online":  ReportOnline,
+		"i-silent":  ReportNeverReported,
+	}
+	if len(results) != len(want) {
+		t.Fatalf("合并结果 = %+v, 期望%d台实例", results, len(want))
+	}
+	for i, result := range results {
+		if i > 0 && results[i-1].InstanceID >= result.InstanceID {
+			t.Fatalf("合并结果应按实例ID排序: %+v", results)
+		}
+		if result.ReportStatus != want[result.InstanceID] {
+			t.Errorf("实例[%s]上报状态 = %s, want %s", result.InstanceID, result.ReportStatus, want[result.InstanceID])
+		}
+		switch result.InstanceID {
+		case "i-online":
+			if result.Source != "makeup" || result.State != "running" || result.UserID != "u-1" || result.LastSeen == nil {
+				t.Errorf("在线实例应同时带有AWS信息和上报信息: %+v", result)
+			}
+		case "i-gone":
+			if result.State != "" || result.ReportedIP != "2.2.2.2" || result.InstanceType != "c5n.large" {
+				t.Errorf("AWS上不存在的实例应只有上报信息: %+v", result)
+			}
+		case "i-silent":
+			if result.UserID != "" || result.LastSeen != nil {
+				t.Errorf("从未上报的实例不应有上报信息: %+v", result)
+			}
+		}
+	}
+}
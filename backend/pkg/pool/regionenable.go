@@ -0,0 +1,90 @@
+// pkg/pool/regionenable.go
+package pool
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"portal/pkg/aws"
+)
+
+// 开通区域后复查状态的间隔，依次递增，超过次数后交由每小时的重置任务兜底
+var regionEnableCheckDelays = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	20 * time.Minute,
+	40 * time.Minute,
+}
+
+// 开通状态查询和重新开通的超时时间
+const regionEnableCallTimeout = 30 * time.Second
+
+var (
+	regionEnableMu      sync.Mutex
+	regionEnablePending = make(map[string]bool) // 账号ID -> 是否已有复查在进行
+)
+
+// enableRegionAndWatch 为账号开通区域并按退避间隔复查开通状态
+// 区域变为启用后重置账号的跳过状态，使其重新参与补机；同一账号同时只有一个复查流程
+func enableRegionAndWatch(awsClient *aws.AWSClient, accountID string, regionCode string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), regionEnableCallTimeout)
+	err := awsClient.EnableRegion(ctx, regionCode)
+	cancel()
+
+	regionEnableMu.Lock()
+	if regionEnablePending[accountID] {
+		regionEnableMu.Unlock()
+		return err
+	}
+	regionEnablePending[accountID] = true
+	regionEnableMu.Unlock()
+
+	go watchRegionEnable(awsClient, accountID, regionCode)
+	return err
+}
+
+// watchRegionEnable 按退避间隔查询区域开通状态，直到启用、账号不再需要等待或达到最大次数
+func watchRegionEnable(awsClient *aws.AWSClient, accountID string, regionCode string) {
+	defer func() {
+		regionEnableMu.Lock()
+		delete(regionEnablePending, accountID)
+		regionEnableMu.Unlock()
+	}()
+
+	for attempt, delay := range regionEnableCheckDelays {
+		time.Sleep(delay)
+
+		// 账号已被移除或已被其他途径重置时停止复查
+		account := GetAccountPool().GetAccount(accountID)
+		if account == nil || !account.IsSkipped {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), regionEnableCallTimeout)
+		status, err := awsClient.CheckRegionStatus(ctx, regionCode)
+		cancel()
+		switch {
+		case err != nil:
+			log.Printf("查询账号[%s]区域[%s]开通状态失败(第%d次): %v", accountID, regionCode, attempt+1, err)
+		case status == "启用":
+			log.Printf("账号[%s]区域[%s]已开通，重新加入补机轮换", accountID, regionCode)
+			GetAccountPool().ResetAccountStatus(accountID)
+			return
+		case status == "未启用":
+			// 之前的开通请求未生效，重新提交，重复提交是安全的
+			ctx, cancel := context.WithTimeout(context.Background(), regionEnableCallTimeout)
+			if enableErr := awsClient.EnableRegion(ctx, regionCode); enableErr != nil {
+				log.Printf("重新为账号[%s]开通区域[%s]失败: %v", accountID, regionCode, enableErr)
+			}
+			cancel()
+		default:
+			log.Printf("账号[%s]区域[%s]开通状态: %s(第%d次)", accountID, regionCode, status, attempt+1)
+		}
+	}
+
+	log.Printf("账号[%s]区域[%s]多次复查仍未开通，等待定时重置任务处理", accountID, regionCode)
+}
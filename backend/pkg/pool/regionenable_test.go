@@ -0,0 +1,74 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"portal/model"
+	"portal/pkg/aws"
+	"portal/pkg/region"
+)
+
+func TestEnableRegionRestoresAccountOnceEnabled(t *testing.T) {
+	// 第一次复查时仍在开通中，之后变为已开通
+	var enableCalls, statusCalls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/enableRegion":
+			enableCalls.Add(1)
+			fmt.Fprint(w, `{}`)
+		case "/getRegionOptStatus":
+			var input struct{ RegionName string }
+			_ = json.NewDecoder(r.Body).Decode(&input)
+			status := "ENABLING"
+			if statusCalls.Add(1) > 1 {
+				status = "ENABLED"
+			}
+			fmt.Fprintf(w, `{"RegionName":"%s","RegionOptStatus":"%s"}`, input.RegionName, status)
+		default:
+			http.Error(w, "unexpected path "+r.URL.Path, http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+
+	oldDelays := regionEnableCheckDelays
+	regionEnableCheckDelays = []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond}
+	t.Cleanup(func() { regionEnableCheckDelays = oldDelays })
+
+	hk := region.HK
+	accountPool := GetAccountPool()
+	accountPool.AddAccount(model.Account{ID: "9501", UserID: "region-enable-user", Key1: "AKIAENABLE", Key2: "secret", Region: &hk})
+	t.Cleanup(func() { accountPool.RemoveAccount("9501") })
+	accountPool.MarkAccountFailed("9501", "香港区域未开通")
+
+	if err := enableRegionAndWatch(aws.NewAWSClient("AKIAENABLE", "secret"), "9501", hk); err != nil {
+		t.Fatalf("开通区域失败: %v", err)
+	}
+
+	// 复查协程会修改账号状态，读取时需持有账号池的锁
+	skipped := func() bool {
+		accountPool.mutex.RLock()
+		defer accountPool.mutex.RUnlock()
+		return accountPool.accounts["9501"].IsSkipped
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for skipped() {
+		if time.Now().After(deadline) {
+			t.Fatalf("区域开通后账号应恢复可用, 查询状态%d次", statusCalls.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := statusCalls.Load(); got != 2 {
+		t.Fatalf("查询开通状态%d次, 期望开通中和已开通各1次", got)
+	}
+	if got := enableCalls.Load(); got != 1 {
+		t.Fatalf("开通区域%d次, 开通中时不应重复提交", got)
+	}
+}
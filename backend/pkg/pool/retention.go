@@ -0,0 +1,81 @@
+// pkg/pool/retention.go
+package pool
+
+import (
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OfflineInstance 最近离线、仍在保留期内的实例
+type OfflineInstance struct {
+	*InstanceMetadata
+	State     string    `json:"state"`      // 固定为offline
+	OfflineAt time.Time `json:"offline_at"` // 判定离线的时间
+}
+
+var (
+	offlineRetention     time.Duration
+	offlineRetentionOnce sync.Once
+)
+
+// getOfflineRetention 获取离线实例的保留时长，可通过 POOL_OFFLINE_RETENTION 配置，如"10m"，默认0表示离线后立即移除
+func getOfflineRetention() time.Duration {
+	offlineRetentionOnce.Do(func() {
+		if value := os.Getenv("POOL_OFFLINE_RETENTION"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+				offlineRetention = d
+			} else {
+				log.Printf("POOL_OFFLINE_RETENTION配置无效: %s，不保留离线实例", value)
+			}
+		}
+	})
+	return offlineRetention
+}
+
+// retainOfflineLocked 将离线实例移入最近离线列表，调用方需持有pool.mu写锁
+func (pool *Pool) retainOfflineLocked(metadata *InstanceMetadata, now time.Time) {
+	if getOfflineRetention() <= 0 {
+		return
+	}
+	pool.recentlyOffline[metadata.InstanceID] = &OfflineInstance{
+		InstanceMetadata: metadata,
+		State:            "offline",
+		OfflineAt:        now,
+	}
+}
+
+// purgeExpiredOffline 移除超过保留期的离线实例
+func (pool *Pool) purgeExpiredOffline(now time.Time) {
+	retention := getOfflineRetention()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for instanceID, record := range pool.recentlyOffline {
+		if now.Sub(record.OfflineAt) >= retention {
+			delete(pool.recentlyOffline, instanceID)
+		}
+	}
+}
+
+// GetRecentlyOfflineInstances 获取保留期内的离线实例，userID为空时返回所有用户的
+func (pool *Pool) GetRecentlyOfflineInstances(userID string) []*OfflineInstance {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	instances := make([]*OfflineInstance, 0)
+	for _, record := range pool.recentlyOffline {
+		if userID == "" || record.UserID == userID {
+			instances = append(instances, record)
+		}
+	}
+
+	// 按离线时间倒序
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].OfflineAt.After(instances[j].OfflineAt)
+	})
+	return instances
+}
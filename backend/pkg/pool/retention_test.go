@@ -0,0 +1,82 @@
+package pool
+
+import (
+	"testing"
+	"time"
+)
+
+// setOfflineRetention 设置离线实例保留时长，测试结束后恢复
+func setOfflineRetention(t *testing.T, d time.Duration) {
+	t.Helper()
+	offlineRetentionOnce.Do(func() {})
+	old := offlineRetention
+	offlineRetention = d
+	t.Cleanup(func() { offlineRetention = old })
+}
+
+// stubOfflineDetection 替换离线后的补机检测，测试结束后恢复，返回的函数等待检测被调用
+func stubOfflineDetection(t *testing.T) func() {
+	t.Helper()
+	detected := make(chan string, 10)
+	old := detectOfflineUser
+	detectOfflineUser = func(userID string) *DetectResult {
+		detected <- userID
+		return nil
+	}
+	t.Cleanup(func() { detectOfflineUser = old })
+
+	return func() {
+		t.Helper()
+		select {
+		case <-detected:
+		case <-time.After(time.Second):
+			t.Fatal("标记离线后未触发补机检测")
+		}
+	}
+}
+
+func TestOfflineInstanceRetainedThenPurged(t *testing.T) {
+	waitDetected := stubOfflineDetection(t)
+	setOfflineRetention(t, 10*time.Minute)
+
+	p := NewPool()
+	t.Cleanup(p.waitOffline)
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-1", UserID: "u1"})
+	if _, ok := p.MarkInstanceOffline("i-1"); !ok {
+		t.Fatal("在线实例应能被标记离线")
+	}
+	waitDetected()
+
+	offline := p.GetRecentlyOfflineInstances("u1")
+	if len(offline) != 1 || offline[0].InstanceID != "i-1" || offline[0].State != "offline" {
+		t.Fatalf("离线实例应在保留期内保留，实际为 %+v", offline)
+	}
+	if others := p.GetRecentlyOfflineInstances("u2"); len(others) != 0 {
+		t.Fatalf("其他用户不应看到该离线实例，实际为 %+v", others)
+	}
+
+	offlineAt := offline[0].OfflineAt
+	p.purgeExpiredOffline(offlineAt.Add(9 * time.Minute))
+	if len(p.GetRecentlyOfflineInstances("")) != 1 {
+		t.Fatal("保留期内的离线实例不应被清理")
+	}
+
+	p.purgeExpiredOffline(offlineAt.Add(10 * time.Minute))
+	if len(p.GetRecentlyOfflineInstances("")) != 0 {
+		t.Fatal("超过保留期的离线实例应被清理")
+	}
+}
+
+func TestOfflineInstanceNotRetainedByDefault(t *testing.T) {
+	waitDetected := stubOfflineDetection(t)
+	setOfflineRetention(t, 0)
+
+	p := NewPool()
+	t.Cleanup(p.waitOffline)
+	p.UpdateInstance(&InstanceMetadata{InstanceID: "i-1", UserID: "u1"})
+	p.MarkInstanceOffline("i-1")
+	waitDetected()
+	if offline := p.GetRecentlyOfflineInstances(""); len(offline) != 0 {
+		t.Fatalf("未配置保留期时离线实例应立即移除，实际为 %+v", offline)
+	}
+}
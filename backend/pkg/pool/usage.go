@@ -0,0 +1,97 @@
+// pkg/pool/usage.go
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"portal/pkg/aws"
+)
+
+// UsageRecomputeResult 重新统计账号使用计数的结果
+type UsageRecomputeResult struct {
+	AccountID     string         `json:"account_id"`     // 账号ID
+	Region        string         `json:"region"`         // 统计的区域
+	InstanceCount int            `json:"instance_count"` // AWS上运行中（含pending）的实例数量
+	Before        map[string]int `json:"before"`         // 重新统计前各实例族的使用计数
+	After         map[string]int `json:"after"`          // 重新统计后各实例族的使用计数
+}
+
+// listAccountInstances 查询账号在指定区域的实例，测试中可替换
+var listAccountInstances = func(ctx context.Context, key1, key2, regionCode, accountID string) ([]aws.InstanceInfo, error) {
+	return aws.NewAWSClient(key1, key2).ListInstances(ctx, aws.ListInstancesParams{
+		Region:    regionCode,
+		AccountID: accountID,
+	})
+}
+
+// usageFromInstances 按实例统计各实例族的使用计数，只统计 pending 和 running 状态的实例
+// 已停止或正在停止的实例不占用vCPU配额，不计入使用量，返回统计的实例数量
+func usageFromInstances(instances []aws.InstanceInfo) (map[string]int, int) {
+	usage := make(map[string]int)
+	counted := 0
+	for _, instance := range instances {
+		if instance.State != "pending" && instance.State != "running" {
+			continue
+		}
+		usage[instanceFamily(instance.InstanceType)] += getInstanceCountForType(instance.InstanceType)
+		counted++
+	}
+	return usage, counted
+}
+
+// RecomputeAccountUsage 按AWS上实际存在的实例重新统计账号在当前区域的使用计数
+// 用于实例被带外删除等导致计数偏离实际时修正，不影响账号的跳过状态
+func (p *AccountPool) RecomputeAccountUsage(ctx context.Context, accountID string) (*UsageRecomputeResult, error) {
+	p.mutex.RLock()
+	account, exists := p.accounts[accountID]
+	var key1, key2, regionCode string
+	if exists {
+		key1, key2, regionCode = account.Key1, account.Key2, account.RegionCode()
+	}
+	p.mutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("账号[%s]不在账号池中", accountID)
+	}
+
+	// 查询AWS时不持有锁，避免阻塞账号选择
+	instances, err := listAccountInstances(ctx, key1, key2, regionCode, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("查询账号[%s]实例失败: %v", accountID, err)
+	}
+
+	usage, instanceCount := usageFromInstances(instances)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	account, exists = p.accounts[accountID]
+	if !exists {
+		return nil, fmt.Errorf("账号[%s]已从账号池中移除", accountID)
+	}
+	if account.RegionCode() != regionCode {
+		return nil, fmt.Errorf("账号[%s]区域已变更为%s，请重试", accountID, account.RegionCode())
+	}
+
+	result := &UsageRecomputeResult{
+		AccountID:     accountID,
+		Region:        regionCode,
+		InstanceCount: instanceCount,
+		Before:        make(map[string]int, len(account.FamilyUsedCount)),
+		After:         make(map[string]int, len(usage)),
+	}
+	for family, count := range account.FamilyUsedCount {
+		result.Before[family] = count
+	}
+	for family, count := range usage {
+		result.After[family] = count
+	}
+
+	account.FamilyUsedCount = usage
+	log.Printf("账号池: 账号[%s]区域[%s]使用计数已按实际实例重新统计: %v -> %v",
+		accountID, regionCode, result.Before, result.After)
+
+	return result, nil
+}
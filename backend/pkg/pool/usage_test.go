@@ -0,0 +1,66 @@
+package pool
+
+import (
+	"context"
+	"testing"
+
+	"portal/pkg/aws"
+)
+
+// stubListInstances 替换账号实例查询，测试结束后恢复
+func stubListInstances(t *testing.T, instances []aws.InstanceInfo) {
+	t.Helper()
+	old := listAccountInstances
+	listAccountInstances = func(ctx context.Context, key1, key2, regionCode, accountID string) ([]aws.InstanceInfo, error) {
+		return instances, nil
+	}
+	t.Cleanup(func() { listAccountInstances = old })
+}
+
+func TestRecomputeAccountUsageCorrectsDrift(t *testing.T) {
+	stubListInstances(t, []aws.InstanceInfo{
+		{InstanceID: "i-1", InstanceType: "c5n.large", State: "running"},
+		{InstanceID: "i-2", InstanceType: "c5n.large", State: "pending"},
+		{InstanceID: "i-3", InstanceType: "c5n.large", State: "stopped"},
+		{InstanceID: "i-4", InstanceType: "t3.micro", State: "stopping"},
+		{InstanceID: "i-5", InstanceType: "t3.micro", State: "running"},
+	})
+
+	// 计数偏离实际：实例被带外删除后计数仍为4
+	p := &AccountPool{accounts: map[string]*AccountInfo{
+		"1": {ID: "1", FamilyUsedCount: map[string]int{"c5n": 4, "m5": 2}},
+	}}
+
+	result, err := p.RecomputeAccountUsage(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("重新统计失败: %v", err)
+	}
+
+	want := map[string]int{
+		"c5n": 2 * getInstanceCountForType("c5n.large"),
+		"t3":  getInstanceCountForType("t3.micro"),
+	}
+	if result.InstanceCount != 3 {
+		t.Errorf("应只统计pending和running的实例，实际统计 %d 台", result.InstanceCount)
+	}
+	if result.Before["c5n"] != 4 || result.Before["m5"] != 2 {
+		t.Errorf("重新统计前的计数错误: %v", result.Before)
+	}
+
+	used := p.accounts["1"].FamilyUsedCount
+	if len(used) != len(want) {
+		t.Fatalf("重新统计后的计数 = %v, 期望 %v", used, want)
+	}
+	for family, count := range want {
+		if used[family] != count || result.After[family] != count {
+			t.Errorf("实例族[%s]计数 = %d（结果中为%d）, 期望 %d", family, used[family], result.After[family], count)
+		}
+	}
+}
+
+func TestRecomputeAccountUsageUnknownAccount(t *testing.T) {
+	p := &AccountPool{accounts: map[string]*AccountInfo{}}
+	if _, err := p.RecomputeAccountUsage(context.Background(), "missing"); err == nil {
+		t.Fatal("账号不在账号池中时应返回错误")
+	}
+}
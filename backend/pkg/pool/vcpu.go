@@ -0,0 +1,75 @@
+// pkg/pool/vcpu.go
+package pool
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"portal/pkg/aws"
+)
+
+// 内置的实例类型vCPU数量，AWS查询失败或未启用时使用
+var staticInstanceVCPUs = map[string]int{
+	"c5n.large":   2,
+	"c5n.xlarge":  4,
+	"c5n.2xlarge": 8,
+	"c5n.4xlarge": 16,
+}
+
+var (
+	instanceVCPUs     sync.Map // 实例类型 -> vCPU数量，从AWS查询得到
+	instanceVCPUsOnce sync.Once
+)
+
+// isAWSVCPUSourceEnabled 是否从AWS查询实例类型的vCPU数量，可通过 INSTANCE_VCPU_SOURCE=aws 开启
+func isAWSVCPUSourceEnabled() bool {
+	return os.Getenv("INSTANCE_VCPU_SOURCE") == "aws"
+}
+
+// getInstanceVCPUs 获取实例类型的vCPU数量，优先使用AWS查询结果，其次使用内置表，未知类型返回0
+func getInstanceVCPUs(instanceType string) int {
+	if isAWSVCPUSourceEnabled() {
+		// 首次使用时在后台加载，加载完成前使用内置表
+		instanceVCPUsOnce.Do(func() {
+			go loadInstanceVCPUs()
+		})
+		if value, ok := instanceVCPUs.Load(instanceType); ok {
+			return value.(int)
+		}
+	}
+	return staticInstanceVCPUs[instanceType]
+}
+
+// loadInstanceVCPUs 使用账号池中的一个可用账号查询实例类型的vCPU数量并缓存
+func loadInstanceVCPUs() {
+	var account *AccountInfo
+	for _, candidate := range GetAccountPool().GetAllAccounts() {
+		if !candidate.IsSkipped {
+			account = candidate
+			break
+		}
+	}
+	if account == nil {
+		log.Printf("没有可用账号查询实例类型vCPU数量，使用内置表")
+		return
+	}
+
+	regionCode := account.RegionCode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	vcpus, err := aws.NewAWSClient(account.Key1, account.Key2).GetInstanceTypeVCPUs(ctx, regionCode)
+	if err != nil {
+		log.Printf("使用账号[%s]查询实例类型vCPU数量失败，使用内置表: %v", account.ID, err)
+		return
+	}
+
+	for instanceType, count := range vcpus {
+		instanceVCPUs.Store(instanceType, count)
+	}
+	log.Printf("已从AWS加载%d种实例类型的vCPU数量", len(vcpus))
+}
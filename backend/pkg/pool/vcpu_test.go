@@ -0,0 +1,63 @@
+package pool
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"portal/model"
+	"portal/pkg/region"
+)
+
+func TestLoadInstanceVCPUsFromAWS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if action := r.PostForm.Get("Action"); action != "DescribeInstanceTypes" {
+			http.Error(w, "unexpected action "+action, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, `<DescribeInstanceTypesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><instanceTypeSet>`+
+			`<item><instanceType>m5.large</instanceType><vCpuInfo><defaultVCpus>2</defaultVCpus></vCpuInfo></item>`+
+			`<item><instanceType>c5n.large</instanceType><vCpuInfo><defaultVCpus>3</defaultVCpus></vCpuInfo></item>`+
+			`</instanceTypeSet></DescribeInstanceTypesResponse>`)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	t.Setenv("INSTANCE_VCPU_SOURCE", "aws")
+
+	// 直接同步加载，不触发后台加载
+	instanceVCPUsOnce.Do(func() {})
+	t.Cleanup(func() {
+		instanceVCPUs.Range(func(key, _ any) bool {
+			instanceVCPUs.Delete(key)
+			return true
+		})
+		instanceVCPUsOnce = sync.Once{}
+	})
+
+	// ID排在最前，确保用于查询
+	hk := region.HK
+	accountPool := GetAccountPool()
+	accountPool.AddAccount(model.Account{ID: "0000", UserID: "vcpu-user", Key1: "AKIAVCPU", Key2: "secret", Region: &hk})
+	t.Cleanup(func() { accountPool.RemoveAccount("0000") })
+
+	if got := getInstanceVCPUs("m5.large"); got != 0 {
+		t.Fatalf("加载前未知实例类型的vCPU数量 = %d, want 0", got)
+	}
+	loadInstanceVCPUs()
+
+	if got := getInstanceVCPUs("m5.large"); got != 2 {
+		t.Fatalf("m5.large vCPU数量 = %d, 期望从AWS加载为2", got)
+	}
+	// AWS查询结果优先于内置表
+	if got := getInstanceVCPUs("c5n.large"); got != 3 {
+		t.Fatalf("c5n.large vCPU数量 = %d, 期望使用AWS返回的3", got)
+	}
+	// AWS未返回的类型回退到内置表
+	if got := getInstanceVCPUs("c5n.xlarge"); got != 4 {
+		t.Fatalf("c5n.xlarge vCPU数量 = %d, 期望使用内置表的4", got)
+	}
+}
@@ -0,0 +1,167 @@
+// pkg/region/region.go
+package region
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 内置区域代码
+const (
+	HK = "ap-east-1"      // 香港区域
+	JP = "ap-northeast-3" // 日本区域
+	SG = "ap-southeast-1" // 新加坡区域
+)
+
+// Config 区域配置，检测、补机、开机和账号导入都从这里读取支持的区域
+type Config struct {
+	Code    string   `json:"code"`    // AWS区域代码
+	Name    string   `json:"name"`    // 显示名称
+	AMI     string   `json:"ami"`     // 开机使用的AMI
+	Aliases []string `json:"aliases"` // 别名（简写、英文名、中文名），匹配时不区分大小写
+}
+
+// builtinRegions 内置区域，香港、日本、新加坡在监控和设置中有单独的字段
+var builtinRegions = []Config{
+	{Code: HK, Name: "香港区", AMI: "ami-06dd48f3dbcc241f3", Aliases: []string{"hk", "hongkong", "香港"}},
+	{Code: JP, Name: "日本区", AMI: "ami-0eed40102c8eb6998", Aliases: []string{"jp", "japan", "日本"}},
+	{Code: SG, Name: "新加坡区", AMI: "ami-0acbb557db23991cc", Aliases: []string{"sg", "singapore", "新加坡"}},
+}
+
+var (
+	regions     []Config
+	regionIndex map[string]int    // 区域代码 -> regions下标
+	aliases     map[string]string // 别名到区域代码的映射，键统一为小写
+	loadOnce    sync.Once
+)
+
+// load 加载区域列表：内置区域加上 EXTRA_REGIONS 配置的区域
+// EXTRA_REGIONS 为JSON数组，如 [{"code":"us-west-2","name":"美西区","ami":"ami-xxx","aliases":["usw2","美西"]}]，
+// 代码与内置区域相同时覆盖内置区域的名称、AMI和别名
+//
+// 新增的区域在这里登记后即可参与检测、补机、开机和阈值校验，但没有内置区域那样的单独字段：
+//   - 阈值保存在监控配置的 RegionThresholds（JSON对象），上限通过如 MAX_THRESHOLD_US_WEST_2 的环境变量配置
+//   - 开机脚本使用部署级别的默认脚本，如 DEFAULT_SCRIPT_US_WEST_2，未配置时使用用户的通用脚本
+//   - 没有用户级别的区域脚本和IP段，开机密码可在设置的 RegionPasswords 中按区域代码单独配置
+//   - 没有单独的补机开关，IsRegionEnabled 只看监控总开关，IPRangeForRegion 返回空即不限制IP段
+func load() {
+	loadOnce.Do(func() {
+		regions = append([]Config(nil), builtinRegions...)
+
+		if value := os.Getenv("EXTRA_REGIONS"); strings.TrimSpace(value) != "" {
+			var extra []Config
+			if err := json.Unmarshal([]byte(value), &extra); err != nil {
+				log.Printf("EXTRA_REGIONS配置无效，只使用内置区域: %v", err)
+				extra = nil
+			}
+			for _, cfg := range extra {
+				cfg.Code = strings.TrimSpace(cfg.Code)
+				if cfg.Code == "" {
+					log.Printf("EXTRA_REGIONS中存在未填写区域代码的配置，已忽略")
+					continue
+				}
+				if cfg.Name == "" {
+					cfg.Name = cfg.Code
+				}
+				replaced := false
+				for i := range regions {
+					if regions[i].Code == cfg.Code {
+						regions[i] = cfg
+						replaced = true
+						break
+					}
+				}
+				if !replaced {
+					regions = append(regions, cfg)
+				}
+			}
+		}
+
+		regionIndex = make(map[string]int, len(regions))
+		aliases = make(map[string]string)
+		codes := make([]string, 0, len(regions))
+		for i, cfg := range regions {
+			codes = append(codes, cfg.Code)
+			regionIndex[cfg.Code] = i
+			aliases[strings.ToLower(cfg.Code)] = cfg.Code
+			for _, alias := range cfg.Aliases {
+				aliases[strings.ToLower(strings.TrimSpace(alias))] = cfg.Code
+			}
+		}
+		// 不能在加载过程中调用 Codes，否则会重入 loadOnce 导致死锁
+		if len(regions) > len(builtinRegions) {
+			log.Printf("已加载区域: %v", codes)
+		}
+	})
+}
+
+// All 返回所有支持的区域配置，内置区域在前
+func All() []Config {
+	load()
+	return append([]Config(nil), regions...)
+}
+
+// Codes 返回所有支持的区域代码，顺序与 All 一致
+func Codes() []string {
+	load()
+	codes := make([]string, 0, len(regions))
+	for _, cfg := range regions {
+		codes = append(codes, cfg.Code)
+	}
+	return codes
+}
+
+// Get 获取区域配置
+func Get(code string) (Config, bool) {
+	load()
+	if i, exists := regionIndex[code]; exists {
+		return regions[i], true
+	}
+	return Config{}, false
+}
+
+// DisplayName 获取区域显示名称，未知区域返回区域代码
+func DisplayName(code string) string {
+	if cfg, exists := Get(code); exists {
+		return cfg.Name
+	}
+	return code
+}
+
+// IsBuiltin 判断是否为在监控和设置中有单独字段的内置区域
+func IsBuiltin(code string) bool {
+	return code == HK || code == JP || code == SG
+}
+
+// EnvKey 获取按区域配置的环境变量名，内置区域使用简写，如 MAX_THRESHOLD_JP，
+// 其他区域使用大写并将"-"替换为"_"的区域代码，如 MAX_THRESHOLD_US_WEST_2
+func EnvKey(prefix, code string) string {
+	switch code {
+	case HK:
+		return prefix + "HK"
+	case JP:
+		return prefix + "JP"
+	case SG:
+		return prefix + "SG"
+	}
+	return prefix + strings.ToUpper(strings.ReplaceAll(code, "-", "_"))
+}
+
+// Normalize 将区域别名（简写、英文名、中文名）转换为区域代码
+// 无法识别的输入原样返回，由调用方决定如何处理
+func Normalize(input string) string {
+	load()
+	if code, exists := aliases[strings.ToLower(strings.TrimSpace(input))]; exists {
+		return code
+	}
+	return input
+}
+
+// IsSupported 判断是否为支持的区域代码
+func IsSupported(code string) bool {
+	_, exists := Get(code)
+	return exists
+}
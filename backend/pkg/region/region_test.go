@@ -0,0 +1,104 @@
+package region
+
+import (
+	"sync"
+	"testing"
+)
+
+// reloadRegions 使用指定的 EXTRA_REGIONS 重新加载区域列表，测试结束后恢复
+func reloadRegions(t *testing.T, extra string) {
+	t.Helper()
+	t.Setenv("EXTRA_REGIONS", extra)
+	loadOnce = sync.Once{}
+	t.Cleanup(func() { loadOnce = sync.Once{} })
+}
+
+func TestEnvKey(t *testing.T) {
+	tests := map[string]string{
+		HK:          "MAX_THRESHOLD_HK",
+		JP:          "MAX_THRESHOLD_JP",
+		SG:          "MAX_THRESHOLD_SG",
+		"us-west-2": "MAX_THRESHOLD_US_WEST_2",
+	}
+	for code, want := range tests {
+		if got := EnvKey("MAX_THRESHOLD_", code); got != want {
+			t.Errorf("区域[%s]的环境变量名 = %s, 期望 %s", code, got, want)
+		}
+	}
+}
+
+func TestExtraRegions(t *testing.T) {
+	reloadRegions(t, `[{"code":"us-west-2","name":"美西区","ami":"ami-usw2","aliases":["usw2","美西"]},{"code":"ap-east-1","name":"香港","ami":"ami-hk-new"},{"name":"缺少代码"}]`)
+
+	codes := Codes()
+	want := []string{HK, JP, SG, "us-west-2"}
+	if len(codes) != len(want) {
+		t.Fatalf("区域列表 = %v, 期望 %v", codes, want)
+	}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("区域列表 = %v, 期望 %v", codes, want)
+		}
+	}
+
+	cfg, ok := Get("us-west-2")
+	if !ok || cfg.AMI != "ami-usw2" || DisplayName("us-west-2") != "美西区" {
+		t.Fatalf("新增区域配置 = %+v, %v", cfg, ok)
+	}
+	if IsBuiltin("us-west-2") || !IsSupported("us-west-2") {
+		t.Fatal("新增区域应受支持且不属于内置区域")
+	}
+	for _, alias := range []string{"usw2", "美西", "US-WEST-2"} {
+		if got := Normalize(alias); got != "us-west-2" {
+			t.Errorf("Normalize(%q) = %q, 期望 us-west-2", alias, got)
+		}
+	}
+
+	// 与内置区域代码相同时覆盖内置配置
+	if hk, _ := Get(HK); hk.AMI != "ami-hk-new" || hk.Name != "香港" {
+		t.Errorf("覆盖后的香港区配置 = %+v", hk)
+	}
+}
+
+func TestExtraRegionsInvalidJSON(t *testing.T) {
+	reloadRegions(t, `{"code":"us-west-2"}`)
+
+	if IsSupported("us-west-2") || len(Codes()) != 3 {
+		t.Fatalf("配置无效时应只使用内置区域, 实际 %v", Codes())
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	reloadRegions(t, "")
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"ap-east-1", HK},
+		{"hk", HK},
+		{"HK", HK},
+		{"hongkong", HK},
+		{"香港", HK},
+		{" hk ", HK},
+		{"ap-northeast-3", JP},
+		{"jp", JP},
+		{"Japan", JP},
+		{"日本", JP},
+		{"ap-southeast-1", SG},
+		{"sg", SG},
+		{"singapore", SG},
+		{"新加坡", SG},
+		{"AP-SOUTHEAST-1", SG},
+		{"mars", "mars"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.input); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, 期望 %q", tt.input, got, tt.want)
+		}
+	}
+	if IsSupported(Normalize("mars")) {
+		t.Error("无法识别的区域不应受支持")
+	}
+}
@@ -0,0 +1,69 @@
+// pkg/testdb/testdb.go
+// Package testdb 提供测试使用的临时数据库，基于 gorm 的 SQLite 驱动，只应在 _test.go 中引用
+package testdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open 创建一个临时数据库并迁移指定的模型，测试结束后关闭并删除
+func Open(t testing.TB, models ...any) *gorm.DB {
+	t.Helper()
+
+	db, err := open(filepath.Join(t.TempDir(), "test.db"), models)
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// New 创建一个临时数据库并迁移指定的模型，用于 TestMain 等没有 testing.TB 的场景，
+// 返回的 cleanup 负责关闭连接并删除数据库文件
+func New(models ...any) (db *gorm.DB, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "testdb")
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err = open(filepath.Join(dir, "test.db"), models)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	return db, func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// open 打开数据库文件，使用文件而不是内存数据库，多个连接才能看到同一份数据；
+// 设置忙等待超时，避免后台协程并发写入时直接返回 database is locked
+func open(path string, models []any) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(path+"?_busy_timeout=5000&_journal_mode=WAL"), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		return nil, fmt.Errorf("迁移测试表失败: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,75 @@
+// pkg/tg/format.go
+package tg
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"portal/pkg/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	parseMode     string
+	parseModeOnce sync.Once
+)
+
+// getParseMode 获取通知消息的解析模式，可通过 TG_PARSE_MODE 配置
+// 支持 MarkdownV2（默认）、HTML、Markdown（旧版）和 none（纯文本）
+func getParseMode() string {
+	parseModeOnce.Do(func() {
+		parseMode = tgbotapi.ModeMarkdownV2
+		value := config.Get().Tg.ParseMode
+		switch value {
+		case "":
+		case "markdownv2":
+			parseMode = tgbotapi.ModeMarkdownV2
+		case "html":
+			parseMode = tgbotapi.ModeHTML
+		case "markdown":
+			parseMode = tgbotapi.ModeMarkdown
+		case "none":
+			parseMode = ""
+		default:
+			log.Printf("TG_PARSE_MODE配置无效: %s，使用默认值%s", value, tgbotapi.ModeMarkdownV2)
+		}
+	})
+	return parseMode
+}
+
+// escapeText 按解析模式转义插入消息的内容，纯文本模式原样返回
+func escapeText(mode string, text string) string {
+	if mode == "" {
+		return text
+	}
+	return tgbotapi.EscapeText(mode, text)
+}
+
+// formatBold 生成加粗文本，text会被转义
+func formatBold(mode string, text string) string {
+	switch mode {
+	case tgbotapi.ModeHTML:
+		return "<b>" + escapeText(mode, text) + "</b>"
+	case tgbotapi.ModeMarkdown, tgbotapi.ModeMarkdownV2:
+		return "*" + escapeText(mode, text) + "*"
+	default:
+		return text
+	}
+}
+
+// formatCode 生成等宽文本，text会被转义
+func formatCode(mode string, text string) string {
+	switch mode {
+	case tgbotapi.ModeHTML:
+		return "<code>" + escapeText(mode, text) + "</code>"
+	case tgbotapi.ModeMarkdownV2:
+		return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(text) + "`"
+	case tgbotapi.ModeMarkdown:
+		// 旧版Markdown的代码块内无法转义，将反引号替换为单引号
+		return "`" + strings.ReplaceAll(text, "`", "'") + "`"
+	default:
+		return text
+	}
+}
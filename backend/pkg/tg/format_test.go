@@ -0,0 +1,88 @@
+package tg
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// setParseMode 设置通知消息的解析模式，测试结束后恢复
+func setParseMode(t *testing.T, mode string) {
+	t.Helper()
+	parseModeOnce.Do(func() {})
+	old := parseMode
+	parseMode = mode
+	t.Cleanup(func() { parseMode = old })
+}
+
+// markdownV2Reserved 代码块以外未转义的MarkdownV2保留字符（不含格式标记*）
+var markdownV2Reserved = regexp.MustCompile("(^|[^\\\\])[_\\[\\]()~>#+\\-=|{}.!]")
+
+// newStrictBotServer 模拟Telegram发送消息接口，MarkdownV2消息中存在未转义的保留字符时按Telegram的方式拒绝
+func newStrictBotServer(t *testing.T, sent chan<- string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"test","username":"test_bot"}}`)
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			_ = r.ParseForm()
+			text := r.PostForm.Get("text")
+			if r.PostForm.Get("parse_mode") == tgbotapi.ModeMarkdownV2 {
+				outside := regexp.MustCompile("`(\\\\.|[^`\\\\])*`").ReplaceAllString(text, "")
+				if markdownV2Reserved.MatchString(outside) {
+					fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`)
+					return
+				}
+			}
+			sent <- text
+			fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":42,"type":"private"}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendInstanceStatusEscapesUnderscores(t *testing.T) {
+	setParseMode(t, tgbotapi.ModeMarkdownV2)
+	sent := make(chan string, 1)
+	srv := newStrictBotServer(t, sent)
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("123:test", srv.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatalf("创建Bot失败: %v", err)
+	}
+	c := &TgClient{bot: bot}
+
+	// 未转义时服务端会拒绝，确认模拟服务与Telegram行为一致
+	raw := tgbotapi.NewMessage(42, "账号ID: acc_test_1")
+	raw.ParseMode = tgbotapi.ModeMarkdownV2
+	if _, err := bot.Send(raw); err == nil {
+		t.Fatal("未转义的下划线应导致发送失败")
+	}
+
+	err = c.SendInstanceStatusNotification("42", InstanceOnline, "u1", "acc_test_1", "i-1", "198.51.100.1", "t3.micro", "ap-east-1")
+	if err != nil {
+		t.Fatalf("发送上线通知失败: %v", err)
+	}
+	text := <-sent
+	if !strings.Contains(text, "`acc_test_1`") || !strings.Contains(text, `198\.51\.100\.1`) {
+		t.Fatalf("通知内容未按MarkdownV2转义: %q", text)
+	}
+
+	// 默认模板中的账号ID不在代码块内，下划线需要转义
+	err = c.SendInstanceStatusNotification("42", MessageType("unknown"), "u1", "acc_test_1", "i-1", "198.51.100.1", "t3.micro", "ap-east-1")
+	if err != nil {
+		t.Fatalf("发送默认通知失败: %v", err)
+	}
+	if text := <-sent; !strings.Contains(text, `acc\_test\_1`) {
+		t.Fatalf("默认通知中的下划线未转义: %q", text)
+	}
+}
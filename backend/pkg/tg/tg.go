@@ -4,13 +4,13 @@ package tg
 import (
 	"crypto/rand"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"portal/model" // 使用项目的正确导入路径
+	"portal/pkg/config"
 	"portal/repository"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -42,42 +42,90 @@ type BindingCode struct {
 	ExpiresAt time.Time // 过期时间
 }
 
+// bindingCodeSweepInterval 后台清理过期绑定码的间隔
+const bindingCodeSweepInterval = 5 * time.Minute
+
+// 初始化失败后的重试间隔，每次失败翻倍，直到最大值
+const (
+	initRetryMinBackoff = 10 * time.Second
+	initRetryMaxBackoff = 5 * time.Minute
+)
+
 var (
-	client  *TgClient
-	once    sync.Once
-	initErr error
+	client      *TgClient
+	initMu      sync.Mutex
+	initErr     error
+	lastInitAt  time.Time     // 上次尝试初始化的时间
+	initBackoff time.Duration // 当前的重试间隔
+
+	// newBotAPI 创建Telegram Bot，测试中可替换
+	newBotAPI = tgbotapi.NewBotAPI
 )
 
 // InitTgClient 初始化TG客户端单例
+// 初始化失败后允许再次调用重试，重试间隔按指数退避，避免启动时的临时故障永久禁用TG
 func InitTgClient() error {
-	once.Do(func() {
-		token := os.Getenv("TG_BOT_TOKEN")
-		if token == "" {
-			initErr = fmt.Errorf("TG_BOT_TOKEN 环境变量未设置")
-			return
-		}
+	initMu.Lock()
+	defer initMu.Unlock()
 
-		bot, err := tgbotapi.NewBotAPI(token)
-		if err != nil {
-			initErr = fmt.Errorf("初始化Telegram Bot失败: %v", err)
-			return
-		}
+	// 已初始化成功
+	if client != nil {
+		return nil
+	}
 
-		client = &TgClient{
-			bot:          bot,
-			token:        token,
-			bindingCodes: make(map[string]*BindingCode),
-			db:           repository.GetDB(),
-		}
+	// 处于退避期内，直接返回上次的错误
+	if initErr != nil && time.Since(lastInitAt) < initBackoff {
+		return initErr
+	}
+	lastInitAt = time.Now()
+
+	token := config.Get().Tg.BotToken
+	if token == "" {
+		initErr = fmt.Errorf("TG_BOT_TOKEN 环境变量未设置")
+		increaseInitBackoff()
+		return initErr
+	}
 
-		// 启动消息监听
-		go client.startMessageListening()
-	})
-	return initErr
+	bot, err := newBotAPI(token)
+	if err != nil {
+		initErr = fmt.Errorf("初始化Telegram Bot失败: %v", err)
+		increaseInitBackoff()
+		return initErr
+	}
+
+	client = &TgClient{
+		bot:          bot,
+		token:        token,
+		bindingCodes: make(map[string]*BindingCode),
+		db:           repository.GetDB(),
+	}
+	initErr = nil
+	initBackoff = 0
+
+	// 启动消息监听
+	go client.startMessageListening()
+	// 定期清理过期绑定码，不依赖新绑定码的创建
+	go client.sweepExpiredBindingCodes(bindingCodeSweepInterval)
+	return nil
+}
+
+// increaseInitBackoff 初始化失败后增加重试间隔，调用方需持有initMu
+func increaseInitBackoff() {
+	if initBackoff == 0 {
+		initBackoff = initRetryMinBackoff
+	} else {
+		initBackoff *= 2
+	}
+	if initBackoff > initRetryMaxBackoff {
+		initBackoff = initRetryMaxBackoff
+	}
 }
 
 // GetClient 获取TG客户端实例
 func GetClient() (*TgClient, error) {
+	initMu.Lock()
+	defer initMu.Unlock()
+
 	if client == nil {
 		return nil, fmt.Errorf("Telegram客户端尚未初始化，请先调用InitTgClient()")
 	}
@@ -85,17 +133,24 @@ func GetClient() (*TgClient, error) {
 }
 
 // getMessageTemplate 根据消息类型生成消息模板
+// 插入的账号ID、IP等内容按解析模式转义，避免特殊字符导致消息格式错误或发送失败
 func getMessageTemplate(msgType MessageType, userID string, accountID string, instanceID string, ipv4 string, instanceType string, region string) string {
-	templates := map[MessageType]string{
-		InstanceOffline: fmt.Sprintf("⚠️ *实例离线通知*\n"+
-			"*账号ID*: `%s`\n"+
-			"*IP地址*: %s",
-			accountID, ipv4),
+	mode := getParseMode()
 
-		InstanceOnline: fmt.Sprintf("✅ *实例上线通知*\n"+
-			"*账号ID*: `%s`\n"+
-			"*IP地址*: %s",
-			accountID, ipv4),
+	templates := map[MessageType]string{
+		InstanceOffline: fmt.Sprintf("⚠️ %s\n"+
+			"%s: %s\n"+
+			"%s: %s",
+			formatBold(mode, "实例离线通知"),
+			formatBold(mode, "账号ID"), formatCode(mode, accountID),
+			formatBold(mode, "IP地址"), escapeText(mode, ipv4)),
+
+		InstanceOnline: fmt.Sprintf("✅ %s\n"+
+			"%s: %s\n"+
+			"%s: %s",
+			formatBold(mode, "实例上线通知"),
+			formatBold(mode, "账号ID"), formatCode(mode, accountID),
+			formatBold(mode, "IP地址"), escapeText(mode, ipv4)),
 	}
 
 	if template, exists := templates[msgType]; exists {
@@ -103,8 +158,10 @@ func getMessageTemplate(msgType MessageType, userID string, accountID string, in
 	}
 
 	// 默认模板
-	return fmt.Sprintf("📢 *通知*\n*账号ID*: %s\n*IP地址*: %s",
-		accountID, ipv4)
+	return fmt.Sprintf("📢 %s\n%s: %s\n%s: %s",
+		formatBold(mode, "通知"),
+		formatBold(mode, "账号ID"), escapeText(mode, accountID),
+		formatBold(mode, "IP地址"), escapeText(mode, ipv4))
 }
 
 // SendInstanceStatusNotification 发送实例状态通知
@@ -124,7 +181,7 @@ func (c *TgClient) SendInstanceStatusNotification(tgUserID string, msgType Messa
 
 	// 创建并发送消息
 	telegramMsg := tgbotapi.NewMessage(chatID, messageText)
-	telegramMsg.ParseMode = tgbotapi.ModeMarkdown
+	telegramMsg.ParseMode = getParseMode()
 
 	// 发送消息
 	_, err = c.bot.Send(telegramMsg)
@@ -167,6 +224,15 @@ func NotifyInstanceStatus(db *gorm.DB, isOnline bool, userID string, accountID s
 		return nil
 	}
 
+	// 处于用户的免打扰时段时不发送通知
+	_, quietHours, err := model.GetNotifySuppression(db, userID)
+	if err != nil {
+		return fmt.Errorf("获取用户免打扰设置失败: %v", err)
+	}
+	if model.InQuietHours(quietHours, time.Now()) {
+		return nil
+	}
+
 	// 根据实例状态发送不同的通知
 	if isOnline {
 		err = client.SendInstanceOnlineNotification(tgUserID, userID, accountID, instanceID, ipv4, instanceType, region)
@@ -181,6 +247,45 @@ func NotifyInstanceStatus(db *gorm.DB, isOnline bool, userID string, accountID s
 	return nil
 }
 
+// NotifyUserMessage 向用户发送自定义通知（检查用户设置）
+func NotifyUserMessage(db *gorm.DB, userID string, message string) error {
+	// 如果客户端未初始化，则尝试初始化
+	if client == nil {
+		if err := InitTgClient(); err != nil {
+			return fmt.Errorf("TG客户端初始化失败: %v", err)
+		}
+	}
+
+	// 获取用户的TG通知设置
+	isTgEnabled, tgUserID, err := model.GetTgNotificationSettings(db, userID)
+	if err != nil {
+		return fmt.Errorf("获取用户TG通知设置失败: %v", err)
+	}
+
+	// 检查是否启用TG通知和TG用户ID是否为空
+	if !isTgEnabled || tgUserID == "" {
+		return nil
+	}
+
+	return client.SendSimpleMessage(tgUserID, message)
+}
+
+// NotifyAdminMessage 向所有开启TG通知的管理员发送通知
+func NotifyAdminMessage(db *gorm.DB, message string) error {
+	var adminIDs []string
+	if err := db.Model(&model.User{}).Where("is_admin = ?", 1).Pluck("id", &adminIDs).Error; err != nil {
+		return fmt.Errorf("获取管理员列表失败: %v", err)
+	}
+
+	var lastErr error
+	for _, adminID := range adminIDs {
+		if err := NotifyUserMessage(db, adminID, message); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
 // GetBotInfo 获取Bot的基本信息
 func (c *TgClient) GetBotInfo() tgbotapi.User {
 	return c.bot.Self
@@ -233,7 +338,19 @@ func (c *TgClient) CreateBindingCode(userID string) (string, error) {
 	return bindingCode, nil
 }
 
-// cleanExpiredBindingCodes 清理过期的绑定码
+// sweepExpiredBindingCodes 按固定间隔清理过期的绑定码
+func (c *TgClient) sweepExpiredBindingCodes(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.bindingCodeLock.Lock()
+		c.cleanExpiredBindingCodes()
+		c.bindingCodeLock.Unlock()
+	}
+}
+
+// cleanExpiredBindingCodes 清理过期的绑定码，调用方需持有bindingCodeLock
 func (c *TgClient) cleanExpiredBindingCodes() {
 	now := time.Now()
 	for code, info := range c.bindingCodes {
@@ -0,0 +1,112 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newFakeBotServer 模拟Telegram Bot接口，getUpdates 挂起一段时间后返回空列表
+func newFakeBotServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"test","username":"test_bot"}}`)
+		default:
+			select {
+			case <-r.Context().Done():
+			case <-time.After(200 * time.Millisecond):
+			}
+			fmt.Fprint(w, `{"ok":true,"result":[]}`)
+		}
+	}))
+	t.Cleanup(srv.CloseClientConnections)
+	return srv
+}
+
+func TestInitTgClientRetriesAfterFailure(t *testing.T) {
+	t.Setenv("TG_BOT_TOKEN", "123:test")
+	srv := newFakeBotServer(t)
+
+	oldNew := newBotAPI
+	t.Cleanup(func() {
+		initMu.Lock()
+		newBotAPI, client, initErr, initBackoff, lastInitAt = oldNew, nil, nil, 0, time.Time{}
+		initMu.Unlock()
+	})
+
+	calls := 0
+	newBotAPI = func(token string) (*tgbotapi.BotAPI, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("network is unreachable")
+		}
+		return tgbotapi.NewBotAPIWithAPIEndpoint(token, srv.URL+"/bot%s/%s")
+	}
+
+	if err := InitTgClient(); err == nil {
+		t.Fatal("首次初始化失败时应返回错误")
+	}
+	if _, err := GetClient(); err == nil {
+		t.Fatal("初始化失败时不应返回客户端")
+	}
+
+	// 退避期内不会再次尝试
+	if err := InitTgClient(); err == nil || calls != 1 {
+		t.Fatalf("退避期内再次初始化: err=%v, 尝试次数=%d, 期望直接返回上次错误", err, calls)
+	}
+
+	// 退避期结束后重试成功
+	initMu.Lock()
+	lastInitAt = time.Now().Add(-initBackoff)
+	initMu.Unlock()
+	if err := InitTgClient(); err != nil {
+		t.Fatalf("退避期结束后重试初始化失败: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("初始化尝试次数 = %d, want 2", calls)
+	}
+	if c, err := GetClient(); err != nil || c == nil {
+		t.Fatalf("重试成功后 GetClient() = %v, %v", c, err)
+	}
+	if initBackoff != 0 {
+		t.Fatalf("初始化成功后重试间隔 = %v, 期望重置为0", initBackoff)
+	}
+}
+
+func TestSweepRemovesExpiredBindingCodes(t *testing.T) {
+	now := time.Now()
+	c := &TgClient{bindingCodes: map[string]*BindingCode{
+		"expired": {Code: "expired", UserID: "u1", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)},
+		"valid":   {Code: "valid", UserID: "u2", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+	}}
+
+	// 不调用 CreateBindingCode，只依赖后台清理
+	go c.sweepExpiredBindingCodes(10 * time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.bindingCodeLock.Lock()
+		_, expired := c.bindingCodes["expired"]
+		_, valid := c.bindingCodes["valid"]
+		c.bindingCodeLock.Unlock()
+		if !valid {
+			t.Fatal("未过期的绑定码不应被清理")
+		}
+		if !expired {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("过期的绑定码未被后台清理")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -21,3 +21,8 @@ func NewImportRepository(db *gorm.DB) *ImportRepository {
 func (r *ImportRepository) ImportAccounts(accounts []model.AccountInput, userID string) model.ImportResult {
 	return model.ValidateAndCreateAccounts(r.db, accounts, userID)
 }
+
+// PreviewImport 预览账号导入结果，不写入数据库
+func (r *ImportRepository) PreviewImport(accounts []model.AccountInput) model.ImportResult {
+	return model.PreviewAccountsImport(r.db, accounts)
+}
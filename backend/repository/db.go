@@ -11,6 +11,8 @@ import (
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"portal/pkg/config"
 )
 
 var (
@@ -22,13 +24,14 @@ var (
 func InitDB() error {
 	var err error
 	once.Do(func() {
-		// 从环境变量中读取数据库配置
+		// 从统一配置中读取数据库配置
+		mysqlCfg := config.Get().MySQL
 		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			os.Getenv("MYSQL_USERNAME"),
-			os.Getenv("MYSQL_PASSWORD"),
-			os.Getenv("MYSQL_HOST"),
-			os.Getenv("MYSQL_PORT"),
-			os.Getenv("MYSQL_DATABASE"),
+			mysqlCfg.Username,
+			mysqlCfg.Password,
+			mysqlCfg.Host,
+			mysqlCfg.Port,
+			mysqlCfg.Database,
 		)
 
 		// 自定义日志配置
@@ -71,3 +74,8 @@ func InitDB() error {
 func GetDB() *gorm.DB {
 	return db
 }
+
+// SetDB 替换数据库连接实例，供测试注入内存数据库使用
+func SetDB(conn *gorm.DB) {
+	db = conn
+}
@@ -62,11 +62,37 @@ func (r *SettingRepository) UpdateSetting(userID string, req *model.UpdateSettin
 	updates := map[string]interface{}{
 		"region":        req.Region,
 		"instance_type": req.InstanceType,
-		// "disk_size":     req.DiskSize,
-		"password":  req.Password,
-		"script":    req.Script,
-		"jp_script": req.JpScript,
-		"sg_script": req.SgScript,
+		"password":      req.Password,
+		"script":        req.Script,
+		"jp_script":     req.JpScript,
+		"sg_script":     req.SgScript,
+	}
+	if len(req.RegionPasswords) > 0 {
+		regionPasswords, err := model.MergeRegionPasswords(setting.RegionPasswords, req.RegionPasswords)
+		if err != nil {
+			return err
+		}
+		updates["region_passwords"] = regionPasswords
+	}
+	if req.SkipSSHPassword != nil {
+		updates["skip_ssh_password"] = *req.SkipSSHPassword
+	}
+	if req.AdditionalVolumes != nil {
+		updates["additional_volumes"] = *req.AdditionalVolumes
+	}
+	if req.AllowedRegions != nil {
+		allowedRegions, err := model.NormalizeAllowedRegions(*req.AllowedRegions)
+		if err != nil {
+			return err
+		}
+		updates["allowed_regions"] = allowedRegions
+	}
+	// 硬盘大小只在传入时更新，0表示保持原值
+	if req.DiskSize != 0 {
+		if err := model.ValidateDiskSize(req.DiskSize); err != nil {
+			return err
+		}
+		updates["disk_size"] = req.DiskSize
 	}
 
 	// 更新或创建记录
@@ -4,12 +4,15 @@ package routes
 import (
 	"portal/api/account"
 	"portal/api/batchimport"
+	"portal/api/dashboard"
 	"portal/api/instance"
+	"portal/api/logfile"
 	"portal/api/monitor"
 	"portal/api/pool"
 	"portal/api/setting"
 	"portal/api/user"
 	"portal/middleware"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,9 +24,13 @@ func RegisterDashRoutes(router *gin.Engine) {
 		// 导入相关路由
 		authRequired.POST("/import", batchimport.ImportAccounts)
 
+		// 新增: 当前用户的仪表盘概览
+		authRequired.GET("/dashboard", dashboard.GetDashboard)
+
 		// 设置相关路由
 		authRequired.GET("/setting", setting.GetSetting)
 		authRequired.POST("/setting", setting.UpdateSetting)
+		authRequired.POST("/setting/script/preview", setting.PreviewScript)    // 新增: 预览开机脚本并检查语法
 		authRequired.POST("/setting/admin", setting.GetAllSettings)            // 新增: 管理员获取所有设置
 		authRequired.POST("/setting/admin/update", setting.AdminUpdateSetting) // 新增: 管理员更新指定用户设置
 
@@ -31,11 +38,22 @@ func RegisterDashRoutes(router *gin.Engine) {
 		accountGroup := authRequired.Group("/account")
 		{
 			accountGroup.GET("/list", account.List)
+			accountGroup.GET("/query-failed", account.QueryFailedList) // 新增: 管理员查看查询失败的账号
 			accountGroup.POST("/delete", account.Delete)
 			accountGroup.POST("/check", account.Check)
 			accountGroup.POST("/apply-hk", account.ApplyHK)
 			accountGroup.POST("/create-instance", account.CreateInstance) // 创建实例保留在account组
+			accountGroup.POST("/recreate", account.RecreateInstance)      // 新增: 删除实例并按当前设置重建
 			accountGroup.POST("/clean-t3-micro", account.CleanT3Micro)    // 新增: 清理t3.micro实例
+			accountGroup.POST("/clean-micro-all", account.CleanAllMicro)  // 新增: 清理全部有效账号的micro实例
+			accountGroup.POST("/region-status", account.RegionStatus)     // 新增: 管理员查询账号区域开通状态
+			accountGroup.POST("/revive", account.Revive)                  // 新增: 管理员恢复失效账号到账号池
+			accountGroup.POST("/set-region", account.SetRegion)           // 新增: 管理员修改账号区域
+			accountGroup.POST("/set-enabled", account.SetEnabled)         // 新增: 管理员启用或停用账号
+			accountGroup.POST("/vm-count/sync", account.ReconcileVMCount) // 新增: 管理员校准账号实例数量
+
+			// 新增: 凭证自检，每用户每分钟最多5次
+			accountGroup.POST("/self-test", middleware.RateLimitMiddleware(5, time.Minute), account.SelfTest)
 		}
 
 		// 实例管理路由组 - 只包含实例本身的操作
@@ -45,6 +63,7 @@ func RegisterDashRoutes(router *gin.Engine) {
 			instanceGroup.POST("/change-ip", instance.ChangeIP)       // 新增更换IP路由
 			instanceGroup.GET("/account_list", instance.ListAccounts) // 新增账号列表路由
 			instanceGroup.POST("/list", instance.ListInstances)       // 新增实例列表路由
+			instanceGroup.POST("/bulk", instance.BulkOperation)       // 新增: 按用户和区域批量操作实例
 		}
 
 		// 实例池路由组
@@ -56,9 +75,18 @@ func RegisterDashRoutes(router *gin.Engine) {
 			poolGroup.POST("/delete", pool.DeleteInstance)     // 新增: 删除实例接口
 			poolGroup.POST("/change-ip", pool.ChangeIP)        // 新增: 更换IP接口
 			poolGroup.POST("/reset-accounts", pool.ResetAccounts)
-			poolGroup.GET("/makeup-queue", pool.GetMakeupQueue)    // 获取补机队列接口
-			poolGroup.POST("/reset-makeup", pool.ResetMakeupQueue) // 重置补机队列
-			poolGroup.POST("/clear-makeup", pool.ClearMakeupQueue) // 新增: 清空补机队列
+			poolGroup.GET("/makeup-queue", pool.GetMakeupQueue)                        // 获取补机队列接口
+			poolGroup.GET("/makeup-stats", pool.GetMakeupStats)                        // 新增: 补机吞吐量和耗时统计
+			poolGroup.POST("/reset-makeup", pool.ResetMakeupQueue)                     // 重置补机队列
+			poolGroup.POST("/clear-makeup", pool.ClearMakeupQueue)                     // 新增: 清空补机队列
+			poolGroup.GET("/ip-locks", pool.GetIPLocks)                                // 新增: 获取IP锁定列表
+			poolGroup.POST("/ip-locks/clear", pool.ClearIPLocks)                       // 新增: 清除IP锁定
+			poolGroup.POST("/eligible-accounts", pool.PreviewEligibleAccounts)         // 新增: 预览可用开机账号
+			poolGroup.GET("/account/:id", pool.GetPoolAccount)                         // 新增: 查看单个账号的账号池状态
+			poolGroup.GET("/account/:id/instances", pool.GetAccountInstances)          // 新增: 合并AWS实例列表和上报状态
+			poolGroup.GET("/account/:id/failures", pool.GetPoolAccountFailures)        // 新增: 查看账号最近的开机失败记录
+			poolGroup.POST("/account/:id/recompute-usage", pool.RecomputeAccountUsage) // 新增: 按实际实例重新统计账号使用计数
+			poolGroup.POST("/debug/instance", pool.DebugInstance)                      // 新增: 模拟实例上下线（调试）
 		}
 
 		// 监控路由组
@@ -73,8 +101,13 @@ func RegisterDashRoutes(router *gin.Engine) {
 			monitorGroup.POST("/tg/unbind", monitor.UnbindTgUser)                  // 解绑TG账号
 			monitorGroup.POST("/admin/clear", monitor.ClearHistory)                // 新增: 清空补机历史和冷却状态
 			monitorGroup.POST("/admin/detect", monitor.TriggerDetection)           // 新增: 立即触发主动检测
+			monitorGroup.GET("/admin/capacity", monitor.GetCapacityMatrix)         // 新增: 各用户区域阈值与实际数量对照
+			monitorGroup.GET("/admin/detect/preview", monitor.PreviewDetection)    // 新增: 预览主动检测的补机计划
 			monitorGroup.POST("/admin/backup", monitor.BackupMonitorSettings)      // 新增: 备份TG通知设置
 			monitorGroup.POST("/admin/restore", monitor.RestoreMonitorSettings)    // 新增: 恢复TG通知设置
+			monitorGroup.GET("/admin/export", monitor.ExportMonitorSettings)       // 新增: 导出监控配置JSON
+			monitorGroup.POST("/admin/import", monitor.ImportMonitorSettings)      // 新增: 导入合并监控配置JSON
+			monitorGroup.POST("/admin/thresholds", monitor.BulkUpdateThresholds)   // 新增: 批量更新用户阈值
 			monitorGroup.POST("/check-ip", monitor.TriggerUserIPRangeCheck)        // 新增: 普通用户触发IP范围检查
 			monitorGroup.POST("/admin/check-ip", monitor.TriggerAdminIPRangeCheck) // 新增: 管理员触发所有用户的IP范围检查
 		}
@@ -87,5 +120,13 @@ func RegisterDashRoutes(router *gin.Engine) {
 			userGroup.POST("/makeup", user.MakeupUsers) // 新增: 用户补机
 			userGroup.POST("/create", user.CreateUser)  // 新增: 创建用户
 		}
+
+		// 日志路由组（管理员）
+		logGroup := authRequired.Group("/logs")
+		{
+			logGroup.GET("/files", logfile.ListFiles)   // 新增: 列出日志文件
+			logGroup.GET("/tail", logfile.Tail)         // 新增: 查看日志最后N行
+			logGroup.GET("/download", logfile.Download) // 新增: 下载日志文件
+		}
 	}
 }
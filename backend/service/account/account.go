@@ -4,12 +4,18 @@ package account
 import (
 	"context"
 	"fmt"
+	"log"
+	"os"
 	"portal/model"
 	"portal/pkg/aws"
+	"portal/pkg/logger"
 	"portal/pkg/pool"
+	"portal/pkg/region"
 	"portal/repository/account"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -45,12 +51,191 @@ func (s *AccountService) Delete(userID string, accountIDs []string) error {
 	return err
 }
 
+// TerminatedInstance 删除账号时终止实例的结果
+type TerminatedInstance struct {
+	AccountID  string `json:"account_id"`
+	Region     string `json:"region"`
+	InstanceID string `json:"instance_id,omitempty"`
+	Status     string `json:"status"`            // 成功/失败
+	Message    string `json:"message,omitempty"` // 错误信息
+}
+
+// DeleteWithInstances 先终止账号在AWS上的实例再删除账号，避免实例在账号删除后继续产生费用
+// 任一实例终止失败时不删除任何账号，返回已处理的结果供用户重试
+func (s *AccountService) DeleteWithInstances(ctx context.Context, userID string, accountIDs []string) ([]TerminatedInstance, error) {
+	// 验证账号归属权
+	if err := model.VerifyAccountOwnership(s.repo.DB, userID, accountIDs); err != nil {
+		return nil, err
+	}
+
+	accounts, err := model.GetAccountKeysByIDs(s.repo.DB, userID, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		results []TerminatedInstance
+		failed  bool
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+	)
+	semaphore := make(chan struct{}, 10) // 最多10个并发
+
+	for _, acc := range accounts {
+		wg.Add(1)
+		go func(acc model.Account) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			accountResults, ok := terminateAccountInstances(ctx, acc)
+
+			mu.Lock()
+			results = append(results, accountResults...)
+			if !ok {
+				failed = true
+			}
+			mu.Unlock()
+		}(acc)
+	}
+	wg.Wait()
+
+	if failed {
+		return results, fmt.Errorf("部分实例终止失败，账号未删除，请稍后重试")
+	}
+
+	if err := s.Delete(userID, accountIDs); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// terminateAccountInstances 终止账号在其区域内的所有实例，全部成功时返回true
+func terminateAccountInstances(ctx context.Context, acc model.Account) ([]TerminatedInstance, bool) {
+	regionCode := acc.RegionCode()
+
+	awsClient := aws.NewAWSClient(acc.Key1, acc.Key2)
+	instances, err := awsClient.ListInstances(ctx, aws.ListInstancesParams{
+		Region:    regionCode,
+		AccountID: acc.ID,
+	})
+	if err != nil {
+		return []TerminatedInstance{{
+			AccountID: acc.ID,
+			Region:    regionCode,
+			Status:    "失败",
+			Message:   fmt.Sprintf("查询实例失败: %v", err),
+		}}, false
+	}
+
+	ok := true
+	results := make([]TerminatedInstance, 0, len(instances))
+	for _, instance := range instances {
+		result := TerminatedInstance{
+			AccountID:  acc.ID,
+			Region:     regionCode,
+			InstanceID: instance.InstanceID,
+			Status:     "成功",
+		}
+		if _, err := awsClient.DeleteInstance(ctx, aws.DeleteInstanceParams{
+			Region:     regionCode,
+			InstanceID: instance.InstanceID,
+		}); err != nil {
+			result.Status = "失败"
+			result.Message = err.Error()
+			ok = false
+			logger.Printf(ctx, "删除账号[%s]前终止实例[%s]失败: %v", acc.ID, instance.InstanceID, err)
+		} else {
+			logger.Printf(ctx, "删除账号[%s]前已终止实例[%s]", acc.ID, instance.InstanceID)
+		}
+		results = append(results, result)
+	}
+	return results, ok
+}
+
 type CheckResult struct {
-	AccountID string `json:"account_id"`
-	Quota     string `json:"quota"`
-	HK        string `json:"hk"`       // 只有香港区账号才有值，表示HK区状态
-	VMCount   *int32 `json:"vm_count"` // 虚拟机数量
-	Region    string `json:"region"`   // 区域代码
+	AccountID     string `json:"account_id"`
+	Quota         string `json:"quota"`
+	HK            string `json:"hk"`                        // 只有香港区账号才有值，表示HK区状态
+	VMCount       *int32 `json:"vm_count"`                  // 虚拟机数量
+	VMCountRegion string `json:"vm_count_region,omitempty"` // 虚拟机数量统计所在的区域
+	Region        string `json:"region"`                    // 区域代码
+	Transient     bool   `json:"transient,omitempty"`       // 查询失败是否由临时错误导致，此时不覆盖已有的正常状态
+}
+
+// 默认配额查询遇到临时错误时的重试次数
+const defaultCheckRetries = 2
+
+// checkRetryInterval 配额查询遇到临时错误时的基础重试间隔，第N次重试等待N倍间隔，测试中可调小
+var checkRetryInterval = 2 * time.Second
+
+var (
+	checkRetries     int
+	checkRetriesOnce sync.Once
+)
+
+// getCheckRetries 获取配额查询的重试次数，可通过环境变量 ACCOUNT_CHECK_RETRIES 配置（0表示不重试）
+func getCheckRetries() int {
+	checkRetriesOnce.Do(func() {
+		checkRetries = defaultCheckRetries
+		if v := os.Getenv("ACCOUNT_CHECK_RETRIES"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 5 {
+				checkRetries = n
+			} else {
+				log.Printf("ACCOUNT_CHECK_RETRIES配置无效[%s]，使用默认值%d", v, defaultCheckRetries)
+			}
+		}
+	})
+	return checkRetries
+}
+
+// isCredentialError 判断是否为凭证相关的错误
+func isCredentialError(err error) bool {
+	return strings.Contains(err.Error(), "get credentials: failed") ||
+		strings.Contains(err.Error(), "UnrecognizedClientException")
+}
+
+// isTransientError 判断是否为超时、限流、网络抖动等临时错误
+func isTransientError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, keyword := range []string{
+		"timeout", "deadline exceeded", "connection reset", "connection refused",
+		"eof", "throttl", "requestlimitexceeded", "toomanyrequests",
+		"serviceunavailable", "internalerror", "no such host",
+	} {
+		if strings.Contains(msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// getQuotaWithRetry 查询配额，遇到临时错误时有限次重试
+// fresh 为 true 时跳过配额缓存，用于用户主动发起的检测
+func getQuotaWithRetry(ctx context.Context, awsClient *aws.AWSClient, accountID, instanceType string, fresh bool) (string, error) {
+	retries := getCheckRetries()
+	var (
+		quota string
+		err   error
+	)
+	for attempt := 0; attempt <= retries; attempt++ {
+		if fresh {
+			quota, err = awsClient.RefreshEC2QuotaForInstanceType(ctx, instanceType)
+		} else {
+			quota, err = awsClient.GetEC2QuotaForInstanceType(ctx, instanceType)
+		}
+		if err == nil || isCredentialError(err) || !isTransientError(err) || attempt == retries {
+			return quota, err
+		}
+
+		logger.Printf(ctx, "账号ID: %s, 配额查询遇到临时错误(尝试 %d/%d): %v", accountID, attempt+1, retries+1, err)
+		select {
+		case <-ctx.Done():
+			return quota, err
+		case <-time.After(time.Duration(attempt+1) * checkRetryInterval):
+		}
+	}
+	return quota, err
 }
 
 func (s *AccountService) Check(ctx context.Context, userID string, accountIDs []string) ([]CheckResult, error) {
@@ -65,27 +250,38 @@ func (s *AccountService) Check(ctx context.Context, userID string, accountIDs []
 		return nil, err
 	}
 
+	// 根据用户设置的实例类型确定查询的配额，获取失败时使用标准配额
+	instanceType := ""
+	if setting, err := model.GetSettingByUserID(s.repo.DB, userID); err == nil {
+		instanceType = setting.InstanceType
+	}
+
 	// 创建并发控制
 	var wg sync.WaitGroup
 	// 设置合理的并发数量
 	semaphore := make(chan struct{}, 10) // 最多10个并发
 	resultChan := make(chan CheckResult, len(accounts))
+	// 配置了分散窗口时，按区域错开各账号的检测时间
+	offsets := staggerOffsets(accounts, getCheckStaggerWindow())
 
 	// 对每个账号并发执行检测
-	for _, acc := range accounts {
+	for i, acc := range accounts {
 		wg.Add(1)
 		// 复制一份acc避免闭包问题
 		account := acc
+		delay := offsets[i]
 
 		go func() {
 			defer wg.Done()
 
+			waitStagger(ctx, delay)
+
 			// 获取信号量，控制并发数
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
 			// 执行单个账号检测
-			result := s.checkSingleAccount(ctx, account)
+			result := s.checkSingleAccount(ctx, account, instanceType, true)
 			resultChan <- result
 		}()
 	}
@@ -106,37 +302,42 @@ func (s *AccountService) Check(ctx context.Context, userID string, accountIDs []
 }
 
 // checkSingleAccount 检测单个账号状态
-func (s *AccountService) checkSingleAccount(ctx context.Context, acc model.Account) CheckResult {
+// fresh 为 true 时配额跳过缓存直接查询，定时复检等后台任务传 false
+func (s *AccountService) checkSingleAccount(ctx context.Context, acc model.Account, instanceType string, fresh bool) CheckResult {
 	result := CheckResult{
 		AccountID: acc.ID,
 	}
+	// 记录检测时间，定时复检会跳过最近检测过的账号
+	defer markAccountChecked(acc.ID)
 
 	// 确保账号有区域信息
-	regionCode := "ap-east-1" // 默认香港区域
-	if acc.Region != nil && *acc.Region != "" {
-		regionCode = *acc.Region
-		result.Region = *acc.Region
-	} else {
-		result.Region = regionCode
-	}
+	regionCode := acc.RegionCode()
+	result.Region = regionCode
 
 	// 初始化AWS客户端
 	awsClient := aws.NewAWSClient(acc.Key1, acc.Key2)
 
-	// 检查配额
-	quota, err := awsClient.GetEC2Quota(ctx)
-	fmt.Printf("账号ID: %s, 配额检测响应: %+v, 错误: %v\n", acc.ID, quota, err)
+	// 检查配额，临时错误会先重试
+	quota, err := getQuotaWithRetry(ctx, awsClient, acc.ID, instanceType, fresh)
+	logger.Printf(ctx, "账号ID: %s, 配额检测响应: %+v, 错误: %v", acc.ID, quota, err)
 	if err != nil {
 		// 判断凭证相关的错误
-		if strings.Contains(err.Error(), "get credentials: failed") ||
-			strings.Contains(err.Error(), "UnrecognizedClientException") {
+		if isCredentialError(err) {
 			quota = "账号已失效"
 		} else {
 			quota = "查询失败"
 		}
+		result.Quota = quota
+
+		// 重试后仍为临时错误时，只在账号尚无状态时记录查询失败，不覆盖之前的正常状态
+		if quota == "查询失败" && isTransientError(err) {
+			result.Transient = true
+			model.MarkAccountQueryFailed(s.repo.DB, acc.ID)
+			return result
+		}
+
 		// 更新数据库并返回结果
 		model.UpdateAccountStatus(s.repo.DB, acc.ID, quota, "", nil)
-		result.Quota = quota
 		return result
 	}
 	result.Quota = quota
@@ -149,7 +350,7 @@ func (s *AccountService) checkSingleAccount(ctx context.Context, acc model.Accou
 
 	// 只有香港区需要检查区域状态
 	var hkStatus string
-	if regionCode == "ap-east-1" {
+	if regionCode == region.HK {
 		// 检查香港区域状态
 		status, err := awsClient.CheckRegionStatus(ctx, regionCode)
 		if err != nil {
@@ -178,12 +379,534 @@ func (s *AccountService) checkSingleAccount(ctx context.Context, acc model.Accou
 		return result
 	}
 	result.VMCount = &count
+	result.VMCountRegion = regionCode
 
-	// 更新数据库
-	model.UpdateAccountStatus(s.repo.DB, acc.ID, quota, hkStatus, &count)
+	// 更新数据库，同时记录数量对应的区域
+	model.UpdateAccountStatusInRegion(s.repo.DB, acc.ID, quota, hkStatus, &count, regionCode)
 	return result
 }
 
+// RegionStatusResult 账号区域开通状态查询结果
+type RegionStatusResult struct {
+	AccountID string `json:"account_id"`
+	Region    string `json:"region"`
+	Status    string `json:"status"`            // 启用/启用中/未启用/失效/查询失败
+	Message   string `json:"message,omitempty"` // 错误信息
+}
+
+// CheckRegionStatuses 管理员并发查询多个账号在指定区域的开通状态
+func (s *AccountService) CheckRegionStatuses(ctx context.Context, accountIDs []string, regionCode string) ([]RegionStatusResult, error) {
+	accounts, err := model.GetAccountKeysByIDsForAdmin(s.repo.DB, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 记录找到的账号，不存在的账号直接返回失效
+	found := make(map[string]bool, len(accounts))
+	for _, acc := range accounts {
+		found[acc.ID] = true
+	}
+
+	// 创建并发控制
+	var wg sync.WaitGroup
+	// 设置合理的并发数量
+	semaphore := make(chan struct{}, 10) // 最多10个并发
+	resultChan := make(chan RegionStatusResult, len(accountIDs))
+
+	for _, id := range accountIDs {
+		if !found[id] {
+			resultChan <- RegionStatusResult{
+				AccountID: id,
+				Region:    regionCode,
+				Status:    "失效",
+				Message:   "账号不存在",
+			}
+		}
+	}
+
+	for _, acc := range accounts {
+		wg.Add(1)
+		// 复制一份acc避免闭包问题
+		account := acc
+
+		go func() {
+			defer wg.Done()
+
+			// 获取信号量，控制并发数
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			result := RegionStatusResult{
+				AccountID: account.ID,
+				Region:    regionCode,
+			}
+
+			awsClient := aws.NewAWSClient(account.Key1, account.Key2)
+			status, err := awsClient.CheckRegionStatus(ctx, regionCode)
+			if err != nil {
+				// 判断凭证相关的错误
+				if strings.Contains(err.Error(), "get credentials: failed") ||
+					strings.Contains(err.Error(), "UnrecognizedClientException") ||
+					strings.Contains(err.Error(), "InvalidClientTokenId") {
+					result.Status = "失效"
+				} else {
+					result.Status = "查询失败"
+				}
+				result.Message = err.Error()
+				logger.Printf(ctx, "账号[%s]查询区域[%s]状态失败: %v", account.ID, regionCode, err)
+			} else {
+				result.Status = status
+			}
+
+			resultChan <- result
+		}()
+	}
+
+	// 等待所有查询完成
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// 收集所有结果
+	results := make([]RegionStatusResult, 0, len(accountIDs))
+	for result := range resultChan {
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ReviveResult 恢复失效账号结果
+type ReviveResult struct {
+	AccountID string `json:"account_id"`
+	Quota     string `json:"quota"`             // 重新检测后的配额状态
+	Revived   bool   `json:"revived"`           // 是否已重新加入账号池
+	Message   string `json:"message,omitempty"` // 详细信息
+}
+
+// ListQueryFailedAccounts 获取检测结果为"查询失败"的账号，这类账号状态不明确，需要人工确认
+func (s *AccountService) ListQueryFailedAccounts() ([]model.Account, error) {
+	accounts, err := model.ListQueryFailedAccounts(s.repo.DB)
+	if err != nil {
+		return nil, fmt.Errorf("获取查询失败账号列表失败: %v", err)
+	}
+	return accounts, nil
+}
+
+// SetRegionResult 设置账号区域结果
+type SetRegionResult struct {
+	AccountID string `json:"account_id"`
+	Region    string `json:"region"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message,omitempty"` // 详细信息
+}
+
+// SetEnabled 批量启用或停用账号，停用的账号保留在数据库中但不参与补机
+func (s *AccountService) SetEnabled(accountIDs []string, enabled bool) (int64, error) {
+	updated, err := model.SetAccountsEnabled(s.repo.DB, accountIDs, enabled)
+	if err != nil {
+		return 0, err
+	}
+
+	event := pool.AccountDisabled
+	if enabled {
+		event = pool.AccountEnabled
+	}
+	for _, accountID := range accountIDs {
+		pool.GetEventManager().TriggerEvent(event, accountID)
+	}
+
+	return updated, nil
+}
+
+// SetRegion 修改账号的区域，修改前检查账号在目标区域是否已开通
+// 修改成功后触发区域变更事件，账号池更新区域并重置区域使用计数
+func (s *AccountService) SetRegion(ctx context.Context, accountIDs []string, regionCode string) ([]SetRegionResult, error) {
+	accounts, err := model.GetAccountsByIDs(s.repo.DB, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建并发控制
+	var wg sync.WaitGroup
+	// 设置合理的并发数量
+	semaphore := make(chan struct{}, 10) // 最多10个并发
+	resultChan := make(chan SetRegionResult, len(accounts))
+
+	for _, acc := range accounts {
+		wg.Add(1)
+		// 复制一份acc避免闭包问题
+		account := acc
+
+		go func() {
+			defer wg.Done()
+
+			// 获取信号量，控制并发数
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			resultChan <- s.setSingleAccountRegion(ctx, account, regionCode)
+		}()
+	}
+
+	// 等待所有处理完成
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// 收集所有结果
+	results := make([]SetRegionResult, 0, len(accountIDs))
+	found := make(map[string]bool, len(accounts))
+	for result := range resultChan {
+		found[result.AccountID] = true
+		results = append(results, result)
+	}
+
+	// 不存在的账号
+	for _, id := range accountIDs {
+		if !found[id] {
+			results = append(results, SetRegionResult{
+				AccountID: id,
+				Region:    regionCode,
+				Message:   "账号不存在",
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// setSingleAccountRegion 检查区域开通状态后修改单个账号的区域
+func (s *AccountService) setSingleAccountRegion(ctx context.Context, acc model.Account, regionCode string) SetRegionResult {
+	result := SetRegionResult{
+		AccountID: acc.ID,
+		Region:    regionCode,
+	}
+
+	if acc.Region != nil && *acc.Region == regionCode {
+		result.Success = true
+		result.Message = "账号已在该区域"
+		return result
+	}
+
+	awsClient := aws.NewAWSClient(acc.Key1, acc.Key2)
+	status, err := awsClient.CheckRegionStatus(ctx, regionCode)
+	if err != nil {
+		result.Message = fmt.Sprintf("检查区域状态失败: %v", err)
+		return result
+	}
+	if status != "启用" {
+		result.Message = fmt.Sprintf("账号在该区域的状态为%s，无法切换", status)
+		return result
+	}
+
+	if err := model.UpdateAccountRegion(s.repo.DB, acc.ID, regionCode); err != nil {
+		result.Message = fmt.Sprintf("更新账号区域失败: %v", err)
+		return result
+	}
+
+	// 通知账号池更新区域，补机队列也会重新处理等待中的任务
+	pool.GetEventManager().TriggerEvent(pool.RegionChanged, acc.ID)
+
+	result.Success = true
+	log.Printf("账号[%s]区域已修改为[%s]", acc.ID, regionCode)
+	return result
+}
+
+// Revive 重新检测之前失效的账号，检测通过的重新加入账号池
+// 用于在数据库中修正账号key之后，无需重新加载整个账号池
+func (s *AccountService) Revive(ctx context.Context, accountIDs []string) ([]ReviveResult, error) {
+	accounts, err := model.GetAccountsByIDs(s.repo.DB, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 缓存每个用户的实例类型，避免重复查询设置
+	instanceTypes := make(map[string]string)
+	for _, acc := range accounts {
+		if _, exists := instanceTypes[acc.UserID]; exists {
+			continue
+		}
+		instanceTypes[acc.UserID] = ""
+		if setting, err := model.GetSettingByUserID(s.repo.DB, acc.UserID); err == nil {
+			instanceTypes[acc.UserID] = setting.InstanceType
+		}
+	}
+
+	// 创建并发控制
+	var wg sync.WaitGroup
+	// 设置合理的并发数量
+	semaphore := make(chan struct{}, 10) // 最多10个并发
+	resultChan := make(chan ReviveResult, len(accounts))
+
+	for _, acc := range accounts {
+		wg.Add(1)
+		// 复制一份acc避免闭包问题
+		account := acc
+
+		go func() {
+			defer wg.Done()
+
+			// 获取信号量，控制并发数
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			resultChan <- s.reviveSingleAccount(ctx, account, instanceTypes[account.UserID])
+		}()
+	}
+
+	// 等待所有处理完成
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// 收集所有结果
+	results := make([]ReviveResult, 0, len(accountIDs))
+	found := make(map[string]bool, len(accounts))
+	for result := range resultChan {
+		found[result.AccountID] = true
+		results = append(results, result)
+	}
+
+	// 不存在的账号
+	for _, id := range accountIDs {
+		if !found[id] {
+			results = append(results, ReviveResult{
+				AccountID: id,
+				Message:   "账号不存在",
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// reviveSingleAccount 重新检测单个账号，通过后加入账号池
+func (s *AccountService) reviveSingleAccount(ctx context.Context, acc model.Account, instanceType string) ReviveResult {
+	result := ReviveResult{
+		AccountID: acc.ID,
+	}
+
+	// 重新检测，检测结果会写回数据库
+	check := s.checkSingleAccount(ctx, acc, instanceType, true)
+	result.Quota = check.Quota
+	if check.Quota == "账号已失效" || check.Quota == "查询失败" {
+		result.Message = "账号检测未通过"
+		return result
+	}
+
+	// 重新读取更新后的账号信息再加入账号池
+	accounts, err := model.GetAccountsByIDs(s.repo.DB, []string{acc.ID})
+	if err != nil || len(accounts) == 0 {
+		result.Message = fmt.Sprintf("读取账号信息失败: %v", err)
+		return result
+	}
+
+	// AddAccount 对新加入的账号会触发 AccountAdded 事件
+	pool.GetAccountPool().AddAccount(accounts[0])
+	result.Revived = true
+	logger.Printf(ctx, "账号[%s]重新检测通过，已加入账号池", acc.ID)
+	return result
+}
+
+// SelfTestResult 凭证自检结果
+type SelfTestResult struct {
+	Valid   bool              `json:"valid"`             // 凭证是否有效
+	Quota   string            `json:"quota"`             // 标准实例vCPU配额
+	Regions map[string]string `json:"regions"`           // 目标区域代码 -> 开通状态
+	Message string            `json:"message,omitempty"` // 错误信息
+}
+
+// SelfTest 使用给定的key或账号ID检测AWS凭证是否可用，不保存任何数据
+func (s *AccountService) SelfTest(ctx context.Context, userID, accountID, key1, key2 string) (*SelfTestResult, error) {
+	// 提供账号ID时使用账号中保存的key，需校验归属
+	if accountID != "" {
+		accounts, err := model.GetAccountKeysByIDs(s.repo.DB, userID, []string{accountID})
+		if err != nil {
+			return nil, err
+		}
+		if len(accounts) == 0 {
+			return nil, fmt.Errorf("账号不存在或无权操作")
+		}
+		key1, key2 = accounts[0].Key1, accounts[0].Key2
+	}
+	if key1 == "" || key2 == "" {
+		return nil, fmt.Errorf("key1和key2不能为空")
+	}
+
+	awsClient := aws.NewAWSClient(key1, key2)
+	result := &SelfTestResult{
+		Regions: make(map[string]string),
+	}
+
+	// 查询标准配额，同时验证凭证
+	quota, err := awsClient.GetEC2Quota(ctx)
+	if err != nil {
+		result.Message = err.Error()
+		if strings.Contains(err.Error(), "get credentials: failed") ||
+			strings.Contains(err.Error(), "UnrecognizedClientException") ||
+			strings.Contains(err.Error(), "InvalidClientTokenId") {
+			result.Quota = "账号已失效"
+		} else {
+			result.Quota = "查询失败"
+		}
+		return result, nil
+	}
+	result.Quota = quota
+	// 凭证无效时配额查询不返回错误，而是返回"账号已失效"
+	if quota == "账号已失效" {
+		return result, nil
+	}
+	result.Valid = true
+
+	// 并发检查各目标区域的开通状态
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, regionCode := range region.Codes() {
+		wg.Add(1)
+		go func(regionCode string) {
+			defer wg.Done()
+			status, err := awsClient.CheckRegionStatus(ctx, regionCode)
+			if err != nil {
+				status = "查询失败"
+				logger.Printf(ctx, "凭证自检查询区域[%s]状态失败: %v", regionCode, err)
+			}
+			mu.Lock()
+			result.Regions[regionCode] = status
+			mu.Unlock()
+		}(regionCode)
+	}
+	wg.Wait()
+
+	return result, nil
+}
+
+// VMCountResult 实例数量校准结果
+type VMCountResult struct {
+	AccountID string `json:"account_id"`
+	Region    string `json:"region"`
+	OldCount  *int   `json:"old_count"`         // 校准前数据库中的数量
+	NewCount  *int32 `json:"new_count"`         // AWS实际运行中的数量
+	Updated   bool   `json:"updated"`           // 是否已写回数据库
+	Message   string `json:"message,omitempty"` // 错误信息
+}
+
+// ReconcileVMCount 按AWS实际运行数量校准账号的vm_count，accountIDs为空时校准所有有效账号
+func (s *AccountService) ReconcileVMCount(ctx context.Context, accountIDs []string) ([]VMCountResult, error) {
+	var accounts []model.Account
+	var err error
+	if len(accountIDs) == 0 {
+		accounts, err = model.ListAllValidAccounts(s.repo.DB)
+	} else {
+		accounts, err = model.GetAccountsByIDs(s.repo.DB, accountIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建并发控制
+	var wg sync.WaitGroup
+	// 设置合理的并发数量
+	semaphore := make(chan struct{}, 10) // 最多10个并发
+	resultChan := make(chan VMCountResult, len(accounts))
+
+	for _, acc := range accounts {
+		wg.Add(1)
+		// 复制一份acc避免闭包问题
+		account := acc
+
+		go func() {
+			defer wg.Done()
+
+			// 获取信号量，控制并发数
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			regionCode := account.RegionCode()
+
+			result := VMCountResult{
+				AccountID: account.ID,
+				Region:    regionCode,
+				OldCount:  account.VMCount,
+			}
+
+			awsClient := aws.NewAWSClient(account.Key1, account.Key2)
+			count, err := awsClient.GetRunningInstanceCount(ctx, regionCode)
+			if err != nil {
+				result.Message = "查询实例数量失败: " + err.Error()
+				resultChan <- result
+				return
+			}
+			result.NewCount = &count
+
+			// 数量和统计区域都没有变化时不写数据库
+			if account.VMCount != nil && int32(*account.VMCount) == count &&
+				account.VMCountRegion != nil && *account.VMCountRegion == regionCode {
+				resultChan <- result
+				return
+			}
+
+			if err := model.UpdateAccountVMCount(s.repo.DB, account.ID, count, regionCode); err != nil {
+				result.Message = "更新实例数量失败: " + err.Error()
+			} else {
+				result.Updated = true
+			}
+			resultChan <- result
+		}()
+	}
+
+	// 等待所有处理完成
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// 收集所有结果
+	results := make([]VMCountResult, 0, len(accounts))
+	for result := range resultChan {
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// StartVMCountReconcileJob 启动定时校准vm_count的任务
+// 间隔由环境变量 VM_COUNT_RECONCILE_INTERVAL 配置（如 30m），未设置或为0时不启动
+func StartVMCountReconcileJob(db *gorm.DB) {
+	value := os.Getenv("VM_COUNT_RECONCILE_INTERVAL")
+	if value == "" {
+		return
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		log.Printf("VM_COUNT_RECONCILE_INTERVAL 配置无效[%s]，不启动实例数量定时校准", value)
+		return
+	}
+
+	log.Printf("启动实例数量定时校准任务，间隔: %v", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			results, err := NewAccountService(db).ReconcileVMCount(context.Background(), nil)
+			if err != nil {
+				log.Printf("实例数量定时校准失败: %v", err)
+				continue
+			}
+			updated := 0
+			for _, result := range results {
+				if result.Updated {
+					updated++
+				}
+			}
+			log.Printf("实例数量定时校准完成，检查%d个账号，更新%d个", len(results), updated)
+		}
+	}()
+}
+
 // ApplyHKResult 申请HK区结果
 type ApplyHKResult struct {
 	AccountID string `json:"account_id"`
@@ -211,14 +934,9 @@ func (s *AccountService) ApplyHK(ctx context.Context, userID string, accountIDs
 			AccountID: acc.ID,
 		}
 
-		// 获取区域代码
-		regionCode := "ap-east-1" // 默认香港
-		if acc.Region != nil && *acc.Region != "" {
-			regionCode = *acc.Region
-		}
-
 		// 只有香港区域需要申请开通，其他区域直接跳过
-		if regionCode != "ap-east-1" {
+		regionCode := acc.RegionCode()
+		if regionCode != region.HK {
 			result.Status = "成功"
 			result.Message = "非香港区账号，无需申请开通"
 			results = append(results, result)
@@ -316,6 +1034,33 @@ func (s *AccountService) CleanMicroInstances(ctx context.Context, userID string,
 		return nil, err
 	}
 
+	return s.cleanMicroForAccounts(ctx, accounts), nil
+}
+
+// CleanAllMicroInstances 清理用户所有有效账号中的t2.micro和t3.micro实例，allUsers为true时清理全部用户的账号
+func (s *AccountService) CleanAllMicroInstances(ctx context.Context, userID string, allUsers bool) (*CleanMicroSummary, error) {
+	var accounts []model.Account
+	var err error
+	if allUsers {
+		accounts, err = model.ListAllValidAccounts(s.repo.DB)
+	} else {
+		accounts, err = model.ListValidAccounts(s.repo.DB, userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取有效账号列表失败: %v", err)
+	}
+
+	if allUsers {
+		log.Printf("开始清理全部用户%d个有效账号中的micro实例", len(accounts))
+	} else {
+		log.Printf("开始清理用户[%s]的%d个有效账号中的micro实例", userID, len(accounts))
+	}
+
+	return s.cleanMicroForAccounts(ctx, accounts), nil
+}
+
+// cleanMicroForAccounts 并发清理多个账号中的micro实例并汇总结果
+func (s *AccountService) cleanMicroForAccounts(ctx context.Context, accounts []model.Account) *CleanMicroSummary {
 	// 创建并发控制
 	var wg sync.WaitGroup
 	// 设置合理的并发数量
@@ -370,7 +1115,7 @@ func (s *AccountService) CleanMicroInstances(ctx context.Context, userID string,
 		}
 	}
 
-	return summary, nil
+	return summary
 }
 
 // cleanMicroForAccount 清理单个账号内的t2.micro和t3.micro实例
@@ -386,10 +1131,7 @@ func (s *AccountService) cleanMicroForAccount(ctx context.Context, acc model.Acc
 	}
 
 	// 获取区域代码
-	regionCode := "ap-east-1" // 默认香港
-	if acc.Region != nil && *acc.Region != "" {
-		regionCode = *acc.Region
-	}
+	regionCode := acc.RegionCode()
 
 	// 初始化AWS客户端
 	awsClient := aws.NewAWSClient(acc.Key1, acc.Key2)
@@ -436,7 +1178,7 @@ func (s *AccountService) cleanMicroForAccount(ctx context.Context, acc model.Acc
 			InstanceID: instance.InstanceID,
 		}
 
-		if err := awsClient.DeleteInstance(ctx, params); err != nil {
+		if _, err := awsClient.DeleteInstance(ctx, params); err != nil {
 			deleteErrors = append(deleteErrors, fmt.Sprintf("实例%s删除失败: %s", instance.InstanceID, err.Error()))
 		} else {
 			result.Deleted++
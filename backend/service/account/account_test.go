@@ -0,0 +1,555 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"portal/model"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+	"portal/repository"
+
+	"gorm.io/gorm"
+)
+
+// fakeAWS 模拟账号检测用到的AWS接口，各字段均以请求签名中的AccessKey为键
+type fakeAWS struct {
+	statuses  map[string]string   // GetRegionOptStatus返回的区域状态，以 "error:" 开头的值作为错误类型返回，凭证错误时配额查询同样返回该错误
+	quotas    map[string]int      // GetServiceQuota返回的配额
+	instances map[string][]string // DescribeInstances返回的实例类型列表
+
+	quotaFailures map[string]int             // 配额查询前N次返回临时错误
+	onTerminate   func(accessKey, id string) // 终止实例时调用，可用于检查终止时的数据库状态
+
+	mu         sync.Mutex
+	terminated map[string][]string  // TerminateInstances终止的实例ID
+	requested  map[string]time.Time // 每个AccessKey的首次请求时间
+}
+
+// start 启动模拟服务并让SDK请求指向它
+func (f *fakeAWS) start(t *testing.T) {
+	t.Helper()
+	statuses, quotas := f.statuses, f.quotas
+	f.terminated = make(map[string][]string)
+	f.requested = make(map[string]time.Time)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessKey := ""
+		if _, rest, ok := strings.Cut(r.Header.Get("Authorization"), "Credential="); ok {
+			accessKey, _, _ = strings.Cut(rest, "/")
+		}
+		f.mu.Lock()
+		if _, seen := f.requested[accessKey]; !seen {
+			f.requested[accessKey] = time.Now()
+		}
+		f.mu.Unlock()
+
+		status := statuses[accessKey]
+		errType, failed := strings.CutPrefix(status, "error:")
+
+		switch {
+		case strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".GetServiceQuota"):
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			f.mu.Lock()
+			transient := f.quotaFailures[accessKey] > 0
+			if transient {
+				f.quotaFailures[accessKey]--
+			}
+			f.mu.Unlock()
+			if transient {
+				// SDK不会自动重试该错误，由检测逻辑按临时错误处理
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"__type":"ServiceException","message":"Read timeout on endpoint"}`)
+				return
+			}
+			if failed && errType == "UnrecognizedClientException" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"__type":"%s","message":"%s"}`, errType, errType)
+				return
+			}
+			fmt.Fprintf(w, `{"Quota":{"Value":%d}}`, quotas[accessKey])
+		case r.URL.Path == "/getRegionOptStatus":
+			var input struct{ RegionName string }
+			_ = json.NewDecoder(r.Body).Decode(&input)
+			w.Header().Set("Content-Type", "application/json")
+			if failed {
+				w.Header().Set("X-Amzn-ErrorType", errType)
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprintf(w, `{"message":"%s"}`, errType)
+				return
+			}
+			fmt.Fprintf(w, `{"RegionName":"%s","RegionOptStatus":"%s"}`, input.RegionName, status)
+		default:
+			_ = r.ParseForm()
+			action := r.PostForm.Get("Action")
+			var body strings.Builder
+			switch action {
+			case "DescribeInstances":
+				body.WriteString(`<reservationSet><item><instancesSet>`)
+				for i, instanceType := range f.instances[accessKey] {
+					fmt.Fprintf(&body, `<item><instanceId>i-%s-%d</instanceId><instanceType>%s</instanceType><instanceState><name>running</name></instanceState><launchTime>2026-01-01T00:00:00Z</launchTime></item>`, accessKey, i, instanceType)
+				}
+				body.WriteString(`</instancesSet></item></reservationSet>`)
+			case "TerminateInstances":
+				f.mu.Lock()
+				f.terminated[accessKey] = append(f.terminated[accessKey], r.PostForm.Get("InstanceId.1"))
+				f.mu.Unlock()
+				if f.onTerminate != nil {
+					f.onTerminate(accessKey, r.PostForm.Get("InstanceId.1"))
+				}
+			}
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId>%s</%sResponse>`, action, body.String(), action)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+}
+
+func TestCheckRegionStatusesMixed(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	for _, acc := range []model.Account{
+		{ID: "9301", UserID: "u1", Key1: "AKIAENABLED", Key2: "secret"},
+		{ID: "9302", UserID: "u2", Key1: "AKIAENABLING", Key2: "secret"},
+		{ID: "9303", UserID: "u1", Key1: "AKIADISABLED", Key2: "secret"},
+		{ID: "9304", UserID: "u3", Key1: "AKIAINVALID", Key2: "secret"},
+	} {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+	}
+	(&fakeAWS{statuses: map[string]string{
+		"AKIAENABLED":  "ENABLED_BY_DEFAULT",
+		"AKIAENABLING": "ENABLING",
+		"AKIADISABLED": "DISABLED",
+		"AKIAINVALID":  "error:UnrecognizedClientException",
+	}}).start(t)
+
+	s := NewAccountService(db)
+	results, err := s.CheckRegionStatuses(context.Background(), []string{"9301", "9302", "9303", "9304", "9399"}, "ap-east-1")
+	if err != nil {
+		t.Fatalf("查询区域状态失败: %v", err)
+	}
+
+	want := map[string]string{
+		"9301": "启用",
+		"9302": "启用中",
+		"9303": "未启用",
+		"9304": "失效",
+		"9399": "失效",
+	}
+	if len(results) != len(want) {
+		t.Fatalf("返回%d条结果, want %d: %+v", len(results), len(want), results)
+	}
+	for _, result := range results {
+		if result.Status != want[result.AccountID] {
+			t.Errorf("账号[%s]状态 = %s（%s）, want %s", result.AccountID, result.Status, result.Message, want[result.AccountID])
+		}
+		if result.Region != "ap-east-1" {
+			t.Errorf("账号[%s]区域 = %s, want ap-east-1", result.AccountID, result.Region)
+		}
+	}
+}
+
+func TestReviveAddsAccountBackToPool(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	const accountID = "9311"
+	invalid := "账号已失效"
+	jp := region.JP
+	seeds := []any{
+		&model.Setting{UserID: "revive-user", Region: "日本", InstanceType: "t3.micro", DiskSize: 20},
+		&model.Account{ID: accountID, UserID: "revive-user", Key1: "AKIAREVIVED", Key2: "secret", Region: &jp, Quatos: &invalid},
+	}
+	for _, seed := range seeds {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(seed).Error; err != nil {
+			t.Fatalf("写入种子数据失败: %v", err)
+		}
+	}
+	// 账号修复后key重新可用
+	(&fakeAWS{quotas: map[string]int{"AKIAREVIVED": 32}}).start(t)
+
+	accountPool := pool.GetAccountPool()
+	t.Cleanup(func() { accountPool.RemoveAccount(accountID) })
+	if _, exists := accountPool.InspectAccount(accountID, "", ""); exists {
+		t.Fatal("失效账号不应在账号池中")
+	}
+
+	results, err := NewAccountService(db).Revive(context.Background(), []string{accountID})
+	if err != nil {
+		t.Fatalf("恢复账号失败: %v", err)
+	}
+	if len(results) != 1 || !results[0].Revived || results[0].Quota != "32" {
+		t.Fatalf("恢复结果 = %+v, 期望重新检测通过并加入账号池", results)
+	}
+
+	state, exists := accountPool.InspectAccount(accountID, "t3.micro", jp)
+	if !exists || state.Selectable == nil || !*state.Selectable {
+		t.Fatalf("恢复后的账号状态 = %+v（存在=%v）, 期望可被选用", state, exists)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	(&fakeAWS{
+		statuses: map[string]string{
+			"AKIAGOOD": "ENABLED",
+			"AKIABAD":  "error:UnrecognizedClientException",
+		},
+		quotas: map[string]int{"AKIAGOOD": 64},
+	}).start(t)
+	s := NewAccountService(db)
+
+	good, err := s.SelfTest(context.Background(), "selftest-user", "", "AKIAGOOD", "secret")
+	if err != nil {
+		t.Fatalf("有效凭证自检失败: %v", err)
+	}
+	if !good.Valid || good.Quota != "64" {
+		t.Fatalf("有效凭证自检结果 = %+v, 期望有效且配额为64", good)
+	}
+	for _, code := range region.Codes() {
+		if good.Regions[code] != "启用" {
+			t.Errorf("区域[%s]状态 = %q, want 启用", code, good.Regions[code])
+		}
+	}
+
+	bad, err := s.SelfTest(context.Background(), "selftest-user", "", "AKIABAD", "secret")
+	if err != nil {
+		t.Fatalf("无效凭证自检失败: %v", err)
+	}
+	if bad.Valid || bad.Quota != "账号已失效" || len(bad.Regions) != 0 {
+		t.Fatalf("无效凭证自检结果 = %+v, 期望无效且不查询区域", bad)
+	}
+
+	if _, err := s.SelfTest(context.Background(), "selftest-user", "", "", ""); err == nil {
+		t.Fatal("未提供key时应返回错误")
+	}
+}
+
+func TestReconcileVMCountCorrectsStaleCount(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	hk := region.HK
+	stale, current := 5, 2
+	for _, acc := range []model.Account{
+		{ID: "9321", UserID: "u1", Key1: "AKIASTALE", Key2: "secret", Region: &hk, VMCount: &stale, VMCountRegion: &hk},
+		{ID: "9322", UserID: "u1", Key1: "AKIACURRENT", Key2: "secret", Region: &hk, VMCount: &current, VMCountRegion: &hk},
+	} {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+	}
+	(&fakeAWS{instances: map[string][]string{
+		"AKIASTALE":   {"t3.micro"},
+		"AKIACURRENT": {"t3.micro", "t3.micro"},
+	}}).start(t)
+
+	results, err := NewAccountService(db).ReconcileVMCount(context.Background(), []string{"9321", "9322"})
+	if err != nil {
+		t.Fatalf("校准实例数量失败: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("校准结果 = %+v, want 2条", results)
+	}
+	for _, result := range results {
+		wantUpdated := result.AccountID == "9321"
+		if result.Updated != wantUpdated || result.NewCount == nil {
+			t.Errorf("账号[%s]校准结果 = %+v, 期望更新=%v", result.AccountID, result, wantUpdated)
+		}
+	}
+
+	accounts, err := model.GetAccountsByIDs(db, []string{"9321", "9322"})
+	if err != nil {
+		t.Fatalf("读取账号失败: %v", err)
+	}
+	want := map[string]int{"9321": 1, "9322": 2}
+	for _, acc := range accounts {
+		if acc.VMCount == nil || *acc.VMCount != want[acc.ID] {
+			t.Errorf("账号[%s]的vm_count = %v, want %d", acc.ID, acc.VMCount, want[acc.ID])
+		}
+	}
+}
+
+func TestCleanAllMicroInstancesDiscoversAccounts(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	invalid := "账号已失效"
+	for _, acc := range []model.Account{
+		{ID: "9331", UserID: "clean-user", Key1: "AKIACLEAN1", Key2: "secret"},
+		{ID: "9332", UserID: "clean-user", Key1: "AKIACLEAN2", Key2: "secret"},
+		{ID: "9333", UserID: "clean-user", Key1: "AKIACLEAN3", Key2: "secret", Quatos: &invalid},
+		{ID: "9334", UserID: "other-user", Key1: "AKIAOTHER", Key2: "secret"},
+	} {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+	}
+	fake := &fakeAWS{instances: map[string][]string{
+		"AKIACLEAN1": {"t2.micro", "c5.large"},
+		"AKIACLEAN2": {"t3.micro", "t3.micro"},
+		"AKIACLEAN3": {"t3.micro"},
+		"AKIAOTHER":  {"t3.micro"},
+	}}
+	fake.start(t)
+
+	summary, err := NewAccountService(db).CleanAllMicroInstances(context.Background(), "clean-user", false)
+	if err != nil {
+		t.Fatalf("清理micro实例失败: %v", err)
+	}
+
+	processed := make(map[string]bool)
+	for _, result := range summary.AccountResults {
+		processed[result.AccountID] = true
+	}
+	if len(processed) != 2 || !processed["9331"] || !processed["9332"] {
+		t.Fatalf("处理的账号 = %v, 期望只处理用户的有效账号9331和9332", processed)
+	}
+	if summary.TotalFound != 3 || summary.TotalDeleted != 3 || summary.T2TotalDeleted != 1 || summary.T3TotalDeleted != 2 || summary.SuccessCount != 2 {
+		t.Fatalf("清理汇总 = %+v, 期望找到并删除3台micro实例", summary)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.terminated["AKIACLEAN1"]) != 1 || len(fake.terminated["AKIACLEAN2"]) != 2 {
+		t.Fatalf("终止的实例 = %v", fake.terminated)
+	}
+	if len(fake.terminated["AKIACLEAN3"]) != 0 || len(fake.terminated["AKIAOTHER"]) != 0 {
+		t.Fatalf("失效账号和其他用户的实例不应被清理: %v", fake.terminated)
+	}
+}
+
+func TestSetRegionUpdatesPoolSelection(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	hk, jp := region.HK, region.JP
+	accounts := []model.Account{
+		{ID: "9351", UserID: "set-region-user", Key1: "AKIAMOVED", Key2: "secret", Region: &hk},
+		{ID: "9352", UserID: "set-region-user", Key1: "AKIADISABLEDJP", Key2: "secret", Region: &hk},
+	}
+	accountPool := pool.GetAccountPool()
+	for _, acc := range accounts {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+		accountPool.AddAccount(acc)
+		t.Cleanup(func() { accountPool.RemoveAccount(acc.ID) })
+	}
+	// 原区域的失败状态在切换后应被清除
+	accountPool.MarkAccountFailed("9351", "香港区域开机失败")
+	(&fakeAWS{statuses: map[string]string{
+		"AKIAMOVED":      "ENABLED",
+		"AKIADISABLEDJP": "DISABLED",
+	}}).start(t)
+
+	results, err := NewAccountService(db).SetRegion(context.Background(), []string{"9351", "9352"}, jp)
+	if err != nil {
+		t.Fatalf("修改账号区域失败: %v", err)
+	}
+	success := make(map[string]bool)
+	for _, result := range results {
+		success[result.AccountID] = result.Success
+	}
+	if !success["9351"] || success["9352"] {
+		t.Fatalf("修改结果 = %+v, 期望只有9351修改成功", results)
+	}
+
+	stored, err := model.GetAccountsByIDs(db, []string{"9351", "9352"})
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("读取账号失败: %v", err)
+	}
+	for _, acc := range stored {
+		want := hk
+		if acc.ID == "9351" {
+			want = jp
+		}
+		if acc.Region == nil || *acc.Region != want {
+			t.Errorf("账号%s数据库区域 = %v, want %s", acc.ID, acc.Region, want)
+		}
+	}
+
+	state, exists := accountPool.InspectAccount("9351", "t3.micro", jp)
+	if !exists || state.Region != jp || state.Selectable == nil || !*state.Selectable {
+		t.Fatalf("切换后账号在新区域的状态 = %+v（存在=%v）, 期望可被选用", state, exists)
+	}
+	if state, _ := accountPool.InspectAccount("9351", "t3.micro", hk); state.Selectable == nil || *state.Selectable {
+		t.Fatalf("切换后账号不应在原区域被选用: %+v", state)
+	}
+	if state, _ := accountPool.InspectAccount("9352", "t3.micro", hk); state.Region != hk || state.Selectable == nil || !*state.Selectable {
+		t.Fatalf("未开通目标区域的账号应保留原区域: %+v", state)
+	}
+}
+
+// eligibleInPool 账号是否在指定区域的可选账号中
+func eligibleInPool(accountID string, regionCode string) bool {
+	eligible, _ := pool.GetAccountPool().PreviewEligibleAccounts("t3.micro", regionCode, 1)
+	for _, acc := range eligible {
+		if acc.AccountID == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSetEnabledTogglesPoolSelection(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	hk := region.HK
+	acc := model.Account{ID: "9391", UserID: "set-enabled-user", Key1: "AKIATOGGLE", Key2: "secret", Region: &hk}
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+		t.Fatalf("写入账号失败: %v", err)
+	}
+	accountPool := pool.GetAccountPool()
+	accountPool.AddAccount(acc)
+	t.Cleanup(func() { accountPool.RemoveAccount(acc.ID) })
+	if !eligibleInPool(acc.ID, hk) {
+		t.Fatal("启用的账号应可被选用")
+	}
+
+	service := NewAccountService(db)
+	if _, err := service.SetEnabled([]string{acc.ID}, false); err != nil {
+		t.Fatalf("停用账号失败: %v", err)
+	}
+	if eligibleInPool(acc.ID, hk) {
+		t.Fatal("停用的账号不应被选用")
+	}
+	stored, err := model.GetAccountsByIDs(db, []string{acc.ID})
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("停用的账号应保留在数据库中: %v", err)
+	}
+	if stored[0].IsEnabled() {
+		t.Fatal("数据库中的账号应标记为停用")
+	}
+
+	if _, err := service.SetEnabled([]string{acc.ID}, true); err != nil {
+		t.Fatalf("启用账号失败: %v", err)
+	}
+	if !eligibleInPool(acc.ID, hk) {
+		t.Fatal("重新启用的账号应恢复可选")
+	}
+}
+
+func TestCheckRetriesTransientQuotaError(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	jp := region.JP
+	acc := model.Account{ID: "9381", UserID: "transient-user", Key1: "AKIATRANSIENT", Key2: "secret", Region: &jp}
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+		t.Fatalf("写入账号失败: %v", err)
+	}
+	fake := &fakeAWS{
+		quotas:        map[string]int{"AKIATRANSIENT": 32},
+		instances:     map[string][]string{"AKIATRANSIENT": {"t3.micro"}},
+		quotaFailures: map[string]int{"AKIATRANSIENT": 1},
+	}
+	fake.start(t)
+	oldInterval := checkRetryInterval
+	checkRetryInterval = 0
+	t.Cleanup(func() { checkRetryInterval = oldInterval })
+
+	results, err := NewAccountService(db).Check(context.Background(), "transient-user", []string{"9381"})
+	if err != nil {
+		t.Fatalf("检测账号失败: %v", err)
+	}
+	if len(results) != 1 || results[0].Quota != "32" || results[0].Transient {
+		t.Fatalf("检测结果 = %+v, 期望临时错误重试后返回正常配额", results)
+	}
+	if fake.quotaFailures["AKIATRANSIENT"] != 0 {
+		t.Fatal("配额查询未遇到临时错误，测试没有覆盖重试")
+	}
+
+	stored, err := model.GetAccountsByIDs(db, []string{"9381"})
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("读取账号失败: %v", err)
+	}
+	if stored[0].Quatos == nil || *stored[0].Quatos != "32" {
+		t.Fatalf("数据库中的配额 = %v, 期望为32而不是查询失败", stored[0].Quatos)
+	}
+}
+
+func TestCheckRecordsVMCountRegion(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	jp := region.JP
+	acc := model.Account{ID: "9392", UserID: "count-region-user", Key1: "AKIACOUNTJP", Key2: "secret", Region: &jp}
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+		t.Fatalf("写入账号失败: %v", err)
+	}
+	(&fakeAWS{
+		quotas:    map[string]int{"AKIACOUNTJP": 32},
+		instances: map[string][]string{"AKIACOUNTJP": {"t3.micro", "t3.micro"}},
+	}).start(t)
+
+	results, err := NewAccountService(db).Check(context.Background(), "count-region-user", []string{"9392"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("检测账号 = %+v, %v", results, err)
+	}
+	result := results[0]
+	if result.VMCount == nil || *result.VMCount != 2 || result.VMCountRegion != jp {
+		t.Fatalf("检测结果的实例数量 = %v（区域%q）, 期望日本区2台", result.VMCount, result.VMCountRegion)
+	}
+
+	stored, err := model.GetAccountsByIDs(db, []string{"9392"})
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("读取账号失败: %v", err)
+	}
+	if stored[0].VMCount == nil || *stored[0].VMCount != 2 || stored[0].VMCountRegion == nil || *stored[0].VMCountRegion != jp {
+		t.Fatalf("数据库中的实例数量 = %v（区域%v）, 期望日本区2台", stored[0].VMCount, stored[0].VMCountRegion)
+	}
+}
+
+func TestDeleteWithInstancesTerminatesBeforeRemoving(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	const userID = "delete-terminate-user"
+	jp := region.JP
+	acc := model.Account{ID: "9491", UserID: userID, Key1: "AKIADELETE", Key2: "secret", Region: &jp}
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+		t.Fatalf("写入账号失败: %v", err)
+	}
+
+	// 终止实例时账号应仍在数据库中
+	var removedEarly atomic.Bool
+	fake := &fakeAWS{
+		instances: map[string][]string{"AKIADELETE": {"t3.micro", "c5n.large"}},
+		onTerminate: func(accessKey, id string) {
+			if stored, err := model.GetAccountsByIDs(db, []string{"9491"}); err != nil || len(stored) != 1 {
+				removedEarly.Store(true)
+			}
+		},
+	}
+	fake.start(t)
+
+	terminated, err := NewAccountService(db).DeleteWithInstances(context.Background(), userID, []string{"9491"})
+	if err != nil {
+		t.Fatalf("删除账号失败: %v", err)
+	}
+	if len(terminated) != 2 {
+		t.Fatalf("终止结果 = %+v, 期望2台实例", terminated)
+	}
+	for _, result := range terminated {
+		if result.Status != "成功" || result.Region != jp {
+			t.Errorf("终止结果 = %+v, 期望在日本区终止成功", result)
+		}
+	}
+	if got := fake.terminated["AKIADELETE"]; len(got) != 2 {
+		t.Fatalf("终止的实例 = %v, 期望账号的2台实例", got)
+	}
+	if removedEarly.Load() {
+		t.Fatal("终止实例前账号已被删除")
+	}
+
+	stored, err := model.GetAccountsByIDs(db, []string{"9491"})
+	if err != nil || len(stored) != 0 {
+		t.Fatalf("实例终止后账号应被删除, 剩余 %+v (%v)", stored, err)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"portal/model"
 	"portal/pkg/aws"
+	"portal/pkg/logger"
+	"portal/pkg/pool"
 	"sync"
 )
 
@@ -15,44 +17,13 @@ type CreateInstanceResult struct {
 	Status    string                     `json:"status"`    // 成功/失败
 	Message   string                     `json:"message"`   // 错误信息
 	Instances []aws.CreateInstanceResult `json:"instances"` // 成功创建的实例信息
-}
-
-// getAMIForRegion 根据区域获取对应的AMI ID
-func getAMIForRegion(regionCode string) string {
-	amiMap := map[string]string{
-		"ap-east-1":      "ami-06dd48f3dbcc241f3", // 香港区域
-		"ap-northeast-3": "ami-0eed40102c8eb6998", // 日本区域
-		"ap-southeast-1": "ami-0acbb557db23991cc", // 新加坡区域
-	}
-
-	if ami, exists := amiMap[regionCode]; exists {
-		return ami
-	}
-
-	// 默认返回香港区域的AMI
-	return "ami-06dd48f3dbcc241f3"
-}
-
-// getScriptForRegion 根据区域获取对应的启动脚本
-func getScriptForRegion(setting *model.Setting, regionCode string) string {
-	// 根据区域选择对应的脚本
-	switch regionCode {
-	case "ap-northeast-3": // 日本区域
-		if setting.JpScript != "" {
-			return setting.JpScript
-		}
-	case "ap-southeast-1": // 新加坡区域
-		if setting.SgScript != "" {
-			return setting.SgScript
-		}
-	}
-
-	// 默认使用通用脚本
-	return setting.Script
+	Requested int32                      `json:"requested"` // 请求创建的数量
+	Launched  int                        `json:"launched"`  // 实际启动的数量，允许部分成功时可能少于请求数量
 }
 
 // CreateInstance 批量创建实例
-func (s *AccountService) CreateInstance(ctx context.Context, userID string, accountIDs []string, region string, count int32) ([]CreateInstanceResult, error) {
+// allowPartial为true时容量不足也尽可能多地启动，结果中标明实际启动数量和原因
+func (s *AccountService) CreateInstance(ctx context.Context, userID string, accountIDs []string, region string, count int32, allowPartial bool) ([]CreateInstanceResult, error) {
 	// 验证账号归属权
 	if err := model.VerifyAccountOwnership(s.repo.DB, userID, accountIDs); err != nil {
 		return nil, err
@@ -70,11 +41,40 @@ func (s *AccountService) CreateInstance(ctx context.Context, userID string, acco
 		return nil, fmt.Errorf("获取用户设置失败: %v", err)
 	}
 
+	// 解析附加数据盘配置
+	additionalVolumes, err := aws.ParseVolumeSpecs(setting.AdditionalVolumes)
+	if err != nil {
+		return nil, err
+	}
+
+	// 明确指定区域时，先检查用户是否允许在该区域开机
+	if region != "" {
+		if err := setting.CheckRegionAllowed(region); err != nil {
+			return nil, err
+		}
+	}
+
 	// 如果未指定数量，默认为1
 	if count <= 0 {
 		count = 1
 	}
 
+	// 检查全局实例上限，每个账号都会启动count台
+	if err := pool.CheckGlobalInstanceLimit(int(count) * len(accounts)); err != nil {
+		return nil, err
+	}
+
+	// 锁定涉及的所有区域，与该用户的自动补机互斥，避免同时开机超出阈值
+	regionCodes := make([]string, 0, len(accounts))
+	for _, acc := range accounts {
+		regionCodes = append(regionCodes, resolveCreateRegion(acc, region, setting))
+	}
+	unlock := pool.LockUserRegions(userID, regionCodes...)
+	defer unlock()
+
+	// 在锁内按阈值余量收紧每个账号的开机数量，避免与自动补机一起超出阈值
+	headroom := thresholdHeadroom(userID, regionCodes)
+
 	var (
 		results []CreateInstanceResult
 		wg      sync.WaitGroup
@@ -82,77 +82,41 @@ func (s *AccountService) CreateInstance(ctx context.Context, userID string, acco
 	)
 
 	// 对每个账号并发执行创建操作
-	for _, acc := range accounts {
-		wg.Add(1)
-		go func(acc model.Account) {
-			defer wg.Done()
-
-			result := CreateInstanceResult{
-				AccountID: acc.ID,
-				Status:    "失败", // 默认状态为失败，只有成功执行才会改变
-			}
-
-			// 确定使用的区域代码
-			var regionCode string
-			if region != "" {
-				// 如果请求指定了区域，使用请求的区域
-				regionCode = region
-			} else if acc.Region != nil && *acc.Region != "" {
-				// 否则使用账号的区域
-				regionCode = *acc.Region
-			} else {
-				// 如果账号没有区域，使用用户设置的区域代码
-				regionCode = setting.GetRegionCode()
-			}
-
-			// 验证账号和区域匹配
-			if acc.Region != nil && *acc.Region != "" && *acc.Region != regionCode {
-				result.Message = fmt.Sprintf("账号[%s]区域为[%s]，与请求区域[%s]不匹配", acc.ID, *acc.Region, regionCode)
-
-				// 线程安全地添加结果
-				mu.Lock()
-				results = append(results, result)
-				mu.Unlock()
-				return
+	for i, acc := range accounts {
+		accCount := count
+		if room, ok := headroom[regionCodes[i]]; ok {
+			if int(accCount) > room {
+				accCount = int32(room)
 			}
+			headroom[regionCodes[i]] -= int(accCount)
+		}
 
-			// 初始化AWS客户端
-			awsClient := aws.NewAWSClient(acc.Key1, acc.Key2)
-
-			// 获取区域对应的AMI
-			amiID := getAMIForRegion(regionCode)
-
-			// 获取区域对应的脚本
-			script := getScriptForRegion(setting, regionCode)
-
-			// 准备创建实例的参数
-			params := aws.CreateInstanceParams{
-				Region:       regionCode,              // 使用确定的区域代码
-				ImageID:      amiID,                   // 根据区域获取对应的AMI
-				InstanceType: setting.InstanceType,    // 从设置获取
-				DiskSize:     int32(setting.DiskSize), // 从设置获取
-				Password:     setting.Password,        // 从设置获取
-				Count:        count,                   // 从请求参数获取
-				Script:       script,                  // 根据区域获取对应的脚本
-				UserID:       userID,                  // 用于标签
-				AccountID:    acc.ID,                  // 用于标签
-			}
+		wg.Add(1)
+		go func(acc model.Account, regionCode string, accCount int32) {
+			defer wg.Done()
 
-			// 执行创建操作
-			instances, err := awsClient.CreateInstance(ctx, params)
-			if err != nil {
-				result.Status = "失败"
-				result.Message = err.Error()
+			var result CreateInstanceResult
+			if accCount == 0 {
+				result = CreateInstanceResult{
+					AccountID: acc.ID,
+					Status:    "失败",
+					Requested: count,
+					Message:   fmt.Sprintf("区域[%s]的实例数已达到监控阈值，跳过开机", regionCode),
+				}
 			} else {
-				result.Status = "成功"
-				result.Instances = instances
+				result = s.createOnAccount(ctx, userID, acc, region, setting, additionalVolumes, accCount, allowPartial)
+				if accCount < count && result.Launched > 0 {
+					result.Requested = count
+					result.Status = "部分成功"
+					result.Message = fmt.Sprintf("区域[%s]的实例数已达到监控阈值，仅启动了%d/%d台", regionCode, result.Launched, count)
+				}
 			}
 
 			// 线程安全地添加结果
 			mu.Lock()
 			results = append(results, result)
 			mu.Unlock()
-		}(acc)
+		}(acc, regionCodes[i], accCount)
 	}
 
 	// 等待所有创建操作完成
@@ -160,3 +124,122 @@ func (s *AccountService) CreateInstance(ctx context.Context, userID string, acco
 
 	return results, nil
 }
+
+// createOnAccount 在单个账号上创建count台实例
+func (s *AccountService) createOnAccount(ctx context.Context, userID string, acc model.Account, region string, setting *model.Setting, additionalVolumes []aws.VolumeSpec, count int32, allowPartial bool) CreateInstanceResult {
+	result := CreateInstanceResult{
+		AccountID: acc.ID,
+		Status:    "失败", // 默认状态为失败，只有成功执行才会改变
+		Requested: count,
+	}
+
+	// 确定使用的区域代码
+	regionCode := resolveCreateRegion(acc, region, setting)
+
+	// 验证账号和区域匹配
+	if acc.Region != nil && *acc.Region != "" && *acc.Region != regionCode {
+		result.Message = fmt.Sprintf("账号[%s]区域为[%s]，与请求区域[%s]不匹配", acc.ID, *acc.Region, regionCode)
+		return result
+	}
+
+	// 验证用户是否允许在该区域开机
+	if err := setting.CheckRegionAllowed(regionCode); err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	// 初始化AWS客户端
+	awsClient := aws.NewAWSClient(acc.Key1, acc.Key2)
+
+	// 获取区域对应的AMI，严格模式下未知区域直接失败
+	amiID, err := aws.ResolveAMI(regionCode)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	// 获取区域对应的脚本和密码
+	script := setting.GetScriptForRegion(regionCode)
+	password := setting.GetPasswordForRegion(regionCode)
+
+	// 准备创建实例的参数
+	params := aws.CreateInstanceParams{
+		Region:            regionCode,              // 使用确定的区域代码
+		ImageID:           amiID,                   // 根据区域获取对应的AMI
+		InstanceType:      setting.InstanceType,    // 从设置获取
+		DiskSize:          int32(setting.DiskSize), // 从设置获取
+		Password:          password,                // 根据区域获取的密码
+		Count:             count,                   // 从请求参数获取
+		Script:            script,                  // 根据区域获取对应的脚本
+		UserID:            userID,                  // 用于标签
+		AccountID:         acc.ID,                  // 用于标签
+		SkipSSHPassword:   setting.SkipSSHPassword, // 是否跳过SSH密码配置
+		AdditionalVolumes: additionalVolumes,       // 附加数据盘
+		Tags:              map[string]string{aws.TagSource: aws.SourceManual},
+		AllowPartial:      allowPartial, // 是否允许部分成功
+	}
+
+	// 执行创建操作
+	instances, err := pool.LaunchInstances(ctx, awsClient, params)
+	if err != nil {
+		result.Status = "失败"
+		result.Message = err.Error()
+		logger.Printf(ctx, "账号[%s]在区域[%s]创建实例失败: %v", acc.ID, regionCode, err)
+		return result
+	}
+
+	// 记录新开的实例，上报前也计入阈值
+	instanceIDs := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		instanceIDs = append(instanceIDs, inst.InstanceID)
+	}
+	pool.RecordUserLaunches(userID, regionCode, instanceIDs...)
+
+	// 按实际启动的数量更新账号池使用计数，与自动补机一致
+	accountPool := pool.GetAccountPool()
+	for range instances {
+		accountPool.IncrementInstanceUsage(acc.ID, setting.InstanceType, regionCode)
+	}
+
+	result.Status = "成功"
+	result.Instances = instances
+	result.Launched = len(instances)
+	logger.Printf(ctx, "账号[%s]在区域[%s]创建实例成功，数量: %d", acc.ID, regionCode, len(instances))
+
+	// 部分成功时说明原因
+	if result.Launched < int(count) {
+		result.Status = "部分成功"
+		result.Message = fmt.Sprintf("区域[%s]容量或配额不足，仅启动了%d/%d台", regionCode, result.Launched, count)
+		logger.Printf(ctx, "账号[%s]%s", acc.ID, result.Message)
+	}
+	return result
+}
+
+// thresholdHeadroom 用户在各区域距监控阈值还可开机的数量，需持有 LockUserRegions 的锁
+// 只包含设置了阈值的区域，未包含的区域不限制开机数量
+func thresholdHeadroom(userID string, regionCodes []string) map[string]int {
+	headroom := make(map[string]int)
+	for _, code := range regionCodes {
+		if _, ok := headroom[code]; ok {
+			continue
+		}
+		if room, limited := pool.UserRegionHeadroom(userID, code); limited {
+			headroom[code] = room
+		}
+	}
+	return headroom
+}
+
+// resolveCreateRegion 确定账号开机使用的区域代码
+func resolveCreateRegion(acc model.Account, region string, setting *model.Setting) string {
+	if region != "" {
+		// 如果请求指定了区域，使用请求的区域
+		return region
+	}
+	if acc.Region != nil && *acc.Region != "" {
+		// 否则使用账号的区域
+		return *acc.Region
+	}
+	// 如果账号没有区域，使用用户设置的区域代码
+	return setting.GetRegionCode()
+}
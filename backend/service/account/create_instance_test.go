@@ -0,0 +1,195 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"portal/model"
+	"portal/pkg/aws"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+	"portal/repository"
+
+	"gorm.io/gorm"
+)
+
+// stubLaunchInstances 替换AWS开机实现，按请求数量返回实例并累计启动总数
+func stubLaunchInstances(t *testing.T, launched *atomic.Int32) {
+	t.Helper()
+	old := pool.LaunchInstances
+	var seq atomic.Int32
+	pool.LaunchInstances = func(ctx context.Context, client *aws.AWSClient, params aws.CreateInstanceParams) ([]aws.CreateInstanceResult, error) {
+		// 模拟接口耗时，让两条开机路径有机会交错
+		time.Sleep(5 * time.Millisecond)
+		results := make([]aws.CreateInstanceResult, 0, params.Count)
+		for i := int32(0); i < params.Count; i++ {
+			results = append(results, aws.CreateInstanceResult{
+				InstanceID: fmt.Sprintf("i-%s-%d", params.UserID, seq.Add(1)),
+				Status:     "pending",
+			})
+		}
+		launched.Add(int32(len(results)))
+		return results, nil
+	}
+	t.Cleanup(func() { pool.LaunchInstances = old })
+}
+
+// seedLaunchUser 写入用户的设置、监控阈值和账号，并把账号加入账号池
+func seedLaunchUser(t *testing.T, userID string, threshold int, accountIDs ...string) *AccountService {
+	t.Helper()
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+
+	seeds := []any{
+		&model.Setting{UserID: userID, Region: "香港", InstanceType: "t3.micro", DiskSize: 20},
+		&model.Monitor{UserID: userID, Threshold: threshold, IsEnabled: true, IsHkEnabled: true},
+	}
+	hk := region.HK
+	for _, id := range accountIDs {
+		seeds = append(seeds, &model.Account{ID: id, UserID: userID, Key1: "AKIA" + id, Key2: "secret", Region: &hk})
+	}
+	// 跳过账号的ID生成钩子，直接使用指定的ID
+	for _, seed := range seeds {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(seed).Error; err != nil {
+			t.Fatalf("写入种子数据失败: %v", err)
+		}
+	}
+	for _, id := range accountIDs {
+		pool.GetAccountPool().AddAccount(model.Account{ID: id, UserID: userID, Key1: "AKIA" + id, Key2: "secret", Region: &hk})
+		t.Cleanup(func() { pool.GetAccountPool().RemoveAccount(id) })
+	}
+	return NewAccountService(db)
+}
+
+// 在 -race 下运行：同一用户同时手动开机和自动补机，两条路径合计不超过阈值
+func TestManualCreateAndMakeupRespectThreshold(t *testing.T) {
+	const (
+		userID    = "oplock-user"
+		threshold = 3
+	)
+	var launched atomic.Int32
+	stubLaunchInstances(t, &launched)
+	s := seedLaunchUser(t, userID, threshold, "9101")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if _, err := s.CreateInstance(context.Background(), userID, []string{"9101"}, "", threshold, false); err != nil {
+			t.Errorf("手动开机失败: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// 补机任务按阈值缺口逐台开机，阈值已满足时提前结束
+		for i := 0; i < threshold; i++ {
+			_, err := pool.CreateInstanceForUser(userID, "", "oplock-task")
+			if errors.Is(err, pool.ErrThresholdReached) {
+				return
+			}
+			if err != nil {
+				t.Errorf("补机开机失败: %v", err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	if got := launched.Load(); got != threshold {
+		t.Fatalf("手动开机和补机共启动%d台, 期望恰好达到阈值%d台", got, threshold)
+	}
+	if room, limited := pool.UserRegionHeadroom(userID, region.HK); !limited || room != 0 {
+		t.Fatalf("开机后阈值余量 = %d（limited=%v）, 期望0", room, limited)
+	}
+}
+
+func TestCreateInstanceAllowedRegions(t *testing.T) {
+	const userID = "allowed-region-user"
+	var launched atomic.Int32
+	stubLaunchInstances(t, &launched)
+	s := seedLaunchUser(t, userID, 0, "9401")
+	setAllowed := func(regions string) {
+		t.Helper()
+		if err := s.repo.DB.Model(&model.Setting{}).Where("user_id = ?", userID).Update("allowed_regions", regions).Error; err != nil {
+			t.Fatalf("更新允许区域失败: %v", err)
+		}
+	}
+
+	t.Run("允许的区域", func(t *testing.T) {
+		setAllowed("ap-east-1,ap-northeast-3")
+		launched.Store(0)
+		results, err := s.CreateInstance(context.Background(), userID, []string{"9401"}, "", 1, false)
+		if err != nil {
+			t.Fatalf("开机失败: %v", err)
+		}
+		if launched.Load() != 1 || len(results) != 1 || results[0].Launched != 1 {
+			t.Fatalf("开机结果 = %+v（启动%d台）, 期望在允许的区域启动1台", results, launched.Load())
+		}
+	})
+
+	t.Run("不允许的区域", func(t *testing.T) {
+		setAllowed("ap-northeast-3")
+		launched.Store(0)
+		if _, err := s.CreateInstance(context.Background(), userID, []string{"9401"}, region.HK, 1, false); !errors.Is(err, model.ErrRegionNotAllowed) {
+			t.Fatalf("指定不允许的区域开机错误 = %v, want ErrRegionNotAllowed", err)
+		}
+
+		// 未指定区域时按账号区域检查，结果中标明原因
+		results, err := s.CreateInstance(context.Background(), userID, []string{"9401"}, "", 1, false)
+		if err != nil {
+			t.Fatalf("开机失败: %v", err)
+		}
+		if len(results) != 1 || results[0].Launched != 0 || results[0].Message == "" {
+			t.Fatalf("开机结果 = %+v, 期望账号区域不允许时不开机并说明原因", results)
+		}
+
+		// 自动补机同样不允许
+		if _, err := pool.CreateInstanceForUser(userID, region.HK, "allowed-region-task"); !errors.Is(err, model.ErrRegionNotAllowed) {
+			t.Fatalf("补机错误 = %v, want ErrRegionNotAllowed", err)
+		}
+		if launched.Load() != 0 {
+			t.Fatalf("不允许的区域启动了%d台实例", launched.Load())
+		}
+	})
+}
+
+func TestCreateInstancePartialLaunch(t *testing.T) {
+	const userID = "partial-launch-user"
+	s := seedLaunchUser(t, userID, 0, "9402")
+
+	// 模拟容量不足：允许部分成功时只启动1台，否则整体失败
+	old := pool.LaunchInstances
+	pool.LaunchInstances = func(ctx context.Context, client *aws.AWSClient, params aws.CreateInstanceParams) ([]aws.CreateInstanceResult, error) {
+		if !params.AllowPartial {
+			return nil, errors.New("InsufficientInstanceCapacity")
+		}
+		return []aws.CreateInstanceResult{{InstanceID: "i-partial-1", Status: "pending"}}, nil
+	}
+	t.Cleanup(func() { pool.LaunchInstances = old })
+
+	results, err := s.CreateInstance(context.Background(), userID, []string{"9402"}, "", 3, true)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("创建实例 = %+v, %v", results, err)
+	}
+	result := results[0]
+	if result.Status != "部分成功" || result.Requested != 3 || result.Launched != 1 || len(result.Instances) != 1 {
+		t.Fatalf("部分成功结果 = %+v, 期望请求3台启动1台", result)
+	}
+	if result.Message == "" {
+		t.Fatal("部分成功时应说明原因")
+	}
+
+	results, err = s.CreateInstance(context.Background(), userID, []string{"9402"}, "", 3, false)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("创建实例 = %+v, %v", results, err)
+	}
+	if results[0].Status != "失败" || results[0].Launched != 0 {
+		t.Fatalf("未允许部分成功时的结果 = %+v, 期望失败", results[0])
+	}
+}
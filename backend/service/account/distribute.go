@@ -0,0 +1,236 @@
+// service/account/distribute.go
+package account
+
+import (
+	"context"
+	"fmt"
+	"portal/model"
+	"portal/pkg/aws"
+	"portal/pkg/logger"
+	"portal/pkg/pool"
+	"sync"
+)
+
+// DistributeCreateResult 按总数分散开机的结果
+type DistributeCreateResult struct {
+	Requested  int                    `json:"requested"`         // 请求的总数量
+	Launched   int                    `json:"launched"`          // 实际启动的总数量
+	Message    string                 `json:"message,omitempty"` // 未达到总数时的原因
+	Placements []CreateInstanceResult `json:"placements"`        // 每个账号的分配和启动情况
+}
+
+// accountCapacity 账号还可开启的实例数量
+type accountCapacity struct {
+	AccountID string
+	Capacity  int
+}
+
+// distributeCount 按账号顺序依次占满每个账号的余量，直到分配完total台
+// 与账号池 PreviewEligibleAccounts 的分配方式一致，返回每个账号的分配数量和未能分配的数量
+func distributeCount(total int, capacities []accountCapacity) (map[string]int, int) {
+	plan := make(map[string]int)
+	remaining := total
+	for _, c := range capacities {
+		if remaining <= 0 {
+			break
+		}
+		if c.Capacity <= 0 {
+			continue
+		}
+		planned := c.Capacity
+		if planned > remaining {
+			planned = remaining
+		}
+		plan[c.AccountID] = planned
+		remaining -= planned
+	}
+	return plan, remaining
+}
+
+// capByHeadroom 按区域阈值余量收紧账号余量，同一区域的账号按顺序共享该区域的余量
+func capByHeadroom(capacities []accountCapacity, regionOf map[string]string, headroom map[string]int) []accountCapacity {
+	left := make(map[string]int, len(headroom))
+	for code, room := range headroom {
+		left[code] = room
+	}
+	capped := make([]accountCapacity, len(capacities))
+	for i, c := range capacities {
+		capped[i] = c
+		code := regionOf[c.AccountID]
+		room, ok := left[code]
+		if !ok {
+			continue
+		}
+		if capped[i].Capacity > room {
+			capped[i].Capacity = room
+		}
+		left[code] -= capped[i].Capacity
+	}
+	return capped
+}
+
+// CreateInstanceDistributed 按总数在多个账号间分散创建实例
+// 每个账号的可用余量取自账号池，与自动补机的选择条件一致；某账号实际启动少于分配数量时，
+// 缺口转移到仍有余量的账号，直到达到总数或余量耗尽
+func (s *AccountService) CreateInstanceDistributed(ctx context.Context, userID string, accountIDs []string, region string, total int32) (*DistributeCreateResult, error) {
+	// 验证账号归属权
+	if err := model.VerifyAccountOwnership(s.repo.DB, userID, accountIDs); err != nil {
+		return nil, err
+	}
+
+	accounts, err := model.GetAccountKeysByIDs(s.repo.DB, userID, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	setting, err := model.GetSettingByUserID(s.repo.DB, userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户设置失败: %v", err)
+	}
+
+	additionalVolumes, err := aws.ParseVolumeSpecs(setting.AdditionalVolumes)
+	if err != nil {
+		return nil, err
+	}
+
+	if region != "" {
+		if err := setting.CheckRegionAllowed(region); err != nil {
+			return nil, err
+		}
+	}
+
+	if total <= 0 {
+		total = 1
+	}
+
+	// 检查全局实例上限，总共只启动total台
+	if err := pool.CheckGlobalInstanceLimit(int(total)); err != nil {
+		return nil, err
+	}
+
+	regionCodes := make([]string, 0, len(accounts))
+	for _, acc := range accounts {
+		regionCodes = append(regionCodes, resolveCreateRegion(acc, region, setting))
+	}
+	unlock := pool.LockUserRegions(userID, regionCodes...)
+	defer unlock()
+
+	// 按请求中的账号顺序读取每个账号的余量
+	accountByID := make(map[string]model.Account, len(accounts))
+	for _, acc := range accounts {
+		accountByID[acc.ID] = acc
+	}
+	accountPool := pool.GetAccountPool()
+	regionOf := make(map[string]string, len(accounts))
+	placements := make(map[string]*CreateInstanceResult, len(accounts))
+	capacities := make([]accountCapacity, 0, len(accounts))
+	for _, id := range accountIDs {
+		acc, ok := accountByID[id]
+		if !ok || placements[id] != nil {
+			continue
+		}
+		placement := &CreateInstanceResult{AccountID: id, Status: "未分配"}
+		placements[id] = placement
+		regionOf[id] = resolveCreateRegion(acc, region, setting)
+
+		capacity, reason := accountPool.InstanceCapacity(id, setting.InstanceType, regionOf[id])
+		if capacity <= 0 {
+			placement.Message = reason
+			continue
+		}
+		capacities = append(capacities, accountCapacity{AccountID: id, Capacity: capacity})
+	}
+
+	// 在锁内读取各区域距监控阈值的余量，避免与自动补机一起超出阈值
+	headroom := thresholdHeadroom(userID, regionCodes)
+
+	result := &DistributeCreateResult{Requested: int(total)}
+	remaining := int(total)
+	for remaining > 0 {
+		plan, _ := distributeCount(remaining, capByHeadroom(capacities, regionOf, headroom))
+		if len(plan) == 0 {
+			break
+		}
+
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		launched := make(map[string]int, len(plan))
+		for id, count := range plan {
+			wg.Add(1)
+			go func(acc model.Account, count int) {
+				defer wg.Done()
+
+				created := s.createOnAccount(ctx, userID, acc, region, setting, additionalVolumes, int32(count), true)
+
+				mu.Lock()
+				defer mu.Unlock()
+				launched[acc.ID] = created.Launched
+				placement := placements[acc.ID]
+				placement.Requested += int32(count)
+				placement.Launched += created.Launched
+				placement.Instances = append(placement.Instances, created.Instances...)
+				placement.Message = created.Message
+			}(accountByID[id], count)
+		}
+		wg.Wait()
+
+		// 扣减已使用的余量，未能启动满分配数量的账号不再参与后续分配
+		progressed := false
+		for i := range capacities {
+			planned, ok := plan[capacities[i].AccountID]
+			if !ok {
+				continue
+			}
+			count := launched[capacities[i].AccountID]
+			if count > 0 {
+				progressed = true
+			}
+			remaining -= count
+			if _, ok := headroom[regionOf[capacities[i].AccountID]]; ok {
+				headroom[regionOf[capacities[i].AccountID]] -= count
+			}
+			if count < planned {
+				capacities[i].Capacity = 0
+			} else {
+				capacities[i].Capacity -= count
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+
+	for _, id := range accountIDs {
+		placement, ok := placements[id]
+		if !ok {
+			continue
+		}
+		delete(placements, id)
+		switch {
+		case placement.Requested == 0:
+		case placement.Launched == 0:
+			placement.Status = "失败"
+		case placement.Launched < int(placement.Requested):
+			placement.Status = "部分成功"
+		default:
+			placement.Status = "成功"
+		}
+		result.Launched += placement.Launched
+		result.Placements = append(result.Placements, *placement)
+	}
+
+	if result.Launched < result.Requested {
+		result.Message = fmt.Sprintf("所选账号余量不足，仅启动了%d/%d台", result.Launched, result.Requested)
+		for code, room := range headroom {
+			if room <= 0 {
+				result.Message = fmt.Sprintf("区域[%s]的实例数已达到监控阈值，仅启动了%d/%d台", code, result.Launched, result.Requested)
+				break
+			}
+		}
+	}
+	logger.Printf(ctx, "用户[%s]分散创建实例完成，启动%d/%d台", userID, result.Launched, result.Requested)
+
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package account
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"portal/pkg/pool"
+	"portal/pkg/region"
+)
+
+func TestDistributeCount(t *testing.T) {
+	capacities := []accountCapacity{
+		{AccountID: "a", Capacity: 4},
+		{AccountID: "b", Capacity: 1},
+		{AccountID: "c", Capacity: 4},
+	}
+	plan, remaining := distributeCount(6, capacities)
+	want := map[string]int{"a": 4, "b": 1, "c": 1}
+	if remaining != 0 || len(plan) != len(want) {
+		t.Fatalf("分配结果 = %v, 剩余%d, 期望 %v", plan, remaining, want)
+	}
+	for id, count := range want {
+		if plan[id] != count {
+			t.Errorf("账号[%s]分配%d台, 期望%d台", id, plan[id], count)
+		}
+	}
+
+	// 总余量不足时返回未能分配的数量
+	if _, remaining := distributeCount(12, capacities); remaining != 3 {
+		t.Errorf("余量9台分配12台时剩余%d台, 期望3台", remaining)
+	}
+}
+
+func TestCreateInstanceDistributedRecordsUsage(t *testing.T) {
+	const userID = "distribute-user"
+	var launched atomic.Int32
+	stubLaunchInstances(t, &launched)
+	s := seedLaunchUser(t, userID, 0, "9201", "9202", "9203")
+
+	// 第二个账号已使用3台，三个账号的余量为 4/1/4
+	accountPool := pool.GetAccountPool()
+	for i := 0; i < 3; i++ {
+		accountPool.IncrementInstanceUsage("9202", "t3.micro", region.HK)
+	}
+
+	result, err := s.CreateInstanceDistributed(context.Background(), userID, []string{"9201", "9202", "9203"}, "", 6)
+	if err != nil {
+		t.Fatalf("分散开机失败: %v", err)
+	}
+	if result.Launched != 6 || launched.Load() != 6 {
+		t.Fatalf("启动%d台（接口调用启动%d台）, 期望6台", result.Launched, launched.Load())
+	}
+
+	wantPlaced := map[string]int{"9201": 4, "9202": 1, "9203": 1}
+	for _, placement := range result.Placements {
+		if placement.Launched != wantPlaced[placement.AccountID] {
+			t.Errorf("账号[%s]启动%d台, 期望%d台", placement.AccountID, placement.Launched, wantPlaced[placement.AccountID])
+		}
+	}
+
+	// 启动的实例计入账号池使用计数，余量随之减少
+	wantLeft := map[string]int{"9201": 0, "9202": 0, "9203": 3}
+	for id, want := range wantLeft {
+		if capacity, _ := accountPool.InstanceCapacity(id, "t3.micro", region.HK); capacity != want {
+			t.Errorf("账号[%s]开机后余量%d台, 期望%d台", id, capacity, want)
+		}
+	}
+}
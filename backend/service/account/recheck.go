@@ -0,0 +1,163 @@
+// service/account/recheck.go
+package account
+
+import (
+	"context"
+	"log"
+	"os"
+	"portal/model"
+	"portal/pkg/pool"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 账号最近一次检测的时间，账号ID -> time.Time
+var accountCheckedAt sync.Map
+
+// markAccountChecked 记录账号的检测时间
+func markAccountChecked(accountID string) {
+	accountCheckedAt.Store(accountID, time.Now())
+}
+
+// checkedWithin 账号是否在指定时间内检测过
+func checkedWithin(accountID string, d time.Duration) bool {
+	value, ok := accountCheckedAt.Load(accountID)
+	if !ok {
+		return false
+	}
+	return time.Since(value.(time.Time)) < d
+}
+
+// RecheckStaleAccounts 重新检测最近minAge内未检测过的有效账号，更新数据库和账号池
+func (s *AccountService) RecheckStaleAccounts(ctx context.Context, minAge time.Duration) ([]CheckResult, error) {
+	accounts, err := model.ListAllValidAccounts(s.repo.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	// 过滤最近检测过的账号
+	staleAccounts := make([]model.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		if !checkedWithin(acc.ID, minAge) {
+			staleAccounts = append(staleAccounts, acc)
+		}
+	}
+
+	// 缓存每个用户的实例类型，避免重复查询设置
+	instanceTypes := make(map[string]string)
+	for _, acc := range staleAccounts {
+		if _, exists := instanceTypes[acc.UserID]; exists {
+			continue
+		}
+		instanceTypes[acc.UserID] = ""
+		if setting, err := model.GetSettingByUserID(s.repo.DB, acc.UserID); err == nil {
+			instanceTypes[acc.UserID] = setting.InstanceType
+		}
+	}
+
+	// 创建并发控制
+	var wg sync.WaitGroup
+	// 设置合理的并发数量
+	semaphore := make(chan struct{}, 10) // 最多10个并发
+	resultChan := make(chan CheckResult, len(staleAccounts))
+	// 配置了分散窗口时，按区域错开各账号的检测时间
+	offsets := staggerOffsets(staleAccounts, getCheckStaggerWindow())
+
+	for i, acc := range staleAccounts {
+		wg.Add(1)
+		// 复制一份acc避免闭包问题
+		account := acc
+		delay := offsets[i]
+
+		go func() {
+			defer wg.Done()
+
+			waitStagger(ctx, delay)
+
+			// 获取信号量，控制并发数
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			result := s.checkSingleAccount(ctx, account, instanceTypes[account.UserID], false)
+
+			// 同步检测结果到账号池
+			var vmCount *int
+			if result.VMCount != nil {
+				count := int(*result.VMCount)
+				vmCount = &count
+			}
+			// 临时错误不覆盖账号池中已有的状态
+			if !result.Transient {
+				pool.GetAccountPool().UpdateAccountCheckResult(account.ID, result.Quota, result.HK, vmCount, result.VMCountRegion)
+			}
+
+			resultChan <- result
+		}()
+	}
+
+	// 等待所有处理完成
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	// 收集所有结果
+	results := make([]CheckResult, 0, len(staleAccounts))
+	for result := range resultChan {
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// StartAccountRecheckJob 启动定时复检账号状态的任务
+// 间隔由环境变量 ACCOUNT_RECHECK_INTERVAL 配置（如 6h），未设置或为0时不启动
+// 最近 ACCOUNT_RECHECK_MIN_AGE 内检测过的账号会被跳过，默认与间隔相同
+// 有补机任务正在进行时跳过本轮，避免与开机争抢API调用
+func StartAccountRecheckJob(db *gorm.DB) {
+	value := os.Getenv("ACCOUNT_RECHECK_INTERVAL")
+	if value == "" {
+		return
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		log.Printf("ACCOUNT_RECHECK_INTERVAL 配置无效[%s]，不启动账号定时复检", value)
+		return
+	}
+
+	minAge := interval
+	if value := os.Getenv("ACCOUNT_RECHECK_MIN_AGE"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			minAge = d
+		} else {
+			log.Printf("ACCOUNT_RECHECK_MIN_AGE 配置无效[%s]，使用默认值%v", value, interval)
+		}
+	}
+
+	log.Printf("启动账号定时复检任务，间隔: %v，跳过%v内检测过的账号", interval, minAge)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if pool.GetMakeupQueue().HasActiveTasks() {
+				log.Printf("有补机任务正在进行，跳过本轮账号复检")
+				continue
+			}
+
+			results, err := NewAccountService(db).RecheckStaleAccounts(context.Background(), minAge)
+			if err != nil {
+				log.Printf("账号定时复检失败: %v", err)
+				continue
+			}
+			invalid := 0
+			for _, result := range results {
+				if result.Quota == "账号已失效" {
+					invalid++
+				}
+			}
+			log.Printf("账号定时复检完成，检测%d个账号，其中%d个已失效", len(results), invalid)
+		}
+	}()
+}
@@ -0,0 +1,69 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"portal/model"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+	"portal/repository"
+
+	"gorm.io/gorm"
+)
+
+func TestRecheckStaleAccountsUpdatesStatus(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	jp, oldQuota := region.JP, "5"
+	for _, acc := range []model.Account{
+		{ID: "9361", UserID: "recheck-user", Key1: "AKIARECHECKSTALE", Key2: "secret", Region: &jp, Quatos: &oldQuota},
+		{ID: "9362", UserID: "recheck-user", Key1: "AKIARECHECKRECENT", Key2: "secret", Region: &jp, Quatos: &oldQuota},
+	} {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+	}
+	(&fakeAWS{
+		quotas: map[string]int{"AKIARECHECKSTALE": 32, "AKIARECHECKRECENT": 64},
+		instances: map[string][]string{
+			"AKIARECHECKSTALE":  {"t3.micro"},
+			"AKIARECHECKRECENT": {"t3.micro"},
+		},
+	}).start(t)
+	// 9362 刚检测过，本轮应跳过
+	markAccountChecked("9362")
+	t.Cleanup(func() {
+		accountCheckedAt.Delete("9361")
+		accountCheckedAt.Delete("9362")
+	})
+
+	results, err := NewAccountService(db).RecheckStaleAccounts(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatalf("复检账号失败: %v", err)
+	}
+	if len(results) != 1 || results[0].AccountID != "9361" || results[0].Quota != "32" {
+		t.Fatalf("复检结果 = %+v, 期望只复检过期账号9361", results)
+	}
+
+	stored, err := model.GetAccountsByIDs(db, []string{"9361", "9362"})
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("读取账号失败: %v", err)
+	}
+	for _, acc := range stored {
+		switch acc.ID {
+		case "9361":
+			if acc.Quatos == nil || *acc.Quatos != "32" || acc.VMCount == nil || *acc.VMCount != 1 {
+				t.Errorf("过期账号复检后 配额=%v 实例数=%v, want 32 和 1", acc.Quatos, acc.VMCount)
+			}
+		case "9362":
+			if acc.Quatos == nil || *acc.Quatos != "5" {
+				t.Errorf("最近检测过的账号不应被复检, 配额=%v", acc.Quatos)
+			}
+		}
+	}
+	if !checkedWithin("9361", time.Minute) {
+		t.Fatal("复检后应记录账号的检测时间")
+	}
+}
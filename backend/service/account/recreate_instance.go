@@ -0,0 +1,127 @@
+// service/account/recreate_instance.go
+package account
+
+import (
+	"context"
+	"fmt"
+	"portal/model"
+	"portal/pkg/aws"
+	"portal/pkg/logger"
+	"portal/service/instance"
+)
+
+// RecreateInstanceResult 重建实例结果
+type RecreateInstanceResult struct {
+	AccountID     string `json:"account_id"`
+	Region        string `json:"region"`
+	OldInstanceID string `json:"old_instance_id"`
+	NewInstanceID string `json:"new_instance_id"`
+	PublicIP      string `json:"public_ip"`             // 新实例的公网IP
+	RetainedIP    string `json:"retained_ip,omitempty"` // 保留并复用的弹性IP
+	Status        string `json:"status"`                // 成功/失败
+	Message       string `json:"message"`               // 错误或提示信息
+}
+
+// RecreateInstance 删除指定实例并按用户当前设置重新创建一台，keepEIP时将原弹性IP绑定到新实例
+func (s *AccountService) RecreateInstance(ctx context.Context, userID, accountID, region, instanceID string, keepEIP bool) (*RecreateInstanceResult, error) {
+	// 删除前先完成所有校验，避免删除成功后无法重建
+	accounts, err := model.GetAccountKeysByIDs(s.repo.DB, userID, []string{accountID})
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("存在无权操作的账号ID")
+	}
+	acc := accounts[0]
+
+	setting, err := model.GetSettingByUserID(s.repo.DB, userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取用户设置失败: %v", err)
+	}
+	if _, err := aws.ParseVolumeSpecs(setting.AdditionalVolumes); err != nil {
+		return nil, err
+	}
+
+	// 确定区域：优先请求区域，其次账号区域，最后默认香港
+	regionCode := region
+	if regionCode == "" {
+		regionCode = acc.RegionCode()
+	}
+	if acc.Region != nil && *acc.Region != "" && *acc.Region != regionCode {
+		return nil, fmt.Errorf("账号[%s]区域为[%s]，与请求区域[%s]不匹配", acc.ID, *acc.Region, regionCode)
+	}
+	if err := setting.CheckRegionAllowed(regionCode); err != nil {
+		return nil, err
+	}
+
+	result := &RecreateInstanceResult{
+		AccountID:     accountID,
+		Region:        regionCode,
+		OldInstanceID: instanceID,
+		Status:        "失败",
+	}
+
+	// 删除旧实例
+	deleteResults, err := instance.NewInstanceService(s.repo.DB).Delete(ctx, userID, []instance.DeleteInstanceItem{{
+		AccountID:  accountID,
+		Region:     regionCode,
+		InstanceID: instanceID,
+		KeepEIP:    keepEIP,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if len(deleteResults) == 0 || deleteResults[0].Status != "成功" {
+		if len(deleteResults) > 0 {
+			result.Message = "删除旧实例失败: " + deleteResults[0].Message
+		} else {
+			result.Message = "删除旧实例失败"
+		}
+		return result, nil
+	}
+	deleted := deleteResults[0]
+	logger.Printf(ctx, "重建实例: 账号[%s]已删除旧实例[%s]", accountID, instanceID)
+
+	// 按用户当前设置创建新实例
+	createResults, err := s.CreateInstance(ctx, userID, []string{accountID}, regionCode, 1, false)
+	if err != nil {
+		result.Message = "旧实例已删除，创建新实例失败: " + err.Error()
+		result.RetainedIP = deleted.RetainedIP
+		return result, nil
+	}
+	if len(createResults) == 0 || createResults[0].Status != "成功" || len(createResults[0].Instances) == 0 {
+		result.Message = "旧实例已删除，创建新实例失败"
+		if len(createResults) > 0 && createResults[0].Message != "" {
+			result.Message += ": " + createResults[0].Message
+		}
+		result.RetainedIP = deleted.RetainedIP
+		return result, nil
+	}
+
+	newInstance := createResults[0].Instances[0]
+	result.Status = "成功"
+	result.NewInstanceID = newInstance.InstanceID
+	result.PublicIP = newInstance.PublicIP
+	logger.Printf(ctx, "重建实例: 账号[%s]旧实例[%s]已替换为新实例[%s]", accountID, instanceID, newInstance.InstanceID)
+
+	// 将保留的弹性IP绑定到新实例
+	if keepEIP && deleted.RetainedAllocationID != "" {
+		awsClient := aws.NewAWSClient(acc.Key1, acc.Key2)
+		ip, err := awsClient.AssociateAddress(ctx, aws.AssociateAddressParams{
+			Region:       regionCode,
+			InstanceID:   newInstance.InstanceID,
+			AllocationID: deleted.RetainedAllocationID,
+		})
+		if err != nil {
+			// 新实例已创建，弹性IP仍保留在账号中，提示用户手动处理
+			result.RetainedIP = deleted.RetainedIP
+			result.Message = fmt.Sprintf("新实例已创建，但绑定保留的弹性IP[%s]失败: %v", deleted.RetainedIP, err)
+			logger.Printf(ctx, "重建实例: 账号[%s]绑定弹性IP[%s]到实例[%s]失败: %v", accountID, deleted.RetainedIP, newInstance.InstanceID, err)
+		} else {
+			result.RetainedIP = ip
+			result.PublicIP = ip
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package account
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"portal/pkg/aws"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+)
+
+func TestRecreateInstanceTerminatesAndRelaunches(t *testing.T) {
+	const userID = "recreate-user"
+	s := seedLaunchUser(t, userID, 5, "9371")
+	fake := &fakeAWS{}
+	fake.start(t)
+
+	var (
+		mu       sync.Mutex
+		launches []aws.CreateInstanceParams
+	)
+	old := pool.LaunchInstances
+	pool.LaunchInstances = func(ctx context.Context, client *aws.AWSClient, params aws.CreateInstanceParams) ([]aws.CreateInstanceResult, error) {
+		mu.Lock()
+		launches = append(launches, params)
+		mu.Unlock()
+		return []aws.CreateInstanceResult{{InstanceID: "i-recreated", Status: "pending"}}, nil
+	}
+	t.Cleanup(func() { pool.LaunchInstances = old })
+
+	result, err := s.RecreateInstance(context.Background(), userID, "9371", "", "i-original", false)
+	if err != nil {
+		t.Fatalf("重建实例失败: %v", err)
+	}
+	if result.Status != "成功" || result.OldInstanceID != "i-original" || result.NewInstanceID != "i-recreated" || result.Region != region.HK {
+		t.Fatalf("重建结果 = %+v", result)
+	}
+
+	fake.mu.Lock()
+	terminated := fake.terminated["AKIA9371"]
+	fake.mu.Unlock()
+	if len(terminated) != 1 || terminated[0] != "i-original" {
+		t.Fatalf("终止的实例 = %v, want [i-original]", terminated)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(launches) != 1 {
+		t.Fatalf("开机次数 = %d, want 1", len(launches))
+	}
+	params := launches[0]
+	if params.Count != 1 || params.Region != region.HK || params.InstanceType != "t3.micro" || params.DiskSize != 20 || params.UserID != userID {
+		t.Fatalf("开机参数 = %+v, 期望与用户设置一致的1台实例", params)
+	}
+}
@@ -0,0 +1,77 @@
+// service/account/stagger.go
+package account
+
+import (
+	"context"
+	"log"
+	"os"
+	"portal/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	checkStaggerWindow     time.Duration
+	checkStaggerWindowOnce sync.Once
+)
+
+// getCheckStaggerWindow 获取批量检测账号时的分散时间窗口，可通过 ACCOUNT_CHECK_STAGGER_WINDOW 配置（如"2m"）
+// 默认为0，所有账号立即开始检测
+func getCheckStaggerWindow() time.Duration {
+	checkStaggerWindowOnce.Do(func() {
+		if value := os.Getenv("ACCOUNT_CHECK_STAGGER_WINDOW"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+				checkStaggerWindow = d
+			} else {
+				log.Printf("ACCOUNT_CHECK_STAGGER_WINDOW配置无效: %s，不分散检测", value)
+			}
+		}
+	})
+	return checkStaggerWindow
+}
+
+// staggerOffsets 计算每个账号开始检测前需要等待的时间，与accounts一一对应
+// 账号按区域分组，每个区域占用时间窗口中的一段，区域内的账号在这一段内均匀分布，
+// 使各区域的API调用错开，同一区域的调用也不会集中在同一时刻
+func staggerOffsets(accounts []model.Account, window time.Duration) []time.Duration {
+	offsets := make([]time.Duration, len(accounts))
+	if window <= 0 || len(accounts) <= 1 {
+		return offsets
+	}
+
+	groups := make(map[string][]int)
+	for i, acc := range accounts {
+		regionCode := acc.RegionCode()
+		groups[regionCode] = append(groups[regionCode], i)
+	}
+
+	regionCodes := make([]string, 0, len(groups))
+	for code := range groups {
+		regionCodes = append(regionCodes, code)
+	}
+	sort.Strings(regionCodes)
+
+	slot := window / time.Duration(len(regionCodes))
+	for r, code := range regionCodes {
+		indexes := groups[code]
+		step := slot / time.Duration(len(indexes))
+		for j, index := range indexes {
+			offsets[index] = time.Duration(r)*slot + time.Duration(j)*step
+		}
+	}
+	return offsets
+}
+
+// waitStagger 等待分散检测的时间，ctx取消时提前返回
+func waitStagger(ctx context.Context, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
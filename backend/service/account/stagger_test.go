@@ -0,0 +1,84 @@
+package account
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"portal/model"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+	"portal/repository"
+
+	"gorm.io/gorm"
+)
+
+// setCheckStaggerWindow 设置批量检测的分散时间窗口，测试结束后恢复
+func setCheckStaggerWindow(t *testing.T, window time.Duration) {
+	t.Helper()
+	checkStaggerWindowOnce.Do(func() {})
+	old := checkStaggerWindow
+	checkStaggerWindow = window
+	t.Cleanup(func() {
+		checkStaggerWindow = old
+		checkStaggerWindowOnce = sync.Once{}
+	})
+}
+
+func TestStaggerOffsets(t *testing.T) {
+	hk, jp := region.HK, region.JP
+	accounts := []model.Account{{ID: "1"}, {ID: "2", Region: &jp}, {ID: "3", Region: &hk}, {ID: "4", Region: &jp}}
+
+	// 香港区占窗口前半段，日本区占后半段，区域内均匀分布
+	offsets := staggerOffsets(accounts, 4*time.Second)
+	want := []time.Duration{0, 2 * time.Second, time.Second, 3 * time.Second}
+	for i := range want {
+		if offsets[i] != want[i] {
+			t.Fatalf("staggerOffsets = %v, want %v", offsets, want)
+		}
+	}
+
+	for _, offset := range staggerOffsets(accounts, 0) {
+		if offset != 0 {
+			t.Fatal("未配置分散窗口时应立即检测")
+		}
+	}
+}
+
+func TestCheckStaggersAccountsAcrossRegions(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	const userID = "stagger-user"
+	hk, jp := region.HK, region.JP
+	accounts := []model.Account{
+		{ID: "9471", UserID: userID, Key1: "AKIASTAGGER1", Key2: "secret", Region: &hk},
+		{ID: "9472", UserID: userID, Key1: "AKIASTAGGER2", Key2: "secret", Region: &hk},
+		{ID: "9473", UserID: userID, Key1: "AKIASTAGGER3", Key2: "secret", Region: &jp},
+	}
+	for i := range accounts {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&accounts[i]).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+	}
+	fake := &fakeAWS{quotas: map[string]int{"AKIASTAGGER1": 32, "AKIASTAGGER2": 32, "AKIASTAGGER3": 32}}
+	fake.start(t)
+	setCheckStaggerWindow(t, 600*time.Millisecond)
+
+	results, err := NewAccountService(db).Check(context.Background(), userID, []string{"9471", "9472", "9473"})
+	if err != nil || len(results) != 3 {
+		t.Fatalf("检测账号 = %+v, %v", results, err)
+	}
+
+	// 按区域分段：香港两个账号在0和150ms开始，日本账号在300ms开始
+	first := fake.requested["AKIASTAGGER1"]
+	for key, min := range map[string]time.Duration{"AKIASTAGGER2": 100 * time.Millisecond, "AKIASTAGGER3": 250 * time.Millisecond} {
+		started, ok := fake.requested[key]
+		if !ok {
+			t.Fatalf("账号[%s]未发起检测请求", key)
+		}
+		if gap := started.Sub(first); gap < min {
+			t.Fatalf("账号[%s]在第一个账号之后%v开始检测, 期望至少间隔%v", key, gap, min)
+		}
+	}
+}
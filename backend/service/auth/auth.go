@@ -1,11 +1,9 @@
 package auth
 
 import (
-	"errors"
-	"log"
-	"os"
 	"time"
 
+	"portal/pkg/config"
 	"portal/repository/auth"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -35,7 +33,22 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-// 移除 init 函数 - 不再尝试加载环境变量
+// signToken 生成登录令牌，密钥和有效期与鉴权中间件共用配置模块加载的值
+func signToken(userID string, isAdmin uint8) (string, error) {
+	jwtCfg := config.Get().JWT
+	claims := &Claims{
+		UserID:  userID,
+		IsAdmin: isAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(jwtCfg.Expire)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(jwtCfg.Secret))
+}
 
 // Login 处理用户登录认证
 func (s *AuthService) Login(email, password string) (*LoginResult, error) {
@@ -45,41 +58,7 @@ func (s *AuthService) Login(email, password string) (*LoginResult, error) {
 		return nil, err
 	}
 
-	// 从环境变量获取 JWT 配置
-	jwtSecret := os.Getenv("JWT_SECRET")
-	jwtExpireStr := os.Getenv("JWT_EXPIRE")
-
-	// 如果环境变量为空，记录警告并使用默认值
-	if jwtSecret == "" {
-		log.Println("警告: JWT_SECRET 环境变量未设置，使用默认值")
-		jwtSecret = "default_secret_for_development_only"
-	}
-
-	if jwtExpireStr == "" {
-		log.Println("警告: JWT_EXPIRE 环境变量未设置，使用默认值")
-		jwtExpireStr = "24h"
-	}
-
-	// 解析过期时间
-	expireDuration, err := time.ParseDuration(jwtExpireStr)
-	if err != nil {
-		return nil, errors.New("JWT过期时间配置错误")
-	}
-
-	// 创建 JWT Claims
-	claims := &Claims{
-		UserID:  user.ID,
-		IsAdmin: user.IsAdmin,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	// 生成 token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := signToken(user.ID, user.IsAdmin)
 	if err != nil {
 		return nil, err
 	}
@@ -101,37 +80,7 @@ func (s *AuthService) Register(email, password string) (*LoginResult, error) {
 	}
 
 	// 生成 JWT Token
-	jwtSecret := os.Getenv("JWT_SECRET")
-	jwtExpireStr := os.Getenv("JWT_EXPIRE")
-
-	// 如果环境变量为空，记录警告并使用默认值
-	if jwtSecret == "" {
-		log.Println("警告: JWT_SECRET 环境变量未设置，使用默认值")
-		jwtSecret = "default_secret_for_development_only"
-	}
-
-	if jwtExpireStr == "" {
-		log.Println("警告: JWT_EXPIRE 环境变量未设置，使用默认值")
-		jwtExpireStr = "24h"
-	}
-
-	expireDuration, err := time.ParseDuration(jwtExpireStr)
-	if err != nil {
-		return nil, errors.New("JWT过期时间配置错误")
-	}
-
-	claims := &Claims{
-		UserID:  user.ID,
-		IsAdmin: user.IsAdmin, // 默认为0
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := signToken(user.ID, user.IsAdmin)
 	if err != nil {
 		return nil, err
 	}
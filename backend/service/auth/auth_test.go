@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"testing"
+
+	"portal/middleware"
+)
+
+func TestSignTokenAcceptedByMiddleware(t *testing.T) {
+	token, err := signToken("u-login", 1)
+	if err != nil {
+		t.Fatalf("生成令牌失败: %v", err)
+	}
+
+	// 登录签发的令牌需能通过鉴权中间件的校验，两者使用同一份密钥
+	claims, err := middleware.ParseToken(token)
+	if err != nil {
+		t.Fatalf("中间件无法解析登录签发的令牌: %v", err)
+	}
+	if claims.UserID != "u-login" || claims.IsAdmin != 1 {
+		t.Fatalf("令牌内容 = %+v", claims)
+	}
+}
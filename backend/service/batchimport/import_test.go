@@ -0,0 +1,93 @@
+package batchimport
+
+import (
+	"testing"
+
+	"portal/model"
+	"portal/pkg/pool"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+
+	"gorm.io/gorm"
+)
+
+func TestImportAccountsSelectableImmediately(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	accountPool := pool.GetAccountPool()
+	before := accountPool.Size()
+
+	content := "a@example.com---pass---AKIAIMPORT1---secret1---日本\n" +
+		"b@example.com---pass---AKIAIMPORT2---secret2---日本\n"
+	result, err := NewImportService(db).ImportAccounts(content, "import-user", false, false)
+	if err != nil {
+		t.Fatalf("导入账号失败: %v", err)
+	}
+	if result.Summary.SuccessCount != 2 {
+		t.Fatalf("导入成功数 = %d, want 2: %+v", result.Summary.SuccessCount, result)
+	}
+	for _, acc := range result.CreatedAccounts {
+		id := acc.ID
+		t.Cleanup(func() { accountPool.RemoveAccount(id) })
+	}
+
+	// 不调用 LoadAccountsFromDB，新账号应已在账号池中并可被选用
+	if got := accountPool.Size(); got != before+2 {
+		t.Fatalf("导入后账号池大小 = %d, want %d", got, before+2)
+	}
+	for _, acc := range result.CreatedAccounts {
+		state, exists := accountPool.InspectAccount(acc.ID, "t3.micro", region.JP)
+		if !exists || state.Selectable == nil || !*state.Selectable {
+			t.Errorf("导入的账号[%s]状态 = %+v（存在=%v）, 期望可被选用", acc.ID, state, exists)
+		}
+	}
+	if next := accountPool.GetNextAccountForInstanceType("t3.micro", region.JP); next == nil || next.UserID != "import-user" {
+		t.Fatalf("GetNextAccountForInstanceType = %+v, 期望选中导入的账号", next)
+	}
+}
+
+func TestImportAccountsDryRun(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	existing := model.Account{ID: "9451", UserID: "dry-run-user", Key1: "AKIAEXISTING", Key2: "secret-existing"}
+	if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&existing).Error; err != nil {
+		t.Fatalf("写入账号失败: %v", err)
+	}
+	accountPool := pool.GetAccountPool()
+	poolSize := accountPool.Size()
+
+	content := "a@example.com---pass---AKIAEXISTING---secret-a---日本\n" + // 与已有账号重复
+		"b@example.com---pass---AKIADRYRUN1---secret-b---日本\n" +
+		"c@example.com---pass---AKIADRYRUN2---secret-c---日本\n" +
+		"d@example.com---pass---AKIADRYRUN1---secret-d---日本\n" + // 与同批次账号重复
+		"格式错误的行\n"
+	s := NewImportService(db)
+	preview, err := s.ImportAccounts(content, "dry-run-user", false, true)
+	if err != nil {
+		t.Fatalf("预览导入失败: %v", err)
+	}
+	if !preview.DryRun || preview.Summary.SuccessCount != 2 || preview.Summary.DuplicateCount != 2 ||
+		preview.Summary.FormatErrorCount != 1 || preview.Summary.FailedCount != 3 {
+		t.Fatalf("预览结果 = %+v, 期望成功2、重复2、格式错误1", preview.Summary)
+	}
+
+	var count int64
+	db.Model(&model.Account{}).Count(&count)
+	if count != 1 || len(preview.CreatedAccounts) != 0 {
+		t.Fatalf("预览后数据库账号数 = %d, 期望不写入任何账号", count)
+	}
+	if got := accountPool.Size(); got != poolSize {
+		t.Fatalf("预览后账号池大小 = %d, want %d", got, poolSize)
+	}
+
+	// 实际导入的统计应与预览一致
+	result, err := s.ImportAccounts(content, "dry-run-user", false, false)
+	if err != nil {
+		t.Fatalf("导入账号失败: %v", err)
+	}
+	for _, acc := range result.CreatedAccounts {
+		id := acc.ID
+		t.Cleanup(func() { accountPool.RemoveAccount(id) })
+	}
+	if result.Summary != preview.Summary {
+		t.Fatalf("实际导入结果 = %+v, 预览结果 = %+v", result.Summary, preview.Summary)
+	}
+}
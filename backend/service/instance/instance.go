@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"portal/model"
 	"portal/pkg/aws"
+	"portal/pkg/logger"
 	"portal/repository/account"
 	"sort"
 	"strconv"
@@ -29,14 +30,17 @@ type DeleteInstanceItem struct {
 	AccountID  string
 	Region     string // 区域参数可选
 	InstanceID string
+	KeepEIP    bool // 是否保留弹性IP
 }
 
 // DeleteResult 删除结果
 type DeleteResult struct {
-	AccountID  string `json:"account_id"`
-	InstanceID string `json:"instance_id"`
-	Status     string `json:"status"`  // 成功/失败
-	Message    string `json:"message"` // 错误信息
+	AccountID            string `json:"account_id"`
+	InstanceID           string `json:"instance_id"`
+	Status               string `json:"status"`                           // 成功/失败
+	Message              string `json:"message"`                          // 错误信息
+	RetainedAllocationID string `json:"retained_allocation_id,omitempty"` // 保留的弹性IP分配ID
+	RetainedIP           string `json:"retained_ip,omitempty"`            // 保留的弹性IP地址
 }
 
 // Delete 批量删除实例
@@ -100,12 +104,9 @@ func (s *InstanceService) Delete(ctx context.Context, userID string, instances [
 			// 优先使用请求中指定的区域
 			regionCode := item.Region
 
-			// 如果请求中没有指定区域，则使用账号的区域
-			if regionCode == "" && acc.Region != nil && *acc.Region != "" {
-				regionCode = *acc.Region
-			} else if regionCode == "" {
-				// 如果账号也没有区域信息，使用香港作为默认值
-				regionCode = "ap-east-1"
+			// 如果请求中没有指定区域，则使用账号的区域，账号也没有区域信息时使用香港
+			if regionCode == "" {
+				regionCode = acc.RegionCode()
 			}
 
 			// 验证账号和区域是否匹配
@@ -126,13 +127,18 @@ func (s *InstanceService) Delete(ctx context.Context, userID string, instances [
 			params := aws.DeleteInstanceParams{
 				Region:     regionCode,
 				InstanceID: item.InstanceID,
+				KeepEIP:    item.KeepEIP,
 			}
 
-			if err := awsClient.DeleteInstance(ctx, params); err != nil {
+			deleteResult, err := awsClient.DeleteInstance(ctx, params)
+			if err != nil {
 				result.Status = "失败"
 				result.Message = err.Error()
+				logger.Printf(ctx, "删除实例[%s]失败，账号[%s]: %v", item.InstanceID, item.AccountID, err)
 			} else {
 				result.Status = "成功"
+				result.RetainedAllocationID = deleteResult.RetainedAllocationID
+				result.RetainedIP = deleteResult.RetainedIP
 			}
 
 			mu.Lock()
@@ -224,12 +230,9 @@ func (s *InstanceService) ChangeIP(ctx context.Context, userID string, instances
 			// 优先使用请求中指定的区域
 			regionCode := item.Region
 
-			// 如果请求中没有指定区域，则使用账号的区域
-			if regionCode == "" && acc.Region != nil && *acc.Region != "" {
-				regionCode = *acc.Region
-			} else if regionCode == "" {
-				// 如果账号也没有区域信息，使用香港作为默认值
-				regionCode = "ap-east-1"
+			// 如果请求中没有指定区域，则使用账号的区域，账号也没有区域信息时使用香港
+			if regionCode == "" {
+				regionCode = acc.RegionCode()
 			}
 
 			// 验证账号和区域是否匹配
@@ -298,8 +301,8 @@ func (s *InstanceService) ListAccounts(ctx context.Context, userID string) ([]mo
 
 // ListInstancesRequest 查询实例列表请求
 type ListInstancesRequest struct {
-	AccountIDs []string `json:"account_ids"`
-	Region     string   `json:"region"` // 区域参数可选
+	AccountIDs []string `json:"account_ids"` // 为空时查询用户所有有效账号
+	Region     string   `json:"region"`      // 区域参数可选
 }
 
 // ListInstancesResult 查询实例列表结果
@@ -309,17 +312,33 @@ type ListInstancesResult struct {
 	Error     string             `json:"error,omitempty"`
 }
 
-// ListInstances 批量查询实例列表
+// ListInstances 批量查询实例列表，未指定账号时查询用户所有有效账号
 func (s *InstanceService) ListInstances(ctx context.Context, userID string, req ListInstancesRequest) ([]ListInstancesResult, error) {
-	// 验证账号归属权
-	if err := model.VerifyAccountOwnership(s.repo.DB, userID, req.AccountIDs); err != nil {
-		return nil, err
-	}
+	var accounts []model.Account
+	if len(req.AccountIDs) == 0 {
+		// 未指定账号，查询用户所有有效账号
+		validAccounts, err := model.ListValidAccounts(s.repo.DB, userID)
+		if err != nil {
+			return nil, err
+		}
+		// 指定了区域时只查询该区域的账号，避免其他区域账号全部报区域不匹配
+		for _, acc := range validAccounts {
+			if req.Region == "" || acc.RegionCode() == req.Region {
+				accounts = append(accounts, acc)
+			}
+		}
+	} else {
+		// 验证账号归属权
+		if err := model.VerifyAccountOwnership(s.repo.DB, userID, req.AccountIDs); err != nil {
+			return nil, err
+		}
 
-	// 获取账号信息(包含区域)
-	accounts, err := model.GetAccountKeysByIDs(s.repo.DB, userID, req.AccountIDs)
-	if err != nil {
-		return nil, err
+		// 获取账号信息(包含区域)
+		var err error
+		accounts, err = model.GetAccountKeysByIDs(s.repo.DB, userID, req.AccountIDs)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	var (
@@ -327,6 +346,8 @@ func (s *InstanceService) ListInstances(ctx context.Context, userID string, req
 		wg      sync.WaitGroup
 		mu      sync.Mutex
 	)
+	// 设置合理的并发数量
+	semaphore := make(chan struct{}, 10) // 最多10个并发
 
 	// 并发查询每个账号的实例
 	for _, account := range accounts {
@@ -334,6 +355,10 @@ func (s *InstanceService) ListInstances(ctx context.Context, userID string, req
 		go func(acc model.Account) {
 			defer wg.Done()
 
+			// 获取信号量，控制并发数
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
 			result := ListInstancesResult{
 				AccountID: acc.ID,
 			}
@@ -342,12 +367,9 @@ func (s *InstanceService) ListInstances(ctx context.Context, userID string, req
 			// 优先使用请求中指定的区域
 			regionCode := req.Region
 
-			// 如果请求中没有指定区域，则使用账号的区域
-			if regionCode == "" && acc.Region != nil && *acc.Region != "" {
-				regionCode = *acc.Region
-			} else if regionCode == "" {
-				// 如果账号也没有区域信息，使用香港作为默认值
-				regionCode = "ap-east-1"
+			// 如果请求中没有指定区域，则使用账号的区域，账号也没有区域信息时使用香港
+			if regionCode == "" {
+				regionCode = acc.RegionCode()
 			}
 
 			// 验证账号和区域是否匹配
@@ -0,0 +1,75 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"portal/model"
+	"portal/pkg/region"
+	"portal/pkg/testdb"
+	"portal/repository"
+
+	"gorm.io/gorm"
+)
+
+// startFakeEC2 模拟EC2接口，DescribeInstances返回空列表
+func startFakeEC2(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		action := r.PostForm.Get("Action")
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<%sResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId></%sResponse>`, action, action)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+}
+
+func TestListInstancesAcrossUserAccounts(t *testing.T) {
+	db := testdb.Open(t, model.Models()...)
+	repository.SetDB(db)
+	hk, jp := region.HK, region.JP
+	invalid := "账号已失效"
+	for _, acc := range []model.Account{
+		{ID: "9441", UserID: "list-user", Key1: "AKIA9441", Key2: "secret", Region: &hk},
+		{ID: "9442", UserID: "list-user", Key1: "AKIA9442", Key2: "secret", Region: &jp},
+		{ID: "9443", UserID: "list-user", Key1: "AKIA9443", Key2: "secret", Region: &hk, Quatos: &invalid},
+		{ID: "9444", UserID: "other-user", Key1: "AKIA9444", Key2: "secret", Region: &hk},
+	} {
+		if err := db.Session(&gorm.Session{SkipHooks: true}).Create(&acc).Error; err != nil {
+			t.Fatalf("写入账号失败: %v", err)
+		}
+	}
+	startFakeEC2(t)
+	s := NewInstanceService(db)
+
+	listed := func(regionCode string) []string {
+		t.Helper()
+		results, err := s.ListInstances(context.Background(), "list-user", ListInstancesRequest{Region: regionCode})
+		if err != nil {
+			t.Fatalf("查询实例列表失败: %v", err)
+		}
+		ids := make([]string, 0, len(results))
+		for _, result := range results {
+			if result.Error != "" {
+				t.Errorf("账号%s查询出错: %s", result.AccountID, result.Error)
+			}
+			ids = append(ids, result.AccountID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	// 未指定账号时查询用户所有有效账号，跳过失效账号和其他用户的账号
+	if got := fmt.Sprint(listed("")); got != "[9441 9442]" {
+		t.Fatalf("未指定区域时查询的账号 = %s, want [9441 9442]", got)
+	}
+	// 指定区域时只查询该区域的账号
+	if got := fmt.Sprint(listed(jp)); got != "[9442]" {
+		t.Fatalf("指定日本区时查询的账号 = %s, want [9442]", got)
+	}
+}
@@ -31,6 +31,16 @@ type Column struct {
 	IsNull   string `gorm:"column:IS_NULLABLE"`
 }
 
+// 新增列的回填规则：表名 -> 列名 -> 初始值表达式，仅在列首次添加时执行
+var columnBackfills = map[string]map[string]string{
+	"monitor": {
+		// 区域补机开关默认继承原有的总开关
+		"is_hk_enabled": "`is_enabled`",
+		"is_jp_enabled": "`is_enabled`",
+		"is_sg_enabled": "`is_enabled`",
+	},
+}
+
 // 管理员用户配置
 type AdminConfig struct {
 	Email    string
@@ -140,7 +150,7 @@ func main() {
 	}
 
 	// 4. 同步表结构
-	models := []interface{}{&model.User{}, &model.Account{}, &model.Setting{}, &model.Monitor{}} // 添加 Monitor 模型
+	models := model.Models()
 	fmt.Printf("开始迁移数据表...\n")
 
 	for _, model := range models {
@@ -190,6 +200,19 @@ func main() {
 			fmt.Printf("同步表 %s 结构失败: %v\n", tableName, err)
 			continue
 		}
+
+		// 新增的列按已有数据回填初始值
+		for colName, expr := range columnBackfills[tableName] {
+			if currentColumns[colName] {
+				continue
+			}
+			sql := fmt.Sprintf("UPDATE `%s` SET `%s` = %s", tableName, colName, expr)
+			if err := db.Exec(sql).Error; err != nil {
+				fmt.Printf("回填字段 %s 失败: %v\n", colName, err)
+			} else {
+				fmt.Printf("表 %s: 回填新增字段 %s\n", tableName, colName)
+			}
+		}
 		fmt.Printf("表 %s: 迁移完成\n", tableName)
 	}
 
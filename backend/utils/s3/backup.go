@@ -7,13 +7,16 @@ import (
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
+	"portal/middleware"
+	"portal/pkg/config"
+	"portal/repository"
+
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gin-gonic/gin"
 )
@@ -33,6 +36,9 @@ type S3Config struct {
 	SecretAccessKey string
 	Region          string
 	BucketName      string
+	Profile         string
+	RoleARN         string
+	ExternalID      string
 }
 
 // BackupService 备份服务
@@ -44,21 +50,25 @@ type BackupService struct {
 
 // NewBackupService 创建备份服务
 func NewBackupService() *BackupService {
+	cfg := config.Get()
 	return &BackupService{
 		DBConfig: DBConfig{
-			Host:     os.Getenv("MYSQL_HOST"),
-			Port:     os.Getenv("MYSQL_PORT"),
-			User:     os.Getenv("MYSQL_USERNAME"),
-			Password: os.Getenv("MYSQL_PASSWORD"),
-			Database: os.Getenv("MYSQL_DATABASE"),
+			Host:     cfg.MySQL.Host,
+			Port:     cfg.MySQL.Port,
+			User:     cfg.MySQL.Username,
+			Password: cfg.MySQL.Password,
+			Database: cfg.MySQL.Database,
 		},
 		S3Config: S3Config{
-			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
-			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
-			Region:          os.Getenv("AWS_DEFAULT_REGION"),
-			BucketName:      os.Getenv("BUCKET_NAME"),
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			Region:          cfg.S3.Region,
+			BucketName:      cfg.S3.BucketName,
+			Profile:         cfg.S3.Profile,
+			RoleARN:         cfg.S3.RoleARN,
+			ExternalID:      cfg.S3.ExternalID,
 		},
-		Env: os.Getenv("APP_ENV"),
+		Env: cfg.AppEnv,
 	}
 }
 
@@ -75,7 +85,7 @@ func (s *BackupService) BackupDatabase(forceEnv string) (string, error) {
 		s.DBConfig.User, s.DBConfig.Host, s.DBConfig.Port, s.DBConfig.Database, backupFilePath)
 
 	// 2. 执行mysqldump命令
-	cmd := exec.Command("mysqldump",
+	cmd := newTimedCommand(getCommandTimeout(), "mysqldump",
 		"--host="+s.DBConfig.Host,
 		"--port="+s.DBConfig.Port,
 		"--user="+s.DBConfig.User,
@@ -84,6 +94,7 @@ func (s *BackupService) BackupDatabase(forceEnv string) (string, error) {
 		"--single-transaction",
 		"--quick",
 		"--lock-tables=false")
+	defer cmd.cancel()
 
 	// 创建输出文件
 	outfile, err := os.Create(backupFilePath)
@@ -99,7 +110,7 @@ func (s *BackupService) BackupDatabase(forceEnv string) (string, error) {
 
 	// 执行命令
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("mysqldump执行失败: %v, 错误信息: %s", err, stderr.String())
+		return "", fmt.Errorf("mysqldump执行失败: %v, 错误信息: %s", cmd.explain(err), stderr.String())
 	}
 
 	// 检查文件大小，确保备份文件不是空的
@@ -123,7 +134,7 @@ func (s *BackupService) BackupDatabase(forceEnv string) (string, error) {
 		defer outfile.Close()
 
 		// 使用管道方式执行，可能更可靠
-		cmd = exec.Command("mysqldump",
+		cmd = newTimedCommand(getCommandTimeout(), "mysqldump",
 			"--host="+s.DBConfig.Host,
 			"--port="+s.DBConfig.Port,
 			"--user="+s.DBConfig.User,
@@ -132,6 +143,7 @@ func (s *BackupService) BackupDatabase(forceEnv string) (string, error) {
 			"--single-transaction",
 			"--quick",
 			"--lock-tables=false")
+		defer cmd.cancel()
 
 		// 捕获标准输出和标准错误
 		stdout, err := cmd.StdoutPipe()
@@ -154,7 +166,7 @@ func (s *BackupService) BackupDatabase(forceEnv string) (string, error) {
 
 		// 等待命令完成
 		if err := cmd.Wait(); err != nil {
-			return "", fmt.Errorf("mysqldump命令执行失败: %v, 错误信息: %s", err, stderr.String())
+			return "", fmt.Errorf("mysqldump命令执行失败: %v, 错误信息: %s", cmd.explain(err), stderr.String())
 		}
 
 		// 再次检查文件大小
@@ -176,13 +188,14 @@ func (s *BackupService) BackupDatabase(forceEnv string) (string, error) {
 				backupFilePath)
 
 			// 使用bash执行
-			cmd = exec.Command("bash", "-c", shellCmd)
+			cmd = newTimedCommand(getCommandTimeout(), "bash", "-c", shellCmd)
+			defer cmd.cancel()
 			var output bytes.Buffer
 			cmd.Stdout = &output
 			cmd.Stderr = &stderr
 
 			if err := cmd.Run(); err != nil {
-				return "", fmt.Errorf("系统命令备份失败: %v, 错误信息: %s", err, stderr.String())
+				return "", fmt.Errorf("系统命令备份失败: %v, 错误信息: %s", cmd.explain(err), stderr.String())
 			}
 
 			// 最后检查文件大小
@@ -220,14 +233,7 @@ func (s *BackupService) BackupDatabase(forceEnv string) (string, error) {
 // uploadToS3 上传文件到S3
 func (s *BackupService) uploadToS3(filePath, s3Key string) (string, error) {
 	// 创建AWS会话
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(s.S3Config.Region),
-		Credentials: credentials.NewStaticCredentials(
-			s.S3Config.AccessKeyID,
-			s.S3Config.SecretAccessKey,
-			"",
-		),
-	})
+	sess, err := s.newSession()
 	if err != nil {
 		return "", fmt.Errorf("创建AWS会话失败: %v", err)
 	}
@@ -267,6 +273,170 @@ func (s *BackupService) uploadToS3(filePath, s3Key string) (string, error) {
 	return s3Path, nil
 }
 
+// tableNamePattern 合法表名格式，只允许字母、数字和下划线
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ValidateTables 校验表名格式并确认表在当前数据库中存在，防止注入
+func ValidateTables(tables []string) error {
+	if len(tables) == 0 {
+		return fmt.Errorf("未指定要备份的表")
+	}
+
+	db := repository.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	// 查询当前数据库中的所有表
+	var existingTables []string
+	if err := db.Raw("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE()").
+		Scan(&existingTables).Error; err != nil {
+		return fmt.Errorf("查询数据库表失败: %v", err)
+	}
+	existing := make(map[string]bool, len(existingTables))
+	for _, table := range existingTables {
+		existing[table] = true
+	}
+
+	for _, table := range tables {
+		if !tableNamePattern.MatchString(table) {
+			return fmt.Errorf("表名格式不正确: %s", table)
+		}
+		if !existing[table] {
+			return fmt.Errorf("表不存在: %s", table)
+		}
+	}
+
+	return nil
+}
+
+// buildTableDumpArgs 构建只导出指定表的mysqldump参数
+func (s *BackupService) buildTableDumpArgs(tables []string) []string {
+	args := []string{
+		"--host=" + s.DBConfig.Host,
+		"--port=" + s.DBConfig.Port,
+		"--user=" + s.DBConfig.User,
+		"--password=" + s.DBConfig.Password,
+		"--single-transaction",
+		"--quick",
+		"--lock-tables=false",
+		s.DBConfig.Database,
+	}
+	return append(args, tables...)
+}
+
+// getTablesS3Dir 获取表备份在S3中的目录
+func (s *BackupService) getTablesS3Dir(forceEnv string) string {
+	if forceEnv == "dev" || (forceEnv == "" && s.Env == "dev") {
+		return "portal/dev/tables/"
+	}
+	return "portal/tables/"
+}
+
+// BackupTables 只备份指定的表并上传到S3的独立目录
+func (s *BackupService) BackupTables(tables []string, forceEnv string) (string, error) {
+	if err := ValidateTables(tables); err != nil {
+		return "", err
+	}
+
+	// 1. 创建临时文件
+	timestamp := time.Now().Format("20060102_150405")
+	backupFileName := fmt.Sprintf("%s_%s_%s.sql", s.DBConfig.Database, strings.Join(tables, "-"), timestamp)
+	backupFilePath := filepath.Join(os.TempDir(), backupFileName)
+
+	log.Printf("开始备份表 %v 到文件 %s", tables, backupFilePath)
+
+	outfile, err := os.Create(backupFilePath)
+	if err != nil {
+		return "", fmt.Errorf("创建备份文件失败: %v", err)
+	}
+	defer os.Remove(backupFilePath)
+	defer outfile.Close()
+
+	// 2. 执行mysqldump命令，只导出指定的表
+	cmd := newTimedCommand(getCommandTimeout(), "mysqldump", s.buildTableDumpArgs(tables)...)
+	defer cmd.cancel()
+	var stderr bytes.Buffer
+	cmd.Stdout = outfile
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("mysqldump执行失败: %v, 错误信息: %s", cmd.explain(err), stderr.String())
+	}
+
+	fileInfo, err := os.Stat(backupFilePath)
+	if err != nil {
+		return "", fmt.Errorf("获取备份文件信息失败: %v", err)
+	}
+	if fileInfo.Size() == 0 {
+		return "", fmt.Errorf("备份文件为空")
+	}
+
+	log.Printf("表备份文件 %s 大小: %d 字节", backupFilePath, fileInfo.Size())
+
+	// 3. 上传到S3的表备份目录
+	return s.uploadToS3(backupFilePath, s.getTablesS3Dir(forceEnv)+backupFileName)
+}
+
+// RestoreTables 从S3表备份目录下载备份文件并恢复
+func (s *BackupService) RestoreTables(s3Key string) error {
+	// 只允许从表备份目录恢复
+	if !strings.HasPrefix(s3Key, "portal/tables/") && !strings.HasPrefix(s3Key, "portal/dev/tables/") {
+		return fmt.Errorf("只能从表备份目录恢复: %s", s3Key)
+	}
+	if strings.Contains(s3Key, "..") || !strings.HasSuffix(s3Key, ".sql") {
+		return fmt.Errorf("备份文件路径不合法: %s", s3Key)
+	}
+
+	localPath := filepath.Join(os.TempDir(), filepath.Base(s3Key))
+	if err := s.downloadFromS3(s3Key, localPath); err != nil {
+		return err
+	}
+	defer os.Remove(localPath)
+
+	return s.RestoreDatabase(localPath)
+}
+
+// downloadFromS3 从S3下载文件到本地
+func (s *BackupService) downloadFromS3(s3Key, filePath string) error {
+	sess, err := s.newSession()
+	if err != nil {
+		return fmt.Errorf("创建AWS会话失败: %v", err)
+	}
+
+	result, err := s3.New(sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.S3Config.BucketName),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		return fmt.Errorf("从S3下载备份失败: %v", err)
+	}
+	defer result.Body.Close()
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("创建本地备份文件失败: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, result.Body); err != nil {
+		return fmt.Errorf("写入本地备份文件失败: %v", err)
+	}
+
+	log.Printf("已从S3下载备份: %s -> %s", s3Key, filePath)
+	return nil
+}
+
+// BackupTablesRequest 备份指定表请求
+type BackupTablesRequest struct {
+	Tables []string `json:"tables" binding:"required,min=1"`
+}
+
+// RestoreTablesRequest 恢复表备份请求
+type RestoreTablesRequest struct {
+	S3Key string `json:"s3Key" binding:"required"`
+}
+
 // 在RegisterBackupAPI函数中添加恢复数据库的路由
 func RegisterBackupAPI(router *gin.Engine) {
 	backupService := NewBackupService()
@@ -325,6 +495,92 @@ func RegisterBackupAPI(router *gin.Engine) {
 			},
 		})
 	})
+
+	// 指定表的备份和恢复，需要管理员登录
+	tablesGroup := router.Group("/admin")
+	tablesGroup.Use(middleware.JWTAuthMiddleware())
+	{
+		// 查看定时备份的最近执行情况
+		tablesGroup.GET("/backup/status", func(c *gin.Context) {
+			if !middleware.RequireAdmin(c) {
+				return
+			}
+
+			c.JSON(200, gin.H{
+				"success": true,
+				"message": "获取备份状态成功",
+				"data":    GetBackupStatus(),
+			})
+		})
+
+		tablesGroup.POST("/backup/tables", func(c *gin.Context) {
+			if !middleware.RequireAdmin(c) {
+				return
+			}
+
+			var request BackupTablesRequest
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(400, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("请求参数错误: %v", err),
+				})
+				return
+			}
+
+			s3Path, err := backupService.BackupTables(request.Tables, "")
+			if err != nil {
+				log.Printf("表备份失败: %v", err)
+				c.JSON(500, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("表备份失败: %v", err),
+				})
+				return
+			}
+
+			c.JSON(200, gin.H{
+				"success": true,
+				"message": "表备份成功",
+				"data": gin.H{
+					"s3Path": s3Path,
+					"tables": request.Tables,
+					"time":   time.Now().Format(time.RFC3339),
+				},
+			})
+		})
+
+		tablesGroup.POST("/restore/tables", func(c *gin.Context) {
+			if !middleware.RequireAdmin(c) {
+				return
+			}
+
+			var request RestoreTablesRequest
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(400, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("请求参数错误: %v", err),
+				})
+				return
+			}
+
+			if err := backupService.RestoreTables(request.S3Key); err != nil {
+				log.Printf("表恢复失败: %v", err)
+				c.JSON(500, gin.H{
+					"success": false,
+					"message": fmt.Sprintf("表恢复失败: %v", err),
+				})
+				return
+			}
+
+			c.JSON(200, gin.H{
+				"success": true,
+				"message": "表恢复成功",
+				"data": gin.H{
+					"s3Key": request.S3Key,
+					"time":  time.Now().Format(time.RFC3339),
+				},
+			})
+		})
+	}
 }
 
 // ScheduleBackupTask 定时备份任务
@@ -347,7 +603,9 @@ func ScheduleBackupTask() {
 			fmt.Printf("下一次自动备份将在 %s 进行\n", next.Format("2006-01-02 15:04:05"))
 			time.Sleep(duration)
 
-			s3Path, err := backupService.BackupDatabase("") // 使用当前环境配置
+			s3Path, err := runBackupWithRetry(func() (string, error) {
+				return backupService.BackupDatabase("") // 使用当前环境配置
+			}, getBackupRetries(), backupRetryBaseDelay, notifyBackupFailure)
 			if err != nil {
 				fmt.Printf("定时备份失败: %v\n", err)
 			} else {
@@ -373,42 +631,26 @@ type RestoreDatabaseRequest struct {
 
 // RestoreDatabase 从备份文件恢复数据库
 func (s *BackupService) RestoreDatabase(backupFilePath string) error {
-	// 验证文件是否存在
-	if _, err := os.Stat(backupFilePath); os.IsNotExist(err) {
-		return fmt.Errorf("备份文件不存在: %s", backupFilePath)
+	// 验证文件存在且是mysqldump导出的SQL文件，避免把任意文件直接导入生产库
+	dumpDatabase, err := validateDumpFile(backupFilePath)
+	if err != nil {
+		return err
 	}
-
-	log.Printf("开始从文件 %s 恢复数据库 %s", backupFilePath, s.DBConfig.Database)
-
-	// 构建mysql命令参数
-	args := []string{
-		"-h" + s.DBConfig.Host,
-		"-P" + s.DBConfig.Port,
-		"-u" + s.DBConfig.User,
-		"-p" + s.DBConfig.Password,
-		s.DBConfig.Database,
+	if dumpDatabase != "" && dumpDatabase != s.DBConfig.Database {
+		return fmt.Errorf("备份文件的数据库为 %s，与当前数据库 %s 不一致", dumpDatabase, s.DBConfig.Database)
 	}
 
-	// 创建命令
-	cmd := exec.Command("mysql", args...)
-
-	// 打开备份文件作为输入
-	backupFile, err := os.Open(backupFilePath)
-	if err != nil {
-		return fmt.Errorf("打开备份文件失败: %v", err)
+	// 可选：先导入临时库确认文件能完整执行
+	if shouldScratchCheck() {
+		if err := s.scratchRestore(backupFilePath, dumpDatabase); err != nil {
+			return err
+		}
 	}
-	defer backupFile.Close()
-
-	// 设置文件作为标准输入
-	cmd.Stdin = backupFile
 
-	// 捕获标准错误输出
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	log.Printf("开始从文件 %s 恢复数据库 %s", backupFilePath, s.DBConfig.Database)
 
-	// 执行命令
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("恢复数据库失败: %v, 错误信息: %s", err, stderr.String())
+	if err := s.importFile(backupFilePath, s.DBConfig.Database); err != nil {
+		return fmt.Errorf("恢复数据库失败: %v", err)
 	}
 
 	log.Printf("成功从 %s 恢复数据库 %s", backupFilePath, s.DBConfig.Database)
@@ -0,0 +1,52 @@
+package s3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildTableDumpArgsOnlyRequestedTables(t *testing.T) {
+	s := &BackupService{DBConfig: DBConfig{
+		Host:     "db.internal",
+		Port:     "3306",
+		User:     "portal",
+		Password: "secret",
+		Database: "portal",
+	}}
+
+	args := s.buildTableDumpArgs([]string{"accounts", "monitor"})
+
+	// 数据库名之后只跟请求的表，mysqldump 据此只导出这些表
+	var positional []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+		}
+	}
+	want := []string{"portal", "accounts", "monitor"}
+	if strings.Join(positional, " ") != strings.Join(want, " ") {
+		t.Fatalf("导出目标 = %v, 期望 %v", positional, want)
+	}
+
+	for _, arg := range args {
+		if arg == "--all-databases" || arg == "--databases" {
+			t.Fatalf("表备份不应导出整个数据库: %v", args)
+		}
+	}
+	if !strings.Contains(strings.Join(args, " "), "--host=db.internal") {
+		t.Errorf("应连接配置的数据库: %v", args)
+	}
+}
+
+func TestTableNamePattern(t *testing.T) {
+	for _, name := range []string{"accounts", "makeup_tasks", "T1"} {
+		if !tableNamePattern.MatchString(name) {
+			t.Errorf("合法表名 %q 未通过校验", name)
+		}
+	}
+	for _, name := range []string{"accounts;drop", "a b", "--all-databases", "`users`", ""} {
+		if tableNamePattern.MatchString(name) {
+			t.Errorf("非法表名 %q 通过了校验", name)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// utils/s3/command.go
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 备份和恢复子进程的默认超时时间
+const defaultCommandTimeout = 30 * time.Minute
+
+var (
+	commandTimeout     time.Duration
+	commandTimeoutOnce sync.Once
+)
+
+// getCommandTimeout 获取mysqldump/mysql子进程的超时时间，可通过 BACKUP_COMMAND_TIMEOUT 配置，如"10m"，设为0表示不限制
+func getCommandTimeout() time.Duration {
+	commandTimeoutOnce.Do(func() {
+		commandTimeout = defaultCommandTimeout
+		if value := os.Getenv("BACKUP_COMMAND_TIMEOUT"); value != "" {
+			if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+				commandTimeout = d
+			} else {
+				log.Printf("BACKUP_COMMAND_TIMEOUT配置无效: %s，使用默认值%v", value, defaultCommandTimeout)
+			}
+		}
+	})
+	return commandTimeout
+}
+
+// timedCommand 受超时控制的子进程，超时后结束整个进程组，避免bash启动的子进程残留
+type timedCommand struct {
+	*exec.Cmd
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+// newTimedCommand 创建受超时控制的子进程，使用完毕后需调用cancel释放资源
+func newTimedCommand(timeout time.Duration, name string, args ...string) *timedCommand {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	// 放到独立的进程组中，超时时连同子进程一起结束
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// 进程结束后最多再等待输出管道关闭的时间
+	cmd.WaitDelay = 10 * time.Second
+
+	return &timedCommand{Cmd: cmd, ctx: ctx, cancel: cancel, timeout: timeout}
+}
+
+// explain 命令因超时被结束时返回超时错误，否则原样返回
+func (c *timedCommand) explain(err error) error {
+	if err != nil && errors.Is(c.ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s执行超过%v，已终止进程", c.Path, c.timeout)
+	}
+	return err
+}
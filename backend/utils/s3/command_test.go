@@ -0,0 +1,55 @@
+package s3
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimedCommandKilledOnTimeout(t *testing.T) {
+	// bash 再启动一个子进程，超时后整个进程组都应被结束
+	cmd := newTimedCommand(100*time.Millisecond, "bash", "-c", "sleep 10 & sleep 10")
+	defer cmd.cancel()
+
+	start := time.Now()
+	err := cmd.explain(cmd.Run())
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("超时的命令%v后才返回, 期望立即被终止", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "已终止进程") {
+		t.Fatalf("超时错误 = %v, 期望说明命令已被终止", err)
+	}
+}
+
+func TestTimedCommandWithinTimeout(t *testing.T) {
+	cmd := newTimedCommand(5*time.Second, "bash", "-c", "exit 3")
+	defer cmd.cancel()
+
+	// 未超时的失败保留原始错误
+	err := cmd.explain(cmd.Run())
+	if err == nil || strings.Contains(err.Error(), "已终止进程") {
+		t.Fatalf("未超时的命令错误 = %v, 期望原始的退出错误", err)
+	}
+}
+
+func TestGetCommandTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", defaultCommandTimeout},
+		{"10m", 10 * time.Minute},
+		{"0", 0},
+		{"abc", defaultCommandTimeout},
+		{"-1m", defaultCommandTimeout},
+	}
+	for _, tt := range tests {
+		t.Setenv("BACKUP_COMMAND_TIMEOUT", tt.value)
+		commandTimeoutOnce = sync.Once{}
+		if got := getCommandTimeout(); got != tt.want {
+			t.Errorf("BACKUP_COMMAND_TIMEOUT=%q 超时时间 = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	commandTimeoutOnce = sync.Once{}
+}
@@ -0,0 +1,196 @@
+// utils/s3/restorecheck.go
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 校验备份文件时读取的文件头大小
+const dumpHeadSize = 64 * 1024
+
+// dumpUseRe 匹配 --databases 导出时写入的 USE 语句
+var dumpUseRe = regexp.MustCompile("(?m)^USE `([^`]+)`;")
+
+var (
+	scratchCheck     bool
+	scratchCheckOnce sync.Once
+)
+
+// shouldScratchCheck 恢复前是否先导入临时库验证，通过 BACKUP_RESTORE_SCRATCH_CHECK=true 开启
+// 需要数据库账号有创建和删除库的权限
+func shouldScratchCheck() bool {
+	scratchCheckOnce.Do(func() {
+		scratchCheck = os.Getenv("BACKUP_RESTORE_SCRATCH_CHECK") == "true"
+	})
+	return scratchCheck
+}
+
+// validateDumpFile 检查恢复文件是否为mysqldump导出的SQL文件
+// 返回文件中USE语句指定的库名，未指定时为空
+func validateDumpFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("备份文件不存在: %s", path)
+		}
+		return "", fmt.Errorf("读取备份文件信息失败: %v", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("备份文件不是普通文件: %s", path)
+	}
+	if info.Size() == 0 {
+		return "", fmt.Errorf("备份文件为空: %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开备份文件失败: %v", err)
+	}
+	defer file.Close()
+
+	head := make([]byte, dumpHeadSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("读取备份文件失败: %v", err)
+	}
+	head = head[:n]
+
+	if bytes.IndexByte(head, 0) >= 0 {
+		return "", fmt.Errorf("备份文件包含二进制内容，不是SQL文件")
+	}
+
+	text := string(head)
+	isDump := strings.HasPrefix(text, "-- MySQL dump") || strings.HasPrefix(text, "-- MariaDB dump")
+	if !isDump && !strings.Contains(text, "CREATE TABLE") && !strings.Contains(text, "INSERT INTO") {
+		return "", fmt.Errorf("备份文件不是mysqldump导出的SQL文件")
+	}
+
+	database := ""
+	if match := dumpUseRe.FindStringSubmatch(text); match != nil {
+		database = match[1]
+	}
+	return database, nil
+}
+
+// scratchRestore 将备份文件导入临时库验证能否完整执行，验证后删除临时库
+// --databases 导出的文件带有 CREATE DATABASE 和 USE 语句，导入临时库时去掉，避免切换到原库
+func (s *BackupService) scratchRestore(backupFilePath string, dumpDatabase string) error {
+	if dumpDatabase != "" {
+		log.Printf("备份文件指定了数据库 %s，导入临时库时去掉建库和切换库语句", dumpDatabase)
+	}
+
+	scratch := fmt.Sprintf("%s_restore_check_%d", s.DBConfig.Database, time.Now().Unix())
+	if err := s.execSQL(fmt.Sprintf("CREATE DATABASE `%s`", scratch)); err != nil {
+		return fmt.Errorf("创建临时库失败: %v", err)
+	}
+	defer func() {
+		if err := s.execSQL(fmt.Sprintf("DROP DATABASE `%s`", scratch)); err != nil {
+			log.Printf("删除临时库 %s 失败: %v", scratch, err)
+		}
+	}()
+
+	backupFile, err := os.Open(backupFilePath)
+	if err != nil {
+		return fmt.Errorf("打开备份文件失败: %v", err)
+	}
+	defer backupFile.Close()
+
+	// 边读边过滤，导入提前结束时关闭管道，让过滤协程退出
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		writer.CloseWithError(stripDatabaseStatements(writer, backupFile))
+	}()
+
+	if err := s.importReader(reader, scratch); err != nil {
+		return fmt.Errorf("导入临时库验证失败: %v", err)
+	}
+	log.Printf("备份文件 %s 已通过临时库验证", backupFilePath)
+	return nil
+}
+
+// stripDatabaseStatements 复制SQL内容，去掉行首的 CREATE DATABASE 和 USE 语句，使其可以导入到任意库
+// 按块读取，超长的INSERT行不会整行载入内存
+func stripDatabaseStatements(dst io.Writer, src io.Reader) error {
+	reader := bufio.NewReaderSize(src, dumpHeadSize)
+	atLineStart, skipping := true, false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if atLineStart {
+			skipping = bytes.HasPrefix(chunk, []byte("CREATE DATABASE ")) || bytes.HasPrefix(chunk, []byte("USE `"))
+		}
+		if !skipping && len(chunk) > 0 {
+			if _, writeErr := dst.Write(chunk); writeErr != nil {
+				return writeErr
+			}
+		}
+
+		switch err {
+		case nil:
+			atLineStart = true
+		case bufio.ErrBufferFull:
+			atLineStart = false
+		case io.EOF:
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// execSQL 执行单条不指定库的SQL语句
+func (s *BackupService) execSQL(statement string) error {
+	cmd := newTimedCommand(getCommandTimeout(), "mysql",
+		"-h"+s.DBConfig.Host,
+		"-P"+s.DBConfig.Port,
+		"-u"+s.DBConfig.User,
+		"-p"+s.DBConfig.Password,
+		"-e", statement)
+	defer cmd.cancel()
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v, 错误信息: %s", cmd.explain(err), stderr.String())
+	}
+	return nil
+}
+
+// importFile 将SQL文件导入指定的库
+func (s *BackupService) importFile(backupFilePath string, database string) error {
+	backupFile, err := os.Open(backupFilePath)
+	if err != nil {
+		return fmt.Errorf("打开备份文件失败: %v", err)
+	}
+	defer backupFile.Close()
+
+	return s.importReader(backupFile, database)
+}
+
+// importReader 将SQL内容导入指定的库
+func (s *BackupService) importReader(sql io.Reader, database string) error {
+	cmd := newTimedCommand(getCommandTimeout(), "mysql",
+		"-h"+s.DBConfig.Host,
+		"-P"+s.DBConfig.Port,
+		"-u"+s.DBConfig.User,
+		"-p"+s.DBConfig.Password,
+		database)
+	defer cmd.cancel()
+
+	cmd.Stdin = sql
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v, 错误信息: %s", cmd.explain(err), stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package s3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateDumpFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatalf("写入测试文件失败: %v", err)
+		}
+		return path
+	}
+
+	dump := write("dump.sql", []byte("-- MySQL dump 10.13  Distrib 8.0.36\n--\n-- Host: localhost    Database: portal\n"+
+		"CREATE TABLE `accounts` (`id` varchar(255) NOT NULL);\nINSERT INTO `accounts` VALUES ('1');\n"))
+	if database, err := validateDumpFile(dump); err != nil || database != "" {
+		t.Fatalf("validateDumpFile(有效备份) = %q, %v", database, err)
+	}
+
+	withUse := write("databases.sql", []byte("-- MySQL dump 10.13\nCREATE DATABASE `portal`;\n\nUSE `portal`;\nCREATE TABLE `t` (`id` int);\n"))
+	if database, err := validateDumpFile(withUse); err != nil || database != "portal" {
+		t.Fatalf("validateDumpFile(带USE语句) = %q, %v, want portal", database, err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"纯文本", write("notes.txt", []byte("hello, this is not a dump\n")), "不是mysqldump导出的SQL文件"},
+		{"二进制文件", write("backup.gz", []byte{0x1f, 0x8b, 0x08, 0x00, 0x00}), "二进制内容"},
+		{"空文件", write("empty.sql", nil), "备份文件为空"},
+		{"文件不存在", filepath.Join(dir, "missing.sql"), "备份文件不存在"},
+		{"目录", dir, "不是普通文件"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateDumpFile(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateDumpFile() = %v, want 包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStripDatabaseStatements(t *testing.T) {
+	// 超过读取缓冲区的INSERT行，缓冲区边界恰好落在行内的 USE 字样上，不应被当作语句去掉
+	prefix := "INSERT INTO `notes` VALUES ('"
+	longLine := prefix + strings.Repeat("a", dumpHeadSize-len(prefix)) + "USE `portal`;');\n"
+
+	dump := "-- MySQL dump 10.13\n" +
+		"CREATE DATABASE /*!32312 IF NOT EXISTS*/ `portal` /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\n" +
+		"\n" +
+		"USE `portal`;\n" +
+		"CREATE TABLE `notes` (`body` text);\n" +
+		longLine +
+		"-- Dump completed"
+	want := "-- MySQL dump 10.13\n" +
+		"\n" +
+		"CREATE TABLE `notes` (`body` text);\n" +
+		longLine +
+		"-- Dump completed"
+
+	var out bytes.Buffer
+	if err := stripDatabaseStatements(&out, strings.NewReader(dump)); err != nil {
+		t.Fatalf("过滤失败: %v", err)
+	}
+	if out.String() != want {
+		t.Fatalf("过滤结果不正确，长度 %d, want %d", out.Len(), len(want))
+	}
+}
@@ -0,0 +1,114 @@
+// utils/s3/schedule.go
+package s3
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"portal/pkg/tg"
+	"portal/repository"
+)
+
+const (
+	// 定时备份失败后的默认重试次数
+	defaultBackupRetries = 3
+	// 第一次重试前的等待时间，之后每次翻倍
+	backupRetryBaseDelay = 5 * time.Minute
+)
+
+var (
+	backupRetries     int
+	backupRetriesOnce sync.Once
+)
+
+// getBackupRetries 获取定时备份失败后的重试次数，可通过 BACKUP_RETRY_COUNT 配置
+func getBackupRetries() int {
+	backupRetriesOnce.Do(func() {
+		backupRetries = defaultBackupRetries
+		if value := os.Getenv("BACKUP_RETRY_COUNT"); value != "" {
+			if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+				backupRetries = n
+			} else {
+				log.Printf("BACKUP_RETRY_COUNT配置无效: %s，使用默认值%d", value, defaultBackupRetries)
+			}
+		}
+	})
+	return backupRetries
+}
+
+// BackupStatus 定时备份的最近执行情况
+type BackupStatus struct {
+	LastSuccessAt   *time.Time `json:"lastSuccessAt"`
+	LastSuccessPath string     `json:"lastSuccessPath"`
+	LastFailureAt   *time.Time `json:"lastFailureAt"`
+	LastError       string     `json:"lastError"`
+}
+
+var (
+	backupStatusMu sync.RWMutex
+	backupStatus   BackupStatus
+)
+
+// GetBackupStatus 获取定时备份的最近执行情况
+func GetBackupStatus() BackupStatus {
+	backupStatusMu.RLock()
+	defer backupStatusMu.RUnlock()
+	return backupStatus
+}
+
+// recordBackupSuccess 记录备份成功
+func recordBackupSuccess(s3Path string) {
+	now := time.Now()
+	backupStatusMu.Lock()
+	backupStatus.LastSuccessAt = &now
+	backupStatus.LastSuccessPath = s3Path
+	backupStatusMu.Unlock()
+}
+
+// recordBackupFailure 记录备份失败
+func recordBackupFailure(err error) {
+	now := time.Now()
+	backupStatusMu.Lock()
+	backupStatus.LastFailureAt = &now
+	backupStatus.LastError = err.Error()
+	backupStatusMu.Unlock()
+}
+
+// runBackupWithRetry 执行备份，失败时按指数退避重试，全部失败后调用alert告警
+func runBackupWithRetry(backup func() (string, error), retries int, baseDelay time.Duration, alert func(string)) (string, error) {
+	var lastErr error
+	delay := baseDelay
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("定时备份第%d次失败，%v后重试: %v", attempt, delay, lastErr)
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		s3Path, err := backup()
+		if err == nil {
+			recordBackupSuccess(s3Path)
+			return s3Path, nil
+		}
+		lastErr = err
+	}
+
+	recordBackupFailure(lastErr)
+	message := fmt.Sprintf("⚠️ 定时数据库备份失败\n已重试: %d次\n错误: %v", retries, lastErr)
+	if status := GetBackupStatus(); status.LastSuccessAt != nil {
+		message += fmt.Sprintf("\n上次成功: %s", status.LastSuccessAt.Format("2006-01-02 15:04:05"))
+	}
+	alert(message)
+	return "", lastErr
+}
+
+// notifyBackupFailure 向管理员发送备份失败告警
+func notifyBackupFailure(message string) {
+	if err := tg.NotifyAdminMessage(repository.GetDB(), message); err != nil {
+		log.Printf("发送备份失败告警失败: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetBackupStatus 清空定时备份状态，测试结束后恢复
+func resetBackupStatus(t *testing.T) {
+	t.Helper()
+	backupStatusMu.Lock()
+	old := backupStatus
+	backupStatus = BackupStatus{}
+	backupStatusMu.Unlock()
+	t.Cleanup(func() {
+		backupStatusMu.Lock()
+		backupStatus = old
+		backupStatusMu.Unlock()
+	})
+}
+
+func TestRunBackupWithRetryAlertsAfterFailures(t *testing.T) {
+	resetBackupStatus(t)
+
+	attempts := 0
+	var alerts []string
+	_, err := runBackupWithRetry(func() (string, error) {
+		attempts++
+		return "", errors.New("mysqldump exited with status 2")
+	}, 2, time.Millisecond, func(message string) {
+		alerts = append(alerts, message)
+	})
+
+	if err == nil {
+		t.Fatal("全部重试失败后应返回错误")
+	}
+	if attempts != 3 {
+		t.Fatalf("备份执行%d次, 期望首次执行加重试2次共3次", attempts)
+	}
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "已重试: 2次") || !strings.Contains(alerts[0], "mysqldump exited") {
+		t.Fatalf("告警 = %q, 期望发送一次包含重试次数和错误的告警", alerts)
+	}
+	if status := GetBackupStatus(); status.LastFailureAt == nil || status.LastError != err.Error() {
+		t.Fatalf("备份状态 = %+v, 期望记录最后一次失败", status)
+	}
+}
+
+func TestRunBackupWithRetrySucceedsWithoutAlert(t *testing.T) {
+	resetBackupStatus(t)
+
+	attempts := 0
+	path, err := runBackupWithRetry(func() (string, error) {
+		attempts++
+		if attempts == 1 {
+			return "", errors.New("temporary failure")
+		}
+		return "s3://backup/portal.sql.gz", nil
+	}, 3, time.Millisecond, func(message string) {
+		t.Fatalf("重试成功后不应告警: %s", message)
+	})
+
+	if err != nil || path != "s3://backup/portal.sql.gz" || attempts != 2 {
+		t.Fatalf("备份结果 = %q, %v（执行%d次）, 期望第2次成功", path, err, attempts)
+	}
+	if status := GetBackupStatus(); status.LastSuccessAt == nil || status.LastSuccessPath != path {
+		t.Fatalf("备份状态 = %+v, 期望记录成功", status)
+	}
+}
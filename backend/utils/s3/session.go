@@ -0,0 +1,52 @@
+// utils/s3/session.go
+package s3
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// newSession 创建备份专用的AWS会话
+// 只使用 S3Config 中的备份凭证，与实例管理账号的凭证互不影响：
+//   - 设置了静态密钥：直接使用该密钥
+//   - 设置了 Profile：从共享配置 (~/.aws/config、~/.aws/credentials) 读取该 profile
+//   - 都未设置：使用SDK默认凭证链（例如运行环境的实例角色）
+//
+// 若设置了 RoleARN，则以上述凭证为基础 AssumeRole 到备份账号的角色。
+func (s *BackupService) newSession() (*session.Session, error) {
+	cfg := s.S3Config
+	opts := session.Options{
+		Config: aws.Config{Region: aws.String(cfg.Region)},
+	}
+	switch {
+	case cfg.AccessKeyID != "":
+		opts.Config.Credentials = credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	case cfg.Profile != "":
+		opts.Profile = cfg.Profile
+		opts.SharedConfigState = session.SharedConfigEnable
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RoleARN == "" {
+		return sess, nil
+	}
+
+	roleCreds := stscreds.NewCredentials(sess, cfg.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = "portal-backup"
+		if cfg.ExternalID != "" {
+			p.ExternalID = aws.String(cfg.ExternalID)
+		}
+	})
+	roleSess, err := session.NewSession(sess.Config.Copy(&aws.Config{Credentials: roleCreds}))
+	if err != nil {
+		return nil, fmt.Errorf("扮演备份角色 %s 失败: %v", cfg.RoleARN, err)
+	}
+	return roleSess, nil
+}
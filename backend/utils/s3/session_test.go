@@ -0,0 +1,60 @@
+package s3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSessionUsesBackupCredentials(t *testing.T) {
+	// 运行环境中的默认凭证不应被备份会话使用
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAINSTANCE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "instance-secret")
+
+	s := &BackupService{S3Config: S3Config{
+		AccessKeyID:     "AKIABACKUP",
+		SecretAccessKey: "backup-secret",
+		Region:          "ap-northeast-1",
+	}}
+	sess, err := s.newSession()
+	if err != nil {
+		t.Fatalf("创建备份会话失败: %v", err)
+	}
+	creds, err := sess.Config.Credentials.Get()
+	if err != nil {
+		t.Fatalf("读取备份会话凭证失败: %v", err)
+	}
+	if creds.AccessKeyID != "AKIABACKUP" || creds.SecretAccessKey != "backup-secret" {
+		t.Fatalf("备份会话使用的凭证 = %s, 期望备份专用凭证", creds.AccessKeyID)
+	}
+	if region := *sess.Config.Region; region != "ap-northeast-1" {
+		t.Fatalf("备份会话区域 = %s, want ap-northeast-1", region)
+	}
+}
+
+func TestNewSessionUsesBackupProfile(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAINSTANCE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "instance-secret")
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	content := "[default]\naws_access_key_id = AKIADEFAULT\naws_secret_access_key = default-secret\n\n" +
+		"[backup]\naws_access_key_id = AKIAPROFILE\naws_secret_access_key = profile-secret\n"
+	if err := os.WriteFile(credentialsFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("写入共享凭证文件失败: %v", err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	s := &BackupService{S3Config: S3Config{Region: "ap-northeast-1", Profile: "backup"}}
+	sess, err := s.newSession()
+	if err != nil {
+		t.Fatalf("创建备份会话失败: %v", err)
+	}
+	creds, err := sess.Config.Credentials.Get()
+	if err != nil {
+		t.Fatalf("读取备份会话凭证失败: %v", err)
+	}
+	if creds.AccessKeyID != "AKIAPROFILE" {
+		t.Fatalf("备份会话使用的凭证 = %s, 期望 backup profile 的凭证", creds.AccessKeyID)
+	}
+}